@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/app"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/configs"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/logger"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+const usage = `Usage: admin <command> [flags]
+
+Commands:
+  retention    apply the data retention policy now (-dry-run only reports what it would remove)
+`
+
+// admin runs one-off maintenance tasks against the same configuration as cmd/web and
+// cmd/worker, e.g. "admin retention -dry-run".
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "retention":
+		retention(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+func retention(args []string) {
+	fs := flag.NewFlagSet("retention", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "count what would be removed without changing anything")
+	fs.Parse(args)
+
+	application, log := setup()
+	defer application.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := application.Services.Retention.Run(ctx, *dryRun)
+	printRetentionReport(report)
+	if err != nil {
+		log.Errorf("Retention failed: %v", err)
+		application.Close()
+		os.Exit(1)
+	}
+}
+
+func setup() (*app.App, *logrus.Logger) {
+	log := logger.NewLogger()
+
+	cfg, err := configs.LoadConfig(log)
+	if err != nil {
+		log.Fatalf("FATAL: Gagal memuat konfigurasi: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	application, err := app.New(ctx, cfg, log)
+	if err != nil {
+		log.Fatalf("Failed to initialize application: %v", err)
+	}
+
+	return application, log
+}
+
+func printRetentionReport(report *services.RetentionReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	action := "removed"
+	if report.DryRun {
+		action = "would remove"
+	}
+
+	fmt.Fprintf(w, "dry run\t%t\n", report.DryRun)
+	fmt.Fprintf(w, "deleted user mode\t%s\n", report.DeletedUserMode)
+	fmt.Fprintf(w, "duration\t%s\n", report.FinishedAt.Sub(report.StartedAt).Round(time.Millisecond))
+	fmt.Fprintf(w, "\n%s\t\n", action)
+	fmt.Fprintf(w, "users\t%d\n", report.Users)
+	fmt.Fprintf(w, "sessions\t%d\n", report.Sessions)
+	fmt.Fprintf(w, "api keys\t%d\n", report.APIKeys)
+	fmt.Fprintf(w, "identities\t%d\n", report.Identities)
+	fmt.Fprintf(w, "outbox events\t%d\n", report.OutboxEvents)
+	fmt.Fprintf(w, "campaign deliveries\t%d\n", report.CampaignDeliveries)
+	fmt.Fprintf(w, "audit events\t%d\n", report.AuditEvents)
+}
@@ -2,33 +2,25 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"net"
+	"strings"
 	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
-	"github.com/RehanAthallahAzhar/shopeezy-accounts/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/app"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/configs"
-	dbGenerated "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/handlers"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
 	customMiddleware "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/middlewares" // Import middleware kita
-	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/logger"
-	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/redisclient"
-	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/routes"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
-	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
 
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
 
 	grpcServer "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/grpc"
@@ -44,72 +36,33 @@ func main() {
 		log.Fatalf("FATAL: Gagal memuat konfigurasi: %v", err)
 	}
 
-	dbCredential := models.Credential{
-		Host:         cfg.Database.Host,
-		Username:     cfg.Database.User,
-		Password:     cfg.Database.Password,
-		DatabaseName: cfg.Database.Name,
-		Port:         cfg.Database.Port,
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Setup DB
-	conn, err := db.Connect(ctx, &dbCredential)
+	// Setup DB, Redis, repositories and services, shared with cmd/worker
+	application, err := app.New(ctx, cfg, log)
 	if err != nil {
-		log.Fatalf("DB connection error: %v", err)
+		log.Fatalf("Failed to initialize application: %v", err)
 	}
+	defer application.Close()
 
 	// Migrations
 	log.Println("Running database migrations...")
-
-	connectionString := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable",
-		dbCredential.Username,
-		dbCredential.Password,
-		dbCredential.Host,
-		dbCredential.Port,
-		dbCredential.DatabaseName,
-	)
-
-	m, err := migrate.New(
-		cfg.Migration.Path,
-		connectionString,
-	)
-	if err != nil {
-		log.Fatalf("Failed to create migrate instance: %v", err)
+	if err := application.Migrate(); err != nil {
+		log.Fatalf("Database migration error: %v", err)
 	}
-
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		log.Fatalf("Failed to run migrations: %v", err)
-	}
-
 	log.Println("Database migrations ran successfully.")
 
-	defer conn.Close()
-
-	// Init SQLC
-	sqlcQueries := dbGenerated.New(conn)
-
 	//jwt
 	_ = helpers.NewJWTHelper(cfg.Server.JWTSecret)
 
-	// Setup Redis
-	redisClient, err := redisclient.NewRedisClient()
-	if err != nil {
-		log.Fatalf("Failed to Inilialization redis client : %v", err)
+	switch cfg.Auth.ServiceAuthMode {
+	case customMiddleware.ServiceAuthEnforce, customMiddleware.ServiceAuthPermissive:
+	default:
+		log.Fatalf("Invalid SERVICE_AUTH_MODE %q", cfg.Auth.ServiceAuthMode)
 	}
-	defer redisClient.Close() // Make sure the Redis connection is closed
-
-	// Setup Repo
-	usersRepo := repositories.NewUserRepository(sqlcQueries, log)
-	jwtBlacklistRepo := repositories.NewJWTBlacklistRepository(redisClient)
-
-	validate := validator.New()
 
-	// Setup Service
-	tokenService := token.NewJWTTokenService(cfg.Server.JWTSecret, jwtBlacklistRepo)
-	userService := services.NewUserService(usersRepo, validate, tokenService, jwtBlacklistRepo, log)
+	repos, svc := application.Repositories, application.Services
 
 	// Setup gRPC
 	lis, err := net.Listen("tcp", ":"+cfg.Server.GRPCPort)
@@ -117,9 +70,42 @@ func main() {
 		log.Fatalf("Failed to listen for gRPC server: %s: %v", cfg.Server.GRPCPort, err)
 	}
 
-	s := grpc.NewServer()
-	authpb.RegisterAuthServiceServer(s, grpcServer.NewAuthServer(tokenService))
-	accountpb.RegisterAccountServiceServer(s, grpcServer.NewAccountServer(userService))
+	rateLimiter := customMiddleware.NewRateLimiter(application.Redis)
+
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			customMiddleware.UnaryRateLimitInterceptor(customMiddleware.GRPCRateLimitOptions{
+				Limiter: rateLimiter,
+				Name:    "grpc-account",
+				Limit: customMiddleware.RateLimit{
+					Limit:  cfg.Server.GRPCRateLimit,
+					Window: cfg.Server.GRPCRateLimitWindow,
+				},
+				Services: []string{
+					accountpb.AccountService_ServiceDesc.ServiceName,
+					grpcServer.AccountSearchService_ServiceDesc.ServiceName,
+				},
+			}),
+			customMiddleware.UnaryPermissionInterceptor(customMiddleware.GRPCPermissionOptions{
+				TokenService: svc.Token,
+				Methods: map[string]string{
+					"/account.AccountSearchService/SearchUsers": entities.PermUsersRead,
+				},
+			}),
+			customMiddleware.UnaryServiceAuthInterceptor(customMiddleware.GRPCServiceAuthOptions{
+				Validator: svc.OAuth,
+				Services:  []string{accountpb.AccountService_ServiceDesc.ServiceName},
+				Methods: map[string]string{
+					accountpb.AccountService_GetUser_FullMethodName:  entities.PermUsersRead,
+					accountpb.AccountService_GetUsers_FullMethodName: entities.PermUsersRead,
+				},
+				Permissive: cfg.Auth.ServiceAuthMode == customMiddleware.ServiceAuthPermissive,
+			}),
+		),
+	)
+	authpb.RegisterAuthServiceServer(s, grpcServer.NewAuthServer(svc.Token))
+	accountpb.RegisterAccountServiceServer(s, grpcServer.NewAccountServer(svc.User))
+	grpcServer.RegisterAccountSearchServiceServer(s, grpcServer.NewSearchServer(svc.User))
 	reflection.Register(s)
 
 	log.Printf("gRPC server for Account service is listening on port %s", lis.Addr())
@@ -134,10 +120,22 @@ func main() {
 
 	e.Use(middleware.RequestID())
 	e.Use(customMiddleware.LoggingMiddleware(log))
+	e.Use(customMiddleware.AuditContextMiddleware())
+
+	renderer, err := handlers.NewTemplateRenderer(cfg.Auth.OIDCTemplates)
+	if err != nil {
+		log.Fatalf("Failed to load templates: %v", err)
+	}
+	e.Renderer = renderer
 
 	// Setup Route
-	handler := handlers.NewHandler(usersRepo, userService, tokenService, jwtBlacklistRepo, log)
-	routes.InitRoutes(e, handler, tokenService)
+	handler := handlers.NewHandler(repos.Users, svc.User, svc.Session, svc.MFA, svc.Password, svc.EmailVerification, svc.LoginThrottle, svc.Role, svc.Tenancy, svc.POS, svc.APIKey, svc.OAuth, svc.OIDC, svc.Passwordless, svc.Preference, svc.Federation, svc.WebAuthn, svc.Campaign, svc.Audit, svc.Token, repos.JWTBlacklist, log)
+	routes.InitRoutes(e, handler, routes.Options{
+		TokenService:         svc.Token,
+		RateLimiter:          rateLimiter,
+		RequireVerifiedEmail: cfg.Auth.EmailVerificationMode == services.EmailVerificationRestricted,
+		SecureCookies:        strings.HasPrefix(cfg.Auth.OIDCIssuer, "https://"),
+	})
 
 	// Start Echo API REST Server (Block main goroutine)
 	log.Printf("Server REST API Echo is listening on port %s", cfg.Server.Port)
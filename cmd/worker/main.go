@@ -1 +1,145 @@
 package main
+
+import (
+	"context"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/app"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/configs"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/consumers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/crons"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/logger"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/rabbitmq"
+)
+
+// The worker runs everything that does not answer a request: the outbox relay, the RabbitMQ
+// consumers and the cron jobs. On SIGINT or SIGTERM it stops taking new work and waits up to
+// WORKER_SHUTDOWN_TIMEOUT for the work in flight. Migrations are left to cmd/web.
+func main() {
+	log := logger.NewLogger()
+
+	cfg, err := configs.LoadConfig(log)
+	if err != nil {
+		log.Fatalf("FATAL: Gagal memuat konfigurasi: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	initCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	application, err := app.New(initCtx, cfg, log)
+	if err != nil {
+		log.Fatalf("Failed to initialize application: %v", err)
+	}
+	defer application.Close()
+
+	svc := application.Services
+
+	var wg sync.WaitGroup
+
+	// Outbox relay
+	if cfg.Outbox.RelayEnabled {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			svc.OutboxRelay.Run(ctx)
+		}()
+	}
+
+	// Consumers
+	accountEvents, err := rabbitmq.NewRabbitMQClient(cfg.RabbitMQ.URL, cfg.Worker.AccountEventsQueue)
+	if err != nil {
+		log.Fatalf("Failed to initialize RabbitMQ consumer: %v", err)
+	}
+	defer accountEvents.Close()
+
+	if err := accountEvents.BindQueue(cfg.RabbitMQ.EventsExchange, consumers.AccountEventRoutingKeys...); err != nil {
+		log.Fatalf("Failed to bind queue: %v", err)
+	}
+
+	accountEventConsumer := consumers.NewAccountEventConsumer(svc.SecurityNotice, log)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := accountEvents.ConsumeMessages(ctx, rabbitmq.ConsumeOptions{
+			Concurrency:    cfg.Worker.Concurrency,
+			MaxRetries:     cfg.Worker.MaxRetries,
+			RetryDelay:     cfg.Worker.RetryDelay,
+			MaxRetryDelay:  cfg.Worker.MaxRetryDelay,
+			ConfirmTimeout: cfg.RabbitMQ.PublishTimeout,
+		}, accountEventConsumer.Handle)
+		if err != nil {
+			// Without its consumer the worker is useless, let the orchestrator restart it
+			log.WithError(err).Error("Account event consumer stopped")
+			stop()
+		}
+	}()
+
+	campaignDeliveries, err := rabbitmq.NewRabbitMQClient(cfg.RabbitMQ.URL, cfg.Campaign.DeliveryQueue)
+	if err != nil {
+		log.Fatalf("Failed to initialize RabbitMQ consumer: %v", err)
+	}
+	defer campaignDeliveries.Close()
+
+	if err := campaignDeliveries.BindQueue(cfg.Campaign.Exchange, entities.CampaignDeliveryRoutingKey); err != nil {
+		log.Fatalf("Failed to bind queue: %v", err)
+	}
+
+	campaignDeliveryConsumer := consumers.NewCampaignDeliveryConsumer(svc.Campaign, log)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := campaignDeliveries.ConsumeMessages(ctx, rabbitmq.ConsumeOptions{
+			Concurrency:    cfg.Worker.Concurrency,
+			MaxRetries:     cfg.Worker.MaxRetries,
+			RetryDelay:     cfg.Worker.RetryDelay,
+			MaxRetryDelay:  cfg.Worker.MaxRetryDelay,
+			ConfirmTimeout: cfg.RabbitMQ.PublishTimeout,
+		}, campaignDeliveryConsumer.Handle)
+		if err != nil {
+			log.WithError(err).Error("Campaign delivery consumer stopped")
+			stop()
+		}
+	}()
+
+	// Cron
+	scheduler := crons.NewScheduler(log)
+	if err := scheduler.Add("purge-expired-tokens", cfg.Worker.TokenPurgeSchedule, crons.PurgeExpiredTokens(svc.TokenPurge)); err != nil {
+		log.Fatalf("Failed to schedule cron job: %v", err)
+	}
+	if err := scheduler.Add("dispatch-campaigns", cfg.Campaign.DispatchSchedule, crons.DispatchCampaigns(svc.Campaign)); err != nil {
+		log.Fatalf("Failed to schedule cron job: %v", err)
+	}
+	if err := scheduler.Add("apply-retention", cfg.Retention.Schedule, crons.ApplyRetention(svc.Retention)); err != nil {
+		log.Fatalf("Failed to schedule cron job: %v", err)
+	}
+	scheduler.Start()
+
+	log.Info("Worker started")
+	<-ctx.Done()
+	log.Info("Shutting down worker...")
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.Worker.ShutdownTimeout)
+	defer cancelShutdown()
+
+	scheduler.Stop(shutdownCtx)
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Info("Worker stopped")
+	case <-shutdownCtx.Done():
+		log.Warn("Worker shutdown timed out, exiting with work in flight")
+	}
+}
@@ -0,0 +1,2 @@
+-- file: 000002_create_refresh_tokens_table.down.sql
+DROP TABLE IF EXISTS refresh_tokens;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- file: 000003_create_mfa_tables.down.sql
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
\ No newline at end of file
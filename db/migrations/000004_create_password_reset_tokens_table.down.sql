@@ -0,0 +1,2 @@
+-- file: 000004_create_password_reset_tokens_table.down.sql
+DROP TABLE IF EXISTS password_reset_tokens;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- file: 000005_add_email_verified_at_to_users.down.sql
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- file: 000005_add_email_verified_at_to_users.up.sql
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
\ No newline at end of file
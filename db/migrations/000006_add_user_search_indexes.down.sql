@@ -0,0 +1,6 @@
+-- file: 000006_add_user_search_indexes.down.sql
+DROP INDEX IF EXISTS idx_users_search_tsv;
+DROP INDEX IF EXISTS idx_users_phone_number_trgm;
+DROP INDEX IF EXISTS idx_users_email_trgm;
+DROP INDEX IF EXISTS idx_users_username_trgm;
+DROP INDEX IF EXISTS idx_users_name_trgm;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- file: 000006_add_user_search_indexes.up.sql
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Trigram indexes serve both similarity (%) and substring (ILIKE) matches, e.g. partial phone numbers
+CREATE INDEX IF NOT EXISTS idx_users_name_trgm ON users USING gin ("name" gin_trgm_ops) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING gin (username gin_trgm_ops) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING gin (email gin_trgm_ops) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_phone_number_trgm ON users USING gin (phone_number gin_trgm_ops) WHERE deleted_at IS NULL;
+
+-- Must stay identical to the expression used by the SearchUsers query
+CREATE INDEX IF NOT EXISTS idx_users_search_tsv ON users USING gin (
+    to_tsvector('simple', "name" || ' ' || username || ' ' || email || ' ' || phone_number)
+) WHERE deleted_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- file: 000007_add_unique_username_email.down.sql
+DROP INDEX IF EXISTS idx_users_email_lower_unique;
+DROP INDEX IF EXISTS idx_users_username_lower_unique;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- file: 000007_add_unique_username_email.up.sql
+-- Case-insensitive uniqueness among active users, a soft-deleted account frees its username and email.
+-- Existing duplicates have to be resolved before this migration can run.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower_unique ON users (lower(username)) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower_unique ON users (lower(email)) WHERE deleted_at IS NULL;
\ No newline at end of file
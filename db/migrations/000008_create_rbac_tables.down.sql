@@ -0,0 +1,5 @@
+-- file: 000008_create_rbac_tables.down.sql
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
\ No newline at end of file
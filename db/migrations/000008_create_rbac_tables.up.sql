@@ -0,0 +1,63 @@
+-- file: 000008_create_rbac_tables.up.sql
+CREATE TABLE IF NOT EXISTS roles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "name" TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    -- the highest priority role of a user is mirrored to users.role for clients that read a single role
+    priority INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "name" TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id UUID NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    permission_id UUID NOT NULL REFERENCES permissions (id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role_id UUID NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    granted_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles (role_id);
+
+INSERT INTO roles ("name", description, priority) VALUES
+    ('user', 'Self-service access to the own account', 0),
+    ('admin', 'Full access to all accounts and role grants', 100)
+ON CONFLICT ("name") DO NOTHING;
+
+INSERT INTO permissions ("name", description) VALUES
+    ('users:read', 'List, search and view accounts'),
+    ('users:manage', 'Revoke sessions, reset MFA and unlock accounts'),
+    ('users:delete', 'Delete other accounts'),
+    ('roles:read', 'View roles and role grants'),
+    ('roles:manage', 'Grant and revoke roles')
+ON CONFLICT ("name") DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r CROSS JOIN permissions p
+WHERE r."name" = 'admin'
+ON CONFLICT DO NOTHING;
+
+-- Carry over the free-text role column, unknown values fall back to the default role
+INSERT INTO user_roles (user_id, role_id)
+SELECT u.id, COALESCE(r.id, d.id)
+FROM users u
+LEFT JOIN roles r ON r."name" = u."role"
+JOIN roles d ON d."name" = 'user'
+ON CONFLICT DO NOTHING;
+
+UPDATE users u
+SET "role" = 'user'
+WHERE NOT EXISTS (SELECT 1 FROM roles r WHERE r."name" = u."role");
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- file: 000009_create_tenancy_tables.down.sql
+DELETE FROM permissions WHERE "name" = 'tenants:manage';
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS store_id;
+DROP TABLE IF EXISTS store_memberships;
+DROP TABLE IF EXISTS stores;
+DROP TABLE IF EXISTS organizations;
\ No newline at end of file
@@ -0,0 +1,41 @@
+-- file: 000009_create_tenancy_tables.up.sql
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "name" TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS stores (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    "name" TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stores_organization_id ON stores (organization_id);
+
+CREATE TABLE IF NOT EXISTS store_memberships (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    store_id UUID NOT NULL REFERENCES stores (id) ON DELETE CASCADE,
+    "role" TEXT NOT NULL CHECK ("role" IN ('owner', 'manager', 'cashier')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, store_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_store_memberships_store_id ON store_memberships (store_id);
+
+-- the active store of a session, carried over on refresh
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS store_id UUID REFERENCES stores (id) ON DELETE SET NULL;
+
+INSERT INTO permissions ("name", description) VALUES
+    ('tenants:manage', 'Create organizations and stores and manage every store membership')
+ON CONFLICT ("name") DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r."name" = 'admin' AND p."name" = 'tenants:manage'
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- file: 000010_create_pos_tables.down.sql
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS amr;
+DROP TABLE IF EXISTS pos_terminals;
+DROP TABLE IF EXISTS user_pins;
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- file: 000010_create_pos_tables.up.sql
+CREATE TABLE IF NOT EXISTS user_pins (
+    user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    pin_hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS pos_terminals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    store_id UUID NOT NULL REFERENCES stores (id) ON DELETE CASCADE,
+    "name" TEXT NOT NULL,
+    registered_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_pos_terminals_store_id ON pos_terminals (store_id);
+
+-- authentication methods of the session (RFC 8176), carried over on refresh
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS amr TEXT[];
\ No newline at end of file
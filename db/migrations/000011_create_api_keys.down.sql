@@ -0,0 +1,2 @@
+-- file: 000011_create_api_keys.down.sql
+DROP TABLE IF EXISTS api_keys;
\ No newline at end of file
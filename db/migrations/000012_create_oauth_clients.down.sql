@@ -0,0 +1,3 @@
+-- file: 000012_create_oauth_clients.down.sql
+DELETE FROM permissions WHERE "name" = 'clients:manage';
+DROP TABLE IF EXISTS oauth_clients;
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- file: 000012_create_oauth_clients.up.sql
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    client_id TEXT NOT NULL UNIQUE,
+    "name" TEXT NOT NULL,
+    secret_hash TEXT NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+INSERT INTO permissions ("name", description) VALUES
+    ('clients:manage', 'Register and revoke OAuth clients of other services')
+ON CONFLICT ("name") DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r."name" = 'admin' AND p."name" = 'clients:manage'
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- file: 000013_create_oidc.down.sql
+DROP TABLE IF EXISTS oauth_authorization_codes;
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS first_party;
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS is_public;
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS grant_types;
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS redirect_uris;
\ No newline at end of file
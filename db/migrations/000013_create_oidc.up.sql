@@ -0,0 +1,21 @@
+-- file: 000013_create_oidc.up.sql
+ALTER TABLE oauth_clients ADD COLUMN redirect_uris TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE oauth_clients ADD COLUMN grant_types TEXT[] NOT NULL DEFAULT '{client_credentials}';
+ALTER TABLE oauth_clients ADD COLUMN is_public BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE oauth_clients ADD COLUMN first_party BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code_hash TEXT NOT NULL UNIQUE,
+    oauth_client_id UUID NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    nonce TEXT NOT NULL DEFAULT '',
+    code_challenge TEXT NOT NULL,
+    amr TEXT[] NOT NULL DEFAULT '{}',
+    auth_time TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
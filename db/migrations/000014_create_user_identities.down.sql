@@ -0,0 +1,2 @@
+-- file: 000014_create_user_identities.down.sql
+DROP TABLE IF EXISTS user_identities;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- file: 000015_create_user_preferences.down.sql
+DROP TABLE IF EXISTS user_preferences;
\ No newline at end of file
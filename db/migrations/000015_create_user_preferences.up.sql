@@ -0,0 +1,7 @@
+-- file: 000015_create_user_preferences.up.sql
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    passwordless_login BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- file: 000016_create_webauthn_credentials.down.sql
+DROP TABLE IF EXISTS webauthn_credentials;
\ No newline at end of file
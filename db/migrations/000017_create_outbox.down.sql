@@ -0,0 +1,2 @@
+-- file: 000017_create_outbox.down.sql
+DROP TABLE IF EXISTS outbox;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- file: 000018_create_email_campaigns.down.sql
+DELETE FROM permissions WHERE "name" = 'campaigns:manage';
+DROP TABLE IF EXISTS email_campaign_deliveries;
+DROP TABLE IF EXISTS email_campaigns;
+ALTER TABLE user_preferences DROP COLUMN IF EXISTS marketing_emails;
\ No newline at end of file
@@ -0,0 +1,48 @@
+-- file: 000018_create_email_campaigns.up.sql
+ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS marketing_emails BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE TABLE IF NOT EXISTS email_campaigns (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "name" TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    segment_roles TEXT[] NOT NULL DEFAULT '{}',
+    segment_signed_up_after TIMESTAMPTZ,
+    segment_signed_up_before TIMESTAMPTZ,
+    segment_email_verified BOOLEAN,
+    status TEXT NOT NULL DEFAULT 'draft',
+    scheduled_at TIMESTAMPTZ,
+    started_at TIMESTAMPTZ,
+    dispatched_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ,
+    recipient_count INTEGER NOT NULL DEFAULT 0,
+    created_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS email_campaign_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    campaign_id UUID NOT NULL REFERENCES email_campaigns (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    error TEXT NOT NULL DEFAULT '',
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (campaign_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_campaigns_due ON email_campaigns (scheduled_at) WHERE status = 'scheduled';
+CREATE INDEX IF NOT EXISTS idx_email_campaign_deliveries_pending ON email_campaign_deliveries (campaign_id) WHERE status = 'pending';
+
+INSERT INTO permissions ("name", description) VALUES
+    ('campaigns:manage', 'Create, schedule and cancel email campaigns to account holders')
+ON CONFLICT ("name") DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r."name" = 'admin' AND p."name" = 'campaigns:manage'
+ON CONFLICT DO NOTHING;
\ No newline at end of file
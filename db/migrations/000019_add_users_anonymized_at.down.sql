@@ -0,0 +1,2 @@
+-- file: 000019_add_users_anonymized_at.down.sql
+ALTER TABLE users DROP COLUMN IF EXISTS anonymized_at;
\ No newline at end of file
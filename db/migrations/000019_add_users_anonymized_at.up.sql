@@ -0,0 +1,2 @@
+-- file: 000019_add_users_anonymized_at.up.sql
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMPTZ;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- file: 000020_create_audit_events.down.sql
+DELETE FROM permissions WHERE "name" = 'audit:read';
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
\ No newline at end of file
@@ -0,0 +1,43 @@
+-- file: 000020_create_audit_events.up.sql
+-- No foreign keys on the user columns: the trail has to outlive the accounts it describes.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "action" TEXT NOT NULL,
+    actor_id UUID,
+    target_user_id UUID,
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    metadata JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target_user ON audit_events (target_user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events (actor_id, created_at DESC, id DESC);
+
+-- Audit events are append-only. Only the retention job may delete, and it has to say so with
+-- SET LOCAL audit.allow_prune = 'on' in its transaction.
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND current_setting('audit.allow_prune', true) = 'on' THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+INSERT INTO permissions ("name", description) VALUES
+    ('audit:read', 'Read the security audit log of all accounts')
+ON CONFLICT ("name") DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r."name" = 'admin' AND p."name" = 'audit:read'
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- file: 000021_add_pos_terminals_secret_hash.down.sql
+ALTER TABLE pos_terminals DROP COLUMN IF EXISTS secret_hash;
\ No newline at end of file
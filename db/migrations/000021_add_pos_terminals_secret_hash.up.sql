@@ -0,0 +1,4 @@
+-- file: 000021_add_pos_terminals_secret_hash.up.sql
+-- Terminals registered before this migration have no secret and can not sign cashiers in
+-- until they are registered again.
+ALTER TABLE pos_terminals ADD COLUMN IF NOT EXISTS secret_hash TEXT NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- file: 000022_add_refresh_tokens_client_id.down.sql
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS client_id;
\ No newline at end of file
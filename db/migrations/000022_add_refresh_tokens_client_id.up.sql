@@ -0,0 +1,4 @@
+-- file: 000022_add_refresh_tokens_client_id.up.sql
+-- OAuth client a refresh token was issued to, empty for the account API's own logins. Tokens
+-- only rotate for the client they were issued to.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS client_id TEXT NOT NULL DEFAULT '';
\ No newline at end of file
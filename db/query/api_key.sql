@@ -0,0 +1,35 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (user_id, "name", prefix, key_hash, scopes, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
+
+-- name: GetAPIKeyByPrefix :one
+SELECT * FROM api_keys
+WHERE prefix = $1;
+
+-- name: GetUserAPIKey :one
+SELECT * FROM api_keys
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: ListUserAPIKeys :many
+SELECT * FROM api_keys
+WHERE user_id = $1 AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: CountActiveAPIKeys :one
+SELECT count(*) FROM api_keys
+WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > now());
+
+-- name: RenameAPIKey :one
+UPDATE api_keys
+SET "name" = $3
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL RETURNING *;
+
+-- name: RevokeAPIKey :execrows
+UPDATE api_keys
+SET revoked_at = now()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = now()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute');
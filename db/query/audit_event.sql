@@ -0,0 +1,35 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (
+    id, "action", actor_id, target_user_id, ip_address, user_agent, request_id, metadata
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_events
+WHERE
+    (sqlc.narg('action')::text IS NULL OR "action" = sqlc.narg('action'))
+    AND (sqlc.narg('actor_id')::uuid IS NULL OR actor_id = sqlc.narg('actor_id'))
+    AND (sqlc.narg('target_user_id')::uuid IS NULL OR target_user_id = sqlc.narg('target_user_id'))
+    AND (sqlc.narg('created_from')::timestamptz IS NULL OR created_at >= sqlc.narg('created_from'))
+    AND (sqlc.narg('created_to')::timestamptz IS NULL OR created_at < sqlc.narg('created_to'))
+    AND (
+        sqlc.narg('cursor_id')::uuid IS NULL
+        OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamptz, sqlc.narg('cursor_id'))
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: AllowAuditEventPrune :exec
+SELECT set_config('audit.allow_prune', 'on', true);
+
+-- name: DeleteAuditEventsBefore :execrows
+DELETE FROM audit_events
+WHERE id IN (
+    SELECT id FROM audit_events
+    WHERE created_at < sqlc.arg(created_before)
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: CountAuditEventsBefore :one
+SELECT count(*) FROM audit_events
+WHERE created_at < $1;
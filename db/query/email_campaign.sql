@@ -0,0 +1,143 @@
+-- name: CreateEmailCampaign :one
+INSERT INTO email_campaigns (
+    "name", subject, body, segment_roles, segment_signed_up_after, segment_signed_up_before, segment_email_verified, created_by
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *;
+
+-- name: GetEmailCampaign :one
+SELECT * FROM email_campaigns
+WHERE id = $1;
+
+-- name: ListEmailCampaigns :many
+SELECT * FROM email_campaigns
+WHERE sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status')
+ORDER BY created_at DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ScheduleEmailCampaign :one
+UPDATE email_campaigns
+SET status = 'scheduled', scheduled_at = $2, updated_at = now()
+WHERE id = $1 AND status IN ('draft', 'scheduled') RETURNING *;
+
+-- name: CancelEmailCampaign :one
+UPDATE email_campaigns
+SET status = 'cancelled', cancelled_at = now(), updated_at = now()
+WHERE id = $1 AND status IN ('draft', 'scheduled', 'sending') RETURNING *;
+
+-- name: ClaimDueEmailCampaigns :many
+UPDATE email_campaigns
+SET status = 'sending', started_at = now(), updated_at = now()
+WHERE id IN (
+    SELECT id FROM email_campaigns
+    WHERE (status = 'scheduled' AND scheduled_at <= now())
+        OR (status = 'sending' AND dispatched_at IS NULL AND started_at < sqlc.arg('stale_before'))
+    ORDER BY scheduled_at
+    LIMIT sqlc.arg('page_limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkEmailCampaignDispatched :exec
+UPDATE email_campaigns
+SET dispatched_at = now(), recipient_count = $2, updated_at = now()
+WHERE id = $1 AND status = 'sending';
+
+-- name: CompleteEmailCampaign :execrows
+UPDATE email_campaigns
+SET status = 'sent', completed_at = now(), updated_at = now()
+WHERE id = $1 AND status = 'sending' AND dispatched_at IS NOT NULL
+    AND NOT EXISTS (
+        SELECT 1 FROM email_campaign_deliveries d
+        WHERE d.campaign_id = $1 AND d.status = 'pending'
+    );
+
+-- name: ListEmailCampaignRecipients :many
+SELECT u.id, u."name", u.username, u.email
+FROM users u
+LEFT JOIN user_preferences p ON p.user_id = u.id
+WHERE u.deleted_at IS NULL
+    AND COALESCE(p.marketing_emails, TRUE)
+    AND (
+        cardinality(sqlc.arg('roles')::text[]) = 0
+        OR EXISTS (
+            SELECT 1 FROM user_roles ur
+            JOIN roles r ON r.id = ur.role_id
+            WHERE ur.user_id = u.id AND r."name" = ANY(sqlc.arg('roles')::text[])
+        )
+    )
+    AND (sqlc.narg('signed_up_after')::timestamptz IS NULL OR u.created_at >= sqlc.narg('signed_up_after'))
+    AND (sqlc.narg('signed_up_before')::timestamptz IS NULL OR u.created_at < sqlc.narg('signed_up_before'))
+    AND (sqlc.narg('email_verified')::boolean IS NULL OR (u.email_verified_at IS NOT NULL) = sqlc.narg('email_verified'))
+    AND u.id > sqlc.arg('after_id')
+ORDER BY u.id
+LIMIT sqlc.arg('page_limit');
+
+-- name: CountEmailCampaignRecipients :one
+SELECT count(*)
+FROM users u
+LEFT JOIN user_preferences p ON p.user_id = u.id
+WHERE u.deleted_at IS NULL
+    AND COALESCE(p.marketing_emails, TRUE)
+    AND (
+        cardinality(sqlc.arg('roles')::text[]) = 0
+        OR EXISTS (
+            SELECT 1 FROM user_roles ur
+            JOIN roles r ON r.id = ur.role_id
+            WHERE ur.user_id = u.id AND r."name" = ANY(sqlc.arg('roles')::text[])
+        )
+    )
+    AND (sqlc.narg('signed_up_after')::timestamptz IS NULL OR u.created_at >= sqlc.narg('signed_up_after'))
+    AND (sqlc.narg('signed_up_before')::timestamptz IS NULL OR u.created_at < sqlc.narg('signed_up_before'))
+    AND (sqlc.narg('email_verified')::boolean IS NULL OR (u.email_verified_at IS NOT NULL) = sqlc.narg('email_verified'));
+
+-- name: CreateEmailCampaignDeliveries :execrows
+INSERT INTO email_campaign_deliveries (campaign_id, user_id, email)
+SELECT sqlc.arg('campaign_id'), unnest(sqlc.arg('user_ids')::uuid[]), unnest(sqlc.arg('emails')::text[])
+WHERE EXISTS (
+    SELECT 1 FROM email_campaigns WHERE id = sqlc.arg('campaign_id') AND status = 'sending'
+)
+ON CONFLICT (campaign_id, user_id) DO NOTHING;
+
+-- name: ListPendingEmailCampaignDeliveries :many
+SELECT id FROM email_campaign_deliveries
+WHERE campaign_id = $1 AND status = 'pending' AND id > $2
+ORDER BY id
+LIMIT $3;
+
+-- name: ClaimEmailCampaignDelivery :one
+UPDATE email_campaign_deliveries d
+SET status = 'sending'
+FROM email_campaigns c
+WHERE d.id = $1 AND d.status = 'pending' AND c.id = d.campaign_id AND c.status = 'sending'
+RETURNING d.id, d.campaign_id, d.user_id, d.email, d.status, d.error, d.sent_at, d.created_at;
+
+-- name: FinishEmailCampaignDelivery :exec
+UPDATE email_campaign_deliveries
+SET status = $2, error = $3, sent_at = CASE WHEN $2 = 'sent' THEN now() END
+WHERE id = $1;
+
+-- name: SkipPendingEmailCampaignDeliveries :execrows
+UPDATE email_campaign_deliveries
+SET status = 'skipped', error = $2
+WHERE campaign_id = $1 AND status = 'pending';
+
+-- name: CountEmailCampaignDeliveries :many
+SELECT status, count(*) FROM email_campaign_deliveries
+WHERE campaign_id = $1
+GROUP BY status;
+
+-- name: DeleteFinishedEmailCampaignDeliveries :execrows
+DELETE FROM email_campaign_deliveries
+WHERE id IN (
+    SELECT d.id FROM email_campaign_deliveries d
+    JOIN email_campaigns c ON c.id = d.campaign_id
+    WHERE c.status IN ('sent', 'cancelled')
+        AND COALESCE(c.completed_at, c.cancelled_at) < sqlc.arg(finished_before)
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: CountFinishedEmailCampaignDeliveries :one
+SELECT count(*) FROM email_campaign_deliveries d
+JOIN email_campaigns c ON c.id = d.campaign_id
+WHERE c.status IN ('sent', 'cancelled')
+    AND COALESCE(c.completed_at, c.cancelled_at) < $1;
@@ -0,0 +1,52 @@
+-- name: UpsertUserMFA :one
+INSERT INTO user_mfa (
+    user_id,
+    totp_secret
+) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET
+    totp_secret = EXCLUDED.totp_secret,
+    last_used_step = 0,
+    enabled_at = NULL,
+    updated_at = now()
+RETURNING *;
+
+-- name: GetUserMFA :one
+SELECT * FROM user_mfa
+WHERE user_id = $1;
+
+-- name: EnableUserMFA :one
+UPDATE user_mfa
+SET
+    enabled_at = now(),
+    last_used_step = $2,
+    updated_at = now()
+WHERE user_id = $1 AND enabled_at IS NULL RETURNING *;
+
+-- name: UpdateMFALastUsedStep :execrows
+UPDATE user_mfa
+SET
+    last_used_step = $2,
+    updated_at = now()
+WHERE user_id = $1 AND last_used_step < $2;
+
+-- name: DeleteUserMFA :exec
+DELETE FROM user_mfa
+WHERE user_id = $1;
+
+-- name: ReplaceRecoveryCodes :exec
+WITH deleted AS (
+    DELETE FROM mfa_recovery_codes
+    WHERE mfa_recovery_codes.user_id = @user_id::uuid
+)
+INSERT INTO mfa_recovery_codes (user_id, code_hash)
+SELECT @user_id::uuid, unnest(@code_hashes::text[]);
+
+-- name: UseRecoveryCode :execrows
+UPDATE mfa_recovery_codes
+SET used_at = now()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM mfa_recovery_codes
+WHERE user_id = $1;
@@ -0,0 +1,12 @@
+-- name: CreateAuthorizationCode :one
+INSERT INTO oauth_authorization_codes (code_hash, oauth_client_id, user_id, redirect_uri, scopes, nonce, code_challenge, amr, auth_time, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING *;
+
+-- name: ConsumeAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET consumed_at = now()
+WHERE code_hash = $1 AND consumed_at IS NULL AND expires_at > now() RETURNING *;
+
+-- name: DeleteExpiredAuthorizationCodes :execrows
+DELETE FROM oauth_authorization_codes
+WHERE expires_at < $1;
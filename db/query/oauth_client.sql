@@ -0,0 +1,31 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (client_id, "name", secret_hash, scopes, created_by, redirect_uris, grant_types, is_public, first_party)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: GetOAuthClientByClientID :one
+SELECT * FROM oauth_clients
+WHERE client_id = $1;
+
+-- name: ListOAuthClients :many
+SELECT * FROM oauth_clients
+WHERE revoked_at IS NULL
+ORDER BY created_at;
+
+-- name: RevokeOAuthClient :execrows
+UPDATE oauth_clients
+SET revoked_at = now()
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: TouchOAuthClient :exec
+UPDATE oauth_clients
+SET last_used_at = now()
+WHERE id = $1;
+
+-- name: UpdateOAuthClient :one
+UPDATE oauth_clients
+SET "name" = $2, scopes = $3, redirect_uris = $4
+WHERE id = $1 AND revoked_at IS NULL RETURNING *;
@@ -0,0 +1,41 @@
+-- name: CreateOutboxEvent :exec
+INSERT INTO outbox (
+    id, aggregate_type, aggregate_id, event_type, payload
+)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ClaimOutboxEvents :many
+WITH due AS (
+    SELECT id FROM outbox
+    WHERE published_at IS NULL AND next_attempt_at <= now()
+    ORDER BY created_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE outbox
+SET next_attempt_at = sqlc.arg(lease_until)
+FROM due
+WHERE outbox.id = due.id
+RETURNING outbox.*;
+
+-- name: MarkOutboxEventPublished :exec
+UPDATE outbox
+SET published_at = now(), last_error = ''
+WHERE id = $1;
+
+-- name: MarkOutboxEventFailed :exec
+UPDATE outbox
+SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+WHERE id = $1;
+
+-- name: DeletePublishedOutboxEvents :execrows
+DELETE FROM outbox
+WHERE id IN (
+    SELECT id FROM outbox
+    WHERE published_at < sqlc.arg(published_before)
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: CountPublishedOutboxEvents :one
+SELECT count(*) FROM outbox
+WHERE published_at < $1;
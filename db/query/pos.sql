@@ -0,0 +1,36 @@
+-- name: UpsertUserPin :exec
+INSERT INTO user_pins (user_id, pin_hash)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET pin_hash = EXCLUDED.pin_hash, updated_at = now();
+
+-- name: GetUserPin :one
+SELECT * FROM user_pins
+WHERE user_id = $1;
+
+-- name: DeleteUserPin :execrows
+DELETE FROM user_pins
+WHERE user_id = $1;
+
+-- name: CreatePosTerminal :one
+INSERT INTO pos_terminals (store_id, "name", registered_by, secret_hash)
+VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: GetPosTerminal :one
+SELECT * FROM pos_terminals
+WHERE id = $1;
+
+-- name: ListPosTerminals :many
+SELECT * FROM pos_terminals
+WHERE store_id = $1 AND revoked_at IS NULL
+ORDER BY created_at;
+
+-- name: RevokePosTerminal :execrows
+UPDATE pos_terminals
+SET revoked_at = now()
+WHERE id = $1 AND store_id = $2 AND revoked_at IS NULL;
+
+-- name: TouchPosTerminal :exec
+UPDATE pos_terminals
+SET last_used_at = now()
+WHERE id = $1;
@@ -0,0 +1,52 @@
+-- name: ListRoles :many
+SELECT * FROM roles
+ORDER BY priority DESC, "name";
+
+-- name: GetRoleByName :one
+SELECT * FROM roles
+WHERE "name" = $1;
+
+-- name: ListRolePermissions :many
+SELECT r."name" AS role_name, p."name" AS permission_name
+FROM role_permissions rp
+JOIN roles r ON r.id = rp.role_id
+JOIN permissions p ON p.id = rp.permission_id
+ORDER BY r."name", p."name";
+
+-- name: GetUserRoles :many
+SELECT r.id, r."name", r.description, r.priority, r.created_at
+FROM user_roles ur
+JOIN roles r ON r.id = ur.role_id
+WHERE ur.user_id = $1
+ORDER BY r.priority DESC, r."name";
+
+-- name: GetUserPermissions :many
+SELECT DISTINCT p."name"
+FROM user_roles ur
+JOIN role_permissions rp ON rp.role_id = ur.role_id
+JOIN permissions p ON p.id = rp.permission_id
+WHERE ur.user_id = $1
+ORDER BY p."name";
+
+-- name: GrantUserRole :execrows
+INSERT INTO user_roles (user_id, role_id, granted_by, created_at)
+VALUES ($1, $2, $3, now())
+ON CONFLICT (user_id, role_id) DO NOTHING;
+
+-- name: RevokeUserRole :execrows
+DELETE FROM user_roles
+WHERE user_id = $1 AND role_id = $2;
+
+-- name: SyncUserPrimaryRole :exec
+UPDATE users
+SET
+    "role" = COALESCE((
+        SELECT r."name"
+        FROM user_roles ur
+        JOIN roles r ON r.id = ur.role_id
+        WHERE ur.user_id = users.id
+        ORDER BY r.priority DESC, r."name"
+        LIMIT 1
+    ), sqlc.arg('default_role')::text),
+    updated_at = now()
+WHERE id = sqlc.arg('user_id');
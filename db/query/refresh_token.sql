@@ -0,0 +1,34 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (
+    user_id,
+    family_id,
+    parent_id,
+    token_hash,
+    expires_at,
+    store_id,
+    amr,
+    client_id
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *;
+
+-- name: GetRefreshTokenByHash :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1;
+
+-- name: MarkRefreshTokenUsed :one
+UPDATE refresh_tokens
+SET used_at = now()
+WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL RETURNING *;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = now()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = now()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < $1;
@@ -0,0 +1,47 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations ("name")
+VALUES ($1) RETURNING *;
+
+-- name: GetOrganization :one
+SELECT * FROM organizations
+WHERE id = $1;
+
+-- name: CreateStore :one
+INSERT INTO stores (organization_id, "name")
+VALUES ($1, $2) RETURNING *;
+
+-- name: GetStore :one
+SELECT * FROM stores
+WHERE id = $1;
+
+-- name: UpsertStoreMembership :one
+INSERT INTO store_memberships (user_id, store_id, "role")
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, store_id) DO UPDATE
+SET "role" = EXCLUDED."role", updated_at = now()
+RETURNING *;
+
+-- name: DeleteStoreMembership :execrows
+DELETE FROM store_memberships
+WHERE user_id = $1 AND store_id = $2;
+
+-- name: GetStoreMembership :one
+SELECT sm.user_id, sm.store_id, sm."role", s.organization_id
+FROM store_memberships sm
+JOIN stores s ON s.id = sm.store_id
+WHERE sm.user_id = $1 AND sm.store_id = $2;
+
+-- name: ListUserMemberships :many
+SELECT sm.store_id, s."name" AS store_name, s.organization_id, o."name" AS organization_name, sm."role", sm.created_at
+FROM store_memberships sm
+JOIN stores s ON s.id = sm.store_id
+JOIN organizations o ON o.id = s.organization_id
+WHERE sm.user_id = $1
+ORDER BY o."name", s."name";
+
+-- name: ListStoreMembers :many
+SELECT sm.user_id, u."name", u.username, sm."role", sm.created_at
+FROM store_memberships sm
+JOIN users u ON u.id = sm.user_id
+WHERE sm.store_id = $1 AND u.deleted_at IS NULL
+ORDER BY u."name";
@@ -10,26 +10,122 @@ INSERT INTO users (
     role
 ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *;
 
--- name: GetAllUsers :many
-SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at
+-- name: GetUserByEmail :one
+SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at, email_verified_at
 FROM users
-WHERE deleted_at IS NULL;
-
--- name: GetUserByUsername :one
-SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at
-FROM users
-WHERE username = $1 AND deleted_at IS NULL;
+WHERE lower(email) = lower(sqlc.arg('email')::text) AND deleted_at IS NULL;
 
 -- name: GetUserByID :one
-SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at
+SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at, email_verified_at
 FROM users
 WHERE id = $1 AND deleted_at IS NULL;
 
 -- name: GetUserByIDs :many
-SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at
+SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at, email_verified_at
 FROM users
 WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL;
 
+-- name: GetUserByUsername :one
+SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at, email_verified_at
+FROM users
+WHERE lower(username) = lower(sqlc.arg('username')::text) AND deleted_at IS NULL;
+
+-- name: ListUsers :many
+SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at, email_verified_at, deleted_at
+FROM users
+WHERE
+    (
+        sqlc.narg('role')::text IS NULL
+        OR EXISTS (
+            SELECT 1 FROM user_roles ur
+            JOIN roles r ON r.id = ur.role_id
+            WHERE ur.user_id = users.id AND r."name" = sqlc.narg('role')
+        )
+    )
+    AND (sqlc.narg('created_from')::timestamptz IS NULL OR created_at >= sqlc.narg('created_from'))
+    AND (sqlc.narg('created_to')::timestamptz IS NULL OR created_at < sqlc.narg('created_to'))
+    AND (CASE sqlc.arg('deleted')::text
+        WHEN 'only' THEN deleted_at IS NOT NULL
+        WHEN 'include' THEN TRUE
+        ELSE deleted_at IS NULL
+    END)
+    AND (
+        sqlc.narg('search')::text IS NULL
+        OR "name" ILIKE '%' || sqlc.narg('search') || '%'
+        OR username ILIKE '%' || sqlc.narg('search') || '%'
+        OR email ILIKE '%' || sqlc.narg('search') || '%'
+    )
+    AND (
+        sqlc.narg('cursor_id')::uuid IS NULL
+        OR (sqlc.arg('sort')::text = 'created_at' AND (created_at, id) > (sqlc.narg('cursor_created_at')::timestamptz, sqlc.narg('cursor_id')))
+        OR (sqlc.arg('sort')::text = '-created_at' AND (created_at, id) < (sqlc.narg('cursor_created_at')::timestamptz, sqlc.narg('cursor_id')))
+        OR (sqlc.arg('sort')::text = 'name' AND ("name", id) > (sqlc.narg('cursor_name')::text, sqlc.narg('cursor_id')))
+        OR (sqlc.arg('sort')::text = '-name' AND ("name", id) < (sqlc.narg('cursor_name')::text, sqlc.narg('cursor_id')))
+    )
+    AND (
+        sqlc.narg('organization_id')::uuid IS NULL
+        OR EXISTS (
+            SELECT 1 FROM store_memberships sm
+            JOIN stores s ON s.id = sm.store_id
+            WHERE sm.user_id = users.id AND s.organization_id = sqlc.narg('organization_id')
+        )
+    )
+ORDER BY
+    CASE WHEN sqlc.arg('sort')::text = 'created_at' THEN created_at END ASC,
+    CASE WHEN sqlc.arg('sort')::text = '-created_at' THEN created_at END DESC,
+    CASE WHEN sqlc.arg('sort')::text = 'name' THEN "name" END ASC,
+    CASE WHEN sqlc.arg('sort')::text = '-name' THEN "name" END DESC,
+    CASE WHEN sqlc.arg('sort')::text IN ('created_at', 'name') THEN id END ASC,
+    CASE WHEN sqlc.arg('sort')::text IN ('-created_at', '-name') THEN id END DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: MarkUserEmailVerified :execrows
+UPDATE users
+SET email_verified_at = now()
+WHERE id = $1 AND email = $2 AND email_verified_at IS NULL AND deleted_at IS NULL;
+
+-- name: SearchUsers :many
+WITH ranked AS (
+    SELECT id, "name", username, email, phone_number, "address", "role", created_at, updated_at, email_verified_at,
+        (GREATEST(
+            similarity("name", sqlc.arg('query')::text),
+            similarity(username, sqlc.arg('query')),
+            similarity(email, sqlc.arg('query')),
+            similarity(phone_number, sqlc.arg('query'))
+        ) + ts_rank(
+            to_tsvector('simple', "name" || ' ' || username || ' ' || email || ' ' || phone_number),
+            plainto_tsquery('simple', sqlc.arg('query'))
+        ))::real AS score
+    FROM users
+    WHERE deleted_at IS NULL
+        AND (
+            "name" % sqlc.arg('query')
+            OR username % sqlc.arg('query')
+            OR email % sqlc.arg('query')
+            OR phone_number % sqlc.arg('query')
+            OR "name" ILIKE sqlc.arg('pattern')::text
+            OR username ILIKE sqlc.arg('pattern')
+            OR email ILIKE sqlc.arg('pattern')
+            OR phone_number ILIKE sqlc.arg('pattern')
+            OR to_tsvector('simple', "name" || ' ' || username || ' ' || email || ' ' || phone_number)
+                @@ plainto_tsquery('simple', sqlc.arg('query'))
+        )
+        AND (
+            sqlc.narg('organization_id')::uuid IS NULL
+            OR EXISTS (
+                SELECT 1 FROM store_memberships sm
+                JOIN stores s ON s.id = sm.store_id
+                WHERE sm.user_id = users.id AND s.organization_id = sqlc.narg('organization_id')
+            )
+        )
+)
+SELECT id, "name", username, email, phone_number, "address", "role", created_at, updated_at, email_verified_at, score
+FROM ranked
+WHERE sqlc.narg('cursor_id')::uuid IS NULL
+    OR (score, id) < (sqlc.narg('cursor_score')::real, sqlc.narg('cursor_id'))
+ORDER BY score DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
 -- name: UpdateUser :one
 UPDATE users
 SET
@@ -40,10 +136,82 @@ SET
     "role" = $6,
     phone_number = $7,
     "address" = $8,
+    email_verified_at = CASE WHEN email = $4 THEN email_verified_at END,
     updated_at = now()
 WHERE id = $1 AND deleted_at IS NULL RETURNING *;
 
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET
+    "password" = $2,
+    updated_at = now()
+WHERE id = $1 AND deleted_at IS NULL;
+
 -- name: DeleteUser :one
 UPDATE users
 SET deleted_at = now()
 WHERE id = $1 AND deleted_at IS NULL RETURNING *;
+
+-- name: ListExpiredDeletedUsers :many
+SELECT id FROM users
+WHERE deleted_at < sqlc.arg('deleted_before') AND anonymized_at IS NULL AND id > sqlc.arg('after_id')
+ORDER BY id
+LIMIT sqlc.arg('batch_size');
+
+-- name: CountExpiredDeletedUsers :one
+SELECT
+    count(*) AS users,
+    COALESCE(sum((SELECT count(*) FROM api_keys k WHERE k.user_id = u.id)), 0)::bigint AS api_keys,
+    COALESCE(sum((SELECT count(*) FROM user_identities i WHERE i.user_id = u.id)), 0)::bigint AS identities
+FROM users u
+WHERE u.deleted_at < $1 AND u.anonymized_at IS NULL;
+
+-- name: PurgeUserCredentials :one
+WITH deleted_api_keys AS (
+    DELETE FROM api_keys WHERE user_id = $1 RETURNING id
+), deleted_identities AS (
+    DELETE FROM user_identities WHERE user_id = $1 RETURNING id
+), deleted_refresh_tokens AS (
+    DELETE FROM refresh_tokens WHERE user_id = $1
+), deleted_mfa AS (
+    DELETE FROM user_mfa WHERE user_id = $1
+), deleted_recovery_codes AS (
+    DELETE FROM mfa_recovery_codes WHERE user_id = $1
+), deleted_reset_tokens AS (
+    DELETE FROM password_reset_tokens WHERE user_id = $1
+), deleted_authorization_codes AS (
+    DELETE FROM oauth_authorization_codes WHERE user_id = $1
+), deleted_pins AS (
+    DELETE FROM user_pins WHERE user_id = $1
+), deleted_passkeys AS (
+    DELETE FROM webauthn_credentials WHERE user_id = $1
+), deleted_preferences AS (
+    DELETE FROM user_preferences WHERE user_id = $1
+), deleted_roles AS (
+    DELETE FROM user_roles WHERE user_id = $1
+), deleted_memberships AS (
+    DELETE FROM store_memberships WHERE user_id = $1
+), deleted_deliveries AS (
+    DELETE FROM email_campaign_deliveries WHERE user_id = $1
+)
+SELECT
+    (SELECT count(*) FROM deleted_api_keys) AS api_keys,
+    (SELECT count(*) FROM deleted_identities) AS identities;
+
+-- name: AnonymizeUser :exec
+UPDATE users
+SET
+    "name" = 'deleted-' || id,
+    username = 'deleted-' || id,
+    email = 'deleted-' || id || '@anonymized.invalid',
+    phone_number = '',
+    "address" = '',
+    "password" = '',
+    email_verified_at = NULL,
+    anonymized_at = now(),
+    updated_at = now()
+WHERE id = $1 AND deleted_at IS NOT NULL;
+
+-- name: HardDeleteUser :exec
+DELETE FROM users
+WHERE id = $1 AND deleted_at IS NOT NULL;
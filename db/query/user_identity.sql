@@ -0,0 +1,25 @@
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (user_id, provider, subject, email)
+VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE provider = $1 AND subject = $2;
+
+-- name: ListUserIdentities :many
+SELECT * FROM user_identities
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: CountUserIdentities :one
+SELECT count(*) FROM user_identities
+WHERE user_id = $1;
+
+-- name: TouchUserIdentity :exec
+UPDATE user_identities
+SET last_login_at = now(), email = $2
+WHERE id = $1;
+
+-- name: DeleteUserIdentity :execrows
+DELETE FROM user_identities
+WHERE id = $1 AND user_id = $2;
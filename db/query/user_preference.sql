@@ -0,0 +1,10 @@
+-- name: GetUserPreferences :one
+SELECT * FROM user_preferences
+WHERE user_id = $1;
+
+-- name: UpsertUserPreferences :one
+INSERT INTO user_preferences (user_id, passwordless_login, marketing_emails)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE
+SET passwordless_login = EXCLUDED.passwordless_login, marketing_emails = EXCLUDED.marketing_emails, updated_at = now()
+RETURNING *;
@@ -0,0 +1,27 @@
+-- name: CreateWebauthnCredential :one
+INSERT INTO webauthn_credentials (
+    user_id, credential_id, public_key, attestation_type, aaguid, sign_count, transports, backup_eligible, backup_state, "name"
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING *;
+
+-- name: GetWebauthnCredential :one
+SELECT * FROM webauthn_credentials
+WHERE credential_id = $1;
+
+-- name: ListWebauthnCredentials :many
+SELECT * FROM webauthn_credentials
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: CountWebauthnCredentials :one
+SELECT count(*) FROM webauthn_credentials
+WHERE user_id = $1;
+
+-- name: UpdateWebauthnCredentialUsage :exec
+UPDATE webauthn_credentials
+SET sign_count = $2, backup_state = $3, last_used_at = now()
+WHERE id = $1;
+
+-- name: DeleteWebauthnCredential :execrows
+DELETE FROM webauthn_credentials
+WHERE id = $1 AND user_id = $2;
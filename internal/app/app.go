@@ -0,0 +1,411 @@
+// Package app wires the connections, repositories and services shared by the web server
+// (cmd/web) and the background worker (cmd/worker).
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/sirupsen/logrus"
+
+	database "github.com/RehanAthallahAzhar/shopeezy-accounts/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/configs"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/notifier"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/rabbitmq"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/redisclient"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/audit"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/identity"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+// App holds everything both processes build from the configuration. Create it with New and
+// release it with Close.
+type App struct {
+	Config    *configs.AppConfig
+	Log       *logrus.Logger
+	DB        *sql.DB
+	Queries   *db.Queries
+	Redis     *redisclient.RedisClient
+	Notifier  notifier.Notifier
+	Publisher *rabbitmq.Publisher
+	// CampaignPublisher publishes the delivery batches of email campaigns.
+	CampaignPublisher *rabbitmq.Publisher
+	Validator         *validator.Validate
+
+	Repositories Repositories
+	Services     Services
+}
+
+type Repositories struct {
+	Users              repositories.UserRepository
+	JWTBlacklist       repositories.JWTBlacklistRepository
+	RefreshTokens      repositories.RefreshTokenRepository
+	Sessions           repositories.SessionRepository
+	MFA                repositories.MFARepository
+	Attempts           repositories.AttemptRepository
+	PasswordResets     repositories.PasswordResetRepository
+	LoginLocks         repositories.LoginLockRepository
+	Roles              repositories.RoleRepository
+	Tenancy            repositories.TenancyRepository
+	POS                repositories.POSRepository
+	APIKeys            repositories.APIKeyRepository
+	OAuthClients       repositories.OAuthClientRepository
+	AuthorizationCodes repositories.AuthorizationCodeRepository
+	Identities         repositories.IdentityRepository
+	FederationStates   repositories.FederationStateRepository
+	Preferences        repositories.PreferenceRepository
+	MagicLogins        repositories.MagicLoginRepository
+	WebAuthn           repositories.WebAuthnRepository
+	WebAuthnSessions   repositories.WebAuthnSessionRepository
+	Outbox             repositories.OutboxRepository
+	Campaigns          repositories.CampaignRepository
+	Audit              repositories.AuditRepository
+}
+
+type Services struct {
+	Token             token.TokenService
+	LoginThrottle     services.LoginThrottleService
+	User              services.UserService
+	Session           services.SessionService
+	Role              services.RoleService
+	Tenancy           services.TenancyService
+	APIKey            services.APIKeyService
+	OAuth             services.OAuthService
+	OIDC              services.OIDCService
+	Federation        services.FederationService
+	POS               services.POSService
+	WebAuthn          services.WebAuthnService
+	MFA               services.MFAService
+	Password          services.PasswordService
+	EmailVerification services.EmailVerificationService
+	Passwordless      services.PasswordlessService
+	Preference        services.PreferenceService
+	OutboxRelay       services.OutboxRelay
+	SecurityNotice    services.SecurityNoticeService
+	TokenPurge        services.TokenPurgeService
+	Campaign          services.CampaignService
+	Retention         services.RetentionService
+	Audit             services.AuditService
+}
+
+// New connects to Postgres and Redis and builds the repositories and services. The RabbitMQ
+// publisher connects on first use. ctx only bounds the initial connection.
+func New(ctx context.Context, cfg *configs.AppConfig, log *logrus.Logger) (*App, error) {
+	switch cfg.Auth.EmailVerificationMode {
+	case services.EmailVerificationOff, services.EmailVerificationDeny, services.EmailVerificationRestricted:
+	default:
+		return nil, fmt.Errorf("invalid EMAIL_VERIFICATION_MODE %q", cfg.Auth.EmailVerificationMode)
+	}
+
+	switch cfg.Retention.DeletedUserMode {
+	case services.RetentionAnonymize, services.RetentionDelete:
+	default:
+		return nil, fmt.Errorf("invalid RETENTION_DELETED_USER_MODE %q", cfg.Retention.DeletedUserMode)
+	}
+	if cfg.Retention.BatchSize <= 0 {
+		return nil, fmt.Errorf("invalid RETENTION_BATCH_SIZE %d", cfg.Retention.BatchSize)
+	}
+
+	conn, err := database.Connect(ctx, credential(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("DB connection error: %w", err)
+	}
+
+	redisClient, err := redisclient.NewRedisClient()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to initialize redis client: %w", err)
+	}
+
+	a := &App{
+		Config:            cfg,
+		Log:               log,
+		DB:                conn,
+		Queries:           db.New(conn),
+		Redis:             redisClient,
+		Publisher:         rabbitmq.NewPublisher(cfg.RabbitMQ.URL, cfg.RabbitMQ.EventsExchange, cfg.RabbitMQ.PublishTimeout),
+		CampaignPublisher: rabbitmq.NewPublisher(cfg.RabbitMQ.URL, cfg.Campaign.Exchange, cfg.RabbitMQ.PublishTimeout),
+		Validator:         validator.New(),
+	}
+
+	a.Notifier, err = notifier.New(notifier.Options{
+		Driver:       cfg.Notifier.Driver,
+		FilePath:     cfg.Notifier.FilePath,
+		SMTPHost:     cfg.Notifier.SMTPHost,
+		SMTPPort:     cfg.Notifier.SMTPPort,
+		SMTPUsername: cfg.Notifier.SMTPUsername,
+		SMTPPassword: cfg.Notifier.SMTPPassword,
+		SMTPFrom:     cfg.Notifier.SMTPFrom,
+	}, log)
+	if err != nil {
+		a.Close()
+		return nil, fmt.Errorf("failed to initialize notifier: %w", err)
+	}
+
+	a.Repositories = newRepositories(conn, a.Queries, redisClient, log)
+	if err := a.buildServices(); err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Migrate brings the database schema up to date.
+func (a *App) Migrate() error {
+	c := credential(a.Config)
+	connectionString := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable",
+		c.Username,
+		c.Password,
+		c.Host,
+		c.Port,
+		c.DatabaseName,
+	)
+
+	m, err := migrate.New(a.Config.Migration.Path, connectionString)
+	if err != nil {
+		return fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	return nil
+}
+
+// Close closes the RabbitMQ publishers, Redis and the database.
+func (a *App) Close() {
+	a.Publisher.Close()
+	a.CampaignPublisher.Close()
+	a.Redis.Close()
+	if err := a.DB.Close(); err != nil {
+		a.Log.WithError(err).Error("Failed to close database connection")
+	}
+}
+
+func credential(cfg *configs.AppConfig) *models.Credential {
+	return &models.Credential{
+		Host:         cfg.Database.Host,
+		Username:     cfg.Database.User,
+		Password:     cfg.Database.Password,
+		DatabaseName: cfg.Database.Name,
+		Port:         cfg.Database.Port,
+	}
+}
+
+func newRepositories(conn *sql.DB, sqlcQueries *db.Queries, redisClient *redisclient.RedisClient, log *logrus.Logger) Repositories {
+	return Repositories{
+		Users:              repositories.NewUserRepository(conn, sqlcQueries, log),
+		JWTBlacklist:       repositories.NewJWTBlacklistRepository(redisClient),
+		RefreshTokens:      repositories.NewRefreshTokenRepository(sqlcQueries, log),
+		Sessions:           repositories.NewSessionRepository(redisClient),
+		MFA:                repositories.NewMFARepository(sqlcQueries, log),
+		Attempts:           repositories.NewAttemptRepository(redisClient),
+		PasswordResets:     repositories.NewPasswordResetRepository(sqlcQueries, log),
+		LoginLocks:         repositories.NewLoginLockRepository(redisClient),
+		Roles:              repositories.NewRoleRepository(sqlcQueries, log),
+		Tenancy:            repositories.NewTenancyRepository(sqlcQueries, log),
+		POS:                repositories.NewPOSRepository(sqlcQueries, log),
+		APIKeys:            repositories.NewAPIKeyRepository(sqlcQueries, log),
+		OAuthClients:       repositories.NewOAuthClientRepository(sqlcQueries, log),
+		AuthorizationCodes: repositories.NewAuthorizationCodeRepository(sqlcQueries, log),
+		Identities:         repositories.NewIdentityRepository(sqlcQueries, log),
+		FederationStates:   repositories.NewFederationStateRepository(redisClient),
+		Preferences:        repositories.NewPreferenceRepository(sqlcQueries, log),
+		MagicLogins:        repositories.NewMagicLoginRepository(redisClient),
+		WebAuthn:           repositories.NewWebAuthnRepository(sqlcQueries, log),
+		WebAuthnSessions:   repositories.NewWebAuthnSessionRepository(redisClient),
+		Outbox:             repositories.NewOutboxRepository(sqlcQueries, log),
+		Campaigns:          repositories.NewCampaignRepository(conn, sqlcQueries, log),
+		Audit:              repositories.NewAuditRepository(conn, sqlcQueries, log),
+	}
+}
+
+func (a *App) buildServices() error {
+	cfg, repos, log := a.Config, &a.Repositories, a.Log
+
+	jwtKeys, err := token.LoadKeySet(token.KeyConfig{
+		Algorithm:            cfg.Server.JWTSigningMethod,
+		Secret:               cfg.Server.JWTSecret,
+		PrivateKeyPath:       cfg.Server.JWTPrivateKeyPath,
+		KeyID:                cfg.Server.JWTKeyID,
+		VerificationKeyPaths: cfg.Server.JWTVerificationKeyPaths,
+		LegacyHMACUntil:      cfg.Server.JWTLegacyHMACUntil,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load JWT signing keys: %w", err)
+	}
+
+	auditor := audit.NewRecorder(repos.Audit, log)
+
+	tokenService := token.NewJWTTokenService(
+		jwtKeys,
+		cfg.Server.AccessTokenTTL,
+		cfg.Server.RefreshTokenTTL,
+		repos.JWTBlacklist,
+		repos.RefreshTokens,
+		repos.Sessions,
+		repos.Users,
+		repos.Roles,
+		repos.Tenancy,
+		repos.APIKeys,
+		auditor,
+		log,
+	)
+
+	loginThrottleService := services.NewLoginThrottleService(repos.Users, repos.Attempts, repos.LoginLocks, services.LoginThrottleOptions{
+		MaxAttempts:        cfg.Auth.LoginMaxAttempts,
+		IPMaxAttempts:      cfg.Auth.LoginIPMaxAttempts,
+		AttemptWindow:      cfg.Auth.LoginAttemptWindow,
+		LockoutDuration:    cfg.Auth.LoginLockoutDuration,
+		MaxLockoutDuration: cfg.Auth.LoginMaxLockoutDuration,
+	}, log)
+
+	var identityProviders []identity.Provider
+	for _, providerCfg := range cfg.Identity.Providers() {
+		provider, err := identity.NewOIDCProvider(identity.OIDCProviderConfig{
+			Name:         providerCfg.Name,
+			Issuer:       providerCfg.Issuer,
+			ClientID:     providerCfg.ClientID,
+			ClientSecret: providerCfg.ClientSecret,
+			Scopes:       providerCfg.Scopes,
+		})
+		if err != nil {
+			return fmt.Errorf("invalid identity provider %q: %w", providerCfg.Name, err)
+		}
+		identityProviders = append(identityProviders, provider)
+	}
+
+	webAuthnService, err := services.NewWebAuthnService(repos.WebAuthn, repos.WebAuthnSessions, repos.Users, repos.Identities, services.WebAuthnOptions{
+		RPID:       cfg.WebAuthn.RPID,
+		RPName:     cfg.WebAuthn.RPName,
+		Origins:    cfg.WebAuthn.Origins,
+		SessionTTL: cfg.WebAuthn.SessionTTL,
+	}, log)
+	if err != nil {
+		return fmt.Errorf("invalid WebAuthn configuration: %w", err)
+	}
+
+	a.Services = Services{
+		Token:         tokenService,
+		LoginThrottle: loginThrottleService,
+		User:          services.NewUserService(repos.Users, a.Validator, tokenService, repos.JWTBlacklist, loginThrottleService, cfg.Auth.EmailVerificationMode, auditor, log),
+		Session:       services.NewSessionService(repos.Sessions, repos.Users, tokenService, log),
+		Role:          services.NewRoleService(repos.Roles, repos.Users, tokenService, auditor, log),
+		Tenancy:       services.NewTenancyService(repos.Tenancy, repos.Users, tokenService, log),
+		APIKey:        services.NewAPIKeyService(repos.APIKeys, repos.Roles, log),
+		OAuth:         services.NewOAuthService(repos.OAuthClients, tokenService, cfg.Auth.ServiceTokenTTL, log),
+		OIDC: services.NewOIDCService(repos.OAuthClients, repos.AuthorizationCodes, repos.Users, tokenService, services.OIDCOptions{
+			Issuer:         cfg.Auth.OIDCIssuer,
+			CodeTTL:        cfg.Auth.OIDCCodeTTL,
+			AccessTokenTTL: cfg.Server.AccessTokenTTL,
+		}, log),
+		Federation: services.NewFederationService(identityProviders, repos.Identities, repos.FederationStates, repos.Users, services.FederationOptions{
+			RedirectURL:           cfg.Identity.CallbackURL,
+			StateTTL:              cfg.Identity.StateTTL,
+			EmailVerificationMode: cfg.Auth.EmailVerificationMode,
+		}, log),
+		POS: services.NewPOSService(repos.POS, repos.Tenancy, repos.Users, repos.Attempts, tokenService, services.POSOptions{
+			PINMaxAttempts:     cfg.Auth.PINMaxAttempts,
+			PINLockoutDuration: cfg.Auth.PINLockoutDuration,
+			TokenTTL:           cfg.Auth.POSTokenTTL,
+		}, log),
+		WebAuthn: webAuthnService,
+		MFA: services.NewMFAService(
+			repos.MFA,
+			repos.Users,
+			repos.Attempts,
+			tokenService,
+			webAuthnService,
+			cfg.Auth.MFAIssuer,
+			cfg.Auth.MFATokenTTL,
+			cfg.Auth.MFAMaxAttempts,
+			log,
+		),
+		Password: services.NewPasswordService(
+			repos.Users,
+			repos.PasswordResets,
+			tokenService,
+			a.Notifier,
+			a.Validator,
+			cfg.Auth.PasswordResetTTL,
+			cfg.Auth.PasswordResetURL,
+			log,
+		),
+		EmailVerification: services.NewEmailVerificationService(
+			repos.Users,
+			repos.Attempts,
+			tokenService,
+			a.Notifier,
+			a.Validator,
+			cfg.Auth.EmailVerificationTTL,
+			cfg.Auth.EmailVerificationURL,
+			log,
+		),
+		Passwordless: services.NewPasswordlessService(
+			repos.Users,
+			repos.Preferences,
+			repos.MagicLogins,
+			repos.Attempts,
+			tokenService,
+			a.Notifier,
+			a.Validator,
+			services.PasswordlessOptions{
+				TTL:         cfg.Auth.MagicLoginTTL,
+				MaxAttempts: cfg.Auth.MagicLoginMaxAttempts,
+				SendLimit:   cfg.Auth.MagicLoginSendLimit,
+				SendWindow:  cfg.Auth.MagicLoginSendWindow,
+				LinkURL:     cfg.Auth.MagicLoginURL,
+			},
+			log,
+		),
+		Preference: services.NewPreferenceService(repos.Preferences, repos.Users, tokenService, log),
+		OutboxRelay: services.NewOutboxRelay(repos.Outbox, a.Publisher, services.OutboxRelayOptions{
+			PollInterval: cfg.Outbox.PollInterval,
+			BatchSize:    cfg.Outbox.BatchSize,
+			Lease:        cfg.Outbox.Lease,
+			RetryBase:    cfg.Outbox.RetryBase,
+			RetryMax:     cfg.Outbox.RetryMax,
+		}, log),
+		SecurityNotice: services.NewSecurityNoticeService(repos.Users, a.Notifier, log),
+		TokenPurge:     services.NewTokenPurgeService(repos.RefreshTokens, repos.PasswordResets, repos.AuthorizationCodes, cfg.Worker.TokenPurgeGrace, log),
+		Campaign: services.NewCampaignService(
+			repos.Campaigns,
+			repos.Users,
+			repos.Preferences,
+			tokenService,
+			a.Notifier,
+			a.CampaignPublisher,
+			a.Validator,
+			services.CampaignOptions{
+				BatchSize:      cfg.Campaign.BatchSize,
+				StaleAfter:     cfg.Campaign.StaleAfter,
+				UnsubscribeURL: cfg.Campaign.UnsubscribeURL,
+				UnsubscribeTTL: cfg.Campaign.UnsubscribeTTL,
+			},
+			log,
+		),
+		Retention: services.NewRetentionService(repos.Users, repos.Sessions, repos.Outbox, repos.Campaigns, repos.Audit, services.RetentionOptions{
+			DeletedUserMode:       cfg.Retention.DeletedUserMode,
+			DeletedUserAfter:      cfg.Retention.DeletedUserAfter,
+			OutboxAfter:           cfg.Retention.OutboxAfter,
+			CampaignDeliveryAfter: cfg.Retention.CampaignDeliveryAfter,
+			AuditAfter:            cfg.Retention.AuditAfter,
+			BatchSize:             cfg.Retention.BatchSize,
+		}, log),
+		Audit: services.NewAuditService(repos.Audit, log),
+	}
+
+	return nil
+}
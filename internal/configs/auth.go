@@ -0,0 +1,57 @@
+package configs
+
+import "time"
+
+// AuthConfig menampung pengaturan alur autentikasi (MFA, reset password, dll).
+type AuthConfig struct {
+	MFAIssuer      string        `env:"MFA_ISSUER" envDefault:"Shopeezy"`
+	MFATokenTTL    time.Duration `env:"MFA_TOKEN_TTL" envDefault:"5m"`
+	MFAMaxAttempts int64         `env:"MFA_MAX_ATTEMPTS" envDefault:"5"`
+
+	// Failed logins are counted per username and per client IP within LoginAttemptWindow. Reaching
+	// LoginMaxAttempts locks the account; every further lockout within a day doubles the duration
+	// up to LoginMaxLockoutDuration.
+	LoginMaxAttempts        int64         `env:"LOGIN_MAX_ATTEMPTS" envDefault:"5"`
+	LoginIPMaxAttempts      int64         `env:"LOGIN_IP_MAX_ATTEMPTS" envDefault:"20"`
+	LoginAttemptWindow      time.Duration `env:"LOGIN_ATTEMPT_WINDOW" envDefault:"15m"`
+	LoginLockoutDuration    time.Duration `env:"LOGIN_LOCKOUT_DURATION" envDefault:"1m"`
+	LoginMaxLockoutDuration time.Duration `env:"LOGIN_MAX_LOCKOUT_DURATION" envDefault:"1h"`
+
+	// PIN login on POS terminals is locked for PINLockoutDuration after PINMaxAttempts wrong PINs.
+	// Its tokens live for POSTokenTTL and are not refreshable.
+	PINMaxAttempts     int64         `env:"PIN_MAX_ATTEMPTS" envDefault:"5"`
+	PINLockoutDuration time.Duration `env:"PIN_LOCKOUT_DURATION" envDefault:"15m"`
+	POSTokenTTL        time.Duration `env:"POS_TOKEN_TTL" envDefault:"1h"`
+
+	// ServiceTokenTTL is the lifetime of tokens issued with the OAuth client credentials grant.
+	// ServiceAuthMode: "enforce" (AccountService calls need a service token with the method's
+	// scope) or "permissive" (failed checks are only logged, for rolling out client registrations).
+	ServiceTokenTTL time.Duration `env:"SERVICE_TOKEN_TTL" envDefault:"1h"`
+	ServiceAuthMode string        `env:"SERVICE_AUTH_MODE" envDefault:"enforce"`
+
+	// OIDCIssuer is the public base URL of this service, used as issuer of ID tokens and to build
+	// the discovery document. Authorization codes are valid for OIDCCodeTTL.
+	OIDCIssuer    string        `env:"OIDC_ISSUER" envDefault:"http://localhost:8080"`
+	OIDCCodeTTL   time.Duration `env:"OIDC_CODE_TTL" envDefault:"2m"`
+	OIDCTemplates string        `env:"OIDC_TEMPLATES" envDefault:"template/oidc/*.html"`
+
+	// Passwordless login sends a link or code valid for MagicLoginTTL, MagicLoginMaxAttempts
+	// codes can be tried per address and MagicLoginTTL. Requests are limited per address and
+	// MagicLoginSendWindow.
+	MagicLoginTTL         time.Duration `env:"MAGIC_LOGIN_TTL" envDefault:"15m"`
+	MagicLoginMaxAttempts int64         `env:"MAGIC_LOGIN_MAX_ATTEMPTS" envDefault:"5"`
+	MagicLoginSendLimit   int64         `env:"MAGIC_LOGIN_SEND_LIMIT" envDefault:"5"`
+	MagicLoginSendWindow  time.Duration `env:"MAGIC_LOGIN_SEND_WINDOW" envDefault:"1h"`
+	// MagicLoginURL is the frontend page the link token is appended to as ?token=...
+	MagicLoginURL string `env:"MAGIC_LOGIN_URL" envDefault:"http://localhost:3000/login/magic"`
+
+	PasswordResetTTL time.Duration `env:"PASSWORD_RESET_TTL" envDefault:"30m"`
+	// PasswordResetURL is the frontend page the reset token is appended to as ?token=...
+	PasswordResetURL string `env:"PASSWORD_RESET_URL" envDefault:"http://localhost:3000/reset-password"`
+
+	// EmailVerificationMode: "off" (verification is informational), "deny" (unverified users
+	// can not log in) or "restricted" (they get a token limited to the profile and verify endpoints).
+	EmailVerificationMode string        `env:"EMAIL_VERIFICATION_MODE" envDefault:"off"`
+	EmailVerificationTTL  time.Duration `env:"EMAIL_VERIFICATION_TTL" envDefault:"24h"`
+	EmailVerificationURL  string        `env:"EMAIL_VERIFICATION_URL" envDefault:"http://localhost:3000/verify-email"`
+}
@@ -0,0 +1,20 @@
+package configs
+
+import "time"
+
+// CampaignConfig mengatur pengiriman email campaign: jadwal dispatch di cmd/worker, ukuran batch
+// pesan RabbitMQ dan link unsubscribe.
+type CampaignConfig struct {
+	// Exchange receives the delivery batches, the worker binds DeliveryQueue to it.
+	Exchange         string `env:"CAMPAIGN_EXCHANGE" envDefault:"account.campaigns"`
+	DeliveryQueue    string `env:"CAMPAIGN_DELIVERY_QUEUE" envDefault:"accounts.campaign-deliveries"`
+	DispatchSchedule string `env:"CAMPAIGN_DISPATCH_SCHEDULE" envDefault:"@every 1m"`
+	// BatchSize is how many recipients one delivery message carries.
+	BatchSize int32 `env:"CAMPAIGN_BATCH_SIZE" envDefault:"100"`
+	// StaleAfter is when a dispatch that never finished, e.g. because the worker crashed, is
+	// picked up again.
+	StaleAfter time.Duration `env:"CAMPAIGN_DISPATCH_STALE_AFTER" envDefault:"15m"`
+	// UnsubscribeURL is the frontend page the unsubscribe token is appended to as ?token=...
+	UnsubscribeURL string        `env:"CAMPAIGN_UNSUBSCRIBE_URL" envDefault:"http://localhost:3000/unsubscribe"`
+	UnsubscribeTTL time.Duration `env:"CAMPAIGN_UNSUBSCRIBE_TTL" envDefault:"2160h"`
+}
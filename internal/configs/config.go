@@ -14,9 +14,15 @@ type AppConfig struct {
 	Redis     RedisConfig
 	GRPC      GrpcConfig
 	Server    ServerConfig
-	RabbitMQ  struct {
-		URL string `env:"RABBITMQ_URL,required"`
-	}
+	Auth      AuthConfig
+	Notifier  NotifierConfig
+	Identity  IdentityConfig
+	WebAuthn  WebAuthnConfig
+	RabbitMQ  RabbitMQConfig
+	Outbox    OutboxConfig
+	Worker    WorkerConfig
+	Campaign  CampaignConfig
+	Retention RetentionConfig
 }
 
 // LoadConfig sekarang akan mengisi struct AppConfig yang sudah terstruktur.
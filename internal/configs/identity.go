@@ -0,0 +1,50 @@
+package configs
+
+import "time"
+
+const (
+	googleProviderName = "google"
+	googleIssuer       = "https://accounts.google.com"
+)
+
+// IdentityConfig menampung provider login eksternal (Google, OIDC lain). Sebuah provider aktif
+// begitu CLIENT_ID-nya diisi.
+type IdentityConfig struct {
+	// CallbackURL is the frontend page registered as redirect URI at every provider. It posts the
+	// code and state it receives back to the API.
+	CallbackURL string        `env:"IDP_CALLBACK_URL" envDefault:"http://localhost:3000/auth/callback"`
+	StateTTL    time.Duration `env:"IDP_STATE_TTL" envDefault:"10m"`
+
+	Google IdentityProviderConfig `envPrefix:"IDP_GOOGLE_"`
+	// OIDC is any other OpenID Connect provider, e.g. Keycloak or Okta. It needs a NAME and ISSUER.
+	OIDC IdentityProviderConfig `envPrefix:"IDP_OIDC_"`
+}
+
+type IdentityProviderConfig struct {
+	Name         string   `env:"NAME"`
+	Issuer       string   `env:"ISSUER"`
+	ClientID     string   `env:"CLIENT_ID"`
+	ClientSecret string   `env:"CLIENT_SECRET"`
+	Scopes       []string `env:"SCOPES" envSeparator:" "`
+}
+
+// Providers returns the enabled providers with their defaults applied.
+func (c IdentityConfig) Providers() []IdentityProviderConfig {
+	var providers []IdentityProviderConfig
+
+	if c.Google.ClientID != "" {
+		google := c.Google
+		if google.Name == "" {
+			google.Name = googleProviderName
+		}
+		if google.Issuer == "" {
+			google.Issuer = googleIssuer
+		}
+		providers = append(providers, google)
+	}
+	if c.OIDC.ClientID != "" {
+		providers = append(providers, c.OIDC)
+	}
+
+	return providers
+}
@@ -0,0 +1,14 @@
+package configs
+
+// NotifierConfig memilih cara pengiriman notifikasi ke user (email reset password, dll).
+type NotifierConfig struct {
+	Driver   string `env:"NOTIFIER_DRIVER" envDefault:"log"`
+	FilePath string `env:"NOTIFIER_FILE_PATH" envDefault:"tmp/notifications.log"`
+
+	// SMTP settings of the "smtp" driver
+	SMTPHost     string `env:"SMTP_HOST"`
+	SMTPPort     int    `env:"SMTP_PORT" envDefault:"587"`
+	SMTPUsername string `env:"SMTP_USERNAME"`
+	SMTPPassword string `env:"SMTP_PASSWORD"`
+	SMTPFrom     string `env:"SMTP_FROM"`
+}
@@ -0,0 +1,15 @@
+package configs
+
+import "time"
+
+// OutboxConfig mengatur relay yang menerbitkan event dari tabel outbox ke RabbitMQ.
+type OutboxConfig struct {
+	RelayEnabled bool          `env:"OUTBOX_RELAY_ENABLED" envDefault:"true"`
+	PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" envDefault:"1s"`
+	BatchSize    int32         `env:"OUTBOX_BATCH_SIZE" envDefault:"100"`
+	// Lease is how long a claimed event is hidden from other relays while it is published.
+	Lease time.Duration `env:"OUTBOX_LEASE" envDefault:"1m"`
+	// RetryBase is the delay after the first failed publish, doubled per attempt up to RetryMax.
+	RetryBase time.Duration `env:"OUTBOX_RETRY_BASE" envDefault:"1s"`
+	RetryMax  time.Duration `env:"OUTBOX_RETRY_MAX" envDefault:"10m"`
+}
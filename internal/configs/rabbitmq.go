@@ -0,0 +1,12 @@
+package configs
+
+import "time"
+
+// RabbitMQConfig menampung koneksi ke RabbitMQ dan exchange tempat event akun diterbitkan.
+type RabbitMQConfig struct {
+	URL string `env:"RABBITMQ_URL,required"`
+	// EventsExchange is the durable topic exchange account events are published to.
+	EventsExchange string `env:"RABBITMQ_EVENTS_EXCHANGE" envDefault:"account.events"`
+	// PublishTimeout is how long to wait for the broker to confirm a message.
+	PublishTimeout time.Duration `env:"RABBITMQ_PUBLISH_TIMEOUT" envDefault:"5s"`
+}
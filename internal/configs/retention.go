@@ -0,0 +1,19 @@
+package configs
+
+import "time"
+
+// RetentionConfig mengatur kebijakan retensi data: berapa lama user yang sudah dihapus dan
+// tabel log disimpan sebelum dibersihkan oleh cmd/worker atau "admin retention".
+type RetentionConfig struct {
+	Schedule string `env:"RETENTION_SCHEDULE" envDefault:"@daily"`
+	// DeletedUserMode is "anonymize" to scrub the personal data of soft-deleted users and keep
+	// the row, or "delete" to remove the row.
+	DeletedUserMode string `env:"RETENTION_DELETED_USER_MODE" envDefault:"anonymize"`
+	// The periods below start at deletion, publication or campaign end. Zero disables the step.
+	DeletedUserAfter      time.Duration `env:"RETENTION_DELETED_USER_AFTER" envDefault:"720h"`
+	OutboxAfter           time.Duration `env:"RETENTION_OUTBOX_AFTER" envDefault:"168h"`
+	CampaignDeliveryAfter time.Duration `env:"RETENTION_CAMPAIGN_DELIVERY_AFTER" envDefault:"2160h"`
+	AuditAfter            time.Duration `env:"RETENTION_AUDIT_AFTER" envDefault:"8760h"`
+	// BatchSize is how many rows one statement removes, so a large backlog never holds long locks.
+	BatchSize int32 `env:"RETENTION_BATCH_SIZE" envDefault:"500"`
+}
@@ -1,7 +1,24 @@
 package configs
 
+import "time"
+
 type ServerConfig struct {
-	Port      string `env:"SERVER_PORT,required"`
-	GRPCPort  string `env:"GRPC_PORT,required"`
-	JWTSecret string `env:"JWT_SECRET,required"`
+	Port            string        `env:"SERVER_PORT,required"`
+	GRPCPort        string        `env:"GRPC_PORT,required"`
+	AccessTokenTTL  time.Duration `env:"ACCESS_TOKEN_TTL" envDefault:"15m"`
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL" envDefault:"720h"`
+
+	// Quota of AccountService calls per calling host and method
+	GRPCRateLimit       int64         `env:"GRPC_RATE_LIMIT" envDefault:"600"`
+	GRPCRateLimitWindow time.Duration `env:"GRPC_RATE_LIMIT_WINDOW" envDefault:"1m"`
+
+	// JWTSigningMethod selects HS256 (shared JWT_SECRET), RS256 or EdDSA (JWT_PRIVATE_KEY_PATH).
+	JWTSigningMethod        string   `env:"JWT_SIGNING_METHOD" envDefault:"HS256"`
+	JWTSecret               string   `env:"JWT_SECRET"`
+	JWTPrivateKeyPath       string   `env:"JWT_PRIVATE_KEY_PATH"`
+	JWTKeyID                string   `env:"JWT_KEY_ID"`
+	JWTVerificationKeyPaths []string `env:"JWT_VERIFICATION_KEY_PATHS" envSeparator:","`
+	// JWTLegacyHMACUntil (RFC 3339) keeps accepting HS256 tokens signed with JWT_SECRET after
+	// switching to RS256 or EdDSA, until the tokens issued before the switch have expired.
+	JWTLegacyHMACUntil time.Time `env:"JWT_LEGACY_HMAC_UNTIL"`
 }
@@ -0,0 +1,13 @@
+package configs
+
+import "time"
+
+// WebAuthnConfig menampung pengaturan relying party untuk passkey. RPID harus sama dengan domain
+// frontend (atau domain induknya), dan setiap origin frontend harus terdaftar di Origins.
+type WebAuthnConfig struct {
+	RPID    string   `env:"WEBAUTHN_RP_ID" envDefault:"localhost"`
+	RPName  string   `env:"WEBAUTHN_RP_NAME" envDefault:"Shopeezy"`
+	Origins []string `env:"WEBAUTHN_ORIGINS" envSeparator:"," envDefault:"http://localhost:3000"`
+	// SessionTTL is how long a registration or login ceremony may take.
+	SessionTTL time.Duration `env:"WEBAUTHN_SESSION_TTL" envDefault:"5m"`
+}
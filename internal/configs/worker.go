@@ -0,0 +1,21 @@
+package configs
+
+import "time"
+
+// WorkerConfig mengatur proses background di cmd/worker: consumer RabbitMQ, jadwal cron dan
+// batas waktu shutdown.
+type WorkerConfig struct {
+	// Concurrency is how many messages each consumer handles at the same time.
+	Concurrency int `env:"WORKER_CONCURRENCY" envDefault:"8"`
+	// MaxRetries is how often a failed message is retried before it goes to the dead-letter queue.
+	MaxRetries int `env:"WORKER_MAX_RETRIES" envDefault:"5"`
+	// RetryDelay is the wait before the first retry, it doubles with every retry up to MaxRetryDelay.
+	RetryDelay    time.Duration `env:"WORKER_RETRY_DELAY" envDefault:"5s"`
+	MaxRetryDelay time.Duration `env:"WORKER_MAX_RETRY_DELAY" envDefault:"5m"`
+	// ShutdownTimeout bounds how long in-flight messages and jobs may take after SIGTERM.
+	ShutdownTimeout    time.Duration `env:"WORKER_SHUTDOWN_TIMEOUT" envDefault:"30s"`
+	AccountEventsQueue string        `env:"WORKER_ACCOUNT_EVENTS_QUEUE" envDefault:"accounts.security-notices"`
+	TokenPurgeSchedule string        `env:"WORKER_TOKEN_PURGE_SCHEDULE" envDefault:"@hourly"`
+	// TokenPurgeGrace is how long expired tokens are kept before they are purged.
+	TokenPurgeGrace time.Duration `env:"WORKER_TOKEN_PURGE_GRACE" envDefault:"168h"`
+}
@@ -0,0 +1,91 @@
+// Package consumers holds the RabbitMQ message handlers run by cmd/worker.
+package consumers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/rabbitmq"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+// AccountEventRoutingKeys are the events AccountEventConsumer handles.
+var AccountEventRoutingKeys = []string{
+	entities.EventUserUpdated,
+	entities.EventUserPasswordChanged,
+}
+
+// eventEnvelope is entities.Event with the data left undecoded until the type is known.
+type eventEnvelope struct {
+	ID         uuid.UUID       `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// AccountEventConsumer sends the security notices for the account events published through
+// the outbox.
+type AccountEventConsumer struct {
+	securityNoticeService services.SecurityNoticeService
+	log                   *logrus.Logger
+}
+
+func NewAccountEventConsumer(securityNoticeService services.SecurityNoticeService, log *logrus.Logger) *AccountEventConsumer {
+	return &AccountEventConsumer{
+		securityNoticeService: securityNoticeService,
+		log:                   log,
+	}
+}
+
+// Handle handles one account event. Events that do not decode, or come in a version this
+// build does not know, are poison and go straight to the dead-letter queue.
+func (c *AccountEventConsumer) Handle(ctx context.Context, msg amqp.Delivery) error {
+	var event eventEnvelope
+	if err := json.Unmarshal(msg.Body, &event); err != nil {
+		return fmt.Errorf("%w: failed to decode account event: %v", rabbitmq.ErrPoisonMessage, err)
+	}
+	if event.Version > entities.EventVersion {
+		return fmt.Errorf("%w: unsupported version %d of %s", rabbitmq.ErrPoisonMessage, event.Version, event.Type)
+	}
+
+	logger := c.log.WithFields(logrus.Fields{
+		"event_id":   event.ID,
+		"event_type": event.Type,
+	})
+
+	switch event.Type {
+	case entities.EventUserUpdated:
+		var data entities.UserEventData
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return fmt.Errorf("%w: failed to decode %s: %v", rabbitmq.ErrPoisonMessage, event.Type, err)
+		}
+		if data.PreviousEmail == "" {
+			return nil
+		}
+		if err := c.securityNoticeService.EmailChanged(ctx, data.Name, data.PreviousEmail, data.Email, event.OccurredAt); err != nil {
+			return err
+		}
+	case entities.EventUserPasswordChanged:
+		var data entities.PasswordChangedEventData
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return fmt.Errorf("%w: failed to decode %s: %v", rabbitmq.ErrPoisonMessage, event.Type, err)
+		}
+		if err := c.securityNoticeService.PasswordChanged(ctx, data.UserID, event.OccurredAt); err != nil {
+			return err
+		}
+	default:
+		logger.Debug("Ignoring account event")
+		return nil
+	}
+
+	logger.Info("Security notice sent")
+	return nil
+}
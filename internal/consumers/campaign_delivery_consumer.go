@@ -0,0 +1,47 @@
+package consumers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/rabbitmq"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+// CampaignDeliveryConsumer sends the mails of the delivery batches published by the campaign
+// dispatcher.
+type CampaignDeliveryConsumer struct {
+	campaignService services.CampaignService
+	log             *logrus.Logger
+}
+
+func NewCampaignDeliveryConsumer(campaignService services.CampaignService, log *logrus.Logger) *CampaignDeliveryConsumer {
+	return &CampaignDeliveryConsumer{
+		campaignService: campaignService,
+		log:             log,
+	}
+}
+
+// Handle handles one delivery batch. A retried batch only sends the deliveries that were not
+// handled before it failed.
+func (c *CampaignDeliveryConsumer) Handle(ctx context.Context, msg amqp.Delivery) error {
+	var batch entities.CampaignDeliveryBatch
+	if err := json.Unmarshal(msg.Body, &batch); err != nil {
+		return fmt.Errorf("%w: failed to decode delivery batch: %v", rabbitmq.ErrPoisonMessage, err)
+	}
+
+	if err := c.campaignService.DeliverBatch(ctx, &batch); err != nil {
+		return err
+	}
+
+	c.log.WithFields(logrus.Fields{
+		"campaign_id": batch.CampaignID,
+		"deliveries":  len(batch.DeliveryIDs),
+	}).Debug("Campaign delivery batch handled")
+	return nil
+}
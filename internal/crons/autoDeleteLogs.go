@@ -1 +1,16 @@
 package crons
+
+import (
+	"context"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+// ApplyRetention purges the users that were soft-deleted longer than the retention policy
+// allows and prunes the outbox, campaign delivery and audit logs.
+func ApplyRetention(retentionService services.RetentionService) Job {
+	return func(ctx context.Context) error {
+		_, err := retentionService.Run(ctx, false)
+		return err
+	}
+}
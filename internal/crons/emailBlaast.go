@@ -1 +1,16 @@
 package crons
+
+import (
+	"context"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+// DispatchCampaigns fans out the email campaigns whose scheduled time has come. The mails
+// themselves are sent by the campaign delivery consumer.
+func DispatchCampaigns(campaignService services.CampaignService) Job {
+	return func(ctx context.Context) error {
+		_, err := campaignService.DispatchDue(ctx)
+		return err
+	}
+}
@@ -0,0 +1,16 @@
+package crons
+
+import (
+	"context"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+// PurgeExpiredTokens removes the refresh tokens, password reset tokens and authorization
+// codes whose grace period after expiry is over.
+func PurgeExpiredTokens(tokenPurgeService services.TokenPurgeService) Job {
+	return func(ctx context.Context) error {
+		_, err := tokenPurgeService.PurgeExpired(ctx)
+		return err
+	}
+}
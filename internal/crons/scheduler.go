@@ -0,0 +1,78 @@
+// Package crons holds the periodic jobs of cmd/worker and the scheduler that runs them.
+package crons
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// Job is one run of a periodic job.
+type Job func(ctx context.Context) error
+
+// Scheduler runs jobs on cron schedules. A run that is still going when the job is due again
+// makes the scheduler skip that tick, and a panicking job is logged instead of killing the
+// worker.
+type Scheduler struct {
+	cron       *cron.Cron
+	ctx        context.Context
+	cancelJobs context.CancelFunc
+	log        *logrus.Logger
+}
+
+func NewScheduler(log *logrus.Logger) *Scheduler {
+	logger := cron.PrintfLogger(log)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Scheduler{
+		cron:       cron.New(cron.WithChain(cron.Recover(logger))),
+		ctx:        ctx,
+		cancelJobs: cancel,
+		log:        log,
+	}
+}
+
+// Add schedules job under name. spec is a standard five field cron expression or a
+// descriptor such as "@hourly" or "@every 10m".
+func (s *Scheduler) Add(name string, spec string, job Job) error {
+	logger := cron.PrintfLogger(s.log)
+
+	wrapped := cron.NewChain(cron.SkipIfStillRunning(logger)).Then(cron.FuncJob(func() {
+		start := time.Now()
+		entry := s.log.WithField("job", name)
+		entry.Info("Cron job started")
+
+		if err := job(s.ctx); err != nil {
+			entry.WithError(err).WithField("duration", time.Since(start)).Error("Cron job failed")
+			return
+		}
+		entry.WithField("duration", time.Since(start)).Info("Cron job finished")
+	}))
+
+	if _, err := s.cron.AddJob(spec, wrapped); err != nil {
+		return fmt.Errorf("invalid schedule %q for job %s: %w", spec, name, err)
+	}
+
+	s.log.WithFields(logrus.Fields{"job": name, "schedule": spec}).Info("Cron job scheduled")
+	return nil
+}
+
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops scheduling new runs and waits for the running ones. If ctx ends first, the
+// running jobs are cancelled and Stop returns without waiting for them further.
+func (s *Scheduler) Stop(ctx context.Context) {
+	done := s.cron.Stop()
+
+	select {
+	case <-done.Done():
+	case <-ctx.Done():
+		s.log.Warn("Cron jobs did not finish in time, cancelling them")
+	}
+	s.cancelJobs()
+}
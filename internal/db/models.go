@@ -6,21 +6,269 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-type User struct {
+type ApiKey struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	CreatedAt  time.Time
+	RevokedAt  sql.NullTime
+}
+
+type AuditEvent struct {
+	ID           uuid.UUID
+	Action       string
+	ActorID      uuid.NullUUID
+	TargetUserID uuid.NullUUID
+	IpAddress    string
+	UserAgent    string
+	RequestID    string
+	Metadata     json.RawMessage
+	CreatedAt    time.Time
+}
+
+type EmailCampaign struct {
+	ID                    uuid.UUID
+	Name                  string
+	Subject               string
+	Body                  string
+	SegmentRoles          []string
+	SegmentSignedUpAfter  sql.NullTime
+	SegmentSignedUpBefore sql.NullTime
+	SegmentEmailVerified  sql.NullBool
+	Status                string
+	ScheduledAt           sql.NullTime
+	StartedAt             sql.NullTime
+	DispatchedAt          sql.NullTime
+	CompletedAt           sql.NullTime
+	CancelledAt           sql.NullTime
+	RecipientCount        int32
+	CreatedBy             uuid.NullUUID
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+}
+
+type EmailCampaignDelivery struct {
+	ID         uuid.UUID
+	CampaignID uuid.UUID
+	UserID     uuid.UUID
+	Email      string
+	Status     string
+	Error      string
+	SentAt     sql.NullTime
+	CreatedAt  time.Time
+}
+
+type MfaRecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type OauthAuthorizationCode struct {
+	ID            uuid.UUID
+	CodeHash      string
+	OauthClientID uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	Nonce         string
+	CodeChallenge string
+	Amr           []string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+	ConsumedAt    sql.NullTime
+	CreatedAt     time.Time
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	ClientID     string
+	Name         string
+	SecretHash   string
+	Scopes       []string
+	CreatedBy    uuid.NullUUID
+	CreatedAt    time.Time
+	LastUsedAt   sql.NullTime
+	RevokedAt    sql.NullTime
+	RedirectUris []string
+	GrantTypes   []string
+	IsPublic     bool
+	FirstParty   bool
+}
+
+type Organization struct {
+	ID        uuid.UUID
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Outbox struct {
+	ID            uuid.UUID
+	AggregateType string
+	AggregateID   uuid.UUID
+	EventType     string
+	Payload       json.RawMessage
+	Attempts      int32
+	LastError     string
+	NextAttemptAt time.Time
+	PublishedAt   sql.NullTime
+	CreatedAt     time.Time
+}
+
+type PasswordResetToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type Permission struct {
 	ID          uuid.UUID
 	Name        string
-	Username    string
+	Description string
+	CreatedAt   time.Time
+}
+
+type PosTerminal struct {
+	ID           uuid.UUID
+	StoreID      uuid.UUID
+	Name         string
+	RegisteredBy uuid.NullUUID
+	CreatedAt    time.Time
+	LastUsedAt   sql.NullTime
+	RevokedAt    sql.NullTime
+	SecretHash   string
+}
+
+type RefreshToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	FamilyID  uuid.UUID
+	ParentID  uuid.NullUUID
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	RevokedAt sql.NullTime
+	CreatedAt time.Time
+	StoreID   uuid.NullUUID
+	Amr       []string
+	ClientID  string
+}
+
+type Role struct {
+	ID          uuid.UUID
+	Name        string
+	Description string
+	Priority    int32
+	CreatedAt   time.Time
+}
+
+type RolePermission struct {
+	RoleID       uuid.UUID
+	PermissionID uuid.UUID
+}
+
+type Store struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+	Name           string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type StoreMembership struct {
+	UserID    uuid.UUID
+	StoreID   uuid.UUID
+	Role      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type User struct {
+	ID              uuid.UUID
+	Name            string
+	Username        string
+	Email           string
+	PhoneNumber     string
+	Address         string
+	Password        string
+	Role            string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       sql.NullTime
+	EmailVerifiedAt sql.NullTime
+	AnonymizedAt    sql.NullTime
+}
+
+type UserIdentity struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Provider    string
+	Subject     string
 	Email       string
-	PhoneNumber string
-	Address     string
-	Password    string
-	Role        string
 	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	DeletedAt   sql.NullTime
+	LastLoginAt sql.NullTime
+}
+
+type UserMfa struct {
+	UserID       uuid.UUID
+	TotpSecret   string
+	LastUsedStep int64
+	EnabledAt    sql.NullTime
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type UserPin struct {
+	UserID    uuid.UUID
+	PinHash   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type UserPreference struct {
+	UserID            uuid.UUID
+	PasswordlessLogin bool
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	MarketingEmails   bool
+}
+
+type UserRole struct {
+	UserID    uuid.UUID
+	RoleID    uuid.UUID
+	GrantedBy uuid.NullUUID
+	CreatedAt time.Time
+}
+
+type WebauthnCredential struct {
+	ID              uuid.UUID
+	UserID          uuid.UUID
+	CredentialID    []byte
+	PublicKey       []byte
+	AttestationType string
+	Aaguid          []byte
+	SignCount       int64
+	Transports      []string
+	BackupEligible  bool
+	BackupState     bool
+	Name            string
+	CreatedAt       time.Time
+	LastUsedAt      sql.NullTime
 }
@@ -7,12 +7,55 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
 
+const anonymizeUser = `-- name: AnonymizeUser :exec
+UPDATE users
+SET
+    "name" = 'deleted-' || id,
+    username = 'deleted-' || id,
+    email = 'deleted-' || id || '@anonymized.invalid',
+    phone_number = '',
+    "address" = '',
+    "password" = '',
+    email_verified_at = NULL,
+    anonymized_at = now(),
+    updated_at = now()
+WHERE id = $1 AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) AnonymizeUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, anonymizeUser, id)
+	return err
+}
+
+const countExpiredDeletedUsers = `-- name: CountExpiredDeletedUsers :one
+SELECT
+    count(*) AS users,
+    COALESCE(sum((SELECT count(*) FROM api_keys k WHERE k.user_id = u.id)), 0)::bigint AS api_keys,
+    COALESCE(sum((SELECT count(*) FROM user_identities i WHERE i.user_id = u.id)), 0)::bigint AS identities
+FROM users u
+WHERE u.deleted_at < $1 AND u.anonymized_at IS NULL
+`
+
+type CountExpiredDeletedUsersRow struct {
+	Users      int64
+	ApiKeys    int64
+	Identities int64
+}
+
+func (q *Queries) CountExpiredDeletedUsers(ctx context.Context, deletedAt sql.NullTime) (CountExpiredDeletedUsersRow, error) {
+	row := q.db.QueryRowContext(ctx, countExpiredDeletedUsers, deletedAt)
+	var i CountExpiredDeletedUsersRow
+	err := row.Scan(&i.Users, &i.ApiKeys, &i.Identities)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
     id, 
@@ -23,7 +66,7 @@ INSERT INTO users (
     phone_number, 
     "address", 
     role
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, name, username, email, phone_number, address, password, role, created_at, updated_at, deleted_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, name, username, email, phone_number, address, password, role, created_at, updated_at, deleted_at, email_verified_at, anonymized_at
 `
 
 type CreateUserParams struct {
@@ -61,6 +104,8 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.EmailVerifiedAt,
+		&i.AnonymizedAt,
 	)
 	return i, err
 }
@@ -68,7 +113,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 const deleteUser = `-- name: DeleteUser :one
 UPDATE users
 SET deleted_at = now()
-WHERE id = $1 AND deleted_at IS NULL RETURNING id, name, username, email, phone_number, address, password, role, created_at, updated_at, deleted_at
+WHERE id = $1 AND deleted_at IS NULL RETURNING id, name, username, email, phone_number, address, password, role, created_at, updated_at, deleted_at, email_verified_at, anonymized_at
 `
 
 func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (User, error) {
@@ -86,80 +131,69 @@ func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.EmailVerifiedAt,
+		&i.AnonymizedAt,
 	)
 	return i, err
 }
 
-const getAllUsers = `-- name: GetAllUsers :many
-SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at, email_verified_at
 FROM users
-WHERE deleted_at IS NULL
+WHERE lower(email) = lower($1::text) AND deleted_at IS NULL
 `
 
-type GetAllUsersRow struct {
-	ID          uuid.UUID
-	Name        string
-	Username    string
-	Email       string
-	Password    string
-	PhoneNumber string
-	Address     string
-	Role        string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+type GetUserByEmailRow struct {
+	ID              uuid.UUID
+	Name            string
+	Username        string
+	Email           string
+	Password        string
+	PhoneNumber     string
+	Address         string
+	Role            string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	EmailVerifiedAt sql.NullTime
 }
 
-func (q *Queries) GetAllUsers(ctx context.Context) ([]GetAllUsersRow, error) {
-	rows, err := q.db.QueryContext(ctx, getAllUsers)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []GetAllUsersRow
-	for rows.Next() {
-		var i GetAllUsersRow
-		if err := rows.Scan(
-			&i.ID,
-			&i.Name,
-			&i.Username,
-			&i.Email,
-			&i.Password,
-			&i.PhoneNumber,
-			&i.Address,
-			&i.Role,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i GetUserByEmailRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Username,
+		&i.Email,
+		&i.Password,
+		&i.PhoneNumber,
+		&i.Address,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at
+SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at, email_verified_at
 FROM users
 WHERE id = $1 AND deleted_at IS NULL
 `
 
 type GetUserByIDRow struct {
-	ID          uuid.UUID
-	Name        string
-	Username    string
-	Email       string
-	Password    string
-	PhoneNumber string
-	Address     string
-	Role        string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID              uuid.UUID
+	Name            string
+	Username        string
+	Email           string
+	Password        string
+	PhoneNumber     string
+	Address         string
+	Role            string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	EmailVerifiedAt sql.NullTime
 }
 
 func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (GetUserByIDRow, error) {
@@ -176,27 +210,29 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (GetUserByIDRow
 		&i.Role,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const getUserByIDs = `-- name: GetUserByIDs :many
-SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at
+SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at, email_verified_at
 FROM users
 WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL
 `
 
 type GetUserByIDsRow struct {
-	ID          uuid.UUID
-	Name        string
-	Username    string
-	Email       string
-	Password    string
-	PhoneNumber string
-	Address     string
-	Role        string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID              uuid.UUID
+	Name            string
+	Username        string
+	Email           string
+	Password        string
+	PhoneNumber     string
+	Address         string
+	Role            string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	EmailVerifiedAt sql.NullTime
 }
 
 func (q *Queries) GetUserByIDs(ctx context.Context, dollar_1 []uuid.UUID) ([]GetUserByIDsRow, error) {
@@ -219,6 +255,7 @@ func (q *Queries) GetUserByIDs(ctx context.Context, dollar_1 []uuid.UUID) ([]Get
 			&i.Role,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.EmailVerifiedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -234,22 +271,23 @@ func (q *Queries) GetUserByIDs(ctx context.Context, dollar_1 []uuid.UUID) ([]Get
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at
+SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at, email_verified_at
 FROM users
-WHERE username = $1 AND deleted_at IS NULL
+WHERE lower(username) = lower($1::text) AND deleted_at IS NULL
 `
 
 type GetUserByUsernameRow struct {
-	ID          uuid.UUID
-	Name        string
-	Username    string
-	Email       string
-	Password    string
-	PhoneNumber string
-	Address     string
-	Role        string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID              uuid.UUID
+	Name            string
+	Username        string
+	Email           string
+	Password        string
+	PhoneNumber     string
+	Address         string
+	Role            string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	EmailVerifiedAt sql.NullTime
 }
 
 func (q *Queries) GetUserByUsername(ctx context.Context, username string) (GetUserByUsernameRow, error) {
@@ -266,10 +304,356 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (GetUs
 		&i.Role,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
+const hardDeleteUser = `-- name: HardDeleteUser :exec
+DELETE FROM users
+WHERE id = $1 AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) HardDeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hardDeleteUser, id)
+	return err
+}
+
+const listExpiredDeletedUsers = `-- name: ListExpiredDeletedUsers :many
+SELECT id FROM users
+WHERE deleted_at < $1 AND anonymized_at IS NULL AND id > $2
+ORDER BY id
+LIMIT $3
+`
+
+type ListExpiredDeletedUsersParams struct {
+	DeletedBefore sql.NullTime
+	AfterID       uuid.UUID
+	BatchSize     int32
+}
+
+func (q *Queries) ListExpiredDeletedUsers(ctx context.Context, arg ListExpiredDeletedUsersParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, listExpiredDeletedUsers, arg.DeletedBefore, arg.AfterID, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, "name", username, email, "password",phone_number, "address", "role", created_at, updated_at, email_verified_at, deleted_at
+FROM users
+WHERE
+    (
+        $1::text IS NULL
+        OR EXISTS (
+            SELECT 1 FROM user_roles ur
+            JOIN roles r ON r.id = ur.role_id
+            WHERE ur.user_id = users.id AND r."name" = $1
+        )
+    )
+    AND ($2::timestamptz IS NULL OR created_at >= $2)
+    AND ($3::timestamptz IS NULL OR created_at < $3)
+    AND (CASE $4::text
+        WHEN 'only' THEN deleted_at IS NOT NULL
+        WHEN 'include' THEN TRUE
+        ELSE deleted_at IS NULL
+    END)
+    AND (
+        $5::text IS NULL
+        OR "name" ILIKE '%' || $5 || '%'
+        OR username ILIKE '%' || $5 || '%'
+        OR email ILIKE '%' || $5 || '%'
+    )
+    AND (
+        $6::uuid IS NULL
+        OR ($7::text = 'created_at' AND (created_at, id) > ($8::timestamptz, $6))
+        OR ($7::text = '-created_at' AND (created_at, id) < ($8::timestamptz, $6))
+        OR ($7::text = 'name' AND ("name", id) > ($9::text, $6))
+        OR ($7::text = '-name' AND ("name", id) < ($9::text, $6))
+    )
+    AND (
+        $10::uuid IS NULL
+        OR EXISTS (
+            SELECT 1 FROM store_memberships sm
+            JOIN stores s ON s.id = sm.store_id
+            WHERE sm.user_id = users.id AND s.organization_id = $10
+        )
+    )
+ORDER BY
+    CASE WHEN $7::text = 'created_at' THEN created_at END ASC,
+    CASE WHEN $7::text = '-created_at' THEN created_at END DESC,
+    CASE WHEN $7::text = 'name' THEN "name" END ASC,
+    CASE WHEN $7::text = '-name' THEN "name" END DESC,
+    CASE WHEN $7::text IN ('created_at', 'name') THEN id END ASC,
+    CASE WHEN $7::text IN ('-created_at', '-name') THEN id END DESC
+LIMIT $11
+`
+
+type ListUsersParams struct {
+	Role            sql.NullString
+	CreatedFrom     sql.NullTime
+	CreatedTo       sql.NullTime
+	Deleted         string
+	Search          sql.NullString
+	CursorID        uuid.NullUUID
+	Sort            string
+	CursorCreatedAt sql.NullTime
+	CursorName      sql.NullString
+	OrganizationID  uuid.NullUUID
+	PageLimit       int32
+}
+
+type ListUsersRow struct {
+	ID              uuid.UUID
+	Name            string
+	Username        string
+	Email           string
+	Password        string
+	PhoneNumber     string
+	Address         string
+	Role            string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	EmailVerifiedAt sql.NullTime
+	DeletedAt       sql.NullTime
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]ListUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers,
+		arg.Role,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Deleted,
+		arg.Search,
+		arg.CursorID,
+		arg.Sort,
+		arg.CursorCreatedAt,
+		arg.CursorName,
+		arg.OrganizationID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsersRow
+	for rows.Next() {
+		var i ListUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Username,
+			&i.Email,
+			&i.Password,
+			&i.PhoneNumber,
+			&i.Address,
+			&i.Role,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EmailVerifiedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :execrows
+UPDATE users
+SET email_verified_at = now()
+WHERE id = $1 AND email = $2 AND email_verified_at IS NULL AND deleted_at IS NULL
+`
+
+type MarkUserEmailVerifiedParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markUserEmailVerified, arg.ID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const purgeUserCredentials = `-- name: PurgeUserCredentials :one
+WITH deleted_api_keys AS (
+    DELETE FROM api_keys WHERE user_id = $1 RETURNING id
+), deleted_identities AS (
+    DELETE FROM user_identities WHERE user_id = $1 RETURNING id
+), deleted_refresh_tokens AS (
+    DELETE FROM refresh_tokens WHERE user_id = $1
+), deleted_mfa AS (
+    DELETE FROM user_mfa WHERE user_id = $1
+), deleted_recovery_codes AS (
+    DELETE FROM mfa_recovery_codes WHERE user_id = $1
+), deleted_reset_tokens AS (
+    DELETE FROM password_reset_tokens WHERE user_id = $1
+), deleted_authorization_codes AS (
+    DELETE FROM oauth_authorization_codes WHERE user_id = $1
+), deleted_pins AS (
+    DELETE FROM user_pins WHERE user_id = $1
+), deleted_passkeys AS (
+    DELETE FROM webauthn_credentials WHERE user_id = $1
+), deleted_preferences AS (
+    DELETE FROM user_preferences WHERE user_id = $1
+), deleted_roles AS (
+    DELETE FROM user_roles WHERE user_id = $1
+), deleted_memberships AS (
+    DELETE FROM store_memberships WHERE user_id = $1
+), deleted_deliveries AS (
+    DELETE FROM email_campaign_deliveries WHERE user_id = $1
+)
+SELECT
+    (SELECT count(*) FROM deleted_api_keys) AS api_keys,
+    (SELECT count(*) FROM deleted_identities) AS identities
+`
+
+type PurgeUserCredentialsRow struct {
+	ApiKeys    int64
+	Identities int64
+}
+
+func (q *Queries) PurgeUserCredentials(ctx context.Context, userID uuid.UUID) (PurgeUserCredentialsRow, error) {
+	row := q.db.QueryRowContext(ctx, purgeUserCredentials, userID)
+	var i PurgeUserCredentialsRow
+	err := row.Scan(&i.ApiKeys, &i.Identities)
+	return i, err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+WITH ranked AS (
+    SELECT id, "name", username, email, phone_number, "address", "role", created_at, updated_at, email_verified_at,
+        (GREATEST(
+            similarity("name", $1::text),
+            similarity(username, $1),
+            similarity(email, $1),
+            similarity(phone_number, $1)
+        ) + ts_rank(
+            to_tsvector('simple', "name" || ' ' || username || ' ' || email || ' ' || phone_number),
+            plainto_tsquery('simple', $1)
+        ))::real AS score
+    FROM users
+    WHERE deleted_at IS NULL
+        AND (
+            "name" % $1
+            OR username % $1
+            OR email % $1
+            OR phone_number % $1
+            OR "name" ILIKE $2::text
+            OR username ILIKE $2
+            OR email ILIKE $2
+            OR phone_number ILIKE $2
+            OR to_tsvector('simple', "name" || ' ' || username || ' ' || email || ' ' || phone_number)
+                @@ plainto_tsquery('simple', $1)
+        )
+        AND (
+            $3::uuid IS NULL
+            OR EXISTS (
+                SELECT 1 FROM store_memberships sm
+                JOIN stores s ON s.id = sm.store_id
+                WHERE sm.user_id = users.id AND s.organization_id = $3
+            )
+        )
+)
+SELECT id, "name", username, email, phone_number, "address", "role", created_at, updated_at, email_verified_at, score
+FROM ranked
+WHERE $4::uuid IS NULL
+    OR (score, id) < ($5::real, $4)
+ORDER BY score DESC, id DESC
+LIMIT $6
+`
+
+type SearchUsersParams struct {
+	Query          string
+	Pattern        string
+	OrganizationID uuid.NullUUID
+	CursorID       uuid.NullUUID
+	CursorScore    sql.NullFloat64
+	PageLimit      int32
+}
+
+type SearchUsersRow struct {
+	ID              uuid.UUID
+	Name            string
+	Username        string
+	Email           string
+	PhoneNumber     string
+	Address         string
+	Role            string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	EmailVerifiedAt sql.NullTime
+	Score           float32
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers,
+		arg.Query,
+		arg.Pattern,
+		arg.OrganizationID,
+		arg.CursorID,
+		arg.CursorScore,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchUsersRow
+	for rows.Next() {
+		var i SearchUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Username,
+			&i.Email,
+			&i.PhoneNumber,
+			&i.Address,
+			&i.Role,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EmailVerifiedAt,
+			&i.Score,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET
@@ -280,8 +664,9 @@ SET
     "role" = $6,
     phone_number = $7,
     "address" = $8,
+    email_verified_at = CASE WHEN email = $4 THEN email_verified_at END,
     updated_at = now()
-WHERE id = $1 AND deleted_at IS NULL RETURNING id, name, username, email, phone_number, address, password, role, created_at, updated_at, deleted_at
+WHERE id = $1 AND deleted_at IS NULL RETURNING id, name, username, email, phone_number, address, password, role, created_at, updated_at, deleted_at, email_verified_at, anonymized_at
 `
 
 type UpdateUserParams struct {
@@ -319,6 +704,26 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.EmailVerifiedAt,
+		&i.AnonymizedAt,
 	)
 	return i, err
 }
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET
+    "password" = $2,
+    updated_at = now()
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+type UpdateUserPasswordParams struct {
+	ID       uuid.UUID
+	Password string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.Password)
+	return err
+}
@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions of the security audit log.
+const (
+	AuditLoginSucceeded     = "login.succeeded"
+	AuditLoginFailed        = "login.failed"
+	AuditLogout             = "logout"
+	AuditUserRegistered     = "user.registered"
+	AuditUserUpdated        = "user.updated"
+	AuditUserDeleted        = "user.deleted"
+	AuditRoleGranted        = "role.granted"
+	AuditRoleRevoked        = "role.revoked"
+	AuditTokenRevoked       = "token.revoked"
+	AuditRefreshTokenReused = "token.refresh_reused"
+)
+
+// Reasons recorded on AuditLoginFailed events.
+const (
+	AuditReasonInvalidCredentials = "invalid_credentials"
+	AuditReasonLocked             = "locked"
+	AuditReasonRateLimited        = "rate_limited"
+	AuditReasonEmailNotVerified   = "email_not_verified"
+)
+
+// AuditEvent is one entry of the security audit log. ActorID is who acted, TargetUserID the
+// account acted upon; either is nil when unknown, e.g. a failed login for an unknown username.
+type AuditEvent struct {
+	ID           uuid.UUID
+	Action       string
+	ActorID      *uuid.UUID
+	TargetUserID *uuid.UUID
+	IPAddress    string
+	UserAgent    string
+	RequestID    string
+	Metadata     json.RawMessage
+	CreatedAt    time.Time
+}
+
+// AuditChange is the old and new value of a field in the diff of a user.updated event. Both
+// are empty for secrets, which are only marked as changed.
+type AuditChange struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
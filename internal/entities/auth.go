@@ -0,0 +1,15 @@
+package entities
+
+// Authentication methods recorded in the amr claim (RFC 8176). AMRAPIKey is our own value for
+// requests authenticated with an API key instead of a session token, AMRFederated marks a login
+// at an external identity provider. AMROTP covers the one-time links and codes sent by email,
+// AMRPasskey a WebAuthn assertion.
+const (
+	AMRPassword  = "pwd"
+	AMRMFA       = "mfa"
+	AMRPIN       = "pin"
+	AMRAPIKey    = "api_key"
+	AMRFederated = "fed"
+	AMROTP       = "otp"
+	AMRPasskey   = "hwk"
+)
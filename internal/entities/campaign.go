@@ -0,0 +1,85 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Campaign statuses. A draft is scheduled, claimed by the worker once it is due and sent when
+// every delivery was attempted. Only campaigns that are not sent yet can be cancelled.
+const (
+	CampaignStatusDraft     = "draft"
+	CampaignStatusScheduled = "scheduled"
+	CampaignStatusSending   = "sending"
+	CampaignStatusSent      = "sent"
+	CampaignStatusCancelled = "cancelled"
+)
+
+// Delivery statuses, one delivery is created per recipient when the campaign is dispatched.
+const (
+	DeliveryStatusPending = "pending"
+	DeliveryStatusSending = "sending"
+	DeliveryStatusSent    = "sent"
+	DeliveryStatusFailed  = "failed"
+	// DeliveryStatusSkipped is used for recipients that unsubscribed or were deleted after the
+	// dispatch, and for the pending deliveries of a cancelled campaign.
+	DeliveryStatusSkipped = "skipped"
+)
+
+// CampaignSegment selects the recipients of a campaign. Empty fields do not filter, users that
+// turned off marketing emails are never selected.
+type CampaignSegment struct {
+	Roles          []string
+	SignedUpAfter  *time.Time
+	SignedUpBefore *time.Time
+	EmailVerified  *bool
+}
+
+// Campaign is a marketing email sent to a segment of the account holders. Subject and Body are
+// text/template sources rendered per recipient with CampaignTemplateData.
+type Campaign struct {
+	ID             uuid.UUID
+	Name           string
+	Subject        string
+	Body           string
+	Segment        CampaignSegment
+	Status         string
+	ScheduledAt    *time.Time
+	StartedAt      *time.Time
+	DispatchedAt   *time.Time
+	CompletedAt    *time.Time
+	CancelledAt    *time.Time
+	RecipientCount int32
+	CreatedBy      *uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	// Deliveries counts the deliveries per status, it is only filled for a single campaign.
+	Deliveries map[string]int64
+}
+
+// CampaignTemplateData is what the subject and body templates of a campaign can use.
+type CampaignTemplateData struct {
+	Name           string
+	Username       string
+	Email          string
+	UnsubscribeURL string
+}
+
+// CampaignPreview is a campaign rendered for one sample recipient.
+type CampaignPreview struct {
+	To             string
+	Subject        string
+	Body           string
+	RecipientCount int64
+}
+
+// CampaignDeliveryRoutingKey is the routing key of the delivery batches on the campaign exchange.
+const CampaignDeliveryRoutingKey = "campaign.delivery"
+
+// CampaignDeliveryBatch is the message the dispatcher publishes for the deliveries one consumer
+// call sends.
+type CampaignDeliveryBatch struct {
+	CampaignID  uuid.UUID   `json:"campaign_id"`
+	DeliveryIDs []uuid.UUID `json:"delivery_ids"`
+}
@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Account events published to the message broker. The type is also the routing key.
+const (
+	EventUserRegistered      = "account.user.registered"
+	EventUserUpdated         = "account.user.updated"
+	EventUserDeleted         = "account.user.deleted"
+	EventUserPasswordChanged = "account.user.password_changed"
+)
+
+// EventVersion is raised when the data of an event changes in a way consumers have to handle.
+const EventVersion = 1
+
+// AggregateUser is the aggregate type of the user events in the outbox.
+const AggregateUser = "user"
+
+// Event is the envelope every account event is published in.
+type Event struct {
+	ID         uuid.UUID `json:"id"`
+	Type       string    `json:"type"`
+	Version    int       `json:"version"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+// UserEventData is the data of the registered, updated and deleted events. PreviousEmail is
+// only set on an update that changed the address.
+type UserEventData struct {
+	UserID          uuid.UUID  `json:"user_id"`
+	Name            string     `json:"name"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	PreviousEmail   string     `json:"previous_email,omitempty"`
+	Role            string     `json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+}
+
+// PasswordChangedEventData is the data of the password changed event. It never carries the
+// password or its hash.
+type PasswordChangedEventData struct {
+	UserID uuid.UUID `json:"user_id"`
+}
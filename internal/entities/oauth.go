@@ -0,0 +1,56 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuth 2.0 grant types a client can be registered for.
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// OpenID Connect scopes. ScopeOpenID is required in every authorization request.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// ServiceScopes are the scopes an OAuth client of another service can be registered for. They
+// reuse the names of the matching user permissions.
+var ServiceScopes = []string{PermUsersRead}
+
+// OIDCScopes are the scopes applications signing users in with OpenID Connect can request.
+var OIDCScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// OAuthClient is another service that authenticates with the client credentials grant, or an
+// application that signs users in with OpenID Connect.
+type OAuthClient struct {
+	ID           uuid.UUID
+	ClientID     string
+	Name         string
+	Scopes       []string
+	RedirectURIs []string
+	GrantTypes   []string
+	// Public clients (SPAs, mobile apps) can not keep a secret and authenticate with PKCE only.
+	Public bool
+	// FirstParty clients are our own apps. They skip the consent screen and receive regular
+	// account tokens with a refresh token, other clients only get a token for /userinfo.
+	FirstParty bool
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// AllowsGrant reports whether the client is registered for the grant type.
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	for _, g := range c.GrantTypes {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
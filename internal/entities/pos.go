@@ -0,0 +1,25 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes of tokens issued by PIN login. Such tokens are only accepted by the POS services.
+const (
+	ScopePOSCheckout = "pos:checkout"
+	ScopePOSShift    = "pos:shift"
+)
+
+// POSScopes are granted to every PIN login.
+var POSScopes = []string{ScopePOSCheckout, ScopePOSShift}
+
+// POSTerminal is a shared register of a store on which cashiers sign in with their PIN.
+type POSTerminal struct {
+	ID         uuid.UUID
+	StoreID    uuid.UUID
+	Name       string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
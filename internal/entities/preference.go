@@ -0,0 +1,17 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserPreferences are the account settings a user manages. PasswordlessLogin opts in to
+// signing in with a link or code sent by email. MarketingEmails is on unless the user opted out
+// of email campaigns. UpdatedAt is nil until they were first saved.
+type UserPreferences struct {
+	UserID            uuid.UUID
+	PasswordlessLogin bool
+	MarketingEmails   bool
+	UpdatedAt         *time.Time
+}
@@ -0,0 +1,26 @@
+package entities
+
+import "github.com/google/uuid"
+
+// DefaultRole is granted on self-registration, it only gives access to the own account.
+const DefaultRole = "user"
+
+// Permissions checked by the account service.
+const (
+	PermUsersRead       = "users:read"
+	PermUsersManage     = "users:manage"
+	PermUsersDelete     = "users:delete"
+	PermRolesRead       = "roles:read"
+	PermRolesManage     = "roles:manage"
+	PermTenantsManage   = "tenants:manage"
+	PermClientsManage   = "clients:manage"
+	PermCampaignsManage = "campaigns:manage"
+	PermAuditRead       = "audit:read"
+)
+
+type Role struct {
+	ID          uuid.UUID
+	Name        string
+	Description string
+	Permissions []string
+}
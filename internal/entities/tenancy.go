@@ -0,0 +1,42 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Roles of a user within one store.
+const (
+	StoreRoleOwner   = "owner"
+	StoreRoleManager = "manager"
+	StoreRoleCashier = "cashier"
+)
+
+// Organization is a merchant, the tenant that owns one or more stores.
+type Organization struct {
+	ID        uuid.UUID
+	Name      string
+	CreatedAt time.Time
+}
+
+type Store struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+	Name           string
+	CreatedAt      time.Time
+}
+
+// StoreMembership is the role of a user in a store. Depending on the listing either the store
+// or the user side is filled.
+type StoreMembership struct {
+	StoreID          uuid.UUID
+	StoreName        string
+	OrganizationID   uuid.UUID
+	OrganizationName string
+	UserID           uuid.UUID
+	UserName         string
+	Username         string
+	Role             string
+	CreatedAt        time.Time
+}
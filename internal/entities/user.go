@@ -8,14 +8,21 @@ import (
 )
 
 type User struct {
-	ID          uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	Name        string         `json:"name"`
-	Username    string         `json:"username" gorm:"unique;not null"`
-	Email       string         `json:"email" gorm:"unique;not null"`
-	Role        string         `gorm:"type:varchar(50);default:'user'"`
-	Address     string         `json:"address"`
-	PhoneNumber string         `json:"phone_number"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Name        string    `json:"name"`
+	Username    string    `json:"username" gorm:"unique;not null"`
+	Email       string    `json:"email" gorm:"unique;not null"`
+	Role        string    `gorm:"type:varchar(50);default:'user'"`
+	Address     string    `json:"address"`
+	PhoneNumber string    `json:"phone_number"`
+	// EmailVerifiedAt is nil until the user confirmed ownership of Email.
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// EmailVerified reports whether the current email address has been confirmed.
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
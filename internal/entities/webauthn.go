@@ -0,0 +1,21 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential is a passkey or security key registered by a user. CredentialID is the ID
+// the authenticator assigned, Name the label the user gave it.
+type WebAuthnCredential struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	CredentialID   []byte
+	Name           string
+	Transports     []string
+	BackupEligible bool
+	BackupState    bool
+	CreatedAt      time.Time
+	LastUsedAt     *time.Time
+}
@@ -2,11 +2,17 @@ package grpc
 
 import (
 	"context"
+	"strconv"
 
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
 
 	accountpb "github.com/RehanAthallahAzhar/shopeezy-protos/pb/account"
@@ -35,7 +41,7 @@ func (s *AccountServer) GetUser(ctx context.Context, req *accountpb.GetUserReque
 
 	user, err := s.UserService.GetUserByID(ctx, uuid)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to get user: %v", err)
+		return nil, toStatusError(err, "get user")
 	}
 
 	return &accountpb.User{
@@ -48,12 +54,60 @@ func (s *AccountServer) GetUser(ctx context.Context, req *accountpb.GetUserReque
 	}, nil
 }
 
+// Metadata keys GetUsers reads its paging options from, GetUsersRequest only carries ids.
+const (
+	metadataLimit      = "x-limit"
+	metadataCursor     = "x-cursor"
+	metadataRole       = "x-role"
+	metadataSort       = "x-sort"
+	metadataSearch     = "x-search"
+	metadataNextCursor = "x-next-cursor"
+)
+
+// GetUsers returns the users with the requested ids. Without ids it returns one page of the
+// members of the organization in the x-organization-id metadata, the tenant ValidateToken
+// reported for the end user; the cursor of the next page is sent back in the x-next-cursor header.
 func (s *AccountServer) GetUsers(ctx context.Context, req *accountpb.GetUsersRequest) (*accountpb.GetUsersResponse, error) {
-	users, err := s.UserService.GetAllUsers(ctx)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to get users: %v", err)
+	var users []entities.User
+
+	if len(req.GetIds()) > 0 {
+		ids := make([]uuid.UUID, 0, len(req.GetIds()))
+		for _, rawID := range req.GetIds() {
+			id, err := helpers.StringToUUID(rawID)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid user ID format: %s", rawID)
+			}
+			ids = append(ids, id)
+		}
+
+		res, err := s.UserService.GetUserByIDs(ctx, ids)
+		if err != nil {
+			return nil, toStatusError(err, "get users")
+		}
+		users = res
+	} else {
+		query, err := userListQueryFromMetadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		page, err := s.UserService.ListUsers(ctx, query)
+		if err != nil {
+			return nil, toStatusError(err, "get users")
+		}
+
+		if err := grpc.SetHeader(ctx, metadata.Pairs(metadataNextCursor, page.NextCursor)); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to set next cursor: %v", err)
+		}
+		users = page.Users
 	}
 
+	return &accountpb.GetUsersResponse{
+		Users: toPBUsers(users),
+	}, nil
+}
+
+func toPBUsers(users []entities.User) []*accountpb.User {
 	pbUsers := make([]*accountpb.User, 0, len(users))
 
 	for _, user := range users {
@@ -67,8 +121,39 @@ func (s *AccountServer) GetUsers(ctx context.Context, req *accountpb.GetUsersReq
 			Role:        user.Role,
 		})
 	}
+	return pbUsers
+}
 
-	return &accountpb.GetUsersResponse{
-		Users: pbUsers,
-	}, nil
+func userListQueryFromMetadata(ctx context.Context) (*models.UserListQuery, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	get := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	query := &models.UserListQuery{
+		Cursor: get(metadataCursor),
+		Role:   get(metadataRole),
+		Sort:   get(metadataSort),
+		Search: get(metadataSearch),
+	}
+
+	// Service clients act for every tenant, so the listing is limited to the one the caller names
+	orgID, err := uuid.Parse(get(OrganizationIDHeader))
+	if err != nil {
+		return nil, status.Errorf(codes.PermissionDenied, "listing users requires the %s metadata", OrganizationIDHeader)
+	}
+	query.OrganizationID = uuid.NullUUID{UUID: orgID, Valid: true}
+
+	if rawLimit := get(metadataLimit); rawLimit != "" {
+		limit, err := strconv.Atoi(rawLimit)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid %s metadata", metadataLimit)
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
 }
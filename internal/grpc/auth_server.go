@@ -3,15 +3,51 @@ package grpc
 import (
 	"context"
 	"log"
+	"strconv"
+	"strings"
 
 	authpb "github.com/RehanAthallahAzhar/shopeezy-protos/pb/auth"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
 )
 
+// EmailVerifiedHeader is the response header of ValidateToken telling whether the user
+// confirmed their email address ("true" / "false").
+const EmailVerifiedHeader = "x-email-verified"
+
+// RolesHeader and PermissionsHeader carry the comma separated roles and permissions of the
+// token, so other services can make fine-grained decisions.
+const (
+	RolesHeader       = "x-roles"
+	PermissionsHeader = "x-permissions"
+)
+
+// StoreIDHeader, StoreRoleHeader and OrganizationIDHeader carry the active tenant of the token.
+// They are empty when the session has no active store.
+const (
+	StoreIDHeader        = "x-store-id"
+	StoreRoleHeader      = "x-store-role"
+	OrganizationIDHeader = "x-organization-id"
+)
+
+// AMRHeader carries the comma separated authentication methods of the token, ScopeHeader the
+// space separated scopes it is limited to ("pos:checkout pos:shift" for PIN logins).
+const (
+	AMRHeader   = "x-amr"
+	ScopeHeader = "x-scope"
+)
+
+// AcceptScopedHeader is request metadata of ValidateToken. Tokens with a scope are only valid
+// for callers that send "true", i.e. that read ScopeHeader and restrict the token to it.
+// ValidateTokenResponse can not carry the scope, so other callers would take such a token
+// for a full session.
+const AcceptScopedHeader = "x-accept-scoped"
+
 type AuthServer struct {
 	authpb.UnimplementedAuthServiceServer
 	TokenService token.TokenService
@@ -25,9 +61,10 @@ func NewAuthServer(tokenService token.TokenService) *AuthServer {
 // ValidateToken mengimplementasikan metode ValidateToken gRPC.
 func (s *AuthServer) ValidateToken(ctx context.Context, req *authpb.ValidateTokenRequest) (*authpb.ValidateTokenResponse, error) {
 	tokenString := req.GetToken()
-	log.Printf("%s[INFO]%s Received gRPC ValidateToken request with token: %s", helpers.ColorYellow, helpers.ColorReset, tokenString)
+	// The token is a credential, API keys stay valid for months, so it is never logged
+	log.Printf("%s[INFO]%s Received gRPC ValidateToken request", helpers.ColorYellow, helpers.ColorReset)
 
-	isValid, userID, username, role, errMsg, err := s.TokenService.ValidateToken(ctx, tokenString)
+	isValid, claims, errMsg, err := s.TokenService.ValidateToken(ctx, tokenString)
 	if err != nil {
 		log.Printf("Error validating token in TokenService: %v", err)
 		return nil, status.Errorf(codes.Internal, "Internal server error during token validation: %v", err)
@@ -40,11 +77,43 @@ func (s *AuthServer) ValidateToken(ctx context.Context, req *authpb.ValidateToke
 		}, status.Errorf(codes.Unauthenticated, "Token validation failed: %s", errMsg)
 	}
 
+	if claims.Scope != "" && !acceptsScopedTokens(ctx) {
+		return &authpb.ValidateTokenResponse{
+			IsValid:      false,
+			ErrorMessage: "Token is limited to scopes the caller does not enforce",
+		}, status.Errorf(codes.PermissionDenied, "Token is limited to the scopes %q", claims.Scope)
+	}
+
+	// ValidateTokenResponse has no fields for them, so they travel as response header metadata
+	header := metadata.Pairs(
+		EmailVerifiedHeader, strconv.FormatBool(claims.EmailVerified),
+		RolesHeader, strings.Join(claims.Roles, ","),
+		PermissionsHeader, strings.Join(claims.Permissions, ","),
+		StoreIDHeader, claims.StoreID,
+		StoreRoleHeader, claims.StoreRole,
+		OrganizationIDHeader, claims.OrganizationID,
+		AMRHeader, strings.Join(claims.AMR, ","),
+		ScopeHeader, claims.Scope,
+	)
+	if err := grpc.SetHeader(ctx, header); err != nil {
+		log.Printf("Failed to set token headers: %v", err)
+	}
+
 	return &authpb.ValidateTokenResponse{
 		IsValid:      true,
-		UserId:       userID.String(),
-		Username:     username,
-		Role:         role,
+		UserId:       claims.UserID.String(),
+		Username:     claims.Username,
+		Role:         claims.Role,
 		ErrorMessage: "",
 	}, nil
 }
+
+// acceptsScopedTokens tells whether the caller sent AcceptScopedHeader.
+func acceptsScopedTokens(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	values := md.Get(AcceptScopedHeader)
+	return len(values) > 0 && values[0] == "true"
+}
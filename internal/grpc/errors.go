@@ -0,0 +1,39 @@
+package grpc
+
+import (
+	"database/sql"
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+// toStatusError maps service errors to gRPC status codes. A conflict carries the taken field
+// in an ErrorInfo detail (metadata "field"), like the "field" of the REST 409 response.
+func toStatusError(err error, action string) error {
+	var conflictErr *apperrors.ConflictError
+	switch {
+	case errors.As(err, &conflictErr):
+		st := status.New(codes.AlreadyExists, err.Error())
+		detailed, detailErr := st.WithDetails(&errdetails.ErrorInfo{
+			Reason:   "ALREADY_EXISTS",
+			Domain:   "accounts",
+			Metadata: map[string]string{"field": conflictErr.Field},
+		})
+		if detailErr != nil {
+			return st.Err()
+		}
+		return detailed.Err()
+	case errors.Is(err, apperrors.ErrUserAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, apperrors.ErrInvalidRequestPayload):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, apperrors.ErrNotFound), errors.Is(err, sql.ErrNoRows):
+		return status.Errorf(codes.NotFound, "failed to %s: %v", action, err)
+	default:
+		return status.Errorf(codes.Internal, "failed to %s: %v", action, err)
+	}
+}
@@ -0,0 +1,125 @@
+package grpc
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/middlewares"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+
+	accountpb "github.com/RehanAthallahAzhar/shopeezy-protos/pb/account"
+)
+
+// AccountSearchService is not part of the shared protos yet, so it is described by hand with
+// well-known types: the request is the search term, the response the users of one page. Like
+// GetUsers, x-limit and x-cursor are read from the metadata and x-next-cursor is sent back.
+//
+//	rpc SearchUsers(google.protobuf.StringValue) returns (account.GetUsersResponse);
+type AccountSearchServiceServer interface {
+	SearchUsers(ctx context.Context, req *wrapperspb.StringValue) (*accountpb.GetUsersResponse, error)
+}
+
+var AccountSearchService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "account.AccountSearchService",
+	HandlerType: (*AccountSearchServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SearchUsers",
+			Handler:    _AccountSearchService_SearchUsers_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+func RegisterAccountSearchServiceServer(s grpc.ServiceRegistrar, srv AccountSearchServiceServer) {
+	s.RegisterService(&AccountSearchService_ServiceDesc, srv)
+}
+
+func _AccountSearchService_SearchUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrapperspb.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccountSearchServiceServer).SearchUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/account.AccountSearchService/SearchUsers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccountSearchServiceServer).SearchUsers(ctx, req.(*wrapperspb.StringValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+type SearchServer struct {
+	UserService services.UserService
+}
+
+// NewSearchServer creates a new SearchServer instance.
+func NewSearchServer(userService services.UserService) *SearchServer {
+	return &SearchServer{UserService: userService}
+}
+
+func (s *SearchServer) SearchUsers(ctx context.Context, req *wrapperspb.StringValue) (*accountpb.GetUsersResponse, error) {
+	scope, err := searchScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	query := &models.UserSearchQuery{Search: req.GetValue(), OrganizationID: scope}
+
+	if values := md.Get(metadataCursor); len(values) > 0 {
+		query.Cursor = values[0]
+	}
+	if values := md.Get(metadataLimit); len(values) > 0 {
+		limit, err := strconv.Atoi(values[0])
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid %s metadata", metadataLimit)
+		}
+		query.Limit = limit
+	}
+
+	page, err := s.UserService.SearchUsers(ctx, query)
+	if err != nil {
+		return nil, toStatusError(err, "search users")
+	}
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs(metadataNextCursor, page.NextCursor)); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to set next cursor: %v", err)
+	}
+
+	return &accountpb.GetUsersResponse{
+		Users: toPBUsers(page.Users),
+	}, nil
+}
+
+// searchScope limits the search to the organization of the caller's active store, like the REST
+// search. Platform administrators holding tenants:manage search every tenant.
+func searchScope(ctx context.Context) (uuid.NullUUID, error) {
+	claims, ok := middlewares.GRPCClaims(ctx)
+	if !ok {
+		return uuid.NullUUID{}, status.Error(codes.Unauthenticated, "authentication token missing")
+	}
+	if slices.Contains(claims.Permissions, entities.PermTenantsManage) {
+		return uuid.NullUUID{}, nil
+	}
+
+	orgID, err := uuid.Parse(claims.OrganizationID)
+	if err != nil {
+		return uuid.NullUUID{}, status.Error(codes.PermissionDenied, "switch to a store of your organization first")
+	}
+	return uuid.NullUUID{UUID: orgID, Valid: true}, nil
+}
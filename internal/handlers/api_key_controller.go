@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+func (h *UserHandler) CreateAPIKey(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	apiKey, secret, err := h.APIKeyService.CreateAPIKey(ctx, id, &req)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	res := toAPIKeyResponse(apiKey)
+	res.Key = secret
+	return respondSuccess(c, http.StatusCreated, MsgAPIKeyCreated, res)
+}
+
+func (h *UserHandler) ListAPIKeys(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	keys, err := h.APIKeyService.ListAPIKeys(ctx, id)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	res := make([]*models.APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		res = append(res, toAPIKeyResponse(&keys[i]))
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgAPIKeysGet, res)
+}
+
+func (h *UserHandler) GetAPIKey(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	keyID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	apiKey, err := h.APIKeyService.GetAPIKey(ctx, id, keyID)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgAPIKeysGet, toAPIKeyResponse(apiKey))
+}
+
+func (h *UserHandler) UpdateAPIKey(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	keyID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	var req models.UpdateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	apiKey, err := h.APIKeyService.RenameAPIKey(ctx, id, keyID, req.Name)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgAPIKeyUpdated, toAPIKeyResponse(apiKey))
+}
+
+func (h *UserHandler) RevokeAPIKey(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	keyID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.APIKeyService.RevokeAPIKey(ctx, id, keyID); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgAPIKeyRevoked, nil)
+}
+
+func toAPIKeyResponse(apiKey *entities.APIKey) *models.APIKeyResponse {
+	res := &models.APIKeyResponse{
+		ID:        apiKey.ID.String(),
+		Name:      apiKey.Name,
+		Prefix:    token.APIKeyPrefix + apiKey.Prefix,
+		Scopes:    apiKey.Scopes,
+		CreatedAt: apiKey.CreatedAt.Format(time.RFC3339),
+	}
+	if apiKey.ExpiresAt != nil {
+		res.ExpiresAt = apiKey.ExpiresAt.Format(time.RFC3339)
+	}
+	if apiKey.LastUsedAt != nil {
+		res.LastUsedAt = apiKey.LastUsedAt.Format(time.RFC3339)
+	}
+	return res
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+// ListAuditEvents pages through the audit log of every account, filtered by action, actor,
+// target user and time.
+func (h *UserHandler) ListAuditEvents(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var query models.AuditEventQuery
+	if err := c.Bind(&query); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	page, err := h.AuditService.ListEvents(ctx, &query)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgAuditEvents, toAuditEventListResponse(page))
+}
+
+// GetSecurityActivity shows users what happened to their own account: sign-ins, failed
+// attempts, profile and role changes and revoked sessions.
+func (h *UserHandler) GetSecurityActivity(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	var query models.AuditEventQuery
+	if err := c.Bind(&query); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	page, err := h.AuditService.ListUserActivity(ctx, id, &query)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgActivity, toAuditEventListResponse(page))
+}
+
+func toAuditEventListResponse(page *services.AuditEventPage) models.AuditEventListResponse {
+	res := models.AuditEventListResponse{
+		Events:     make([]models.AuditEventResponse, 0, len(page.Events)),
+		NextCursor: page.NextCursor,
+	}
+	for _, event := range page.Events {
+		res.Events = append(res.Events, toAuditEventResponse(event))
+	}
+
+	return res
+}
+
+func toAuditEventResponse(event entities.AuditEvent) models.AuditEventResponse {
+	return models.AuditEventResponse{
+		ID:           event.ID,
+		Action:       event.Action,
+		ActorID:      event.ActorID,
+		TargetUserID: event.TargetUserID,
+		IPAddress:    event.IPAddress,
+		UserAgent:    event.UserAgent,
+		RequestID:    event.RequestID,
+		Metadata:     event.Metadata,
+		CreatedAt:    event.CreatedAt,
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+func (h *UserHandler) CreateCampaign(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	var req models.CreateCampaignRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	campaign, err := h.CampaignService.CreateCampaign(ctx, id, &req)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusCreated, MsgCampaignNew, toCampaignResponse(campaign))
+}
+
+func (h *UserHandler) ListCampaigns(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var query models.CampaignListQuery
+	if err := c.Bind(&query); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	campaigns, err := h.CampaignService.ListCampaigns(ctx, &query)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	res := make([]*models.CampaignResponse, 0, len(campaigns))
+	for i := range campaigns {
+		res = append(res, toCampaignResponse(&campaigns[i]))
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgCampaignsGet, res)
+}
+
+func (h *UserHandler) GetCampaign(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	campaignID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	campaign, err := h.CampaignService.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgCampaignsGet, toCampaignResponse(campaign))
+}
+
+// PreviewCampaign renders the campaign as the calling admin would receive it.
+func (h *UserHandler) PreviewCampaign(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	campaignID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	preview, err := h.CampaignService.PreviewCampaign(ctx, campaignID, id)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgCampaignPrev, &models.CampaignPreviewResponse{
+		To:             preview.To,
+		Subject:        preview.Subject,
+		Body:           preview.Body,
+		RecipientCount: preview.RecipientCount,
+	})
+}
+
+func (h *UserHandler) ScheduleCampaign(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	campaignID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	var req models.ScheduleCampaignRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	campaign, err := h.CampaignService.ScheduleCampaign(ctx, campaignID, &req)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgCampaignSched, toCampaignResponse(campaign))
+}
+
+func (h *UserHandler) CancelCampaign(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	campaignID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	campaign, err := h.CampaignService.CancelCampaign(ctx, campaignID)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgCampaignCancel, toCampaignResponse(campaign))
+}
+
+func toCampaignResponse(campaign *entities.Campaign) *models.CampaignResponse {
+	res := &models.CampaignResponse{
+		ID:      campaign.ID.String(),
+		Name:    campaign.Name,
+		Subject: campaign.Subject,
+		Body:    campaign.Body,
+		Segment: models.CampaignSegmentResponse{
+			Roles:          campaign.Segment.Roles,
+			SignedUpAfter:  formatOptionalTime(campaign.Segment.SignedUpAfter),
+			SignedUpBefore: formatOptionalTime(campaign.Segment.SignedUpBefore),
+			EmailVerified:  campaign.Segment.EmailVerified,
+		},
+		Status:         campaign.Status,
+		ScheduledAt:    formatOptionalTime(campaign.ScheduledAt),
+		StartedAt:      formatOptionalTime(campaign.StartedAt),
+		DispatchedAt:   formatOptionalTime(campaign.DispatchedAt),
+		CompletedAt:    formatOptionalTime(campaign.CompletedAt),
+		CancelledAt:    formatOptionalTime(campaign.CancelledAt),
+		RecipientCount: campaign.RecipientCount,
+		CreatedAt:      campaign.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      campaign.UpdatedAt.Format(time.RFC3339),
+		Deliveries:     campaign.Deliveries,
+	}
+	if campaign.CreatedBy != nil {
+		res.CreatedBy = campaign.CreatedBy.String()
+	}
+	return res
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
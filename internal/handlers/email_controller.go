@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+func (h *UserHandler) VerifyEmail(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.VerifyEmailRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	if err := h.EmailVerificationService.VerifyEmail(ctx, &req); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgEmailVerified, nil)
+}
+
+// ResendVerification is public so users blocked by EMAIL_VERIFICATION_MODE=deny can use it, and
+// always answers 202 for a well-formed request.
+func (h *UserHandler) ResendVerification(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.ResendVerificationRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	if err := h.EmailVerificationService.ResendVerification(ctx, &req); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusAccepted, MsgEmailResent, nil)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+func (h *UserHandler) ListIdentityProviders(c echo.Context) error {
+	names := h.FederationService.Providers()
+
+	res := make([]models.IdentityProviderResponse, 0, len(names))
+	for _, name := range names {
+		res = append(res, models.IdentityProviderResponse{Name: name})
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgProvidersGet, res)
+}
+
+// BeginFederatedLogin returns the provider URL the frontend sends the browser to. The provider
+// redirects back to the configured callback page, which posts code and state to
+// FederatedLoginCallback.
+func (h *UserHandler) BeginFederatedLogin(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	provider, err := helpers.GetFromPathParam(c, "provider")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	authorization, err := h.FederationService.BeginLogin(ctx, provider)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgProviderRedir, toFederatedAuthorizationResponse(authorization))
+}
+
+// FederatedLoginCallback finishes a federated login. It answers like Login, including the MFA
+// challenge for users with a second factor.
+func (h *UserHandler) FederatedLoginCallback(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.FederatedCallbackRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	login, err := h.FederationService.CompleteLogin(ctx, &req)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	if login.Created && !login.User.EmailVerified() {
+		if err := h.EmailVerificationService.SendVerification(ctx, login.User); err != nil {
+			h.log.WithError(err).Error("Failed to send verification email")
+		}
+	}
+
+	return h.completeFirstFactor(c, login.User, MsgFederatedMFA, entities.AMRFederated)
+}
+
+func (h *UserHandler) ListIdentities(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	identities, err := h.FederationService.ListIdentities(ctx, id)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	res := make([]*models.UserIdentityResponse, 0, len(identities))
+	for i := range identities {
+		res = append(res, toUserIdentityResponse(&identities[i]))
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgIdentitiesGet, res)
+}
+
+// BeginLinkIdentity starts linking a provider to the signed in user. The callback page posts
+// code and state to LinkIdentityCallback.
+func (h *UserHandler) BeginLinkIdentity(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	provider, err := helpers.GetFromPathParam(c, "provider")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	authorization, err := h.FederationService.BeginLink(ctx, id, provider)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgProviderRedir, toFederatedAuthorizationResponse(authorization))
+}
+
+func (h *UserHandler) LinkIdentityCallback(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	var req models.FederatedCallbackRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	linked, err := h.FederationService.CompleteLink(ctx, id, &req)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusCreated, MsgIdentityLinked, toUserIdentityResponse(linked))
+}
+
+func (h *UserHandler) UnlinkIdentity(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	id, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.FederationService.UnlinkIdentity(ctx, userID, id); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgIdentityDel, nil)
+}
+
+func toFederatedAuthorizationResponse(authorization *services.FederatedAuthorization) models.FederatedAuthorizationResponse {
+	return models.FederatedAuthorizationResponse{
+		AuthorizationURL: authorization.URL,
+		State:            authorization.State,
+	}
+}
+
+func toUserIdentityResponse(identity *entities.UserIdentity) *models.UserIdentityResponse {
+	res := &models.UserIdentityResponse{
+		ID:        identity.ID.String(),
+		Provider:  identity.Provider,
+		Email:     identity.Email,
+		CreatedAt: identity.CreatedAt.Format(time.RFC3339),
+	}
+	if identity.LastLoginAt != nil {
+		res.LastLoginAt = identity.LastLoginAt.Format(time.RFC3339)
+	}
+	return res
+}
@@ -3,7 +3,9 @@ package handlers
 import (
 	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -12,8 +14,12 @@ import (
 	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
 
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
 )
 
+// maxDeviceLength caps the user agent stored on a session.
+const maxDeviceLength = 255
+
 // ------- HELPERS -------
 
 const (
@@ -24,6 +30,64 @@ const (
 	MsgUsersRetrieved = "Users retrieved successfully"
 	MsgLogin          = "Login successful"
 	MsgLogout         = "Logout successful"
+	MsgTokenRefreshed = "Token refreshed successfully"
+	MsgSessionsList   = "Sessions retrieved successfully"
+	MsgSessionRevoked = "Session revoked successfully"
+	MsgSessionsRevoke = "All sessions revoked successfully"
+	MsgMFARequired    = "Password accepted, second factor required"
+	MsgMFAEnrollment  = "Scan the secret with your authenticator app and confirm with a code"
+	MsgMFAEnabled     = "Two-factor authentication enabled, store the recovery codes safely"
+	MsgMFAReset       = "Two-factor authentication reset successfully"
+	MsgPasswordForgot = "If the email is registered, a password reset link has been sent"
+	MsgPasswordReset  = "Password reset successfully, please log in again"
+	MsgEmailVerified  = "Email verified successfully"
+	MsgUserUnlocked   = "User unlocked successfully"
+	MsgRolesRetrieved = "Roles retrieved successfully"
+	MsgRoleGranted    = "Role granted successfully"
+	MsgRoleRevoked    = "Role revoked successfully, the user has to log in again"
+	MsgEmailResent    = "If the email is registered and not verified yet, a new verification link has been sent"
+	MsgOrgCreated     = "Organization created successfully"
+	MsgStoreCreated   = "Store created successfully"
+	MsgMembershipsGet = "Store memberships retrieved successfully"
+	MsgMembershipSet  = "Store membership saved successfully"
+	MsgMembershipDel  = "Store membership removed successfully"
+	MsgStoreSwitched  = "Active store switched successfully"
+	MsgPINSet         = "PIN saved successfully"
+	MsgPINRemoved     = "PIN removed successfully"
+	MsgTerminalAdded  = "Terminal registered successfully"
+	MsgTerminalsGet   = "Terminals retrieved successfully"
+	MsgTerminalDel    = "Terminal revoked successfully"
+	MsgAPIKeyCreated  = "API key created, store it safely as it will not be shown again"
+	MsgAPIKeysGet     = "API keys retrieved successfully"
+	MsgAPIKeyUpdated  = "API key updated successfully"
+	MsgAPIKeyRevoked  = "API key revoked successfully"
+	MsgClientCreated  = "OAuth client registered, store the secret safely as it will not be shown again"
+	MsgClientsGet     = "OAuth clients retrieved successfully"
+	MsgClientUpdated  = "OAuth client updated successfully"
+	MsgClientRevoked  = "OAuth client revoked successfully"
+	MsgProvidersGet   = "Identity providers retrieved successfully"
+	MsgProviderRedir  = "Continue the sign-in at the identity provider"
+	MsgFederatedMFA   = "Sign-in accepted, second factor required"
+	MsgIdentitiesGet  = "Linked identities retrieved successfully"
+	MsgIdentityLinked = "Identity linked successfully"
+	MsgIdentityDel    = "Identity unlinked successfully"
+	MsgMagicLoginSent = "If the email belongs to an account with passwordless login, a sign-in link or code has been sent"
+	MsgMagicLoginMFA  = "Sign-in code accepted, second factor required"
+	MsgPreferencesGet = "Preferences retrieved successfully"
+	MsgPreferencesSet = "Preferences updated successfully"
+	MsgPasskeyOptions = "Pass the options to the browser's WebAuthn API"
+	MsgPasskeyMFA     = "Passkey accepted, second factor required"
+	MsgPasskeysGet    = "Passkeys retrieved successfully"
+	MsgPasskeyAdded   = "Passkey registered successfully"
+	MsgPasskeyDeleted = "Passkey removed successfully"
+	MsgUnsubscribed   = "You will no longer receive marketing emails"
+	MsgCampaignNew    = "Campaign created successfully"
+	MsgCampaignsGet   = "Campaigns retrieved successfully"
+	MsgCampaignPrev   = "Campaign preview rendered successfully"
+	MsgCampaignSched  = "Campaign scheduled successfully"
+	MsgCampaignCancel = "Campaign cancelled successfully"
+	MsgAuditEvents    = "Audit events retrieved successfully"
+	MsgActivity       = "Security activity retrieved successfully"
 )
 
 func extractUserID(c echo.Context) (uuid.UUID, error) {
@@ -42,6 +106,19 @@ func extractUserID(c echo.Context) (uuid.UUID, error) {
 	return uuid.Nil, errors.New("invalid user session: userID in context is not of type uuid.UUID")
 }
 
+// issueOptions describes the client a token pair is issued to, for the session registry.
+func issueOptions(c echo.Context) token.IssueOptions {
+	device := c.Request().UserAgent()
+	if len(device) > maxDeviceLength {
+		device = device[:maxDeviceLength]
+	}
+
+	return token.IssueOptions{
+		Device:    device,
+		IPAddress: c.RealIP(),
+	}
+}
+
 func respondSuccess(c echo.Context, status int, message string, data interface{}) error {
 	return c.JSON(status, models.SuccessResponse{
 		Message: message,
@@ -56,10 +133,25 @@ func respondError(c echo.Context, status int, err error) error {
 }
 
 func (h *UserHandler) handleServiceError(c echo.Context, err error) error {
+	var retryErr *apperrors.RetryAfterError
+	if errors.As(err, &retryErr) && retryErr.RetryAfter > 0 {
+		seconds := int64(math.Ceil(retryErr.RetryAfter.Seconds()))
+		c.Response().Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+
 	// validation error
 	if errors.Is(err, apperrors.ErrInvalidRequestPayload) {
 		return respondError(c, http.StatusBadRequest, err)
 	}
+	if errors.Is(err, apperrors.ErrInvalidResetToken) {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+	if errors.Is(err, apperrors.ErrPasskeyRegistration) {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+	if errors.Is(err, apperrors.ErrAPIKeyLogout) {
+		return respondError(c, http.StatusBadRequest, err)
+	}
 
 	// Authentication & Authorization Errors (401 & 403)
 	if errors.Is(err, apperrors.ErrInvalidCredentials) {
@@ -68,19 +160,68 @@ func (h *UserHandler) handleServiceError(c echo.Context, err error) error {
 	if errors.Is(err, apperrors.ErrInvalidToken) {
 		return respondError(c, http.StatusUnauthorized, err)
 	}
+	if errors.Is(err, apperrors.ErrExpiredToken) {
+		return respondError(c, http.StatusUnauthorized, err)
+	}
+	if errors.Is(err, apperrors.ErrRefreshTokenReused) {
+		return respondError(c, http.StatusUnauthorized, err)
+	}
+	if errors.Is(err, apperrors.ErrInvalidMFACode) {
+		return respondError(c, http.StatusUnauthorized, err)
+	}
+	if errors.Is(err, apperrors.ErrTooManyAttempts) {
+		return respondError(c, http.StatusTooManyRequests, err)
+	}
+	if errors.Is(err, apperrors.ErrAccountLocked) || errors.Is(err, apperrors.ErrPINLocked) {
+		return respondError(c, http.StatusLocked, err)
+	}
+	if errors.Is(err, apperrors.ErrInvalidTerminal) {
+		return respondError(c, http.StatusUnauthorized, err)
+	}
+	if errors.Is(err, apperrors.ErrIdentityProvider) || errors.Is(err, apperrors.ErrInvalidLoginState) || errors.Is(err, apperrors.ErrInvalidLoginCode) {
+		return respondError(c, http.StatusUnauthorized, err)
+	}
+	if errors.Is(err, apperrors.ErrInvalidPasskey) {
+		return respondError(c, http.StatusUnauthorized, err)
+	}
 	if errors.Is(err, apperrors.ErrForbidden) {
 		return respondError(c, http.StatusForbidden, err)
 	}
+	if errors.Is(err, apperrors.ErrEmailNotVerified) {
+		return respondError(c, http.StatusForbidden, err)
+	}
+	if errors.Is(err, apperrors.ErrNotStoreMember) {
+		return respondError(c, http.StatusForbidden, err)
+	}
 
 	// not found
-	if errors.Is(err, apperrors.ErrNotFound) {
+	if errors.Is(err, apperrors.ErrNotFound) || errors.Is(err, apperrors.ErrPINNotSet) || errors.Is(err, apperrors.ErrUnknownProvider) {
 		return respondError(c, http.StatusNotFound, err)
 	}
 
 	// Data Conflict
+	var conflictErr *apperrors.ConflictError
+	if errors.As(err, &conflictErr) {
+		return c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: err.Error(),
+			Field: conflictErr.Field,
+		})
+	}
 	if errors.Is(err, apperrors.ErrUserAlreadyExists) {
 		return respondError(c, http.StatusConflict, err)
 	}
+	if errors.Is(err, apperrors.ErrMFAAlreadyEnabled) || errors.Is(err, apperrors.ErrMFANotEnrolled) {
+		return respondError(c, http.StatusConflict, err)
+	}
+	if errors.Is(err, apperrors.ErrIdentityAlreadyLinked) || errors.Is(err, apperrors.ErrFederatedEmailTaken) || errors.Is(err, apperrors.ErrLastSignInMethod) {
+		return respondError(c, http.StatusConflict, err)
+	}
+	if errors.Is(err, apperrors.ErrPasskeyExists) {
+		return respondError(c, http.StatusConflict, err)
+	}
+	if errors.Is(err, apperrors.ErrCampaignNotEditable) {
+		return respondError(c, http.StatusConflict, err)
+	}
 
 	// Out of Stock Product
 	if errors.Is(err, apperrors.ErrProductOutOfStock) {
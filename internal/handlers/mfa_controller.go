@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+func (h *UserHandler) EnrollTOTP(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	enrollment, err := h.MFAService.BeginEnrollment(ctx, id)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgMFAEnrollment, models.MFAEnrollmentResponse{
+		Secret:     enrollment.Secret,
+		OTPAuthURI: enrollment.OTPAuthURI,
+	})
+}
+
+func (h *UserHandler) ConfirmTOTP(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	var req models.MFAConfirmRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	codes, err := h.MFAService.ConfirmEnrollment(ctx, id, req.Code)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgMFAEnabled, models.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// VerifyMFALogin exchanges the mfa_pending token plus a code or passkey assertion for the real
+// token pair.
+func (h *UserHandler) VerifyMFALogin(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.MFAVerifyRequest
+	if err := c.Bind(&req); err != nil || req.MFAToken == "" {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	userSvc, amr, err := h.MFAService.VerifyLogin(ctx, &req)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return h.completeLogin(c, userSvc, amr...)
+}
+
+// BeginPasskeyMFA returns the passkey options for answering the challenge of an mfa_pending token.
+func (h *UserHandler) BeginPasskeyMFA(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.PasskeyMFAOptionsRequest
+	if err := c.Bind(&req); err != nil || req.MFAToken == "" {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	ceremony, err := h.MFAService.BeginPasskeyChallenge(ctx, req.MFAToken)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgPasskeyOptions, toWebAuthnOptionsResponse(ceremony))
+}
+
+func (h *UserHandler) ResetMFA(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.checkTenantUser(c, id); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	if err := h.MFAService.ResetMFA(ctx, id); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgMFAReset, nil)
+}
+
+func toMFAChallengeResponse(mfaToken string, expiresAt time.Time) models.MFAChallengeResponse {
+	return models.MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    mfaToken,
+		ExpiresAt:   expiresAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+// IssueOAuthToken is the RFC 6749 token endpoint for the client credentials, authorization code
+// and refresh token grants. Clients authenticate with HTTP Basic auth or client_id/client_secret
+// form parameters, public clients only send their client_id. Responses use the OAuth format
+// instead of the usual envelope so standard clients understand them.
+func (h *UserHandler) IssueOAuthToken(c echo.Context) error {
+	ctx := c.Request().Context()
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+
+	clientID, clientSecret, basic := c.Request().BasicAuth()
+	if !basic {
+		clientID, clientSecret = c.FormValue("client_id"), c.FormValue("client_secret")
+	}
+
+	var (
+		res *models.OAuthTokenResponse
+		err error
+	)
+	switch grantType := c.FormValue("grant_type"); grantType {
+	case entities.GrantClientCredentials:
+		var (
+			accessToken string
+			scopes      []string
+			expiresAt   time.Time
+		)
+		accessToken, scopes, expiresAt, err = h.OAuthService.IssueClientCredentialsToken(ctx, clientID, clientSecret, c.FormValue("scope"))
+		if err == nil {
+			res = &models.OAuthTokenResponse{
+				AccessToken: accessToken,
+				ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+				Scope:       strings.Join(scopes, " "),
+			}
+		}
+	case entities.GrantAuthorizationCode:
+		var tokens *services.OIDCTokens
+		tokens, err = h.OIDCService.ExchangeAuthorizationCode(ctx, clientID, clientSecret,
+			c.FormValue("code"), c.FormValue("redirect_uri"), c.FormValue("code_verifier"), issueOptions(c))
+		if err == nil {
+			res = toOAuthTokenResponse(tokens)
+		}
+	case entities.GrantRefreshToken:
+		var tokens *services.OIDCTokens
+		tokens, err = h.OIDCService.RefreshTokens(ctx, clientID, clientSecret, c.FormValue("refresh_token"), issueOptions(c))
+		if err == nil {
+			res = toOAuthTokenResponse(tokens)
+		}
+	case "":
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "grant_type is required")
+	default:
+		return oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+
+	if err != nil {
+		status, code := oauthErrorCode(err)
+		switch code {
+		case "invalid_client":
+			if basic {
+				c.Response().Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+			}
+			return oauthError(c, status, code, "")
+		case "server_error":
+			h.log.WithError(err).Error("Failed to issue oauth token")
+			return oauthError(c, status, code, "")
+		default:
+			return oauthError(c, status, code, err.Error())
+		}
+	}
+
+	res.TokenType = "Bearer"
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) CreateOAuthClient(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	var req models.CreateOAuthClientRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	client, secret, err := h.OAuthService.RegisterClient(ctx, id, &req)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	res := toOAuthClientResponse(client)
+	res.ClientSecret = secret
+	return respondSuccess(c, http.StatusCreated, MsgClientCreated, res)
+}
+
+func (h *UserHandler) ListOAuthClients(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	clients, err := h.OAuthService.ListClients(ctx)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	res := make([]*models.OAuthClientResponse, 0, len(clients))
+	for i := range clients {
+		res = append(res, toOAuthClientResponse(&clients[i]))
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgClientsGet, res)
+}
+
+func (h *UserHandler) GetOAuthClient(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	clientID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	client, err := h.OAuthService.GetClient(ctx, clientID)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgClientsGet, toOAuthClientResponse(client))
+}
+
+func (h *UserHandler) UpdateOAuthClient(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	clientID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	var req models.UpdateOAuthClientRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	client, err := h.OAuthService.UpdateClient(ctx, clientID, &req)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgClientUpdated, toOAuthClientResponse(client))
+}
+
+func (h *UserHandler) RevokeOAuthClient(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	clientID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.OAuthService.RevokeClient(ctx, clientID); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgClientRevoked, nil)
+}
+
+// oauthErrorCode maps service errors to the RFC 6749 error codes.
+func oauthErrorCode(err error) (int, string) {
+	switch {
+	case errors.Is(err, apperrors.ErrInvalidClient):
+		return http.StatusUnauthorized, "invalid_client"
+	case errors.Is(err, apperrors.ErrInvalidGrant), errors.Is(err, apperrors.ErrInvalidToken),
+		errors.Is(err, apperrors.ErrExpiredToken), errors.Is(err, apperrors.ErrRefreshTokenReused):
+		return http.StatusBadRequest, "invalid_grant"
+	case errors.Is(err, apperrors.ErrInvalidScope):
+		return http.StatusBadRequest, "invalid_scope"
+	case errors.Is(err, apperrors.ErrUnauthorizedClient):
+		return http.StatusBadRequest, "unauthorized_client"
+	case errors.Is(err, apperrors.ErrUnsupportedResponseType):
+		return http.StatusBadRequest, "unsupported_response_type"
+	case errors.Is(err, apperrors.ErrInvalidRequestPayload):
+		return http.StatusBadRequest, "invalid_request"
+	default:
+		return http.StatusInternalServerError, "server_error"
+	}
+}
+
+func oauthError(c echo.Context, status int, code string, description string) error {
+	return c.JSON(status, models.OAuthErrorResponse{Error: code, ErrorDescription: description})
+}
+
+func toOAuthClientResponse(client *entities.OAuthClient) *models.OAuthClientResponse {
+	res := &models.OAuthClientResponse{
+		ID:           client.ID.String(),
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		Scopes:       client.Scopes,
+		GrantTypes:   client.GrantTypes,
+		RedirectURIs: client.RedirectURIs,
+		Public:       client.Public,
+		FirstParty:   client.FirstParty,
+		CreatedAt:    client.CreatedAt.Format(time.RFC3339),
+	}
+	if client.LastUsedAt != nil {
+		res.LastUsedAt = client.LastUsedAt.Format(time.RFC3339)
+	}
+	return res
+}
+
+func toOAuthTokenResponse(tokens *services.OIDCTokens) *models.OAuthTokenResponse {
+	return &models.OAuthTokenResponse{
+		AccessToken:  tokens.AccessToken,
+		ExpiresIn:    int64(time.Until(tokens.ExpiresAt).Seconds()),
+		Scope:        strings.Join(tokens.Scopes, " "),
+		RefreshToken: tokens.RefreshToken,
+		IDToken:      tokens.IDToken,
+	}
+}
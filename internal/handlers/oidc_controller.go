@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+// Templates of the authorization pages, see template/oidc.
+const (
+	templateAuthorize = "authorize"
+	templateMFA       = "authorize_mfa"
+	templateError     = "authorize_error"
+)
+
+// scopeDescriptions are shown on the consent screen of third-party clients.
+var scopeDescriptions = map[string]string{
+	entities.ScopeOpenID:  "Sign you in with your Shopeezy account",
+	entities.ScopeProfile: "See your name and username",
+	entities.ScopeEmail:   "See your email address and whether it is verified",
+}
+
+// authorizePage is the data of the login, MFA and error pages.
+type authorizePage struct {
+	Request    *models.AuthorizationRequest
+	ClientName string
+	FirstParty bool
+	Scopes     []string
+	CSRFToken  string
+	Username   string
+	MFAToken   string
+	Error      string
+}
+
+// GetOpenIDConfiguration serves the OpenID Provider metadata (OpenID Connect Discovery 1.0).
+func (h *UserHandler) GetOpenIDConfiguration(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, h.OIDCService.Discovery())
+}
+
+// Authorize is the authorization endpoint. It validates the request and shows the login page,
+// there is no single sign-on session so users always enter their credentials.
+func (h *UserHandler) Authorize(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.AuthorizationRequest
+	if err := c.Bind(&req); err != nil {
+		return h.renderAuthorizeError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	grant, err := h.OIDCService.ValidateAuthorizationRequest(ctx, &req)
+	if err != nil {
+		return h.authorizationFailed(c, grant, err)
+	}
+
+	for _, prompt := range strings.Fields(req.Prompt) {
+		if prompt == "none" {
+			return c.Redirect(http.StatusFound, h.OIDCService.AuthorizationErrorRedirect(grant, "login_required", ""))
+		}
+	}
+
+	return h.renderAuthorize(c, http.StatusOK, templateAuthorize, newAuthorizePage(c, grant))
+}
+
+// SubmitAuthorize handles the login form. Denying the consent is reported to the client as access_denied.
+func (h *UserHandler) SubmitAuthorize(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.AuthorizationRequest
+	if err := c.Bind(&req); err != nil {
+		return h.renderAuthorizeError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	grant, err := h.OIDCService.ValidateAuthorizationRequest(ctx, &req)
+	if err != nil {
+		return h.authorizationFailed(c, grant, err)
+	}
+
+	if c.FormValue("action") == "deny" {
+		return c.Redirect(http.StatusSeeOther, h.OIDCService.AuthorizationErrorRedirect(grant, "access_denied", ""))
+	}
+
+	page := newAuthorizePage(c, grant)
+	page.Username = c.FormValue("username")
+
+	user, err := h.UserService.Login(ctx, &models.UserLoginRequest{
+		Username:  page.Username,
+		Password:  c.FormValue("password"),
+		IPAddress: c.RealIP(),
+	})
+	if err != nil {
+		return h.authorizeLoginFailed(c, templateAuthorize, page, err)
+	}
+
+	mfaEnabled, err := h.MFAService.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return h.authorizeLoginFailed(c, templateAuthorize, page, err)
+	}
+	if mfaEnabled {
+		page.MFAToken, _, err = h.MFAService.IssueChallenge(ctx, user.ID, []string{entities.AMRPassword})
+		if err != nil {
+			return h.authorizeLoginFailed(c, templateAuthorize, page, err)
+		}
+		return h.renderAuthorize(c, http.StatusOK, templateMFA, page)
+	}
+
+	return h.completeAuthorization(c, grant, user, entities.AMRPassword)
+}
+
+// SubmitAuthorizeMFA handles the second factor form of the login page.
+func (h *UserHandler) SubmitAuthorizeMFA(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.AuthorizationRequest
+	if err := c.Bind(&req); err != nil {
+		return h.renderAuthorizeError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	grant, err := h.OIDCService.ValidateAuthorizationRequest(ctx, &req)
+	if err != nil {
+		return h.authorizationFailed(c, grant, err)
+	}
+
+	page := newAuthorizePage(c, grant)
+	page.MFAToken = c.FormValue("mfa_token")
+
+	user, amr, err := h.MFAService.VerifyLogin(ctx, &models.MFAVerifyRequest{
+		MFAToken:     page.MFAToken,
+		Code:         c.FormValue("code"),
+		RecoveryCode: c.FormValue("recovery_code"),
+	})
+	if err != nil {
+		return h.authorizeLoginFailed(c, templateMFA, page, err)
+	}
+
+	return h.completeAuthorization(c, grant, user, amr...)
+}
+
+// UserInfo is the OpenID Connect UserInfo endpoint. It accepts the access tokens issued by the
+// token endpoint, including the scoped tokens of third-party clients.
+func (h *UserHandler) UserInfo(c echo.Context) error {
+	ctx := c.Request().Context()
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	authHeader := c.Request().Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader || tokenString == "" {
+		c.Response().Header().Set("WWW-Authenticate", `Bearer realm="userinfo"`)
+		return oauthError(c, http.StatusUnauthorized, "invalid_token", "")
+	}
+
+	isValid, claims, errMsg, err := h.TokenService.ValidateUserInfoToken(ctx, tokenString)
+	if err != nil || !isValid {
+		c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		return oauthError(c, http.StatusUnauthorized, "invalid_token", errMsg)
+	}
+
+	res, err := h.OIDCService.UserInfo(ctx, claims)
+	if err != nil {
+		switch {
+		case errors.Is(err, apperrors.ErrForbidden):
+			c.Response().Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+			return oauthError(c, http.StatusForbidden, "insufficient_scope", "")
+		case errors.Is(err, apperrors.ErrInvalidToken), errors.Is(err, apperrors.ErrNotFound):
+			c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			return oauthError(c, http.StatusUnauthorized, "invalid_token", "")
+		default:
+			h.log.WithError(err).Error("Failed to build userinfo")
+			return oauthError(c, http.StatusInternalServerError, "server_error", "")
+		}
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) completeAuthorization(c echo.Context, grant *services.AuthorizationGrant, user *entities.User, amr ...string) error {
+	redirect, err := h.OIDCService.IssueAuthorizationCode(c.Request().Context(), grant, user, amr)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to issue authorization code")
+		return c.Redirect(http.StatusSeeOther, h.OIDCService.AuthorizationErrorRedirect(grant, "server_error", ""))
+	}
+	return c.Redirect(http.StatusSeeOther, redirect)
+}
+
+// authorizationFailed shows invalid client and redirect URI errors to the user, every other
+// error is sent back to the client.
+func (h *UserHandler) authorizationFailed(c echo.Context, grant *services.AuthorizationGrant, err error) error {
+	if grant == nil {
+		if errors.Is(err, apperrors.ErrInvalidClient) || errors.Is(err, apperrors.ErrInvalidRedirectURI) {
+			return h.renderAuthorizeError(c, http.StatusBadRequest, err)
+		}
+		h.log.WithError(err).Error("Failed to validate authorization request")
+		return h.renderAuthorizeError(c, http.StatusInternalServerError, apperrors.ErrInternalServerError)
+	}
+
+	_, code := oauthErrorCode(err)
+	description := ""
+	if code != "server_error" {
+		description = err.Error()
+	}
+	return c.Redirect(http.StatusFound, h.OIDCService.AuthorizationErrorRedirect(grant, code, description))
+}
+
+// authorizeLoginFailed shows the page again with the reason the sign-in was rejected.
+func (h *UserHandler) authorizeLoginFailed(c echo.Context, name string, page *authorizePage, err error) error {
+	var retryErr *apperrors.RetryAfterError
+	if errors.As(err, &retryErr) && retryErr.RetryAfter > 0 {
+		seconds := int64(math.Ceil(retryErr.RetryAfter.Seconds()))
+		c.Response().Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+
+	status := http.StatusUnauthorized
+	switch {
+	case errors.Is(err, apperrors.ErrInvalidCredentials), errors.Is(err, apperrors.ErrInvalidMFACode):
+	case errors.Is(err, apperrors.ErrInvalidToken), errors.Is(err, apperrors.ErrInvalidRequestPayload):
+		status = http.StatusBadRequest
+	case errors.Is(err, apperrors.ErrAccountLocked):
+		status = http.StatusLocked
+	case errors.Is(err, apperrors.ErrTooManyAttempts):
+		status = http.StatusTooManyRequests
+	case errors.Is(err, apperrors.ErrEmailNotVerified):
+		status = http.StatusForbidden
+	default:
+		h.log.WithError(err).Error("Sign-in on the authorization page failed")
+		status = http.StatusInternalServerError
+		err = apperrors.ErrInternalServerError
+	}
+
+	page.Error = err.Error()
+	return h.renderAuthorize(c, status, name, page)
+}
+
+func (h *UserHandler) renderAuthorizeError(c echo.Context, status int, err error) error {
+	return h.renderAuthorize(c, status, templateError, &authorizePage{Error: err.Error()})
+}
+
+// renderAuthorize renders an authorization page. The pages take credentials, so they must
+// never be framed or cached.
+func (h *UserHandler) renderAuthorize(c echo.Context, status int, name string, page *authorizePage) error {
+	header := c.Response().Header()
+	header.Set("Cache-Control", "no-store")
+	header.Set("X-Frame-Options", "DENY")
+	header.Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+	return c.Render(status, name, page)
+}
+
+func newAuthorizePage(c echo.Context, grant *services.AuthorizationGrant) *authorizePage {
+	page := &authorizePage{
+		Request:    grant.Request,
+		ClientName: grant.Client.Name,
+		FirstParty: grant.Client.FirstParty,
+	}
+	if csrf, ok := c.Get("csrf").(string); ok {
+		page.CSRFToken = csrf
+	}
+	for _, scope := range grant.Scopes {
+		if description, ok := scopeDescriptions[scope]; ok {
+			page.Scopes = append(page.Scopes, description)
+		}
+	}
+	return page
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+// BeginPasskeyLogin returns the options for navigator.credentials.get(). The user picks one of
+// the passkeys their device holds for us, no username is sent.
+func (h *UserHandler) BeginPasskeyLogin(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	ceremony, err := h.WebAuthnService.BeginLogin(ctx)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgPasskeyOptions, toWebAuthnOptionsResponse(ceremony))
+}
+
+// PasskeyLogin signs in with a passkey assertion. A passkey that verified the user (PIN or
+// biometric) counts as two factors, otherwise users with MFA still get the MFA challenge.
+func (h *UserHandler) PasskeyLogin(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.PasskeyLoginRequest
+	if err := c.Bind(&req); err != nil || req.SessionID == "" || len(req.Credential) == 0 {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	login, err := h.WebAuthnService.FinishLogin(ctx, &req)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	if login.UserVerified {
+		return h.completeLogin(c, login.User, entities.AMRPasskey, entities.AMRMFA)
+	}
+	return h.completeFirstFactor(c, login.User, MsgPasskeyMFA, entities.AMRPasskey)
+}
+
+func (h *UserHandler) ListPasskeys(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	credentials, err := h.WebAuthnService.ListCredentials(ctx, id)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	res := make([]*models.PasskeyResponse, 0, len(credentials))
+	for i := range credentials {
+		res = append(res, toPasskeyResponse(&credentials[i]))
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgPasskeysGet, res)
+}
+
+// BeginPasskeyRegistration returns the options for navigator.credentials.create(). The result is
+// posted to RegisterPasskey.
+func (h *UserHandler) BeginPasskeyRegistration(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	ceremony, err := h.WebAuthnService.BeginRegistration(ctx, id)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgPasskeyOptions, toWebAuthnOptionsResponse(ceremony))
+}
+
+func (h *UserHandler) RegisterPasskey(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	var req models.RegisterPasskeyRequest
+	if err := c.Bind(&req); err != nil || req.SessionID == "" || len(req.Credential) == 0 {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	credential, err := h.WebAuthnService.FinishRegistration(ctx, id, &req)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusCreated, MsgPasskeyAdded, toPasskeyResponse(credential))
+}
+
+func (h *UserHandler) DeletePasskey(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	id, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.WebAuthnService.DeleteCredential(ctx, userID, id); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgPasskeyDeleted, nil)
+}
+
+func toWebAuthnOptionsResponse(ceremony *services.WebAuthnCeremony) models.WebAuthnOptionsResponse {
+	return models.WebAuthnOptionsResponse{
+		SessionID: ceremony.SessionID,
+		Options:   ceremony.Options,
+	}
+}
+
+func toPasskeyResponse(credential *entities.WebAuthnCredential) *models.PasskeyResponse {
+	res := &models.PasskeyResponse{
+		ID:         credential.ID.String(),
+		Name:       credential.Name,
+		Transports: credential.Transports,
+		Synced:     credential.BackupState,
+		CreatedAt:  credential.CreatedAt.Format(time.RFC3339),
+	}
+	if res.Transports == nil {
+		res.Transports = []string{}
+	}
+	if credential.LastUsedAt != nil {
+		res.LastUsedAt = credential.LastUsedAt.Format(time.RFC3339)
+	}
+	return res
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+// ForgotPassword always answers 202 for a well-formed request, whether or not the email exists.
+func (h *UserHandler) ForgotPassword(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.ForgotPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	if err := h.PasswordService.RequestPasswordReset(ctx, &req); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusAccepted, MsgPasswordForgot, nil)
+}
+
+func (h *UserHandler) ResetPassword(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	if err := h.PasswordService.ResetPassword(ctx, &req); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgPasswordReset, nil)
+}
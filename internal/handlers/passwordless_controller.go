@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+// RequestMagicLogin always answers the same way, whether or not a mail was sent.
+func (h *UserHandler) RequestMagicLogin(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.MagicLoginRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	if err := h.PasswordlessService.RequestLogin(ctx, &req); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusAccepted, MsgMagicLoginSent, nil)
+}
+
+// VerifyMagicLogin exchanges a sign-in link token or code for the token pair, or the MFA
+// challenge for users with a second factor.
+func (h *UserHandler) VerifyMagicLogin(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.MagicLoginVerifyRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	userSvc, err := h.PasswordlessService.VerifyLogin(ctx, &req)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return h.completeFirstFactor(c, userSvc, MsgMagicLoginMFA, entities.AMROTP)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+// PINLogin signs a cashier in on a registered POS terminal.
+func (h *UserHandler) PINLogin(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.PINLoginRequest
+	if err := c.Bind(&req); err != nil || req.Username == "" || req.PIN == "" {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+	terminalID, err := uuid.Parse(strings.TrimSpace(req.TerminalID))
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	accessToken, expiresAt, err := h.POSService.PINLogin(ctx, &services.PINLoginRequest{
+		TerminalID:     terminalID,
+		TerminalSecret: req.TerminalSecret,
+		Username:       req.Username,
+		PIN:            req.PIN,
+	}, issueOptions(c))
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgLogin, models.PINLoginResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+		Scope:       strings.Join(entities.POSScopes, " "),
+	})
+}
+
+func (h *UserHandler) SetPIN(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	var req models.SetPINRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	if err := h.POSService.SetPIN(ctx, id, req.Password, req.PIN); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgPINSet, nil)
+}
+
+func (h *UserHandler) RemovePIN(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	if err := h.POSService.RemovePIN(ctx, id); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgPINRemoved, nil)
+}
+
+func (h *UserHandler) RegisterTerminal(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	actor, err := storeActor(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	storeID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	var req models.RegisterTerminalRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	terminal, secret, err := h.POSService.RegisterTerminal(ctx, storeID, req.Name, actor)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	res := toTerminalResponse(terminal)
+	res.Secret = secret
+	return respondSuccess(c, http.StatusCreated, MsgTerminalAdded, res)
+}
+
+func (h *UserHandler) ListTerminals(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	actor, err := storeActor(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	storeID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	terminals, err := h.POSService.ListTerminals(ctx, storeID, actor)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	res := make([]*models.TerminalResponse, 0, len(terminals))
+	for i := range terminals {
+		res = append(res, toTerminalResponse(&terminals[i]))
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgTerminalsGet, res)
+}
+
+func (h *UserHandler) RevokeTerminal(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	actor, err := storeActor(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	storeID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	terminalID, err := helpers.GetIDFromPathParam(c, "terminal_id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.POSService.RevokeTerminal(ctx, storeID, terminalID, actor); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgTerminalDel, nil)
+}
+
+func toTerminalResponse(terminal *entities.POSTerminal) *models.TerminalResponse {
+	res := &models.TerminalResponse{
+		ID:        terminal.ID.String(),
+		StoreID:   terminal.StoreID.String(),
+		Name:      terminal.Name,
+		CreatedAt: terminal.CreatedAt.Format(time.RFC3339),
+	}
+	if terminal.LastUsedAt != nil {
+		res.LastUsedAt = terminal.LastUsedAt.Format(time.RFC3339)
+	}
+	return res
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+func (h *UserHandler) GetPreferences(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	prefs, err := h.PreferenceService.GetPreferences(ctx, id)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgPreferencesGet, toPreferencesResponse(prefs))
+}
+
+func (h *UserHandler) UpdatePreferences(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	var req models.UpdatePreferencesRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	prefs, err := h.PreferenceService.UpdatePreferences(ctx, id, &req)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgPreferencesSet, toPreferencesResponse(prefs))
+}
+
+// Unsubscribe is public, it is called with the token of the link in a campaign email.
+func (h *UserHandler) Unsubscribe(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.UnsubscribeRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	if err := h.PreferenceService.Unsubscribe(ctx, req.Token); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgUnsubscribed, nil)
+}
+
+func toPreferencesResponse(prefs *entities.UserPreferences) *models.PreferencesResponse {
+	res := &models.PreferencesResponse{
+		PasswordlessLogin: prefs.PasswordlessLogin,
+		MarketingEmails:   prefs.MarketingEmails,
+	}
+	if prefs.UpdatedAt != nil {
+		res.UpdatedAt = prefs.UpdatedAt.Format(time.RFC3339)
+	}
+	return res
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TemplateRenderer renders the server-side pages, e.g. the OpenID Connect login page.
+type TemplateRenderer struct {
+	templates *template.Template
+}
+
+// NewTemplateRenderer parses every template matching the glob pattern once at startup.
+func NewTemplateRenderer(pattern string) (*TemplateRenderer, error) {
+	templates, err := template.ParseGlob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templates %s: %w", pattern, err)
+	}
+	return &TemplateRenderer{templates: templates}, nil
+}
+
+func (r *TemplateRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	return r.templates.ExecuteTemplate(w, name, data)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+func (h *UserHandler) ListRoles(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	roles, err := h.RoleService.ListRoles(ctx)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgRolesRetrieved, toRoleResponses(roles))
+}
+
+func (h *UserHandler) GetUserRoles(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.checkTenantUser(c, id); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	roles, err := h.RoleService.GetUserRoles(ctx, id)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgRolesRetrieved, toRoleResponses(roles))
+}
+
+func (h *UserHandler) GrantUserRole(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	adminID, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	id, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.checkTenantUser(c, id); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	var req models.GrantRoleRequest
+	if err := c.Bind(&req); err != nil || strings.TrimSpace(req.Role) == "" {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	if err := h.RoleService.GrantRole(ctx, id, strings.TrimSpace(req.Role), adminID); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgRoleGranted, nil)
+}
+
+func (h *UserHandler) RevokeUserRole(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	adminID, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	id, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.checkTenantUser(c, id); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	role, err := helpers.GetFromPathParam(c, "role")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.RoleService.RevokeRole(ctx, id, role, adminID); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgRoleRevoked, nil)
+}
+
+func toRoleResponses(roles []entities.Role) []models.RoleResponse {
+	res := make([]models.RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		res = append(res, models.RoleResponse{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: role.Permissions,
+		})
+	}
+	return res
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+func (h *UserHandler) GetSessions(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	sessions, err := h.SessionService.ListSessions(ctx, id)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgSessionsList, toSessionResponses(sessions))
+}
+
+func (h *UserHandler) RevokeSession(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	jti, err := helpers.GetFromPathParam(c, "jti")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.SessionService.RevokeSession(ctx, id, jti); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgSessionRevoked, nil)
+}
+
+// RevokeUserSessions lets an admin kill every session of a user, e.g. after a stolen device.
+func (h *UserHandler) RevokeUserSessions(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.checkTenantUser(c, id); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	if err := h.SessionService.RevokeAllSessions(ctx, id); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgSessionsRevoke, nil)
+}
+
+func toSessionResponses(sessions []models.Session) []models.SessionResponse {
+	res := make([]models.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, models.SessionResponse{
+			JTI:        session.JTI,
+			SessionID:  session.SessionID,
+			Device:     session.Device,
+			IPAddress:  session.IPAddress,
+			IssuedAt:   session.IssuedAt.Format(time.RFC3339),
+			LastSeenAt: session.LastSeenAt.Format(time.RFC3339),
+			ExpiresAt:  session.ExpiresAt.Format(time.RFC3339),
+		})
+	}
+	return res
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/middlewares"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+func (h *UserHandler) CreateOrganization(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.CreateOrganizationRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	org, err := h.TenancyService.CreateOrganization(ctx, req.Name)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusCreated, MsgOrgCreated, models.OrganizationResponse{
+		ID:        org.ID.String(),
+		Name:      org.Name,
+		CreatedAt: org.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+func (h *UserHandler) CreateStore(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	orgID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	var req models.CreateStoreRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	store, err := h.TenancyService.CreateStore(ctx, orgID, req.Name)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusCreated, MsgStoreCreated, models.StoreResponse{
+		ID:             store.ID.String(),
+		OrganizationID: store.OrganizationID.String(),
+		Name:           store.Name,
+		CreatedAt:      store.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+// GetMemberships lists the stores the caller works at, the candidates for SwitchStore.
+func (h *UserHandler) GetMemberships(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	memberships, err := h.TenancyService.ListMemberships(ctx, id)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	res := make([]models.StoreMembershipResponse, 0, len(memberships))
+	for _, m := range memberships {
+		res = append(res, models.StoreMembershipResponse{
+			StoreID:          m.StoreID.String(),
+			StoreName:        m.StoreName,
+			OrganizationID:   m.OrganizationID.String(),
+			OrganizationName: m.OrganizationName,
+			Role:             m.Role,
+			CreatedAt:        m.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgMembershipsGet, res)
+}
+
+// SwitchStore ends the current session and returns a token pair for the selected store.
+func (h *UserHandler) SwitchStore(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	var req models.SwitchStoreRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	var storeID uuid.NullUUID
+	if raw := strings.TrimSpace(req.StoreID); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+		}
+		storeID = uuid.NullUUID{UUID: parsed, Valid: true}
+	}
+
+	// The new session was authenticated the same way as the current one
+	opts := issueOptions(c)
+	opts.AMR, _ = c.Get("amr").([]string)
+
+	sessionID, _ := c.Get("sessionID").(string)
+	tokenPair, err := h.TenancyService.SwitchStore(ctx, id, sessionID, storeID, opts)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgStoreSwitched, toTokenResponse(tokenPair))
+}
+
+func (h *UserHandler) GetStoreMembers(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	actor, err := storeActor(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	storeID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	members, err := h.TenancyService.ListStoreMembers(ctx, storeID, actor)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	res := make([]models.StoreMemberResponse, 0, len(members))
+	for _, m := range members {
+		res = append(res, models.StoreMemberResponse{
+			UserID:    m.UserID.String(),
+			Name:      m.UserName,
+			Username:  m.Username,
+			Role:      m.Role,
+			CreatedAt: m.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgMembershipsGet, res)
+}
+
+func (h *UserHandler) SetStoreMember(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	actor, err := storeActor(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	storeID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	var req models.StoreMembershipRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+	userID, err := uuid.Parse(strings.TrimSpace(req.UserID))
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	if err := h.TenancyService.SetMembership(ctx, storeID, userID, strings.TrimSpace(req.Role), actor); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgMembershipSet, nil)
+}
+
+func (h *UserHandler) RemoveStoreMember(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	actor, err := storeActor(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
+	storeID, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	userID, err := helpers.GetIDFromPathParam(c, "user_id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.TenancyService.RemoveMembership(ctx, storeID, userID, actor); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgMembershipDel, nil)
+}
+
+func storeActor(c echo.Context) (services.StoreActor, error) {
+	id, err := extractUserID(c)
+	if err != nil {
+		return services.StoreActor{}, err
+	}
+
+	return services.StoreActor{
+		UserID:           id,
+		CanManageTenants: middlewares.HasPermission(c, entities.PermTenantsManage),
+	}, nil
+}
+
+// tenantScope limits admin listings and lookups to the organization of the caller's active
+// store. Platform administrators holding tenants:manage see every tenant, anyone else without
+// an active store is refused rather than shown every tenant.
+func tenantScope(c echo.Context) (uuid.NullUUID, error) {
+	if middlewares.HasPermission(c, entities.PermTenantsManage) {
+		return uuid.NullUUID{}, nil
+	}
+
+	raw, _ := c.Get("organizationID").(string)
+	orgID, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.NullUUID{}, fmt.Errorf("%w: switch to a store of your organization first", apperrors.ErrForbidden)
+	}
+	return uuid.NullUUID{UUID: orgID, Valid: true}, nil
+}
+
+// checkTenantUser applies tenantScope to the admin actions on a single user. Users of other
+// tenants are reported as missing, not as forbidden.
+func (h *UserHandler) checkTenantUser(c echo.Context, id uuid.UUID) error {
+	scope, err := tenantScope(c)
+	if err != nil || !scope.Valid {
+		return err
+	}
+
+	member, err := h.TenancyService.IsOrganizationMember(c.Request().Context(), id, scope.UUID)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return fmt.Errorf("%w: user %s", apperrors.ErrNotFound, id)
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
 
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/middlewares"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
@@ -18,26 +19,77 @@ import (
 )
 
 type UserHandler struct {
-	UserRepo         repositories.UserRepository
-	UserService      services.UserService
-	TokenService     token.TokenService
-	JWTBlacklistRepo repositories.JWTBlacklistRepository
-	log              *logrus.Logger
+	UserRepo                 repositories.UserRepository
+	UserService              services.UserService
+	SessionService           services.SessionService
+	MFAService               services.MFAService
+	PasswordService          services.PasswordService
+	EmailVerificationService services.EmailVerificationService
+	LoginThrottleService     services.LoginThrottleService
+	RoleService              services.RoleService
+	TenancyService           services.TenancyService
+	POSService               services.POSService
+	APIKeyService            services.APIKeyService
+	OAuthService             services.OAuthService
+	OIDCService              services.OIDCService
+	PasswordlessService      services.PasswordlessService
+	PreferenceService        services.PreferenceService
+	FederationService        services.FederationService
+	WebAuthnService          services.WebAuthnService
+	CampaignService          services.CampaignService
+	AuditService             services.AuditService
+	TokenService             token.TokenService
+	JWTBlacklistRepo         repositories.JWTBlacklistRepository
+	log                      *logrus.Logger
 }
 
 func NewHandler(
 	userRepo repositories.UserRepository,
 	userService services.UserService,
+	sessionService services.SessionService,
+	mfaService services.MFAService,
+	passwordService services.PasswordService,
+	emailVerificationService services.EmailVerificationService,
+	loginThrottleService services.LoginThrottleService,
+	roleService services.RoleService,
+	tenancyService services.TenancyService,
+	posService services.POSService,
+	apiKeyService services.APIKeyService,
+	oauthService services.OAuthService,
+	oidcService services.OIDCService,
+	passwordlessService services.PasswordlessService,
+	preferenceService services.PreferenceService,
+	federationService services.FederationService,
+	webAuthnService services.WebAuthnService,
+	campaignService services.CampaignService,
+	auditService services.AuditService,
 	tokenService token.TokenService,
 	jwtBlacklistRepo repositories.JWTBlacklistRepository,
 	log *logrus.Logger,
 ) *UserHandler {
 	return &UserHandler{
-		UserRepo:         userRepo,
-		UserService:      userService,
-		TokenService:     tokenService,
-		JWTBlacklistRepo: jwtBlacklistRepo,
-		log:              log,
+		UserRepo:                 userRepo,
+		UserService:              userService,
+		SessionService:           sessionService,
+		MFAService:               mfaService,
+		PasswordService:          passwordService,
+		EmailVerificationService: emailVerificationService,
+		LoginThrottleService:     loginThrottleService,
+		RoleService:              roleService,
+		TenancyService:           tenancyService,
+		POSService:               posService,
+		APIKeyService:            apiKeyService,
+		OAuthService:             oauthService,
+		OIDCService:              oidcService,
+		PasswordlessService:      passwordlessService,
+		PreferenceService:        preferenceService,
+		FederationService:        federationService,
+		WebAuthnService:          webAuthnService,
+		CampaignService:          campaignService,
+		AuditService:             auditService,
+		TokenService:             tokenService,
+		JWTBlacklistRepo:         jwtBlacklistRepo,
+		log:                      log,
 	}
 }
 
@@ -54,6 +106,11 @@ func (h *UserHandler) RegisterUser(c echo.Context) error {
 		return h.handleServiceError(c, err)
 	}
 
+	// The account exists at this point, a failed mail can be retried through /email/resend
+	if err := h.EmailVerificationService.SendVerification(ctx, userSvc); err != nil {
+		h.log.WithError(err).Error("Failed to send verification email")
+	}
+
 	return respondSuccess(c, http.StatusCreated, MsgUserCreated, toUserResponse(userSvc))
 }
 
@@ -64,24 +121,73 @@ func (h *UserHandler) Login(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
 	}
+	req.IPAddress = c.RealIP()
 
 	userSvc, err := h.UserService.Login(ctx, &req)
 	if err != nil {
 		return h.handleServiceError(c, err)
 	}
 
-	signedToken, err := h.TokenService.GenerateToken(ctx, userSvc)
+	return h.completeFirstFactor(c, userSvc, MsgMFARequired, entities.AMRPassword)
+}
+
+// completeFirstFactor finishes a login whose first factor (amr) succeeded: users with MFA get a
+// challenge, answered with mfaMessage, everyone else the token pair.
+func (h *UserHandler) completeFirstFactor(c echo.Context, userSvc *entities.User, mfaMessage string, amr ...string) error {
+	ctx := c.Request().Context()
+
+	mfaEnabled, err := h.MFAService.IsEnabled(ctx, userSvc.ID)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+	if mfaEnabled {
+		mfaToken, expiresAt, err := h.MFAService.IssueChallenge(ctx, userSvc.ID, amr)
+		if err != nil {
+			return h.handleServiceError(c, err)
+		}
+		return respondSuccess(c, http.StatusOK, mfaMessage, toMFAChallengeResponse(mfaToken, expiresAt))
+	}
+
+	return h.completeLogin(c, userSvc, amr...)
+}
+
+// completeLogin issues the token pair once every login factor has been checked. amr lists the
+// factors that were used.
+func (h *UserHandler) completeLogin(c echo.Context, userSvc *entities.User, amr ...string) error {
+	ctx := c.Request().Context()
+
+	opts := issueOptions(c)
+	opts.AMR = amr
+	tokenPair, err := h.TokenService.GenerateTokenPair(ctx, userSvc, opts)
 	if err != nil {
 		h.log.WithError(err).Error("Failed to generate JWT")
 		return respondError(c, http.StatusInternalServerError, apperrors.ErrFailedToGenerateToken)
 	}
 
 	res := toUserResponse(userSvc)
-	res.Token = signedToken
+	res.Token = tokenPair.AccessToken
+	res.TokenExpiresAt = tokenPair.AccessTokenExpiresAt.Format(time.RFC3339)
+	res.RefreshToken = tokenPair.RefreshToken
 
 	return respondSuccess(c, http.StatusOK, MsgLogin, res)
 }
 
+func (h *UserHandler) RefreshToken(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.RefreshTokenRequest
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+
+	tokenPair, err := h.TokenService.Refresh(ctx, req.RefreshToken, issueOptions(c))
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgTokenRefreshed, toTokenResponse(tokenPair))
+}
+
 func (h *UserHandler) Logout(c echo.Context) error {
 	ctx := c.Request().Context()
 
@@ -97,23 +203,70 @@ func (h *UserHandler) Logout(c echo.Context) error {
 	return respondSuccess(c, http.StatusOK, MsgLogout, nil)
 }
 
-func (h *UserHandler) GetAllUsers(c echo.Context) error {
+// GetJWKS publishes the token verification keys so other services can validate tokens locally.
+func (h *UserHandler) GetJWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, h.TokenService.JWKS())
+}
+
+func (h *UserHandler) ListUsers(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	res, err := h.UserService.GetAllUsers(ctx)
+	var query models.UserListQuery
+	if err := c.Bind(&query); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+	scope, err := tenantScope(c)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+	query.OrganizationID = scope
+
+	page, err := h.UserService.ListUsers(ctx, &query)
 	if err != nil {
 		return h.handleServiceError(c, err)
 	}
 
-	return respondSuccess(c, http.StatusOK, MsgUsersRetrieved, toUserResponses(res))
+	return respondSuccess(c, http.StatusOK, MsgUsersRetrieved, models.UserListResponse{
+		Users:      toUserResponses(page.Users),
+		NextCursor: page.NextCursor,
+	})
+}
+
+func (h *UserHandler) SearchUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var query models.UserSearchQuery
+	if err := c.Bind(&query); err != nil {
+		return respondError(c, http.StatusBadRequest, apperrors.ErrInvalidRequestPayload)
+	}
+	scope, err := tenantScope(c)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+	query.OrganizationID = scope
+
+	page, err := h.UserService.SearchUsers(ctx, &query)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgUsersRetrieved, models.UserListResponse{
+		Users:      toUserResponses(page.Users),
+		NextCursor: page.NextCursor,
+	})
 }
 
 func (h *UserHandler) GetUserById(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	id, err := extractUserID(c)
+	id, err := helpers.GetIDFromPathParam(c, "id")
 	if err != nil {
-		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.checkTenantUser(c, id); err != nil {
+		return h.handleServiceError(c, err)
 	}
 
 	res, err := h.UserService.GetUserByID(ctx, id)
@@ -121,7 +274,35 @@ func (h *UserHandler) GetUserById(c echo.Context) error {
 		return h.handleServiceError(c, err)
 	}
 
-	return respondSuccess(c, http.StatusOK, MsgUserRetrieved, toUserResponse(res))
+	lockStatus, err := h.LoginThrottleService.GetLockStatus(ctx, res.Username)
+	if err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	userRes := toUserResponse(res)
+	userRes.Lock = toLockStatusResponse(lockStatus)
+
+	return respondSuccess(c, http.StatusOK, MsgUserRetrieved, userRes)
+}
+
+// UnlockUser lifts a brute-force lockout before it expires.
+func (h *UserHandler) UnlockUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := helpers.GetIDFromPathParam(c, "id")
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, err)
+	}
+
+	if err := h.checkTenantUser(c, id); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	if err := h.LoginThrottleService.Unlock(ctx, id); err != nil {
+		return h.handleServiceError(c, err)
+	}
+
+	return respondSuccess(c, http.StatusOK, MsgUserUnlocked, nil)
 }
 
 func (h *UserHandler) GetUserProfile(c echo.Context) error {
@@ -164,11 +345,26 @@ func (h *UserHandler) UpdateUser(c echo.Context) error {
 func (h *UserHandler) DeleteUser(c echo.Context) error {
 	ctx := c.Request().Context()
 
+	callerID, err := extractUserID(c)
+	if err != nil {
+		return respondError(c, http.StatusUnauthorized, apperrors.ErrInvalidUserSession)
+	}
+
 	id, err := helpers.GetIDFromPathParam(c, "id")
 	if err != nil {
 		return respondError(c, http.StatusBadRequest, err)
 	}
 
+	// Users may delete their own account, anyone else's needs the permission
+	if id != callerID && !middlewares.HasPermission(c, entities.PermUsersDelete) {
+		return respondError(c, http.StatusForbidden, apperrors.ErrForbidden)
+	}
+	if id != callerID {
+		if err := h.checkTenantUser(c, id); err != nil {
+			return h.handleServiceError(c, err)
+		}
+	}
+
 	res, err := h.UserService.DeleteUser(ctx, id)
 	if err != nil {
 		return h.handleServiceError(c, err)
@@ -179,21 +375,47 @@ func (h *UserHandler) DeleteUser(c echo.Context) error {
 
 // ------- HELPERS -------
 func toUserResponse(user *entities.User) *models.UserResponse {
-	return &models.UserResponse{
-		Id:          user.ID,
-		Name:        user.Name,
-		Username:    user.Username,
-		Email:       user.Email,
-		Role:        user.Role,
-		Address:     user.Address,
-		PhoneNumber: user.PhoneNumber,
-		CreatedAt:   user.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:   user.UpdatedAt.Format(time.RFC3339),
+	res := &models.UserResponse{
+		Id:            user.ID,
+		Name:          user.Name,
+		Username:      user.Username,
+		Email:         user.Email,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerified(),
+		Address:       user.Address,
+		PhoneNumber:   user.PhoneNumber,
+		CreatedAt:     user.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:     user.UpdatedAt.Format(time.RFC3339),
+	}
+	if user.DeletedAt.Valid {
+		res.DeletedAt = user.DeletedAt.Time.Format(time.RFC3339)
+	}
+	return res
+}
+
+func toLockStatusResponse(status *services.LockStatus) *models.LockStatusResponse {
+	res := &models.LockStatusResponse{
+		Locked:         status.Locked,
+		FailedAttempts: status.FailedAttempts,
+	}
+	if status.Locked {
+		res.LockedUntil = status.LockedUntil.Format(time.RFC3339)
+	}
+	return res
+}
+
+func toTokenResponse(pair *token.TokenPair) *models.TokenResponse {
+	return &models.TokenResponse{
+		AccessToken:           pair.AccessToken,
+		TokenType:             "Bearer",
+		ExpiresIn:             int64(time.Until(pair.AccessTokenExpiresAt).Seconds()),
+		RefreshToken:          pair.RefreshToken,
+		RefreshTokenExpiresAt: pair.RefreshTokenExpiresAt.Format(time.RFC3339),
 	}
 }
 
 func toUserResponses(users []entities.User) []models.UserResponse {
-	var res []models.UserResponse
+	res := make([]models.UserResponse, 0, len(users))
 	for _, user := range users {
 		res = append(res, *toUserResponse(&user))
 	}
@@ -1,8 +1,20 @@
 package helpers
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 func HashPassword(password string) string {
 	hashed, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(hashed)
 }
+
+// HashToken returns the hex encoded SHA-256 digest of a high-entropy opaque token.
+// Use it for values that are looked up by hash (refresh tokens, reset tokens), not for passwords.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,6 +1,10 @@
 package helpers
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -10,6 +14,15 @@ func GenerateNewUUID() string {
 	return uuid.New().String()
 }
 
+// GenerateSecureToken returns a URL-safe random string built from n random bytes.
+func GenerateSecureToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secure token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 func isValidUUID(u string) bool {
 	_, err := uuid.Parse(u)
 	return err == nil
@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/audit"
+)
+
+// AuditContextMiddleware puts the client IP, user agent and request ID into the request
+// context, where the services pick them up for the audit log. It has to run after
+// middleware.RequestID.
+func AuditContextMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			ctx := audit.WithRequest(req.Context(), audit.RequestInfo{
+				IPAddress: c.RealIP(),
+				UserAgent: req.UserAgent(),
+				RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+			})
+			c.SetRequest(req.WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
@@ -2,11 +2,14 @@ package middlewares
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
 
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/audit"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
 
 	"github.com/labstack/echo/v4"
@@ -16,6 +19,10 @@ type AuthMiddlewareOptions struct {
 	TokenService token.TokenService
 }
 
+// scopedTokenMessage is returned for tokens limited to a scope (e.g. PIN logins on a POS
+// terminal), they are only accepted by the services of that scope.
+const scopedTokenMessage = "Token is limited to scope %q and can not be used for the account API"
+
 // AuthMiddleware is an Echo middleware function to validate JWT tokens for REST API.
 func AuthMiddleware(opts AuthMiddlewareOptions) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -26,7 +33,7 @@ func AuthMiddleware(opts AuthMiddlewareOptions) echo.MiddlewareFunc {
 			}
 			token := authHeader[7:]
 
-			isValid, userID, username, userRole, errMsg, err := opts.TokenService.ValidateToken(context.Background(), token)
+			isValid, claims, errMsg, err := opts.TokenService.ValidateToken(context.Background(), token)
 			if err != nil {
 				log.Printf("Token validation error: %v", err)
 				// If the error is due to an expired token or cryptographic invalidity,
@@ -38,9 +45,24 @@ func AuthMiddleware(opts AuthMiddlewareOptions) echo.MiddlewareFunc {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid token: " + errMsg})
 			}
 
-			c.Set("userID", userID)
-			c.Set("username", username)
-			c.Set("role", userRole)
+			if claims.Scope != "" {
+				return c.JSON(http.StatusForbidden, map[string]string{"message": fmt.Sprintf(scopedTokenMessage, claims.Scope)})
+			}
+
+			c.Set("userID", claims.UserID)
+			c.Set("username", claims.Username)
+			c.Set("role", claims.Role)
+			c.Set("roles", claims.Roles)
+			c.Set("permissions", claims.Permissions)
+			c.Set("emailVerified", claims.EmailVerified)
+			c.Set("sessionID", claims.SessionID)
+			c.Set("amr", claims.AMR)
+			c.Set("storeID", claims.StoreID)
+			c.Set("storeRole", claims.StoreRole)
+			c.Set("organizationID", claims.OrganizationID)
+
+			// The user is the actor of everything the audit log records for this request
+			c.SetRequest(c.Request().WithContext(audit.WithActor(c.Request().Context(), claims.UserID)))
 
 			// Continue to the next handler
 			return next(c)
@@ -48,21 +70,63 @@ func AuthMiddleware(opts AuthMiddlewareOptions) echo.MiddlewareFunc {
 	}
 }
 
-func RequireRoles(allowedRoles ...string) echo.MiddlewareFunc {
-	roleSet := make(map[string]struct{})
-	for _, r := range allowedRoles {
-		roleSet[r] = struct{}{}
+// RequirePermission only lets requests through whose token grants the permission.
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, ok := c.Get("permissions").([]string); !ok {
+				return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+			}
+
+			if !HasPermission(c, permission) {
+				return c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Access denied"})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// HasPermission reports whether the authenticated user was granted the permission, for
+// handlers that allow an action on the own account but need a permission for others.
+func HasPermission(c echo.Context, permission string) bool {
+	permissions, _ := c.Get("permissions").([]string)
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireSession rejects API keys on endpoints that manage credentials or start sessions, so a
+// leaked key can not be turned into further access.
+func RequireSession() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			amr, _ := c.Get("amr").([]string)
+			for _, method := range amr {
+				if method == entities.AMRAPIKey {
+					return c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "This endpoint requires a login session, API keys are not accepted"})
+				}
+			}
+			return next(c)
+		}
 	}
+}
 
+// RequireVerifiedEmail rejects tokens of users that did not confirm their email address yet.
+// Only used in the "restricted" EMAIL_VERIFICATION_MODE.
+func RequireVerifiedEmail() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			role, ok := c.Get("role").(string)
+			verified, ok := c.Get("emailVerified").(bool)
 			if !ok {
 				return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
 			}
 
-			if _, allowed := roleSet[role]; !allowed {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Access denied"})
+			if !verified {
+				return c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Email address is not verified"})
 			}
 
 			return next(c)
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+type GRPCPermissionOptions struct {
+	TokenService token.TokenService
+	// Methods maps full method names ("/package.Service/Method") to the permission they need.
+	// Methods that are not listed are not checked.
+	Methods map[string]string
+}
+
+// UnaryPermissionInterceptor is the gRPC counterpart of RequirePermission. The caller passes
+// the user's access token as "authorization: Bearer <token>" metadata.
+func UnaryPermissionInterceptor(opts GRPCPermissionOptions) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		permission, ok := opts.Methods[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+			return nil, status.Error(codes.Unauthenticated, "authentication token missing or invalid format")
+		}
+
+		isValid, claims, errMsg, err := opts.TokenService.ValidateToken(ctx, strings.TrimPrefix(values[0], "Bearer "))
+		if err != nil {
+			log.Printf("Token validation error: %v", err)
+		}
+		if !isValid {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid token: %s", errMsg)
+		}
+
+		for _, granted := range claims.Permissions {
+			if granted == permission {
+				return handler(context.WithValue(ctx, grpcClaimsKey{}, claims), req)
+			}
+		}
+		return nil, status.Errorf(codes.PermissionDenied, "missing permission %s", permission)
+	}
+}
+
+type grpcClaimsKey struct{}
+
+// GRPCClaims returns the claims of the access token UnaryPermissionInterceptor checked for the call.
+func GRPCClaims(ctx context.Context) (*token.JWTClaims, bool) {
+	claims, ok := ctx.Value(grpcClaimsKey{}).(*token.JWTClaims)
+	return claims, ok
+}
@@ -0,0 +1,89 @@
+package middlewares
+
+import (
+	"context"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCRateLimitKeyFunc derives the bucket a gRPC call is counted in.
+type GRPCRateLimitKeyFunc func(ctx context.Context, info *grpc.UnaryServerInfo) string
+
+// GRPCKeyByPeer counts calls per calling host and method.
+func GRPCKeyByPeer(ctx context.Context, info *grpc.UnaryServerInfo) string {
+	host := "unknown"
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host = p.Addr.String()
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+	return "peer:" + host + ":" + info.FullMethod
+}
+
+type GRPCRateLimitOptions struct {
+	Limiter RateLimiter
+	Name    string
+	Limit   RateLimit
+	KeyFunc GRPCRateLimitKeyFunc
+	// Services limits the interceptor to these fully qualified services, e.g. "account.AccountService".
+	// Empty means every service.
+	Services []string
+}
+
+// UnaryRateLimitInterceptor is the gRPC counterpart of RateLimitMiddleware. The quota is sent
+// back as x-ratelimit-* header metadata and exhausted calls fail with ResourceExhausted.
+func UnaryRateLimitInterceptor(opts GRPCRateLimitOptions) grpc.UnaryServerInterceptor {
+	keyFunc := opts.KeyFunc
+	if keyFunc == nil {
+		keyFunc = GRPCKeyByPeer
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !matchesService(info.FullMethod, opts.Services) {
+			return handler(ctx, req)
+		}
+
+		res, err := opts.Limiter.Allow(ctx, opts.Name+":"+keyFunc(ctx, info), opts.Limit)
+		if err != nil {
+			log.Printf("Rate limiter unavailable, allowing call: %v", err)
+			return handler(ctx, req)
+		}
+
+		header := metadata.Pairs(
+			"x-ratelimit-limit", strconv.FormatInt(res.Limit, 10),
+			"x-ratelimit-remaining", strconv.FormatInt(res.Remaining, 10),
+			"x-ratelimit-reset", strconv.FormatInt(res.ResetAt.Unix(), 10),
+		)
+		if err := grpc.SetHeader(ctx, header); err != nil {
+			log.Printf("Failed to set rate limit headers: %v", err)
+		}
+
+		if !res.Allowed {
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry after %s", res.ResetAt.Format("15:04:05"))
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// matchesService checks the "/package.Service/Method" name against the configured services.
+func matchesService(fullMethod string, services []string) bool {
+	if len(services) == 0 {
+		return true
+	}
+	for _, service := range services {
+		if strings.HasPrefix(fullMethod, "/"+service+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package middlewares
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+// Supported values for SERVICE_AUTH_MODE.
+const (
+	ServiceAuthEnforce    = "enforce"
+	ServiceAuthPermissive = "permissive"
+)
+
+// ServiceTokenValidator verifies the tokens other services obtain with the client credentials grant.
+type ServiceTokenValidator interface {
+	ValidateServiceToken(ctx context.Context, tokenString string) (*token.ServiceClaims, error)
+}
+
+type GRPCServiceAuthOptions struct {
+	Validator ServiceTokenValidator
+	// Services are the fully qualified services that require a service token, e.g. "account.AccountService".
+	Services []string
+	// Methods maps full method names ("/package.Service/Method") to the scope they need. Methods
+	// of the listed services that are missing here are denied.
+	Methods map[string]string
+	// Permissive only logs failed checks instead of rejecting the call, to roll the check out
+	// before every caller has a client registered.
+	Permissive bool
+}
+
+// UnaryServiceAuthInterceptor requires callers of the listed services to pass a service token as
+// "authorization: Bearer <token>" metadata that grants the scope of the called method.
+func UnaryServiceAuthInterceptor(opts GRPCServiceAuthOptions) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if len(opts.Services) == 0 || !matchesService(info.FullMethod, opts.Services) {
+			return handler(ctx, req)
+		}
+
+		if err := authorizeServiceCall(ctx, opts, info.FullMethod); err != nil {
+			if opts.Permissive {
+				log.Printf("Service auth check failed for %s, allowed in permissive mode: %v", info.FullMethod, err)
+				return handler(ctx, req)
+			}
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func authorizeServiceCall(ctx context.Context, opts GRPCServiceAuthOptions, fullMethod string) error {
+	scope, ok := opts.Methods[fullMethod]
+	if !ok {
+		return status.Errorf(codes.PermissionDenied, "method %s is not available to services", fullMethod)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return status.Error(codes.Unauthenticated, "service token missing or invalid format")
+	}
+
+	claims, err := opts.Validator.ValidateServiceToken(ctx, strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil {
+		return status.Errorf(codes.Unauthenticated, "invalid service token: %v", err)
+	}
+
+	if !claims.HasScope(scope) {
+		return status.Errorf(codes.PermissionDenied, "client %s is missing scope %s", claims.ClientID, scope)
+	}
+	return nil
+}
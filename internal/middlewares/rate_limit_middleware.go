@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+)
+
+// RateLimitKeyFunc derives the bucket a request is counted in.
+type RateLimitKeyFunc func(c echo.Context) string
+
+// KeyByIP counts requests per client IP.
+func KeyByIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// KeyByUserID counts requests per authenticated user and falls back to the client IP, so it
+// must run after AuthMiddleware to be effective.
+func KeyByUserID(c echo.Context) string {
+	if id, ok := c.Get("userID").(uuid.UUID); ok {
+		return "user:" + id.String()
+	}
+	return KeyByIP(c)
+}
+
+// KeyByRoute gives every route its own quota on top of the wrapped key.
+func KeyByRoute(key RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(c echo.Context) string {
+		return c.Request().Method + ":" + c.Path() + ":" + key(c)
+	}
+}
+
+type RateLimitOptions struct {
+	Limiter RateLimiter
+	// Name separates the quotas of different route groups using the same key function.
+	Name    string
+	Limit   RateLimit
+	KeyFunc RateLimitKeyFunc
+}
+
+// RateLimitMiddleware rejects requests over the quota with 429 and reports the quota in
+// X-RateLimit-* headers. If Redis is unavailable requests are let through.
+func RateLimitMiddleware(opts RateLimitOptions) echo.MiddlewareFunc {
+	keyFunc := opts.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByIP
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := fmt.Sprintf("%s:%s", opts.Name, keyFunc(c))
+
+			res, err := opts.Limiter.Allow(c.Request().Context(), key, opts.Limit)
+			if err != nil {
+				log.Printf("Rate limiter unavailable, allowing request: %v", err)
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set("X-RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+			header.Set("X-RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+			header.Set("X-RateLimit-Reset", strconv.FormatInt(res.ResetAt.Unix(), 10))
+
+			if !res.Allowed {
+				retryAfter := int64(math.Ceil(time.Until(res.ResetAt).Seconds()))
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				header.Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+				return c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: "Rate limit exceeded"})
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/redisclient"
+)
+
+// RateLimit allows Limit requests per key in any sliding Window.
+type RateLimit struct {
+	Limit  int64
+	Window time.Duration
+}
+
+// RateLimitResult is the outcome of a single limiter check.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	// ResetAt is when the oldest request in the window expires and frees a slot.
+	ResetAt time.Time
+}
+
+type RateLimiter interface {
+	// Allow records one request for key and reports whether it fits in the limit.
+	Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error)
+}
+
+// redisRateLimiter is a sliding-window log limiter kept in Redis sorted sets, so the quota is
+// shared by every instance of the service.
+type redisRateLimiter struct {
+	redisClient *redisclient.RedisClient
+}
+
+func NewRateLimiter(redisClient *redisclient.RedisClient) RateLimiter {
+	return &redisRateLimiter{redisClient: redisClient}
+}
+
+// slidingWindowScript drops entries older than the window, then records the request if there
+// is room left. Returns {allowed, count, oldest entry in ms}.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+local count = redis.call("ZCARD", key)
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", key, now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call("PEXPIRE", key, window)
+
+local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+local oldestScore = now
+if oldest[2] then
+	oldestScore = tonumber(oldest[2])
+end
+return {allowed, count, oldestScore}
+`)
+
+func (l *redisRateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error) {
+	now := time.Now()
+	res, err := slidingWindowScript.Run(ctx, l.redisClient.Client,
+		[]string{fmt.Sprintf("ratelimit:%s", key)},
+		now.UnixMilli(),
+		limit.Window.Milliseconds(),
+		limit.Limit,
+		uuid.NewString(),
+	).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	remaining := limit.Limit - res[1]
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return &RateLimitResult{
+		Allowed:   res[0] == 1,
+		Limit:     limit.Limit,
+		Remaining: remaining,
+		ResetAt:   time.UnixMilli(res[2]).Add(limit.Window),
+	}, nil
+}
@@ -0,0 +1,25 @@
+package models
+
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" validate:"required"`
+	// Scopes are permissions of the user the key may use, e.g. "users:read".
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is an optional RFC 3339 timestamp, keys without it stay valid until revoked.
+	ExpiresAt string `json:"expires_at"`
+}
+
+type UpdateAPIKeyRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type APIKeyResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+	// Key is only returned when the key is created, it can not be retrieved again.
+	Key string `json:"key,omitempty"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEventQuery are the query parameters of the audit log listings. The security activity
+// of a user ignores ActorID and TargetUserID.
+type AuditEventQuery struct {
+	Limit int `query:"limit"`
+	// Cursor is the next_cursor of the previous page.
+	Cursor       string `query:"cursor"`
+	Action       string `query:"action"`
+	ActorID      string `query:"actor_id"`
+	TargetUserID string `query:"target_user_id"`
+	// CreatedFrom and CreatedTo are RFC 3339 timestamps, CreatedTo is exclusive.
+	CreatedFrom string `query:"created_from"`
+	CreatedTo   string `query:"created_to"`
+}
+
+type AuditEventResponse struct {
+	ID           uuid.UUID       `json:"id"`
+	Action       string          `json:"action"`
+	ActorID      *uuid.UUID      `json:"actor_id"`
+	TargetUserID *uuid.UUID      `json:"target_user_id"`
+	IPAddress    string          `json:"ip_address"`
+	UserAgent    string          `json:"user_agent"`
+	RequestID    string          `json:"request_id"`
+	Metadata     json.RawMessage `json:"metadata"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+type AuditEventListResponse struct {
+	Events     []AuditEventResponse `json:"events"`
+	NextCursor string               `json:"next_cursor"`
+}
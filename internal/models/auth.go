@@ -6,8 +6,9 @@ import (
 )
 
 type JWTClaims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Username string    `json:"username"`
-	Role     string    `json:"role"`
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	SessionID string    `json:"sid,omitempty"` // refresh token family the token was issued for
 	jwt.RegisteredClaims
 }
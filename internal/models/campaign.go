@@ -0,0 +1,65 @@
+package models
+
+type CampaignSegmentRequest struct {
+	// Roles limits the campaign to users holding any of the roles, all users when empty.
+	Roles []string `json:"roles"`
+	// SignedUpAfter and SignedUpBefore are optional RFC 3339 timestamps, SignedUpBefore is exclusive.
+	SignedUpAfter  string `json:"signed_up_after"`
+	SignedUpBefore string `json:"signed_up_before"`
+	// EmailVerified limits the campaign to verified (true) or unverified (false) addresses.
+	EmailVerified *bool `json:"email_verified"`
+}
+
+// CreateCampaignRequest creates a draft campaign. Subject and Body are Go text templates that
+// can use {{.Name}}, {{.Username}}, {{.Email}} and {{.UnsubscribeURL}}.
+type CreateCampaignRequest struct {
+	Name    string                 `json:"name" validate:"required,max=200"`
+	Subject string                 `json:"subject" validate:"required,max=300"`
+	Body    string                 `json:"body" validate:"required"`
+	Segment CampaignSegmentRequest `json:"segment"`
+}
+
+type ScheduleCampaignRequest struct {
+	// ScheduledAt is an RFC 3339 timestamp, the campaign is sent right away when it is omitted.
+	ScheduledAt string `json:"scheduled_at"`
+}
+
+// CampaignListQuery are the query parameters of the campaign listing.
+type CampaignListQuery struct {
+	Status string `query:"status"`
+	Limit  int    `query:"limit"`
+}
+
+type CampaignSegmentResponse struct {
+	Roles          []string `json:"roles"`
+	SignedUpAfter  string   `json:"signed_up_after,omitempty"`
+	SignedUpBefore string   `json:"signed_up_before,omitempty"`
+	EmailVerified  *bool    `json:"email_verified,omitempty"`
+}
+
+type CampaignResponse struct {
+	ID             string                  `json:"id"`
+	Name           string                  `json:"name"`
+	Subject        string                  `json:"subject"`
+	Body           string                  `json:"body"`
+	Segment        CampaignSegmentResponse `json:"segment"`
+	Status         string                  `json:"status"`
+	ScheduledAt    string                  `json:"scheduled_at,omitempty"`
+	StartedAt      string                  `json:"started_at,omitempty"`
+	DispatchedAt   string                  `json:"dispatched_at,omitempty"`
+	CompletedAt    string                  `json:"completed_at,omitempty"`
+	CancelledAt    string                  `json:"cancelled_at,omitempty"`
+	RecipientCount int32                   `json:"recipient_count"`
+	CreatedBy      string                  `json:"created_by,omitempty"`
+	CreatedAt      string                  `json:"created_at"`
+	UpdatedAt      string                  `json:"updated_at"`
+	// Deliveries counts the deliveries per status, only returned for a single campaign.
+	Deliveries map[string]int64 `json:"deliveries,omitempty"`
+}
+
+type CampaignPreviewResponse struct {
+	To             string `json:"to"`
+	Subject        string `json:"subject"`
+	Body           string `json:"body"`
+	RecipientCount int64  `json:"recipient_count"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "github.com/google/uuid"
+
+// FederationState is kept between sending the user to an identity provider and the callback.
+// UserID is set when a signed in user links the provider to their account.
+type FederationState struct {
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	UserID       uuid.UUID `json:"user_id"`
+}
+
+// FederatedCallbackRequest carries the query parameters the provider redirected the browser with.
+type FederatedCallbackRequest struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+type FederatedAuthorizationResponse struct {
+	// AuthorizationURL is the provider page the browser has to be sent to.
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+}
+
+type IdentityProviderResponse struct {
+	Name string `json:"name"`
+}
+
+type UserIdentityResponse struct {
+	ID          string `json:"id"`
+	Provider    string `json:"provider"`
+	Email       string `json:"email"`
+	CreatedAt   string `json:"created_at"`
+	LastLoginAt string `json:"last_login_at,omitempty"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "encoding/json"
+
+type MFAEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type MFAConfirmRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAChallengeResponse is returned by the password step when the account has MFA enabled.
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+// MFAVerifyRequest answers an MFA challenge with a TOTP code, a recovery code or a passkey
+// assertion for the options from the passkey MFA endpoint.
+type MFAVerifyRequest struct {
+	MFAToken         string          `json:"mfa_token" validate:"required"`
+	Code             string          `json:"code,omitempty"`
+	RecoveryCode     string          `json:"recovery_code,omitempty"`
+	PasskeySessionID string          `json:"passkey_session_id,omitempty"`
+	Passkey          json.RawMessage `json:"passkey,omitempty"`
+}
@@ -0,0 +1,52 @@
+package models
+
+type CreateOAuthClientRequest struct {
+	Name string `json:"name" validate:"required"`
+	// Scopes the client may request, e.g. "users:read" for services or "openid" for applications.
+	// Applications get every OpenID Connect scope when left empty.
+	Scopes []string `json:"scopes"`
+	// GrantTypes defaults to client_credentials. Applications signing users in use authorization_code.
+	GrantTypes   []string `json:"grant_types"`
+	RedirectURIs []string `json:"redirect_uris"`
+	// Public clients (SPAs, mobile apps) get no secret and must use PKCE.
+	Public bool `json:"public"`
+	// FirstParty clients are our own apps, they skip the consent screen and get refresh tokens.
+	FirstParty bool `json:"first_party"`
+}
+
+type UpdateOAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required"`
+	Scopes       []string `json:"scopes"`
+	RedirectURIs []string `json:"redirect_uris"`
+}
+
+type OAuthClientResponse struct {
+	ID           string   `json:"id"`
+	ClientID     string   `json:"client_id"`
+	Name         string   `json:"name"`
+	Scopes       []string `json:"scopes"`
+	GrantTypes   []string `json:"grant_types"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Public       bool     `json:"public"`
+	FirstParty   bool     `json:"first_party"`
+	LastUsedAt   string   `json:"last_used_at,omitempty"`
+	CreatedAt    string   `json:"created_at"`
+	// ClientSecret is only returned when the client is registered, it can not be retrieved again.
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// OAuthTokenResponse is the RFC 6749 section 5.1 access token response.
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Scope        string `json:"scope"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+// OAuthErrorResponse is the RFC 6749 section 5.2 error response.
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
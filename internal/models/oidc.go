@@ -0,0 +1,42 @@
+package models
+
+// AuthorizationRequest are the OpenID Connect authentication request parameters. The login page
+// posts them back as hidden fields, so they are bound from the query and the form.
+type AuthorizationRequest struct {
+	ResponseType        string `query:"response_type" form:"response_type"`
+	ClientID            string `query:"client_id" form:"client_id"`
+	RedirectURI         string `query:"redirect_uri" form:"redirect_uri"`
+	Scope               string `query:"scope" form:"scope"`
+	State               string `query:"state" form:"state"`
+	Nonce               string `query:"nonce" form:"nonce"`
+	CodeChallenge       string `query:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" form:"code_challenge_method"`
+	Prompt              string `query:"prompt" form:"prompt"`
+}
+
+// UserInfoResponse is the OpenID Connect UserInfo response, claims depend on the granted scopes.
+type UserInfoResponse struct {
+	Subject           string `json:"sub"`
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	UpdatedAt         int64  `json:"updated_at,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+// OIDCDiscoveryResponse is the OpenID Provider metadata served at /.well-known/openid-configuration.
+type OIDCDiscoveryResponse struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
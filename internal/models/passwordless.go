@@ -0,0 +1,22 @@
+package models
+
+// MagicLoginRequest asks for a sign-in link or a 6-digit code by email. Method is "link"
+// (default) or "code".
+type MagicLoginRequest struct {
+	Email  string `json:"email" validate:"required,email"`
+	Method string `json:"method" validate:"omitempty,oneof=link code"`
+}
+
+// MagicLoginVerifyRequest carries either the token of a sign-in link or the email and code.
+type MagicLoginVerifyRequest struct {
+	Token string `json:"token"`
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+// MagicLoginChallenge is the pending passwordless login of a user. Only hashes are stored.
+type MagicLoginChallenge struct {
+	Method    string `json:"method"`
+	TokenHash string `json:"token_hash,omitempty"`
+	CodeHash  string `json:"code_hash,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+type SetPINRequest struct {
+	// Password of the account confirms the change.
+	Password string `json:"password" validate:"required"`
+	PIN      string `json:"pin" validate:"required"`
+}
+
+type PINLoginRequest struct {
+	TerminalID     string `json:"terminal_id" validate:"required"`
+	TerminalSecret string `json:"terminal_secret" validate:"required"`
+	Username       string `json:"username" validate:"required"`
+	PIN            string `json:"pin" validate:"required"`
+}
+
+// PINLoginResponse carries a token limited to the POS scopes. It can not be refreshed, the
+// cashier signs in with the PIN again once it expires.
+type PINLoginResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+type RegisterTerminalRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type TerminalResponse struct {
+	ID         string `json:"id"`
+	StoreID    string `json:"store_id"`
+	Name       string `json:"name"`
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+	// Secret is only returned when the terminal is registered, it can not be retrieved again.
+	Secret string `json:"secret,omitempty"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// UpdatePreferencesRequest changes the given preferences, omitted fields are left as they are.
+type UpdatePreferencesRequest struct {
+	PasswordlessLogin *bool `json:"passwordless_login"`
+	MarketingEmails   *bool `json:"marketing_emails"`
+}
+
+// UnsubscribeRequest carries the token of the unsubscribe link in a campaign email.
+type UnsubscribeRequest struct {
+	Token string `json:"token"`
+}
+
+type PreferencesResponse struct {
+	PasswordlessLogin bool   `json:"passwordless_login"`
+	MarketingEmails   bool   `json:"marketing_emails"`
+	UpdatedAt         string `json:"updated_at,omitempty"`
+}
@@ -4,6 +4,8 @@ package models
 
 type ErrorResponse struct {
 	Error any `json:"error"`
+	// Field names the conflicting field of a 409 response.
+	Field string `json:"field,omitempty"`
 }
 
 // SuccessResponse untuk response sukses standar (tanpa data)
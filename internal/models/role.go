@@ -0,0 +1,11 @@
+package models
+
+type RoleResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+type GrantRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
@@ -6,10 +6,25 @@ import (
 	"github.com/google/uuid"
 )
 
+// Session is an active login of a user. It follows the refresh token family (SessionID)
+// and always points at the JTI of the most recently issued access token.
 type Session struct {
-	Token     string    `json:"token"`
-	UserID    uuid.UUID `json:"user_id"`
-	Username  string    `json:"username"`
-	Role      string    `json:"role"`
-	ExpiresAt time.Time `json:"expires_at"`
+	JTI        string    `json:"jti"`
+	SessionID  string    `json:"session_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Device     string    `json:"device"`
+	IPAddress  string    `json:"ip_address"`
+	IssuedAt   time.Time `json:"issued_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type SessionResponse struct {
+	JTI        string `json:"jti"`
+	SessionID  string `json:"session_id"`
+	Device     string `json:"device"`
+	IPAddress  string `json:"ip_address"`
+	IssuedAt   string `json:"issued_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	ExpiresAt  string `json:"expires_at"`
 }
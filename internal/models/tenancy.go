@@ -0,0 +1,51 @@
+package models
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type CreateStoreRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// StoreMembershipRequest adds a user to a store or changes their role. Role is owner, manager
+// or cashier.
+type StoreMembershipRequest struct {
+	UserID string `json:"user_id" validate:"required"`
+	Role   string `json:"role" validate:"required"`
+}
+
+// SwitchStoreRequest selects the active store of the session. An empty StoreID leaves the store.
+type SwitchStoreRequest struct {
+	StoreID string `json:"store_id"`
+}
+
+type OrganizationResponse struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+type StoreResponse struct {
+	ID             string `json:"id"`
+	OrganizationID string `json:"organization_id"`
+	Name           string `json:"name"`
+	CreatedAt      string `json:"created_at"`
+}
+
+type StoreMembershipResponse struct {
+	StoreID          string `json:"store_id"`
+	StoreName        string `json:"store_name"`
+	OrganizationID   string `json:"organization_id"`
+	OrganizationName string `json:"organization_name"`
+	Role             string `json:"role"`
+	CreatedAt        string `json:"created_at"`
+}
+
+type StoreMemberResponse struct {
+	UserID    string `json:"user_id"`
+	Name      string `json:"name"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+}
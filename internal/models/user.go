@@ -7,21 +7,24 @@ type UserRegisterRequest struct {
 	Username string `json:"username" validate:"required"`
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
-	Role     string `json:"role"`
-	Token    string `json:"token"` // TODO : validate jika rolenya admin
 }
 
 type UserResponse struct {
-	Id          uuid.UUID `json:"id"`
-	Name        string    `json:"name"`
-	Username    string    `json:"username"`
-	Email       string    `json:"email"`
-	Address     string    `json:"address"`
-	PhoneNumber string    `json:"phone_number"`
-	Role        string    `json:"role"`
-	Token       string    `json:"token"`
-	CreatedAt   string    `json:"created_at"`
-	UpdatedAt   string    `json:"updated_at"`
+	Id             uuid.UUID           `json:"id"`
+	Name           string              `json:"name"`
+	Username       string              `json:"username"`
+	Email          string              `json:"email"`
+	Address        string              `json:"address"`
+	PhoneNumber    string              `json:"phone_number"`
+	Role           string              `json:"role"`
+	EmailVerified  bool                `json:"email_verified"`
+	Token          string              `json:"token"`
+	RefreshToken   string              `json:"refresh_token,omitempty"`
+	TokenExpiresAt string              `json:"token_expires_at,omitempty"`
+	Lock           *LockStatusResponse `json:"lock,omitempty"` // admin view only
+	CreatedAt      string              `json:"created_at"`
+	UpdatedAt      string              `json:"updated_at"`
+	DeletedAt      string              `json:"deleted_at,omitempty"`
 }
 
 type UserUpdateRequest struct {
@@ -33,6 +36,25 @@ type UserUpdateRequest struct {
 	PhoneNumber string `json:"phone_number,omitempty"`
 }
 type UserLoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username  string `json:"username" binding:"required"`
+	Password  string `json:"password" binding:"required"`
+	IPAddress string `json:"-"` // client IP, filled by the handler for login throttling
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type TokenResponse struct {
+	AccessToken           string `json:"access_token"`
+	TokenType             string `json:"token_type"`
+	ExpiresIn             int64  `json:"expires_in"`
+	RefreshToken          string `json:"refresh_token"`
+	RefreshTokenExpiresAt string `json:"refresh_token_expires_at"`
+}
+
+type LockStatusResponse struct {
+	Locked         bool   `json:"locked"`
+	LockedUntil    string `json:"locked_until,omitempty"`
+	FailedAttempts int64  `json:"failed_attempts"`
 }
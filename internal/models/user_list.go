@@ -0,0 +1,37 @@
+package models
+
+import "github.com/google/uuid"
+
+// UserListQuery are the query parameters of the admin user listing.
+type UserListQuery struct {
+	Limit int `query:"limit"`
+	// Cursor is the next_cursor of the previous page.
+	Cursor string `query:"cursor"`
+	Role   string `query:"role"`
+	// CreatedFrom and CreatedTo are RFC 3339 timestamps, CreatedTo is exclusive.
+	CreatedFrom string `query:"created_from"`
+	CreatedTo   string `query:"created_to"`
+	// Deleted is "exclude" (default), "include" or "only".
+	Deleted string `query:"deleted"`
+	// Sort is created_at, -created_at (default), name or -name.
+	Sort   string `query:"sort"`
+	Search string `query:"q"`
+	// OrganizationID scopes the listing to the members of one tenant. It is taken from the
+	// caller's token, never from the query string.
+	OrganizationID uuid.NullUUID `query:"-"`
+}
+
+type UserListResponse struct {
+	Users      []UserResponse `json:"users"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+// UserSearchQuery are the query parameters of the admin user search.
+type UserSearchQuery struct {
+	Limit  int    `query:"limit"`
+	Cursor string `query:"cursor"`
+	// Search matches partial or misspelled names, usernames, emails and phone numbers.
+	Search string `query:"q"`
+	// OrganizationID scopes the search like UserListQuery.OrganizationID.
+	OrganizationID uuid.NullUUID `query:"-"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+// WebAuthnSession is kept between handing out the options of a ceremony and the response of the
+// authenticator. UserID is uuid.Nil for passkey logins, where the user is not known yet.
+type WebAuthnSession struct {
+	Purpose string               `json:"purpose"`
+	UserID  uuid.UUID            `json:"user_id"`
+	Data    webauthn.SessionData `json:"data"`
+}
+
+// WebAuthnOptionsResponse carries the options for navigator.credentials.create() or .get().
+// SessionID has to be sent back with the result.
+type WebAuthnOptionsResponse struct {
+	SessionID string      `json:"session_id"`
+	Options   interface{} `json:"options"`
+}
+
+// RegisterPasskeyRequest carries the PublicKeyCredential returned by navigator.credentials.create().
+type RegisterPasskeyRequest struct {
+	SessionID  string          `json:"session_id" validate:"required"`
+	Name       string          `json:"name" validate:"max=64"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+// PasskeyLoginRequest carries the PublicKeyCredential returned by navigator.credentials.get().
+type PasskeyLoginRequest struct {
+	SessionID  string          `json:"session_id" validate:"required"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type PasskeyMFAOptionsRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+type PasskeyResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Transports []string `json:"transports"`
+	// Synced reports whether the passkey is backed up, e.g. to a password manager.
+	Synced     bool   `json:"synced"`
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+}
@@ -1,6 +1,9 @@
 package errors
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	// validation
@@ -15,10 +18,16 @@ var (
 	ErrMissingJTI            = errors.New("missing JTI, cannot revoke token")
 	ErrFailedToRevokeToken   = errors.New("failed to revoke token")
 	ErrTokenNotFound         = errors.New("token not found")
+	ErrRefreshTokenReused    = errors.New("refresh token reuse detected, session revoked")
+	ErrSessionNotFound       = errors.New("session not found")
+	ErrInvalidResetToken     = errors.New("invalid or expired password reset token")
 	ErrInvalidCredentials    = errors.New("invalid credentials")
 	ErrUserAlreadyExists     = errors.New("user already exists")
 	ErrNotFound              = errors.New("not found")
 	ErrForbidden             = errors.New("forbidden")
+	// ErrAPIKeyLogout is returned when an API key is sent to logout. A key has no session to end,
+	// it is revoked through the API key endpoints.
+	ErrAPIKeyLogout = errors.New("API keys can not be logged out, revoke the key instead")
 
 	// status
 	ErrInternalServerError = errors.New("internal server error")
@@ -29,11 +38,89 @@ var (
 	ErrFailedToCreateUser = errors.New("failed to create user")
 	ErrFailedToUpdateUser = errors.New("failed to update user")
 	ErrFailedToDeleteUser = errors.New("failed to delete user")
+	ErrEmailNotVerified   = errors.New("email address is not verified")
+
+	// mfa
+	ErrMFARequired       = errors.New("multi-factor authentication required")
+	ErrMFANotEnrolled    = errors.New("multi-factor authentication is not enrolled")
+	ErrMFAAlreadyEnabled = errors.New("multi-factor authentication is already enabled")
+	ErrInvalidMFACode    = errors.New("invalid verification code")
+	ErrTooManyAttempts   = errors.New("too many attempts, please try again later")
+
+	// login throttling
+	ErrAccountLocked = errors.New("account is temporarily locked due to too many failed login attempts")
+
+	// tenancy
+	ErrNotStoreMember = errors.New("user is not a member of the store")
+
+	// pos
+	ErrInvalidTerminal = errors.New("terminal is not registered or was revoked")
+	ErrPINNotSet       = errors.New("no PIN is set")
+	ErrPINLocked       = errors.New("PIN login is temporarily locked due to too many failed attempts")
+
+	// oauth
+	ErrInvalidClient = errors.New("invalid client credentials")
+	ErrInvalidScope  = errors.New("requested scope is not allowed for the client")
+	// ErrInvalidGrant covers unknown, expired or already used authorization codes and PKCE mismatches
+	ErrInvalidGrant       = errors.New("invalid or expired authorization grant")
+	ErrUnauthorizedClient = errors.New("client is not allowed to use this grant type")
+	// authorization request errors
+	ErrInvalidRedirectURI      = errors.New("redirect_uri is not registered for the client")
+	ErrUnsupportedResponseType = errors.New("only the code response type is supported")
+
+	// passwordless login
+	ErrInvalidLoginCode = errors.New("invalid or expired sign-in link or code")
+
+	// passkeys
+	ErrInvalidPasskey      = errors.New("passkey could not be verified")
+	ErrPasskeyRegistration = errors.New("passkey registration could not be verified")
+	ErrPasskeyExists       = errors.New("passkey is already registered")
+
+	// federated login
+	ErrUnknownProvider       = errors.New("unknown identity provider")
+	ErrIdentityProvider      = errors.New("sign-in with the identity provider could not be verified")
+	ErrInvalidLoginState     = errors.New("invalid or expired sign-in state")
+	ErrIdentityAlreadyLinked = errors.New("external identity is already linked to an account")
+	// ErrFederatedEmailTaken is returned when a first federated login matches an existing account by
+	// email. Accounts are only linked by their owner, from the profile.
+	ErrFederatedEmailTaken = errors.New("an account with this email already exists, sign in and link the provider from your profile")
+	ErrLastSignInMethod    = errors.New("the last sign-in method of an account without a password can not be removed")
+
+	// email campaigns
+	ErrCampaignNotEditable = errors.New("campaign can not be changed in its current status")
 
 	// stock
 	ErrProductOutOfStock = errors.New("product out of stock")
 )
 
+// RetryAfterError decorates an error with the time after which the client may try again.
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// ConflictError reports which unique field of a user is already taken. It matches
+// ErrUserAlreadyExists with errors.Is.
+type ConflictError struct {
+	Field string
+}
+
+func (e *ConflictError) Error() string {
+	return ErrUserAlreadyExists.Error() + ": " + e.Field + " is already taken"
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrUserAlreadyExists
+}
+
 /*
 
 ### 📌 Error Handling Best Practice per Layer
@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileNotifier appends messages to a local file, handy to click through flows in development.
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileNotifier(path string) (*FileNotifier, error) {
+	if path == "" {
+		return nil, fmt.Errorf("notifier: file path is required for the file driver")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("notifier: failed to create directory for %s: %w", path, err)
+	}
+	return &FileNotifier{path: path}, nil
+}
+
+func (n *FileNotifier) Send(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("notifier: failed to open %s: %w", n.path, err)
+	}
+	defer f.Close()
+
+	var headers strings.Builder
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&headers, "%s: %s\n", name, msg.Headers[name])
+	}
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n%s\n%s\n\n---\n\n",
+		time.Now().Format(time.RFC1123Z), msg.To, msg.Subject, headers.String(), msg.Body)
+	if err != nil {
+		return fmt.Errorf("notifier: failed to write message: %w", err)
+	}
+	return nil
+}
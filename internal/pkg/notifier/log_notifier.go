@@ -0,0 +1,25 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogNotifier writes messages to the application log. Meant for local development only,
+// the body usually contains secrets such as reset links.
+type LogNotifier struct {
+	log *logrus.Logger
+}
+
+func NewLogNotifier(log *logrus.Logger) *LogNotifier {
+	return &LogNotifier{log: log}
+}
+
+func (n *LogNotifier) Send(ctx context.Context, msg Message) error {
+	n.log.WithFields(logrus.Fields{
+		"to":      msg.To,
+		"subject": msg.Subject,
+	}).Info(msg.Body)
+	return nil
+}
@@ -0,0 +1,55 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Supported values for Options.Driver.
+const (
+	DriverLog  = "log"
+	DriverFile = "file"
+	DriverSMTP = "smtp"
+)
+
+// Message is a single notification addressed to a user, e.g. a password reset mail.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	// Headers are extra mail headers such as List-Unsubscribe, drivers that are not mail ignore them.
+	Headers map[string]string
+}
+
+// Notifier delivers messages to users. Implementations must be safe for concurrent use.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type Options struct {
+	Driver   string
+	FilePath string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	// SMTPFrom is the sender address of every mail, e.g. "Shopeezy <no-reply@shopeezy.id>".
+	SMTPFrom string
+}
+
+// New returns the notifier selected by opts.Driver.
+func New(opts Options, log *logrus.Logger) (Notifier, error) {
+	switch opts.Driver {
+	case DriverLog, "":
+		return NewLogNotifier(log), nil
+	case DriverFile:
+		return NewFileNotifier(opts.FilePath)
+	case DriverSMTP:
+		return NewSMTPNotifier(opts.SMTPHost, opts.SMTPPort, opts.SMTPUsername, opts.SMTPPassword, opts.SMTPFrom)
+	default:
+		return nil, fmt.Errorf("unsupported notifier driver %q", opts.Driver)
+	}
+}
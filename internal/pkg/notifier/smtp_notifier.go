@@ -0,0 +1,137 @@
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpTimeout bounds a whole delivery when ctx has no earlier deadline.
+const smtpTimeout = 30 * time.Second
+
+// SMTPNotifier sends messages as plain text mails through an SMTP server. The connection is
+// upgraded with STARTTLS whenever the server offers it, and authentication is only attempted
+// on a TLS connection.
+type SMTPNotifier struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPNotifier(host string, port int, username string, password string, from string) (*SMTPNotifier, error) {
+	if host == "" {
+		return nil, fmt.Errorf("notifier: SMTP host is required for the smtp driver")
+	}
+	if from == "" {
+		return nil, fmt.Errorf("notifier: sender address is required for the smtp driver")
+	}
+	return &SMTPNotifier{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}, nil
+}
+
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	addr := net.JoinHostPort(n.host, strconv.Itoa(n.port))
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("notifier: failed to connect to %s: %w", addr, err)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return fmt.Errorf("notifier: failed to set deadline: %w", err)
+	}
+
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("notifier: failed to greet %s: %w", addr, err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+			return fmt.Errorf("notifier: STARTTLS failed: %w", err)
+		}
+	}
+	if n.username != "" {
+		// PlainAuth refuses to send the password over an unencrypted connection to a remote host
+		if err := c.Auth(smtp.PlainAuth("", n.username, n.password, n.host)); err != nil {
+			return fmt.Errorf("notifier: SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := c.Mail(n.from); err != nil {
+		return fmt.Errorf("notifier: MAIL FROM rejected: %w", err)
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("notifier: RCPT TO rejected: %w", err)
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("notifier: DATA rejected: %w", err)
+	}
+	if _, err := w.Write(n.compose(msg)); err != nil {
+		return fmt.Errorf("notifier: failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("notifier: message rejected: %w", err)
+	}
+
+	return c.Quit()
+}
+
+func (n *SMTPNotifier) compose(msg Message) []byte {
+	var b strings.Builder
+
+	writeHeader := func(name, value string) {
+		// Header values must not break out of their line
+		value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+		b.WriteString(name + ": " + value + "\r\n")
+	}
+
+	writeHeader("From", n.from)
+	writeHeader("To", msg.To)
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	writeHeader("MIME-Version", "1.0")
+	writeHeader("Content-Type", "text/plain; charset=utf-8")
+	writeHeader("Content-Transfer-Encoding", "8bit")
+
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeHeader(name, msg.Headers[name])
+	}
+
+	b.WriteString("\r\n")
+	// SMTP needs CRLF line endings, the client takes care of dot-stuffing
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+
+	return []byte(b.String())
+}
@@ -0,0 +1,163 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrNotConfirmed is returned when the broker nacks a message or does not confirm it in time.
+var ErrNotConfirmed = errors.New("rabbitmq: message not confirmed by the broker")
+
+// Publisher publishes persistent messages to a durable topic exchange with publisher confirms.
+// Publish only returns nil once the broker took responsibility for the message. The connection
+// is opened on first use and again after it broke, so a broker restart only fails the publishes
+// made while it was down.
+type Publisher struct {
+	url            string
+	exchange       string
+	confirmTimeout time.Duration
+
+	mu       sync.Mutex
+	conn     *amqp.Connection
+	channel  *amqp.Channel
+	confirms chan amqp.Confirmation
+	closed   chan *amqp.Error
+}
+
+func NewPublisher(url string, exchange string, confirmTimeout time.Duration) *Publisher {
+	return &Publisher{
+		url:            url,
+		exchange:       exchange,
+		confirmTimeout: confirmTimeout,
+	}
+}
+
+// Publish sends body to the exchange with routingKey and waits for the broker confirm.
+// messageID lets consumers drop the duplicates a retry after a lost confirm produces.
+func (p *Publisher) Publish(ctx context.Context, routingKey string, messageID string, body []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.connect(); err != nil {
+		return err
+	}
+
+	err := p.channel.Publish(
+		p.exchange,
+		routingKey,
+		false, // mandatory, an event nobody subscribed to yet is not an error
+		false, // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    messageID,
+			Type:         routingKey,
+			Timestamp:    time.Now(),
+			Body:         body,
+		})
+	if err != nil {
+		p.reset()
+		return fmt.Errorf("failed to publish to exchange '%s': %w", p.exchange, err)
+	}
+
+	timer := time.NewTimer(p.confirmTimeout)
+	defer timer.Stop()
+
+	select {
+	case confirm, ok := <-p.confirms:
+		if !ok {
+			p.reset()
+			return fmt.Errorf("%w: channel closed", ErrNotConfirmed)
+		}
+		if !confirm.Ack {
+			return ErrNotConfirmed
+		}
+		return nil
+	case <-timer.C:
+		// A late confirm would be taken for the next message, start over on a fresh channel
+		p.reset()
+		return fmt.Errorf("%w: no confirm within %s", ErrNotConfirmed, p.confirmTimeout)
+	case <-ctx.Done():
+		p.reset()
+		return ctx.Err()
+	}
+}
+
+// Close closes the channel and the connection.
+func (p *Publisher) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reset()
+}
+
+// connect opens a connection and a confirm mode channel unless the current ones are still open.
+func (p *Publisher) connect() error {
+	if p.channel != nil {
+		select {
+		case amqpErr := <-p.closed:
+			log.Printf("RabbitMQ publisher connection closed: %v", amqpErr)
+			p.reset()
+		default:
+			return nil
+		}
+	}
+
+	conn, err := amqp.Dial(p.url)
+	if err != nil {
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to open a channel: %w", err)
+	}
+
+	err = ch.ExchangeDeclare(
+		p.exchange, // name
+		"topic",    // kind
+		true,       // durable
+		false,      // auto-deleted
+		false,      // internal
+		false,      // no-wait
+		nil,        // arguments
+	)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to declare exchange '%s': %w", p.exchange, err)
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to put channel in confirm mode: %w", err)
+	}
+
+	p.conn = conn
+	p.channel = ch
+	p.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	// The channel is also closed when the connection breaks
+	p.closed = ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	log.Printf("RabbitMQ publisher connected to exchange '%s'", p.exchange)
+	return nil
+}
+
+func (p *Publisher) reset() {
+	if p.channel != nil {
+		_ = p.channel.Close()
+	}
+	if p.conn != nil {
+		_ = p.conn.Close()
+	}
+	p.conn = nil
+	p.channel = nil
+	p.confirms = nil
+	p.closed = nil
+}
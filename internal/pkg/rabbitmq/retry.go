@@ -0,0 +1,165 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	defaultRetryDelay     = 5 * time.Second
+	defaultMaxRetryDelay  = 5 * time.Minute
+	defaultConfirmTimeout = 5 * time.Second
+)
+
+// RetryDelay is the backoff before retry number retries+1: opts.RetryDelay, doubled for every
+// earlier retry and capped at opts.MaxRetryDelay.
+func RetryDelay(opts ConsumeOptions, retries int) time.Duration {
+	delay := opts.RetryDelay
+	for i := 0; i < retries && delay < opts.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > opts.MaxRetryDelay {
+		delay = opts.MaxRetryDelay
+	}
+	return delay
+}
+
+// retryQueueName is the delay queue holding the messages of queueName that wait delay before
+// their next attempt.
+func retryQueueName(queueName string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%s", queueName, delay)
+}
+
+// Retrier parks a failed message for delay before its next attempt.
+type Retrier interface {
+	// Publish returns nil once the broker holds the copy, only then the delivery may be acked.
+	Publish(ctx context.Context, delay time.Duration, msg amqp.Publishing) error
+}
+
+// retryPublisher parks failed messages in delay queues. A delay queue has no consumer, its
+// messages expire after the delay and the broker dead-letters them back to the work queue.
+// Each delay has a queue of its own, as a message only expires once it reached the head.
+type retryPublisher struct {
+	conn           *amqp.Connection
+	queueName      string
+	confirmTimeout time.Duration
+
+	mu       sync.Mutex
+	channel  *amqp.Channel
+	confirms chan amqp.Confirmation
+}
+
+// newRetryPublisher declares the delay queues of every retry opts allows and opens a confirm
+// mode channel to publish to them.
+func newRetryPublisher(conn *amqp.Connection, queueName string, opts ConsumeOptions) (*retryPublisher, error) {
+	var delays []time.Duration
+	for retries := 0; retries < opts.MaxRetries; retries++ {
+		delay := RetryDelay(opts, retries)
+		if len(delays) == 0 || delays[len(delays)-1] != delay {
+			delays = append(delays, delay)
+		}
+	}
+
+	p := &retryPublisher{conn: conn, queueName: queueName, confirmTimeout: opts.ConfirmTimeout}
+	if err := p.connect(); err != nil {
+		return nil, err
+	}
+
+	for _, delay := range delays {
+		_, err := p.channel.QueueDeclare(
+			retryQueueName(queueName, delay),
+			true,  // durable
+			false, // delete when unused
+			false, // exclusive
+			false, // no-wait
+			amqp.Table{ // expired messages go back to the work queue
+				"x-message-ttl":             delay.Milliseconds(),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": queueName,
+			},
+		)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("failed to declare a queue '%s': %w", retryQueueName(queueName, delay), err)
+		}
+	}
+	return p, nil
+}
+
+// Publish parks msg for delay and waits for the broker confirm, so the original delivery may
+// only be acked once Publish returned nil.
+func (p *retryPublisher) Publish(ctx context.Context, delay time.Duration, msg amqp.Publishing) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.connect(); err != nil {
+		return err
+	}
+
+	queue := retryQueueName(p.queueName, delay)
+	if err := p.channel.Publish("", queue, false, false, msg); err != nil {
+		p.reset()
+		return fmt.Errorf("failed to publish to queue '%s': %w", queue, err)
+	}
+
+	timer := time.NewTimer(p.confirmTimeout)
+	defer timer.Stop()
+
+	select {
+	case confirm, ok := <-p.confirms:
+		if !ok {
+			p.reset()
+			return fmt.Errorf("%w: channel closed", ErrNotConfirmed)
+		}
+		if !confirm.Ack {
+			return ErrNotConfirmed
+		}
+		return nil
+	case <-timer.C:
+		// A late confirm would be taken for the next message, start over on a fresh channel
+		p.reset()
+		return fmt.Errorf("%w: no confirm within %s", ErrNotConfirmed, p.confirmTimeout)
+	case <-ctx.Done():
+		p.reset()
+		return ctx.Err()
+	}
+}
+
+func (p *retryPublisher) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reset()
+}
+
+// connect opens a confirm mode channel on the consumer's connection unless one is open.
+func (p *retryPublisher) connect() error {
+	if p.channel != nil {
+		return nil
+	}
+
+	ch, err := p.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open a channel: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		return fmt.Errorf("failed to put channel in confirm mode: %w", err)
+	}
+
+	p.channel = ch
+	p.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	return nil
+}
+
+func (p *retryPublisher) reset() {
+	if p.channel != nil {
+		_ = p.channel.Close()
+	}
+	p.channel = nil
+	p.confirms = nil
+}
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, param *db.CreateAPIKeyParams) (*db.ApiKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*db.ApiKey, error)
+	GetUserAPIKey(ctx context.Context, param *db.GetUserAPIKeyParams) (*db.ApiKey, error)
+	ListUserAPIKeys(ctx context.Context, userID uuid.UUID) ([]db.ApiKey, error)
+	CountActiveAPIKeys(ctx context.Context, userID uuid.UUID) (int64, error)
+	RenameAPIKey(ctx context.Context, param *db.RenameAPIKeyParams) (*db.ApiKey, error)
+	RevokeAPIKey(ctx context.Context, param *db.RevokeAPIKeyParams) (bool, error)
+	TouchAPIKey(ctx context.Context, id uuid.UUID) error
+}
+
+type apiKeyRepository struct {
+	db  *db.Queries
+	log *logrus.Logger
+}
+
+func NewAPIKeyRepository(sqlcQueries *db.Queries, log *logrus.Logger) APIKeyRepository {
+	return &apiKeyRepository{db: sqlcQueries, log: log}
+}
+
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, param *db.CreateAPIKeyParams) (*db.ApiKey, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.CreateAPIKey(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *apiKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*db.ApiKey, error) {
+	res, err := r.db.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *apiKeyRepository) GetUserAPIKey(ctx context.Context, param *db.GetUserAPIKeyParams) (*db.ApiKey, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.GetUserAPIKey(ctx, *param)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: api key %s", apperrors.ErrNotFound, param.ID)
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *apiKeyRepository) ListUserAPIKeys(ctx context.Context, userID uuid.UUID) ([]db.ApiKey, error) {
+	res, err := r.db.ListUserAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	return res, nil
+}
+
+func (r *apiKeyRepository) CountActiveAPIKeys(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := r.db.CountActiveAPIKeys(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count api keys: %w", err)
+	}
+	return count, nil
+}
+
+func (r *apiKeyRepository) RenameAPIKey(ctx context.Context, param *db.RenameAPIKeyParams) (*db.ApiKey, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.RenameAPIKey(ctx, *param)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: api key %s", apperrors.ErrNotFound, param.ID)
+		}
+		return nil, fmt.Errorf("failed to rename api key: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *apiKeyRepository) RevokeAPIKey(ctx context.Context, param *db.RevokeAPIKeyParams) (bool, error) {
+	if param == nil {
+		return false, apperrors.ErrInvalidQuery
+	}
+
+	rows, err := r.db.RevokeAPIKey(ctx, *param)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return rows > 0, nil
+}
+
+func (r *apiKeyRepository) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	if err := r.db.TouchAPIKey(ctx, id); err != nil {
+		return fmt.Errorf("failed to update api key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/redisclient"
+	"github.com/go-redis/redis/v8"
+)
+
+// AttemptRepository counts attempts (failed codes, failed logins, ...) in fixed Redis windows.
+type AttemptRepository interface {
+	// Increment adds one attempt and returns the new count. The window starts at the first attempt.
+	Increment(ctx context.Context, key string, window time.Duration) (int64, error)
+	Count(ctx context.Context, key string) (int64, error)
+	// TTL returns how long the current window of the key still lasts, zero if there is none.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Reset(ctx context.Context, key string) error
+}
+
+type attemptRepository struct {
+	redisClient *redisclient.RedisClient
+}
+
+func NewAttemptRepository(redisClient *redisclient.RedisClient) AttemptRepository {
+	return &attemptRepository{redisClient: redisClient}
+}
+
+func attemptKey(key string) string {
+	return fmt.Sprintf("attempts:%s", key)
+}
+
+// incrementScript starts the expiry window on the first attempt only, so retries do not extend it.
+var incrementScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+func (r *attemptRepository) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
+	count, err := incrementScript.Run(ctx, r.redisClient.Client, []string{attemptKey(key)}, window.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count attempt: %w", err)
+	}
+	return count, nil
+}
+
+func (r *attemptRepository) Count(ctx context.Context, key string) (int64, error) {
+	count, err := r.redisClient.Client.Get(ctx, attemptKey(key)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read attempts: %w", err)
+	}
+	return count, nil
+}
+
+func (r *attemptRepository) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.redisClient.Client.PTTL(ctx, attemptKey(key)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read attempt window: %w", err)
+	}
+	// Negative values mean the key does not exist or has no expiry
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (r *attemptRepository) Reset(ctx context.Context, key string) error {
+	return r.redisClient.Client.Del(ctx, attemptKey(key)).Err()
+}
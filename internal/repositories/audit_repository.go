@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+// AuditRepository stores the security audit log. The table is append-only, a trigger rejects
+// updates and every delete that does not come from DeleteBefore.
+type AuditRepository interface {
+	CreateEvent(ctx context.Context, param *db.CreateAuditEventParams) error
+	ListEvents(ctx context.Context, param *db.ListAuditEventsParams) ([]db.AuditEvent, error)
+	// DeleteBefore removes up to batchSize events created before createdBefore.
+	DeleteBefore(ctx context.Context, createdBefore time.Time, batchSize int32) (int64, error)
+	CountBefore(ctx context.Context, createdBefore time.Time) (int64, error)
+}
+
+type auditRepository struct {
+	sqlDB *sql.DB
+	db    *db.Queries
+	log   *logrus.Logger
+}
+
+func NewAuditRepository(sqlDB *sql.DB, sqlcQueries *db.Queries, log *logrus.Logger) AuditRepository {
+	return &auditRepository{sqlDB: sqlDB, db: sqlcQueries, log: log}
+}
+
+func (r *auditRepository) CreateEvent(ctx context.Context, param *db.CreateAuditEventParams) error {
+	if param == nil {
+		return apperrors.ErrInvalidQuery
+	}
+
+	if err := r.db.CreateAuditEvent(ctx, *param); err != nil {
+		return fmt.Errorf("failed to create audit event: %w", err)
+	}
+
+	return nil
+}
+
+func (r *auditRepository) ListEvents(ctx context.Context, param *db.ListAuditEventsParams) ([]db.AuditEvent, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.ListAuditEvents(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	return res, nil
+}
+
+func (r *auditRepository) DeleteBefore(ctx context.Context, createdBefore time.Time, batchSize int32) (int64, error) {
+	var rows int64
+
+	err := inTx(ctx, r.sqlDB, r.db, func(q *db.Queries) error {
+		// Lifts the append-only trigger for this transaction only
+		if err := q.AllowAuditEventPrune(ctx); err != nil {
+			return fmt.Errorf("failed to allow audit event prune: %w", err)
+		}
+
+		var err error
+		rows, err = q.DeleteAuditEventsBefore(ctx, db.DeleteAuditEventsBeforeParams{
+			CreatedBefore: createdBefore,
+			BatchSize:     batchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete audit events: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *auditRepository) CountBefore(ctx context.Context, createdBefore time.Time) (int64, error) {
+	count, err := r.db.CountAuditEventsBefore(ctx, createdBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count audit events: %w", err)
+	}
+
+	return count, nil
+}
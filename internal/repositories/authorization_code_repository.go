@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+type AuthorizationCodeRepository interface {
+	CreateAuthorizationCode(ctx context.Context, param *db.CreateAuthorizationCodeParams) (*db.OauthAuthorizationCode, error)
+	// ConsumeAuthorizationCode marks an unused, unexpired code as used and returns it.
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*db.OauthAuthorizationCode, error)
+	DeleteExpired(ctx context.Context, expiresBefore time.Time) (int64, error)
+}
+
+type authorizationCodeRepository struct {
+	db  *db.Queries
+	log *logrus.Logger
+}
+
+func NewAuthorizationCodeRepository(sqlcQueries *db.Queries, log *logrus.Logger) AuthorizationCodeRepository {
+	return &authorizationCodeRepository{db: sqlcQueries, log: log}
+}
+
+func (r *authorizationCodeRepository) CreateAuthorizationCode(ctx context.Context, param *db.CreateAuthorizationCodeParams) (*db.OauthAuthorizationCode, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.CreateAuthorizationCode(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create authorization code: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *authorizationCodeRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*db.OauthAuthorizationCode, error) {
+	res, err := r.db.ConsumeAuthorizationCode(ctx, codeHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrInvalidGrant
+		}
+		return nil, fmt.Errorf("failed to consume authorization code: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *authorizationCodeRepository) DeleteExpired(ctx context.Context, expiresBefore time.Time) (int64, error) {
+	rows, err := r.db.DeleteExpiredAuthorizationCodes(ctx, expiresBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired authorization codes: %w", err)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,279 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+// CampaignRepository stores email campaigns and their per-recipient deliveries.
+type CampaignRepository interface {
+	CreateCampaign(ctx context.Context, param *db.CreateEmailCampaignParams) (*db.EmailCampaign, error)
+	GetCampaign(ctx context.Context, id uuid.UUID) (*db.EmailCampaign, error)
+	ListCampaigns(ctx context.Context, param *db.ListEmailCampaignsParams) ([]db.EmailCampaign, error)
+	// ScheduleCampaign and CancelCampaign return ErrCampaignNotEditable when the campaign is
+	// past the statuses they apply to.
+	ScheduleCampaign(ctx context.Context, param *db.ScheduleEmailCampaignParams) (*db.EmailCampaign, error)
+	// CancelCampaign also skips the deliveries that were not sent yet.
+	CancelCampaign(ctx context.Context, id uuid.UUID) (*db.EmailCampaign, error)
+	ClaimDueCampaigns(ctx context.Context, param *db.ClaimDueEmailCampaignsParams) ([]db.EmailCampaign, error)
+	MarkDispatched(ctx context.Context, param *db.MarkEmailCampaignDispatchedParams) error
+	// CompleteCampaign marks a dispatched campaign as sent once no delivery is pending anymore.
+	CompleteCampaign(ctx context.Context, id uuid.UUID) (bool, error)
+
+	ListRecipients(ctx context.Context, param *db.ListEmailCampaignRecipientsParams) ([]db.ListEmailCampaignRecipientsRow, error)
+	CountRecipients(ctx context.Context, param *db.CountEmailCampaignRecipientsParams) (int64, error)
+
+	CreateDeliveries(ctx context.Context, param *db.CreateEmailCampaignDeliveriesParams) (int64, error)
+	ListPendingDeliveries(ctx context.Context, param *db.ListPendingEmailCampaignDeliveriesParams) ([]uuid.UUID, error)
+	// ClaimDelivery takes a pending delivery of a campaign that is still sending. It returns
+	// ErrNotFound when the delivery was already handled or the campaign was cancelled.
+	ClaimDelivery(ctx context.Context, id uuid.UUID) (*db.EmailCampaignDelivery, error)
+	FinishDelivery(ctx context.Context, param *db.FinishEmailCampaignDeliveryParams) error
+	CountDeliveries(ctx context.Context, campaignID uuid.UUID) ([]db.CountEmailCampaignDeliveriesRow, error)
+	// DeleteFinishedDeliveries removes up to batchSize deliveries of campaigns that were sent
+	// or cancelled before finishedBefore. The campaigns themselves are kept.
+	DeleteFinishedDeliveries(ctx context.Context, finishedBefore time.Time, batchSize int32) (int64, error)
+	CountFinishedDeliveries(ctx context.Context, finishedBefore time.Time) (int64, error)
+}
+
+type campaignRepository struct {
+	sqlDB *sql.DB
+	db    *db.Queries
+	log   *logrus.Logger
+}
+
+func NewCampaignRepository(sqlDB *sql.DB, sqlcQueries *db.Queries, log *logrus.Logger) CampaignRepository {
+	return &campaignRepository{sqlDB: sqlDB, db: sqlcQueries, log: log}
+}
+
+func (r *campaignRepository) CreateCampaign(ctx context.Context, param *db.CreateEmailCampaignParams) (*db.EmailCampaign, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.CreateEmailCampaign(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create campaign: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *campaignRepository) GetCampaign(ctx context.Context, id uuid.UUID) (*db.EmailCampaign, error) {
+	res, err := r.db.GetEmailCampaign(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: campaign %s", apperrors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get campaign: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *campaignRepository) ListCampaigns(ctx context.Context, param *db.ListEmailCampaignsParams) ([]db.EmailCampaign, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.ListEmailCampaigns(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list campaigns: %w", err)
+	}
+
+	return res, nil
+}
+
+func (r *campaignRepository) ScheduleCampaign(ctx context.Context, param *db.ScheduleEmailCampaignParams) (*db.EmailCampaign, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.ScheduleEmailCampaign(ctx, *param)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrCampaignNotEditable
+		}
+		return nil, fmt.Errorf("failed to schedule campaign: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *campaignRepository) CancelCampaign(ctx context.Context, id uuid.UUID) (*db.EmailCampaign, error) {
+	var res db.EmailCampaign
+
+	err := inTx(ctx, r.sqlDB, r.db, func(q *db.Queries) error {
+		var err error
+		res, err = q.CancelEmailCampaign(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperrors.ErrCampaignNotEditable
+			}
+			return fmt.Errorf("failed to cancel campaign: %w", err)
+		}
+
+		_, err = q.SkipPendingEmailCampaignDeliveries(ctx, db.SkipPendingEmailCampaignDeliveriesParams{
+			CampaignID: id,
+			Error:      "campaign cancelled",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to skip pending deliveries: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (r *campaignRepository) ClaimDueCampaigns(ctx context.Context, param *db.ClaimDueEmailCampaignsParams) ([]db.EmailCampaign, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.ClaimDueEmailCampaigns(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim due campaigns: %w", err)
+	}
+
+	return res, nil
+}
+
+func (r *campaignRepository) MarkDispatched(ctx context.Context, param *db.MarkEmailCampaignDispatchedParams) error {
+	if param == nil {
+		return apperrors.ErrInvalidQuery
+	}
+
+	if err := r.db.MarkEmailCampaignDispatched(ctx, *param); err != nil {
+		return fmt.Errorf("failed to mark campaign dispatched: %w", err)
+	}
+
+	return nil
+}
+
+func (r *campaignRepository) CompleteCampaign(ctx context.Context, id uuid.UUID) (bool, error) {
+	rows, err := r.db.CompleteEmailCampaign(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to complete campaign: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+func (r *campaignRepository) ListRecipients(ctx context.Context, param *db.ListEmailCampaignRecipientsParams) ([]db.ListEmailCampaignRecipientsRow, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.ListEmailCampaignRecipients(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list campaign recipients: %w", err)
+	}
+
+	return res, nil
+}
+
+func (r *campaignRepository) CountRecipients(ctx context.Context, param *db.CountEmailCampaignRecipientsParams) (int64, error) {
+	if param == nil {
+		return 0, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.CountEmailCampaignRecipients(ctx, *param)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count campaign recipients: %w", err)
+	}
+
+	return res, nil
+}
+
+func (r *campaignRepository) CreateDeliveries(ctx context.Context, param *db.CreateEmailCampaignDeliveriesParams) (int64, error) {
+	if param == nil {
+		return 0, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.CreateEmailCampaignDeliveries(ctx, *param)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create campaign deliveries: %w", err)
+	}
+
+	return res, nil
+}
+
+func (r *campaignRepository) ListPendingDeliveries(ctx context.Context, param *db.ListPendingEmailCampaignDeliveriesParams) ([]uuid.UUID, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.ListPendingEmailCampaignDeliveries(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending deliveries: %w", err)
+	}
+
+	return res, nil
+}
+
+func (r *campaignRepository) ClaimDelivery(ctx context.Context, id uuid.UUID) (*db.EmailCampaignDelivery, error) {
+	res, err := r.db.ClaimEmailCampaignDelivery(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: pending delivery %s", apperrors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to claim delivery: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *campaignRepository) FinishDelivery(ctx context.Context, param *db.FinishEmailCampaignDeliveryParams) error {
+	if param == nil {
+		return apperrors.ErrInvalidQuery
+	}
+
+	if err := r.db.FinishEmailCampaignDelivery(ctx, *param); err != nil {
+		return fmt.Errorf("failed to finish delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (r *campaignRepository) CountDeliveries(ctx context.Context, campaignID uuid.UUID) ([]db.CountEmailCampaignDeliveriesRow, error) {
+	res, err := r.db.CountEmailCampaignDeliveries(ctx, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count deliveries: %w", err)
+	}
+
+	return res, nil
+}
+
+func (r *campaignRepository) DeleteFinishedDeliveries(ctx context.Context, finishedBefore time.Time, batchSize int32) (int64, error) {
+	rows, err := r.db.DeleteFinishedEmailCampaignDeliveries(ctx, db.DeleteFinishedEmailCampaignDeliveriesParams{
+		FinishedBefore: sql.NullTime{Time: finishedBefore, Valid: true},
+		BatchSize:      batchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete finished deliveries: %w", err)
+	}
+
+	return rows, nil
+}
+
+func (r *campaignRepository) CountFinishedDeliveries(ctx context.Context, finishedBefore time.Time) (int64, error) {
+	count, err := r.db.CountFinishedEmailCampaignDeliveries(ctx, sql.NullTime{Time: finishedBefore, Valid: true})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count finished deliveries: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/redisclient"
+)
+
+// FederationStateRepository keeps the state of federated logins that are waiting for the
+// provider callback. A state can be taken only once.
+type FederationStateRepository interface {
+	SaveState(ctx context.Context, state string, value *models.FederationState, ttl time.Duration) error
+	TakeState(ctx context.Context, state string) (*models.FederationState, error)
+}
+
+type federationStateRepository struct {
+	redisClient *redisclient.RedisClient
+}
+
+func NewFederationStateRepository(redisClient *redisclient.RedisClient) FederationStateRepository {
+	return &federationStateRepository{redisClient: redisClient}
+}
+
+func federationStateKey(state string) string {
+	return fmt.Sprintf("federation:state:%s", state)
+}
+
+func (r *federationStateRepository) SaveState(ctx context.Context, state string, value *models.FederationState, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal federation state: %w", err)
+	}
+
+	if err := r.redisClient.Client.Set(ctx, federationStateKey(state), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store federation state: %w", err)
+	}
+	return nil
+}
+
+func (r *federationStateRepository) TakeState(ctx context.Context, state string) (*models.FederationState, error) {
+	val, err := r.redisClient.Client.GetDel(ctx, federationStateKey(state)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, apperrors.ErrInvalidLoginState
+		}
+		return nil, fmt.Errorf("failed to take federation state: %w", err)
+	}
+
+	var value models.FederationState
+	if err := json.Unmarshal([]byte(val), &value); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal federation state: %w", err)
+	}
+	return &value, nil
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+// IdentityRepository stores the external identities (provider + subject) linked to users.
+type IdentityRepository interface {
+	CreateIdentity(ctx context.Context, param *db.CreateUserIdentityParams) (*db.UserIdentity, error)
+	GetIdentity(ctx context.Context, provider string, subject string) (*db.UserIdentity, error)
+	ListIdentities(ctx context.Context, userID uuid.UUID) ([]db.UserIdentity, error)
+	CountIdentities(ctx context.Context, userID uuid.UUID) (int64, error)
+	TouchIdentity(ctx context.Context, param *db.TouchUserIdentityParams) error
+	DeleteIdentity(ctx context.Context, param *db.DeleteUserIdentityParams) (bool, error)
+}
+
+type identityRepository struct {
+	db  *db.Queries
+	log *logrus.Logger
+}
+
+func NewIdentityRepository(sqlcQueries *db.Queries, log *logrus.Logger) IdentityRepository {
+	return &identityRepository{db: sqlcQueries, log: log}
+}
+
+func (r *identityRepository) CreateIdentity(ctx context.Context, param *db.CreateUserIdentityParams) (*db.UserIdentity, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.CreateUserIdentity(ctx, *param)
+	if err != nil {
+		// Either the subject belongs to another user or the user already has an identity at the provider
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, apperrors.ErrIdentityAlreadyLinked
+		}
+		return nil, fmt.Errorf("failed to create identity: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *identityRepository) GetIdentity(ctx context.Context, provider string, subject string) (*db.UserIdentity, error) {
+	res, err := r.db.GetUserIdentity(ctx, db.GetUserIdentityParams{Provider: provider, Subject: subject})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s identity", apperrors.ErrNotFound, provider)
+		}
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *identityRepository) ListIdentities(ctx context.Context, userID uuid.UUID) ([]db.UserIdentity, error) {
+	res, err := r.db.ListUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	return res, nil
+}
+
+func (r *identityRepository) CountIdentities(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := r.db.CountUserIdentities(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count identities: %w", err)
+	}
+	return count, nil
+}
+
+func (r *identityRepository) TouchIdentity(ctx context.Context, param *db.TouchUserIdentityParams) error {
+	if param == nil {
+		return apperrors.ErrInvalidQuery
+	}
+
+	if err := r.db.TouchUserIdentity(ctx, *param); err != nil {
+		return fmt.Errorf("failed to update identity: %w", err)
+	}
+	return nil
+}
+
+func (r *identityRepository) DeleteIdentity(ctx context.Context, param *db.DeleteUserIdentityParams) (bool, error) {
+	if param == nil {
+		return false, apperrors.ErrInvalidQuery
+	}
+
+	rows, err := r.db.DeleteUserIdentity(ctx, *param)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete identity: %w", err)
+	}
+	return rows > 0, nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/redisclient"
+	"github.com/google/uuid"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -13,8 +14,19 @@ import (
 type JWTBlacklistRepository interface {
 	AddToBlacklist(ctx context.Context, jti string, expiration time.Duration) error
 	IsBlacklisted(ctx context.Context, jti string) (bool, error)
+	// AddToBlacklistIfAbsent blacklists the JTI unless it already was, in a single step.
+	AddToBlacklistIfAbsent(ctx context.Context, jti string, expiration time.Duration) (bool, error)
+	RemoveFromBlacklist(ctx context.Context, jti string) error
+	BlacklistSession(ctx context.Context, sessionID string, expiration time.Duration) error
+	IsSessionBlacklisted(ctx context.Context, sessionID string) (bool, error)
+	SetTokensValidAfter(ctx context.Context, userID uuid.UUID, validAfter time.Time, expiration time.Duration) error
+	GetTokensValidAfter(ctx context.Context, userID uuid.UUID) (time.Time, error)
 }
 
+// secondsCutoffLimit tells tokens_valid_after values in seconds from ones in milliseconds, in
+// milliseconds it is early 1973.
+const secondsCutoffLimit = 100_000_000_000
+
 type jwtBlacklistRepository struct {
 	redisClient *redisclient.RedisClient
 }
@@ -35,6 +47,66 @@ func (r *jwtBlacklistRepository) AddToBlacklist(ctx context.Context, jti string,
 // IsBlacklisted checks if the JWT ID (JTI) is on the Redis blacklist
 func (r *jwtBlacklistRepository) IsBlacklisted(ctx context.Context, jti string) (bool, error) {
 	key := fmt.Sprintf("jwt:blacklist:%s", jti)
+	return r.exists(ctx, key)
+}
+
+// AddToBlacklistIfAbsent returns false when the JTI was already blacklisted, so of concurrent
+// callers consuming the same single-use token only one wins.
+func (r *jwtBlacklistRepository) AddToBlacklistIfAbsent(ctx context.Context, jti string, expiration time.Duration) (bool, error) {
+	key := fmt.Sprintf("jwt:blacklist:%s", jti)
+	added, err := r.redisClient.Client.SetNX(ctx, key, "blacklisted", expiration).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to blacklist jti: %w", err)
+	}
+	return added, nil
+}
+
+// RemoveFromBlacklist takes the JTI off the blacklist again.
+func (r *jwtBlacklistRepository) RemoveFromBlacklist(ctx context.Context, jti string) error {
+	key := fmt.Sprintf("jwt:blacklist:%s", jti)
+	return r.redisClient.Client.Del(ctx, key).Err()
+}
+
+// BlacklistSession revokes every access token carrying the given session ID (sid claim).
+// The expiration should cover the lifetime of the longest access token issued for the session.
+func (r *jwtBlacklistRepository) BlacklistSession(ctx context.Context, sessionID string, expiration time.Duration) error {
+	key := fmt.Sprintf("jwt:blacklist:session:%s", sessionID)
+	return r.redisClient.Client.Set(ctx, key, "blacklisted", expiration).Err()
+}
+
+// IsSessionBlacklisted checks if the session ID (sid claim) is on the Redis blacklist
+func (r *jwtBlacklistRepository) IsSessionBlacklisted(ctx context.Context, sessionID string) (bool, error) {
+	key := fmt.Sprintf("jwt:blacklist:session:%s", sessionID)
+	return r.exists(ctx, key)
+}
+
+// SetTokensValidAfter makes every token of the user issued before validAfter invalid. The cut-off
+// is kept in milliseconds so tokens issued later in the same second can be told apart.
+// The key only has to outlive the longest access token, older tokens are expired anyway.
+func (r *jwtBlacklistRepository) SetTokensValidAfter(ctx context.Context, userID uuid.UUID, validAfter time.Time, expiration time.Duration) error {
+	key := fmt.Sprintf("jwt:valid_after:%s", userID)
+	return r.redisClient.Client.Set(ctx, key, validAfter.UnixMilli(), expiration).Err()
+}
+
+// GetTokensValidAfter returns the zero time when no cut-off is set for the user.
+func (r *jwtBlacklistRepository) GetTokensValidAfter(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	key := fmt.Sprintf("jwt:valid_after:%s", userID)
+	val, err := r.redisClient.Client.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read tokens_valid_after from Redis: %w", err)
+	}
+	// Cut-offs written before the switch to milliseconds are in seconds, they expire with the
+	// longest access token
+	if val < secondsCutoffLimit {
+		return time.Unix(val, 0), nil
+	}
+	return time.UnixMilli(val), nil
+}
+
+func (r *jwtBlacklistRepository) exists(ctx context.Context, key string) (bool, error) {
 	_, err := r.redisClient.Client.Get(ctx, key).Result()
 	if err == nil {
 		return true, nil // Found in blacklist
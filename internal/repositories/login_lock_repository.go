@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/redisclient"
+)
+
+// LoginLockRepository stores temporary account lockouts after repeated failed logins.
+type LoginLockRepository interface {
+	Lock(ctx context.Context, username string, duration time.Duration) error
+	// LockedFor returns the remaining lock time, zero if the account is not locked.
+	LockedFor(ctx context.Context, username string) (time.Duration, error)
+	Unlock(ctx context.Context, username string) error
+}
+
+type loginLockRepository struct {
+	redisClient *redisclient.RedisClient
+}
+
+func NewLoginLockRepository(redisClient *redisclient.RedisClient) LoginLockRepository {
+	return &loginLockRepository{redisClient: redisClient}
+}
+
+func loginLockKey(username string) string {
+	return fmt.Sprintf("login:lock:%s", username)
+}
+
+func (r *loginLockRepository) Lock(ctx context.Context, username string, duration time.Duration) error {
+	return r.redisClient.Client.Set(ctx, loginLockKey(username), time.Now().Add(duration).Unix(), duration).Err()
+}
+
+func (r *loginLockRepository) LockedFor(ctx context.Context, username string) (time.Duration, error) {
+	ttl, err := r.redisClient.Client.PTTL(ctx, loginLockKey(username)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read login lock: %w", err)
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (r *loginLockRepository) Unlock(ctx context.Context, username string) error {
+	return r.redisClient.Client.Del(ctx, loginLockKey(username)).Err()
+}
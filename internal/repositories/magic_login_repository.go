@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/redisclient"
+)
+
+// MagicLoginRepository keeps the pending passwordless login of a user. A new challenge replaces
+// the previous one, so only the latest link or code works.
+type MagicLoginRepository interface {
+	SaveChallenge(ctx context.Context, userID uuid.UUID, challenge *models.MagicLoginChallenge, ttl time.Duration) error
+	GetChallenge(ctx context.Context, userID uuid.UUID) (*models.MagicLoginChallenge, error)
+	// DeleteChallenge reports whether the challenge still existed, only one caller can win it.
+	DeleteChallenge(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+type magicLoginRepository struct {
+	redisClient *redisclient.RedisClient
+}
+
+func NewMagicLoginRepository(redisClient *redisclient.RedisClient) MagicLoginRepository {
+	return &magicLoginRepository{redisClient: redisClient}
+}
+
+func magicLoginKey(userID uuid.UUID) string {
+	return fmt.Sprintf("login:magic:%s", userID)
+}
+
+func (r *magicLoginRepository) SaveChallenge(ctx context.Context, userID uuid.UUID, challenge *models.MagicLoginChallenge, ttl time.Duration) error {
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return fmt.Errorf("failed to marshal magic login challenge: %w", err)
+	}
+
+	if err := r.redisClient.Client.Set(ctx, magicLoginKey(userID), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store magic login challenge: %w", err)
+	}
+	return nil
+}
+
+func (r *magicLoginRepository) GetChallenge(ctx context.Context, userID uuid.UUID) (*models.MagicLoginChallenge, error) {
+	val, err := r.redisClient.Client.Get(ctx, magicLoginKey(userID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, apperrors.ErrInvalidLoginCode
+		}
+		return nil, fmt.Errorf("failed to get magic login challenge: %w", err)
+	}
+
+	var challenge models.MagicLoginChallenge
+	if err := json.Unmarshal([]byte(val), &challenge); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal magic login challenge: %w", err)
+	}
+	return &challenge, nil
+}
+
+func (r *magicLoginRepository) DeleteChallenge(ctx context.Context, userID uuid.UUID) (bool, error) {
+	deleted, err := r.redisClient.Client.Del(ctx, magicLoginKey(userID)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete magic login challenge: %w", err)
+	}
+	return deleted > 0, nil
+}
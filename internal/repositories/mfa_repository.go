@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+type MFARepository interface {
+	UpsertUserMFA(ctx context.Context, param *db.UpsertUserMFAParams) (*db.UserMfa, error)
+	GetUserMFA(ctx context.Context, userID uuid.UUID) (*db.UserMfa, error)
+	EnableUserMFA(ctx context.Context, param *db.EnableUserMFAParams) (*db.UserMfa, error)
+	UpdateLastUsedStep(ctx context.Context, param *db.UpdateMFALastUsedStepParams) (bool, error)
+	DeleteUserMFA(ctx context.Context, userID uuid.UUID) error
+	ReplaceRecoveryCodes(ctx context.Context, param *db.ReplaceRecoveryCodesParams) error
+	UseRecoveryCode(ctx context.Context, param *db.UseRecoveryCodeParams) (bool, error)
+}
+
+type mfaRepository struct {
+	db  *db.Queries
+	log *logrus.Logger
+}
+
+func NewMFARepository(sqlcQueries *db.Queries, log *logrus.Logger) MFARepository {
+	return &mfaRepository{db: sqlcQueries, log: log}
+}
+
+func (r *mfaRepository) UpsertUserMFA(ctx context.Context, param *db.UpsertUserMFAParams) (*db.UserMfa, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.UpsertUserMFA(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store mfa secret: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *mfaRepository) GetUserMFA(ctx context.Context, userID uuid.UUID) (*db.UserMfa, error) {
+	res, err := r.db.GetUserMFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrMFANotEnrolled
+		}
+		return nil, fmt.Errorf("failed to get mfa settings: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *mfaRepository) EnableUserMFA(ctx context.Context, param *db.EnableUserMFAParams) (*db.UserMfa, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.EnableUserMFA(ctx, *param)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrMFAAlreadyEnabled
+		}
+		return nil, fmt.Errorf("failed to enable mfa: %w", err)
+	}
+
+	return &res, nil
+}
+
+// UpdateLastUsedStep returns false when the step was already used, i.e. the code is a replay.
+func (r *mfaRepository) UpdateLastUsedStep(ctx context.Context, param *db.UpdateMFALastUsedStepParams) (bool, error) {
+	if param == nil {
+		return false, apperrors.ErrInvalidQuery
+	}
+
+	rows, err := r.db.UpdateMFALastUsedStep(ctx, *param)
+	if err != nil {
+		return false, fmt.Errorf("failed to update mfa step: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// DeleteUserMFA removes the TOTP secret and every recovery code of the user.
+func (r *mfaRepository) DeleteUserMFA(ctx context.Context, userID uuid.UUID) error {
+	if err := r.db.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if err := r.db.DeleteUserMFA(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete mfa settings: %w", err)
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, param *db.ReplaceRecoveryCodesParams) error {
+	if param == nil {
+		return apperrors.ErrInvalidQuery
+	}
+
+	if err := r.db.ReplaceRecoveryCodes(ctx, *param); err != nil {
+		return fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode consumes a recovery code, returning false if it does not exist or was used.
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, param *db.UseRecoveryCodeParams) (bool, error) {
+	if param == nil {
+		return false, apperrors.ErrInvalidQuery
+	}
+
+	rows, err := r.db.UseRecoveryCode(ctx, *param)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	return rows > 0, nil
+}
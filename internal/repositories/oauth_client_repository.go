@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+type OAuthClientRepository interface {
+	CreateClient(ctx context.Context, param *db.CreateOAuthClientParams) (*db.OauthClient, error)
+	GetClient(ctx context.Context, id uuid.UUID) (*db.OauthClient, error)
+	GetClientByClientID(ctx context.Context, clientID string) (*db.OauthClient, error)
+	ListClients(ctx context.Context) ([]db.OauthClient, error)
+	RevokeClient(ctx context.Context, id uuid.UUID) (bool, error)
+	TouchClient(ctx context.Context, id uuid.UUID) error
+	UpdateClient(ctx context.Context, param *db.UpdateOAuthClientParams) (*db.OauthClient, error)
+}
+
+type oauthClientRepository struct {
+	db  *db.Queries
+	log *logrus.Logger
+}
+
+func NewOAuthClientRepository(sqlcQueries *db.Queries, log *logrus.Logger) OAuthClientRepository {
+	return &oauthClientRepository{db: sqlcQueries, log: log}
+}
+
+func (r *oauthClientRepository) CreateClient(ctx context.Context, param *db.CreateOAuthClientParams) (*db.OauthClient, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.CreateOAuthClient(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create oauth client: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *oauthClientRepository) GetClient(ctx context.Context, id uuid.UUID) (*db.OauthClient, error) {
+	res, err := r.db.GetOAuthClient(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: oauth client %s", apperrors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get oauth client: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *oauthClientRepository) GetClientByClientID(ctx context.Context, clientID string) (*db.OauthClient, error) {
+	res, err := r.db.GetOAuthClientByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrInvalidClient
+		}
+		return nil, fmt.Errorf("failed to get oauth client: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *oauthClientRepository) ListClients(ctx context.Context) ([]db.OauthClient, error) {
+	res, err := r.db.ListOAuthClients(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list oauth clients: %w", err)
+	}
+	return res, nil
+}
+
+func (r *oauthClientRepository) RevokeClient(ctx context.Context, id uuid.UUID) (bool, error) {
+	rows, err := r.db.RevokeOAuthClient(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke oauth client: %w", err)
+	}
+	return rows > 0, nil
+}
+
+func (r *oauthClientRepository) TouchClient(ctx context.Context, id uuid.UUID) error {
+	if err := r.db.TouchOAuthClient(ctx, id); err != nil {
+		return fmt.Errorf("failed to update oauth client: %w", err)
+	}
+	return nil
+}
+
+func (r *oauthClientRepository) UpdateClient(ctx context.Context, param *db.UpdateOAuthClientParams) (*db.OauthClient, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.UpdateOAuthClient(ctx, *param)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: oauth client %s", apperrors.ErrNotFound, param.ID)
+		}
+		return nil, fmt.Errorf("failed to update oauth client: %w", err)
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+// OutboxRepository gives the relay the events that still have to be published. Events are
+// written by the repositories that change the aggregate, in the same transaction.
+type OutboxRepository interface {
+	// ClaimEvents returns up to batchSize due events and hides them from other relays until
+	// leaseUntil, so a relay that dies mid-batch only delays its events.
+	ClaimEvents(ctx context.Context, batchSize int32, leaseUntil time.Time) ([]db.Outbox, error)
+	MarkPublished(ctx context.Context, id uuid.UUID) error
+	MarkFailed(ctx context.Context, param *db.MarkOutboxEventFailedParams) error
+	// DeletePublished removes up to batchSize events published before publishedBefore.
+	DeletePublished(ctx context.Context, publishedBefore time.Time, batchSize int32) (int64, error)
+	CountPublished(ctx context.Context, publishedBefore time.Time) (int64, error)
+}
+
+type outboxRepository struct {
+	db  *db.Queries
+	log *logrus.Logger
+}
+
+func NewOutboxRepository(sqlcQueries *db.Queries, log *logrus.Logger) OutboxRepository {
+	return &outboxRepository{db: sqlcQueries, log: log}
+}
+
+func (r *outboxRepository) ClaimEvents(ctx context.Context, batchSize int32, leaseUntil time.Time) ([]db.Outbox, error) {
+	rows, err := r.db.ClaimOutboxEvents(ctx, db.ClaimOutboxEventsParams{
+		BatchSize:  batchSize,
+		LeaseUntil: leaseUntil,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+
+	return rows, nil
+}
+
+func (r *outboxRepository) MarkPublished(ctx context.Context, id uuid.UUID) error {
+	if err := r.db.MarkOutboxEventPublished(ctx, id); err != nil {
+		return fmt.Errorf("failed to mark outbox event published: %w", err)
+	}
+
+	return nil
+}
+
+func (r *outboxRepository) MarkFailed(ctx context.Context, param *db.MarkOutboxEventFailedParams) error {
+	if param == nil {
+		return apperrors.ErrInvalidQuery
+	}
+
+	if err := r.db.MarkOutboxEventFailed(ctx, *param); err != nil {
+		return fmt.Errorf("failed to mark outbox event failed: %w", err)
+	}
+
+	return nil
+}
+
+func (r *outboxRepository) DeletePublished(ctx context.Context, publishedBefore time.Time, batchSize int32) (int64, error) {
+	rows, err := r.db.DeletePublishedOutboxEvents(ctx, db.DeletePublishedOutboxEventsParams{
+		PublishedBefore: sql.NullTime{Time: publishedBefore, Valid: true},
+		BatchSize:       batchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete published outbox events: %w", err)
+	}
+
+	return rows, nil
+}
+
+func (r *outboxRepository) CountPublished(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	count, err := r.db.CountPublishedOutboxEvents(ctx, sql.NullTime{Time: publishedBefore, Valid: true})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count published outbox events: %w", err)
+	}
+
+	return count, nil
+}
+
+// enqueueEvent writes an event to the outbox with q, which is bound to the transaction of the
+// change the event describes.
+func enqueueEvent(ctx context.Context, q *db.Queries, aggregateType string, aggregateID uuid.UUID, eventType string, data any) error {
+	event := entities.Event{
+		ID:         uuid.New(),
+		Type:       eventType,
+		Version:    entities.EventVersion,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	err = q.CreateOutboxEvent(ctx, db.CreateOutboxEventParams{
+		ID:            event.ID,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Payload:       payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s event to the outbox: %w", eventType, err)
+	}
+
+	return nil
+}
+
+func enqueueUserEvent(ctx context.Context, q *db.Queries, eventType string, user *db.User, previousEmail string) error {
+	data := entities.UserEventData{
+		UserID:        user.ID,
+		Name:          user.Name,
+		Username:      user.Username,
+		Email:         user.Email,
+		PreviousEmail: previousEmail,
+		Role:          user.Role,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+	if user.EmailVerifiedAt.Valid {
+		data.EmailVerifiedAt = &user.EmailVerifiedAt.Time
+	}
+	if user.DeletedAt.Valid {
+		data.DeletedAt = &user.DeletedAt.Time
+	}
+
+	return enqueueEvent(ctx, q, entities.AggregateUser, user.ID, eventType, data)
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+type PasswordResetRepository interface {
+	CreateResetToken(ctx context.Context, param *db.CreatePasswordResetTokenParams) (*db.PasswordResetToken, error)
+	ConsumeResetToken(ctx context.Context, tokenHash string) (*db.PasswordResetToken, error)
+	InvalidateUserResetTokens(ctx context.Context, userID uuid.UUID) error
+	DeleteExpired(ctx context.Context, expiresBefore time.Time) (int64, error)
+}
+
+type passwordResetRepository struct {
+	db  *db.Queries
+	log *logrus.Logger
+}
+
+func NewPasswordResetRepository(sqlcQueries *db.Queries, log *logrus.Logger) PasswordResetRepository {
+	return &passwordResetRepository{db: sqlcQueries, log: log}
+}
+
+func (r *passwordResetRepository) CreateResetToken(ctx context.Context, param *db.CreatePasswordResetTokenParams) (*db.PasswordResetToken, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.CreatePasswordResetToken(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create password reset token: %w", err)
+	}
+
+	return &res, nil
+}
+
+// ConsumeResetToken marks the token as used in the same statement that looks it up, so a
+// token can only ever be redeemed once. Unknown, used and expired tokens all yield ErrTokenNotFound.
+func (r *passwordResetRepository) ConsumeResetToken(ctx context.Context, tokenHash string) (*db.PasswordResetToken, error) {
+	res, err := r.db.ConsumePasswordResetToken(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to consume password reset token: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *passwordResetRepository) InvalidateUserResetTokens(ctx context.Context, userID uuid.UUID) error {
+	if err := r.db.InvalidateUserPasswordResetTokens(ctx, userID); err != nil {
+		return fmt.Errorf("failed to invalidate password reset tokens: %w", err)
+	}
+
+	return nil
+}
+
+func (r *passwordResetRepository) DeleteExpired(ctx context.Context, expiresBefore time.Time) (int64, error) {
+	rows, err := r.db.DeleteExpiredPasswordResetTokens(ctx, expiresBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired password reset tokens: %w", err)
+	}
+
+	return rows, nil
+}
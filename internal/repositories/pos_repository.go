@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+type POSRepository interface {
+	UpsertUserPin(ctx context.Context, param *db.UpsertUserPinParams) error
+	GetUserPin(ctx context.Context, userID uuid.UUID) (*db.UserPin, error)
+	DeleteUserPin(ctx context.Context, userID uuid.UUID) (bool, error)
+	CreateTerminal(ctx context.Context, param *db.CreatePosTerminalParams) (*db.PosTerminal, error)
+	GetTerminal(ctx context.Context, id uuid.UUID) (*db.PosTerminal, error)
+	ListTerminals(ctx context.Context, storeID uuid.UUID) ([]db.PosTerminal, error)
+	RevokeTerminal(ctx context.Context, param *db.RevokePosTerminalParams) (bool, error)
+	TouchTerminal(ctx context.Context, id uuid.UUID) error
+}
+
+type posRepository struct {
+	db  *db.Queries
+	log *logrus.Logger
+}
+
+func NewPOSRepository(sqlcQueries *db.Queries, log *logrus.Logger) POSRepository {
+	return &posRepository{db: sqlcQueries, log: log}
+}
+
+func (r *posRepository) UpsertUserPin(ctx context.Context, param *db.UpsertUserPinParams) error {
+	if param == nil {
+		return apperrors.ErrInvalidQuery
+	}
+
+	if err := r.db.UpsertUserPin(ctx, *param); err != nil {
+		return fmt.Errorf("failed to save pin: %w", err)
+	}
+	return nil
+}
+
+func (r *posRepository) GetUserPin(ctx context.Context, userID uuid.UUID) (*db.UserPin, error) {
+	res, err := r.db.GetUserPin(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrPINNotSet
+		}
+		return nil, fmt.Errorf("failed to get pin: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *posRepository) DeleteUserPin(ctx context.Context, userID uuid.UUID) (bool, error) {
+	rows, err := r.db.DeleteUserPin(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete pin: %w", err)
+	}
+	return rows > 0, nil
+}
+
+func (r *posRepository) CreateTerminal(ctx context.Context, param *db.CreatePosTerminalParams) (*db.PosTerminal, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.CreatePosTerminal(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create terminal: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *posRepository) GetTerminal(ctx context.Context, id uuid.UUID) (*db.PosTerminal, error) {
+	res, err := r.db.GetPosTerminal(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: terminal %s", apperrors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get terminal: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *posRepository) ListTerminals(ctx context.Context, storeID uuid.UUID) ([]db.PosTerminal, error) {
+	res, err := r.db.ListPosTerminals(ctx, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list terminals: %w", err)
+	}
+	return res, nil
+}
+
+func (r *posRepository) RevokeTerminal(ctx context.Context, param *db.RevokePosTerminalParams) (bool, error) {
+	if param == nil {
+		return false, apperrors.ErrInvalidQuery
+	}
+
+	rows, err := r.db.RevokePosTerminal(ctx, *param)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke terminal: %w", err)
+	}
+	return rows > 0, nil
+}
+
+func (r *posRepository) TouchTerminal(ctx context.Context, id uuid.UUID) error {
+	if err := r.db.TouchPosTerminal(ctx, id); err != nil {
+		return fmt.Errorf("failed to update terminal: %w", err)
+	}
+	return nil
+}
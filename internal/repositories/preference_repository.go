@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+// PreferenceRepository stores the per-account settings users can change themselves.
+type PreferenceRepository interface {
+	// GetPreferences returns the defaults for users that never saved their preferences.
+	GetPreferences(ctx context.Context, userID uuid.UUID) (*db.UserPreference, error)
+	UpsertPreferences(ctx context.Context, param *db.UpsertUserPreferencesParams) (*db.UserPreference, error)
+}
+
+type preferenceRepository struct {
+	db  *db.Queries
+	log *logrus.Logger
+}
+
+func NewPreferenceRepository(sqlcQueries *db.Queries, log *logrus.Logger) PreferenceRepository {
+	return &preferenceRepository{db: sqlcQueries, log: log}
+}
+
+func (r *preferenceRepository) GetPreferences(ctx context.Context, userID uuid.UUID) (*db.UserPreference, error) {
+	res, err := r.db.GetUserPreferences(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &db.UserPreference{UserID: userID, MarketingEmails: true}, nil
+		}
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *preferenceRepository) UpsertPreferences(ctx context.Context, param *db.UpsertUserPreferencesParams) (*db.UserPreference, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.UpsertUserPreferences(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save preferences: %w", err)
+	}
+
+	return &res, nil
+}
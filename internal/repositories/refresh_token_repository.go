@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, param *db.CreateRefreshTokenParams) (*db.RefreshToken, error)
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*db.RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, id uuid.UUID) (*db.RefreshToken, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error
+	// DeleteExpired removes tokens that expired before expiresBefore and returns the count.
+	DeleteExpired(ctx context.Context, expiresBefore time.Time) (int64, error)
+}
+
+type refreshTokenRepository struct {
+	db  *db.Queries
+	log *logrus.Logger
+}
+
+func NewRefreshTokenRepository(sqlcQueries *db.Queries, log *logrus.Logger) RefreshTokenRepository {
+	return &refreshTokenRepository{db: sqlcQueries, log: log}
+}
+
+func (r *refreshTokenRepository) CreateRefreshToken(ctx context.Context, param *db.CreateRefreshTokenParams) (*db.RefreshToken, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.CreateRefreshToken(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *refreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*db.RefreshToken, error) {
+	res, err := r.db.GetRefreshTokenByHash(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return &res, nil
+}
+
+// MarkRefreshTokenUsed consumes a refresh token. It returns ErrTokenNotFound when the
+// token was already used or revoked, which lets callers detect concurrent reuse.
+func (r *refreshTokenRepository) MarkRefreshTokenUsed(ctx context.Context, id uuid.UUID) (*db.RefreshToken, error) {
+	res, err := r.db.MarkRefreshTokenUsed(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to mark refresh token as used: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *refreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	if err := r.db.RevokeRefreshTokenFamily(ctx, familyID); err != nil {
+		r.log.WithError(err).WithField("family_id", familyID).Error("Failed to revoke refresh token family")
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	return nil
+}
+
+func (r *refreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	if err := r.db.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		r.log.WithError(err).WithField("user_id", userID).Error("Failed to revoke refresh tokens of user")
+		return fmt.Errorf("failed to revoke refresh tokens of user: %w", err)
+	}
+
+	return nil
+}
+
+func (r *refreshTokenRepository) DeleteExpired(ctx context.Context, expiresBefore time.Time) (int64, error) {
+	rows, err := r.db.DeleteExpiredRefreshTokens(ctx, expiresBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+
+	return rows, nil
+}
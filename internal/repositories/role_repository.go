@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+type RoleRepository interface {
+	ListRoles(ctx context.Context) ([]db.Role, error)
+	ListRolePermissions(ctx context.Context) ([]db.ListRolePermissionsRow, error)
+	GetRoleByName(ctx context.Context, name string) (*db.Role, error)
+	GetUserRoles(ctx context.Context, userID uuid.UUID) ([]db.Role, error)
+	GetUserPermissions(ctx context.Context, userID uuid.UUID) ([]string, error)
+	GrantUserRole(ctx context.Context, param *db.GrantUserRoleParams) (bool, error)
+	RevokeUserRole(ctx context.Context, param *db.RevokeUserRoleParams) (bool, error)
+	SyncUserPrimaryRole(ctx context.Context, userID uuid.UUID) error
+}
+
+type roleRepository struct {
+	db  *db.Queries
+	log *logrus.Logger
+}
+
+func NewRoleRepository(sqlcQueries *db.Queries, log *logrus.Logger) RoleRepository {
+	return &roleRepository{db: sqlcQueries, log: log}
+}
+
+func (r *roleRepository) ListRoles(ctx context.Context) ([]db.Role, error) {
+	res, err := r.db.ListRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	return res, nil
+}
+
+func (r *roleRepository) ListRolePermissions(ctx context.Context) ([]db.ListRolePermissionsRow, error) {
+	res, err := r.db.ListRolePermissions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role permissions: %w", err)
+	}
+
+	return res, nil
+}
+
+func (r *roleRepository) GetRoleByName(ctx context.Context, name string) (*db.Role, error) {
+	res, err := r.db.GetRoleByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: role %q", apperrors.ErrNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *roleRepository) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]db.Role, error) {
+	res, err := r.db.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+
+	return res, nil
+}
+
+func (r *roleRepository) GetUserPermissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	res, err := r.db.GetUserPermissions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user permissions: %w", err)
+	}
+
+	return res, nil
+}
+
+// GrantUserRole returns false when the user already had the role.
+func (r *roleRepository) GrantUserRole(ctx context.Context, param *db.GrantUserRoleParams) (bool, error) {
+	if param == nil {
+		return false, apperrors.ErrInvalidQuery
+	}
+
+	rows, err := r.db.GrantUserRole(ctx, *param)
+	if err != nil {
+		return false, fmt.Errorf("failed to grant role: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// RevokeUserRole returns false when the user did not have the role.
+func (r *roleRepository) RevokeUserRole(ctx context.Context, param *db.RevokeUserRoleParams) (bool, error) {
+	if param == nil {
+		return false, apperrors.ErrInvalidQuery
+	}
+
+	rows, err := r.db.RevokeUserRole(ctx, *param)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke role: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// SyncUserPrimaryRole copies the highest priority role of the user to users.role.
+func (r *roleRepository) SyncUserPrimaryRole(ctx context.Context, userID uuid.UUID) error {
+	err := r.db.SyncUserPrimaryRole(ctx, db.SyncUserPrimaryRoleParams{
+		DefaultRole: entities.DefaultRole,
+		UserID:      userID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sync primary role: %w", err)
+	}
+
+	return nil
+}
@@ -4,17 +4,24 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
-	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/google/uuid"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/redisclient"
 	"github.com/go-redis/redis/v8"
 )
 
 type SessionRepository interface {
-	StoreUserSession(ctx context.Context, token string, user *entities.User, duration time.Duration) error
-	GetUserSession(ctx context.Context, token string) (*entities.User, error)
-	DeleteUserSession(ctx context.Context, token string) error
+	StoreSession(ctx context.Context, session *models.Session) error
+	GetSession(ctx context.Context, userID uuid.UUID, jti string) (*models.Session, error)
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error)
+	TouchSession(ctx context.Context, userID uuid.UUID, jti string, seenAt time.Time) error
+	DeleteSession(ctx context.Context, userID uuid.UUID, jti string) error
+	DeleteAllSessions(ctx context.Context, userID uuid.UUID) error
 }
 
 type sessionRepository struct {
@@ -25,32 +32,123 @@ func NewSessionRepository(redisClient *redisclient.RedisClient) SessionRepositor
 	return &sessionRepository{redisClient: redisClient}
 }
 
-func (r *sessionRepository) StoreUserSession(ctx context.Context, token string, user *entities.User, duration time.Duration) error {
-	userJSON, err := json.Marshal(user)
+// Sessions of a user live in the hash "session:user:<user_id>" (field = JTI, value = JSON).
+// Last-seen timestamps are written on every request, so they are kept in a separate hash
+// "session:lastseen:<user_id>" to avoid rewriting the whole session document.
+func sessionKey(userID uuid.UUID) string {
+	return fmt.Sprintf("session:user:%s", userID)
+}
+
+func lastSeenKey(userID uuid.UUID) string {
+	return fmt.Sprintf("session:lastseen:%s", userID)
+}
+
+func (r *sessionRepository) StoreSession(ctx context.Context, session *models.Session) error {
+	sessionJSON, err := json.Marshal(session)
 	if err != nil {
-		return fmt.Errorf("failed to marshal user to JSON: %w", err)
+		return fmt.Errorf("failed to marshal session to JSON: %w", err)
+	}
+
+	key := sessionKey(session.UserID)
+	ttl := time.Until(session.ExpiresAt)
+
+	pipe := r.redisClient.Client.TxPipeline()
+	pipe.HSet(ctx, key, session.JTI, sessionJSON)
+	pipe.HSet(ctx, lastSeenKey(session.UserID), session.JTI, session.LastSeenAt.Unix())
+	// The hash lives as long as the longest session in it
+	if currentTTL, err := r.redisClient.Client.TTL(ctx, key).Result(); err == nil && currentTTL < ttl {
+		pipe.Expire(ctx, key, ttl)
+		pipe.Expire(ctx, lastSeenKey(session.UserID), ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store session: %w", err)
 	}
-	// Use SETEX (Set with Expire) for automatic TTL
-	return r.redisClient.Client.SetEX(ctx, token, userJSON, duration).Err()
+	return nil
 }
 
-func (r *sessionRepository) GetUserSession(ctx context.Context, token string) (*entities.User, error) {
-	val, err := r.redisClient.Client.Get(ctx, token).Result()
+func (r *sessionRepository) GetSession(ctx context.Context, userID uuid.UUID, jti string) (*models.Session, error) {
+	val, err := r.redisClient.Client.HGet(ctx, sessionKey(userID), jti).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, fmt.Errorf("session not found for token: %s", token)
+			return nil, apperrors.ErrSessionNotFound
 		}
 		return nil, fmt.Errorf("failed to retrieve session from Redis: %w", err)
 	}
 
-	var user entities.User
-	err = json.Unmarshal([]byte(val), &user)
+	var session models.Session
+	if err := json.Unmarshal([]byte(val), &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session from JSON: %w", err)
+	}
+
+	if seen, err := r.redisClient.Client.HGet(ctx, lastSeenKey(userID), jti).Int64(); err == nil {
+		session.LastSeenAt = time.Unix(seen, 0)
+	}
+	return &session, nil
+}
+
+// ListSessions returns the active sessions of a user and drops the ones that already expired.
+func (r *sessionRepository) ListSessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	entries, err := r.redisClient.Client.HGetAll(ctx, sessionKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions from Redis: %w", err)
+	}
+
+	lastSeen, err := r.redisClient.Client.HGetAll(ctx, lastSeenKey(userID)).Result()
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal user from JSON: %w", err)
+		return nil, fmt.Errorf("failed to list session activity from Redis: %w", err)
+	}
+
+	now := time.Now()
+	sessions := make([]models.Session, 0, len(entries))
+	var expired []string
+	for jti, val := range entries {
+		var session models.Session
+		if err := json.Unmarshal([]byte(val), &session); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal session from JSON: %w", err)
+		}
+		if now.After(session.ExpiresAt) {
+			expired = append(expired, jti)
+			continue
+		}
+		if seen, err := strconv.ParseInt(lastSeen[jti], 10, 64); err == nil {
+			session.LastSeenAt = time.Unix(seen, 0)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if len(expired) > 0 {
+		pipe := r.redisClient.Client.Pipeline()
+		pipe.HDel(ctx, sessionKey(userID), expired...)
+		pipe.HDel(ctx, lastSeenKey(userID), expired...)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to prune expired sessions: %w", err)
+		}
+	}
+
+	return sessions, nil
+}
+
+func (r *sessionRepository) TouchSession(ctx context.Context, userID uuid.UUID, jti string, seenAt time.Time) error {
+	exists, err := r.redisClient.Client.HExists(ctx, sessionKey(userID), jti).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check session: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+	return r.redisClient.Client.HSet(ctx, lastSeenKey(userID), jti, seenAt.Unix()).Err()
+}
+
+func (r *sessionRepository) DeleteSession(ctx context.Context, userID uuid.UUID, jti string) error {
+	pipe := r.redisClient.Client.TxPipeline()
+	pipe.HDel(ctx, sessionKey(userID), jti)
+	pipe.HDel(ctx, lastSeenKey(userID), jti)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
 	}
-	return &user, nil
+	return nil
 }
 
-func (r *sessionRepository) DeleteUserSession(ctx context.Context, token string) error {
-	return r.redisClient.Client.Del(ctx, token).Err()
+func (r *sessionRepository) DeleteAllSessions(ctx context.Context, userID uuid.UUID) error {
+	return r.redisClient.Client.Del(ctx, sessionKey(userID), lastSeenKey(userID)).Err()
 }
@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+type TenancyRepository interface {
+	CreateOrganization(ctx context.Context, name string) (*db.Organization, error)
+	GetOrganization(ctx context.Context, id uuid.UUID) (*db.Organization, error)
+	CreateStore(ctx context.Context, param *db.CreateStoreParams) (*db.Store, error)
+	GetStore(ctx context.Context, id uuid.UUID) (*db.Store, error)
+	UpsertStoreMembership(ctx context.Context, param *db.UpsertStoreMembershipParams) (*db.StoreMembership, error)
+	DeleteStoreMembership(ctx context.Context, param *db.DeleteStoreMembershipParams) (bool, error)
+	GetStoreMembership(ctx context.Context, param *db.GetStoreMembershipParams) (*db.GetStoreMembershipRow, error)
+	ListUserMemberships(ctx context.Context, userID uuid.UUID) ([]db.ListUserMembershipsRow, error)
+	ListStoreMembers(ctx context.Context, storeID uuid.UUID) ([]db.ListStoreMembersRow, error)
+}
+
+type tenancyRepository struct {
+	db  *db.Queries
+	log *logrus.Logger
+}
+
+func NewTenancyRepository(sqlcQueries *db.Queries, log *logrus.Logger) TenancyRepository {
+	return &tenancyRepository{db: sqlcQueries, log: log}
+}
+
+func (r *tenancyRepository) CreateOrganization(ctx context.Context, name string) (*db.Organization, error) {
+	res, err := r.db.CreateOrganization(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *tenancyRepository) GetOrganization(ctx context.Context, id uuid.UUID) (*db.Organization, error) {
+	res, err := r.db.GetOrganization(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: organization %s", apperrors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *tenancyRepository) CreateStore(ctx context.Context, param *db.CreateStoreParams) (*db.Store, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.CreateStore(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *tenancyRepository) GetStore(ctx context.Context, id uuid.UUID) (*db.Store, error) {
+	res, err := r.db.GetStore(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: store %s", apperrors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get store: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *tenancyRepository) UpsertStoreMembership(ctx context.Context, param *db.UpsertStoreMembershipParams) (*db.StoreMembership, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.UpsertStoreMembership(ctx, *param)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store membership: %w", err)
+	}
+
+	return &res, nil
+}
+
+// DeleteStoreMembership returns false when the user was not a member of the store.
+func (r *tenancyRepository) DeleteStoreMembership(ctx context.Context, param *db.DeleteStoreMembershipParams) (bool, error) {
+	if param == nil {
+		return false, apperrors.ErrInvalidQuery
+	}
+
+	rows, err := r.db.DeleteStoreMembership(ctx, *param)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete membership: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+func (r *tenancyRepository) GetStoreMembership(ctx context.Context, param *db.GetStoreMembershipParams) (*db.GetStoreMembershipRow, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.GetStoreMembership(ctx, *param)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrNotStoreMember
+		}
+		return nil, fmt.Errorf("failed to get membership: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *tenancyRepository) ListUserMemberships(ctx context.Context, userID uuid.UUID) ([]db.ListUserMembershipsRow, error) {
+	res, err := r.db.ListUserMemberships(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memberships: %w", err)
+	}
+
+	return res, nil
+}
+
+func (r *tenancyRepository) ListStoreMembers(ctx context.Context, storeID uuid.UUID) ([]db.ListStoreMembersRow, error) {
+	res, err := r.db.ListStoreMembers(ctx, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list store members: %w", err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+)
+
+// inTx runs fn with queries bound to a single transaction. The transaction is committed when fn
+// succeeds and rolled back otherwise.
+func inTx(ctx context.Context, sqlDB *sql.DB, queries *db.Queries, fn func(q *db.Queries) error) error {
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(queries.WithTx(tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -2,32 +2,64 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
 	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
 	"github.com/google/uuid"
 )
 
 type UserRepository interface {
 	CreateUser(ctx context.Context, param *db.CreateUserParams) (*db.User, error)
-	GetAllUsers(ctx context.Context) ([]db.GetAllUsersRow, error)
+	// CreateFederatedUser creates the user like CreateUser and links the identity, whose UserID
+	// it sets, in the same transaction: a failed link leaves no account nobody can sign in to.
+	CreateFederatedUser(ctx context.Context, param *db.CreateUserParams, identity *db.CreateUserIdentityParams) (*db.User, error)
+	ListUsers(ctx context.Context, param *db.ListUsersParams) ([]db.ListUsersRow, error)
+	SearchUsers(ctx context.Context, param *db.SearchUsersParams) ([]db.SearchUsersRow, error)
 	GetUserByUsername(ctx context.Context, username string) (*db.GetUserByUsernameRow, error)
+	GetUserByEmail(ctx context.Context, email string) (*db.GetUserByEmailRow, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*db.GetUserByIDRow, error)
 	GetUserByIDs(ctx context.Context, id []uuid.UUID) ([]db.GetUserByIDsRow, error)
 	UpdateUser(ctx context.Context, param *db.UpdateUserParams) (*db.User, error)
+	UpdateUserPassword(ctx context.Context, param *db.UpdateUserPasswordParams) error
+	MarkEmailVerified(ctx context.Context, param *db.MarkUserEmailVerifiedParams) (bool, error)
 	DeleteUser(ctx context.Context, id uuid.UUID) (*db.User, error)
+
+	// ListExpiredDeletedUsers returns, ordered by id, up to batchSize users after afterID that
+	// were soft-deleted before deletedBefore and not anonymized yet.
+	ListExpiredDeletedUsers(ctx context.Context, deletedBefore time.Time, afterID uuid.UUID, batchSize int32) ([]uuid.UUID, error)
+	CountExpiredDeletedUsers(ctx context.Context, deletedBefore time.Time) (*db.CountExpiredDeletedUsersRow, error)
+	// PurgeDeletedUser removes the credentials, identities and API keys of a soft-deleted user,
+	// then either anonymizes the row or, with hardDelete, removes it. No event is published:
+	// consumers were already told about the deletion.
+	PurgeDeletedUser(ctx context.Context, id uuid.UUID, hardDelete bool) (*db.PurgeUserCredentialsRow, error)
+}
+
+// uniqueUserFields maps the unique constraints of the users table to the field they guard.
+var uniqueUserFields = map[string]string{
+	"users_name_key":                  "name",
+	"idx_users_username_lower_unique": "username",
+	"idx_users_email_lower_unique":    "email",
 }
 
+// userRepository writes an account event to the outbox in the same transaction as every
+// create, update, delete and password change, so no change is published without being stored
+// and none is stored without being published.
 type userRepository struct {
-	db  *db.Queries
-	log *logrus.Logger
+	sqlDB *sql.DB
+	db    *db.Queries
+	log   *logrus.Logger
 }
 
-func NewUserRepository(sqlcQueries *db.Queries, log *logrus.Logger) UserRepository {
-	return &userRepository{db: sqlcQueries, log: log}
+func NewUserRepository(sqlDB *sql.DB, sqlcQueries *db.Queries, log *logrus.Logger) UserRepository {
+	return &userRepository{sqlDB: sqlDB, db: sqlcQueries, log: log}
 }
 
 func (u *userRepository) CreateUser(ctx context.Context, param *db.CreateUserParams) (*db.User, error) {
@@ -37,22 +69,96 @@ func (u *userRepository) CreateUser(ctx context.Context, param *db.CreateUserPar
 		return nil, apperrors.ErrInvalidQuery
 	}
 
-	res, err := u.db.CreateUser(ctx, *param)
+	err := inTx(ctx, u.sqlDB, u.db, func(q *db.Queries) error {
+		var err error
+		res, err = createUser(ctx, q, param)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, err
 	}
 
 	return &res, nil
 }
 
-func (u *userRepository) GetAllUsers(ctx context.Context) ([]db.GetAllUsersRow, error) {
-	u.log.Debug()
-	var rows []db.GetAllUsersRow
+func (u *userRepository) CreateFederatedUser(ctx context.Context, param *db.CreateUserParams, identity *db.CreateUserIdentityParams) (*db.User, error) {
+	var res db.User
+
+	if param == nil || identity == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	err := inTx(ctx, u.sqlDB, u.db, func(q *db.Queries) error {
+		var err error
+		res, err = createUser(ctx, q, param)
+		if err != nil {
+			return err
+		}
 
-	rows, err := u.db.GetAllUsers(ctx)
+		identity.UserID = res.ID
+		if _, err := q.CreateUserIdentity(ctx, *identity); err != nil {
+			// The subject was linked to another account in the meantime
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+				return apperrors.ErrIdentityAlreadyLinked
+			}
+			return fmt.Errorf("failed to create identity: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		u.log.WithError(err).Error("Failed to retrieve all users from the database")
-		return nil, fmt.Errorf("failed to get all users: %w", err)
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// createUser inserts the user with its role and registration event on the queries of a transaction.
+func createUser(ctx context.Context, q *db.Queries, param *db.CreateUserParams) (db.User, error) {
+	res, err := q.CreateUser(ctx, *param)
+	if err != nil {
+		if conflict := toConflictError(err); conflict != nil {
+			return db.User{}, conflict
+		}
+		return db.User{}, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	// The account starts with the role of its legacy role column, granted in the same
+	// transaction so no user exists without it
+	role, err := q.GetRoleByName(ctx, param.Role)
+	if err != nil {
+		return db.User{}, fmt.Errorf("failed to get role %q: %w", param.Role, err)
+	}
+	if _, err := q.GrantUserRole(ctx, db.GrantUserRoleParams{UserID: res.ID, RoleID: role.ID}); err != nil {
+		return db.User{}, fmt.Errorf("failed to grant role: %w", err)
+	}
+
+	return res, enqueueUserEvent(ctx, q, entities.EventUserRegistered, &res, "")
+}
+
+func (u *userRepository) ListUsers(ctx context.Context, param *db.ListUsersParams) ([]db.ListUsersRow, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	rows, err := u.db.ListUsers(ctx, *param)
+	if err != nil {
+		u.log.WithError(err).Error("Failed to list users from the database")
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return rows, nil
+}
+
+func (u *userRepository) SearchUsers(ctx context.Context, param *db.SearchUsersParams) ([]db.SearchUsersRow, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	rows, err := u.db.SearchUsers(ctx, *param)
+	if err != nil {
+		u.log.WithError(err).Error("Failed to search users in the database")
+		return nil, fmt.Errorf("failed to search users: %w", err)
 	}
 
 	return rows, nil
@@ -69,6 +175,17 @@ func (u *userRepository) GetUserByUsername(ctx context.Context, username string)
 	return &row, nil
 }
 
+func (u *userRepository) GetUserByEmail(ctx context.Context, email string) (*db.GetUserByEmailRow, error) {
+	var row db.GetUserByEmailRow
+
+	row, err := u.db.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by email: %w", err)
+	}
+
+	return &row, nil
+}
+
 func (u *userRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*db.GetUserByIDRow, error) {
 	var row db.GetUserByIDRow
 
@@ -92,25 +209,159 @@ func (u *userRepository) GetUserByIDs(ctx context.Context, id []uuid.UUID) ([]db
 	return row, nil
 }
 
+// UpdateUser also publishes a password change when the update stored a new hash.
 func (u *userRepository) UpdateUser(ctx context.Context, param *db.UpdateUserParams) (*db.User, error) {
 	var res db.User
 
-	res, err := u.db.UpdateUser(ctx, *param)
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	err := inTx(ctx, u.sqlDB, u.db, func(q *db.Queries) error {
+		previous, err := q.GetUserByID(ctx, param.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get user by id: %w", err)
+		}
 
+		res, err = q.UpdateUser(ctx, *param)
+		if err != nil {
+			if conflict := toConflictError(err); conflict != nil {
+				return conflict
+			}
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+
+		previousEmail := ""
+		if previous.Email != res.Email {
+			previousEmail = previous.Email
+		}
+		if err := enqueueUserEvent(ctx, q, entities.EventUserUpdated, &res, previousEmail); err != nil {
+			return err
+		}
+
+		if previous.Password == res.Password {
+			return nil
+		}
+		return enqueueEvent(ctx, q, entities.AggregateUser, res.ID, entities.EventUserPasswordChanged,
+			entities.PasswordChangedEventData{UserID: res.ID})
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to update user: %w", err)
+		return nil, err
 	}
 
 	return &res, nil
 }
 
+func (u *userRepository) UpdateUserPassword(ctx context.Context, param *db.UpdateUserPasswordParams) error {
+	if param == nil {
+		return apperrors.ErrInvalidQuery
+	}
+
+	return inTx(ctx, u.sqlDB, u.db, func(q *db.Queries) error {
+		if err := q.UpdateUserPassword(ctx, *param); err != nil {
+			return fmt.Errorf("failed to update user password: %w", err)
+		}
+
+		return enqueueEvent(ctx, q, entities.AggregateUser, param.ID, entities.EventUserPasswordChanged,
+			entities.PasswordChangedEventData{UserID: param.ID})
+	})
+}
+
+// MarkEmailVerified reports false when the user is already verified or changed the address.
+func (u *userRepository) MarkEmailVerified(ctx context.Context, param *db.MarkUserEmailVerifiedParams) (bool, error) {
+	if param == nil {
+		return false, apperrors.ErrInvalidQuery
+	}
+
+	rows, err := u.db.MarkUserEmailVerified(ctx, *param)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark email verified: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
 func (u *userRepository) DeleteUser(ctx context.Context, id uuid.UUID) (*db.User, error) {
 	var res db.User
 
-	res, err := u.db.DeleteUser(ctx, id)
+	err := inTx(ctx, u.sqlDB, u.db, func(q *db.Queries) error {
+		var err error
+		res, err = q.DeleteUser(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+
+		return enqueueUserEvent(ctx, q, entities.EventUserDeleted, &res, "")
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete user: %w", err)
+		return nil, err
 	}
 
 	return &res, nil
 }
+
+func (u *userRepository) ListExpiredDeletedUsers(ctx context.Context, deletedBefore time.Time, afterID uuid.UUID, batchSize int32) ([]uuid.UUID, error) {
+	res, err := u.db.ListExpiredDeletedUsers(ctx, db.ListExpiredDeletedUsersParams{
+		DeletedBefore: sql.NullTime{Time: deletedBefore, Valid: true},
+		AfterID:       afterID,
+		BatchSize:     batchSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired deleted users: %w", err)
+	}
+
+	return res, nil
+}
+
+func (u *userRepository) CountExpiredDeletedUsers(ctx context.Context, deletedBefore time.Time) (*db.CountExpiredDeletedUsersRow, error) {
+	res, err := u.db.CountExpiredDeletedUsers(ctx, sql.NullTime{Time: deletedBefore, Valid: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count expired deleted users: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (u *userRepository) PurgeDeletedUser(ctx context.Context, id uuid.UUID, hardDelete bool) (*db.PurgeUserCredentialsRow, error) {
+	var res db.PurgeUserCredentialsRow
+
+	err := inTx(ctx, u.sqlDB, u.db, func(q *db.Queries) error {
+		var err error
+		res, err = q.PurgeUserCredentials(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to purge user credentials: %w", err)
+		}
+
+		if hardDelete {
+			if err := q.HardDeleteUser(ctx, id); err != nil {
+				return fmt.Errorf("failed to hard delete user: %w", err)
+			}
+			return nil
+		}
+
+		if err := q.AnonymizeUser(ctx, id); err != nil {
+			return fmt.Errorf("failed to anonymize user: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// toConflictError turns a unique violation on the users table into a *ConflictError naming
+// the field, and returns nil for any other error.
+func toConflictError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return nil
+	}
+
+	field, ok := uniqueUserFields[pqErr.Constraint]
+	if !ok {
+		field = "user"
+	}
+	return &apperrors.ConflictError{Field: field}
+}
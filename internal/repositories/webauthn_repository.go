@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+// WebAuthnRepository stores the passkeys and security keys of users.
+type WebAuthnRepository interface {
+	CreateCredential(ctx context.Context, param *db.CreateWebauthnCredentialParams) (*db.WebauthnCredential, error)
+	GetCredential(ctx context.Context, credentialID []byte) (*db.WebauthnCredential, error)
+	ListCredentials(ctx context.Context, userID uuid.UUID) ([]db.WebauthnCredential, error)
+	CountCredentials(ctx context.Context, userID uuid.UUID) (int64, error)
+	UpdateCredentialUsage(ctx context.Context, param *db.UpdateWebauthnCredentialUsageParams) error
+	DeleteCredential(ctx context.Context, param *db.DeleteWebauthnCredentialParams) (bool, error)
+}
+
+type webAuthnRepository struct {
+	db  *db.Queries
+	log *logrus.Logger
+}
+
+func NewWebAuthnRepository(sqlcQueries *db.Queries, log *logrus.Logger) WebAuthnRepository {
+	return &webAuthnRepository{db: sqlcQueries, log: log}
+}
+
+func (r *webAuthnRepository) CreateCredential(ctx context.Context, param *db.CreateWebauthnCredentialParams) (*db.WebauthnCredential, error) {
+	if param == nil {
+		return nil, apperrors.ErrInvalidQuery
+	}
+
+	res, err := r.db.CreateWebauthnCredential(ctx, *param)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, apperrors.ErrPasskeyExists
+		}
+		return nil, fmt.Errorf("failed to create webauthn credential: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *webAuthnRepository) GetCredential(ctx context.Context, credentialID []byte) (*db.WebauthnCredential, error) {
+	res, err := r.db.GetWebauthnCredential(ctx, credentialID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: webauthn credential", apperrors.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get webauthn credential: %w", err)
+	}
+
+	return &res, nil
+}
+
+func (r *webAuthnRepository) ListCredentials(ctx context.Context, userID uuid.UUID) ([]db.WebauthnCredential, error) {
+	res, err := r.db.ListWebauthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webauthn credentials: %w", err)
+	}
+	return res, nil
+}
+
+func (r *webAuthnRepository) CountCredentials(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := r.db.CountWebauthnCredentials(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count webauthn credentials: %w", err)
+	}
+	return count, nil
+}
+
+func (r *webAuthnRepository) UpdateCredentialUsage(ctx context.Context, param *db.UpdateWebauthnCredentialUsageParams) error {
+	if param == nil {
+		return apperrors.ErrInvalidQuery
+	}
+
+	if err := r.db.UpdateWebauthnCredentialUsage(ctx, *param); err != nil {
+		return fmt.Errorf("failed to update webauthn credential: %w", err)
+	}
+	return nil
+}
+
+func (r *webAuthnRepository) DeleteCredential(ctx context.Context, param *db.DeleteWebauthnCredentialParams) (bool, error) {
+	if param == nil {
+		return false, apperrors.ErrInvalidQuery
+	}
+
+	rows, err := r.db.DeleteWebauthnCredential(ctx, *param)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webauthn credential: %w", err)
+	}
+	return rows > 0, nil
+}
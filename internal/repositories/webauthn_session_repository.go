@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/redisclient"
+)
+
+// WebAuthnSessionRepository keeps the challenge of WebAuthn ceremonies that wait for the
+// authenticator. A session can be taken only once, so every challenge is answered at most once.
+type WebAuthnSessionRepository interface {
+	SaveSession(ctx context.Context, id string, session *models.WebAuthnSession, ttl time.Duration) error
+	TakeSession(ctx context.Context, id string) (*models.WebAuthnSession, error)
+}
+
+type webAuthnSessionRepository struct {
+	redisClient *redisclient.RedisClient
+}
+
+func NewWebAuthnSessionRepository(redisClient *redisclient.RedisClient) WebAuthnSessionRepository {
+	return &webAuthnSessionRepository{redisClient: redisClient}
+}
+
+func webAuthnSessionKey(id string) string {
+	return fmt.Sprintf("webauthn:session:%s", id)
+}
+
+func (r *webAuthnSessionRepository) SaveSession(ctx context.Context, id string, session *models.WebAuthnSession, ttl time.Duration) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webauthn session: %w", err)
+	}
+
+	if err := r.redisClient.Client.Set(ctx, webAuthnSessionKey(id), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store webauthn session: %w", err)
+	}
+	return nil
+}
+
+func (r *webAuthnSessionRepository) TakeSession(ctx context.Context, id string) (*models.WebAuthnSession, error) {
+	val, err := r.redisClient.Client.GetDel(ctx, webAuthnSessionKey(id)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, apperrors.ErrInvalidPasskey
+		}
+		return nil, fmt.Errorf("failed to take webauthn session: %w", err)
+	}
+
+	var session models.WebAuthnSession
+	if err := json.Unmarshal([]byte(val), &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webauthn session: %w", err)
+	}
+	return &session, nil
+}
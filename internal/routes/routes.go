@@ -1,39 +1,199 @@
 package routes
 
 import (
+	"net/http"
+	"time"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/handlers"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/middlewares"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// Request quotas per route group.
+var (
+	// credential endpoints are the brute-force and spam targets, counted per IP and route
+	publicAuthLimit = middlewares.RateLimit{Limit: 10, Window: time.Minute}
+	// regular API usage of a logged in user
+	authenticatedLimit = middlewares.RateLimit{Limit: 120, Window: time.Minute}
+	// listing and searching every user is expensive
+	adminListLimit = middlewares.RateLimit{Limit: 30, Window: time.Minute}
 )
 
-func InitRoutes(e *echo.Echo, api *handlers.UserHandler, tokenService token.TokenService) {
+type Options struct {
+	TokenService token.TokenService
+	RateLimiter  middlewares.RateLimiter
+	// RequireVerifiedEmail enables the "restricted" email verification mode.
+	RequireVerifiedEmail bool
+	// SecureCookies marks the CSRF cookie of the login page as HTTPS only.
+	SecureCookies bool
+}
+
+func InitRoutes(e *echo.Echo, api *handlers.UserHandler, opts Options) {
 	e.Static("/static", "template")
 
+	e.GET("/.well-known/jwks.json", api.GetJWKS)
+	e.GET("/.well-known/openid-configuration", api.GetOpenIDConfiguration)
+
+	// OpenID Connect login page, the form is protected by a double submit CSRF cookie
+	authorizeGroup := e.Group("/oauth/authorize", middlewares.RateLimitMiddleware(middlewares.RateLimitOptions{
+		Limiter: opts.RateLimiter,
+		Name:    "public",
+		Limit:   publicAuthLimit,
+		KeyFunc: middlewares.KeyByRoute(middlewares.KeyByIP),
+	}), middleware.CSRFWithConfig(middleware.CSRFConfig{
+		TokenLookup:    "form:_csrf",
+		CookieName:     "_oidc_csrf",
+		CookiePath:     "/oauth/authorize",
+		CookieHTTPOnly: true,
+		CookieSecure:   opts.SecureCookies,
+		CookieSameSite: http.SameSiteStrictMode,
+	}))
+	{
+		authorizeGroup.GET("", api.Authorize)
+		authorizeGroup.POST("", api.SubmitAuthorize)
+		authorizeGroup.POST("/mfa", api.SubmitAuthorizeMFA)
+	}
+	e.GET("/oauth/userinfo", api.UserInfo)
+	e.POST("/oauth/userinfo", api.UserInfo)
+
+	// OAuth 2.0 token endpoint for other services and OpenID Connect clients
+	e.POST("/oauth/token", api.IssueOAuthToken, middlewares.RateLimitMiddleware(middlewares.RateLimitOptions{
+		Limiter: opts.RateLimiter,
+		Name:    "oauth-token",
+		Limit:   publicAuthLimit,
+		KeyFunc: middlewares.KeyByIP,
+	}))
+
 	// without token
-	e.POST("/api/v1/accounts/register", api.RegisterUser)
-	e.POST("/api/v1/accounts/login", api.Login)
+	publicAuthGroup := e.Group("/api/v1/accounts", middlewares.RateLimitMiddleware(middlewares.RateLimitOptions{
+		Limiter: opts.RateLimiter,
+		Name:    "public",
+		Limit:   publicAuthLimit,
+		KeyFunc: middlewares.KeyByRoute(middlewares.KeyByIP),
+	}))
+	{
+		publicAuthGroup.POST("/register", api.RegisterUser)
+		publicAuthGroup.POST("/login", api.Login)
+		publicAuthGroup.POST("/login/mfa", api.VerifyMFALogin)
+		publicAuthGroup.POST("/login/magic", api.RequestMagicLogin)
+		publicAuthGroup.POST("/login/magic/verify", api.VerifyMagicLogin)
+		publicAuthGroup.POST("/login/passkey/options", api.BeginPasskeyLogin)
+		publicAuthGroup.POST("/login/passkey", api.PasskeyLogin)
+		publicAuthGroup.POST("/login/mfa/passkey/options", api.BeginPasskeyMFA)
+		publicAuthGroup.POST("/pin-login", api.PINLogin)
+		publicAuthGroup.POST("/token/refresh", api.RefreshToken)
+		publicAuthGroup.POST("/password/forgot", api.ForgotPassword)
+		publicAuthGroup.POST("/password/reset", api.ResetPassword)
+		publicAuthGroup.POST("/email/verify", api.VerifyEmail)
+		publicAuthGroup.POST("/email/resend", api.ResendVerification)
+		publicAuthGroup.POST("/unsubscribe", api.Unsubscribe)
+		publicAuthGroup.GET("/login/providers", api.ListIdentityProviders)
+		publicAuthGroup.POST("/login/providers/:provider", api.BeginFederatedLogin)
+		publicAuthGroup.POST("/login/federated/callback", api.FederatedLoginCallback)
 
-	// Logout Endpoint (requires token to be blacklisted, but not validated by this middleware)
-	// JWT parsing and blacklist logic is handled within the handler.Logout
-	e.POST("/api/v1/accounts/logout", api.Logout)
+		// Logout Endpoint (requires token to be blacklisted, but not validated by this middleware)
+		// JWT parsing and blacklist logic is handled within the handler.Logout
+		publicAuthGroup.POST("/logout", api.Logout)
+	}
 
 	// Requires JWT Authentication
 	// Create JWT authentication middleware
 	jwtAuthMiddleware := middlewares.AuthMiddleware(middlewares.AuthMiddlewareOptions{
-		TokenService: tokenService,
+		TokenService: opts.TokenService,
 	})
 
 	accountProtectedGroup := e.Group("/api/v1/accounts")
 	accountProtectedGroup.Use(jwtAuthMiddleware) // Apply JWT middleware
+	accountProtectedGroup.Use(middlewares.RateLimitMiddleware(middlewares.RateLimitOptions{
+		Limiter: opts.RateLimiter,
+		Name:    "authenticated",
+		Limit:   authenticatedLimit,
+		KeyFunc: middlewares.KeyByUserID,
+	}))
 	{
-		// all users
+		// all users, also with an unverified email
 		accountProtectedGroup.GET("/profile", api.GetUserProfile)
-		accountProtectedGroup.PUT("/update", api.UpdateUser)
-		accountProtectedGroup.DELETE("/delete/:id", api.DeleteUser)
+		accountProtectedGroup.GET("/sessions", api.GetSessions)
+		accountProtectedGroup.GET("/security-activity", api.GetSecurityActivity)
+		accountProtectedGroup.DELETE("/sessions/:jti", api.RevokeSession)
+		accountProtectedGroup.GET("/stores/memberships", api.GetMemberships)
+		accountProtectedGroup.POST("/stores/switch", api.SwitchStore, middlewares.RequireSession())
+	}
+
+	// In the "restricted" email verification mode the routes below need a verified email
+	verifiedGroup := accountProtectedGroup.Group("")
+	if opts.RequireVerifiedEmail {
+		verifiedGroup.Use(middlewares.RequireVerifiedEmail())
+	}
+	{
+		requireSession := middlewares.RequireSession()
+
+		// all users, never with an API key as they change the password and email
+		verifiedGroup.PUT("/update", api.UpdateUser, requireSession)
+		verifiedGroup.DELETE("/delete/:id", api.DeleteUser, requireSession)
+
+		// credentials, never with an API key
+		verifiedGroup.POST("/mfa/totp/enroll", api.EnrollTOTP, requireSession)
+		verifiedGroup.POST("/mfa/totp/confirm", api.ConfirmTOTP, requireSession)
+		verifiedGroup.PUT("/pin", api.SetPIN, requireSession)
+		verifiedGroup.DELETE("/pin", api.RemovePIN, requireSession)
+		verifiedGroup.GET("/api-keys", api.ListAPIKeys, requireSession)
+		verifiedGroup.POST("/api-keys", api.CreateAPIKey, requireSession)
+		verifiedGroup.GET("/api-keys/:id", api.GetAPIKey, requireSession)
+		verifiedGroup.PATCH("/api-keys/:id", api.UpdateAPIKey, requireSession)
+		verifiedGroup.DELETE("/api-keys/:id", api.RevokeAPIKey, requireSession)
+		verifiedGroup.GET("/identities", api.ListIdentities, requireSession)
+		verifiedGroup.POST("/identities/callback", api.LinkIdentityCallback, requireSession)
+		verifiedGroup.POST("/identities/:provider", api.BeginLinkIdentity, requireSession)
+		verifiedGroup.DELETE("/identities/:id", api.UnlinkIdentity, requireSession)
+		verifiedGroup.GET("/preferences", api.GetPreferences, requireSession)
+		verifiedGroup.PATCH("/preferences", api.UpdatePreferences, requireSession)
+		verifiedGroup.GET("/passkeys", api.ListPasskeys, requireSession)
+		verifiedGroup.POST("/passkeys/options", api.BeginPasskeyRegistration, requireSession)
+		verifiedGroup.POST("/passkeys", api.RegisterPasskey, requireSession)
+		verifiedGroup.DELETE("/passkeys/:id", api.DeletePasskey, requireSession)
+
+		// store owners and managers, checked per store
+		verifiedGroup.GET("/stores/:id/members", api.GetStoreMembers)
+		verifiedGroup.PUT("/stores/:id/members", api.SetStoreMember)
+		verifiedGroup.DELETE("/stores/:id/members/:user_id", api.RemoveStoreMember)
+		verifiedGroup.GET("/stores/:id/terminals", api.ListTerminals)
+		verifiedGroup.POST("/stores/:id/terminals", api.RegisterTerminal)
+		verifiedGroup.DELETE("/stores/:id/terminals/:terminal_id", api.RevokeTerminal)
 
 		// admin
-		accountProtectedGroup.GET("/list", api.GetAllUsers, middlewares.RequireRoles("admin"))
-		accountProtectedGroup.GET("/:id", api.GetUserById, middlewares.RequireRoles("admin"))
+		adminListRateLimit := middlewares.RateLimitMiddleware(middlewares.RateLimitOptions{
+			Limiter: opts.RateLimiter,
+			Name:    "admin-list",
+			Limit:   adminListLimit,
+			KeyFunc: middlewares.KeyByUserID,
+		})
+		verifiedGroup.GET("/list", api.ListUsers, middlewares.RequirePermission(entities.PermUsersRead), adminListRateLimit)
+		verifiedGroup.GET("/search", api.SearchUsers, middlewares.RequirePermission(entities.PermUsersRead), adminListRateLimit)
+		verifiedGroup.POST("/organizations", api.CreateOrganization, middlewares.RequirePermission(entities.PermTenantsManage))
+		verifiedGroup.POST("/organizations/:id/stores", api.CreateStore, middlewares.RequirePermission(entities.PermTenantsManage))
+		verifiedGroup.GET("/oauth-clients", api.ListOAuthClients, middlewares.RequirePermission(entities.PermClientsManage), requireSession)
+		verifiedGroup.POST("/oauth-clients", api.CreateOAuthClient, middlewares.RequirePermission(entities.PermClientsManage), requireSession)
+		verifiedGroup.GET("/oauth-clients/:id", api.GetOAuthClient, middlewares.RequirePermission(entities.PermClientsManage), requireSession)
+		verifiedGroup.PATCH("/oauth-clients/:id", api.UpdateOAuthClient, middlewares.RequirePermission(entities.PermClientsManage), requireSession)
+		verifiedGroup.DELETE("/oauth-clients/:id", api.RevokeOAuthClient, middlewares.RequirePermission(entities.PermClientsManage), requireSession)
+		verifiedGroup.GET("/roles", api.ListRoles, middlewares.RequirePermission(entities.PermRolesRead))
+		verifiedGroup.GET("/audit-events", api.ListAuditEvents, middlewares.RequirePermission(entities.PermAuditRead), adminListRateLimit)
+		verifiedGroup.GET("/campaigns", api.ListCampaigns, middlewares.RequirePermission(entities.PermCampaignsManage), requireSession)
+		verifiedGroup.POST("/campaigns", api.CreateCampaign, middlewares.RequirePermission(entities.PermCampaignsManage), requireSession)
+		verifiedGroup.GET("/campaigns/:id", api.GetCampaign, middlewares.RequirePermission(entities.PermCampaignsManage), requireSession)
+		verifiedGroup.POST("/campaigns/:id/preview", api.PreviewCampaign, middlewares.RequirePermission(entities.PermCampaignsManage), requireSession)
+		verifiedGroup.POST("/campaigns/:id/schedule", api.ScheduleCampaign, middlewares.RequirePermission(entities.PermCampaignsManage), requireSession)
+		verifiedGroup.POST("/campaigns/:id/cancel", api.CancelCampaign, middlewares.RequirePermission(entities.PermCampaignsManage), requireSession)
+		verifiedGroup.GET("/:id", api.GetUserById, middlewares.RequirePermission(entities.PermUsersRead))
+		verifiedGroup.POST("/:id/revoke-sessions", api.RevokeUserSessions, middlewares.RequirePermission(entities.PermUsersManage))
+		verifiedGroup.POST("/:id/mfa/reset", api.ResetMFA, middlewares.RequirePermission(entities.PermUsersManage))
+		verifiedGroup.POST("/:id/unlock", api.UnlockUser, middlewares.RequirePermission(entities.PermUsersManage))
+		verifiedGroup.GET("/:id/roles", api.GetUserRoles, middlewares.RequirePermission(entities.PermRolesRead))
+		verifiedGroup.POST("/:id/roles", api.GrantUserRole, middlewares.RequirePermission(entities.PermRolesManage))
+		verifiedGroup.DELETE("/:id/roles/:role", api.RevokeUserRole, middlewares.RequirePermission(entities.PermRolesManage))
 	}
 }
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+const (
+	maxAPIKeysPerUser   = 25
+	maxAPIKeyNameLength = 100
+)
+
+type APIKeyService interface {
+	// CreateAPIKey returns the new key together with its secret value, which is not stored.
+	CreateAPIKey(ctx context.Context, userID uuid.UUID, req *models.CreateAPIKeyRequest) (*entities.APIKey, string, error)
+	ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]entities.APIKey, error)
+	GetAPIKey(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*entities.APIKey, error)
+	RenameAPIKey(ctx context.Context, userID uuid.UUID, id uuid.UUID, name string) (*entities.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID uuid.UUID, id uuid.UUID) error
+}
+
+type APIKeyServiceImpl struct {
+	apiKeyRepo repositories.APIKeyRepository
+	roleRepo   repositories.RoleRepository
+	log        *logrus.Logger
+}
+
+func NewAPIKeyService(
+	apiKeyRepo repositories.APIKeyRepository,
+	roleRepo repositories.RoleRepository,
+	log *logrus.Logger,
+) APIKeyService {
+	return &APIKeyServiceImpl{
+		apiKeyRepo: apiKeyRepo,
+		roleRepo:   roleRepo,
+		log:        log,
+	}
+}
+
+func (s *APIKeyServiceImpl) CreateAPIKey(ctx context.Context, userID uuid.UUID, req *models.CreateAPIKeyRequest) (*entities.APIKey, string, error) {
+	name, err := validateAPIKeyName(req.Name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	scopes, err := s.validateScopes(ctx, userID, req.Scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var expiresAt sql.NullTime
+	if req.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: expires_at must be an RFC 3339 timestamp", apperrors.ErrInvalidRequestPayload)
+		}
+		if !t.After(time.Now()) {
+			return nil, "", fmt.Errorf("%w: expires_at must be in the future", apperrors.ErrInvalidRequestPayload)
+		}
+		expiresAt = sql.NullTime{Time: t, Valid: true}
+	}
+
+	count, err := s.apiKeyRepo.CountActiveAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, "", fmt.Errorf("service: failed to count api keys: %w", err)
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, "", fmt.Errorf("%w: at most %d api keys are allowed", apperrors.ErrInvalidRequestPayload, maxAPIKeysPerUser)
+	}
+
+	key, prefix, hash, err := token.NewAPIKey()
+	if err != nil {
+		return nil, "", fmt.Errorf("service: failed to generate api key: %w", err)
+	}
+
+	stored, err := s.apiKeyRepo.CreateAPIKey(ctx, &db.CreateAPIKeyParams{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("service: failed to create api key: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"user_id": userID,
+		"prefix":  prefix,
+		"scopes":  scopes,
+	}).Info("API key created")
+	return toAPIKey(stored), key, nil
+}
+
+func (s *APIKeyServiceImpl) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]entities.APIKey, error) {
+	rows, err := s.apiKeyRepo.ListUserAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list api keys: %w", err)
+	}
+
+	keys := make([]entities.APIKey, 0, len(rows))
+	for i := range rows {
+		keys = append(keys, *toAPIKey(&rows[i]))
+	}
+	return keys, nil
+}
+
+func (s *APIKeyServiceImpl) GetAPIKey(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*entities.APIKey, error) {
+	stored, err := s.apiKeyRepo.GetUserAPIKey(ctx, &db.GetUserAPIKeyParams{ID: id, UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	return toAPIKey(stored), nil
+}
+
+func (s *APIKeyServiceImpl) RenameAPIKey(ctx context.Context, userID uuid.UUID, id uuid.UUID, name string) (*entities.APIKey, error) {
+	name, err := validateAPIKeyName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.apiKeyRepo.RenameAPIKey(ctx, &db.RenameAPIKeyParams{ID: id, UserID: userID, Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return toAPIKey(stored), nil
+}
+
+// RevokeAPIKey takes effect immediately, keys are checked against the database on every request.
+func (s *APIKeyServiceImpl) RevokeAPIKey(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	revoked, err := s.apiKeyRepo.RevokeAPIKey(ctx, &db.RevokeAPIKeyParams{ID: id, UserID: userID})
+	if err != nil {
+		return fmt.Errorf("service: failed to revoke api key: %w", err)
+	}
+	if !revoked {
+		return fmt.Errorf("%w: api key %s", apperrors.ErrNotFound, id)
+	}
+
+	s.log.WithFields(logrus.Fields{"user_id": userID, "api_key_id": id}).Info("API key revoked")
+	return nil
+}
+
+// validateScopes only allows permissions the user currently holds, a key never grants more
+// than its owner has.
+func (s *APIKeyServiceImpl) validateScopes(ctx context.Context, userID uuid.UUID, requested []string) ([]string, error) {
+	held, err := s.roleRepo.GetUserPermissions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to get permissions: %w", err)
+	}
+	allowed := make(map[string]struct{}, len(held))
+	for _, p := range held {
+		allowed[p] = struct{}{}
+	}
+
+	seen := make(map[string]struct{}, len(requested))
+	scopes := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		scope = strings.TrimSpace(scope)
+		if _, dup := seen[scope]; dup {
+			continue
+		}
+		if _, ok := allowed[scope]; !ok {
+			return nil, fmt.Errorf("%w: scope %q is not one of your permissions", apperrors.ErrInvalidRequestPayload, scope)
+		}
+		seen[scope] = struct{}{}
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes, nil
+}
+
+func validateAPIKeyName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxAPIKeyNameLength {
+		return "", fmt.Errorf("%w: name is required and at most %d characters", apperrors.ErrInvalidRequestPayload, maxAPIKeyNameLength)
+	}
+	return name, nil
+}
+
+func toAPIKey(key *db.ApiKey) *entities.APIKey {
+	res := &entities.APIKey{
+		ID:        key.ID,
+		UserID:    key.UserID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if key.ExpiresAt.Valid {
+		res.ExpiresAt = &key.ExpiresAt.Time
+	}
+	if key.LastUsedAt.Valid {
+		res.LastUsedAt = &key.LastUsedAt.Time
+	}
+	return res
+}
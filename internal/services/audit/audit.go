@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+)
+
+// maxUserAgentLength caps the user agent stored with an event.
+const maxUserAgentLength = 512
+
+// RequestInfo describes the request an event happened in.
+type RequestInfo struct {
+	IPAddress string
+	UserAgent string
+	RequestID string
+}
+
+type contextKey int
+
+const (
+	requestKey contextKey = iota
+	actorKey
+)
+
+// WithRequest returns ctx carrying the request details recorded with every event.
+func WithRequest(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestKey, info)
+}
+
+// RequestFrom returns the request details of ctx, empty outside a request.
+func RequestFrom(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestKey).(RequestInfo)
+	return info
+}
+
+// WithActor returns ctx carrying the authenticated user, who is recorded as the actor.
+func WithActor(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, actorKey, userID)
+}
+
+// ActorFrom returns the authenticated user of ctx.
+func ActorFrom(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(actorKey).(uuid.UUID)
+	return userID, ok && userID != uuid.Nil
+}
+
+// Event is an action to record. The actor and request details are taken from the context.
+type Event struct {
+	Action string
+	// ActorID overrides the actor of the context, for actions taken before the user is
+	// authenticated, like a login or a registration.
+	ActorID      uuid.UUID
+	TargetUserID uuid.UUID
+	// Metadata is stored as JSON and must never contain secrets.
+	Metadata any
+}
+
+// Recorder writes the security audit log.
+type Recorder interface {
+	// Record stores the event. A failure is logged and not returned: an unavailable audit log
+	// must not lock users out, and the request log still has the request ID to correlate.
+	Record(ctx context.Context, event Event)
+}
+
+type recorder struct {
+	auditRepo repositories.AuditRepository
+	log       *logrus.Logger
+}
+
+func NewRecorder(auditRepo repositories.AuditRepository, log *logrus.Logger) Recorder {
+	return &recorder{auditRepo: auditRepo, log: log}
+}
+
+func (r *recorder) Record(ctx context.Context, event Event) {
+	request := RequestFrom(ctx)
+	if len(request.UserAgent) > maxUserAgentLength {
+		request.UserAgent = request.UserAgent[:maxUserAgentLength]
+	}
+
+	actorID := event.ActorID
+	if actorID == uuid.Nil {
+		actorID, _ = ActorFrom(ctx)
+	}
+
+	metadata := json.RawMessage("{}")
+	if event.Metadata != nil {
+		data, err := json.Marshal(event.Metadata)
+		if err != nil {
+			r.log.WithError(err).WithField("action", event.Action).Error("Failed to encode audit event metadata")
+		} else {
+			metadata = data
+		}
+	}
+
+	// The event is written even when the client already went away
+	err := r.auditRepo.CreateEvent(context.WithoutCancel(ctx), &db.CreateAuditEventParams{
+		ID:           uuid.New(),
+		Action:       event.Action,
+		ActorID:      uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil},
+		TargetUserID: uuid.NullUUID{UUID: event.TargetUserID, Valid: event.TargetUserID != uuid.Nil},
+		IpAddress:    request.IPAddress,
+		UserAgent:    request.UserAgent,
+		RequestID:    request.RequestID,
+		Metadata:     metadata,
+	})
+	if err != nil {
+		r.log.WithFields(logrus.Fields{
+			"action":         event.Action,
+			"actor_id":       actorID,
+			"target_user_id": event.TargetUserID,
+			"request_id":     request.RequestID,
+		}).WithError(err).Error("Failed to record audit event")
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// AuditEventPage is one page of the audit log, newest first.
+type AuditEventPage struct {
+	Events     []entities.AuditEvent
+	NextCursor string
+}
+
+// AuditService reads the security audit log. Events are written by the services that perform
+// the actions, through audit.Recorder.
+type AuditService interface {
+	// ListEvents returns one page of the whole log. Pass NextCursor back as query.Cursor to get
+	// the next page; it is empty on the last page.
+	ListEvents(ctx context.Context, query *models.AuditEventQuery) (*AuditEventPage, error)
+	// ListUserActivity returns the events about the user's own account, paged like ListEvents.
+	ListUserActivity(ctx context.Context, userID uuid.UUID, query *models.AuditEventQuery) (*AuditEventPage, error)
+}
+
+type AuditServiceImpl struct {
+	auditRepo repositories.AuditRepository
+	log       *logrus.Logger
+}
+
+func NewAuditService(auditRepo repositories.AuditRepository, log *logrus.Logger) AuditService {
+	return &AuditServiceImpl{auditRepo: auditRepo, log: log}
+}
+
+func (s *AuditServiceImpl) ListEvents(ctx context.Context, query *models.AuditEventQuery) (*AuditEventPage, error) {
+	params, err := toListAuditEventsParams(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.ActorID != "" {
+		actorID, err := uuid.Parse(query.ActorID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: actor_id must be a UUID", apperrors.ErrInvalidRequestPayload)
+		}
+		params.ActorID = uuid.NullUUID{UUID: actorID, Valid: true}
+	}
+
+	if query.TargetUserID != "" {
+		targetUserID, err := uuid.Parse(query.TargetUserID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: target_user_id must be a UUID", apperrors.ErrInvalidRequestPayload)
+		}
+		params.TargetUserID = uuid.NullUUID{UUID: targetUserID, Valid: true}
+	}
+
+	return s.listEvents(ctx, params)
+}
+
+func (s *AuditServiceImpl) ListUserActivity(ctx context.Context, userID uuid.UUID, query *models.AuditEventQuery) (*AuditEventPage, error) {
+	params, err := toListAuditEventsParams(query)
+	if err != nil {
+		return nil, err
+	}
+	params.TargetUserID = uuid.NullUUID{UUID: userID, Valid: true}
+
+	return s.listEvents(ctx, params)
+}
+
+func (s *AuditServiceImpl) listEvents(ctx context.Context, params *db.ListAuditEventsParams) (*AuditEventPage, error) {
+	limit := params.PageLimit
+	// Fetch one extra row to know whether there is a next page
+	params.PageLimit++
+
+	rows, err := s.auditRepo.ListEvents(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list audit events: %w", err)
+	}
+
+	page := &AuditEventPage{}
+	if int32(len(rows)) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeAuditCursor(auditCursor{ID: last.ID, CreatedAt: last.CreatedAt})
+	}
+
+	page.Events = make([]entities.AuditEvent, 0, len(rows))
+	for _, row := range rows {
+		page.Events = append(page.Events, toDomainAuditEvent(row))
+	}
+
+	return page, nil
+}
+
+func toListAuditEventsParams(query *models.AuditEventQuery) (*db.ListAuditEventsParams, error) {
+	params := &db.ListAuditEventsParams{
+		PageLimit: int32(query.Limit),
+	}
+
+	switch {
+	case params.PageLimit == 0:
+		params.PageLimit = defaultAuditPageSize
+	case params.PageLimit < 0 || params.PageLimit > maxAuditPageSize:
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", apperrors.ErrInvalidRequestPayload, maxAuditPageSize)
+	}
+
+	if query.Action != "" {
+		params.Action = sql.NullString{String: query.Action, Valid: true}
+	}
+
+	if query.CreatedFrom != "" {
+		from, err := time.Parse(time.RFC3339, query.CreatedFrom)
+		if err != nil {
+			return nil, fmt.Errorf("%w: created_from must be an RFC 3339 timestamp", apperrors.ErrInvalidRequestPayload)
+		}
+		params.CreatedFrom = sql.NullTime{Time: from, Valid: true}
+	}
+
+	if query.CreatedTo != "" {
+		to, err := time.Parse(time.RFC3339, query.CreatedTo)
+		if err != nil {
+			return nil, fmt.Errorf("%w: created_to must be an RFC 3339 timestamp", apperrors.ErrInvalidRequestPayload)
+		}
+		params.CreatedTo = sql.NullTime{Time: to, Valid: true}
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeAuditCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+	}
+
+	return params, nil
+}
+
+// auditCursor is the position after the last event of a page.
+type auditCursor struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"c"`
+}
+
+func encodeAuditCursor(cursor auditCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeAuditCursor(value string) (*auditCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", apperrors.ErrInvalidRequestPayload)
+	}
+
+	var cursor auditCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == uuid.Nil {
+		return nil, fmt.Errorf("%w: malformed cursor", apperrors.ErrInvalidRequestPayload)
+	}
+	return &cursor, nil
+}
+
+func toDomainAuditEvent(row db.AuditEvent) entities.AuditEvent {
+	event := entities.AuditEvent{
+		ID:        row.ID,
+		Action:    row.Action,
+		IPAddress: row.IpAddress,
+		UserAgent: row.UserAgent,
+		RequestID: row.RequestID,
+		Metadata:  row.Metadata,
+		CreatedAt: row.CreatedAt,
+	}
+	if row.ActorID.Valid {
+		event.ActorID = &row.ActorID.UUID
+	}
+	if row.TargetUserID.Valid {
+		event.TargetUserID = &row.TargetUserID.UUID
+	}
+
+	return event
+}
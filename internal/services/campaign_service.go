@@ -0,0 +1,623 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/notifier"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+const (
+	defaultCampaignPageSize = 50
+	maxCampaignPageSize     = 200
+	// maxCampaignsPerDispatch bounds how many due campaigns one dispatch run claims.
+	maxCampaignsPerDispatch = 10
+	// maxDeliveryErrorLength keeps a verbose SMTP error from bloating the delivery row.
+	maxDeliveryErrorLength = 500
+)
+
+// sampleCampaignRecipient is rendered when a campaign is created, to reject templates that
+// use fields recipients do not have.
+var sampleCampaignRecipient = entities.CampaignTemplateData{
+	Name:           "Jane Doe",
+	Username:       "jane",
+	Email:          "jane@example.com",
+	UnsubscribeURL: "https://example.com/unsubscribe",
+}
+
+// CampaignOptions configures the campaign service. BatchSize is how many deliveries one
+// RabbitMQ message carries. A campaign whose dispatch did not finish within StaleAfter is
+// dispatched again. UnsubscribeURL is the frontend page the unsubscribe token is appended to.
+type CampaignOptions struct {
+	BatchSize      int32
+	StaleAfter     time.Duration
+	UnsubscribeURL string
+	UnsubscribeTTL time.Duration
+}
+
+// CampaignService manages email campaigns. The web server creates and schedules them, the
+// worker dispatches due campaigns by creating one delivery per recipient and publishing them in
+// batches, and the consumers of those batches send the mails.
+type CampaignService interface {
+	CreateCampaign(ctx context.Context, createdBy uuid.UUID, req *models.CreateCampaignRequest) (*entities.Campaign, error)
+	ListCampaigns(ctx context.Context, query *models.CampaignListQuery) ([]entities.Campaign, error)
+	GetCampaign(ctx context.Context, id uuid.UUID) (*entities.Campaign, error)
+	// PreviewCampaign renders the campaign for the given user and counts its current recipients.
+	PreviewCampaign(ctx context.Context, id uuid.UUID, sampleUserID uuid.UUID) (*entities.CampaignPreview, error)
+	ScheduleCampaign(ctx context.Context, id uuid.UUID, req *models.ScheduleCampaignRequest) (*entities.Campaign, error)
+	CancelCampaign(ctx context.Context, id uuid.UUID) (*entities.Campaign, error)
+	// DispatchDue fans out the campaigns that are due and returns how many it dispatched.
+	DispatchDue(ctx context.Context) (int, error)
+	// DeliverBatch sends the mails of one delivery batch. Deliveries that were already handled
+	// are skipped, so a redelivered batch does not mail anybody twice.
+	DeliverBatch(ctx context.Context, batch *entities.CampaignDeliveryBatch) error
+}
+
+type CampaignServiceImpl struct {
+	campaignRepo   repositories.CampaignRepository
+	userRepo       repositories.UserRepository
+	preferenceRepo repositories.PreferenceRepository
+	tokenService   token.TokenService
+	notifier       notifier.Notifier
+	publisher      EventPublisher
+	validator      *validator.Validate
+	opts           CampaignOptions
+	log            *logrus.Logger
+}
+
+func NewCampaignService(
+	campaignRepo repositories.CampaignRepository,
+	userRepo repositories.UserRepository,
+	preferenceRepo repositories.PreferenceRepository,
+	tokenService token.TokenService,
+	notifier notifier.Notifier,
+	publisher EventPublisher,
+	validator *validator.Validate,
+	opts CampaignOptions,
+	log *logrus.Logger,
+) CampaignService {
+	return &CampaignServiceImpl{
+		campaignRepo:   campaignRepo,
+		userRepo:       userRepo,
+		preferenceRepo: preferenceRepo,
+		tokenService:   tokenService,
+		notifier:       notifier,
+		publisher:      publisher,
+		validator:      validator,
+		opts:           opts,
+		log:            log,
+	}
+}
+
+func (s *CampaignServiceImpl) CreateCampaign(ctx context.Context, createdBy uuid.UUID, req *models.CreateCampaignRequest) (*entities.Campaign, error) {
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("%w: %s", apperrors.ErrInvalidRequestPayload, err)
+	}
+
+	tmpl, err := parseCampaignTemplates(req.Subject, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := tmpl.render(sampleCampaignRecipient); err != nil {
+		return nil, fmt.Errorf("%w: %s", apperrors.ErrInvalidRequestPayload, err)
+	}
+
+	param := &db.CreateEmailCampaignParams{
+		Name:         strings.TrimSpace(req.Name),
+		Subject:      req.Subject,
+		Body:         req.Body,
+		SegmentRoles: []string{},
+		CreatedBy:    uuid.NullUUID{UUID: createdBy, Valid: true},
+	}
+
+	seen := make(map[string]bool)
+	for _, role := range req.Segment.Roles {
+		role = strings.TrimSpace(role)
+		if role == "" || seen[role] {
+			continue
+		}
+		seen[role] = true
+		param.SegmentRoles = append(param.SegmentRoles, role)
+	}
+
+	if param.SegmentSignedUpAfter, err = parseOptionalTime("segment.signed_up_after", req.Segment.SignedUpAfter); err != nil {
+		return nil, err
+	}
+	if param.SegmentSignedUpBefore, err = parseOptionalTime("segment.signed_up_before", req.Segment.SignedUpBefore); err != nil {
+		return nil, err
+	}
+	if req.Segment.EmailVerified != nil {
+		param.SegmentEmailVerified = sql.NullBool{Bool: *req.Segment.EmailVerified, Valid: true}
+	}
+
+	stored, err := s.campaignRepo.CreateCampaign(ctx, param)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to create campaign: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"campaign_id": stored.ID,
+		"created_by":  createdBy,
+	}).Info("Campaign created")
+
+	return toCampaign(stored), nil
+}
+
+func (s *CampaignServiceImpl) ListCampaigns(ctx context.Context, query *models.CampaignListQuery) ([]entities.Campaign, error) {
+	params := &db.ListEmailCampaignsParams{PageLimit: int32(query.Limit)}
+
+	switch {
+	case params.PageLimit == 0:
+		params.PageLimit = defaultCampaignPageSize
+	case params.PageLimit < 0 || params.PageLimit > maxCampaignPageSize:
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", apperrors.ErrInvalidRequestPayload, maxCampaignPageSize)
+	}
+
+	switch query.Status {
+	case "":
+	case entities.CampaignStatusDraft, entities.CampaignStatusScheduled, entities.CampaignStatusSending,
+		entities.CampaignStatusSent, entities.CampaignStatusCancelled:
+		params.Status = sql.NullString{String: query.Status, Valid: true}
+	default:
+		return nil, fmt.Errorf("%w: unsupported status %q", apperrors.ErrInvalidRequestPayload, query.Status)
+	}
+
+	rows, err := s.campaignRepo.ListCampaigns(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list campaigns: %w", err)
+	}
+
+	campaigns := make([]entities.Campaign, 0, len(rows))
+	for i := range rows {
+		campaigns = append(campaigns, *toCampaign(&rows[i]))
+	}
+	return campaigns, nil
+}
+
+func (s *CampaignServiceImpl) GetCampaign(ctx context.Context, id uuid.UUID) (*entities.Campaign, error) {
+	stored, err := s.campaignRepo.GetCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.campaignRepo.CountDeliveries(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to count deliveries: %w", err)
+	}
+
+	campaign := toCampaign(stored)
+	campaign.Deliveries = make(map[string]int64, len(counts))
+	for _, c := range counts {
+		campaign.Deliveries[c.Status] = c.Count
+	}
+	return campaign, nil
+}
+
+func (s *CampaignServiceImpl) PreviewCampaign(ctx context.Context, id uuid.UUID, sampleUserID uuid.UUID) (*entities.CampaignPreview, error) {
+	stored, err := s.campaignRepo.GetCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, sampleUserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("service: failed to get user: %w", err)
+	}
+
+	tmpl, err := parseCampaignTemplates(stored.Subject, stored.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// A real token would unsubscribe whoever previews the campaign
+	subject, body, err := tmpl.render(entities.CampaignTemplateData{
+		Name:           user.Name,
+		Username:       user.Username,
+		Email:          user.Email,
+		UnsubscribeURL: s.opts.UnsubscribeURL + "?token=preview",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", apperrors.ErrInvalidRequestPayload, err)
+	}
+
+	count, err := s.campaignRepo.CountRecipients(ctx, &db.CountEmailCampaignRecipientsParams{
+		Roles:          stored.SegmentRoles,
+		SignedUpAfter:  stored.SegmentSignedUpAfter,
+		SignedUpBefore: stored.SegmentSignedUpBefore,
+		EmailVerified:  stored.SegmentEmailVerified,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to count recipients: %w", err)
+	}
+
+	return &entities.CampaignPreview{
+		To:             user.Email,
+		Subject:        subject,
+		Body:           body,
+		RecipientCount: count,
+	}, nil
+}
+
+func (s *CampaignServiceImpl) ScheduleCampaign(ctx context.Context, id uuid.UUID, req *models.ScheduleCampaignRequest) (*entities.Campaign, error) {
+	scheduledAt := time.Now()
+	if req.ScheduledAt != "" {
+		t, err := time.Parse(time.RFC3339, req.ScheduledAt)
+		if err != nil {
+			return nil, fmt.Errorf("%w: scheduled_at must be an RFC 3339 timestamp", apperrors.ErrInvalidRequestPayload)
+		}
+		if t.Before(scheduledAt) {
+			return nil, fmt.Errorf("%w: scheduled_at must not be in the past", apperrors.ErrInvalidRequestPayload)
+		}
+		scheduledAt = t
+	}
+
+	// Tells a missing campaign apart from one that can not be scheduled anymore
+	if _, err := s.campaignRepo.GetCampaign(ctx, id); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.campaignRepo.ScheduleCampaign(ctx, &db.ScheduleEmailCampaignParams{
+		ID:          id,
+		ScheduledAt: sql.NullTime{Time: scheduledAt, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"campaign_id":  id,
+		"scheduled_at": scheduledAt,
+	}).Info("Campaign scheduled")
+
+	return toCampaign(stored), nil
+}
+
+func (s *CampaignServiceImpl) CancelCampaign(ctx context.Context, id uuid.UUID) (*entities.Campaign, error) {
+	if _, err := s.campaignRepo.GetCampaign(ctx, id); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.campaignRepo.CancelCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.WithField("campaign_id", id).Info("Campaign cancelled")
+	return toCampaign(stored), nil
+}
+
+func (s *CampaignServiceImpl) DispatchDue(ctx context.Context) (int, error) {
+	campaigns, err := s.campaignRepo.ClaimDueCampaigns(ctx, &db.ClaimDueEmailCampaignsParams{
+		StaleBefore: time.Now().Add(-s.opts.StaleAfter),
+		PageLimit:   maxCampaignsPerDispatch,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("service: failed to claim due campaigns: %w", err)
+	}
+
+	dispatched := 0
+	var firstErr error
+	for i := range campaigns {
+		// A failed campaign is picked up again once its dispatch went stale
+		if err := s.dispatch(ctx, &campaigns[i]); err != nil {
+			s.log.WithError(err).WithField("campaign_id", campaigns[i].ID).Error("Failed to dispatch campaign")
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		dispatched++
+	}
+
+	return dispatched, firstErr
+}
+
+// dispatch creates the deliveries of a claimed campaign and publishes the pending ones. Both
+// steps are idempotent, so a campaign whose dispatch was interrupted can simply be dispatched
+// again.
+func (s *CampaignServiceImpl) dispatch(ctx context.Context, campaign *db.EmailCampaign) error {
+	var afterID uuid.UUID
+	for {
+		recipients, err := s.campaignRepo.ListRecipients(ctx, &db.ListEmailCampaignRecipientsParams{
+			Roles:          campaign.SegmentRoles,
+			SignedUpAfter:  campaign.SegmentSignedUpAfter,
+			SignedUpBefore: campaign.SegmentSignedUpBefore,
+			EmailVerified:  campaign.SegmentEmailVerified,
+			AfterID:        afterID,
+			PageLimit:      s.opts.BatchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("service: failed to list recipients: %w", err)
+		}
+		if len(recipients) == 0 {
+			break
+		}
+
+		userIDs := make([]uuid.UUID, 0, len(recipients))
+		emails := make([]string, 0, len(recipients))
+		for _, r := range recipients {
+			userIDs = append(userIDs, r.ID)
+			emails = append(emails, r.Email)
+		}
+
+		_, err = s.campaignRepo.CreateDeliveries(ctx, &db.CreateEmailCampaignDeliveriesParams{
+			CampaignID: campaign.ID,
+			UserIds:    userIDs,
+			Emails:     emails,
+		})
+		if err != nil {
+			return fmt.Errorf("service: failed to create deliveries: %w", err)
+		}
+
+		afterID = userIDs[len(userIDs)-1]
+		if int32(len(recipients)) < s.opts.BatchSize {
+			break
+		}
+	}
+
+	var afterDeliveryID uuid.UUID
+	batches := 0
+	for {
+		deliveryIDs, err := s.campaignRepo.ListPendingDeliveries(ctx, &db.ListPendingEmailCampaignDeliveriesParams{
+			CampaignID: campaign.ID,
+			ID:         afterDeliveryID,
+			Limit:      s.opts.BatchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("service: failed to list pending deliveries: %w", err)
+		}
+		if len(deliveryIDs) == 0 {
+			break
+		}
+
+		body, err := json.Marshal(&entities.CampaignDeliveryBatch{
+			CampaignID:  campaign.ID,
+			DeliveryIDs: deliveryIDs,
+		})
+		if err != nil {
+			return fmt.Errorf("service: failed to encode delivery batch: %w", err)
+		}
+		if err := s.publisher.Publish(ctx, entities.CampaignDeliveryRoutingKey, uuid.NewString(), body); err != nil {
+			return fmt.Errorf("service: failed to publish delivery batch: %w", err)
+		}
+		batches++
+
+		afterDeliveryID = deliveryIDs[len(deliveryIDs)-1]
+		if int32(len(deliveryIDs)) < s.opts.BatchSize {
+			break
+		}
+	}
+
+	counts, err := s.campaignRepo.CountDeliveries(ctx, campaign.ID)
+	if err != nil {
+		return fmt.Errorf("service: failed to count deliveries: %w", err)
+	}
+	var recipients int64
+	for _, c := range counts {
+		recipients += c.Count
+	}
+
+	err = s.campaignRepo.MarkDispatched(ctx, &db.MarkEmailCampaignDispatchedParams{
+		ID:             campaign.ID,
+		RecipientCount: int32(recipients),
+	})
+	if err != nil {
+		return fmt.Errorf("service: failed to mark campaign dispatched: %w", err)
+	}
+
+	// Completes campaigns without recipients or whose batches were all handled already
+	if _, err := s.campaignRepo.CompleteCampaign(ctx, campaign.ID); err != nil {
+		return fmt.Errorf("service: failed to complete campaign: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"campaign_id": campaign.ID,
+		"recipients":  recipients,
+		"batches":     batches,
+	}).Info("Campaign dispatched")
+	return nil
+}
+
+func (s *CampaignServiceImpl) DeliverBatch(ctx context.Context, batch *entities.CampaignDeliveryBatch) error {
+	campaign, err := s.campaignRepo.GetCampaign(ctx, batch.CampaignID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			s.log.WithField("campaign_id", batch.CampaignID).Debug("Dropping delivery batch of a deleted campaign")
+			return nil
+		}
+		return fmt.Errorf("service: failed to get campaign: %w", err)
+	}
+	if campaign.Status != entities.CampaignStatusSending {
+		// Cancelled in the meantime, its pending deliveries were skipped already
+		return nil
+	}
+
+	tmpl, tmplErr := parseCampaignTemplates(campaign.Subject, campaign.Body)
+
+	for _, id := range batch.DeliveryIDs {
+		delivery, err := s.campaignRepo.ClaimDelivery(ctx, id)
+		if err != nil {
+			if errors.Is(err, apperrors.ErrNotFound) {
+				continue
+			}
+			return fmt.Errorf("service: failed to claim delivery: %w", err)
+		}
+
+		status, reason := entities.DeliveryStatusFailed, ""
+		if tmplErr != nil {
+			reason = tmplErr.Error()
+		} else {
+			status, reason = s.deliver(ctx, tmpl, delivery)
+		}
+		if len(reason) > maxDeliveryErrorLength {
+			reason = reason[:maxDeliveryErrorLength]
+		}
+
+		err = s.campaignRepo.FinishDelivery(ctx, &db.FinishEmailCampaignDeliveryParams{
+			ID:     delivery.ID,
+			Status: status,
+			Error:  reason,
+		})
+		if err != nil {
+			return fmt.Errorf("service: failed to finish delivery: %w", err)
+		}
+	}
+
+	completed, err := s.campaignRepo.CompleteCampaign(ctx, campaign.ID)
+	if err != nil {
+		return fmt.Errorf("service: failed to complete campaign: %w", err)
+	}
+	if completed {
+		s.log.WithField("campaign_id", campaign.ID).Info("Campaign sent")
+	}
+
+	return nil
+}
+
+// deliver sends one campaign mail and returns the final status of the delivery with the reason
+// it was not sent. Recipients that were deleted or unsubscribed since the dispatch are skipped.
+// A failed delivery is not retried, so one broken address can not hold up the whole batch.
+func (s *CampaignServiceImpl) deliver(ctx context.Context, tmpl *campaignTemplates, delivery *db.EmailCampaignDelivery) (string, string) {
+	user, err := s.userRepo.GetUserByID(ctx, delivery.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.DeliveryStatusSkipped, "user deleted"
+		}
+		return entities.DeliveryStatusFailed, err.Error()
+	}
+
+	prefs, err := s.preferenceRepo.GetPreferences(ctx, delivery.UserID)
+	if err != nil {
+		return entities.DeliveryStatusFailed, err.Error()
+	}
+	if !prefs.MarketingEmails {
+		return entities.DeliveryStatusSkipped, "unsubscribed"
+	}
+
+	unsubscribeToken, _, err := s.tokenService.GeneratePurposeToken(ctx, token.PurposeSubject{UserID: user.ID}, token.PurposeUnsubscribe, s.opts.UnsubscribeTTL)
+	if err != nil {
+		return entities.DeliveryStatusFailed, err.Error()
+	}
+	unsubscribeURL := s.opts.UnsubscribeURL + "?token=" + url.QueryEscape(unsubscribeToken)
+
+	subject, body, err := tmpl.render(entities.CampaignTemplateData{
+		Name:           user.Name,
+		Username:       user.Username,
+		Email:          user.Email,
+		UnsubscribeURL: unsubscribeURL,
+	})
+	if err != nil {
+		return entities.DeliveryStatusFailed, err.Error()
+	}
+
+	err = s.notifier.Send(ctx, notifier.Message{
+		// The address the delivery was created for, a later email change is not mailed
+		To:      delivery.Email,
+		Subject: subject,
+		Body:    body,
+		Headers: map[string]string{"List-Unsubscribe": "<" + unsubscribeURL + ">"},
+	})
+	if err != nil {
+		return entities.DeliveryStatusFailed, err.Error()
+	}
+
+	return entities.DeliveryStatusSent, ""
+}
+
+type campaignTemplates struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+func parseCampaignTemplates(subject string, body string) (*campaignTemplates, error) {
+	subjectTmpl, err := template.New("subject").Option("missingkey=error").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid subject template: %s", apperrors.ErrInvalidRequestPayload, err)
+	}
+	bodyTmpl, err := template.New("body").Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid body template: %s", apperrors.ErrInvalidRequestPayload, err)
+	}
+	return &campaignTemplates{subject: subjectTmpl, body: bodyTmpl}, nil
+}
+
+func (t *campaignTemplates) render(data entities.CampaignTemplateData) (string, string, error) {
+	var subject, body strings.Builder
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return "", "", fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return "", "", fmt.Errorf("failed to render body: %w", err)
+	}
+	return strings.TrimSpace(subject.String()), body.String(), nil
+}
+
+func parseOptionalTime(field string, value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return sql.NullTime{}, fmt.Errorf("%w: %s must be an RFC 3339 timestamp", apperrors.ErrInvalidRequestPayload, field)
+	}
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
+
+func toCampaign(c *db.EmailCampaign) *entities.Campaign {
+	res := &entities.Campaign{
+		ID:             c.ID,
+		Name:           c.Name,
+		Subject:        c.Subject,
+		Body:           c.Body,
+		Status:         c.Status,
+		RecipientCount: c.RecipientCount,
+		CreatedAt:      c.CreatedAt,
+		UpdatedAt:      c.UpdatedAt,
+		Segment: entities.CampaignSegment{
+			Roles:          c.SegmentRoles,
+			SignedUpAfter:  nullTimePtr(c.SegmentSignedUpAfter),
+			SignedUpBefore: nullTimePtr(c.SegmentSignedUpBefore),
+		},
+		ScheduledAt:  nullTimePtr(c.ScheduledAt),
+		StartedAt:    nullTimePtr(c.StartedAt),
+		DispatchedAt: nullTimePtr(c.DispatchedAt),
+		CompletedAt:  nullTimePtr(c.CompletedAt),
+		CancelledAt:  nullTimePtr(c.CancelledAt),
+	}
+	if c.SegmentEmailVerified.Valid {
+		verified := c.SegmentEmailVerified.Bool
+		res.Segment.EmailVerified = &verified
+	}
+	if c.CreatedBy.Valid {
+		createdBy := c.CreatedBy.UUID
+		res.CreatedBy = &createdBy
+	}
+	return res
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
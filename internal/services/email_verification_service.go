@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/notifier"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+// Values of EMAIL_VERIFICATION_MODE.
+const (
+	EmailVerificationOff        = "off"
+	EmailVerificationDeny       = "deny"
+	EmailVerificationRestricted = "restricted"
+)
+
+const (
+	// verificationResendLimit caps how many links can be requested per address and window.
+	verificationResendLimit  = 3
+	verificationResendWindow = time.Hour
+)
+
+type EmailVerificationService interface {
+	SendVerification(ctx context.Context, user *entities.User) error
+	ResendVerification(ctx context.Context, req *models.ResendVerificationRequest) error
+	VerifyEmail(ctx context.Context, req *models.VerifyEmailRequest) error
+}
+
+type EmailVerificationServiceImpl struct {
+	userRepo     repositories.UserRepository
+	attemptRepo  repositories.AttemptRepository
+	tokenService token.TokenService
+	notifier     notifier.Notifier
+	validator    *validator.Validate
+	tokenTTL     time.Duration
+	verifyURL    string
+	log          *logrus.Logger
+}
+
+func NewEmailVerificationService(
+	userRepo repositories.UserRepository,
+	attemptRepo repositories.AttemptRepository,
+	tokenService token.TokenService,
+	notifier notifier.Notifier,
+	validator *validator.Validate,
+	tokenTTL time.Duration,
+	verifyURL string,
+	log *logrus.Logger,
+) EmailVerificationService {
+	return &EmailVerificationServiceImpl{
+		userRepo:     userRepo,
+		attemptRepo:  attemptRepo,
+		tokenService: tokenService,
+		notifier:     notifier,
+		validator:    validator,
+		tokenTTL:     tokenTTL,
+		verifyURL:    verifyURL,
+		log:          log,
+	}
+}
+
+// SendVerification mails a signed link bound to the user's current email address.
+func (s *EmailVerificationServiceImpl) SendVerification(ctx context.Context, user *entities.User) error {
+	if user.EmailVerified() {
+		return nil
+	}
+
+	verifyToken, _, err := s.tokenService.GeneratePurposeToken(ctx, token.PurposeSubject{
+		UserID: user.ID,
+		Email:  user.Email,
+	}, token.PurposeEmailVerification, s.tokenTTL)
+	if err != nil {
+		return fmt.Errorf("service: failed to generate verification token: %w", err)
+	}
+
+	err = s.notifier.Send(ctx, notifier.Message{
+		To:      user.Email,
+		Subject: "Verify your Shopeezy email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s",
+			user.Name, s.tokenTTL, s.verificationLink(verifyToken),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("service: failed to send verification email: %w", err)
+	}
+
+	return nil
+}
+
+// ResendVerification behaves the same for unknown, verified and throttled addresses so it does
+// not reveal which emails are registered.
+func (s *EmailVerificationServiceImpl) ResendVerification(ctx context.Context, req *models.ResendVerificationRequest) error {
+	if err := s.validator.Struct(req); err != nil {
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidRequestPayload, err)
+	}
+
+	attempts, err := s.attemptRepo.Increment(ctx, "email_verification:"+req.Email, verificationResendWindow)
+	if err != nil {
+		return fmt.Errorf("service: failed to count verification resend: %w", err)
+	}
+	if attempts > verificationResendLimit {
+		s.log.WithField("attempts", attempts).Warn("Verification resend limit reached")
+		return nil
+	}
+
+	userDB, err := s.userRepo.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("service: failed to resend verification: %w", err)
+	}
+
+	return s.SendVerification(ctx, toDomainUser(userDB))
+}
+
+func (s *EmailVerificationServiceImpl) VerifyEmail(ctx context.Context, req *models.VerifyEmailRequest) error {
+	if err := s.validator.Struct(req); err != nil {
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidRequestPayload, err)
+	}
+
+	claims, err := s.tokenService.ValidatePurposeToken(ctx, req.Token, token.PurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	// Only matches while the account still has the address the link was sent to
+	updated, err := s.userRepo.MarkEmailVerified(ctx, &db.MarkUserEmailVerifiedParams{
+		ID:    claims.UserID,
+		Email: claims.Email,
+	})
+	if err != nil {
+		return fmt.Errorf("service: failed to verify email: %w", err)
+	}
+	if !updated {
+		user, err := s.userRepo.GetUserByID(ctx, claims.UserID)
+		if err != nil || user.Email != claims.Email {
+			return apperrors.ErrInvalidToken
+		}
+		// Already verified, clicking the link twice is fine
+	}
+
+	if err := s.tokenService.BlacklistToken(ctx, claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+		return fmt.Errorf("service: failed to consume verification token: %w", err)
+	}
+
+	s.log.WithField("user_id", claims.UserID).Info("Email address verified")
+	return nil
+}
+
+func (s *EmailVerificationServiceImpl) verificationLink(verifyToken string) string {
+	u, err := url.Parse(s.verifyURL)
+	if err != nil {
+		return s.verifyURL + "?token=" + url.QueryEscape(verifyToken)
+	}
+
+	q := u.Query()
+	q.Set("token", verifyToken)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
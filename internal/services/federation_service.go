@@ -0,0 +1,457 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/identity"
+)
+
+const (
+	// federatedUsernameAttempts bounds the retries with a random suffix when a derived
+	// username or name is already taken.
+	federatedUsernameAttempts = 5
+	maxFederatedUsernameLen   = 30
+	minFederatedUsernameLen   = 3
+)
+
+// FederationOptions configures federated login. RedirectURL is the callback page registered
+// at every provider; it hands code and state to CompleteLogin or CompleteLink.
+type FederationOptions struct {
+	RedirectURL           string
+	StateTTL              time.Duration
+	EmailVerificationMode string
+}
+
+// FederatedAuthorization is where the browser has to be sent to sign in at the provider.
+type FederatedAuthorization struct {
+	URL   string
+	State string
+}
+
+// FederatedLogin is the result of a federated login. Created is set when the login created
+// the account.
+type FederatedLogin struct {
+	User    *entities.User
+	Created bool
+}
+
+type FederationService interface {
+	// Providers lists the names of the configured identity providers.
+	Providers() []string
+	BeginLogin(ctx context.Context, provider string) (*FederatedAuthorization, error)
+	CompleteLogin(ctx context.Context, req *models.FederatedCallbackRequest) (*FederatedLogin, error)
+	BeginLink(ctx context.Context, userID uuid.UUID, provider string) (*FederatedAuthorization, error)
+	CompleteLink(ctx context.Context, userID uuid.UUID, req *models.FederatedCallbackRequest) (*entities.UserIdentity, error)
+	ListIdentities(ctx context.Context, userID uuid.UUID) ([]entities.UserIdentity, error)
+	UnlinkIdentity(ctx context.Context, userID uuid.UUID, id uuid.UUID) error
+}
+
+type FederationServiceImpl struct {
+	providers     map[string]identity.Provider
+	providerNames []string
+	identityRepo  repositories.IdentityRepository
+	stateRepo     repositories.FederationStateRepository
+	userRepo      repositories.UserRepository
+	opts          FederationOptions
+	log           *logrus.Logger
+}
+
+func NewFederationService(
+	providers []identity.Provider,
+	identityRepo repositories.IdentityRepository,
+	stateRepo repositories.FederationStateRepository,
+	userRepo repositories.UserRepository,
+	opts FederationOptions,
+	log *logrus.Logger,
+) FederationService {
+	s := &FederationServiceImpl{
+		providers:    make(map[string]identity.Provider, len(providers)),
+		identityRepo: identityRepo,
+		stateRepo:    stateRepo,
+		userRepo:     userRepo,
+		opts:         opts,
+		log:          log,
+	}
+	for _, provider := range providers {
+		s.providers[provider.Name()] = provider
+		s.providerNames = append(s.providerNames, provider.Name())
+	}
+	return s
+}
+
+func (s *FederationServiceImpl) Providers() []string {
+	return s.providerNames
+}
+
+func (s *FederationServiceImpl) BeginLogin(ctx context.Context, provider string) (*FederatedAuthorization, error) {
+	return s.begin(ctx, provider, uuid.Nil)
+}
+
+// CompleteLogin signs in the user the provider identity is linked to. An unknown identity
+// creates a new account, unless its email already belongs to an account: that one has to be
+// linked by its owner, otherwise whoever controls the provider account would take it over.
+func (s *FederationServiceImpl) CompleteLogin(ctx context.Context, req *models.FederatedCallbackRequest) (*FederatedLogin, error) {
+	provider, profile, err := s.callback(ctx, req, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &FederatedLogin{}
+	linked, err := s.identityRepo.GetIdentity(ctx, provider, profile.Subject)
+	switch {
+	case err == nil:
+		userDB, err := s.userRepo.GetUserByID(ctx, linked.UserID)
+		if err != nil {
+			// The account was deleted, its identity can not sign in anymore
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, apperrors.ErrInvalidCredentials
+			}
+			return nil, fmt.Errorf("service: failed to get user: %w", err)
+		}
+		res.User = toDomainUser(userDB)
+
+		if err := s.identityRepo.TouchIdentity(ctx, &db.TouchUserIdentityParams{ID: linked.ID, Email: profile.Email}); err != nil {
+			s.log.WithError(err).Warn("Failed to record identity login")
+		}
+	case errors.Is(err, apperrors.ErrNotFound):
+		res.User, err = s.createFederatedUser(ctx, provider, profile)
+		if err != nil {
+			return nil, err
+		}
+		res.Created = true
+	default:
+		return nil, fmt.Errorf("service: failed to get identity: %w", err)
+	}
+
+	if err := s.markEmailVerified(ctx, res.User, profile); err != nil {
+		return nil, err
+	}
+	if s.opts.EmailVerificationMode == EmailVerificationDeny && !res.User.EmailVerified() {
+		return nil, apperrors.ErrEmailNotVerified
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"user_id":  res.User.ID,
+		"provider": provider,
+		"created":  res.Created,
+	}).Info("Federated login")
+
+	return res, nil
+}
+
+func (s *FederationServiceImpl) BeginLink(ctx context.Context, userID uuid.UUID, provider string) (*FederatedAuthorization, error) {
+	if userID == uuid.Nil {
+		return nil, apperrors.ErrInvalidUserSession
+	}
+	return s.begin(ctx, provider, userID)
+}
+
+// CompleteLink links the provider identity to the signed in user. The state must have been
+// issued to the same user by BeginLink.
+func (s *FederationServiceImpl) CompleteLink(ctx context.Context, userID uuid.UUID, req *models.FederatedCallbackRequest) (*entities.UserIdentity, error) {
+	if userID == uuid.Nil {
+		return nil, apperrors.ErrInvalidUserSession
+	}
+
+	provider, profile, err := s.callback(ctx, req, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	linked, err := s.identityRepo.CreateIdentity(ctx, &db.CreateUserIdentityParams{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+	})
+	if err != nil {
+		if errors.Is(err, apperrors.ErrIdentityAlreadyLinked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("service: failed to link identity: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"user_id":  userID,
+		"provider": provider,
+	}).Info("Identity linked")
+
+	return toUserIdentity(linked), nil
+}
+
+func (s *FederationServiceImpl) ListIdentities(ctx context.Context, userID uuid.UUID) ([]entities.UserIdentity, error) {
+	rows, err := s.identityRepo.ListIdentities(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list identities: %w", err)
+	}
+
+	identities := make([]entities.UserIdentity, 0, len(rows))
+	for i := range rows {
+		identities = append(identities, *toUserIdentity(&rows[i]))
+	}
+	return identities, nil
+}
+
+// UnlinkIdentity removes a linked identity. Accounts created by a federated login have no
+// password, so their last identity can only go once a password has been set.
+func (s *FederationServiceImpl) UnlinkIdentity(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	userDB, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.ErrUserNotFound
+		}
+		return fmt.Errorf("service: failed to get user: %w", err)
+	}
+
+	if userDB.Password == "" {
+		count, err := s.identityRepo.CountIdentities(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("service: failed to unlink identity: %w", err)
+		}
+		if count <= 1 {
+			return apperrors.ErrLastSignInMethod
+		}
+	}
+
+	deleted, err := s.identityRepo.DeleteIdentity(ctx, &db.DeleteUserIdentityParams{ID: id, UserID: userID})
+	if err != nil {
+		return fmt.Errorf("service: failed to unlink identity: %w", err)
+	}
+	if !deleted {
+		return fmt.Errorf("%w: identity %s", apperrors.ErrNotFound, id)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"identity_id": id,
+	}).Info("Identity unlinked")
+
+	return nil
+}
+
+// begin stores a fresh state, nonce and PKCE verifier and returns the provider URL. userID is
+// uuid.Nil for logins.
+func (s *FederationServiceImpl) begin(ctx context.Context, name string, userID uuid.UUID) (*FederatedAuthorization, error) {
+	provider, ok := s.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", apperrors.ErrUnknownProvider, name)
+	}
+
+	state, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to start federated login: %w", err)
+	}
+	nonce, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to start federated login: %w", err)
+	}
+	verifier, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to start federated login: %w", err)
+	}
+
+	authURL, err := provider.AuthorizeURL(ctx, identity.AuthorizeOptions{
+		State:         state,
+		Nonce:         nonce,
+		CodeChallenge: identity.CodeChallenge(verifier),
+		RedirectURI:   s.opts.RedirectURL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to start federated login: %w", err)
+	}
+
+	err = s.stateRepo.SaveState(ctx, state, &models.FederationState{
+		Provider:     name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		UserID:       userID,
+	}, s.opts.StateTTL)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to start federated login: %w", err)
+	}
+
+	return &FederatedAuthorization{URL: authURL, State: state}, nil
+}
+
+// callback consumes the state and returns the verified profile from the provider.
+func (s *FederationServiceImpl) callback(ctx context.Context, req *models.FederatedCallbackRequest, userID uuid.UUID) (string, *identity.Profile, error) {
+	if req == nil || req.Code == "" || req.State == "" {
+		return "", nil, apperrors.ErrInvalidRequestPayload
+	}
+
+	state, err := s.stateRepo.TakeState(ctx, req.State)
+	if err != nil {
+		return "", nil, err
+	}
+	// A login state can not complete a link and the other way round
+	if state.UserID != userID {
+		return "", nil, apperrors.ErrInvalidLoginState
+	}
+	provider, ok := s.providers[state.Provider]
+	if !ok {
+		return "", nil, apperrors.ErrInvalidLoginState
+	}
+
+	tok, err := provider.Exchange(ctx, req.Code, identity.ExchangeOptions{
+		RedirectURI:  s.opts.RedirectURL,
+		CodeVerifier: state.CodeVerifier,
+		Nonce:        state.Nonce,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("service: failed to complete federated login: %w", err)
+	}
+
+	profile, err := provider.FetchProfile(ctx, tok)
+	if err != nil {
+		return "", nil, fmt.Errorf("service: failed to complete federated login: %w", err)
+	}
+
+	return state.Provider, profile, nil
+}
+
+// createFederatedUser creates the account for a first federated login, linked to the identity
+// in the same transaction. It has no password, the user can set one through the password reset flow.
+func (s *FederationServiceImpl) createFederatedUser(ctx context.Context, provider string, profile *identity.Profile) (*entities.User, error) {
+	if profile.Email == "" {
+		return nil, fmt.Errorf("%w: %s did not share an email address", apperrors.ErrIdentityProvider, provider)
+	}
+
+	if _, err := s.userRepo.GetUserByEmail(ctx, profile.Email); err == nil {
+		return nil, apperrors.ErrFederatedEmailTaken
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("service: failed to create user: %w", err)
+	}
+
+	baseUsername := federatedUsername(profile)
+	baseName := strings.TrimSpace(profile.Name)
+	if baseName == "" {
+		baseName = baseUsername
+	}
+
+	param := &db.CreateUserParams{
+		Name:     baseName,
+		Username: baseUsername,
+		Email:    profile.Email,
+		Role:     entities.DefaultRole,
+	}
+
+	identityParam := &db.CreateUserIdentityParams{
+		Provider: provider,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+	}
+
+	var userDB *db.User
+	for attempt := 0; ; attempt++ {
+		param.ID = uuid.New()
+
+		var err error
+		userDB, err = s.userRepo.CreateFederatedUser(ctx, param, identityParam)
+		if err == nil {
+			break
+		}
+
+		var conflictErr *apperrors.ConflictError
+		if !errors.As(err, &conflictErr) || attempt == federatedUsernameAttempts {
+			return nil, fmt.Errorf("service: failed to create user: %w", err)
+		}
+
+		suffix, err := helpers.GenerateSecureToken(3)
+		if err != nil {
+			return nil, fmt.Errorf("service: failed to create user: %w", err)
+		}
+		suffix = strings.ToLower(suffix)
+
+		switch conflictErr.Field {
+		case "email":
+			return nil, apperrors.ErrFederatedEmailTaken
+		case "username":
+			param.Username = truncate(baseUsername, maxFederatedUsernameLen-len(suffix)-1) + "_" + suffix
+		case "name":
+			param.Name = baseName + " " + suffix
+		default:
+			return nil, fmt.Errorf("service: failed to create user: %w", err)
+		}
+	}
+
+	return toDomainUser(userDB), nil
+}
+
+// markEmailVerified trusts the provider's verification when it reports the user's current address.
+func (s *FederationServiceImpl) markEmailVerified(ctx context.Context, user *entities.User, profile *identity.Profile) error {
+	if user.EmailVerified() || !profile.EmailVerified || !strings.EqualFold(user.Email, profile.Email) {
+		return nil
+	}
+
+	marked, err := s.userRepo.MarkEmailVerified(ctx, &db.MarkUserEmailVerifiedParams{ID: user.ID, Email: user.Email})
+	if err != nil {
+		return fmt.Errorf("service: failed to verify email: %w", err)
+	}
+	if marked {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	return nil
+}
+
+// federatedUsername derives a username from the provider profile, keeping only lowercase
+// letters, digits, dots, dashes and underscores.
+func federatedUsername(profile *identity.Profile) string {
+	candidate := profile.PreferredUsername
+	if candidate == "" {
+		candidate, _, _ = strings.Cut(profile.Email, "@")
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(candidate) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+		case r == '.' || r == '-' || r == '_':
+			b.WriteRune(r)
+		}
+	}
+
+	username := truncate(strings.Trim(b.String(), ".-_"), maxFederatedUsernameLen)
+	if len(username) < minFederatedUsernameLen {
+		username = "user"
+	}
+	return username
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func toUserIdentity(row *db.UserIdentity) *entities.UserIdentity {
+	res := &entities.UserIdentity{
+		ID:        row.ID,
+		UserID:    row.UserID,
+		Provider:  row.Provider,
+		Subject:   row.Subject,
+		Email:     row.Email,
+		CreatedAt: row.CreatedAt,
+	}
+	if row.LastLoginAt.Valid {
+		res.LastLoginAt = &row.LastLoginAt.Time
+	}
+	return res
+}
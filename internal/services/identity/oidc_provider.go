@@ -0,0 +1,412 @@
+package identity
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+const (
+	// maxResponseSize caps what we read from a provider endpoint.
+	maxResponseSize = 1 << 20
+	// jwksRefreshInterval limits refetching the JWKS when a token names an unknown kid.
+	jwksRefreshInterval = time.Minute
+	// clockSkew is tolerated on the exp, iat and nbf claims of ID tokens.
+	clockSkew = time.Minute
+)
+
+// DefaultScopes are requested when a provider is configured without scopes.
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+// idTokenMethods are the signature algorithms accepted on provider ID tokens. Symmetric
+// algorithms are not accepted, the client secret is not a verification key.
+var idTokenMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// OIDCProviderConfig configures a provider that implements OpenID Connect discovery.
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// HTTPClient defaults to a client with a 10 second timeout.
+	HTTPClient *http.Client
+}
+
+// IDTokenClaims are the ID token claims we rely on.
+type IDTokenClaims struct {
+	Nonce             string    `json:"nonce"`
+	AuthorizedParty   string    `json:"azp,omitempty"`
+	Email             string    `json:"email,omitempty"`
+	EmailVerified     claimBool `json:"email_verified,omitempty"`
+	Name              string    `json:"name,omitempty"`
+	PreferredUsername string    `json:"preferred_username,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// claimBool accepts booleans sent as JSON strings, which some providers do for email_verified.
+type claimBool bool
+
+func (b *claimBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	case "false", "null", "":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean claim %s", data)
+	}
+	return nil
+}
+
+type providerMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type userInfoResponse struct {
+	Subject           string    `json:"sub"`
+	Email             string    `json:"email"`
+	EmailVerified     claimBool `json:"email_verified"`
+	Name              string    `json:"name"`
+	PreferredUsername string    `json:"preferred_username"`
+}
+
+type oidcProvider struct {
+	cfg        OIDCProviderConfig
+	httpClient *http.Client
+
+	// Discovery and keys are loaded on first use, so a provider being down does not keep the
+	// service from starting.
+	mu            sync.Mutex
+	metadata      *providerMetadata
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+// NewOIDCProvider returns a Provider for any OpenID Connect compliant identity provider.
+func NewOIDCProvider(cfg OIDCProviderConfig) (Provider, error) {
+	if cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, fmt.Errorf("identity provider needs a name, issuer and client id")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = DefaultScopes
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &oidcProvider{cfg: cfg, httpClient: httpClient}, nil
+}
+
+func (p *oidcProvider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *oidcProvider) AuthorizeURL(ctx context.Context, opts AuthorizeOptions) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint of %s: %w", p.cfg.Name, err)
+	}
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.cfg.ClientID)
+	query.Set("redirect_uri", opts.RedirectURI)
+	query.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	query.Set("state", opts.State)
+	query.Set("nonce", opts.Nonce)
+	query.Set("code_challenge", opts.CodeChallenge)
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	return authURL.String(), nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code string, opts ExchangeOptions) (*Token, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {opts.RedirectURI},
+		"code_verifier": {opts.CodeVerifier},
+	}
+
+	useBasicAuth := p.cfg.ClientSecret != "" && !p.prefersClientSecretPost(metadata)
+	if !useBasicAuth {
+		form.Set("client_id", p.cfg.ClientID)
+		if p.cfg.ClientSecret != "" {
+			form.Set("client_secret", p.cfg.ClientSecret)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if useBasicAuth {
+		// RFC 6749 section 2.3.1: the credentials are form encoded before basic encoding
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call token endpoint of %s: %w", p.cfg.Name, err)
+	}
+	defer res.Body.Close()
+
+	var body tokenResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode token response of %s: %w", p.cfg.Name, err)
+	}
+	if res.StatusCode != http.StatusOK || body.Error != "" {
+		return nil, fmt.Errorf("%w: token endpoint of %s returned %d %s", apperrors.ErrIdentityProvider, p.cfg.Name, res.StatusCode, body.Error)
+	}
+	if body.IDToken == "" {
+		return nil, fmt.Errorf("%w: %s returned no id_token", apperrors.ErrIdentityProvider, p.cfg.Name)
+	}
+
+	claims, err := p.verifyIDToken(ctx, metadata, body.IDToken, opts.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Token{AccessToken: body.AccessToken, IDToken: body.IDToken, Claims: claims}, nil
+}
+
+func (p *oidcProvider) FetchProfile(ctx context.Context, tok *Token) (*Profile, error) {
+	if tok == nil || tok.Claims == nil {
+		return nil, fmt.Errorf("%w: token has not been verified", apperrors.ErrIdentityProvider)
+	}
+
+	profile := &Profile{
+		Subject:           tok.Claims.Subject,
+		Email:             tok.Claims.Email,
+		EmailVerified:     bool(tok.Claims.EmailVerified),
+		Name:              tok.Claims.Name,
+		PreferredUsername: tok.Claims.PreferredUsername,
+	}
+
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.UserinfoEndpoint == "" || tok.AccessToken == "" {
+		return profile, nil
+	}
+
+	var info userInfoResponse
+	header := http.Header{"Authorization": {"Bearer " + tok.AccessToken}}
+	if err := p.getJSON(ctx, metadata.UserinfoEndpoint, header, &info); err != nil {
+		return nil, err
+	}
+	// OIDC Core 5.3.2: the userinfo response must be about the user of the ID token
+	if info.Subject != profile.Subject {
+		return nil, fmt.Errorf("%w: userinfo subject of %s does not match the id token", apperrors.ErrIdentityProvider, p.cfg.Name)
+	}
+
+	if info.Email != "" {
+		profile.Email = info.Email
+		profile.EmailVerified = bool(info.EmailVerified)
+	}
+	if info.Name != "" {
+		profile.Name = info.Name
+	}
+	if info.PreferredUsername != "" {
+		profile.PreferredUsername = info.PreferredUsername
+	}
+
+	return profile, nil
+}
+
+func (p *oidcProvider) verifyIDToken(ctx context.Context, metadata *providerMetadata, idToken string, nonce string) (*IDTokenClaims, error) {
+	keyFunc := func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.verificationKey(ctx, metadata, kid)
+	}
+
+	parsed, err := jwt.ParseWithClaims(idToken, &IDTokenClaims{}, keyFunc,
+		jwt.WithValidMethods(idTokenMethods),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid id token from %s: %v", apperrors.ErrIdentityProvider, p.cfg.Name, err)
+	}
+
+	claims, ok := parsed.Claims.(*IDTokenClaims)
+	if !ok || !parsed.Valid || claims.Subject == "" {
+		return nil, fmt.Errorf("%w: invalid id token from %s", apperrors.ErrIdentityProvider, p.cfg.Name)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: id token nonce from %s does not match", apperrors.ErrIdentityProvider, p.cfg.Name)
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID {
+		return nil, fmt.Errorf("%w: id token from %s was issued to another party", apperrors.ErrIdentityProvider, p.cfg.Name)
+	}
+
+	return claims, nil
+}
+
+// verificationKey looks up a provider key by kid. An unknown kid refetches the JWKS, at most
+// once per jwksRefreshInterval, to pick up key rotations.
+func (p *oidcProvider) verificationKey(ctx context.Context, metadata *providerMetadata, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if p.keys != nil && time.Since(p.keysFetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	data, err := p.get(ctx, metadata.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey must be called with p.mu held. Tokens without a kid are accepted only while the
+// provider publishes a single key.
+func (p *oidcProvider) lookupKey(kid string) (interface{}, bool) {
+	if key, ok := p.keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (*providerMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var metadata providerMetadata
+	discoveryURL := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, discoveryURL, nil, &metadata); err != nil {
+		return nil, err
+	}
+	// OIDC Discovery 4.3: the issuer in the document must be the one we asked
+	if metadata.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovery document of %s is for issuer %q", p.cfg.Name, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document of %s is missing endpoints", p.cfg.Name)
+	}
+
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// prefersClientSecretPost reports whether the provider only supports sending the client
+// credentials in the form body. client_secret_basic is the default of the spec.
+func (p *oidcProvider) prefersClientSecretPost(metadata *providerMetadata) bool {
+	basic, post := false, false
+	for _, method := range metadata.TokenEndpointAuthMethodsSupported {
+		switch method {
+		case "client_secret_basic":
+			basic = true
+		case "client_secret_post":
+			post = true
+		}
+	}
+	return post && !basic
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, endpoint string, header http.Header, out interface{}) error {
+	data, err := p.get(ctx, endpoint, header)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response of %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+func (p *oidcProvider) get(ctx context.Context, endpoint string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", endpoint, err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response of %s: %w", endpoint, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		if res.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("%w: %s rejected the access token", apperrors.ErrIdentityProvider, endpoint)
+		}
+		return nil, fmt.Errorf("%s returned status %d", endpoint, res.StatusCode)
+	}
+	return data, nil
+}
@@ -0,0 +1,58 @@
+package identity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// Provider is an external identity provider users can sign in with.
+type Provider interface {
+	// Name is the stable key of the provider, stored with every identity linked through it.
+	Name() string
+	// AuthorizeURL returns the provider page the user is sent to.
+	AuthorizeURL(ctx context.Context, opts AuthorizeOptions) (string, error)
+	// Exchange redeems the authorization code returned to the redirect URI. Implementations
+	// verify the ID token, including the nonce, before returning.
+	Exchange(ctx context.Context, code string, opts ExchangeOptions) (*Token, error)
+	// FetchProfile returns the verified profile of the user the token was issued for.
+	FetchProfile(ctx context.Context, tok *Token) (*Profile, error)
+}
+
+// AuthorizeOptions are the per-login values of an authorization request. CodeChallenge is the
+// S256 PKCE challenge of the verifier later passed to Exchange.
+type AuthorizeOptions struct {
+	State         string
+	Nonce         string
+	CodeChallenge string
+	RedirectURI   string
+}
+
+// ExchangeOptions must repeat the values of the authorization request the code belongs to.
+type ExchangeOptions struct {
+	RedirectURI  string
+	CodeVerifier string
+	Nonce        string
+}
+
+// Token is the result of a code exchange. Claims are the verified ID token claims.
+type Token struct {
+	AccessToken string
+	IDToken     string
+	Claims      *IDTokenClaims
+}
+
+// Profile is what we learn about the user from the provider. Subject is unique per provider.
+type Profile struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// CodeChallenge derives the S256 PKCE challenge of a code verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+)
+
+// lockoutHistoryWindow is how long previous lockouts count towards the exponential backoff.
+const lockoutHistoryWindow = 24 * time.Hour
+
+type LoginThrottleOptions struct {
+	MaxAttempts        int64
+	IPMaxAttempts      int64
+	AttemptWindow      time.Duration
+	LockoutDuration    time.Duration
+	MaxLockoutDuration time.Duration
+}
+
+// LockStatus describes the brute-force protection state of an account.
+type LockStatus struct {
+	Locked         bool
+	LockedUntil    time.Time
+	FailedAttempts int64
+}
+
+type LoginThrottleService interface {
+	// Check returns a *RetryAfterError when the username or IP may not attempt a login right now.
+	Check(ctx context.Context, username string, ipAddress string) error
+	// RecordFailure counts a failed login and locks the account once the limit is reached.
+	RecordFailure(ctx context.Context, username string, ipAddress string) error
+	RecordSuccess(ctx context.Context, username string)
+	GetLockStatus(ctx context.Context, username string) (*LockStatus, error)
+	Unlock(ctx context.Context, userID uuid.UUID) error
+}
+
+type LoginThrottleServiceImpl struct {
+	userRepo    repositories.UserRepository
+	attemptRepo repositories.AttemptRepository
+	lockRepo    repositories.LoginLockRepository
+	opts        LoginThrottleOptions
+	log         *logrus.Logger
+}
+
+func NewLoginThrottleService(
+	userRepo repositories.UserRepository,
+	attemptRepo repositories.AttemptRepository,
+	lockRepo repositories.LoginLockRepository,
+	opts LoginThrottleOptions,
+	log *logrus.Logger,
+) LoginThrottleService {
+	return &LoginThrottleServiceImpl{
+		userRepo:    userRepo,
+		attemptRepo: attemptRepo,
+		lockRepo:    lockRepo,
+		opts:        opts,
+		log:         log,
+	}
+}
+
+func usernameAttemptKey(username string) string {
+	return "login:user:" + strings.ToLower(username)
+}
+
+func ipAttemptKey(ipAddress string) string {
+	return "login:ip:" + ipAddress
+}
+
+func lockoutCountKey(username string) string {
+	return "login:lockouts:" + strings.ToLower(username)
+}
+
+func (s *LoginThrottleServiceImpl) Check(ctx context.Context, username string, ipAddress string) error {
+	lockedFor, err := s.lockRepo.LockedFor(ctx, strings.ToLower(username))
+	if err != nil {
+		return fmt.Errorf("service: failed to check login lock: %w", err)
+	}
+	if lockedFor > 0 {
+		return &apperrors.RetryAfterError{Err: apperrors.ErrAccountLocked, RetryAfter: lockedFor}
+	}
+
+	if ipAddress == "" {
+		return nil
+	}
+
+	attempts, err := s.attemptRepo.Count(ctx, ipAttemptKey(ipAddress))
+	if err != nil {
+		return fmt.Errorf("service: failed to check login attempts: %w", err)
+	}
+	if attempts >= s.opts.IPMaxAttempts {
+		retryAfter, err := s.attemptRepo.TTL(ctx, ipAttemptKey(ipAddress))
+		if err != nil {
+			return fmt.Errorf("service: failed to check login attempts: %w", err)
+		}
+		return &apperrors.RetryAfterError{Err: apperrors.ErrTooManyAttempts, RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+func (s *LoginThrottleServiceImpl) RecordFailure(ctx context.Context, username string, ipAddress string) error {
+	if ipAddress != "" {
+		if _, err := s.attemptRepo.Increment(ctx, ipAttemptKey(ipAddress), s.opts.AttemptWindow); err != nil {
+			return fmt.Errorf("service: failed to record login failure: %w", err)
+		}
+	}
+
+	attempts, err := s.attemptRepo.Increment(ctx, usernameAttemptKey(username), s.opts.AttemptWindow)
+	if err != nil {
+		return fmt.Errorf("service: failed to record login failure: %w", err)
+	}
+	if attempts < s.opts.MaxAttempts {
+		return nil
+	}
+
+	lockouts, err := s.attemptRepo.Increment(ctx, lockoutCountKey(username), lockoutHistoryWindow)
+	if err != nil {
+		return fmt.Errorf("service: failed to record lockout: %w", err)
+	}
+
+	duration := s.lockoutDuration(lockouts)
+	if err := s.lockRepo.Lock(ctx, strings.ToLower(username), duration); err != nil {
+		return fmt.Errorf("service: failed to lock account: %w", err)
+	}
+	if err := s.attemptRepo.Reset(ctx, usernameAttemptKey(username)); err != nil {
+		return fmt.Errorf("service: failed to reset login attempts: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"username": username,
+		"duration": duration.String(),
+		"lockouts": lockouts,
+	}).Warn("Account locked after repeated failed logins")
+
+	return &apperrors.RetryAfterError{Err: apperrors.ErrAccountLocked, RetryAfter: duration}
+}
+
+// RecordSuccess clears the failure counter of the username. The lockout history is kept so an
+// attacker that occasionally guesses right does not reset the backoff.
+func (s *LoginThrottleServiceImpl) RecordSuccess(ctx context.Context, username string) {
+	if err := s.attemptRepo.Reset(ctx, usernameAttemptKey(username)); err != nil {
+		s.log.WithError(err).Warn("Failed to reset login attempts")
+	}
+}
+
+func (s *LoginThrottleServiceImpl) GetLockStatus(ctx context.Context, username string) (*LockStatus, error) {
+	lockedFor, err := s.lockRepo.LockedFor(ctx, strings.ToLower(username))
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to read login lock: %w", err)
+	}
+
+	attempts, err := s.attemptRepo.Count(ctx, usernameAttemptKey(username))
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to read login attempts: %w", err)
+	}
+
+	status := &LockStatus{FailedAttempts: attempts}
+	if lockedFor > 0 {
+		status.Locked = true
+		status.LockedUntil = time.Now().Add(lockedFor)
+	}
+	return status, nil
+}
+
+func (s *LoginThrottleServiceImpl) Unlock(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", apperrors.ErrNotFound, err)
+		}
+		return fmt.Errorf("service: failed to get user: %w", err)
+	}
+
+	if err := s.lockRepo.Unlock(ctx, strings.ToLower(user.Username)); err != nil {
+		return fmt.Errorf("service: failed to unlock account: %w", err)
+	}
+	if err := s.attemptRepo.Reset(ctx, usernameAttemptKey(user.Username)); err != nil {
+		return fmt.Errorf("service: failed to reset login attempts: %w", err)
+	}
+	if err := s.attemptRepo.Reset(ctx, lockoutCountKey(user.Username)); err != nil {
+		return fmt.Errorf("service: failed to reset lockout history: %w", err)
+	}
+
+	s.log.WithField("user_id", userID).Info("Account unlocked by administrator")
+	return nil
+}
+
+// lockoutDuration doubles the base duration for every lockout within the history window.
+func (s *LoginThrottleServiceImpl) lockoutDuration(lockouts int64) time.Duration {
+	duration := s.opts.LockoutDuration
+	for i := int64(1); i < lockouts && duration < s.opts.MaxLockoutDuration; i++ {
+		duration *= 2
+	}
+	if duration > s.opts.MaxLockoutDuration {
+		duration = s.opts.MaxLockoutDuration
+	}
+	return duration
+}
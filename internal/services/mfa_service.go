@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+const (
+	totpPeriod        = 30
+	totpSkew          = 1
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 8
+)
+
+type MFAEnrollment struct {
+	Secret     string
+	OTPAuthURI string
+}
+
+type MFAService interface {
+	BeginEnrollment(ctx context.Context, userID uuid.UUID) (*MFAEnrollment, error)
+	ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	IsEnabled(ctx context.Context, userID uuid.UUID) (bool, error)
+	IssueChallenge(ctx context.Context, userID uuid.UUID, amr []string) (string, time.Time, error)
+	BeginPasskeyChallenge(ctx context.Context, mfaToken string) (*WebAuthnCeremony, error)
+	VerifyLogin(ctx context.Context, req *models.MFAVerifyRequest) (*entities.User, []string, error)
+	ResetMFA(ctx context.Context, userID uuid.UUID) error
+}
+
+type MFAServiceImpl struct {
+	mfaRepo         repositories.MFARepository
+	userRepo        repositories.UserRepository
+	attemptRepo     repositories.AttemptRepository
+	tokenService    token.TokenService
+	webAuthnService WebAuthnService
+	issuer          string
+	tokenTTL        time.Duration
+	maxAttempts     int64
+	log             *logrus.Logger
+}
+
+func NewMFAService(
+	mfaRepo repositories.MFARepository,
+	userRepo repositories.UserRepository,
+	attemptRepo repositories.AttemptRepository,
+	tokenService token.TokenService,
+	webAuthnService WebAuthnService,
+	issuer string,
+	tokenTTL time.Duration,
+	maxAttempts int64,
+	log *logrus.Logger,
+) MFAService {
+	return &MFAServiceImpl{
+		mfaRepo:         mfaRepo,
+		userRepo:        userRepo,
+		attemptRepo:     attemptRepo,
+		tokenService:    tokenService,
+		webAuthnService: webAuthnService,
+		issuer:          issuer,
+		tokenTTL:        tokenTTL,
+		maxAttempts:     maxAttempts,
+		log:             log,
+	}
+}
+
+// BeginEnrollment generates a new TOTP secret. It only becomes active after ConfirmEnrollment.
+func (s *MFAServiceImpl) BeginEnrollment(ctx context.Context, userID uuid.UUID) (*MFAEnrollment, error) {
+	enabled, err := s.IsEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, apperrors.ErrMFAAlreadyEnabled
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to get user: %w", err)
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      s.issuer,
+		AccountName: user.Username,
+		Period:      totpPeriod,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to generate totp secret: %w", err)
+	}
+
+	_, err = s.mfaRepo.UpsertUserMFA(ctx, &db.UpsertUserMFAParams{
+		UserID:     userID,
+		TotpSecret: key.Secret(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to begin mfa enrollment: %w", err)
+	}
+
+	return &MFAEnrollment{
+		Secret:     key.Secret(),
+		OTPAuthURI: key.URL(),
+	}, nil
+}
+
+// ConfirmEnrollment activates MFA with the first code from the authenticator app and returns
+// the recovery codes. They are only stored hashed and can not be shown again.
+func (s *MFAServiceImpl) ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	settings, err := s.mfaRepo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if settings.EnabledAt.Valid {
+		return nil, apperrors.ErrMFAAlreadyEnabled
+	}
+
+	step, ok := matchTOTP(settings.TotpSecret, code, time.Now())
+	if !ok {
+		return nil, apperrors.ErrInvalidMFACode
+	}
+
+	if _, err := s.mfaRepo.EnableUserMFA(ctx, &db.EnableUserMFAParams{UserID: userID, LastUsedStep: step}); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.mfaRepo.ReplaceRecoveryCodes(ctx, &db.ReplaceRecoveryCodesParams{UserID: userID, CodeHashes: hashes})
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to store recovery codes: %w", err)
+	}
+
+	s.log.WithField("user_id", userID).Info("MFA enabled")
+	return codes, nil
+}
+
+func (s *MFAServiceImpl) IsEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	settings, err := s.mfaRepo.GetUserMFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrMFANotEnrolled) {
+			return false, nil
+		}
+		return false, fmt.Errorf("service: failed to check mfa: %w", err)
+	}
+
+	return settings.EnabledAt.Valid, nil
+}
+
+// IssueChallenge returns the short-lived "mfa_pending" token handed out after the first login
+// step. amr names the methods of that step and is carried over to the final token pair.
+func (s *MFAServiceImpl) IssueChallenge(ctx context.Context, userID uuid.UUID, amr []string) (string, time.Time, error) {
+	subject := token.PurposeSubject{UserID: userID, AMR: amr}
+	mfaToken, expiresAt, err := s.tokenService.GeneratePurposeToken(ctx, subject, token.PurposeMFA, s.tokenTTL)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("service: failed to issue mfa challenge: %w", err)
+	}
+	return mfaToken, expiresAt, nil
+}
+
+// BeginPasskeyChallenge returns the passkey request options for answering an MFA challenge.
+func (s *MFAServiceImpl) BeginPasskeyChallenge(ctx context.Context, mfaToken string) (*WebAuthnCeremony, error) {
+	claims, err := s.tokenService.ValidatePurposeToken(ctx, mfaToken, token.PurposeMFA)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.webAuthnService.BeginSecondFactor(ctx, claims.UserID)
+}
+
+// VerifyLogin completes the second login step. A TOTP code, a recovery code or a passkey
+// assertion is accepted. It returns the user and the amr of the whole login.
+func (s *MFAServiceImpl) VerifyLogin(ctx context.Context, req *models.MFAVerifyRequest) (*entities.User, []string, error) {
+	claims, err := s.tokenService.ValidatePurposeToken(ctx, req.MFAToken, token.PurposeMFA)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Guesses are counted per user, not per challenge: a new challenge only takes the password,
+	// and must not bring a fresh set of guesses at the code.
+	attemptKey := "mfa:" + claims.UserID.String()
+	attempts, err := s.attemptRepo.Increment(ctx, attemptKey, s.tokenTTL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("service: failed to count mfa attempt: %w", err)
+	}
+	if attempts > s.maxAttempts {
+		return nil, nil, apperrors.ErrTooManyAttempts
+	}
+
+	settings, err := s.mfaRepo.GetUserMFA(ctx, claims.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !settings.EnabledAt.Valid {
+		return nil, nil, apperrors.ErrMFANotEnrolled
+	}
+
+	// The challenge is single use. It is consumed before the factor is checked, so concurrent
+	// requests with the same challenge can not both pass, and given back when the check fails
+	// so a mistyped code can be corrected within the attempt limit.
+	if err := s.tokenService.ConsumePurposeToken(ctx, claims); err != nil {
+		return nil, nil, err
+	}
+
+	amr, err := s.verifySecondFactor(ctx, claims, settings, req)
+	if err != nil {
+		if releaseErr := s.tokenService.ReleasePurposeToken(ctx, claims); releaseErr != nil {
+			s.log.WithError(releaseErr).WithField("user_id", claims.UserID).Warn("Failed to release mfa challenge")
+		}
+		return nil, nil, err
+	}
+	_ = s.attemptRepo.Reset(ctx, attemptKey)
+
+	user, err := s.userRepo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("service: failed to get user: %w", err)
+	}
+
+	return toDomainUser(user), append(amr, entities.AMRMFA), nil
+}
+
+// verifySecondFactor checks the TOTP code, recovery code or passkey assertion of the request
+// and returns the amr of the whole login.
+func (s *MFAServiceImpl) verifySecondFactor(ctx context.Context, claims *token.PurposeClaims, settings *db.UserMfa, req *models.MFAVerifyRequest) ([]string, error) {
+	// Challenges issued before the amr was recorded always followed a password
+	amr := claims.AMR
+	if len(amr) == 0 {
+		amr = []string{entities.AMRPassword}
+	}
+
+	switch {
+	case req.Code != "":
+		step, ok := matchTOTP(settings.TotpSecret, req.Code, time.Now())
+		if !ok {
+			return nil, apperrors.ErrInvalidMFACode
+		}
+		fresh, err := s.mfaRepo.UpdateLastUsedStep(ctx, &db.UpdateMFALastUsedStepParams{UserID: claims.UserID, LastUsedStep: step})
+		if err != nil {
+			return nil, fmt.Errorf("service: failed to verify mfa code: %w", err)
+		}
+		if !fresh {
+			// The code was already used, a captured code must not work twice
+			return nil, apperrors.ErrInvalidMFACode
+		}
+	case req.RecoveryCode != "":
+		used, err := s.mfaRepo.UseRecoveryCode(ctx, &db.UseRecoveryCodeParams{
+			UserID:   claims.UserID,
+			CodeHash: hashRecoveryCode(req.RecoveryCode),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("service: failed to verify recovery code: %w", err)
+		}
+		if !used {
+			return nil, apperrors.ErrInvalidMFACode
+		}
+		s.log.WithField("user_id", claims.UserID).Warn("Recovery code used to sign in")
+	case len(req.Passkey) > 0:
+		if err := s.webAuthnService.VerifySecondFactor(ctx, claims.UserID, req.PasskeySessionID, req.Passkey); err != nil {
+			return nil, err
+		}
+		amr = append(amr, entities.AMRPasskey)
+	default:
+		return nil, apperrors.ErrInvalidRequestPayload
+	}
+
+	return amr, nil
+}
+
+// ResetMFA removes the second factor of a user, e.g. after losing the authenticator and codes.
+func (s *MFAServiceImpl) ResetMFA(ctx context.Context, userID uuid.UUID) error {
+	if _, err := s.userRepo.GetUserByID(ctx, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", apperrors.ErrNotFound, err)
+		}
+		return fmt.Errorf("service: failed to get user: %w", err)
+	}
+
+	if err := s.mfaRepo.DeleteUserMFA(ctx, userID); err != nil {
+		return fmt.Errorf("service: failed to reset mfa: %w", err)
+	}
+
+	s.log.WithField("user_id", userID).Warn("MFA reset by administrator")
+	return nil
+}
+
+// matchTOTP checks the code against the current time step and its neighbours and returns the
+// matching step, so callers can reject replays of the same code.
+func matchTOTP(secret string, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	opts := totp.ValidateOpts{
+		Period:    totpPeriod,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	}
+
+	current := now.Unix() / totpPeriod
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		step := current + offset
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), opts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("service: failed to generate recovery code: %w", err)
+		}
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+		code := raw[:5] + "-" + raw[5:10]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode normalises user input (case, dashes, spaces) before hashing.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return helpers.HashToken(normalized)
+}
@@ -0,0 +1,442 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+const (
+	oauthClientIDPrefix      = "svc-"
+	oauthClientSecretBytes   = 32
+	maxOAuthClientNameLength = 100
+)
+
+type OAuthService interface {
+	// RegisterClient returns the new client together with its secret, which is not stored.
+	RegisterClient(ctx context.Context, createdBy uuid.UUID, req *models.CreateOAuthClientRequest) (*entities.OAuthClient, string, error)
+	ListClients(ctx context.Context) ([]entities.OAuthClient, error)
+	GetClient(ctx context.Context, id uuid.UUID) (*entities.OAuthClient, error)
+	UpdateClient(ctx context.Context, id uuid.UUID, req *models.UpdateOAuthClientRequest) (*entities.OAuthClient, error)
+	RevokeClient(ctx context.Context, id uuid.UUID) error
+	// IssueClientCredentialsToken implements the client credentials grant. An empty scope grants
+	// every scope the client is registered for.
+	IssueClientCredentialsToken(ctx context.Context, clientID string, clientSecret string, scope string) (string, []string, time.Time, error)
+	// ValidateServiceToken verifies a service token and that its client was not revoked since.
+	ValidateServiceToken(ctx context.Context, tokenString string) (*token.ServiceClaims, error)
+}
+
+type OAuthServiceImpl struct {
+	oauthClientRepo repositories.OAuthClientRepository
+	tokenService    token.TokenService
+	tokenTTL        time.Duration
+	log             *logrus.Logger
+}
+
+func NewOAuthService(
+	oauthClientRepo repositories.OAuthClientRepository,
+	tokenService token.TokenService,
+	tokenTTL time.Duration,
+	log *logrus.Logger,
+) OAuthService {
+	return &OAuthServiceImpl{
+		oauthClientRepo: oauthClientRepo,
+		tokenService:    tokenService,
+		tokenTTL:        tokenTTL,
+		log:             log,
+	}
+}
+
+func (s *OAuthServiceImpl) RegisterClient(ctx context.Context, createdBy uuid.UUID, req *models.CreateOAuthClientRequest) (*entities.OAuthClient, string, error) {
+	name, err := validateOAuthClientName(req.Name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	grantTypes, err := validateGrantTypes(req.GrantTypes, req.Public, req.FirstParty)
+	if err != nil {
+		return nil, "", err
+	}
+
+	scopes, err := validateClientScopes(req.Scopes, grantTypes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	redirectURIs, err := validateRedirectURIs(req.RedirectURIs, grantTypes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, "", fmt.Errorf("service: failed to generate client id: %w", err)
+	}
+	clientID := oauthClientIDPrefix + hex.EncodeToString(idBytes)
+
+	// Public clients can not keep a secret, they prove themselves with PKCE instead
+	var secret, secretHash string
+	if !req.Public {
+		secret, err = helpers.GenerateSecureToken(oauthClientSecretBytes)
+		if err != nil {
+			return nil, "", fmt.Errorf("service: failed to generate client secret: %w", err)
+		}
+		secretHash = helpers.HashToken(secret)
+	}
+
+	stored, err := s.oauthClientRepo.CreateClient(ctx, &db.CreateOAuthClientParams{
+		ClientID:     clientID,
+		Name:         name,
+		SecretHash:   secretHash,
+		Scopes:       scopes,
+		CreatedBy:    uuid.NullUUID{UUID: createdBy, Valid: createdBy != uuid.Nil},
+		RedirectUris: redirectURIs,
+		GrantTypes:   grantTypes,
+		IsPublic:     req.Public,
+		FirstParty:   req.FirstParty,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("service: failed to register oauth client: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"client_id":   clientID,
+		"created_by":  createdBy,
+		"scopes":      scopes,
+		"grant_types": grantTypes,
+	}).Info("OAuth client registered")
+	return toOAuthClient(stored), secret, nil
+}
+
+func (s *OAuthServiceImpl) ListClients(ctx context.Context) ([]entities.OAuthClient, error) {
+	rows, err := s.oauthClientRepo.ListClients(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list oauth clients: %w", err)
+	}
+
+	clients := make([]entities.OAuthClient, 0, len(rows))
+	for i := range rows {
+		clients = append(clients, *toOAuthClient(&rows[i]))
+	}
+	return clients, nil
+}
+
+func (s *OAuthServiceImpl) GetClient(ctx context.Context, id uuid.UUID) (*entities.OAuthClient, error) {
+	stored, err := s.oauthClientRepo.GetClient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toOAuthClient(stored), nil
+}
+
+// UpdateClient changes the name, scopes and redirect URIs. Grant types and the client type are
+// fixed, register a new client to change them.
+func (s *OAuthServiceImpl) UpdateClient(ctx context.Context, id uuid.UUID, req *models.UpdateOAuthClientRequest) (*entities.OAuthClient, error) {
+	current, err := s.oauthClientRepo.GetClient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := validateOAuthClientName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, err := validateClientScopes(req.Scopes, current.GrantTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	redirectURIs, err := validateRedirectURIs(req.RedirectURIs, current.GrantTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.oauthClientRepo.UpdateClient(ctx, &db.UpdateOAuthClientParams{
+		ID:           id,
+		Name:         name,
+		Scopes:       scopes,
+		RedirectUris: redirectURIs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.WithFields(logrus.Fields{"client_id": stored.ClientID, "scopes": scopes}).Info("OAuth client updated")
+	return toOAuthClient(stored), nil
+}
+
+// RevokeClient takes effect immediately, tokens already issued to the client are rejected too.
+func (s *OAuthServiceImpl) RevokeClient(ctx context.Context, id uuid.UUID) error {
+	revoked, err := s.oauthClientRepo.RevokeClient(ctx, id)
+	if err != nil {
+		return fmt.Errorf("service: failed to revoke oauth client: %w", err)
+	}
+	if !revoked {
+		return fmt.Errorf("%w: oauth client %s", apperrors.ErrNotFound, id)
+	}
+
+	s.log.WithField("oauth_client_id", id).Info("OAuth client revoked")
+	return nil
+}
+
+func (s *OAuthServiceImpl) IssueClientCredentialsToken(ctx context.Context, clientID string, clientSecret string, scope string) (string, []string, time.Time, error) {
+	client, err := authenticateClient(ctx, s.oauthClientRepo, clientID, clientSecret)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrInvalidClient) {
+			s.log.WithField("client_id", clientID).Warn("Rejected client credentials")
+		}
+		return "", nil, time.Time{}, err
+	}
+	if !toOAuthClient(client).AllowsGrant(entities.GrantClientCredentials) {
+		return "", nil, time.Time{}, apperrors.ErrUnauthorizedClient
+	}
+
+	// Scopes of other grants the client is registered for never end up in service tokens
+	scopes, _ := intersectScopes(client.Scopes, entities.ServiceScopes)
+	if requested := strings.Fields(scope); len(requested) > 0 {
+		scopes, err = normalizeScopes(requested, scopes)
+		if err != nil {
+			return "", nil, time.Time{}, fmt.Errorf("%w: %v", apperrors.ErrInvalidScope, err)
+		}
+	}
+
+	accessToken, expiresAt, err := s.tokenService.GenerateServiceToken(ctx, client.ClientID, scopes, s.tokenTTL)
+	if err != nil {
+		return "", nil, time.Time{}, fmt.Errorf("service: failed to issue service token: %w", err)
+	}
+
+	if err := s.oauthClientRepo.TouchClient(ctx, client.ID); err != nil {
+		s.log.WithError(err).WithField("client_id", clientID).Warn("Failed to update oauth client last use")
+	}
+
+	s.log.WithFields(logrus.Fields{"client_id": clientID, "scopes": scopes}).Info("Service token issued")
+	return accessToken, scopes, expiresAt, nil
+}
+
+func (s *OAuthServiceImpl) ValidateServiceToken(ctx context.Context, tokenString string) (*token.ServiceClaims, error) {
+	claims, err := s.tokenService.ValidateServiceToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.oauthClientRepo.GetClientByClientID(ctx, claims.ClientID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrInvalidClient) {
+			return nil, apperrors.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("service: failed to get oauth client: %w", err)
+	}
+	if client.RevokedAt.Valid {
+		return nil, apperrors.ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// authenticateClient checks the credentials a client presents at the token endpoint. Public
+// clients only send their client_id, confidential clients must send the matching secret.
+func authenticateClient(ctx context.Context, repo repositories.OAuthClientRepository, clientID string, clientSecret string) (*db.OauthClient, error) {
+	if clientID == "" {
+		return nil, apperrors.ErrInvalidClient
+	}
+
+	client, err := repo.GetClientByClientID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client.RevokedAt.Valid {
+		return nil, apperrors.ErrInvalidClient
+	}
+
+	if client.IsPublic {
+		if clientSecret != "" {
+			return nil, apperrors.ErrInvalidClient
+		}
+		return client, nil
+	}
+
+	hash := helpers.HashToken(clientSecret)
+	if clientSecret == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash)) != 1 {
+		return nil, apperrors.ErrInvalidClient
+	}
+	return client, nil
+}
+
+func validateOAuthClientName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxOAuthClientNameLength {
+		return "", fmt.Errorf("%w: name is required and at most %d characters", apperrors.ErrInvalidRequestPayload, maxOAuthClientNameLength)
+	}
+	return name, nil
+}
+
+// validateGrantTypes defaults to client_credentials. Public clients can not authenticate on
+// their own and refresh tokens are only handed to first-party apps.
+func validateGrantTypes(requested []string, public bool, firstParty bool) ([]string, error) {
+	if len(requested) == 0 {
+		requested = []string{entities.GrantClientCredentials}
+	}
+
+	grantTypes, err := normalizeScopes(requested, []string{
+		entities.GrantClientCredentials,
+		entities.GrantAuthorizationCode,
+		entities.GrantRefreshToken,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", apperrors.ErrInvalidRequestPayload, err)
+	}
+
+	client := entities.OAuthClient{GrantTypes: grantTypes}
+	switch {
+	case public && client.AllowsGrant(entities.GrantClientCredentials):
+		return nil, fmt.Errorf("%w: public clients can not use client_credentials", apperrors.ErrInvalidRequestPayload)
+	case public && !client.AllowsGrant(entities.GrantAuthorizationCode):
+		return nil, fmt.Errorf("%w: public clients must use authorization_code", apperrors.ErrInvalidRequestPayload)
+	case client.AllowsGrant(entities.GrantRefreshToken) && (!firstParty || !client.AllowsGrant(entities.GrantAuthorizationCode)):
+		return nil, fmt.Errorf("%w: refresh_token is only available to first-party clients using authorization_code", apperrors.ErrInvalidRequestPayload)
+	}
+	return grantTypes, nil
+}
+
+// validateClientScopes allows service scopes for client_credentials and OpenID Connect scopes
+// for authorization_code. Applications get every OpenID Connect scope when none are given.
+func validateClientScopes(requested []string, grantTypes []string) ([]string, error) {
+	client := entities.OAuthClient{GrantTypes: grantTypes}
+
+	allowed := make([]string, 0, len(entities.ServiceScopes)+len(entities.OIDCScopes))
+	if client.AllowsGrant(entities.GrantClientCredentials) {
+		allowed = append(allowed, entities.ServiceScopes...)
+	}
+	if client.AllowsGrant(entities.GrantAuthorizationCode) {
+		allowed = append(allowed, entities.OIDCScopes...)
+		if len(requested) == 0 {
+			requested = entities.OIDCScopes
+		}
+	}
+
+	scopes, err := normalizeScopes(requested, allowed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", apperrors.ErrInvalidRequestPayload, err)
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", apperrors.ErrInvalidRequestPayload)
+	}
+	if client.AllowsGrant(entities.GrantAuthorizationCode) {
+		if _, hasOpenID := intersectScopes(scopes, []string{entities.ScopeOpenID}); !hasOpenID {
+			return nil, fmt.Errorf("%w: the openid scope is required for authorization_code", apperrors.ErrInvalidRequestPayload)
+		}
+	}
+	return scopes, nil
+}
+
+// validateRedirectURIs requires absolute URIs without fragment. Plain http is only accepted for
+// loopback addresses during development.
+func validateRedirectURIs(requested []string, grantTypes []string) ([]string, error) {
+	client := entities.OAuthClient{GrantTypes: grantTypes}
+	if !client.AllowsGrant(entities.GrantAuthorizationCode) {
+		if len(requested) > 0 {
+			return nil, fmt.Errorf("%w: redirect_uris are only used with authorization_code", apperrors.ErrInvalidRequestPayload)
+		}
+		return []string{}, nil
+	}
+	if len(requested) == 0 {
+		return nil, fmt.Errorf("%w: at least one redirect_uri is required", apperrors.ErrInvalidRequestPayload)
+	}
+
+	redirectURIs := make([]string, 0, len(requested))
+	for _, raw := range requested {
+		u, err := url.Parse(raw)
+		if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+			return nil, fmt.Errorf("%w: redirect_uri %q must be an absolute URI without fragment", apperrors.ErrInvalidRequestPayload, raw)
+		}
+		if u.Scheme != "https" && !(u.Scheme == "http" && isLoopbackHost(u.Hostname())) {
+			return nil, fmt.Errorf("%w: redirect_uri %q must use https", apperrors.ErrInvalidRequestPayload, raw)
+		}
+		redirectURIs = append(redirectURIs, raw)
+	}
+	return redirectURIs, nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// intersectScopes returns the scopes present in both lists and whether there are any.
+func intersectScopes(scopes []string, allowed []string) ([]string, bool) {
+	res := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		for _, a := range allowed {
+			if scope == a {
+				res = append(res, scope)
+				break
+			}
+		}
+	}
+	return res, len(res) > 0
+}
+
+// normalizeScopes trims and de-duplicates the requested scopes and rejects any that are not allowed.
+func normalizeScopes(requested []string, allowed []string) ([]string, error) {
+	allowedSet := make(map[string]struct{}, len(allowed))
+	for _, scope := range allowed {
+		allowedSet[scope] = struct{}{}
+	}
+
+	seen := make(map[string]struct{}, len(requested))
+	scopes := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		scope = strings.TrimSpace(scope)
+		if _, dup := seen[scope]; dup {
+			continue
+		}
+		if _, ok := allowedSet[scope]; !ok {
+			return nil, fmt.Errorf("scope %q is not allowed", scope)
+		}
+		seen[scope] = struct{}{}
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes, nil
+}
+
+func toOAuthClient(client *db.OauthClient) *entities.OAuthClient {
+	res := &entities.OAuthClient{
+		ID:           client.ID,
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		Scopes:       client.Scopes,
+		RedirectURIs: client.RedirectUris,
+		GrantTypes:   client.GrantTypes,
+		Public:       client.IsPublic,
+		FirstParty:   client.FirstParty,
+		CreatedAt:    client.CreatedAt,
+	}
+	if client.LastUsedAt.Valid {
+		res.LastUsedAt = &client.LastUsedAt.Time
+	}
+	return res
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+const (
+	authorizationCodeBytes  = 32
+	codeChallengeMethodS256 = "S256"
+	// RFC 7636 section 4.1
+	minCodeVerifierLength = 43
+	maxCodeVerifierLength = 128
+)
+
+type OIDCOptions struct {
+	// Issuer is the public base URL of this service, e.g. "https://accounts.shopeezy.com".
+	Issuer  string
+	CodeTTL time.Duration
+	// AccessTokenTTL is the lifetime of ID tokens and of the access tokens of third-party clients.
+	AccessTokenTTL time.Duration
+}
+
+// AuthorizationGrant is an authorization request that passed validation.
+type AuthorizationGrant struct {
+	Client      *entities.OAuthClient
+	Request     *models.AuthorizationRequest
+	RedirectURI string
+	Scopes      []string
+}
+
+// OIDCTokens is the result of a token exchange. RefreshToken is only set for first-party clients.
+type OIDCTokens struct {
+	AccessToken  string
+	ExpiresAt    time.Time
+	RefreshToken string
+	IDToken      string
+	Scopes       []string
+}
+
+type OIDCService interface {
+	// ValidateAuthorizationRequest checks an authorization request. ErrInvalidClient and
+	// ErrInvalidRedirectURI have to be shown to the user, other errors are reported to the client
+	// through the redirect URI.
+	ValidateAuthorizationRequest(ctx context.Context, req *models.AuthorizationRequest) (*AuthorizationGrant, error)
+	// IssueAuthorizationCode returns the redirect URI carrying a new code for the signed in user.
+	IssueAuthorizationCode(ctx context.Context, grant *AuthorizationGrant, user *entities.User, amr []string) (string, error)
+	ExchangeAuthorizationCode(ctx context.Context, clientID string, clientSecret string, code string, redirectURI string, codeVerifier string, opts token.IssueOptions) (*OIDCTokens, error)
+	RefreshTokens(ctx context.Context, clientID string, clientSecret string, refreshToken string, opts token.IssueOptions) (*OIDCTokens, error)
+	UserInfo(ctx context.Context, claims *token.JWTClaims) (*models.UserInfoResponse, error)
+	Discovery() *models.OIDCDiscoveryResponse
+	// AuthorizationErrorRedirect builds the redirect URI that reports an error to the client.
+	AuthorizationErrorRedirect(grant *AuthorizationGrant, code string, description string) string
+}
+
+type OIDCServiceImpl struct {
+	oauthClientRepo repositories.OAuthClientRepository
+	codeRepo        repositories.AuthorizationCodeRepository
+	userRepo        repositories.UserRepository
+	tokenService    token.TokenService
+	opts            OIDCOptions
+	log             *logrus.Logger
+}
+
+func NewOIDCService(
+	oauthClientRepo repositories.OAuthClientRepository,
+	codeRepo repositories.AuthorizationCodeRepository,
+	userRepo repositories.UserRepository,
+	tokenService token.TokenService,
+	opts OIDCOptions,
+	log *logrus.Logger,
+) OIDCService {
+	opts.Issuer = strings.TrimSuffix(opts.Issuer, "/")
+	return &OIDCServiceImpl{
+		oauthClientRepo: oauthClientRepo,
+		codeRepo:        codeRepo,
+		userRepo:        userRepo,
+		tokenService:    tokenService,
+		opts:            opts,
+		log:             log,
+	}
+}
+
+func (s *OIDCServiceImpl) ValidateAuthorizationRequest(ctx context.Context, req *models.AuthorizationRequest) (*AuthorizationGrant, error) {
+	if req.ClientID == "" {
+		return nil, apperrors.ErrInvalidClient
+	}
+	stored, err := s.oauthClientRepo.GetClientByClientID(ctx, req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if stored.RevokedAt.Valid {
+		return nil, apperrors.ErrInvalidClient
+	}
+	client := toOAuthClient(stored)
+
+	// Only an exact match of a registered URI is accepted, it may be omitted when there is one
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if _, ok := intersectScopes([]string{redirectURI}, client.RedirectURIs); !ok {
+		return nil, apperrors.ErrInvalidRedirectURI
+	}
+
+	// From here on errors can be sent back to the client
+	grant := &AuthorizationGrant{Client: client, Request: req, RedirectURI: redirectURI}
+
+	if !client.AllowsGrant(entities.GrantAuthorizationCode) {
+		return grant, apperrors.ErrUnauthorizedClient
+	}
+	if req.ResponseType != "code" {
+		return grant, apperrors.ErrUnsupportedResponseType
+	}
+
+	requested := strings.Fields(req.Scope)
+	if _, hasOpenID := intersectScopes(requested, []string{entities.ScopeOpenID}); !hasOpenID {
+		return grant, fmt.Errorf("%w: the openid scope is required", apperrors.ErrInvalidScope)
+	}
+	grant.Scopes, err = normalizeScopes(requested, client.Scopes)
+	if err != nil {
+		return grant, fmt.Errorf("%w: %v", apperrors.ErrInvalidScope, err)
+	}
+
+	// PKCE is required for every client, not only public ones
+	if req.CodeChallengeMethod != codeChallengeMethodS256 || len(req.CodeChallenge) < minCodeVerifierLength || len(req.CodeChallenge) > maxCodeVerifierLength {
+		return grant, fmt.Errorf("%w: a code_challenge with code_challenge_method S256 is required", apperrors.ErrInvalidRequestPayload)
+	}
+
+	return grant, nil
+}
+
+func (s *OIDCServiceImpl) IssueAuthorizationCode(ctx context.Context, grant *AuthorizationGrant, user *entities.User, amr []string) (string, error) {
+	code, err := helpers.GenerateSecureToken(authorizationCodeBytes)
+	if err != nil {
+		return "", fmt.Errorf("service: failed to generate authorization code: %w", err)
+	}
+
+	now := time.Now()
+	_, err = s.codeRepo.CreateAuthorizationCode(ctx, &db.CreateAuthorizationCodeParams{
+		CodeHash:      helpers.HashToken(code),
+		OauthClientID: grant.Client.ID,
+		UserID:        user.ID,
+		RedirectUri:   grant.RedirectURI,
+		Scopes:        grant.Scopes,
+		Nonce:         grant.Request.Nonce,
+		CodeChallenge: grant.Request.CodeChallenge,
+		Amr:           amr,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(s.opts.CodeTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("service: failed to store authorization code: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"client_id": grant.Client.ClientID,
+		"user_id":   user.ID,
+		"scopes":    grant.Scopes,
+	}).Info("Authorization code issued")
+
+	params := url.Values{}
+	params.Set("code", code)
+	return s.redirectWith(grant, params), nil
+}
+
+func (s *OIDCServiceImpl) AuthorizationErrorRedirect(grant *AuthorizationGrant, code string, description string) string {
+	params := url.Values{}
+	params.Set("error", code)
+	if description != "" {
+		params.Set("error_description", description)
+	}
+	return s.redirectWith(grant, params)
+}
+
+// redirectWith appends the response parameters, the state and the issuer (RFC 9207) to the redirect URI.
+func (s *OIDCServiceImpl) redirectWith(grant *AuthorizationGrant, params url.Values) string {
+	if grant.Request.State != "" {
+		params.Set("state", grant.Request.State)
+	}
+	params.Set("iss", s.opts.Issuer)
+
+	u, _ := url.Parse(grant.RedirectURI)
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func (s *OIDCServiceImpl) ExchangeAuthorizationCode(ctx context.Context, clientID string, clientSecret string, code string, redirectURI string, codeVerifier string, opts token.IssueOptions) (*OIDCTokens, error) {
+	stored, err := authenticateClient(ctx, s.oauthClientRepo, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	client := toOAuthClient(stored)
+	if !client.AllowsGrant(entities.GrantAuthorizationCode) {
+		return nil, apperrors.ErrUnauthorizedClient
+	}
+
+	if code == "" || codeVerifier == "" {
+		return nil, fmt.Errorf("%w: code and code_verifier are required", apperrors.ErrInvalidRequestPayload)
+	}
+
+	// The code is consumed before any further check, a failed attempt burns it
+	grant, err := s.codeRepo.ConsumeAuthorizationCode(ctx, helpers.HashToken(code))
+	if err != nil {
+		return nil, err
+	}
+	if grant.OauthClientID != client.ID || grant.RedirectUri != redirectURI {
+		return nil, apperrors.ErrInvalidGrant
+	}
+	if !verifyCodeChallenge(codeVerifier, grant.CodeChallenge) {
+		return nil, apperrors.ErrInvalidGrant
+	}
+
+	userDB, err := s.userRepo.GetUserByID(ctx, grant.UserID)
+	if err != nil {
+		s.log.WithError(err).WithField("user_id", grant.UserID).Warn("Authorization code rejected, user could not be loaded")
+		return nil, apperrors.ErrInvalidGrant
+	}
+	user := toDomainUser(userDB)
+
+	tokens := &OIDCTokens{Scopes: grant.Scopes}
+	opts.AMR = grant.Amr
+	opts.ClientID = client.ClientID
+	if client.FirstParty {
+		// Our own apps get the same session as a password login on /login
+		pair, err := s.tokenService.GenerateTokenPair(ctx, user, opts)
+		if err != nil {
+			return nil, fmt.Errorf("service: failed to issue tokens: %w", err)
+		}
+		tokens.AccessToken = pair.AccessToken
+		tokens.ExpiresAt = pair.AccessTokenExpiresAt
+		if client.AllowsGrant(entities.GrantRefreshToken) {
+			tokens.RefreshToken = pair.RefreshToken
+		}
+	} else {
+		// Other apps only get a token for the userinfo endpoint, never for the account API
+		tokens.AccessToken, tokens.ExpiresAt, err = s.tokenService.GenerateUserInfoToken(ctx, user, grant.Scopes, s.opts.AccessTokenTTL, opts)
+		if err != nil {
+			return nil, fmt.Errorf("service: failed to issue tokens: %w", err)
+		}
+	}
+
+	tokens.IDToken, err = s.tokenService.GenerateIDToken(ctx, user, token.IDTokenOptions{
+		Issuer:      s.opts.Issuer,
+		ClientID:    client.ClientID,
+		Nonce:       grant.Nonce,
+		AuthTime:    grant.AuthTime,
+		AMR:         grant.Amr,
+		Scopes:      grant.Scopes,
+		AccessToken: tokens.AccessToken,
+		TTL:         s.opts.AccessTokenTTL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to issue id token: %w", err)
+	}
+
+	if err := s.oauthClientRepo.TouchClient(ctx, client.ID); err != nil {
+		s.log.WithError(err).WithField("client_id", clientID).Warn("Failed to update oauth client last use")
+	}
+
+	s.log.WithFields(logrus.Fields{"client_id": clientID, "user_id": user.ID}).Info("Authorization code exchanged")
+	return tokens, nil
+}
+
+// RefreshTokens rotates the refresh token of a first-party client, like /token/refresh does.
+// Only refresh tokens issued to the same client are accepted.
+func (s *OIDCServiceImpl) RefreshTokens(ctx context.Context, clientID string, clientSecret string, refreshToken string, opts token.IssueOptions) (*OIDCTokens, error) {
+	stored, err := authenticateClient(ctx, s.oauthClientRepo, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	client := toOAuthClient(stored)
+	if !client.FirstParty || !client.AllowsGrant(entities.GrantRefreshToken) {
+		return nil, apperrors.ErrUnauthorizedClient
+	}
+
+	if refreshToken == "" {
+		return nil, fmt.Errorf("%w: refresh_token is required", apperrors.ErrInvalidRequestPayload)
+	}
+
+	opts.ClientID = client.ClientID
+	pair, err := s.tokenService.Refresh(ctx, refreshToken, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCTokens{
+		AccessToken:  pair.AccessToken,
+		ExpiresAt:    pair.AccessTokenExpiresAt,
+		RefreshToken: pair.RefreshToken,
+		Scopes:       client.Scopes,
+	}, nil
+}
+
+// UserInfo returns the claims the token's scopes grant. Regular session tokens have no scope
+// and see every claim.
+func (s *OIDCServiceImpl) UserInfo(ctx context.Context, claims *token.JWTClaims) (*models.UserInfoResponse, error) {
+	for _, method := range claims.AMR {
+		if method == entities.AMRAPIKey {
+			return nil, apperrors.ErrInvalidToken
+		}
+	}
+
+	scopes := strings.Fields(claims.Scope)
+	if len(scopes) == 0 {
+		scopes = entities.OIDCScopes
+	}
+	if _, hasOpenID := intersectScopes(scopes, []string{entities.ScopeOpenID}); !hasOpenID {
+		return nil, fmt.Errorf("%w: the token was not issued for the openid scope", apperrors.ErrForbidden)
+	}
+
+	userDB, err := s.userRepo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: user %s", apperrors.ErrNotFound, claims.UserID)
+	}
+	user := toDomainUser(userDB)
+
+	res := &models.UserInfoResponse{Subject: user.ID.String()}
+	for _, scope := range scopes {
+		switch scope {
+		case entities.ScopeProfile:
+			res.Name = user.Name
+			res.PreferredUsername = user.Username
+			res.UpdatedAt = user.UpdatedAt.Unix()
+		case entities.ScopeEmail:
+			verified := user.EmailVerified()
+			res.Email = user.Email
+			res.EmailVerified = &verified
+		}
+	}
+	return res, nil
+}
+
+func (s *OIDCServiceImpl) Discovery() *models.OIDCDiscoveryResponse {
+	return &models.OIDCDiscoveryResponse{
+		Issuer:                            s.opts.Issuer,
+		AuthorizationEndpoint:             s.opts.Issuer + "/oauth/authorize",
+		TokenEndpoint:                     s.opts.Issuer + "/oauth/token",
+		UserInfoEndpoint:                  s.opts.Issuer + "/oauth/userinfo",
+		JWKSURI:                           s.opts.Issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   entities.OIDCScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{entities.GrantAuthorizationCode, entities.GrantRefreshToken, entities.GrantClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{s.tokenService.SigningAlgorithm()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{codeChallengeMethodS256},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "amr", "at_hash",
+			"name", "preferred_username", "updated_at", "email", "email_verified",
+		},
+	}
+}
+
+// verifyCodeChallenge checks the PKCE verifier against the S256 challenge of the authorization request.
+func verifyCodeChallenge(verifier string, challenge string) bool {
+	if len(verifier) < minCodeVerifierLength || len(verifier) > maxCodeVerifierLength {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+)
+
+// maxLastErrorLength keeps a verbose broker error from bloating the outbox row.
+const maxLastErrorLength = 500
+
+// EventPublisher delivers one event to the message broker and returns once the broker
+// confirmed it. The message id lets consumers drop duplicates.
+type EventPublisher interface {
+	Publish(ctx context.Context, routingKey string, messageID string, body []byte) error
+}
+
+// OutboxRelayOptions configures the outbox relay. Due events are polled every PollInterval,
+// BatchSize at a time, and hidden from other relays for Lease while they are published. A failed
+// publish is retried after RetryBase, doubling per attempt up to RetryMax.
+type OutboxRelayOptions struct {
+	PollInterval time.Duration
+	BatchSize    int32
+	Lease        time.Duration
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+}
+
+type OutboxRelay interface {
+	// Run relays events until ctx is cancelled.
+	Run(ctx context.Context)
+	// RelayBatch publishes one batch of due events and returns how many were claimed.
+	RelayBatch(ctx context.Context) (int, error)
+}
+
+type OutboxRelayImpl struct {
+	outboxRepo repositories.OutboxRepository
+	publisher  EventPublisher
+	opts       OutboxRelayOptions
+	log        *logrus.Logger
+}
+
+func NewOutboxRelay(
+	outboxRepo repositories.OutboxRepository,
+	publisher EventPublisher,
+	opts OutboxRelayOptions,
+	log *logrus.Logger,
+) OutboxRelay {
+	return &OutboxRelayImpl{
+		outboxRepo: outboxRepo,
+		publisher:  publisher,
+		opts:       opts,
+		log:        log,
+	}
+}
+
+func (r *OutboxRelayImpl) Run(ctx context.Context) {
+	r.log.WithField("interval", r.opts.PollInterval).Info("Outbox relay started")
+
+	for {
+		claimed, err := r.RelayBatch(ctx)
+		if err != nil {
+			r.log.WithError(err).Error("Failed to relay outbox events")
+		}
+
+		// A full batch means there is probably more waiting, so only sleep once caught up
+		if err == nil && claimed == int(r.opts.BatchSize) && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			r.log.Info("Outbox relay stopped")
+			return
+		case <-time.After(r.opts.PollInterval):
+		}
+	}
+}
+
+func (r *OutboxRelayImpl) RelayBatch(ctx context.Context) (int, error) {
+	events, err := r.outboxRepo.ClaimEvents(ctx, r.opts.BatchSize, time.Now().Add(r.opts.Lease))
+	if err != nil {
+		return 0, fmt.Errorf("service: failed to claim outbox events: %w", err)
+	}
+
+	// Publish in the order the changes happened
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].CreatedAt.Before(events[j].CreatedAt)
+	})
+
+	for i := range events {
+		event := &events[i]
+
+		if err := r.publisher.Publish(ctx, event.EventType, event.ID.String(), event.Payload); err != nil {
+			if ctx.Err() != nil {
+				// Shutting down, the rest of the batch is picked up again once the lease ran out
+				return len(events), nil
+			}
+			r.retryLater(ctx, event, err)
+			continue
+		}
+
+		// Failing here only means the event is published again once the lease ran out
+		if err := r.outboxRepo.MarkPublished(ctx, event.ID); err != nil {
+			r.log.WithError(err).WithField("event_id", event.ID).Error("Failed to mark outbox event published")
+		}
+	}
+
+	return len(events), nil
+}
+
+func (r *OutboxRelayImpl) retryLater(ctx context.Context, event *db.Outbox, publishErr error) {
+	delay := r.backoff(event.Attempts)
+
+	lastError := publishErr.Error()
+	if len(lastError) > maxLastErrorLength {
+		lastError = lastError[:maxLastErrorLength]
+	}
+
+	r.log.WithError(publishErr).WithFields(logrus.Fields{
+		"event_id":   event.ID,
+		"event_type": event.EventType,
+		"attempts":   event.Attempts + 1,
+		"retry_in":   delay,
+	}).Warn("Failed to publish outbox event")
+
+	err := r.outboxRepo.MarkFailed(ctx, &db.MarkOutboxEventFailedParams{
+		ID:            event.ID,
+		LastError:     lastError,
+		NextAttemptAt: time.Now().Add(delay),
+	})
+	if err != nil {
+		r.log.WithError(err).WithField("event_id", event.ID).Error("Failed to schedule outbox event retry")
+	}
+}
+
+// backoff returns RetryBase doubled for every earlier attempt, capped at RetryMax.
+func (r *OutboxRelayImpl) backoff(attempts int32) time.Duration {
+	delay := r.opts.RetryBase
+	for i := int32(0); i < attempts && delay < r.opts.RetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, r.opts.RetryMax)
+}
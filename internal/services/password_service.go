@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/notifier"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+const resetTokenBytes = 32
+
+type PasswordService interface {
+	RequestPasswordReset(ctx context.Context, req *models.ForgotPasswordRequest) error
+	ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) error
+}
+
+type PasswordServiceImpl struct {
+	userRepo          repositories.UserRepository
+	passwordResetRepo repositories.PasswordResetRepository
+	tokenService      token.TokenService
+	notifier          notifier.Notifier
+	validator         *validator.Validate
+	resetTTL          time.Duration
+	resetURL          string
+	log               *logrus.Logger
+}
+
+func NewPasswordService(
+	userRepo repositories.UserRepository,
+	passwordResetRepo repositories.PasswordResetRepository,
+	tokenService token.TokenService,
+	notifier notifier.Notifier,
+	validator *validator.Validate,
+	resetTTL time.Duration,
+	resetURL string,
+	log *logrus.Logger,
+) PasswordService {
+	return &PasswordServiceImpl{
+		userRepo:          userRepo,
+		passwordResetRepo: passwordResetRepo,
+		tokenService:      tokenService,
+		notifier:          notifier,
+		validator:         validator,
+		resetTTL:          resetTTL,
+		resetURL:          resetURL,
+		log:               log,
+	}
+}
+
+// RequestPasswordReset sends a reset link if the email belongs to an account. Unknown emails are
+// not reported back to the caller so the endpoint can not be used to enumerate accounts.
+func (s *PasswordServiceImpl) RequestPasswordReset(ctx context.Context, req *models.ForgotPasswordRequest) error {
+	if err := s.validator.Struct(req); err != nil {
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidRequestPayload, err)
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.log.Debug("Password reset requested for unknown email")
+			return nil
+		}
+		return fmt.Errorf("service: failed to request password reset: %w", err)
+	}
+
+	resetToken, err := helpers.GenerateSecureToken(resetTokenBytes)
+	if err != nil {
+		return fmt.Errorf("service: failed to request password reset: %w", err)
+	}
+
+	// Only the latest link is valid
+	if err := s.passwordResetRepo.InvalidateUserResetTokens(ctx, user.ID); err != nil {
+		return fmt.Errorf("service: failed to request password reset: %w", err)
+	}
+
+	_, err = s.passwordResetRepo.CreateResetToken(ctx, &db.CreatePasswordResetTokenParams{
+		UserID:    user.ID,
+		TokenHash: helpers.HashToken(resetToken),
+		ExpiresAt: time.Now().Add(s.resetTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("service: failed to request password reset: %w", err)
+	}
+
+	err = s.notifier.Send(ctx, notifier.Message{
+		To:      user.Email,
+		Subject: "Reset your Shopeezy password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s\n\nIf you did not request this, you can ignore this message.",
+			user.Name, s.resetTTL, s.resetLink(resetToken),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("service: failed to send password reset: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword redeems a reset token, stores the new password and signs the user out everywhere.
+func (s *PasswordServiceImpl) ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) error {
+	if err := s.validator.Struct(req); err != nil {
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidRequestPayload, err)
+	}
+
+	resetToken, err := s.passwordResetRepo.ConsumeResetToken(ctx, helpers.HashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrTokenNotFound) {
+			return apperrors.ErrInvalidResetToken
+		}
+		return fmt.Errorf("service: failed to reset password: %w", err)
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("service: failed to reset password: %w", err)
+	}
+
+	err = s.userRepo.UpdateUserPassword(ctx, &db.UpdateUserPasswordParams{
+		ID:       resetToken.UserID,
+		Password: string(hashedPassword),
+	})
+	if err != nil {
+		return fmt.Errorf("service: failed to reset password: %w", err)
+	}
+
+	if err := s.passwordResetRepo.InvalidateUserResetTokens(ctx, resetToken.UserID); err != nil {
+		return fmt.Errorf("service: failed to reset password: %w", err)
+	}
+
+	if err := s.tokenService.RevokeAllUserTokens(ctx, resetToken.UserID); err != nil {
+		return fmt.Errorf("service: failed to revoke tokens after password reset: %w", err)
+	}
+
+	s.log.WithField("user_id", resetToken.UserID).Info("Password reset completed")
+	return nil
+}
+
+func (s *PasswordServiceImpl) resetLink(resetToken string) string {
+	u, err := url.Parse(s.resetURL)
+	if err != nil {
+		return s.resetURL + "?token=" + url.QueryEscape(resetToken)
+	}
+
+	q := u.Query()
+	q.Set("token", resetToken)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
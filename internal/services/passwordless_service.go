@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/notifier"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+// Delivery methods of a passwordless login.
+const (
+	MagicLoginMethodLink = "link"
+	MagicLoginMethodCode = "code"
+)
+
+const magicLoginCodeDigits = 6
+
+// PasswordlessOptions configures passwordless login. A link or code is valid for TTL. At most
+// MaxAttempts codes can be tried per address and TTL, however many codes were sent, and at most
+// SendLimit mails are sent per address and SendWindow.
+type PasswordlessOptions struct {
+	TTL         time.Duration
+	MaxAttempts int64
+	SendLimit   int64
+	SendWindow  time.Duration
+	// LinkURL is the frontend page the link token is appended to as ?token=...
+	LinkURL string
+}
+
+type PasswordlessService interface {
+	RequestLogin(ctx context.Context, req *models.MagicLoginRequest) error
+	VerifyLogin(ctx context.Context, req *models.MagicLoginVerifyRequest) (*entities.User, error)
+}
+
+type PasswordlessServiceImpl struct {
+	userRepo       repositories.UserRepository
+	preferenceRepo repositories.PreferenceRepository
+	magicLoginRepo repositories.MagicLoginRepository
+	attemptRepo    repositories.AttemptRepository
+	tokenService   token.TokenService
+	notifier       notifier.Notifier
+	validator      *validator.Validate
+	opts           PasswordlessOptions
+	log            *logrus.Logger
+}
+
+func NewPasswordlessService(
+	userRepo repositories.UserRepository,
+	preferenceRepo repositories.PreferenceRepository,
+	magicLoginRepo repositories.MagicLoginRepository,
+	attemptRepo repositories.AttemptRepository,
+	tokenService token.TokenService,
+	notifier notifier.Notifier,
+	validator *validator.Validate,
+	opts PasswordlessOptions,
+	log *logrus.Logger,
+) PasswordlessService {
+	return &PasswordlessServiceImpl{
+		userRepo:       userRepo,
+		preferenceRepo: preferenceRepo,
+		magicLoginRepo: magicLoginRepo,
+		attemptRepo:    attemptRepo,
+		tokenService:   tokenService,
+		notifier:       notifier,
+		validator:      validator,
+		opts:           opts,
+		log:            log,
+	}
+}
+
+// RequestLogin sends a sign-in link or code if the email belongs to an account that opted in to
+// passwordless login. Unknown, not opted in and throttled addresses get the same answer, so the
+// endpoint does not reveal which emails are registered.
+func (s *PasswordlessServiceImpl) RequestLogin(ctx context.Context, req *models.MagicLoginRequest) error {
+	if err := s.validator.Struct(req); err != nil {
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidRequestPayload, err)
+	}
+	method := req.Method
+	if method == "" {
+		method = MagicLoginMethodLink
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	attempts, err := s.attemptRepo.Increment(ctx, "magic_login:send:"+email, s.opts.SendWindow)
+	if err != nil {
+		return fmt.Errorf("service: failed to count magic login request: %w", err)
+	}
+	if attempts > s.opts.SendLimit {
+		s.log.WithField("attempts", attempts).Warn("Magic login send limit reached")
+		return nil
+	}
+
+	userDB, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.log.Debug("Magic login requested for unknown email")
+			return nil
+		}
+		return fmt.Errorf("service: failed to request magic login: %w", err)
+	}
+	user := toDomainUser(userDB)
+
+	allowed, err := s.passwordlessAllowed(ctx, user)
+	if err != nil {
+		return fmt.Errorf("service: failed to request magic login: %w", err)
+	}
+	if !allowed {
+		s.log.WithField("user_id", user.ID).Debug("Magic login requested for an account without passwordless login")
+		return nil
+	}
+
+	challenge := &models.MagicLoginChallenge{Method: method}
+	var msg notifier.Message
+	switch method {
+	case MagicLoginMethodLink:
+		linkToken, _, err := s.tokenService.GeneratePurposeToken(ctx, token.PurposeSubject{
+			UserID: user.ID,
+			Email:  user.Email,
+		}, token.PurposeMagicLink, s.opts.TTL)
+		if err != nil {
+			return fmt.Errorf("service: failed to generate magic link: %w", err)
+		}
+		challenge.TokenHash = helpers.HashToken(linkToken)
+		msg = notifier.Message{
+			To:      user.Email,
+			Subject: "Your Shopeezy sign-in link",
+			Body: fmt.Sprintf(
+				"Hi %s,\n\nOpen the link below to sign in. It expires in %s and can only be used once.\n\n%s\n\nIf you did not request this, you can ignore this message.",
+				user.Name, s.opts.TTL, s.magicLink(linkToken),
+			),
+		}
+	case MagicLoginMethodCode:
+		code, err := generateLoginCode()
+		if err != nil {
+			return fmt.Errorf("service: failed to generate login code: %w", err)
+		}
+		challenge.CodeHash = helpers.HashToken(code)
+		msg = notifier.Message{
+			To:      user.Email,
+			Subject: "Your Shopeezy sign-in code",
+			Body: fmt.Sprintf(
+				"Hi %s,\n\nYour sign-in code is %s. It expires in %s.\n\nIf you did not request this, you can ignore this message.",
+				user.Name, code, s.opts.TTL,
+			),
+		}
+	}
+
+	// The new challenge replaces any earlier one. It does not reset the attempts of the address,
+	// or every resend would bring another MaxAttempts guesses at the code
+	if err := s.magicLoginRepo.SaveChallenge(ctx, user.ID, challenge, s.opts.TTL); err != nil {
+		return fmt.Errorf("service: failed to request magic login: %w", err)
+	}
+
+	if err := s.notifier.Send(ctx, msg); err != nil {
+		return fmt.Errorf("service: failed to send magic login: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"user_id": user.ID,
+		"method":  method,
+	}).Info("Magic login sent")
+
+	return nil
+}
+
+// VerifyLogin redeems a sign-in link token, or an email and code, and returns the user to sign in.
+func (s *PasswordlessServiceImpl) VerifyLogin(ctx context.Context, req *models.MagicLoginVerifyRequest) (*entities.User, error) {
+	var (
+		userID uuid.UUID
+		email  string
+	)
+
+	switch {
+	case req.Token != "":
+		claims, err := s.tokenService.ValidatePurposeToken(ctx, req.Token, token.PurposeMagicLink)
+		if err != nil {
+			return nil, apperrors.ErrInvalidLoginCode
+		}
+
+		challenge, err := s.magicLoginRepo.GetChallenge(ctx, claims.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if challenge.Method != MagicLoginMethodLink || !hashEqual(challenge.TokenHash, helpers.HashToken(req.Token)) {
+			return nil, apperrors.ErrInvalidLoginCode
+		}
+		userID, email = claims.UserID, claims.Email
+	case req.Email != "" && req.Code != "":
+		email = strings.ToLower(strings.TrimSpace(req.Email))
+
+		attemptKey := "magic_login:verify:" + email
+		attempts, err := s.attemptRepo.Increment(ctx, attemptKey, s.opts.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("service: failed to count magic login attempt: %w", err)
+		}
+		if attempts > s.opts.MaxAttempts {
+			return nil, apperrors.ErrTooManyAttempts
+		}
+
+		userDB, err := s.userRepo.GetUserByEmail(ctx, email)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, apperrors.ErrInvalidLoginCode
+			}
+			return nil, fmt.Errorf("service: failed to get user: %w", err)
+		}
+
+		challenge, err := s.magicLoginRepo.GetChallenge(ctx, userDB.ID)
+		if err != nil {
+			return nil, err
+		}
+		if challenge.Method != MagicLoginMethodCode || !hashEqual(challenge.CodeHash, helpers.HashToken(req.Code)) {
+			if attempts == s.opts.MaxAttempts {
+				// Out of attempts, the user has to request a new code
+				_, _ = s.magicLoginRepo.DeleteChallenge(ctx, userDB.ID)
+			}
+			return nil, apperrors.ErrInvalidLoginCode
+		}
+		_ = s.attemptRepo.Reset(ctx, attemptKey)
+		userID = userDB.ID
+	default:
+		return nil, apperrors.ErrInvalidRequestPayload
+	}
+
+	// Single use: of two requests with the same link or code only one deletes the challenge
+	consumed, err := s.magicLoginRepo.DeleteChallenge(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to consume magic login: %w", err)
+	}
+	if !consumed {
+		return nil, apperrors.ErrInvalidLoginCode
+	}
+
+	userDB, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrInvalidLoginCode
+		}
+		return nil, fmt.Errorf("service: failed to get user: %w", err)
+	}
+	user := toDomainUser(userDB)
+
+	// The address or the opt-in may have changed since the mail was sent
+	allowed, err := s.passwordlessAllowed(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to verify magic login: %w", err)
+	}
+	if !allowed || !strings.EqualFold(user.Email, email) {
+		return nil, apperrors.ErrInvalidLoginCode
+	}
+
+	return user, nil
+}
+
+// passwordlessAllowed reports whether the user opted in and proved to own the email address.
+func (s *PasswordlessServiceImpl) passwordlessAllowed(ctx context.Context, user *entities.User) (bool, error) {
+	if !user.EmailVerified() {
+		return false, nil
+	}
+
+	prefs, err := s.preferenceRepo.GetPreferences(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	return prefs.PasswordlessLogin, nil
+}
+
+func (s *PasswordlessServiceImpl) magicLink(linkToken string) string {
+	u, err := url.Parse(s.opts.LinkURL)
+	if err != nil {
+		return s.opts.LinkURL + "?token=" + url.QueryEscape(linkToken)
+	}
+
+	q := u.Query()
+	q.Set("token", linkToken)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// generateLoginCode returns a uniformly distributed code of magicLoginCodeDigits digits.
+func generateLoginCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", magicLoginCodeDigits, n.Int64()), nil
+}
+
+func hashEqual(a string, b string) bool {
+	return a != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
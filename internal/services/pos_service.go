@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+const (
+	minPINLength = 4
+	maxPINLength = 6
+	// terminalSecretBytes of randomness authenticate a terminal on PIN login.
+	terminalSecretBytes = 32
+)
+
+type POSOptions struct {
+	// PINMaxAttempts wrong PINs lock PIN login of the user for PINLockoutDuration.
+	PINMaxAttempts     int64
+	PINLockoutDuration time.Duration
+	// TokenTTL is the lifetime of PIN login tokens. They can not be refreshed.
+	TokenTTL time.Duration
+}
+
+// PINLoginRequest identifies the cashier on a registered terminal. The terminal lists the
+// cashiers of its store, so the username is picked rather than typed.
+type PINLoginRequest struct {
+	TerminalID uuid.UUID
+	// TerminalSecret was returned when the terminal was registered and is kept on the device.
+	TerminalSecret string
+	Username       string
+	PIN            string
+}
+
+type POSService interface {
+	// SetPIN sets or replaces the PIN of the user, confirmed with their password.
+	SetPIN(ctx context.Context, userID uuid.UUID, password string, pin string) error
+	RemovePIN(ctx context.Context, userID uuid.UUID) error
+	// RegisterTerminal returns the terminal and its secret. Only a hash of the secret is stored,
+	// it can not be retrieved again.
+	RegisterTerminal(ctx context.Context, storeID uuid.UUID, name string, actor StoreActor) (*entities.POSTerminal, string, error)
+	ListTerminals(ctx context.Context, storeID uuid.UUID, actor StoreActor) ([]entities.POSTerminal, error)
+	RevokeTerminal(ctx context.Context, storeID uuid.UUID, terminalID uuid.UUID, actor StoreActor) error
+	// PINLogin returns a short-lived token limited to entities.POSScopes for the terminal's store.
+	PINLogin(ctx context.Context, req *PINLoginRequest, opts token.IssueOptions) (string, time.Time, error)
+}
+
+type POSServiceImpl struct {
+	posRepo      repositories.POSRepository
+	tenancyRepo  repositories.TenancyRepository
+	userRepo     repositories.UserRepository
+	attemptRepo  repositories.AttemptRepository
+	tokenService token.TokenService
+	opts         POSOptions
+	log          *logrus.Logger
+}
+
+func NewPOSService(
+	posRepo repositories.POSRepository,
+	tenancyRepo repositories.TenancyRepository,
+	userRepo repositories.UserRepository,
+	attemptRepo repositories.AttemptRepository,
+	tokenService token.TokenService,
+	opts POSOptions,
+	log *logrus.Logger,
+) POSService {
+	return &POSServiceImpl{
+		posRepo:      posRepo,
+		tenancyRepo:  tenancyRepo,
+		userRepo:     userRepo,
+		attemptRepo:  attemptRepo,
+		tokenService: tokenService,
+		opts:         opts,
+		log:          log,
+	}
+}
+
+func pinAttemptKey(userID uuid.UUID) string {
+	return "pin:user:" + userID.String()
+}
+
+func (s *POSServiceImpl) SetPIN(ctx context.Context, userID uuid.UUID, password string, pin string) error {
+	if err := validatePIN(pin); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("service: failed to get user: %w", err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return apperrors.ErrInvalidCredentials
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("service: failed to hash pin: %w", err)
+	}
+
+	if err := s.posRepo.UpsertUserPin(ctx, &db.UpsertUserPinParams{UserID: userID, PinHash: string(hash)}); err != nil {
+		return fmt.Errorf("service: failed to set pin: %w", err)
+	}
+	_ = s.attemptRepo.Reset(ctx, pinAttemptKey(userID))
+
+	s.log.WithField("user_id", userID).Info("PIN set")
+	return nil
+}
+
+func (s *POSServiceImpl) RemovePIN(ctx context.Context, userID uuid.UUID) error {
+	removed, err := s.posRepo.DeleteUserPin(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("service: failed to remove pin: %w", err)
+	}
+	if !removed {
+		return apperrors.ErrPINNotSet
+	}
+	return nil
+}
+
+func (s *POSServiceImpl) RegisterTerminal(ctx context.Context, storeID uuid.UUID, name string, actor StoreActor) (*entities.POSTerminal, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", apperrors.ErrInvalidRequestPayload)
+	}
+
+	if _, err := s.tenancyRepo.GetStore(ctx, storeID); err != nil {
+		return nil, "", err
+	}
+	if err := s.authorizeStoreManager(ctx, storeID, actor); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := helpers.GenerateSecureToken(terminalSecretBytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("service: failed to generate terminal secret: %w", err)
+	}
+
+	terminal, err := s.posRepo.CreateTerminal(ctx, &db.CreatePosTerminalParams{
+		StoreID:      storeID,
+		Name:         name,
+		RegisteredBy: uuid.NullUUID{UUID: actor.UserID, Valid: true},
+		SecretHash:   helpers.HashToken(secret),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("service: failed to register terminal: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"store_id":    storeID,
+		"terminal_id": terminal.ID,
+		"actor_id":    actor.UserID,
+	}).Info("POS terminal registered")
+	return toPOSTerminal(terminal), secret, nil
+}
+
+func (s *POSServiceImpl) ListTerminals(ctx context.Context, storeID uuid.UUID, actor StoreActor) ([]entities.POSTerminal, error) {
+	if err := s.authorizeStoreManager(ctx, storeID, actor); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.posRepo.ListTerminals(ctx, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list terminals: %w", err)
+	}
+
+	terminals := make([]entities.POSTerminal, 0, len(rows))
+	for i := range rows {
+		terminals = append(terminals, *toPOSTerminal(&rows[i]))
+	}
+	return terminals, nil
+}
+
+// RevokeTerminal stops PIN logins on the terminal. Tokens it already issued stay valid until they
+// expire after POSOptions.TokenTTL.
+func (s *POSServiceImpl) RevokeTerminal(ctx context.Context, storeID uuid.UUID, terminalID uuid.UUID, actor StoreActor) error {
+	if err := s.authorizeStoreManager(ctx, storeID, actor); err != nil {
+		return err
+	}
+
+	revoked, err := s.posRepo.RevokeTerminal(ctx, &db.RevokePosTerminalParams{ID: terminalID, StoreID: storeID})
+	if err != nil {
+		return fmt.Errorf("service: failed to revoke terminal: %w", err)
+	}
+	if !revoked {
+		return fmt.Errorf("%w: terminal %s", apperrors.ErrNotFound, terminalID)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"store_id":    storeID,
+		"terminal_id": terminalID,
+		"actor_id":    actor.UserID,
+	}).Info("POS terminal revoked")
+	return nil
+}
+
+func (s *POSServiceImpl) PINLogin(ctx context.Context, req *PINLoginRequest, opts token.IssueOptions) (string, time.Time, error) {
+	terminal, err := s.posRepo.GetTerminal(ctx, req.TerminalID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return "", time.Time{}, apperrors.ErrInvalidTerminal
+		}
+		return "", time.Time{}, fmt.Errorf("service: failed to get terminal: %w", err)
+	}
+	if terminal.RevokedAt.Valid || !validTerminalSecret(terminal, req.TerminalSecret) {
+		return "", time.Time{}, apperrors.ErrInvalidTerminal
+	}
+
+	userDB, err := s.userRepo.GetUserByUsername(ctx, req.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.PIN))
+			return "", time.Time{}, apperrors.ErrInvalidCredentials
+		}
+		return "", time.Time{}, fmt.Errorf("service: failed to get user: %w", err)
+	}
+
+	attemptKey := pinAttemptKey(userDB.ID)
+	if err := s.checkPINLock(ctx, attemptKey); err != nil {
+		return "", time.Time{}, err
+	}
+
+	pinHash := dummyPasswordHash
+	pin, err := s.posRepo.GetUserPin(ctx, userDB.ID)
+	switch {
+	case err == nil:
+		pinHash = []byte(pin.PinHash)
+	case !errors.Is(err, apperrors.ErrPINNotSet):
+		return "", time.Time{}, fmt.Errorf("service: failed to get pin: %w", err)
+	}
+
+	if bcrypt.CompareHashAndPassword(pinHash, []byte(req.PIN)) != nil || pin == nil {
+		return "", time.Time{}, s.pinFailed(ctx, attemptKey, userDB.ID)
+	}
+	_ = s.attemptRepo.Reset(ctx, attemptKey)
+
+	// Only cashiers of the terminal's store may sign in on it
+	if _, err := s.tenancyRepo.GetStoreMembership(ctx, &db.GetStoreMembershipParams{UserID: userDB.ID, StoreID: terminal.StoreID}); err != nil {
+		return "", time.Time{}, err
+	}
+
+	if err := s.posRepo.TouchTerminal(ctx, terminal.ID); err != nil {
+		s.log.WithError(err).Warn("Failed to update last use of terminal")
+	}
+
+	opts.StoreID = uuid.NullUUID{UUID: terminal.StoreID, Valid: true}
+	opts.AMR = []string{entities.AMRPIN}
+	opts.Device = "POS terminal " + terminal.Name
+
+	accessToken, expiresAt, err := s.tokenService.GenerateScopedToken(ctx, toDomainUser(userDB), entities.POSScopes, s.opts.TokenTTL, opts)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("service: failed to issue pin token: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"user_id":     userDB.ID,
+		"terminal_id": terminal.ID,
+	}).Info("PIN login")
+	return accessToken, expiresAt, nil
+}
+
+// checkPINLock returns a *RetryAfterError while PIN login of the user is locked.
+func (s *POSServiceImpl) checkPINLock(ctx context.Context, attemptKey string) error {
+	attempts, err := s.attemptRepo.Count(ctx, attemptKey)
+	if err != nil {
+		return fmt.Errorf("service: failed to check pin attempts: %w", err)
+	}
+	if attempts < s.opts.PINMaxAttempts {
+		return nil
+	}
+
+	retryAfter, err := s.attemptRepo.TTL(ctx, attemptKey)
+	if err != nil {
+		retryAfter = s.opts.PINLockoutDuration
+	}
+	return &apperrors.RetryAfterError{Err: apperrors.ErrPINLocked, RetryAfter: retryAfter}
+}
+
+// pinFailed counts a wrong PIN. The window restarts with every failure, so the lock lasts
+// PINLockoutDuration after the last attempt.
+func (s *POSServiceImpl) pinFailed(ctx context.Context, attemptKey string, userID uuid.UUID) error {
+	attempts, err := s.attemptRepo.Increment(ctx, attemptKey, s.opts.PINLockoutDuration)
+	if err != nil {
+		return fmt.Errorf("service: failed to count pin attempt: %w", err)
+	}
+
+	if attempts >= s.opts.PINMaxAttempts {
+		s.log.WithField("user_id", userID).Warn("PIN login locked after too many failed attempts")
+		return &apperrors.RetryAfterError{Err: apperrors.ErrPINLocked, RetryAfter: s.opts.PINLockoutDuration}
+	}
+	return apperrors.ErrInvalidCredentials
+}
+
+// authorizeStoreManager lets owners and managers of the store through.
+func (s *POSServiceImpl) authorizeStoreManager(ctx context.Context, storeID uuid.UUID, actor StoreActor) error {
+	if actor.CanManageTenants {
+		return nil
+	}
+
+	membership, err := s.tenancyRepo.GetStoreMembership(ctx, &db.GetStoreMembershipParams{UserID: actor.UserID, StoreID: storeID})
+	if err != nil {
+		return err
+	}
+	if membership.Role != entities.StoreRoleOwner && membership.Role != entities.StoreRoleManager {
+		return fmt.Errorf("%w: only owners and managers manage terminals", apperrors.ErrForbidden)
+	}
+	return nil
+}
+
+// validTerminalSecret compares the secret with the stored hash. Terminals registered before
+// secrets were introduced have no hash and are refused.
+func validTerminalSecret(terminal *db.PosTerminal, secret string) bool {
+	if secret == "" || terminal.SecretHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(helpers.HashToken(secret)), []byte(terminal.SecretHash)) == 1
+}
+
+// validatePIN accepts 4 to 6 digits that are not trivially guessable.
+func validatePIN(pin string) error {
+	if len(pin) < minPINLength || len(pin) > maxPINLength {
+		return fmt.Errorf("%w: pin must have %d to %d digits", apperrors.ErrInvalidRequestPayload, minPINLength, maxPINLength)
+	}
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("%w: pin must only contain digits", apperrors.ErrInvalidRequestPayload)
+		}
+	}
+
+	// Reject 1111, 1234, 9876 and the like
+	repeated, ascending, descending := true, true, true
+	for i := 1; i < len(pin); i++ {
+		diff := int(pin[i]) - int(pin[i-1])
+		repeated = repeated && diff == 0
+		ascending = ascending && diff == 1
+		descending = descending && diff == -1
+	}
+	if repeated || ascending || descending {
+		return fmt.Errorf("%w: pin is too easy to guess", apperrors.ErrInvalidRequestPayload)
+	}
+	return nil
+}
+
+func toPOSTerminal(terminal *db.PosTerminal) *entities.POSTerminal {
+	res := &entities.POSTerminal{
+		ID:        terminal.ID,
+		StoreID:   terminal.StoreID,
+		Name:      terminal.Name,
+		CreatedAt: terminal.CreatedAt,
+	}
+	if terminal.LastUsedAt.Valid {
+		res.LastUsedAt = &terminal.LastUsedAt.Time
+	}
+	return res
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+type PreferenceService interface {
+	GetPreferences(ctx context.Context, userID uuid.UUID) (*entities.UserPreferences, error)
+	UpdatePreferences(ctx context.Context, userID uuid.UUID, req *models.UpdatePreferencesRequest) (*entities.UserPreferences, error)
+	// Unsubscribe turns off marketing emails for the user of an unsubscribe link token. The link
+	// keeps working, unsubscribing twice is not an error.
+	Unsubscribe(ctx context.Context, unsubscribeToken string) error
+}
+
+type PreferenceServiceImpl struct {
+	preferenceRepo repositories.PreferenceRepository
+	userRepo       repositories.UserRepository
+	tokenService   token.TokenService
+	log            *logrus.Logger
+}
+
+func NewPreferenceService(preferenceRepo repositories.PreferenceRepository, userRepo repositories.UserRepository, tokenService token.TokenService, log *logrus.Logger) PreferenceService {
+	return &PreferenceServiceImpl{
+		preferenceRepo: preferenceRepo,
+		userRepo:       userRepo,
+		tokenService:   tokenService,
+		log:            log,
+	}
+}
+
+func (s *PreferenceServiceImpl) GetPreferences(ctx context.Context, userID uuid.UUID) (*entities.UserPreferences, error) {
+	prefs, err := s.preferenceRepo.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to get preferences: %w", err)
+	}
+	return toUserPreferences(prefs), nil
+}
+
+// UpdatePreferences saves the fields set in req. Passwordless login can only be turned on with a
+// verified email, otherwise whoever owns a mistyped address could sign in.
+func (s *PreferenceServiceImpl) UpdatePreferences(ctx context.Context, userID uuid.UUID, req *models.UpdatePreferencesRequest) (*entities.UserPreferences, error) {
+	current, err := s.preferenceRepo.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to update preferences: %w", err)
+	}
+
+	param := &db.UpsertUserPreferencesParams{
+		UserID:            userID,
+		PasswordlessLogin: current.PasswordlessLogin,
+		MarketingEmails:   current.MarketingEmails,
+	}
+	if req.PasswordlessLogin != nil {
+		param.PasswordlessLogin = *req.PasswordlessLogin
+	}
+	if req.MarketingEmails != nil {
+		param.MarketingEmails = *req.MarketingEmails
+	}
+
+	if param.PasswordlessLogin && !current.PasswordlessLogin {
+		userDB, err := s.userRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, apperrors.ErrUserNotFound
+			}
+			return nil, fmt.Errorf("service: failed to get user: %w", err)
+		}
+		if !userDB.EmailVerifiedAt.Valid {
+			return nil, apperrors.ErrEmailNotVerified
+		}
+	}
+
+	prefs, err := s.preferenceRepo.UpsertPreferences(ctx, param)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to update preferences: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"user_id":            userID,
+		"passwordless_login": prefs.PasswordlessLogin,
+		"marketing_emails":   prefs.MarketingEmails,
+	}).Info("Preferences updated")
+
+	return toUserPreferences(prefs), nil
+}
+
+func (s *PreferenceServiceImpl) Unsubscribe(ctx context.Context, unsubscribeToken string) error {
+	if unsubscribeToken == "" {
+		return apperrors.ErrInvalidToken
+	}
+
+	claims, err := s.tokenService.ValidatePurposeToken(ctx, unsubscribeToken, token.PurposeUnsubscribe)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.preferenceRepo.GetPreferences(ctx, claims.UserID)
+	if err != nil {
+		return fmt.Errorf("service: failed to unsubscribe: %w", err)
+	}
+	if !current.MarketingEmails {
+		return nil
+	}
+
+	_, err = s.preferenceRepo.UpsertPreferences(ctx, &db.UpsertUserPreferencesParams{
+		UserID:            claims.UserID,
+		PasswordlessLogin: current.PasswordlessLogin,
+		MarketingEmails:   false,
+	})
+	if err != nil {
+		return fmt.Errorf("service: failed to unsubscribe: %w", err)
+	}
+
+	s.log.WithField("user_id", claims.UserID).Info("User unsubscribed from marketing emails")
+	return nil
+}
+
+func toUserPreferences(prefs *db.UserPreference) *entities.UserPreferences {
+	res := &entities.UserPreferences{
+		UserID:            prefs.UserID,
+		PasswordlessLogin: prefs.PasswordlessLogin,
+		MarketingEmails:   prefs.MarketingEmails,
+	}
+	if !prefs.UpdatedAt.IsZero() {
+		res.UpdatedAt = &prefs.UpdatedAt
+	}
+	return res
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+)
+
+// Values of RETENTION_DELETED_USER_MODE.
+const (
+	RetentionAnonymize = "anonymize"
+	RetentionDelete    = "delete"
+)
+
+type RetentionOptions struct {
+	DeletedUserMode string
+	// A zero period disables the step it belongs to.
+	DeletedUserAfter      time.Duration
+	OutboxAfter           time.Duration
+	CampaignDeliveryAfter time.Duration
+	AuditAfter            time.Duration
+	BatchSize             int32
+}
+
+// RetentionReport summarises one retention run. In a dry run the counts are what a real run
+// would have removed.
+type RetentionReport struct {
+	DryRun          bool
+	StartedAt       time.Time
+	FinishedAt      time.Time
+	DeletedUserMode string
+
+	Users              int64
+	Sessions           int64
+	APIKeys            int64
+	Identities         int64
+	OutboxEvents       int64
+	CampaignDeliveries int64
+	AuditEvents        int64
+}
+
+// RetentionService enforces the retention policy. Users soft-deleted longer than the policy
+// allows lose their sessions, credentials, identities and API keys and are then anonymized or
+// removed. Published outbox events, the deliveries of finished campaigns and old audit events
+// are pruned.
+type RetentionService interface {
+	// Run applies the policy, or with dryRun only counts what it would remove. On error the
+	// report covers the work done until then.
+	Run(ctx context.Context, dryRun bool) (*RetentionReport, error)
+}
+
+type RetentionServiceImpl struct {
+	userRepo     repositories.UserRepository
+	sessionRepo  repositories.SessionRepository
+	outboxRepo   repositories.OutboxRepository
+	campaignRepo repositories.CampaignRepository
+	auditRepo    repositories.AuditRepository
+	opts         RetentionOptions
+	log          *logrus.Logger
+}
+
+func NewRetentionService(
+	userRepo repositories.UserRepository,
+	sessionRepo repositories.SessionRepository,
+	outboxRepo repositories.OutboxRepository,
+	campaignRepo repositories.CampaignRepository,
+	auditRepo repositories.AuditRepository,
+	opts RetentionOptions,
+	log *logrus.Logger,
+) RetentionService {
+	return &RetentionServiceImpl{
+		userRepo:     userRepo,
+		sessionRepo:  sessionRepo,
+		outboxRepo:   outboxRepo,
+		campaignRepo: campaignRepo,
+		auditRepo:    auditRepo,
+		opts:         opts,
+		log:          log,
+	}
+}
+
+func (s *RetentionServiceImpl) Run(ctx context.Context, dryRun bool) (*RetentionReport, error) {
+	now := time.Now()
+	report := &RetentionReport{
+		DryRun:          dryRun,
+		StartedAt:       now,
+		DeletedUserMode: s.opts.DeletedUserMode,
+	}
+
+	err := s.run(ctx, now, report)
+	report.FinishedAt = time.Now()
+
+	entry := s.log.WithFields(logrus.Fields{
+		"dry_run":             report.DryRun,
+		"deleted_user_mode":   report.DeletedUserMode,
+		"users":               report.Users,
+		"sessions":            report.Sessions,
+		"api_keys":            report.APIKeys,
+		"identities":          report.Identities,
+		"outbox_events":       report.OutboxEvents,
+		"campaign_deliveries": report.CampaignDeliveries,
+		"audit_events":        report.AuditEvents,
+		"duration":            report.FinishedAt.Sub(report.StartedAt).String(),
+	})
+	if err != nil {
+		entry.WithError(err).Error("Retention run failed")
+		return report, err
+	}
+	entry.Info("Retention run finished")
+
+	return report, nil
+}
+
+func (s *RetentionServiceImpl) run(ctx context.Context, now time.Time, report *RetentionReport) error {
+	if s.opts.DeletedUserAfter > 0 {
+		if err := s.purgeDeletedUsers(ctx, now.Add(-s.opts.DeletedUserAfter), report); err != nil {
+			return err
+		}
+	}
+
+	if s.opts.OutboxAfter > 0 {
+		before := now.Add(-s.opts.OutboxAfter)
+
+		var err error
+		if report.DryRun {
+			report.OutboxEvents, err = s.outboxRepo.CountPublished(ctx, before)
+		} else {
+			report.OutboxEvents, err = s.deleteInBatches(ctx, func(ctx context.Context) (int64, error) {
+				return s.outboxRepo.DeletePublished(ctx, before, s.opts.BatchSize)
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("service: failed to prune outbox events: %w", err)
+		}
+	}
+
+	if s.opts.CampaignDeliveryAfter > 0 {
+		before := now.Add(-s.opts.CampaignDeliveryAfter)
+
+		var err error
+		if report.DryRun {
+			report.CampaignDeliveries, err = s.campaignRepo.CountFinishedDeliveries(ctx, before)
+		} else {
+			report.CampaignDeliveries, err = s.deleteInBatches(ctx, func(ctx context.Context) (int64, error) {
+				return s.campaignRepo.DeleteFinishedDeliveries(ctx, before, s.opts.BatchSize)
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("service: failed to prune campaign deliveries: %w", err)
+		}
+	}
+
+	if s.opts.AuditAfter > 0 {
+		before := now.Add(-s.opts.AuditAfter)
+
+		var err error
+		if report.DryRun {
+			report.AuditEvents, err = s.auditRepo.CountBefore(ctx, before)
+		} else {
+			report.AuditEvents, err = s.deleteInBatches(ctx, func(ctx context.Context) (int64, error) {
+				return s.auditRepo.DeleteBefore(ctx, before, s.opts.BatchSize)
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("service: failed to prune audit events: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// purgeDeletedUsers walks the expired users by id. Users that were purged drop out of the
+// listing, but walking by id keeps a dry run, which changes nothing, from looping forever.
+func (s *RetentionServiceImpl) purgeDeletedUsers(ctx context.Context, before time.Time, report *RetentionReport) error {
+	if report.DryRun {
+		counts, err := s.userRepo.CountExpiredDeletedUsers(ctx, before)
+		if err != nil {
+			return fmt.Errorf("service: failed to count expired users: %w", err)
+		}
+		report.Users = counts.Users
+		report.APIKeys = counts.ApiKeys
+		report.Identities = counts.Identities
+	}
+
+	afterID := uuid.Nil
+	for {
+		ids, err := s.userRepo.ListExpiredDeletedUsers(ctx, before, afterID, s.opts.BatchSize)
+		if err != nil {
+			return fmt.Errorf("service: failed to list expired users: %w", err)
+		}
+
+		for _, id := range ids {
+			if err := s.purgeDeletedUser(ctx, id, report); err != nil {
+				return err
+			}
+		}
+
+		if len(ids) < int(s.opts.BatchSize) {
+			return nil
+		}
+		afterID = ids[len(ids)-1]
+	}
+}
+
+func (s *RetentionServiceImpl) purgeDeletedUser(ctx context.Context, id uuid.UUID, report *RetentionReport) error {
+	sessions, err := s.sessionRepo.ListSessions(ctx, id)
+	if err != nil {
+		return fmt.Errorf("service: failed to list sessions of user %s: %w", id, err)
+	}
+	report.Sessions += int64(len(sessions))
+
+	if report.DryRun {
+		return nil
+	}
+
+	if err := s.sessionRepo.DeleteAllSessions(ctx, id); err != nil {
+		return fmt.Errorf("service: failed to revoke sessions of user %s: %w", id, err)
+	}
+
+	purged, err := s.userRepo.PurgeDeletedUser(ctx, id, s.opts.DeletedUserMode == RetentionDelete)
+	if err != nil {
+		return fmt.Errorf("service: failed to purge user %s: %w", id, err)
+	}
+	report.Users++
+	report.APIKeys += purged.ApiKeys
+	report.Identities += purged.Identities
+
+	return nil
+}
+
+// deleteInBatches calls deleteBatch until it removes less than a full batch and returns the
+// total.
+func (s *RetentionServiceImpl) deleteInBatches(ctx context.Context, deleteBatch func(ctx context.Context) (int64, error)) (int64, error) {
+	var total int64
+	for {
+		n, err := deleteBatch(ctx)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < int64(s.opts.BatchSize) {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/audit"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+type RoleService interface {
+	ListRoles(ctx context.Context) ([]entities.Role, error)
+	GetUserRoles(ctx context.Context, userID uuid.UUID) ([]entities.Role, error)
+	GrantRole(ctx context.Context, userID uuid.UUID, roleName string, grantedBy uuid.UUID) error
+	RevokeRole(ctx context.Context, userID uuid.UUID, roleName string, revokedBy uuid.UUID) error
+}
+
+type RoleServiceImpl struct {
+	roleRepo     repositories.RoleRepository
+	userRepo     repositories.UserRepository
+	tokenService token.TokenService
+	auditor      audit.Recorder
+	log          *logrus.Logger
+}
+
+func NewRoleService(
+	roleRepo repositories.RoleRepository,
+	userRepo repositories.UserRepository,
+	tokenService token.TokenService,
+	auditor audit.Recorder,
+	log *logrus.Logger,
+) RoleService {
+	return &RoleServiceImpl{
+		roleRepo:     roleRepo,
+		userRepo:     userRepo,
+		tokenService: tokenService,
+		auditor:      auditor,
+		log:          log,
+	}
+}
+
+// ListRoles returns every role together with the permissions it grants.
+func (s *RoleServiceImpl) ListRoles(ctx context.Context) ([]entities.Role, error) {
+	roles, err := s.roleRepo.ListRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list roles: %w", err)
+	}
+
+	grants, err := s.roleRepo.ListRolePermissions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list roles: %w", err)
+	}
+
+	permissions := make(map[string][]string)
+	for _, grant := range grants {
+		permissions[grant.RoleName] = append(permissions[grant.RoleName], grant.PermissionName)
+	}
+
+	res := make([]entities.Role, 0, len(roles))
+	for _, role := range roles {
+		res = append(res, toDomainRole(role, permissions[role.Name]))
+	}
+	return res, nil
+}
+
+func (s *RoleServiceImpl) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]entities.Role, error) {
+	if err := s.ensureUserExists(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.roleRepo.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to get user roles: %w", err)
+	}
+
+	res := make([]entities.Role, 0, len(roles))
+	for _, role := range roles {
+		res = append(res, toDomainRole(role, nil))
+	}
+	return res, nil
+}
+
+// GrantRole adds a role to the user. The new permissions are part of the next issued token.
+func (s *RoleServiceImpl) GrantRole(ctx context.Context, userID uuid.UUID, roleName string, grantedBy uuid.UUID) error {
+	if err := s.ensureUserExists(ctx, userID); err != nil {
+		return err
+	}
+
+	role, err := s.roleRepo.GetRoleByName(ctx, roleName)
+	if err != nil {
+		return err
+	}
+
+	granted, err := s.roleRepo.GrantUserRole(ctx, &db.GrantUserRoleParams{
+		UserID:    userID,
+		RoleID:    role.ID,
+		GrantedBy: uuid.NullUUID{UUID: grantedBy, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("service: failed to grant role: %w", err)
+	}
+	if !granted {
+		return nil
+	}
+
+	if err := s.roleRepo.SyncUserPrimaryRole(ctx, userID); err != nil {
+		return fmt.Errorf("service: failed to grant role: %w", err)
+	}
+
+	s.auditor.Record(ctx, audit.Event{
+		Action:       entities.AuditRoleGranted,
+		ActorID:      grantedBy,
+		TargetUserID: userID,
+		Metadata:     map[string]string{"role": roleName},
+	})
+
+	s.log.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"role":       roleName,
+		"granted_by": grantedBy,
+	}).Info("Role granted")
+	return nil
+}
+
+// RevokeRole removes a role from the user and revokes their tokens, so the permissions of the
+// role can not be used until the access token expires.
+func (s *RoleServiceImpl) RevokeRole(ctx context.Context, userID uuid.UUID, roleName string, revokedBy uuid.UUID) error {
+	if userID == revokedBy {
+		// Keeps an administrator from locking themselves out of role management
+		return fmt.Errorf("%w: you can not revoke your own roles", apperrors.ErrForbidden)
+	}
+
+	if err := s.ensureUserExists(ctx, userID); err != nil {
+		return err
+	}
+
+	role, err := s.roleRepo.GetRoleByName(ctx, roleName)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := s.roleRepo.RevokeUserRole(ctx, &db.RevokeUserRoleParams{UserID: userID, RoleID: role.ID})
+	if err != nil {
+		return fmt.Errorf("service: failed to revoke role: %w", err)
+	}
+	if !revoked {
+		return fmt.Errorf("%w: user does not have role %q", apperrors.ErrNotFound, roleName)
+	}
+
+	if err := s.roleRepo.SyncUserPrimaryRole(ctx, userID); err != nil {
+		return fmt.Errorf("service: failed to revoke role: %w", err)
+	}
+
+	s.auditor.Record(ctx, audit.Event{
+		Action:       entities.AuditRoleRevoked,
+		ActorID:      revokedBy,
+		TargetUserID: userID,
+		Metadata:     map[string]string{"role": roleName},
+	})
+
+	if err := s.tokenService.RevokeAllUserTokens(ctx, userID); err != nil {
+		return fmt.Errorf("service: failed to revoke tokens: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"role":       roleName,
+		"revoked_by": revokedBy,
+	}).Warn("Role revoked")
+	return nil
+}
+
+func (s *RoleServiceImpl) ensureUserExists(ctx context.Context, userID uuid.UUID) error {
+	if _, err := s.userRepo.GetUserByID(ctx, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", apperrors.ErrNotFound, err)
+		}
+		return fmt.Errorf("service: failed to get user: %w", err)
+	}
+	return nil
+}
+
+func toDomainRole(role db.Role, permissions []string) entities.Role {
+	return entities.Role{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: permissions,
+	}
+}
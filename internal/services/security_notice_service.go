@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/notifier"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+)
+
+// SecurityNoticeService tells users about sensitive changes to their account, so an attacker
+// who took over the account cannot make them silently.
+type SecurityNoticeService interface {
+	// EmailChanged notifies the previous address, the only one the owner may still control.
+	EmailChanged(ctx context.Context, name string, previousEmail string, newEmail string, changedAt time.Time) error
+	PasswordChanged(ctx context.Context, userID uuid.UUID, changedAt time.Time) error
+}
+
+type SecurityNoticeServiceImpl struct {
+	userRepo repositories.UserRepository
+	notifier notifier.Notifier
+	log      *logrus.Logger
+}
+
+func NewSecurityNoticeService(userRepo repositories.UserRepository, notifier notifier.Notifier, log *logrus.Logger) SecurityNoticeService {
+	return &SecurityNoticeServiceImpl{
+		userRepo: userRepo,
+		notifier: notifier,
+		log:      log,
+	}
+}
+
+func (s *SecurityNoticeServiceImpl) EmailChanged(ctx context.Context, name string, previousEmail string, newEmail string, changedAt time.Time) error {
+	err := s.notifier.Send(ctx, notifier.Message{
+		To:      previousEmail,
+		Subject: "Your Shopeezy email address was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe email address of your Shopeezy account was changed to %s on %s.\n\nIf you did not make this change, contact our support right away.",
+			name, newEmail, changedAt.Format(time.RFC1123),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("service: failed to send email change notice: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SecurityNoticeServiceImpl) PasswordChanged(ctx context.Context, userID uuid.UUID, changedAt time.Time) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Deleted since, there is nobody left to warn
+			s.log.WithField("user_id", userID).Debug("Skipping password change notice for a deleted user")
+			return nil
+		}
+		return fmt.Errorf("service: failed to get user: %w", err)
+	}
+
+	err = s.notifier.Send(ctx, notifier.Message{
+		To:      user.Email,
+		Subject: "Your Shopeezy password was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe password of your Shopeezy account was changed on %s.\n\nIf you did not make this change, reset your password and contact our support right away.",
+			user.Name, changedAt.Format(time.RFC1123),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("service: failed to send password change notice: %w", err)
+	}
+
+	return nil
+}
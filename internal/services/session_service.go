@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+type SessionService interface {
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error)
+	RevokeSession(ctx context.Context, userID uuid.UUID, jti string) error
+	RevokeAllSessions(ctx context.Context, userID uuid.UUID) error
+}
+
+type SessionServiceImpl struct {
+	sessionRepo  repositories.SessionRepository
+	userRepo     repositories.UserRepository
+	tokenService token.TokenService
+	log          *logrus.Logger
+}
+
+func NewSessionService(
+	sessionRepo repositories.SessionRepository,
+	userRepo repositories.UserRepository,
+	tokenService token.TokenService,
+	log *logrus.Logger,
+) SessionService {
+	return &SessionServiceImpl{
+		sessionRepo:  sessionRepo,
+		userRepo:     userRepo,
+		tokenService: tokenService,
+		log:          log,
+	}
+}
+
+func (s *SessionServiceImpl) ListSessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	sessions, err := s.sessionRepo.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list sessions: %w", err)
+	}
+
+	// Most recently used first
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+func (s *SessionServiceImpl) RevokeSession(ctx context.Context, userID uuid.UUID, jti string) error {
+	session, err := s.sessionRepo.GetSession(ctx, userID, jti)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrSessionNotFound) {
+			return fmt.Errorf("%w: %s", apperrors.ErrNotFound, err)
+		}
+		return fmt.Errorf("service: failed to get session: %w", err)
+	}
+
+	familyID, err := uuid.Parse(session.SessionID)
+	if err != nil {
+		return fmt.Errorf("service: session %s has an invalid session id: %w", jti, err)
+	}
+
+	if err := s.tokenService.RevokeRefreshFamily(ctx, userID, familyID); err != nil {
+		s.log.WithError(err).WithField("jti", jti).Error("Failed to revoke session")
+		return apperrors.ErrFailedToRevokeToken
+	}
+	return nil
+}
+
+func (s *SessionServiceImpl) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	if _, err := s.userRepo.GetUserByID(ctx, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", apperrors.ErrNotFound, err)
+		}
+		return fmt.Errorf("service: failed to get user: %w", err)
+	}
+
+	if err := s.tokenService.RevokeAllUserTokens(ctx, userID); err != nil {
+		s.log.WithError(err).WithField("user_id", userID).Error("Failed to revoke all sessions")
+		return apperrors.ErrFailedToRevokeToken
+	}
+
+	s.log.WithField("user_id", userID).Info("All sessions of user revoked")
+	return nil
+}
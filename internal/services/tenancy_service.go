@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+// StoreActor is the user changing store memberships. CanManageTenants is set for holders of the
+// tenants:manage permission, who may manage every store.
+type StoreActor struct {
+	UserID           uuid.UUID
+	CanManageTenants bool
+}
+
+type TenancyService interface {
+	CreateOrganization(ctx context.Context, name string) (*entities.Organization, error)
+	CreateStore(ctx context.Context, organizationID uuid.UUID, name string) (*entities.Store, error)
+	ListMemberships(ctx context.Context, userID uuid.UUID) ([]entities.StoreMembership, error)
+	// IsOrganizationMember reports whether the user is a member of any store of the organization.
+	IsOrganizationMember(ctx context.Context, userID uuid.UUID, organizationID uuid.UUID) (bool, error)
+	ListStoreMembers(ctx context.Context, storeID uuid.UUID, actor StoreActor) ([]entities.StoreMembership, error)
+	SetMembership(ctx context.Context, storeID uuid.UUID, userID uuid.UUID, role string, actor StoreActor) error
+	RemoveMembership(ctx context.Context, storeID uuid.UUID, userID uuid.UUID, actor StoreActor) error
+	// SwitchStore ends the current session and starts a new one for the store. A zero storeID
+	// starts a session without an active store.
+	SwitchStore(ctx context.Context, userID uuid.UUID, sessionID string, storeID uuid.NullUUID, opts token.IssueOptions) (*token.TokenPair, error)
+}
+
+type TenancyServiceImpl struct {
+	tenancyRepo  repositories.TenancyRepository
+	userRepo     repositories.UserRepository
+	tokenService token.TokenService
+	log          *logrus.Logger
+}
+
+func NewTenancyService(
+	tenancyRepo repositories.TenancyRepository,
+	userRepo repositories.UserRepository,
+	tokenService token.TokenService,
+	log *logrus.Logger,
+) TenancyService {
+	return &TenancyServiceImpl{
+		tenancyRepo:  tenancyRepo,
+		userRepo:     userRepo,
+		tokenService: tokenService,
+		log:          log,
+	}
+}
+
+func (s *TenancyServiceImpl) CreateOrganization(ctx context.Context, name string) (*entities.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", apperrors.ErrInvalidRequestPayload)
+	}
+
+	org, err := s.tenancyRepo.CreateOrganization(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to create organization: %w", err)
+	}
+
+	return &entities.Organization{ID: org.ID, Name: org.Name, CreatedAt: org.CreatedAt}, nil
+}
+
+func (s *TenancyServiceImpl) CreateStore(ctx context.Context, organizationID uuid.UUID, name string) (*entities.Store, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", apperrors.ErrInvalidRequestPayload)
+	}
+
+	if _, err := s.tenancyRepo.GetOrganization(ctx, organizationID); err != nil {
+		return nil, err
+	}
+
+	store, err := s.tenancyRepo.CreateStore(ctx, &db.CreateStoreParams{OrganizationID: organizationID, Name: name})
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to create store: %w", err)
+	}
+
+	return &entities.Store{
+		ID:             store.ID,
+		OrganizationID: store.OrganizationID,
+		Name:           store.Name,
+		CreatedAt:      store.CreatedAt,
+	}, nil
+}
+
+func (s *TenancyServiceImpl) ListMemberships(ctx context.Context, userID uuid.UUID) ([]entities.StoreMembership, error) {
+	rows, err := s.tenancyRepo.ListUserMemberships(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list memberships: %w", err)
+	}
+
+	memberships := make([]entities.StoreMembership, 0, len(rows))
+	for _, row := range rows {
+		memberships = append(memberships, entities.StoreMembership{
+			StoreID:          row.StoreID,
+			StoreName:        row.StoreName,
+			OrganizationID:   row.OrganizationID,
+			OrganizationName: row.OrganizationName,
+			UserID:           userID,
+			Role:             row.Role,
+			CreatedAt:        row.CreatedAt,
+		})
+	}
+	return memberships, nil
+}
+
+func (s *TenancyServiceImpl) IsOrganizationMember(ctx context.Context, userID uuid.UUID, organizationID uuid.UUID) (bool, error) {
+	rows, err := s.tenancyRepo.ListUserMemberships(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("service: failed to list memberships: %w", err)
+	}
+
+	for _, row := range rows {
+		if row.OrganizationID == organizationID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ListStoreMembers is open to every member of the store.
+func (s *TenancyServiceImpl) ListStoreMembers(ctx context.Context, storeID uuid.UUID, actor StoreActor) ([]entities.StoreMembership, error) {
+	if !actor.CanManageTenants {
+		if _, err := s.tenancyRepo.GetStoreMembership(ctx, &db.GetStoreMembershipParams{UserID: actor.UserID, StoreID: storeID}); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := s.tenancyRepo.ListStoreMembers(ctx, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list store members: %w", err)
+	}
+
+	members := make([]entities.StoreMembership, 0, len(rows))
+	for _, row := range rows {
+		members = append(members, entities.StoreMembership{
+			StoreID:   storeID,
+			UserID:    row.UserID,
+			UserName:  row.Name,
+			Username:  row.Username,
+			Role:      row.Role,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+	return members, nil
+}
+
+// SetMembership adds a user to a store or changes their role. Owners manage every role of
+// their store, managers only cashiers.
+func (s *TenancyServiceImpl) SetMembership(ctx context.Context, storeID uuid.UUID, userID uuid.UUID, role string, actor StoreActor) error {
+	switch role {
+	case entities.StoreRoleOwner, entities.StoreRoleManager, entities.StoreRoleCashier:
+	default:
+		return fmt.Errorf("%w: role must be owner, manager or cashier", apperrors.ErrInvalidRequestPayload)
+	}
+
+	if _, err := s.tenancyRepo.GetStore(ctx, storeID); err != nil {
+		return err
+	}
+
+	current, err := s.currentMembership(ctx, storeID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.authorizeMembershipChange(ctx, storeID, actor, role, current); err != nil {
+		return err
+	}
+
+	if _, err := s.userRepo.GetUserByID(ctx, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", apperrors.ErrNotFound, err)
+		}
+		return fmt.Errorf("service: failed to get user: %w", err)
+	}
+
+	_, err = s.tenancyRepo.UpsertStoreMembership(ctx, &db.UpsertStoreMembershipParams{
+		UserID:  userID,
+		StoreID: storeID,
+		Role:    role,
+	})
+	if err != nil {
+		return fmt.Errorf("service: failed to set membership: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"store_id": storeID,
+		"user_id":  userID,
+		"role":     role,
+		"actor_id": actor.UserID,
+	}).Info("Store membership set")
+	return nil
+}
+
+// RemoveMembership removes a user from a store. The role of the user in the store's sessions
+// ends with their next refresh.
+func (s *TenancyServiceImpl) RemoveMembership(ctx context.Context, storeID uuid.UUID, userID uuid.UUID, actor StoreActor) error {
+	current, err := s.currentMembership(ctx, storeID, userID)
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		return apperrors.ErrNotStoreMember
+	}
+	if err := s.authorizeMembershipChange(ctx, storeID, actor, current, current); err != nil {
+		return err
+	}
+
+	removed, err := s.tenancyRepo.DeleteStoreMembership(ctx, &db.DeleteStoreMembershipParams{UserID: userID, StoreID: storeID})
+	if err != nil {
+		return fmt.Errorf("service: failed to remove membership: %w", err)
+	}
+	if !removed {
+		return apperrors.ErrNotStoreMember
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"store_id": storeID,
+		"user_id":  userID,
+		"actor_id": actor.UserID,
+	}).Info("Store membership removed")
+	return nil
+}
+
+func (s *TenancyServiceImpl) SwitchStore(ctx context.Context, userID uuid.UUID, sessionID string, storeID uuid.NullUUID, opts token.IssueOptions) (*token.TokenPair, error) {
+	if storeID.Valid {
+		if _, err := s.tenancyRepo.GetStoreMembership(ctx, &db.GetStoreMembershipParams{UserID: userID, StoreID: storeID.UUID}); err != nil {
+			return nil, err
+		}
+	}
+
+	userDB, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to get user: %w", err)
+	}
+
+	// Tokens of the previous store must not stay usable next to the new ones
+	if familyID, err := uuid.Parse(sessionID); err == nil {
+		if err := s.tokenService.RevokeRefreshFamily(ctx, userID, familyID); err != nil {
+			return nil, fmt.Errorf("service: failed to end previous session: %w", err)
+		}
+	}
+
+	opts.StoreID = storeID
+	pair, err := s.tokenService.GenerateTokenPair(ctx, toDomainUser(userDB), opts)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to switch store: %w", err)
+	}
+
+	return pair, nil
+}
+
+// currentMembership returns the role of the user in the store, or "" when they are no member.
+func (s *TenancyServiceImpl) currentMembership(ctx context.Context, storeID uuid.UUID, userID uuid.UUID) (string, error) {
+	membership, err := s.tenancyRepo.GetStoreMembership(ctx, &db.GetStoreMembershipParams{UserID: userID, StoreID: storeID})
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotStoreMember) {
+			return "", nil
+		}
+		return "", fmt.Errorf("service: failed to get membership: %w", err)
+	}
+	return membership.Role, nil
+}
+
+// authorizeMembershipChange checks that the actor may move a member from currentRole to newRole.
+func (s *TenancyServiceImpl) authorizeMembershipChange(ctx context.Context, storeID uuid.UUID, actor StoreActor, newRole string, currentRole string) error {
+	if actor.CanManageTenants {
+		return nil
+	}
+
+	actorRole, err := s.currentMembership(ctx, storeID, actor.UserID)
+	if err != nil {
+		return err
+	}
+
+	switch actorRole {
+	case entities.StoreRoleOwner:
+		return nil
+	case entities.StoreRoleManager:
+		if newRole == entities.StoreRoleCashier && (currentRole == "" || currentRole == entities.StoreRoleCashier) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: not allowed to manage this membership", apperrors.ErrForbidden)
+}
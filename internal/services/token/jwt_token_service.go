@@ -2,79 +2,305 @@ package token
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/audit"
+)
+
+const (
+	tokenIssuer         = "shopeezy-account-service"
+	accessTokenAudience = "shopeezy-cashier-app"
+	// userInfoAudience is the audience of the access tokens of third-party OpenID Connect
+	// clients. ValidateToken refuses them, only the UserInfo endpoint accepts them.
+	userInfoAudience = "shopeezy-account-service:userinfo"
+
+	// refreshTokenBytes is the amount of random bytes in an opaque refresh token.
+	refreshTokenBytes = 32
 )
 
 // Custom JWT Claims (must be consistent across the application)
 type JWTClaims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Username string    `json:"username"`
-	Role     string    `json:"role"`
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`          // highest priority role, kept for clients that read a single role
+	SessionID string    `json:"sid,omitempty"` // refresh token family the token was issued for
+	// Roles and Permissions are loaded when the token is issued, grants take effect on the next refresh.
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// Active store (tenant) of the session and the role of the user in it, empty without a store.
+	StoreID        string `json:"store_id,omitempty"`
+	StoreRole      string `json:"store_role,omitempty"`
+	OrganizationID string `json:"org_id,omitempty"`
+	// AMR are the authentication methods of the session, Scope limits tokens that are not meant
+	// for the account API (space separated, empty for regular tokens).
+	AMR   []string `json:"amr,omitempty"`
+	Scope string   `json:"scope,omitempty"`
+	// EmailVerified is false for accounts that did not confirm their email yet (see EMAIL_VERIFICATION_MODE).
+	EmailVerified bool `json:"email_verified"`
 	jwt.RegisteredClaims
 }
 
 type jwtTokenService struct {
-	jwtSecret        string
+	keys             *KeySet
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
 	jwtBlacklistRepo repositories.JWTBlacklistRepository
+	refreshTokenRepo repositories.RefreshTokenRepository
+	sessionRepo      repositories.SessionRepository
+	userRepo         repositories.UserRepository
+	roleRepo         repositories.RoleRepository
+	tenancyRepo      repositories.TenancyRepository
+	apiKeyRepo       repositories.APIKeyRepository
+	auditor          audit.Recorder
+	log              *logrus.Logger
 }
 
 // NewJWTTokenService creates a new JWTTokenService instance.
-func NewJWTTokenService(jwtSecret string, jwtBlacklistRepo repositories.JWTBlacklistRepository) TokenService {
+func NewJWTTokenService(
+	keys *KeySet,
+	accessTokenTTL time.Duration,
+	refreshTokenTTL time.Duration,
+	jwtBlacklistRepo repositories.JWTBlacklistRepository,
+	refreshTokenRepo repositories.RefreshTokenRepository,
+	sessionRepo repositories.SessionRepository,
+	userRepo repositories.UserRepository,
+	roleRepo repositories.RoleRepository,
+	tenancyRepo repositories.TenancyRepository,
+	apiKeyRepo repositories.APIKeyRepository,
+	auditor audit.Recorder,
+	log *logrus.Logger,
+) TokenService {
 	return &jwtTokenService{
-		jwtSecret:        jwtSecret,
+		keys:             keys,
+		accessTokenTTL:   accessTokenTTL,
+		refreshTokenTTL:  refreshTokenTTL,
 		jwtBlacklistRepo: jwtBlacklistRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		sessionRepo:      sessionRepo,
+		userRepo:         userRepo,
+		roleRepo:         roleRepo,
+		tenancyRepo:      tenancyRepo,
+		apiKeyRepo:       apiKeyRepo,
+		auditor:          auditor,
+		log:              log,
 	}
 }
 
 func (s *jwtTokenService) GenerateToken(ctx context.Context, user *entities.User) (string, error) {
-	claims := &JWTClaims{
-		UserID:   user.ID,
-		Username: user.Username,
-		Role:     user.Role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			ID:        uuid.New().String(), // Unique JTI (JWT ID) for blacklisting
-			Issuer:    "shopeezy-account-service",
-			Subject:   user.Username,
-			Audience:  jwt.ClaimStrings{"shopeezy-cashier-app"}, // Audience if needed
-		},
+	signedToken, _, err := s.generateAccessToken(ctx, user, "", nil, nil)
+	return signedToken, err
+}
+
+func (s *jwtTokenService) GenerateTokenPair(ctx context.Context, user *entities.User, opts IssueOptions) (*TokenPair, error) {
+	return s.issueTokenPair(ctx, user, uuid.New(), uuid.NullUUID{}, opts)
+}
+
+func (s *jwtTokenService) GenerateScopedToken(ctx context.Context, user *entities.User, scopes []string, ttl time.Duration, opts IssueOptions) (string, time.Time, error) {
+	return s.generateScopedToken(ctx, user, scopes, ttl, opts, accessTokenAudience)
+}
+
+func (s *jwtTokenService) GenerateUserInfoToken(ctx context.Context, user *entities.User, scopes []string, ttl time.Duration, opts IssueOptions) (string, time.Time, error) {
+	return s.generateScopedToken(ctx, user, scopes, ttl, opts, userInfoAudience)
+}
+
+func (s *jwtTokenService) generateScopedToken(ctx context.Context, user *entities.User, scopes []string, ttl time.Duration, opts IssueOptions, audience string) (string, time.Time, error) {
+	membership, err := s.storeMembership(ctx, user.ID, opts.StoreID)
+	if err != nil {
+		return "", time.Time{}, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(s.jwtSecret))
+	issuedAt, err := s.issuedAt(ctx, user.ID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// A session of its own, so the token shows up in the session list and can be revoked there
+	claims := s.newAccessClaims(user, issuedAt, uuid.New().String(), membership, opts.AMR, ttl)
+	// The legacy role claim would grant admins their full rights at the terminal
+	claims.Role = ""
+	claims.Scope = strings.Join(scopes, " ")
+	claims.Audience = jwt.ClaimStrings{audience}
+
+	signedToken, err := s.keys.sign(claims)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign JWT: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to sign JWT: %w", err)
 	}
-	return signedToken, nil
+
+	if err := s.registerSession(ctx, claims, claims.ExpiresAt.Time, opts); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to register session: %w", err)
+	}
+
+	return signedToken, claims.ExpiresAt.Time, nil
 }
 
-func (s *jwtTokenService) ValidateToken(ctx context.Context, tokenString string) (isValid bool, userID uuid.UUID, username string, role string, errorMessage string, err error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+func (s *jwtTokenService) Refresh(ctx context.Context, refreshToken string, opts IssueOptions) (*TokenPair, error) {
+	stored, err := s.refreshTokenRepo.GetRefreshTokenByHash(ctx, helpers.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrTokenNotFound) {
+			return nil, apperrors.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to look up refresh token: %w", err)
+	}
+
+	if stored.RevokedAt.Valid {
+		return nil, apperrors.ErrInvalidToken
+	}
+
+	if stored.UsedAt.Valid {
+		return nil, s.handleRefreshTokenReuse(ctx, stored)
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, apperrors.ErrExpiredToken
+	}
+
+	// A token of one client can not be redeemed by another one, nor on /token/refresh
+	if stored.ClientID != opts.ClientID {
+		s.log.WithFields(logrus.Fields{"user_id": stored.UserID, "client_id": opts.ClientID}).Warn("Refresh token presented by another client")
+		return nil, apperrors.ErrInvalidToken
+	}
+
+	// Consume the token. Losing this race means someone else redeemed it concurrently,
+	// which is treated exactly like a replay.
+	if _, err := s.refreshTokenRepo.MarkRefreshTokenUsed(ctx, stored.ID); err != nil {
+		if errors.Is(err, apperrors.ErrTokenNotFound) {
+			return nil, s.handleRefreshTokenReuse(ctx, stored)
+		}
+		return nil, fmt.Errorf("failed to consume refresh token: %w", err)
+	}
+
+	// Reload the user so role changes and deletions take effect on refresh.
+	userDB, err := s.userRepo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		s.log.WithError(err).WithField("user_id", stored.UserID).Warn("Refresh rejected, user could not be loaded")
+		return nil, apperrors.ErrInvalidToken
+	}
+
+	user := &entities.User{
+		ID:       userDB.ID,
+		Name:     userDB.Name,
+		Username: userDB.Username,
+		Email:    userDB.Email,
+		Role:     userDB.Role,
+	}
+	if userDB.EmailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &userDB.EmailVerifiedAt.Time
+	}
+
+	opts.AMR = stored.Amr
+
+	// The session keeps its active store as long as the user is still a member
+	opts.StoreID = stored.StoreID
+	if stored.StoreID.Valid {
+		if _, err := s.storeMembership(ctx, user.ID, opts.StoreID); err != nil {
+			if !errors.Is(err, apperrors.ErrNotStoreMember) {
+				return nil, err
+			}
+			s.log.WithFields(logrus.Fields{"user_id": user.ID, "store_id": stored.StoreID.UUID}).Info("User is no longer a member of the store, refreshing without store")
+			opts.StoreID = uuid.NullUUID{}
 		}
-		return []byte(s.jwtSecret), nil
+	}
+
+	return s.issueTokenPair(ctx, user, stored.FamilyID, uuid.NullUUID{UUID: stored.ID, Valid: true}, opts)
+}
+
+func (s *jwtTokenService) RevokeRefreshFamily(ctx context.Context, userID uuid.UUID, familyID uuid.UUID) error {
+	if err := s.refreshTokenRepo.RevokeRefreshTokenFamily(ctx, familyID); err != nil {
+		return err
+	}
+
+	// Access tokens of the family are still valid until they expire, so block them by sid as well.
+	if err := s.jwtBlacklistRepo.BlacklistSession(ctx, familyID.String(), s.accessTokenTTL); err != nil {
+		return err
+	}
+
+	s.auditor.Record(ctx, audit.Event{
+		Action:       entities.AuditTokenRevoked,
+		TargetUserID: userID,
+		Metadata:     map[string]string{"scope": "session", "session_id": familyID.String()},
+	})
+
+	if session := s.findSession(ctx, userID, familyID.String()); session != nil {
+		return s.sessionRepo.DeleteSession(ctx, userID, session.JTI)
+	}
+	return nil
+}
+
+func (s *jwtTokenService) RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) error {
+	validAfter := time.Now()
+
+	// Tokens issued in the second of the previous cut-off were stamped with the next second (see
+	// issuedAt), the new cut-off has to lie past them
+	previous, err := s.jwtBlacklistRepo.GetTokensValidAfter(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to read tokens_valid_after: %w", err)
+	}
+	if stamped := nextSecond(previous); !previous.IsZero() && !validAfter.After(stamped) {
+		validAfter = stamped.Add(time.Millisecond)
+	}
+
+	if err := s.jwtBlacklistRepo.SetTokensValidAfter(ctx, userID, validAfter, s.accessTokenTTL); err != nil {
+		return fmt.Errorf("failed to set tokens_valid_after: %w", err)
+	}
+
+	if err := s.refreshTokenRepo.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	s.auditor.Record(ctx, audit.Event{
+		Action:       entities.AuditTokenRevoked,
+		TargetUserID: userID,
+		Metadata:     map[string]string{"scope": "all"},
 	})
 
+	return s.sessionRepo.DeleteAllSessions(ctx, userID)
+}
+
+func (s *jwtTokenService) ValidateToken(ctx context.Context, tokenString string) (isValid bool, claims *JWTClaims, errorMessage string, err error) {
+	if strings.HasPrefix(tokenString, APIKeyPrefix) {
+		return s.validateAPIKey(ctx, tokenString)
+	}
+	return s.validateAccessToken(ctx, tokenString, accessTokenAudience)
+}
+
+// ValidateUserInfoToken accepts the tokens of GenerateUserInfoToken besides regular access
+// tokens. API keys are refused.
+func (s *jwtTokenService) ValidateUserInfoToken(ctx context.Context, tokenString string) (isValid bool, claims *JWTClaims, errorMessage string, err error) {
+	if strings.HasPrefix(tokenString, APIKeyPrefix) {
+		return false, nil, "Invalid token", nil
+	}
+	return s.validateAccessToken(ctx, tokenString, accessTokenAudience, userInfoAudience)
+}
+
+// validateAccessToken accepts JWTs issued for any of the audiences.
+func (s *jwtTokenService) validateAccessToken(ctx context.Context, tokenString string, audiences ...string) (isValid bool, claims *JWTClaims, errorMessage string, err error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, s.keys.keyFunc,
+		jwt.WithValidMethods(s.keys.validMethods()),
+		jwt.WithAudience(audiences...),
+	)
+
 	if err != nil {
-		log.Printf("Failed to parse or validate JWT: %v", err)
-		return false, uuid.Nil, "", "", "Token invalid or expired", err
+		s.log.WithError(err).Debug("Failed to parse or validate JWT")
+		return false, nil, "Token invalid or expired", err
 	}
 
 	claims, ok := token.Claims.(*JWTClaims)
 	if !ok || !token.Valid {
-		log.Printf("Invalid token or claims mismatch: %v", err)
-		return false, uuid.Nil, "", "", "Invalid token", nil // No Go error, just invalid token
+		s.log.Debug("Invalid token or claims mismatch")
+		return false, nil, "Invalid token", nil // No Go error, just invalid token
 	}
 
 	// Check Redis Blacklist (if JTI exists)
@@ -82,20 +308,251 @@ func (s *jwtTokenService) ValidateToken(ctx context.Context, tokenString string)
 	if jti != "" {   // JTI might be empty if not set during token creation
 		isBlacklisted, err := s.jwtBlacklistRepo.IsBlacklisted(ctx, jti)
 		if err != nil {
-			log.Printf("Error checking JWT blacklist for JTI %s: %v", jti, err)
-			return false, uuid.Nil, "", "", "Internal server error during token validation", err
+			s.log.WithError(err).WithField("jti", jti).Error("Failed to check JWT blacklist")
+			return false, nil, "Internal server error during token validation", err
+		}
+		if isBlacklisted {
+			s.log.WithField("jti", jti).Debug("Token is blacklisted")
+			return false, nil, "Token has been revoked", nil // No Go error, just invalid token
+		}
+	}
+
+	// Check whether the whole session (refresh token family) was revoked
+	if claims.SessionID != "" {
+		isBlacklisted, err := s.jwtBlacklistRepo.IsSessionBlacklisted(ctx, claims.SessionID)
+		if err != nil {
+			s.log.WithError(err).WithField("session_id", claims.SessionID).Error("Failed to check session blacklist")
+			return false, nil, "Internal server error during token validation", err
 		}
 		if isBlacklisted {
-			log.Printf("Token with JTI %s is blacklisted.", jti)
-			return false, uuid.Nil, "", "", "Token has been revoked", nil // No Go error, just invalid token
+			s.log.WithFields(logrus.Fields{"session_id": claims.SessionID, "jti": jti}).Debug("Session of token is revoked")
+			return false, nil, "Token has been revoked", nil
 		}
 	}
 
+	// Reject tokens issued before the user's last "revoke all sessions"
+	validAfter, err := s.jwtBlacklistRepo.GetTokensValidAfter(ctx, claims.UserID)
+	if err != nil {
+		s.log.WithError(err).WithField("user_id", claims.UserID).Error("Failed to check tokens_valid_after")
+		return false, nil, "Internal server error during token validation", err
+	}
+	if !validAfter.IsZero() && (claims.IssuedAt == nil || claims.IssuedAt.Before(validAfter)) {
+		s.log.WithFields(logrus.Fields{"jti": jti, "user_id": claims.UserID}).Debug("Token was issued before tokens_valid_after")
+		return false, nil, "Token has been revoked", nil
+	}
+
+	if err := s.sessionRepo.TouchSession(ctx, claims.UserID, jti, time.Now()); err != nil {
+		// Last-seen is informational only, never fail authentication because of it
+		s.log.WithError(err).WithField("jti", jti).Warn("Failed to update last seen of session")
+	}
+
 	// Token is valid and not blacklisted
-	return true, claims.UserID, claims.Username, claims.Role, "", nil
+	return true, claims, "", nil
+}
+
+// JWKS returns the public keys other services use to verify tokens locally.
+func (s *jwtTokenService) JWKS() JSONWebKeySet {
+	return s.keys.JWKS()
 }
 
 // BlacklistToken adds a JWT ID (JTI) to the blacklist.
 func (s *jwtTokenService) BlacklistToken(ctx context.Context, jti string, expiration time.Duration) error {
 	return s.jwtBlacklistRepo.AddToBlacklist(ctx, jti, expiration)
 }
+
+// storeMembership returns the membership for the active store, or nil when no store is requested.
+func (s *jwtTokenService) storeMembership(ctx context.Context, userID uuid.UUID, storeID uuid.NullUUID) (*db.GetStoreMembershipRow, error) {
+	if !storeID.Valid {
+		return nil, nil
+	}
+	return s.tenancyRepo.GetStoreMembership(ctx, &db.GetStoreMembershipParams{UserID: userID, StoreID: storeID.UUID})
+}
+
+func (s *jwtTokenService) generateAccessToken(ctx context.Context, user *entities.User, sessionID string, store *db.GetStoreMembershipRow, amr []string) (string, *JWTClaims, error) {
+	roles, err := s.roleRepo.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	roleNames := make([]string, 0, len(roles))
+	for _, role := range roles {
+		roleNames = append(roleNames, role.Name)
+	}
+
+	permissions, err := s.roleRepo.GetUserPermissions(ctx, user.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	issuedAt, err := s.issuedAt(ctx, user.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims := s.newAccessClaims(user, issuedAt, sessionID, store, amr, s.accessTokenTTL)
+	claims.Roles = roleNames
+	claims.Permissions = permissions
+
+	signedToken, err := s.keys.sign(claims)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return signedToken, claims, nil
+}
+
+// issuedAt returns the iat of a new token for the user. iat only has second precision while the
+// "revoke all" cut-off has millisecond precision, so a token issued in the same second as the
+// cut-off is stamped with the next second to stay valid.
+func (s *jwtTokenService) issuedAt(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	now := time.Now()
+
+	validAfter, err := s.jwtBlacklistRepo.GetTokensValidAfter(ctx, userID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read tokens_valid_after: %w", err)
+	}
+	if now.Truncate(time.Second).Before(validAfter) {
+		return nextSecond(validAfter), nil
+	}
+	return now, nil
+}
+
+// nextSecond returns the first full second after t.
+func nextSecond(t time.Time) time.Time {
+	return t.Truncate(time.Second).Add(time.Second)
+}
+
+// newAccessClaims builds the claims shared by every access token.
+func (s *jwtTokenService) newAccessClaims(user *entities.User, issuedAt time.Time, sessionID string, store *db.GetStoreMembershipRow, amr []string, ttl time.Duration) *JWTClaims {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	claims := &JWTClaims{
+		UserID:        user.ID,
+		Username:      user.Username,
+		Role:          user.Role,
+		SessionID:     sessionID,
+		AMR:           amr,
+		EmailVerified: user.EmailVerified(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			NotBefore: jwt.NewNumericDate(now),
+			ID:        uuid.New().String(), // Unique JTI (JWT ID) for blacklisting
+			Issuer:    tokenIssuer,
+			Subject:   user.Username,
+			Audience:  jwt.ClaimStrings{accessTokenAudience},
+		},
+	}
+
+	if store != nil {
+		claims.StoreID = store.StoreID.String()
+		claims.StoreRole = store.Role
+		claims.OrganizationID = store.OrganizationID.String()
+	}
+
+	return claims
+}
+
+func (s *jwtTokenService) issueTokenPair(ctx context.Context, user *entities.User, familyID uuid.UUID, parentID uuid.NullUUID, opts IssueOptions) (*TokenPair, error) {
+	membership, err := s.storeMembership(ctx, user.ID, opts.StoreID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, claims, err := s.generateAccessToken(ctx, user, familyID.String(), membership, opts.AMR)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := helpers.GenerateSecureToken(refreshTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshExpiresAt := time.Now().Add(s.refreshTokenTTL)
+	_, err = s.refreshTokenRepo.CreateRefreshToken(ctx, &db.CreateRefreshTokenParams{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		ParentID:  parentID,
+		TokenHash: helpers.HashToken(refreshToken),
+		ExpiresAt: refreshExpiresAt,
+		StoreID:   opts.StoreID,
+		Amr:       opts.AMR,
+		ClientID:  opts.ClientID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	if err := s.registerSession(ctx, claims, refreshExpiresAt, opts); err != nil {
+		return nil, fmt.Errorf("failed to register session: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  claims.ExpiresAt.Time,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+// handleRefreshTokenReuse revokes the family of a refresh token that was presented after it had
+// already been rotated. Either the legitimate client or an attacker holds a stale copy, and we
+// cannot tell which, so the whole session is killed.
+func (s *jwtTokenService) handleRefreshTokenReuse(ctx context.Context, stored *db.RefreshToken) error {
+	s.log.WithFields(logrus.Fields{"user_id": stored.UserID, "session_id": stored.FamilyID}).Warn("Refresh token reuse detected, revoking family")
+
+	s.auditor.Record(ctx, audit.Event{
+		Action:       entities.AuditRefreshTokenReused,
+		TargetUserID: stored.UserID,
+		Metadata:     map[string]string{"session_id": stored.FamilyID.String()},
+	})
+
+	if err := s.RevokeRefreshFamily(ctx, stored.UserID, stored.FamilyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return apperrors.ErrRefreshTokenReused
+}
+
+// registerSession records the access token in the user's session registry. On refresh the
+// entry of the family is moved to the new JTI while keeping the original sign-in time.
+func (s *jwtTokenService) registerSession(ctx context.Context, claims *JWTClaims, expiresAt time.Time, opts IssueOptions) error {
+	now := time.Now()
+	session := &models.Session{
+		JTI:        claims.ID,
+		SessionID:  claims.SessionID,
+		UserID:     claims.UserID,
+		Device:     opts.Device,
+		IPAddress:  opts.IPAddress,
+		IssuedAt:   now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}
+
+	if previous := s.findSession(ctx, claims.UserID, claims.SessionID); previous != nil {
+		session.IssuedAt = previous.IssuedAt
+		if session.Device == "" {
+			session.Device = previous.Device
+		}
+		if err := s.sessionRepo.DeleteSession(ctx, claims.UserID, previous.JTI); err != nil {
+			return err
+		}
+	}
+
+	return s.sessionRepo.StoreSession(ctx, session)
+}
+
+// findSession returns the registry entry of a refresh token family, or nil.
+func (s *jwtTokenService) findSession(ctx context.Context, userID uuid.UUID, sessionID string) *models.Session {
+	sessions, err := s.sessionRepo.ListSessions(ctx, userID)
+	if err != nil {
+		s.log.WithError(err).WithField("user_id", userID).Warn("Failed to list sessions")
+		return nil
+	}
+
+	for i := range sessions {
+		if sessions[i].SessionID == sessionID {
+			return &sessions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported values for KeyConfig.Algorithm.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// defaultHMACKeyID is used as kid for the shared-secret key when no explicit key ID is configured.
+const defaultHMACKeyID = "hmac"
+
+// KeyConfig describes how tokens are signed and which keys are accepted when verifying them.
+type KeyConfig struct {
+	Algorithm string
+	// Secret is the HMAC secret. In HS256 mode it signs tokens. In asymmetric modes it is ignored
+	// unless LegacyHMACUntil is set.
+	Secret string
+	// LegacyHMACUntil keeps accepting tokens signed with Secret after switching to an asymmetric
+	// algorithm, until the given time. Every service holding the secret can mint such tokens, so
+	// it should end once the HS256 tokens issued before the switch have expired. Zero disables it.
+	LegacyHMACUntil time.Time
+	// PrivateKeyPath points to the PEM encoded RSA or Ed25519 private key used for signing.
+	PrivateKeyPath string
+	// KeyID overrides the kid of the signing key. Defaults to the RFC 7638 thumbprint.
+	KeyID string
+	// VerificationKeyPaths are additional PEM encoded public (or private) keys that are still
+	// accepted, e.g. the previous signing key during a rotation.
+	VerificationKeyPaths []string
+}
+
+type jwtKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   interface{} // nil for verification-only keys
+	verifyKey interface{}
+	// notAfter ends verification with the key, zero for keys without an end date
+	notAfter time.Time
+}
+
+// KeySet holds the active signing key and every key tokens may be verified with.
+type KeySet struct {
+	signing      *jwtKey
+	verification map[string]*jwtKey
+	legacyHMAC   *jwtKey // accepts tokens without a kid header, nil when HMAC is not accepted
+}
+
+// JSONWebKey is the public part of a verification key as published in the JWKS document.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// LoadKeySet builds the key set for the configured algorithm, reading PEM files from disk.
+func LoadKeySet(cfg KeyConfig) (*KeySet, error) {
+	ks := &KeySet{verification: make(map[string]*jwtKey)}
+
+	switch cfg.Algorithm {
+	case AlgorithmHS256, "":
+		if cfg.Secret == "" {
+			return nil, errors.New("JWT_SECRET is required for HS256 signing")
+		}
+		kid := defaultHMACKeyID
+		if cfg.KeyID != "" {
+			kid = cfg.KeyID
+		}
+		ks.signing = newHMACKey(kid, cfg.Secret)
+		ks.legacyHMAC = ks.signing
+		ks.verification[kid] = ks.signing
+	case AlgorithmRS256, AlgorithmEdDSA:
+		if cfg.PrivateKeyPath == "" {
+			return nil, fmt.Errorf("a private key path is required for %s signing", cfg.Algorithm)
+		}
+		key, err := loadPrivateKey(cfg.PrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		if key.method.Alg() != cfg.Algorithm {
+			return nil, fmt.Errorf("private key %s is a %s key, expected %s", cfg.PrivateKeyPath, key.method.Alg(), cfg.Algorithm)
+		}
+		if cfg.KeyID != "" {
+			key.kid = cfg.KeyID
+		}
+		ks.signing = key
+		ks.verification[key.kid] = key
+
+		if !cfg.LegacyHMACUntil.IsZero() {
+			if cfg.Secret == "" {
+				return nil, errors.New("JWT_SECRET is required to accept legacy HS256 tokens")
+			}
+			ks.legacyHMAC = newHMACKey(defaultHMACKeyID, cfg.Secret)
+			ks.legacyHMAC.notAfter = cfg.LegacyHMACUntil
+			ks.verification[defaultHMACKeyID] = ks.legacyHMAC
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT signing algorithm %q", cfg.Algorithm)
+	}
+
+	for _, path := range cfg.VerificationKeyPaths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		key, err := loadVerificationKey(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := ks.verification[key.kid]; !exists {
+			ks.verification[key.kid] = key
+		}
+	}
+
+	return ks, nil
+}
+
+// sign signs the claims with the active key and stamps its kid in the header.
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	token.Header["kid"] = ks.signing.kid
+	return token.SignedString(ks.signing.signKey)
+}
+
+// keyFunc resolves the verification key for a token from its kid header.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	var key *jwtKey
+	if kid == "" {
+		key = ks.legacyHMAC
+	} else {
+		key = ks.verification[kid]
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if !key.notAfter.IsZero() && time.Now().After(key.notAfter) {
+		return nil, fmt.Errorf("signing key %q is no longer accepted", key.kid)
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.verifyKey, nil
+}
+
+// validMethods lists the algorithms of all verification keys.
+func (ks *KeySet) validMethods() []string {
+	seen := make(map[string]struct{})
+	methods := make([]string, 0, len(ks.verification))
+	for _, key := range ks.verification {
+		if _, ok := seen[key.method.Alg()]; ok {
+			continue
+		}
+		seen[key.method.Alg()] = struct{}{}
+		methods = append(methods, key.method.Alg())
+	}
+	return methods
+}
+
+// JWKS returns the public verification keys. Shared HMAC secrets are never published.
+func (ks *KeySet) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(ks.verification))}
+	for _, key := range ks.verification {
+		if jwk, ok := toJSONWebKey(key); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	// Keep the document stable across requests, active key first.
+	sort.Slice(set.Keys, func(i, j int) bool {
+		if (set.Keys[i].Kid == ks.signing.kid) != (set.Keys[j].Kid == ks.signing.kid) {
+			return set.Keys[i].Kid == ks.signing.kid
+		}
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
+
+func newHMACKey(kid string, secret string) *jwtKey {
+	return &jwtKey{
+		kid:       kid,
+		method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+func loadPrivateKey(path string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key %s: %w", path, err)
+	}
+
+	if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return newJWTKey(jwt.SigningMethodRS256, rsaKey, &rsaKey.PublicKey)
+	}
+	if edKey, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		signer, ok := edKey.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key %s is not an Ed25519 key", path)
+		}
+		return newJWTKey(jwt.SigningMethodEdDSA, signer, signer.Public())
+	}
+
+	return nil, fmt.Errorf("private key %s is neither an RSA nor an Ed25519 PEM key", path)
+}
+
+func loadVerificationKey(path string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read verification key %s: %w", path, err)
+	}
+
+	if rsaKey, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return newJWTKey(jwt.SigningMethodRS256, nil, rsaKey)
+	}
+	if edKey, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return newJWTKey(jwt.SigningMethodEdDSA, nil, edKey)
+	}
+
+	// Accept a retired private key file as well, only its public half is kept.
+	key, err := loadPrivateKey(path)
+	if err != nil {
+		return nil, fmt.Errorf("verification key %s is not a supported PEM key", path)
+	}
+	key.signKey = nil
+	return key, nil
+}
+
+func newJWTKey(method jwt.SigningMethod, signKey interface{}, verifyKey crypto.PublicKey) (*jwtKey, error) {
+	key := &jwtKey{method: method, signKey: signKey, verifyKey: verifyKey}
+
+	jwk, ok := toJSONWebKey(key)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %T", verifyKey)
+	}
+	kid, err := thumbprint(jwk)
+	if err != nil {
+		return nil, err
+	}
+	key.kid = kid
+	return key, nil
+}
+
+func toJSONWebKey(key *jwtKey) (JSONWebKey, bool) {
+	switch pub := key.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JSONWebKey{
+			Kty: "RSA",
+			Kid: key.kid,
+			Use: "sig",
+			Alg: key.method.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JSONWebKey{
+			Kty: "OKP",
+			Kid: key.kid,
+			Use: "sig",
+			Alg: key.method.Alg(),
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}, true
+	default:
+		return JSONWebKey{}, false
+	}
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint, used as the default kid.
+func thumbprint(jwk JSONWebKey) (string, error) {
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	default:
+		return "", fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute key thumbprint: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
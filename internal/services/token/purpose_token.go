@@ -0,0 +1,118 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+// Purposes of single-step tokens.
+const (
+	// PurposeMFA marks a login whose password step succeeded and that still needs a second factor.
+	PurposeMFA = "mfa_pending"
+	// PurposeEmailVerification proves ownership of the email address embedded in the token.
+	PurposeEmailVerification = "email_verification"
+	// PurposeMagicLink signs the user in to the account of the embedded email address.
+	PurposeMagicLink = "magic_link"
+	// PurposeUnsubscribe turns off marketing emails for the embedded user. Unlike the other
+	// purposes it is not consumed, the link in an old campaign email keeps working.
+	PurposeUnsubscribe = "unsubscribe"
+)
+
+// PurposeSubject is what a purpose token is issued for. Email is optional and binds the token
+// to the address it was sent to. AMR records the factors already checked, e.g. for an MFA challenge.
+type PurposeSubject struct {
+	UserID uuid.UUID
+	Email  string
+	AMR    []string
+}
+
+// PurposeClaims are carried by single-purpose tokens. Their audience is derived from the purpose
+// and never matches the access token audience, so they can not be used to call APIs.
+type PurposeClaims struct {
+	UserID uuid.UUID `json:"user_id"`
+	Email  string    `json:"email,omitempty"`
+	AMR    []string  `json:"amr,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func purposeAudience(purpose string) string {
+	return "shopeezy-account-service:" + purpose
+}
+
+func (s *jwtTokenService) GeneratePurposeToken(ctx context.Context, subject PurposeSubject, purpose string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	claims := &PurposeClaims{
+		UserID: subject.UserID,
+		Email:  subject.Email,
+		AMR:    subject.AMR,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ID:        uuid.New().String(),
+			Issuer:    tokenIssuer,
+			Subject:   subject.UserID.String(),
+			Audience:  jwt.ClaimStrings{purposeAudience(purpose)},
+		},
+	}
+
+	signedToken, err := s.keys.sign(claims)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign %s token: %w", purpose, err)
+	}
+	return signedToken, expiresAt, nil
+}
+
+func (s *jwtTokenService) ValidatePurposeToken(ctx context.Context, tokenString string, purpose string) (*PurposeClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &PurposeClaims{}, s.keys.keyFunc,
+		jwt.WithValidMethods(s.keys.validMethods()),
+		jwt.WithAudience(purposeAudience(purpose)),
+		jwt.WithIssuer(tokenIssuer),
+	)
+	if err != nil {
+		s.log.WithError(err).WithField("purpose", purpose).Debug("Failed to validate purpose token")
+		return nil, apperrors.ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*PurposeClaims)
+	if !ok || !token.Valid || claims.ID == "" {
+		return nil, apperrors.ErrInvalidToken
+	}
+
+	// Purpose tokens are blacklisted once consumed
+	isBlacklisted, err := s.jwtBlacklistRepo.IsBlacklisted(ctx, claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %s token blacklist: %w", purpose, err)
+	}
+	if isBlacklisted {
+		return nil, apperrors.ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func (s *jwtTokenService) ConsumePurposeToken(ctx context.Context, claims *PurposeClaims) error {
+	consumed, err := s.jwtBlacklistRepo.AddToBlacklistIfAbsent(ctx, claims.ID, time.Until(claims.ExpiresAt.Time))
+	if err != nil {
+		return fmt.Errorf("failed to consume purpose token: %w", err)
+	}
+	if !consumed {
+		return apperrors.ErrInvalidToken
+	}
+	return nil
+}
+
+func (s *jwtTokenService) ReleasePurposeToken(ctx context.Context, claims *PurposeClaims) error {
+	if err := s.jwtBlacklistRepo.RemoveFromBlacklist(ctx, claims.ID); err != nil {
+		return fmt.Errorf("failed to release purpose token: %w", err)
+	}
+	return nil
+}
@@ -9,12 +9,70 @@ import (
 	// Jika model User digunakan di sini
 )
 
+// TokenPair is a short-lived access token together with the opaque refresh token used to renew it.
+type TokenPair struct {
+	AccessToken           string
+	AccessTokenExpiresAt  time.Time
+	RefreshToken          string
+	RefreshTokenExpiresAt time.Time
+}
+
+// IssueOptions carries request details recorded on the session a token pair belongs to.
+type IssueOptions struct {
+	Device    string
+	IPAddress string
+	// StoreID is the active store the token is issued for, the user must be a member of it.
+	StoreID uuid.NullUUID
+	// AMR lists how the user authenticated (entities.AMRPassword, ...), kept for the whole session.
+	AMR []string
+	// ClientID is the OAuth client the tokens are issued to, empty for logins on the account API.
+	// Refresh only rotates a refresh token for the client it was issued to.
+	ClientID string
+}
+
 // TokenService defines the interface for token management service (JWT).
 type TokenService interface {
 	// generates a JWT for the user.
 	GenerateToken(ctx context.Context, user *entities.User) (string, error)
-	//  validates a JWT and returns user details if valid.
-	ValidateToken(ctx context.Context, tokenString string) (isValid bool, userID uuid.UUID, username string, role string, errorMessage string, err error)
+	// generates an access token and starts a new refresh token family for the user.
+	GenerateTokenPair(ctx context.Context, user *entities.User, opts IssueOptions) (*TokenPair, error)
+	// issues an access token without refresh token that is limited to the given scopes and
+	// carries no roles or permissions, e.g. for PIN logins on a POS terminal.
+	GenerateScopedToken(ctx context.Context, user *entities.User, scopes []string, ttl time.Duration, opts IssueOptions) (string, time.Time, error)
+	// issues a scoped token like GenerateScopedToken for third-party OpenID Connect clients. It is
+	// only accepted by ValidateUserInfoToken, never by ValidateToken.
+	GenerateUserInfoToken(ctx context.Context, user *entities.User, scopes []string, ttl time.Duration, opts IssueOptions) (string, time.Time, error)
+	// exchanges a refresh token for a new pair. Each refresh token can be used once;
+	// presenting a used token again revokes the whole family.
+	Refresh(ctx context.Context, refreshToken string, opts IssueOptions) (*TokenPair, error)
+	// revokes every refresh token of a family (session) and the access tokens issued for it.
+	RevokeRefreshFamily(ctx context.Context, userID uuid.UUID, familyID uuid.UUID) error
+	// invalidates every token of the user issued up to now and clears the session registry.
+	RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) error
+	//  validates a JWT or an API key and returns its claims if valid.
+	ValidateToken(ctx context.Context, tokenString string) (isValid bool, claims *JWTClaims, errorMessage string, err error)
+	// validates a token for the OpenID Connect UserInfo endpoint: a JWT accepted by ValidateToken
+	// or a token of GenerateUserInfoToken. API keys are refused.
+	ValidateUserInfoToken(ctx context.Context, tokenString string) (isValid bool, claims *JWTClaims, errorMessage string, err error)
+	// issues a short-lived token that only proves one step of a flow, e.g. PurposeMFA.
+	GeneratePurposeToken(ctx context.Context, subject PurposeSubject, purpose string, ttl time.Duration) (string, time.Time, error)
+	// validates a purpose token issued for the given purpose.
+	ValidatePurposeToken(ctx context.Context, tokenString string, purpose string) (*PurposeClaims, error)
+	// consumes a single-use purpose token, only one of concurrent callers succeeds. The others
+	// get apperrors.ErrInvalidToken.
+	ConsumePurposeToken(ctx context.Context, claims *PurposeClaims) error
+	// makes a consumed purpose token usable again, e.g. after a wrong code was entered.
+	ReleasePurposeToken(ctx context.Context, claims *PurposeClaims) error
+	// issues a token for another service (OAuth client credentials grant), subject "service:<client_id>".
+	GenerateServiceToken(ctx context.Context, clientID string, scopes []string, ttl time.Duration) (string, time.Time, error)
+	// validates a service token. It does not check whether the client was revoked since.
+	ValidateServiceToken(ctx context.Context, tokenString string) (*ServiceClaims, error)
+	// issues an OpenID Connect ID token for the user and client.
+	GenerateIDToken(ctx context.Context, user *entities.User, opts IDTokenOptions) (string, error)
+	// returns the JWS algorithm tokens are signed with, e.g. for the discovery document.
+	SigningAlgorithm() string
+	// returns the public verification keys as a JSON Web Key Set.
+	JWKS() JSONWebKeySet
 	// adds a JWT ID (JTI) to the blacklist.
 	BlacklistToken(ctx context.Context, jti string, expiration time.Duration) error
 }
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+)
+
+// TokenPurgeResult counts the rows one purge removed.
+type TokenPurgeResult struct {
+	RefreshTokens       int64
+	PasswordResetTokens int64
+	AuthorizationCodes  int64
+}
+
+// TokenPurgeService deletes refresh tokens, password reset tokens and authorization codes
+// that can no longer be used. They are kept for a grace period after expiring so a recent
+// incident can still be traced.
+type TokenPurgeService interface {
+	PurgeExpired(ctx context.Context) (*TokenPurgeResult, error)
+}
+
+type TokenPurgeServiceImpl struct {
+	refreshTokenRepo      repositories.RefreshTokenRepository
+	passwordResetRepo     repositories.PasswordResetRepository
+	authorizationCodeRepo repositories.AuthorizationCodeRepository
+	grace                 time.Duration
+	log                   *logrus.Logger
+}
+
+func NewTokenPurgeService(
+	refreshTokenRepo repositories.RefreshTokenRepository,
+	passwordResetRepo repositories.PasswordResetRepository,
+	authorizationCodeRepo repositories.AuthorizationCodeRepository,
+	grace time.Duration,
+	log *logrus.Logger,
+) TokenPurgeService {
+	return &TokenPurgeServiceImpl{
+		refreshTokenRepo:      refreshTokenRepo,
+		passwordResetRepo:     passwordResetRepo,
+		authorizationCodeRepo: authorizationCodeRepo,
+		grace:                 grace,
+		log:                   log,
+	}
+}
+
+func (s *TokenPurgeServiceImpl) PurgeExpired(ctx context.Context) (*TokenPurgeResult, error) {
+	before := time.Now().Add(-s.grace)
+	res := &TokenPurgeResult{}
+
+	var err error
+	if res.RefreshTokens, err = s.refreshTokenRepo.DeleteExpired(ctx, before); err != nil {
+		return nil, fmt.Errorf("service: failed to purge refresh tokens: %w", err)
+	}
+	if res.PasswordResetTokens, err = s.passwordResetRepo.DeleteExpired(ctx, before); err != nil {
+		return nil, fmt.Errorf("service: failed to purge password reset tokens: %w", err)
+	}
+	if res.AuthorizationCodes, err = s.authorizationCodeRepo.DeleteExpired(ctx, before); err != nil {
+		return nil, fmt.Errorf("service: failed to purge authorization codes: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"refresh_tokens":        res.RefreshTokens,
+		"password_reset_tokens": res.PasswordResetTokens,
+		"authorization_codes":   res.AuthorizationCodes,
+		"expired_before":        before,
+	}).Info("Expired tokens purged")
+
+	return res, nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+// userCursor is the position after the last user of a page. It is only valid for the sort
+// order it was created with.
+type userCursor struct {
+	Sort      string    `json:"s"`
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	Name      string    `json:"n,omitempty"`
+	// Score and Query position a page of search results
+	Score float32 `json:"r,omitempty"`
+	Query string  `json:"q,omitempty"`
+}
+
+func encodeUserCursor(cursor userCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeUserCursor(value string, sort string) (*userCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", apperrors.ErrInvalidRequestPayload)
+	}
+
+	var cursor userCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == uuid.Nil {
+		return nil, fmt.Errorf("%w: malformed cursor", apperrors.ErrInvalidRequestPayload)
+	}
+	if cursor.Sort != sort {
+		return nil, fmt.Errorf("%w: cursor belongs to a different sort order", apperrors.ErrInvalidRequestPayload)
+	}
+	return &cursor, nil
+}
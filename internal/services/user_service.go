@@ -2,17 +2,20 @@ package services
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
 	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
@@ -20,12 +23,16 @@ import (
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/audit"
 	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
 )
 
 type UserSource interface {
-	db.GetAllUsersRow |
+	db.ListUsersRow |
+		db.SearchUsersRow |
 		db.GetUserByIDRow |
+		db.GetUserByEmailRow |
+		db.GetUserByUsernameRow |
 		db.GetUserByIDsRow |
 		db.User
 }
@@ -34,19 +41,40 @@ type UserService interface {
 	Register(ctx context.Context, req *models.UserRegisterRequest) (*entities.User, error)
 	Login(ctx context.Context, req *models.UserLoginRequest) (*entities.User, error)
 	Logout(ctx context.Context, authHeader string) error
-	GetAllUsers(ctx context.Context) ([]entities.User, error)
+	ListUsers(ctx context.Context, query *models.UserListQuery) (*UserPage, error)
+	SearchUsers(ctx context.Context, query *models.UserSearchQuery) (*UserPage, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*entities.User, error)
 	GetUserByIDs(ctx context.Context, IDs []uuid.UUID) ([]entities.User, error)
 	UpdateUser(ctx context.Context, id uuid.UUID, req *models.UserUpdateRequest) (*entities.User, error)
 	DeleteUser(ctx context.Context, id uuid.UUID) (*entities.User, error)
 }
 
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 200
+
+	searchSort           = "relevance"
+	maxSearchQueryLength = 100
+)
+
+// UserPage is one page of a user listing.
+type UserPage struct {
+	Users      []entities.User
+	NextCursor string
+}
+
+// dummyPasswordHash is compared against when the username does not exist.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("shopeezy-dummy-password"), bcrypt.DefaultCost)
+
 type UserServiceImpl struct {
-	userRepo         repositories.UserRepository
-	validator        *validator.Validate
-	tokenService     token.TokenService
-	JWTBlacklistRepo repositories.JWTBlacklistRepository
-	log              *logrus.Logger
+	userRepo              repositories.UserRepository
+	validator             *validator.Validate
+	tokenService          token.TokenService
+	JWTBlacklistRepo      repositories.JWTBlacklistRepository
+	loginThrottle         LoginThrottleService
+	emailVerificationMode string
+	auditor               audit.Recorder
+	log                   *logrus.Logger
 }
 
 func NewUserService(
@@ -54,20 +82,24 @@ func NewUserService(
 	validator *validator.Validate,
 	tokenService token.TokenService,
 	JWTBlacklistRepo repositories.JWTBlacklistRepository,
+	loginThrottle LoginThrottleService,
+	emailVerificationMode string,
+	auditor audit.Recorder,
 	log *logrus.Logger,
 ) UserService {
 	return &UserServiceImpl{
-		userRepo:         userRepo,
-		validator:        validator,
-		tokenService:     tokenService,
-		JWTBlacklistRepo: JWTBlacklistRepo,
-		log:              log,
+		userRepo:              userRepo,
+		validator:             validator,
+		tokenService:          tokenService,
+		JWTBlacklistRepo:      JWTBlacklistRepo,
+		loginThrottle:         loginThrottle,
+		emailVerificationMode: emailVerificationMode,
+		auditor:               auditor,
+		log:                   log,
 	}
 }
 
 func (s *UserServiceImpl) Register(ctx context.Context, req *models.UserRegisterRequest) (*entities.User, error) {
-	var user *entities.User
-
 	if err := s.validator.Struct(req); err != nil {
 		validationErrors := err.(validator.ValidationErrors)
 
@@ -80,10 +112,6 @@ func (s *UserServiceImpl) Register(ctx context.Context, req *models.UserRegister
 		return nil, fmt.Errorf("%w: %s", apperrors.ErrInvalidRequestPayload, strings.Join(errorMessages, ", ")) // Menggunakan error kustom
 	}
 
-	if req.Role == "" {
-		req.Role = "user"
-	}
-
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		log.Printf("Error hashing password: %v", err)
@@ -98,60 +126,102 @@ func (s *UserServiceImpl) Register(ctx context.Context, req *models.UserRegister
 		Password:    string(hashedPassword),
 		PhoneNumber: "",
 		Address:     "",
-		Role:        req.Role,
+		// Self-registration always gets the default role, privileged roles are granted by an admin
+		Role: entities.DefaultRole,
 	}
 
 	userDB, err := s.userRepo.CreateUser(ctx, dbParam)
 	if err != nil {
+		if errors.Is(err, apperrors.ErrUserAlreadyExists) {
+			return nil, err
+		}
 		log.Printf("Error creating user: %v", err)
 		return nil, fmt.Errorf("service: failed to register user: %w", err)
 	}
 
-	user = &entities.User{
-		ID:        userDB.ID,
-		Name:      userDB.Name,
-		Username:  userDB.Username,
-		Email:     userDB.Email,
-		Role:      userDB.Role,
-		Address:   userDB.Address,
-		CreatedAt: userDB.CreatedAt,
-		UpdatedAt: userDB.UpdatedAt,
-	}
+	s.auditor.Record(ctx, audit.Event{
+		Action:       entities.AuditUserRegistered,
+		ActorID:      userDB.ID,
+		TargetUserID: userDB.ID,
+	})
 
-	return user, nil
+	return toDomainUser(userDB), nil
 }
 
 func (s *UserServiceImpl) Login(ctx context.Context, req *models.UserLoginRequest) (*entities.User, error) {
-	var user *entities.User
+	if err := s.loginThrottle.Check(ctx, req.Username, req.IPAddress); err != nil {
+		switch {
+		case errors.Is(err, apperrors.ErrAccountLocked):
+			s.recordLoginFailure(ctx, req, uuid.Nil, entities.AuditReasonLocked)
+		case errors.Is(err, apperrors.ErrTooManyAttempts):
+			s.recordLoginFailure(ctx, req, uuid.Nil, entities.AuditReasonRateLimited)
+		}
+		return nil, err
+	}
 
 	userDB, err := s.userRepo.GetUserByUsername(ctx, req.Username)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Spend the same time as for a wrong password so unknown usernames can not be told apart
+			_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+			return nil, s.loginFailed(ctx, req, uuid.Nil)
+		}
 		s.log.WithError(err).Error("Failed to retrieve user by username from the database")
 		return nil, fmt.Errorf("service: failed to login: %w", err)
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(userDB.Password), []byte(req.Password))
 	if err != nil {
-		log.Printf("Password comparison failed: %v", err)
-		s.log.WithError(err).Error("Password comparison failed")
-		return nil, apperrors.ErrInvalidCredentials
+		s.log.WithError(err).Warn("Password comparison failed")
+		return nil, s.loginFailed(ctx, req, userDB.ID)
 	}
 
-	user = &entities.User{
-		ID:          userDB.ID,
-		Name:        userDB.Name,
-		Username:    userDB.Username,
-		Email:       userDB.Email,
-		Role:        userDB.Role,
-		Address:     userDB.Address,
-		PhoneNumber: userDB.PhoneNumber,
-		CreatedAt:   userDB.CreatedAt,
-		UpdatedAt:   userDB.UpdatedAt,
+	s.loginThrottle.RecordSuccess(ctx, req.Username)
+
+	user := toDomainUser(userDB)
+
+	if s.emailVerificationMode == EmailVerificationDeny && !user.EmailVerified() {
+		s.recordLoginFailure(ctx, req, user.ID, entities.AuditReasonEmailNotVerified)
+		return nil, apperrors.ErrEmailNotVerified
 	}
 
+	s.auditor.Record(ctx, audit.Event{
+		Action:       entities.AuditLoginSucceeded,
+		ActorID:      user.ID,
+		TargetUserID: user.ID,
+		Metadata:     map[string]string{"method": entities.AMRPassword},
+	})
+
 	return user, nil
 }
 
+// loginFailed records the failure and returns the error for the caller, which is the lockout
+// when this attempt reached the limit. userID is nil when the username does not exist.
+func (s *UserServiceImpl) loginFailed(ctx context.Context, req *models.UserLoginRequest, userID uuid.UUID) error {
+	s.recordLoginFailure(ctx, req, userID, entities.AuditReasonInvalidCredentials)
+
+	if err := s.loginThrottle.RecordFailure(ctx, req.Username, req.IPAddress); err != nil {
+		var retryErr *apperrors.RetryAfterError
+		if errors.As(err, &retryErr) {
+			return err
+		}
+		s.log.WithError(err).Error("Failed to record failed login")
+	}
+	return apperrors.ErrInvalidCredentials
+}
+
+func (s *UserServiceImpl) recordLoginFailure(ctx context.Context, req *models.UserLoginRequest, userID uuid.UUID, reason string) {
+	s.auditor.Record(ctx, audit.Event{
+		Action:       entities.AuditLoginFailed,
+		TargetUserID: userID,
+		Metadata: map[string]string{
+			"method":   entities.AMRPassword,
+			"username": req.Username,
+			"reason":   reason,
+		},
+	})
+}
+
 func (s *UserServiceImpl) Logout(ctx context.Context, authHeader string) error {
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
@@ -159,15 +229,13 @@ func (s *UserServiceImpl) Logout(ctx context.Context, authHeader string) error {
 		return apperrors.ErrInvalidTokenFormat
 	}
 
-	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &models.JWTClaims{})
-	if err != nil {
-		log.Printf("Error parsing unverified JWT for logout: %v", err)
+	// The token drives session revocation, so it must carry a valid signature
+	isValid, claims, _, err := s.tokenService.ValidateToken(ctx, tokenString)
+	if err != nil || !isValid {
 		return apperrors.ErrInvalidToken
 	}
-
-	claims, ok := token.Claims.(*models.JWTClaims)
-	if !ok {
-		return apperrors.ErrInvalidToken
+	if slices.Contains(claims.AMR, entities.AMRAPIKey) {
+		return apperrors.ErrAPIKeyLogout
 	}
 
 	jti := claims.ID
@@ -175,6 +243,10 @@ func (s *UserServiceImpl) Logout(ctx context.Context, authHeader string) error {
 		return apperrors.ErrMissingJTI
 	}
 
+	if claims.ExpiresAt == nil {
+		return apperrors.ErrInvalidToken
+	}
+
 	// Calculate remaining time the token is valid
 	remainingTime := time.Until(claims.ExpiresAt.Time)
 	if remainingTime < 0 {
@@ -188,17 +260,93 @@ func (s *UserServiceImpl) Logout(ctx context.Context, authHeader string) error {
 		return apperrors.ErrFailedToRevokeToken
 	}
 
+	// Revoke the refresh token family so the session cannot be renewed either
+	if claims.SessionID != "" {
+		familyID, err := uuid.Parse(claims.SessionID)
+		if err != nil {
+			return apperrors.ErrInvalidToken
+		}
+
+		if err := s.tokenService.RevokeRefreshFamily(ctx, claims.UserID, familyID); err != nil {
+			log.Printf("Error revoking refresh token family %s: %v", familyID, err)
+			return apperrors.ErrFailedToRevokeToken
+		}
+	}
+
+	s.auditor.Record(ctx, audit.Event{
+		Action:       entities.AuditLogout,
+		ActorID:      claims.UserID,
+		TargetUserID: claims.UserID,
+		Metadata:     map[string]string{"session_id": claims.SessionID},
+	})
+
 	log.Printf("Token with JTI %s successfully revoked.", jti)
 	return nil
 }
 
-func (s *UserServiceImpl) GetAllUsers(ctx context.Context) ([]entities.User, error) {
-	users, err := s.userRepo.GetAllUsers(ctx)
+// ListUsers returns one page of users in keyset order. Pass UserPage.NextCursor back as
+// query.Cursor to get the next page; it is empty on the last page.
+func (s *UserServiceImpl) ListUsers(ctx context.Context, query *models.UserListQuery) (*UserPage, error) {
+	params, err := toListUsersParams(query)
 	if err != nil {
-		return nil, fmt.Errorf("service: failed to get all users: %w", err)
+		return nil, err
 	}
 
-	return toDomainUsers(users), nil
+	limit := params.PageLimit
+	// Fetch one extra row to know whether there is a next page
+	params.PageLimit++
+
+	rows, err := s.userRepo.ListUsers(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list users: %w", err)
+	}
+
+	page := &UserPage{}
+	if int32(len(rows)) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeUserCursor(userCursor{
+			Sort:      params.Sort,
+			ID:        last.ID,
+			CreatedAt: last.CreatedAt,
+			Name:      last.Name,
+		})
+	}
+	page.Users = toDomainUsers(rows)
+
+	return page, nil
+}
+
+// SearchUsers finds users by partial or misspelled name, username, email or phone number,
+// best matches first. Paging works like ListUsers.
+func (s *UserServiceImpl) SearchUsers(ctx context.Context, query *models.UserSearchQuery) (*UserPage, error) {
+	params, err := toSearchUsersParams(query)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := params.PageLimit
+	params.PageLimit++
+
+	rows, err := s.userRepo.SearchUsers(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to search users: %w", err)
+	}
+
+	page := &UserPage{}
+	if int32(len(rows)) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeUserCursor(userCursor{
+			Sort:  searchSort,
+			ID:    last.ID,
+			Score: last.Score,
+			Query: params.Query,
+		})
+	}
+	page.Users = toDomainUsers(rows)
+
+	return page, nil
 }
 
 func (s *UserServiceImpl) GetUserByID(ctx context.Context, id uuid.UUID) (*entities.User, error) {
@@ -224,33 +372,202 @@ func (s *UserServiceImpl) UpdateUser(ctx context.Context, id uuid.UUID, req *mod
 		return nil, fmt.Errorf("%w: %s", apperrors.ErrInvalidRequestPayload, err)
 	}
 
+	existing, err := s.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", apperrors.ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("UpdateUser service error: %w", err)
+	}
+
+	// Keep the stored hash unless a new password is given, and never let a profile update change the role
+	password := existing.Password
+	if req.Password != "" {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("UpdateUser service error: %w", err)
+		}
+		password = string(hashedPassword)
+	}
+
 	dbParams := &db.UpdateUserParams{
 		ID:          id,
 		Name:        req.Name,
 		Username:    req.Username,
 		Email:       req.Email,
-		Password:    req.Password,
+		Password:    password,
+		Role:        existing.Role,
 		Address:     req.Address,
 		PhoneNumber: req.PhoneNumber,
 	}
 
 	user, err := s.userRepo.UpdateUser(ctx, dbParams)
 	if err != nil {
+		if errors.Is(err, apperrors.ErrUserAlreadyExists) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("UpdateUser service error: %w", err)
 	}
 
+	s.auditor.Record(ctx, audit.Event{
+		Action:       entities.AuditUserUpdated,
+		TargetUserID: id,
+		Metadata:     map[string]any{"changes": userChanges(existing, user, req.Password != "")},
+	})
+
 	return toDomainUser(user), nil
 }
 
+// userChanges is the field-level diff of a profile update for the audit log. A new password
+// is only marked as changed.
+func userChanges(before *db.GetUserByIDRow, after *db.User, passwordChanged bool) map[string]entities.AuditChange {
+	changes := make(map[string]entities.AuditChange)
+
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"name", before.Name, after.Name},
+		{"username", before.Username, after.Username},
+		{"email", before.Email, after.Email},
+		{"phone_number", before.PhoneNumber, after.PhoneNumber},
+		{"address", before.Address, after.Address},
+	}
+	for _, field := range fields {
+		if field.from != field.to {
+			changes[field.name] = entities.AuditChange{From: field.from, To: field.to}
+		}
+	}
+
+	if passwordChanged {
+		changes["password"] = entities.AuditChange{}
+	}
+
+	return changes
+}
+
 func (s *UserServiceImpl) DeleteUser(ctx context.Context, id uuid.UUID) (*entities.User, error) {
 	user, err := s.userRepo.DeleteUser(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("UpdateUser service error: %w", err)
 	}
 
+	s.auditor.Record(ctx, audit.Event{
+		Action:       entities.AuditUserDeleted,
+		TargetUserID: id,
+	})
+
 	return toDomainUser(user), nil
 }
 
+func toListUsersParams(query *models.UserListQuery) (*db.ListUsersParams, error) {
+	params := &db.ListUsersParams{
+		Sort:           query.Sort,
+		Deleted:        query.Deleted,
+		OrganizationID: query.OrganizationID,
+		PageLimit:      int32(query.Limit),
+	}
+
+	switch {
+	case params.PageLimit == 0:
+		params.PageLimit = defaultUserPageSize
+	case params.PageLimit < 0 || params.PageLimit > maxUserPageSize:
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", apperrors.ErrInvalidRequestPayload, maxUserPageSize)
+	}
+
+	switch params.Sort {
+	case "":
+		params.Sort = "-created_at"
+	case "created_at", "-created_at", "name", "-name":
+	default:
+		return nil, fmt.Errorf("%w: unsupported sort %q", apperrors.ErrInvalidRequestPayload, query.Sort)
+	}
+
+	switch params.Deleted {
+	case "":
+		params.Deleted = "exclude"
+	case "exclude", "include", "only":
+	default:
+		return nil, fmt.Errorf("%w: deleted must be exclude, include or only", apperrors.ErrInvalidRequestPayload)
+	}
+
+	if query.Role != "" {
+		params.Role = sql.NullString{String: query.Role, Valid: true}
+	}
+
+	if query.CreatedFrom != "" {
+		from, err := time.Parse(time.RFC3339, query.CreatedFrom)
+		if err != nil {
+			return nil, fmt.Errorf("%w: created_from must be an RFC 3339 timestamp", apperrors.ErrInvalidRequestPayload)
+		}
+		params.CreatedFrom = sql.NullTime{Time: from, Valid: true}
+	}
+
+	if query.CreatedTo != "" {
+		to, err := time.Parse(time.RFC3339, query.CreatedTo)
+		if err != nil {
+			return nil, fmt.Errorf("%w: created_to must be an RFC 3339 timestamp", apperrors.ErrInvalidRequestPayload)
+		}
+		params.CreatedTo = sql.NullTime{Time: to, Valid: true}
+	}
+
+	if search := strings.TrimSpace(query.Search); search != "" {
+		// The term is used inside ILIKE, so its wildcards must match literally
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(search)
+		params.Search = sql.NullString{String: escaped, Valid: true}
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeUserCursor(query.Cursor, params.Sort)
+		if err != nil {
+			return nil, err
+		}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorName = sql.NullString{String: cursor.Name, Valid: true}
+	}
+
+	return params, nil
+}
+
+func toSearchUsersParams(query *models.UserSearchQuery) (*db.SearchUsersParams, error) {
+	search := strings.TrimSpace(query.Search)
+	if search == "" {
+		return nil, fmt.Errorf("%w: q is required", apperrors.ErrInvalidRequestPayload)
+	}
+	if len(search) > maxSearchQueryLength {
+		return nil, fmt.Errorf("%w: q must be at most %d characters", apperrors.ErrInvalidRequestPayload, maxSearchQueryLength)
+	}
+
+	params := &db.SearchUsersParams{
+		Query:          search,
+		Pattern:        "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(search) + "%",
+		OrganizationID: query.OrganizationID,
+		PageLimit:      int32(query.Limit),
+	}
+
+	switch {
+	case params.PageLimit == 0:
+		params.PageLimit = defaultUserPageSize
+	case params.PageLimit < 0 || params.PageLimit > maxUserPageSize:
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", apperrors.ErrInvalidRequestPayload, maxUserPageSize)
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeUserCursor(query.Cursor, searchSort)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Query != search {
+			return nil, fmt.Errorf("%w: cursor belongs to a different search", apperrors.ErrInvalidRequestPayload)
+		}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+		params.CursorScore = sql.NullFloat64{Float64: float64(cursor.Score), Valid: true}
+	}
+
+	return params, nil
+}
+
 func toDomainUser[T UserSource](dbUser *T) *entities.User {
 	v := reflect.ValueOf(dbUser)
 	if v.Kind() == reflect.Ptr {
@@ -259,17 +576,30 @@ func toDomainUser[T UserSource](dbUser *T) *entities.User {
 
 	id := v.FieldByName("ID").Interface().(uuid.UUID)
 
-	return &entities.User{
-		ID:          id,
-		Name:        v.FieldByName("Name").Interface().(string),
-		Username:    v.FieldByName("Username").Interface().(string),
-		Email:       v.FieldByName("Email").Interface().(string),
-		Role:        v.FieldByName("Role").Interface().(string),
-		Address:     v.FieldByName("Address").Interface().(string),
-		PhoneNumber: v.FieldByName("PhoneNumber").Interface().(string),
-		CreatedAt:   v.FieldByName("CreatedAt").Interface().(time.Time),
-		UpdatedAt:   v.FieldByName("UpdatedAt").Interface().(time.Time),
+	var emailVerifiedAt *time.Time
+	if verifiedAt := v.FieldByName("EmailVerifiedAt").Interface().(sql.NullTime); verifiedAt.Valid {
+		emailVerifiedAt = &verifiedAt.Time
+	}
+
+	user := &entities.User{
+		ID:              id,
+		Name:            v.FieldByName("Name").Interface().(string),
+		Username:        v.FieldByName("Username").Interface().(string),
+		Email:           v.FieldByName("Email").Interface().(string),
+		Role:            v.FieldByName("Role").Interface().(string),
+		Address:         v.FieldByName("Address").Interface().(string),
+		PhoneNumber:     v.FieldByName("PhoneNumber").Interface().(string),
+		EmailVerifiedAt: emailVerifiedAt,
+		CreatedAt:       v.FieldByName("CreatedAt").Interface().(time.Time),
+		UpdatedAt:       v.FieldByName("UpdatedAt").Interface().(time.Time),
 	}
+
+	// Only listings that can include deleted users select deleted_at
+	if deletedAt := v.FieldByName("DeletedAt"); deletedAt.IsValid() {
+		user.DeletedAt = gorm.DeletedAt(deletedAt.Interface().(sql.NullTime))
+	}
+
+	return user
 }
 
 func toDomainUsers[T UserSource](dbUsers []T) []entities.User {
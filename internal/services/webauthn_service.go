@@ -0,0 +1,507 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+)
+
+// Purposes of WebAuthn ceremonies, a session of one can not finish another.
+const (
+	webAuthnPurposeRegistration = "registration"
+	webAuthnPurposeLogin        = "login"
+	webAuthnPurposeMFA          = "mfa"
+)
+
+const (
+	defaultPasskeyName = "Passkey"
+	maxPasskeyNameLen  = 64
+)
+
+// WebAuthnOptions configures the relying party. RPID is the domain passkeys are bound to and
+// Origins the frontend origins allowed to use them. A ceremony must finish within SessionTTL.
+type WebAuthnOptions struct {
+	RPID       string
+	RPName     string
+	Origins    []string
+	SessionTTL time.Duration
+}
+
+// WebAuthnCeremony is handed to the browser. Options is passed to navigator.credentials and
+// SessionID identifies the stored challenge when the result comes back.
+type WebAuthnCeremony struct {
+	SessionID string
+	Options   interface{}
+}
+
+// PasskeyLogin is the result of a passkey login. UserVerified reports whether the authenticator
+// checked a PIN or biometric, which makes the passkey a second factor on its own.
+type PasskeyLogin struct {
+	User         *entities.User
+	UserVerified bool
+}
+
+type WebAuthnService interface {
+	BeginRegistration(ctx context.Context, userID uuid.UUID) (*WebAuthnCeremony, error)
+	FinishRegistration(ctx context.Context, userID uuid.UUID, req *models.RegisterPasskeyRequest) (*entities.WebAuthnCredential, error)
+	BeginLogin(ctx context.Context) (*WebAuthnCeremony, error)
+	FinishLogin(ctx context.Context, req *models.PasskeyLoginRequest) (*PasskeyLogin, error)
+	BeginSecondFactor(ctx context.Context, userID uuid.UUID) (*WebAuthnCeremony, error)
+	VerifySecondFactor(ctx context.Context, userID uuid.UUID, sessionID string, credential json.RawMessage) error
+	ListCredentials(ctx context.Context, userID uuid.UUID) ([]entities.WebAuthnCredential, error)
+	DeleteCredential(ctx context.Context, userID uuid.UUID, id uuid.UUID) error
+}
+
+type WebAuthnServiceImpl struct {
+	webAuthn     *webauthn.WebAuthn
+	webAuthnRepo repositories.WebAuthnRepository
+	sessionRepo  repositories.WebAuthnSessionRepository
+	userRepo     repositories.UserRepository
+	identityRepo repositories.IdentityRepository
+	sessionTTL   time.Duration
+	log          *logrus.Logger
+}
+
+func NewWebAuthnService(
+	webAuthnRepo repositories.WebAuthnRepository,
+	sessionRepo repositories.WebAuthnSessionRepository,
+	userRepo repositories.UserRepository,
+	identityRepo repositories.IdentityRepository,
+	opts WebAuthnOptions,
+	log *logrus.Logger,
+) (WebAuthnService, error) {
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          opts.RPID,
+		RPDisplayName: opts.RPName,
+		RPOrigins:     opts.Origins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: opts.SessionTTL, TimeoutUVD: opts.SessionTTL},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: opts.SessionTTL, TimeoutUVD: opts.SessionTTL},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("service: invalid webauthn configuration: %w", err)
+	}
+
+	return &WebAuthnServiceImpl{
+		webAuthn:     webAuthn,
+		webAuthnRepo: webAuthnRepo,
+		sessionRepo:  sessionRepo,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		sessionTTL:   opts.SessionTTL,
+		log:          log,
+	}, nil
+}
+
+// BeginRegistration returns the creation options for a new passkey of the user. Keys the user
+// already registered are excluded, so the same authenticator is not added twice.
+func (s *WebAuthnServiceImpl) BeginRegistration(ctx context.Context, userID uuid.UUID) (*WebAuthnCeremony, error) {
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to begin passkey registration: %w", err)
+	}
+
+	return s.saveSession(ctx, webAuthnPurposeRegistration, userID, session, creation)
+}
+
+func (s *WebAuthnServiceImpl) FinishRegistration(ctx context.Context, userID uuid.UUID, req *models.RegisterPasskeyRequest) (*entities.WebAuthnCredential, error) {
+	name := strings.TrimSpace(req.Name)
+	if len(name) > maxPasskeyNameLen {
+		return nil, fmt.Errorf("%w: name is longer than %d characters", apperrors.ErrInvalidRequestPayload, maxPasskeyNameLen)
+	}
+	if name == "" {
+		name = defaultPasskeyName
+	}
+
+	session, err := s.takeSession(ctx, req.SessionID, webAuthnPurposeRegistration, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", apperrors.ErrPasskeyRegistration, err)
+	}
+
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webAuthn.CreateCredential(user, session.Data, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", apperrors.ErrPasskeyRegistration, err)
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	created, err := s.webAuthnRepo.CreateCredential(ctx, &db.CreateWebauthnCredentialParams{
+		UserID:          userID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Aaguid:          credential.Authenticator.AAGUID,
+		SignCount:       int64(credential.Authenticator.SignCount),
+		Transports:      transports,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		Name:            name,
+	})
+	if err != nil {
+		if errors.Is(err, apperrors.ErrPasskeyExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("service: failed to register passkey: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"passkey_id": created.ID,
+	}).Info("Passkey registered")
+
+	return toWebAuthnCredential(created), nil
+}
+
+// BeginLogin starts a passkey login. The browser offers the passkeys it holds for the relying
+// party, so no username is needed and none is revealed.
+func (s *WebAuthnServiceImpl) BeginLogin(ctx context.Context) (*WebAuthnCeremony, error) {
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to begin passkey login: %w", err)
+	}
+
+	return s.saveSession(ctx, webAuthnPurposeLogin, uuid.Nil, session, assertion)
+}
+
+func (s *WebAuthnServiceImpl) FinishLogin(ctx context.Context, req *models.PasskeyLoginRequest) (*PasskeyLogin, error) {
+	session, err := s.takeSession(ctx, req.SessionID, webAuthnPurposeLogin, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		return nil, s.rejectAssertion(uuid.Nil, err)
+	}
+
+	var found *webAuthnUser
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		stored, err := s.webAuthnRepo.GetCredential(ctx, rawID)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(stored.UserID[:], userHandle) {
+			return nil, errors.New("user handle does not match the credential owner")
+		}
+
+		found, err = s.loadUser(ctx, stored.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return found, nil
+	}
+
+	_, credential, err := s.webAuthn.ValidatePasskeyLogin(handler, session.Data, parsed)
+	if err != nil {
+		if found != nil {
+			return nil, s.rejectAssertion(found.user.ID, err)
+		}
+		return nil, s.rejectAssertion(uuid.Nil, err)
+	}
+
+	if err := s.recordUse(ctx, found, credential); err != nil {
+		return nil, err
+	}
+
+	return &PasskeyLogin{
+		User:         found.user,
+		UserVerified: credential.Flags.UserVerified,
+	}, nil
+}
+
+// BeginSecondFactor returns the request options for a user who passed the first login step and
+// answers the MFA challenge with one of their passkeys.
+func (s *WebAuthnServiceImpl) BeginSecondFactor(ctx context.Context, userID uuid.UUID) (*WebAuthnCeremony, error) {
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(user.credentials) == 0 {
+		return nil, fmt.Errorf("%w: no passkey registered", apperrors.ErrInvalidPasskey)
+	}
+
+	assertion, session, err := s.webAuthn.BeginLogin(user)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to begin passkey challenge: %w", err)
+	}
+
+	return s.saveSession(ctx, webAuthnPurposeMFA, userID, session, assertion)
+}
+
+func (s *WebAuthnServiceImpl) VerifySecondFactor(ctx context.Context, userID uuid.UUID, sessionID string, credentialJSON json.RawMessage) error {
+	session, err := s.takeSession(ctx, sessionID, webAuthnPurposeMFA, userID)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(credentialJSON)
+	if err != nil {
+		return s.rejectAssertion(userID, err)
+	}
+
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	credential, err := s.webAuthn.ValidateLogin(user, session.Data, parsed)
+	if err != nil {
+		return s.rejectAssertion(userID, err)
+	}
+
+	return s.recordUse(ctx, user, credential)
+}
+
+func (s *WebAuthnServiceImpl) ListCredentials(ctx context.Context, userID uuid.UUID) ([]entities.WebAuthnCredential, error) {
+	rows, err := s.webAuthnRepo.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list passkeys: %w", err)
+	}
+
+	credentials := make([]entities.WebAuthnCredential, 0, len(rows))
+	for i := range rows {
+		credentials = append(credentials, *toWebAuthnCredential(&rows[i]))
+	}
+	return credentials, nil
+}
+
+// DeleteCredential removes a passkey. An account without a password keeps at least one way to
+// sign in, a passkey or a linked identity.
+func (s *WebAuthnServiceImpl) DeleteCredential(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	userDB, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.ErrUserNotFound
+		}
+		return fmt.Errorf("service: failed to get user: %w", err)
+	}
+
+	if userDB.Password == "" {
+		passkeys, err := s.webAuthnRepo.CountCredentials(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("service: failed to remove passkey: %w", err)
+		}
+		identities, err := s.identityRepo.CountIdentities(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("service: failed to remove passkey: %w", err)
+		}
+		if passkeys+identities <= 1 {
+			return apperrors.ErrLastSignInMethod
+		}
+	}
+
+	deleted, err := s.webAuthnRepo.DeleteCredential(ctx, &db.DeleteWebauthnCredentialParams{ID: id, UserID: userID})
+	if err != nil {
+		return fmt.Errorf("service: failed to remove passkey: %w", err)
+	}
+	if !deleted {
+		return fmt.Errorf("%w: passkey %s", apperrors.ErrNotFound, id)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"passkey_id": id,
+	}).Info("Passkey removed")
+
+	return nil
+}
+
+func (s *WebAuthnServiceImpl) saveSession(ctx context.Context, purpose string, userID uuid.UUID, data *webauthn.SessionData, options interface{}) (*WebAuthnCeremony, error) {
+	sessionID, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to generate webauthn session: %w", err)
+	}
+
+	err = s.sessionRepo.SaveSession(ctx, sessionID, &models.WebAuthnSession{
+		Purpose: purpose,
+		UserID:  userID,
+		Data:    *data,
+	}, s.sessionTTL)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to store webauthn session: %w", err)
+	}
+
+	return &WebAuthnCeremony{SessionID: sessionID, Options: options}, nil
+}
+
+// takeSession consumes the session and checks it was started for the same purpose and user.
+func (s *WebAuthnServiceImpl) takeSession(ctx context.Context, sessionID string, purpose string, userID uuid.UUID) (*models.WebAuthnSession, error) {
+	if sessionID == "" {
+		return nil, apperrors.ErrInvalidPasskey
+	}
+
+	session, err := s.sessionRepo.TakeSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Purpose != purpose || session.UserID != userID {
+		return nil, apperrors.ErrInvalidPasskey
+	}
+	return session, nil
+}
+
+// recordUse stores the new signature counter. A counter that did not increase means the key
+// may have been cloned, such an assertion is refused.
+func (s *WebAuthnServiceImpl) recordUse(ctx context.Context, user *webAuthnUser, credential *webauthn.Credential) error {
+	stored := user.stored(credential.ID)
+	if stored == nil {
+		return apperrors.ErrInvalidPasskey
+	}
+
+	if credential.Authenticator.CloneWarning {
+		s.log.WithFields(logrus.Fields{
+			"user_id":    user.user.ID,
+			"passkey_id": stored.ID,
+		}).Warn("Passkey signature counter did not increase, possible cloned authenticator")
+		return apperrors.ErrInvalidPasskey
+	}
+
+	err := s.webAuthnRepo.UpdateCredentialUsage(ctx, &db.UpdateWebauthnCredentialUsageParams{
+		ID:          stored.ID,
+		SignCount:   int64(credential.Authenticator.SignCount),
+		BackupState: credential.Flags.BackupState,
+	})
+	if err != nil {
+		return fmt.Errorf("service: failed to update passkey: %w", err)
+	}
+	return nil
+}
+
+// rejectAssertion logs why an assertion failed and returns the generic error, the details are
+// of no use to an attacker's client.
+func (s *WebAuthnServiceImpl) rejectAssertion(userID uuid.UUID, err error) error {
+	entry := s.log.WithError(err)
+	if userID != uuid.Nil {
+		entry = entry.WithField("user_id", userID)
+	}
+	entry.Warn("Passkey assertion rejected")
+	return apperrors.ErrInvalidPasskey
+}
+
+func (s *WebAuthnServiceImpl) loadUser(ctx context.Context, userID uuid.UUID) (*webAuthnUser, error) {
+	userDB, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("service: failed to get user: %w", err)
+	}
+
+	rows, err := s.webAuthnRepo.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to load passkeys: %w", err)
+	}
+
+	user := &webAuthnUser{user: toDomainUser(userDB), rows: rows}
+	for _, row := range rows {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(row.Transports))
+		for _, transport := range row.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+
+		user.credentials = append(user.credentials, webauthn.Credential{
+			ID:              row.CredentialID,
+			PublicKey:       row.PublicKey,
+			AttestationType: row.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: row.BackupEligible,
+				BackupState:    row.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    row.Aaguid,
+				SignCount: uint32(row.SignCount),
+			},
+		})
+	}
+	return user, nil
+}
+
+// webAuthnUser adapts a user and their stored credentials to webauthn.User. The user handle is
+// the user ID, it carries no personal data.
+type webAuthnUser struct {
+	user        *entities.User
+	rows        []db.WebauthnCredential
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Name
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func (u *webAuthnUser) stored(credentialID []byte) *db.WebauthnCredential {
+	for i := range u.rows {
+		if bytes.Equal(u.rows[i].CredentialID, credentialID) {
+			return &u.rows[i]
+		}
+	}
+	return nil
+}
+
+func toWebAuthnCredential(row *db.WebauthnCredential) *entities.WebAuthnCredential {
+	credential := &entities.WebAuthnCredential{
+		ID:             row.ID,
+		UserID:         row.UserID,
+		CredentialID:   row.CredentialID,
+		Name:           row.Name,
+		Transports:     row.Transports,
+		BackupEligible: row.BackupEligible,
+		BackupState:    row.BackupState,
+		CreatedAt:      row.CreatedAt,
+	}
+	if row.LastUsedAt.Valid {
+		credential.LastUsedAt = &row.LastUsedAt.Time
+	}
+	return credential
+}
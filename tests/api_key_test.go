@@ -0,0 +1,71 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	authpb "github.com/RehanAthallahAzhar/shopeezy-protos/pb/auth"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	authgrpc "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/grpc"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+func TestLogoutRefusesAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	f := newLoginFixture(t, services.LoginThrottleOptions{MaxAttempts: 10, IPMaxAttempts: 100, AttemptWindow: time.Hour})
+	user := f.account(t, "jane")
+
+	keys := map[string]string{
+		"key without expiry": f.apiKeys.add(t, user.ID, sql.NullTime{}, entities.PermUsersRead),
+		"key with expiry":    f.apiKeys.add(t, user.ID, sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}, entities.PermUsersRead),
+	}
+	for name, key := range keys {
+		t.Run(name, func(t *testing.T) {
+			if err := f.service.Logout(ctx, "Bearer "+key); !errors.Is(err, apperrors.ErrAPIKeyLogout) {
+				t.Fatalf("Logout error = %v, want ErrAPIKeyLogout", err)
+			}
+			if valid, msg := f.validate(t, key); !valid {
+				t.Fatalf("key was rejected after the refused logout: %s", msg)
+			}
+		})
+	}
+
+	session, err := f.tokens.GenerateTokenPair(ctx, user, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if err := f.service.Logout(ctx, "Bearer "+session.AccessToken); err != nil {
+		t.Fatalf("Logout of a session: %v", err)
+	}
+	if valid, _ := f.validate(t, session.AccessToken); valid {
+		t.Fatal("session token is still valid after logout")
+	}
+}
+
+func TestValidateTokenDoesNotLogTheKey(t *testing.T) {
+	f := newTokenFixture(t)
+	user := f.user("jane")
+	key := f.apiKeys.add(t, user.ID, sql.NullTime{})
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	res, err := authgrpc.NewAuthServer(f.tokens).ValidateToken(context.Background(), &authpb.ValidateTokenRequest{Token: key})
+	if err != nil || !res.GetIsValid() {
+		t.Fatalf("ValidateToken = %v, %v, want the key accepted", res, err)
+	}
+	if strings.Contains(out.String(), key) {
+		t.Fatalf("the API key was logged: %s", out.String())
+	}
+}
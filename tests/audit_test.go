@@ -0,0 +1,244 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/middlewares"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/audit"
+)
+
+func TestLoginsAreAuditedWithTheRequest(t *testing.T) {
+	f := newLoginFixture(t, services.LoginThrottleOptions{
+		MaxAttempts:        10,
+		IPMaxAttempts:      100,
+		AttemptWindow:      time.Hour,
+		LockoutDuration:    time.Minute,
+		MaxLockoutDuration: time.Hour,
+	})
+	user := f.account(t, "jane")
+
+	// The middleware order of cmd/web
+	e := echo.New()
+	e.Use(middleware.RequestID())
+	e.Use(middlewares.AuditContextMiddleware())
+	e.POST("/login", func(c echo.Context) error {
+		var req models.UserLoginRequest
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		req.IPAddress = c.RealIP()
+		if _, err := f.service.Login(c.Request().Context(), &req); err != nil {
+			return c.NoContent(http.StatusUnauthorized)
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	login := func(username, password string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"username":"`+username+`","password":"`+password+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXRealIP, "198.51.100.7")
+		req.Header.Set("User-Agent", "audit-test/1.0")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Header().Get(echo.HeaderXRequestID)
+	}
+
+	tests := []struct {
+		name       string
+		username   string
+		password   string
+		wantAction string
+		wantActor  uuid.UUID
+		wantTarget uuid.UUID
+		wantReason string
+	}{
+		{name: "success", username: user.Username, password: testPassword, wantAction: entities.AuditLoginSucceeded, wantActor: user.ID, wantTarget: user.ID},
+		{name: "wrong password", username: user.Username, password: "wrong-password", wantAction: entities.AuditLoginFailed, wantTarget: user.ID, wantReason: entities.AuditReasonInvalidCredentials},
+		{name: "unknown user", username: "nobody", password: "wrong-password", wantAction: entities.AuditLoginFailed, wantReason: entities.AuditReasonInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(f.audit.events)
+			requestID := login(tt.username, tt.password)
+			if len(f.audit.events) != before+1 {
+				t.Fatalf("%d events recorded, want 1", len(f.audit.events)-before)
+			}
+
+			event := f.audit.events[before]
+			if event.Action != tt.wantAction {
+				t.Fatalf("action = %q, want %q", event.Action, tt.wantAction)
+			}
+			if event.ActorID.UUID != tt.wantActor || event.ActorID.Valid != (tt.wantActor != uuid.Nil) {
+				t.Fatalf("actor = %+v, want %s", event.ActorID, tt.wantActor)
+			}
+			if event.TargetUserID.UUID != tt.wantTarget || event.TargetUserID.Valid != (tt.wantTarget != uuid.Nil) {
+				t.Fatalf("target = %+v, want %s", event.TargetUserID, tt.wantTarget)
+			}
+			if event.IpAddress != "198.51.100.7" || event.UserAgent != "audit-test/1.0" || requestID == "" || event.RequestID != requestID {
+				t.Fatalf("request details %q, %q, %q, want the client IP, user agent and request ID %q", event.IpAddress, event.UserAgent, event.RequestID, requestID)
+			}
+
+			var metadata map[string]string
+			if err := json.Unmarshal(event.Metadata, &metadata); err != nil {
+				t.Fatalf("metadata %s: %v", event.Metadata, err)
+			}
+			if metadata["reason"] != tt.wantReason {
+				t.Fatalf("reason = %q, want %q", metadata["reason"], tt.wantReason)
+			}
+			if strings.Contains(string(event.Metadata), tt.password) {
+				t.Fatal("the password was written to the audit log")
+			}
+		})
+	}
+}
+
+func TestAuditRecorder(t *testing.T) {
+	f := newTokenFixture(t)
+	admin, jane := uuid.New(), uuid.New()
+	recorder := audit.NewRecorder(f.audit, f.log)
+
+	request := audit.WithRequest(context.Background(), audit.RequestInfo{
+		IPAddress: "203.0.113.4",
+		UserAgent: strings.Repeat("a", 600),
+		RequestID: "req-1",
+	})
+	cancelled, cancel := context.WithCancel(audit.WithActor(request, admin))
+	cancel()
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		event        audit.Event
+		wantActor    uuid.UUID
+		wantMetadata string
+	}{
+		{name: "actor from the context", ctx: audit.WithActor(request, admin), event: audit.Event{Action: entities.AuditRoleGranted, TargetUserID: jane, Metadata: map[string]string{"role": "admin"}}, wantActor: admin, wantMetadata: `{"role":"admin"}`},
+		{name: "explicit actor", ctx: audit.WithActor(request, admin), event: audit.Event{Action: entities.AuditLoginSucceeded, ActorID: jane, TargetUserID: jane}, wantActor: jane, wantMetadata: `{}`},
+		{name: "no actor", ctx: request, event: audit.Event{Action: entities.AuditLoginFailed}, wantMetadata: `{}`},
+		{name: "client went away", ctx: cancelled, event: audit.Event{Action: entities.AuditLogout, TargetUserID: admin}, wantActor: admin, wantMetadata: `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(f.audit.events)
+			recorder.Record(tt.ctx, tt.event)
+			if len(f.audit.events) != before+1 {
+				t.Fatal("the event was not recorded")
+			}
+
+			event := f.audit.events[before]
+			if event.Action != tt.event.Action || event.ActorID.UUID != tt.wantActor || event.ActorID.Valid != (tt.wantActor != uuid.Nil) {
+				t.Fatalf("recorded %s by %+v, want %s by %s", event.Action, event.ActorID, tt.event.Action, tt.wantActor)
+			}
+			if event.IpAddress != "203.0.113.4" || event.RequestID != "req-1" || len(event.UserAgent) != 512 {
+				t.Fatalf("request details %q, %q and a %d character user agent, want them from the context with the user agent cut at 512", event.IpAddress, event.RequestID, len(event.UserAgent))
+			}
+			if string(event.Metadata) != tt.wantMetadata {
+				t.Fatalf("metadata = %s, want %s", event.Metadata, tt.wantMetadata)
+			}
+		})
+	}
+
+	// An unavailable audit log does not fail the action
+	f.audit.err = errors.New("connection refused")
+	recorder.Record(request, audit.Event{Action: entities.AuditLogout})
+}
+
+func TestAuditLogPaging(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	repo := f.audit
+	svc := services.NewAuditService(repo, f.log)
+	jane, john := uuid.New(), uuid.New()
+
+	// Pairs of events share a timestamp, so pages have to break ties on the id
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var janeEvents []uuid.UUID
+	for i := 0; i < 9; i++ {
+		target := jane
+		if i%3 == 2 {
+			target = john
+		}
+		id := repo.add(entities.AuditLoginSucceeded, target, start.Add(time.Duration(i/2)*time.Minute))
+		if target == jane {
+			janeEvents = append(janeEvents, id)
+		}
+	}
+
+	var (
+		seen   []uuid.UUID
+		cursor string
+		pages  int
+	)
+	for {
+		page, err := svc.ListEvents(ctx, &models.AuditEventQuery{Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("ListEvents: %v", err)
+		}
+		for _, event := range page.Events {
+			if len(seen) > 0 {
+				last := repo.find(seen[len(seen)-1])
+				if event.CreatedAt.After(last.CreatedAt) {
+					t.Fatal("events are not listed newest first")
+				}
+			}
+			seen = append(seen, event.ID)
+		}
+		pages++
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if pages != 5 || len(seen) != 9 {
+		t.Fatalf("%d events on %d pages, want 9 on 5", len(seen), pages)
+	}
+	slices.SortFunc(seen, func(a, b uuid.UUID) int { return bytes.Compare(a[:], b[:]) })
+	if len(slices.Compact(seen)) != 9 {
+		t.Fatal("an event was listed twice")
+	}
+
+	activity, err := svc.ListUserActivity(ctx, jane, &models.AuditEventQuery{TargetUserID: john.String()})
+	if err != nil {
+		t.Fatalf("ListUserActivity: %v", err)
+	}
+	if len(activity.Events) != len(janeEvents) || activity.NextCursor != "" {
+		t.Fatalf("activity has %d events, want the %d about the user", len(activity.Events), len(janeEvents))
+	}
+	for _, event := range activity.Events {
+		if event.TargetUserID == nil || *event.TargetUserID != jane {
+			t.Fatalf("activity lists an event about %v", event.TargetUserID)
+		}
+	}
+
+	for name, query := range map[string]models.AuditEventQuery{
+		"limit too large":     {Limit: 201},
+		"malformed actor":     {ActorID: "jane"},
+		"malformed timestamp": {CreatedFrom: "yesterday"},
+		"malformed cursor":    {Cursor: "not-a-cursor"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := svc.ListEvents(ctx, &query); !errors.Is(err, apperrors.ErrInvalidRequestPayload) {
+				t.Fatalf("ListEvents error = %v, want ErrInvalidRequestPayload", err)
+			}
+		})
+	}
+}
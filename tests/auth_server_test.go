@@ -0,0 +1,64 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	authpb "github.com/RehanAthallahAzhar/shopeezy-protos/pb/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	authgrpc "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/grpc"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+func TestValidateTokenRefusesScopedTokensToCallersThatIgnoreScopes(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	user := f.user("jane")
+	server := authgrpc.NewAuthServer(f.tokens)
+
+	posToken, _, err := f.tokens.GenerateScopedToken(ctx, user, entities.POSScopes, time.Minute, token.IssueOptions{AMR: []string{entities.AMRPIN}})
+	if err != nil {
+		t.Fatalf("GenerateScopedToken: %v", err)
+	}
+	userInfoToken, _, err := f.tokens.GenerateUserInfoToken(ctx, user, []string{entities.ScopeOpenID}, time.Minute, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateUserInfoToken: %v", err)
+	}
+	pair, err := f.tokens.GenerateTokenPair(ctx, user, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+
+	acceptsScoped := metadata.NewIncomingContext(ctx, metadata.Pairs(authgrpc.AcceptScopedHeader, "true"))
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		token string
+		valid bool
+		// code is only checked when set
+		code codes.Code
+	}{
+		{name: "session token", ctx: ctx, token: pair.AccessToken, valid: true},
+		{name: "POS token to a caller ignoring scopes", ctx: ctx, token: posToken, code: codes.PermissionDenied},
+		{name: "POS token to a caller enforcing scopes", ctx: acceptsScoped, token: posToken, valid: true},
+		{name: "userinfo token", ctx: acceptsScoped, token: userInfoToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := server.ValidateToken(tt.ctx, &authpb.ValidateTokenRequest{Token: tt.token})
+			if valid := err == nil && res.GetIsValid(); valid != tt.valid {
+				t.Fatalf("ValidateToken valid = %t, want %t (%v)", valid, tt.valid, err)
+			}
+			if code := status.Code(err); tt.code != codes.OK && code != tt.code {
+				t.Fatalf("ValidateToken code = %s, want %s", code, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,850 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/consumers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/handlers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/rabbitmq"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+const (
+	testCampaignBatchSize  = 2
+	testCampaignStaleAfter = time.Minute
+	testUnsubscribeURL     = "https://shop.example.com/unsubscribe"
+	testSellerRole         = "seller"
+)
+
+type campaignFixture struct {
+	*tokenFixture
+	prefs     *fakePreferenceRepository
+	campaigns *fakeCampaignRepository
+	publisher *fakeEventPublisher
+	outbox    *fakeNotifier
+	service   services.CampaignService
+	consumer  *consumers.CampaignDeliveryConsumer
+	// delivered counts the published batches that were handed to the consumer
+	delivered int
+}
+
+func newCampaignFixture(t *testing.T) *campaignFixture {
+	t.Helper()
+
+	f := &campaignFixture{
+		tokenFixture: newTokenFixture(t),
+		prefs:        newFakePreferenceRepository(),
+		publisher:    &fakeEventPublisher{},
+		outbox:       &fakeNotifier{},
+	}
+	f.campaigns = newFakeCampaignRepository(f.users, f.roles, f.prefs)
+	f.service = services.NewCampaignService(f.campaigns, f.users, f.prefs, f.tokens, f.outbox, f.publisher, validator.New(),
+		services.CampaignOptions{
+			BatchSize:      testCampaignBatchSize,
+			StaleAfter:     testCampaignStaleAfter,
+			UnsubscribeURL: testUnsubscribeURL,
+			UnsubscribeTTL: 24 * time.Hour,
+		}, f.log)
+	f.consumer = consumers.NewCampaignDeliveryConsumer(f.service, f.log)
+	return f
+}
+
+// verified returns a user with a verified email.
+func (f *campaignFixture) verified(t *testing.T, name string) *entities.User {
+	t.Helper()
+
+	user := f.user(name)
+	if _, err := f.users.MarkEmailVerified(context.Background(), &db.MarkUserEmailVerifiedParams{ID: user.ID, Email: user.Email}); err != nil {
+		t.Fatalf("MarkEmailVerified: %v", err)
+	}
+	return user
+}
+
+func (f *campaignFixture) setMarketing(t *testing.T, userID uuid.UUID, enabled bool) {
+	t.Helper()
+	if _, err := f.prefs.UpsertPreferences(context.Background(), &db.UpsertUserPreferencesParams{UserID: userID, MarketingEmails: enabled}); err != nil {
+		t.Fatalf("UpsertPreferences: %v", err)
+	}
+}
+
+// create stores a draft campaign for the segment.
+func (f *campaignFixture) create(t *testing.T, segment models.CampaignSegmentRequest) uuid.UUID {
+	t.Helper()
+
+	campaign, err := f.service.CreateCampaign(context.Background(), uuid.New(), &models.CreateCampaignRequest{
+		Name:    "Spring sale",
+		Subject: "Hi {{.Name}}",
+		Body:    "Everything is on sale. Unsubscribe: {{.UnsubscribeURL}}",
+		Segment: segment,
+	})
+	if err != nil {
+		t.Fatalf("CreateCampaign: %v", err)
+	}
+	return campaign.ID
+}
+
+// start creates a campaign for the segment and schedules it right away.
+func (f *campaignFixture) start(t *testing.T, segment models.CampaignSegmentRequest) uuid.UUID {
+	t.Helper()
+
+	id := f.create(t, segment)
+	if _, err := f.service.ScheduleCampaign(context.Background(), id, &models.ScheduleCampaignRequest{}); err != nil {
+		t.Fatalf("ScheduleCampaign: %v", err)
+	}
+	return id
+}
+
+func (f *campaignFixture) dispatch(t *testing.T, want int) {
+	t.Helper()
+	if dispatched, err := f.service.DispatchDue(context.Background()); err != nil || dispatched != want {
+		t.Fatalf("DispatchDue = %d, %v, want %d campaigns", dispatched, err, want)
+	}
+}
+
+// deliver hands the batches published since the last call to the consumer.
+func (f *campaignFixture) deliver(t *testing.T) {
+	t.Helper()
+
+	f.publisher.mu.Lock()
+	published := slices.Clone(f.publisher.published[f.delivered:])
+	f.publisher.mu.Unlock()
+	f.delivered += len(published)
+
+	for _, msg := range published {
+		if msg.routingKey != entities.CampaignDeliveryRoutingKey {
+			t.Fatalf("batch published with routing key %q", msg.routingKey)
+		}
+		if err := f.consumer.Handle(context.Background(), amqp.Delivery{MessageId: msg.messageID, Body: msg.body}); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+	}
+}
+
+// mailed counts the campaign mails sent per address.
+func (f *campaignFixture) mailed() map[string]int {
+	f.outbox.mu.Lock()
+	defer f.outbox.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, msg := range f.outbox.sent {
+		counts[msg.To]++
+	}
+	return counts
+}
+
+func (f *campaignFixture) status(t *testing.T, id uuid.UUID) *entities.Campaign {
+	t.Helper()
+	campaign, err := f.service.GetCampaign(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetCampaign: %v", err)
+	}
+	return campaign
+}
+
+// unsubscribeToken returns the token of the List-Unsubscribe link of the last mail to email.
+func (f *campaignFixture) unsubscribeToken(t *testing.T, email string) string {
+	t.Helper()
+
+	msg, ok := f.outbox.last(email)
+	if !ok {
+		t.Fatalf("no campaign mail was sent to %s", email)
+	}
+	link, err := url.Parse(strings.Trim(msg.Headers["List-Unsubscribe"], "<>"))
+	if err != nil || link.Query().Get("token") == "" {
+		t.Fatalf("mail to %s has no unsubscribe link: %+v", email, msg.Headers)
+	}
+	if !strings.Contains(msg.Body, link.String()) {
+		t.Fatalf("the body of the mail to %s does not carry its unsubscribe link", email)
+	}
+	return link.Query().Get("token")
+}
+
+func TestCampaignAudienceFollowsOptInAndSegment(t *testing.T) {
+	signedUp := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	verified := true
+
+	tests := []struct {
+		name    string
+		segment models.CampaignSegmentRequest
+		want    []string
+	}{
+		{name: "everybody", want: []string{"jane", "ana", "max"}},
+		{name: "verified", segment: models.CampaignSegmentRequest{EmailVerified: &verified}, want: []string{"jane", "ana"}},
+		{name: "role", segment: models.CampaignSegmentRequest{Roles: []string{testSellerRole, " ", testSellerRole}}, want: []string{"ana"}},
+		{name: "signed up after", segment: models.CampaignSegmentRequest{SignedUpAfter: signedUp.Format(time.RFC3339)}, want: []string{"ana", "max"}},
+		{name: "signed up before", segment: models.CampaignSegmentRequest{SignedUpBefore: signedUp.Format(time.RFC3339)}, want: []string{"jane"}},
+		{name: "nobody", segment: models.CampaignSegmentRequest{Roles: []string{"wholesaler"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newCampaignFixture(t)
+
+			users := map[string]*entities.User{
+				"jane": f.verified(t, "jane"),
+				"ana":  f.verified(t, "ana"),
+				"max":  f.user("max"),
+				"john": f.verified(t, "john"),
+			}
+			f.roles.grant(users["ana"].ID, testSellerRole)
+			f.roles.grant(users["john"].ID, testSellerRole)
+			f.users.mu.Lock()
+			f.users.byID[users["jane"].ID].CreatedAt = signedUp.Add(-time.Hour)
+			f.users.byID[users["ana"].ID].CreatedAt = signedUp
+			f.users.byID[users["max"].ID].CreatedAt = signedUp.Add(time.Hour)
+			f.users.byID[users["john"].ID].CreatedAt = signedUp.Add(time.Hour)
+			f.users.mu.Unlock()
+			// john turned off marketing emails, no segment reaches him
+			f.setMarketing(t, users["john"].ID, false)
+
+			id := f.start(t, tt.segment)
+			preview, err := f.service.PreviewCampaign(ctx, id, users["jane"].ID)
+			if err != nil {
+				t.Fatalf("PreviewCampaign: %v", err)
+			}
+			if preview.RecipientCount != int64(len(tt.want)) {
+				t.Fatalf("preview counts %d recipients, want %d", preview.RecipientCount, len(tt.want))
+			}
+
+			f.dispatch(t, 1)
+			f.deliver(t)
+
+			want := make(map[string]int)
+			for _, name := range tt.want {
+				want[users[name].Email] = 1
+			}
+			if got := f.mailed(); !maps.Equal(got, want) {
+				t.Fatalf("mailed %v, want %v", got, want)
+			}
+			if campaign := f.status(t, id); campaign.Status != entities.CampaignStatusSent || campaign.RecipientCount != int32(len(tt.want)) {
+				t.Fatalf("campaign is %s with %d recipients, want sent with %d", campaign.Status, campaign.RecipientCount, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestCampaignSkipsRecipientsThatLeftAfterTheDispatch(t *testing.T) {
+	f := newCampaignFixture(t)
+	jane := f.verified(t, "jane")
+	john := f.verified(t, "john")
+	max := f.verified(t, "max")
+
+	id := f.start(t, models.CampaignSegmentRequest{})
+	f.dispatch(t, 1)
+
+	f.setMarketing(t, john.ID, false)
+	f.users.mu.Lock()
+	delete(f.users.byID, max.ID)
+	f.users.mu.Unlock()
+	f.deliver(t)
+
+	if got, want := f.mailed(), map[string]int{jane.Email: 1}; !maps.Equal(got, want) {
+		t.Fatalf("mailed %v, want %v", got, want)
+	}
+
+	wantReasons := map[uuid.UUID]string{john.ID: "unsubscribed", max.ID: "user deleted"}
+	for _, d := range f.campaigns.deliveriesOf(id) {
+		reason, skipped := wantReasons[d.UserID]
+		switch {
+		case skipped && (d.Status != entities.DeliveryStatusSkipped || d.Error != reason):
+			t.Fatalf("delivery to %s is %s (%q), want skipped (%q)", d.Email, d.Status, d.Error, reason)
+		case !skipped && d.Status != entities.DeliveryStatusSent:
+			t.Fatalf("delivery to %s is %s, want sent", d.Email, d.Status)
+		}
+	}
+
+	campaign := f.status(t, id)
+	if campaign.Status != entities.CampaignStatusSent {
+		t.Fatalf("campaign is %s, want sent", campaign.Status)
+	}
+	if campaign.Deliveries[entities.DeliveryStatusSent] != 1 || campaign.Deliveries[entities.DeliveryStatusSkipped] != 2 {
+		t.Fatalf("deliveries = %v, want 1 sent and 2 skipped", campaign.Deliveries)
+	}
+}
+
+func TestCampaignUnsubscribeLink(t *testing.T) {
+	ctx := context.Background()
+	f := newCampaignFixture(t)
+	jane := f.verified(t, "jane")
+	john := f.verified(t, "john")
+
+	f.start(t, models.CampaignSegmentRequest{})
+	f.dispatch(t, 1)
+	f.deliver(t)
+	unsubscribe := f.unsubscribeToken(t, jane.Email)
+
+	api := &handlers.UserHandler{PreferenceService: services.NewPreferenceService(f.prefs, f.users, f.tokens, f.log)}
+	e := newTestRouter(t, api, f.tokens)
+	post := func(tokenValue string) int {
+		body, _ := json.Marshal(models.UnsubscribeRequest{Token: tokenValue})
+		return serve(e, http.MethodPost, "/api/v1/accounts/unsubscribe", "", string(body)).Code
+	}
+
+	magicLink, _, err := f.tokens.GeneratePurposeToken(ctx, token.PurposeSubject{UserID: jane.ID, Email: jane.Email}, token.PurposeMagicLink, time.Hour)
+	if err != nil {
+		t.Fatalf("GeneratePurposeToken: %v", err)
+	}
+	rejected := []struct {
+		name  string
+		token string
+	}{
+		{name: "missing", token: ""},
+		{name: "garbage", token: "not-a-token"},
+		{name: "preview", token: "preview"},
+		{name: "magic link", token: magicLink},
+		{name: "access token", token: f.accessToken(t, jane)},
+		{name: "tampered", token: unsubscribe[:len(unsubscribe)-2] + "xx"},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := post(tt.token); code != http.StatusUnauthorized {
+				t.Fatalf("unsubscribe got %d, want %d", code, http.StatusUnauthorized)
+			}
+			if prefs, _ := f.prefs.GetPreferences(ctx, jane.ID); !prefs.MarketingEmails {
+				t.Fatal("a rejected token unsubscribed the user")
+			}
+		})
+	}
+
+	// The link keeps working after the first click
+	for i := 0; i < 2; i++ {
+		if code := post(unsubscribe); code != http.StatusOK {
+			t.Fatalf("unsubscribe %d got %d, want %d", i+1, code, http.StatusOK)
+		}
+	}
+	if prefs, _ := f.prefs.GetPreferences(ctx, jane.ID); prefs.MarketingEmails {
+		t.Fatal("the link did not unsubscribe the user")
+	}
+	if prefs, _ := f.prefs.GetPreferences(ctx, john.ID); !prefs.MarketingEmails {
+		t.Fatal("the link unsubscribed somebody else")
+	}
+
+	// The next campaign is not mailed to jane
+	f.start(t, models.CampaignSegmentRequest{})
+	f.dispatch(t, 1)
+	f.deliver(t)
+	if got, want := f.mailed(), map[string]int{jane.Email: 1, john.Email: 2}; !maps.Equal(got, want) {
+		t.Fatalf("mailed %v, want %v", got, want)
+	}
+}
+
+func TestCampaignIsDeliveredOncePerRecipient(t *testing.T) {
+	f := newCampaignFixture(t)
+	var emails []string
+	for _, name := range []string{"jane", "john", "max", "ana", "lisa"} {
+		emails = append(emails, f.verified(t, name).Email)
+	}
+
+	// The dispatch is interrupted after publishing its first batch
+	id := f.start(t, models.CampaignSegmentRequest{})
+	failure := errors.New("broker unavailable")
+	published := 0
+	f.publisher.onPublish = func(messageID string) error {
+		if published++; published > 1 {
+			return failure
+		}
+		return nil
+	}
+	if _, err := f.service.DispatchDue(context.Background()); !errors.Is(err, failure) {
+		t.Fatalf("DispatchDue error = %v, want %v", err, failure)
+	}
+	f.publisher.onPublish = nil
+	f.deliver(t)
+
+	// It is not dispatched again before it went stale, and then only its pending deliveries are
+	f.dispatch(t, 0)
+	f.campaigns.backdate(id, 2*testCampaignStaleAfter)
+	f.dispatch(t, 1)
+	f.dispatch(t, 0)
+
+	// Every batch is redelivered, twice of them at the same time
+	f.publisher.mu.Lock()
+	batches := slices.Clone(f.publisher.published)
+	f.publisher.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, msg := range batches {
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := f.consumer.Handle(context.Background(), amqp.Delivery{MessageId: msg.messageID, Body: msg.body}); err != nil {
+					t.Errorf("Handle: %v", err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	want := make(map[string]int)
+	for _, email := range emails {
+		want[email] = 1
+	}
+	if got := f.mailed(); !maps.Equal(got, want) {
+		t.Fatalf("mailed %v, want %v", got, want)
+	}
+	if deliveries := f.campaigns.deliveriesOf(id); len(deliveries) != len(emails) {
+		t.Fatalf("%d deliveries were created, want %d", len(deliveries), len(emails))
+	}
+	if campaign := f.status(t, id); campaign.Status != entities.CampaignStatusSent || campaign.RecipientCount != int32(len(emails)) {
+		t.Fatalf("campaign is %s with %d recipients, want sent with %d", campaign.Status, campaign.RecipientCount, len(emails))
+	}
+}
+
+func TestCampaignSendStates(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("scheduled, sending and sent", func(t *testing.T) {
+		f := newCampaignFixture(t)
+		jane := f.verified(t, "jane")
+		f.verified(t, "john")
+		f.verified(t, "max")
+
+		id := f.create(t, models.CampaignSegmentRequest{})
+		if campaign := f.status(t, id); campaign.Status != entities.CampaignStatusDraft {
+			t.Fatalf("new campaign is %s, want draft", campaign.Status)
+		}
+		f.dispatch(t, 0)
+
+		past := &models.ScheduleCampaignRequest{ScheduledAt: time.Now().Add(-time.Hour).Format(time.RFC3339)}
+		if _, err := f.service.ScheduleCampaign(ctx, id, past); !errors.Is(err, apperrors.ErrInvalidRequestPayload) {
+			t.Fatalf("scheduling in the past error = %v, want ErrInvalidRequestPayload", err)
+		}
+		if _, err := f.service.ScheduleCampaign(ctx, uuid.New(), &models.ScheduleCampaignRequest{}); !errors.Is(err, apperrors.ErrNotFound) {
+			t.Fatalf("scheduling an unknown campaign error = %v, want ErrNotFound", err)
+		}
+
+		later := &models.ScheduleCampaignRequest{ScheduledAt: time.Now().Add(time.Hour).Format(time.RFC3339)}
+		if campaign, err := f.service.ScheduleCampaign(ctx, id, later); err != nil || campaign.Status != entities.CampaignStatusScheduled {
+			t.Fatalf("ScheduleCampaign = %+v, %v, want scheduled", campaign, err)
+		}
+		f.dispatch(t, 0)
+
+		// Rescheduling moves it up
+		if _, err := f.service.ScheduleCampaign(ctx, id, &models.ScheduleCampaignRequest{}); err != nil {
+			t.Fatalf("rescheduling: %v", err)
+		}
+		f.dispatch(t, 1)
+		campaign := f.status(t, id)
+		if campaign.Status != entities.CampaignStatusSending || campaign.DispatchedAt == nil || campaign.RecipientCount != 3 {
+			t.Fatalf("dispatched campaign is %+v, want sending to 3 recipients", campaign)
+		}
+		if _, err := f.service.ScheduleCampaign(ctx, id, &models.ScheduleCampaignRequest{}); !errors.Is(err, apperrors.ErrCampaignNotEditable) {
+			t.Fatalf("rescheduling while sending error = %v, want ErrCampaignNotEditable", err)
+		}
+
+		f.deliver(t)
+		campaign = f.status(t, id)
+		if campaign.Status != entities.CampaignStatusSent || campaign.CompletedAt == nil || campaign.Deliveries[entities.DeliveryStatusSent] != 3 {
+			t.Fatalf("delivered campaign is %+v, want sent with 3 deliveries", campaign)
+		}
+		if _, err := f.service.CancelCampaign(ctx, id); !errors.Is(err, apperrors.ErrCampaignNotEditable) {
+			t.Fatalf("cancelling a sent campaign error = %v, want ErrCampaignNotEditable", err)
+		}
+
+		// The handler reports the status conflict
+		admin := f.verified(t, "admin")
+		f.roles.grant(admin.ID, testAdminRole, entities.PermCampaignsManage)
+		e := newTestRouter(t, &handlers.UserHandler{CampaignService: f.service}, f.tokens)
+		if rec := serve(e, http.MethodPost, "/api/v1/accounts/campaigns/"+id.String()+"/cancel", f.accessToken(t, admin), ""); rec.Code != http.StatusConflict {
+			t.Fatalf("cancelling a sent campaign got %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body)
+		}
+		if _, ok := f.outbox.last(jane.Email); !ok {
+			t.Fatal("the campaign was not mailed")
+		}
+	})
+
+	t.Run("cancelled while sending", func(t *testing.T) {
+		f := newCampaignFixture(t)
+		f.verified(t, "jane")
+		f.verified(t, "john")
+		f.verified(t, "max")
+
+		id := f.start(t, models.CampaignSegmentRequest{})
+		f.dispatch(t, 1)
+		if campaign, err := f.service.CancelCampaign(ctx, id); err != nil || campaign.Status != entities.CampaignStatusCancelled {
+			t.Fatalf("CancelCampaign = %+v, %v, want cancelled", campaign, err)
+		}
+		f.deliver(t)
+
+		if got := f.mailed(); len(got) != 0 {
+			t.Fatalf("a cancelled campaign was mailed to %v", got)
+		}
+		for _, d := range f.campaigns.deliveriesOf(id) {
+			if d.Status != entities.DeliveryStatusSkipped || d.Error != "campaign cancelled" {
+				t.Fatalf("delivery to %s is %s (%q), want skipped", d.Email, d.Status, d.Error)
+			}
+		}
+		if campaign := f.status(t, id); campaign.Status != entities.CampaignStatusCancelled {
+			t.Fatalf("campaign is %s after its batches, want cancelled", campaign.Status)
+		}
+		if _, err := f.service.ScheduleCampaign(ctx, id, &models.ScheduleCampaignRequest{}); !errors.Is(err, apperrors.ErrCampaignNotEditable) {
+			t.Fatalf("scheduling a cancelled campaign error = %v, want ErrCampaignNotEditable", err)
+		}
+	})
+
+	t.Run("without recipients", func(t *testing.T) {
+		f := newCampaignFixture(t)
+		f.verified(t, "jane")
+
+		id := f.start(t, models.CampaignSegmentRequest{Roles: []string{"wholesaler"}})
+		f.dispatch(t, 1)
+
+		if campaign := f.status(t, id); campaign.Status != entities.CampaignStatusSent || campaign.RecipientCount != 0 {
+			t.Fatalf("campaign is %s with %d recipients, want sent with none", campaign.Status, campaign.RecipientCount)
+		}
+		if ids := f.publisher.messageIDs(); len(ids) != 0 {
+			t.Fatalf("%d batches were published for no recipients", len(ids))
+		}
+	})
+}
+
+func TestCampaignDeliveryConsumerDropsWhatItCanNotSend(t *testing.T) {
+	f := newCampaignFixture(t)
+
+	err := f.consumer.Handle(context.Background(), amqp.Delivery{Body: []byte("not json")})
+	if !errors.Is(err, rabbitmq.ErrPoisonMessage) {
+		t.Fatalf("malformed batch error = %v, want ErrPoisonMessage", err)
+	}
+
+	body := fmt.Sprintf(`{"campaign_id":%q,"delivery_ids":[%q]}`, uuid.New(), uuid.New())
+	if err := f.consumer.Handle(context.Background(), amqp.Delivery{Body: []byte(body)}); err != nil {
+		t.Fatalf("batch of a deleted campaign error = %v, want it dropped", err)
+	}
+}
+
+// fakeCampaignRepository keeps campaigns and deliveries in memory and applies the status rules of
+// the campaign queries. Recipients are selected from the user, role and preference fakes.
+type fakeCampaignRepository struct {
+	repositories.CampaignRepository
+	users *fakeUserRepository
+	roles *fakeRoleRepository
+	prefs *fakePreferenceRepository
+
+	mu         sync.Mutex
+	campaigns  map[uuid.UUID]*db.EmailCampaign
+	deliveries map[uuid.UUID]*db.EmailCampaignDelivery
+}
+
+func newFakeCampaignRepository(users *fakeUserRepository, roles *fakeRoleRepository, prefs *fakePreferenceRepository) *fakeCampaignRepository {
+	return &fakeCampaignRepository{
+		users:      users,
+		roles:      roles,
+		prefs:      prefs,
+		campaigns:  map[uuid.UUID]*db.EmailCampaign{},
+		deliveries: map[uuid.UUID]*db.EmailCampaignDelivery{},
+	}
+}
+
+// backdate moves the start of the campaign's dispatch into the past.
+func (r *fakeCampaignRepository) backdate(id uuid.UUID, by time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.campaigns[id].StartedAt.Time = r.campaigns[id].StartedAt.Time.Add(-by)
+}
+
+func (r *fakeCampaignRepository) deliveriesOf(campaignID uuid.UUID) []db.EmailCampaignDelivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var res []db.EmailCampaignDelivery
+	for _, d := range r.deliveries {
+		if d.CampaignID == campaignID {
+			res = append(res, *d)
+		}
+	}
+	return res
+}
+
+func (r *fakeCampaignRepository) CreateCampaign(ctx context.Context, param *db.CreateEmailCampaignParams) (*db.EmailCampaign, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	campaign := &db.EmailCampaign{
+		ID:                    uuid.New(),
+		Name:                  param.Name,
+		Subject:               param.Subject,
+		Body:                  param.Body,
+		SegmentRoles:          param.SegmentRoles,
+		SegmentSignedUpAfter:  param.SegmentSignedUpAfter,
+		SegmentSignedUpBefore: param.SegmentSignedUpBefore,
+		SegmentEmailVerified:  param.SegmentEmailVerified,
+		Status:                entities.CampaignStatusDraft,
+		CreatedBy:             param.CreatedBy,
+		CreatedAt:             now,
+		UpdatedAt:             now,
+	}
+	r.campaigns[campaign.ID] = campaign
+	res := *campaign
+	return &res, nil
+}
+
+func (r *fakeCampaignRepository) GetCampaign(ctx context.Context, id uuid.UUID) (*db.EmailCampaign, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	campaign, ok := r.campaigns[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: campaign %s", apperrors.ErrNotFound, id)
+	}
+	res := *campaign
+	return &res, nil
+}
+
+// update applies change to the campaign when it is in one of the statuses.
+func (r *fakeCampaignRepository) update(id uuid.UUID, statuses []string, change func(c *db.EmailCampaign)) (*db.EmailCampaign, bool) {
+	campaign, ok := r.campaigns[id]
+	if !ok || !slices.Contains(statuses, campaign.Status) {
+		return nil, false
+	}
+	change(campaign)
+	campaign.UpdatedAt = time.Now()
+	res := *campaign
+	return &res, true
+}
+
+func (r *fakeCampaignRepository) ScheduleCampaign(ctx context.Context, param *db.ScheduleEmailCampaignParams) (*db.EmailCampaign, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, ok := r.update(param.ID, []string{entities.CampaignStatusDraft, entities.CampaignStatusScheduled}, func(c *db.EmailCampaign) {
+		c.Status = entities.CampaignStatusScheduled
+		c.ScheduledAt = param.ScheduledAt
+	})
+	if !ok {
+		return nil, apperrors.ErrCampaignNotEditable
+	}
+	return res, nil
+}
+
+func (r *fakeCampaignRepository) CancelCampaign(ctx context.Context, id uuid.UUID) (*db.EmailCampaign, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, ok := r.update(id, []string{entities.CampaignStatusDraft, entities.CampaignStatusScheduled, entities.CampaignStatusSending}, func(c *db.EmailCampaign) {
+		c.Status = entities.CampaignStatusCancelled
+		c.CancelledAt = sql.NullTime{Time: time.Now(), Valid: true}
+	})
+	if !ok {
+		return nil, apperrors.ErrCampaignNotEditable
+	}
+	for _, d := range r.deliveries {
+		if d.CampaignID == id && d.Status == entities.DeliveryStatusPending {
+			d.Status, d.Error = entities.DeliveryStatusSkipped, "campaign cancelled"
+		}
+	}
+	return res, nil
+}
+
+func (r *fakeCampaignRepository) ClaimDueCampaigns(ctx context.Context, param *db.ClaimDueEmailCampaignsParams) ([]db.EmailCampaign, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var res []db.EmailCampaign
+	for _, c := range r.campaigns {
+		due := c.Status == entities.CampaignStatusScheduled && !c.ScheduledAt.Time.After(now)
+		stale := c.Status == entities.CampaignStatusSending && !c.DispatchedAt.Valid && c.StartedAt.Time.Before(param.StaleBefore)
+		if !due && !stale || int32(len(res)) == param.PageLimit {
+			continue
+		}
+		c.Status = entities.CampaignStatusSending
+		c.StartedAt = sql.NullTime{Time: now, Valid: true}
+		res = append(res, *c)
+	}
+	return res, nil
+}
+
+func (r *fakeCampaignRepository) MarkDispatched(ctx context.Context, param *db.MarkEmailCampaignDispatchedParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.update(param.ID, []string{entities.CampaignStatusSending}, func(c *db.EmailCampaign) {
+		c.DispatchedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		c.RecipientCount = param.RecipientCount
+	})
+	return nil
+}
+
+func (r *fakeCampaignRepository) CompleteCampaign(ctx context.Context, id uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.campaigns[id]; !ok || !c.DispatchedAt.Valid {
+		return false, nil
+	}
+	for _, d := range r.deliveries {
+		if d.CampaignID == id && d.Status == entities.DeliveryStatusPending {
+			return false, nil
+		}
+	}
+	_, ok := r.update(id, []string{entities.CampaignStatusSending}, func(c *db.EmailCampaign) {
+		c.Status = entities.CampaignStatusSent
+		c.CompletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	})
+	return ok, nil
+}
+
+// recipients returns the users of the segment that did not turn off marketing emails, ordered
+// by id.
+func (r *fakeCampaignRepository) recipients(ctx context.Context, roles []string, after sql.NullTime, before sql.NullTime, verified sql.NullBool) []db.User {
+	r.users.mu.Lock()
+	var users []db.User
+	for _, u := range r.users.byID {
+		users = append(users, *u)
+	}
+	r.users.mu.Unlock()
+	slices.SortFunc(users, func(a, b db.User) int { return bytes.Compare(a.ID[:], b.ID[:]) })
+
+	var res []db.User
+	for _, u := range users {
+		r.roles.mu.Lock()
+		inRole := len(roles) == 0 || slices.ContainsFunc(r.roles.roles[u.ID], func(role string) bool { return slices.Contains(roles, role) })
+		r.roles.mu.Unlock()
+		prefs, _ := r.prefs.GetPreferences(ctx, u.ID)
+
+		switch {
+		case u.DeletedAt.Valid, !prefs.MarketingEmails, !inRole,
+			after.Valid && u.CreatedAt.Before(after.Time),
+			before.Valid && !u.CreatedAt.Before(before.Time),
+			verified.Valid && u.EmailVerifiedAt.Valid != verified.Bool:
+			continue
+		}
+		res = append(res, u)
+	}
+	return res
+}
+
+func (r *fakeCampaignRepository) ListRecipients(ctx context.Context, param *db.ListEmailCampaignRecipientsParams) ([]db.ListEmailCampaignRecipientsRow, error) {
+	var res []db.ListEmailCampaignRecipientsRow
+	for _, u := range r.recipients(ctx, param.Roles, param.SignedUpAfter, param.SignedUpBefore, param.EmailVerified) {
+		if bytes.Compare(u.ID[:], param.AfterID[:]) <= 0 {
+			continue
+		}
+		if int32(len(res)) == param.PageLimit {
+			break
+		}
+		res = append(res, db.ListEmailCampaignRecipientsRow{ID: u.ID, Name: u.Name, Username: u.Username, Email: u.Email})
+	}
+	return res, nil
+}
+
+func (r *fakeCampaignRepository) CountRecipients(ctx context.Context, param *db.CountEmailCampaignRecipientsParams) (int64, error) {
+	return int64(len(r.recipients(ctx, param.Roles, param.SignedUpAfter, param.SignedUpBefore, param.EmailVerified))), nil
+}
+
+func (r *fakeCampaignRepository) CreateDeliveries(ctx context.Context, param *db.CreateEmailCampaignDeliveriesParams) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.campaigns[param.CampaignID]; !ok || c.Status != entities.CampaignStatusSending {
+		return 0, nil
+	}
+
+	var created int64
+	for i, userID := range param.UserIds {
+		exists := false
+		for _, d := range r.deliveries {
+			if d.CampaignID == param.CampaignID && d.UserID == userID {
+				exists = true
+				break
+			}
+		}
+		if exists {
+			continue
+		}
+		d := &db.EmailCampaignDelivery{
+			ID:         uuid.New(),
+			CampaignID: param.CampaignID,
+			UserID:     userID,
+			Email:      param.Emails[i],
+			Status:     entities.DeliveryStatusPending,
+			CreatedAt:  time.Now(),
+		}
+		r.deliveries[d.ID] = d
+		created++
+	}
+	return created, nil
+}
+
+func (r *fakeCampaignRepository) ListPendingDeliveries(ctx context.Context, param *db.ListPendingEmailCampaignDeliveriesParams) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ids []uuid.UUID
+	for _, d := range r.deliveries {
+		if d.CampaignID == param.CampaignID && d.Status == entities.DeliveryStatusPending && bytes.Compare(d.ID[:], param.ID[:]) > 0 {
+			ids = append(ids, d.ID)
+		}
+	}
+	slices.SortFunc(ids, func(a, b uuid.UUID) int { return bytes.Compare(a[:], b[:]) })
+	if int32(len(ids)) > param.Limit {
+		ids = ids[:param.Limit]
+	}
+	return ids, nil
+}
+
+func (r *fakeCampaignRepository) ClaimDelivery(ctx context.Context, id uuid.UUID) (*db.EmailCampaignDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.deliveries[id]
+	if !ok || d.Status != entities.DeliveryStatusPending || r.campaigns[d.CampaignID].Status != entities.CampaignStatusSending {
+		return nil, fmt.Errorf("%w: pending delivery %s", apperrors.ErrNotFound, id)
+	}
+	d.Status = entities.DeliveryStatusSending
+	res := *d
+	return &res, nil
+}
+
+func (r *fakeCampaignRepository) FinishDelivery(ctx context.Context, param *db.FinishEmailCampaignDeliveryParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if d, ok := r.deliveries[param.ID]; ok {
+		d.Status, d.Error = param.Status, param.Error
+		d.SentAt = sql.NullTime{Time: time.Now(), Valid: param.Status == entities.DeliveryStatusSent}
+	}
+	return nil
+}
+
+func (r *fakeCampaignRepository) CountDeliveries(ctx context.Context, campaignID uuid.UUID) ([]db.CountEmailCampaignDeliveriesRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int64)
+	for _, d := range r.deliveries {
+		if d.CampaignID == campaignID {
+			counts[d.Status]++
+		}
+	}
+	var res []db.CountEmailCampaignDeliveriesRow
+	for status, count := range counts {
+		res = append(res, db.CountEmailCampaignDeliveriesRow{Status: status, Count: count})
+	}
+	return res, nil
+}
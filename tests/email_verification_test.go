@@ -0,0 +1,307 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/handlers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/routes"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/audit"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+var verifyLinkPattern = regexp.MustCompile(`https://shop\.example\.com/verify-email\S*`)
+
+type verificationFixture struct {
+	*tokenFixture
+	outbox  *fakeNotifier
+	service services.EmailVerificationService
+}
+
+func newVerificationFixture(t *testing.T) *verificationFixture {
+	t.Helper()
+
+	f := &verificationFixture{tokenFixture: newTokenFixture(t), outbox: &fakeNotifier{}}
+	f.service = services.NewEmailVerificationService(f.users, newFakeAttemptRepository(), f.tokens, f.outbox, validator.New(),
+		time.Hour, "https://shop.example.com/verify-email", f.log)
+	return f
+}
+
+// link returns the token of the last verification link mailed to email.
+func (f *verificationFixture) link(t *testing.T, email string) string {
+	t.Helper()
+
+	msg, ok := f.outbox.last(email)
+	if !ok {
+		t.Fatalf("no verification email was sent to %s", email)
+	}
+	link, err := url.Parse(verifyLinkPattern.FindString(msg.Body))
+	if err != nil || link.Query().Get("token") == "" {
+		t.Fatalf("message to %s has no verification link: %q", email, msg.Body)
+	}
+	return link.Query().Get("token")
+}
+
+// resent asks for a new verification link and returns its token.
+func (f *verificationFixture) resent(t *testing.T, email string) string {
+	t.Helper()
+	if err := f.service.ResendVerification(context.Background(), &models.ResendVerificationRequest{Email: email}); err != nil {
+		t.Fatalf("ResendVerification: %v", err)
+	}
+	return f.link(t, email)
+}
+
+func (f *verificationFixture) verified(userID uuid.UUID) bool {
+	f.users.mu.Lock()
+	defer f.users.mu.Unlock()
+	return f.users.byID[userID].EmailVerifiedAt.Valid
+}
+
+func TestEmailVerificationLink(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("verifies the address it was sent to", func(t *testing.T) {
+		f := newVerificationFixture(t)
+		user := f.user("jane")
+		if err := f.service.SendVerification(ctx, user); err != nil {
+			t.Fatalf("SendVerification: %v", err)
+		}
+
+		if err := f.service.VerifyEmail(ctx, &models.VerifyEmailRequest{Token: f.link(t, user.Email)}); err != nil {
+			t.Fatalf("VerifyEmail: %v", err)
+		}
+		if !f.verified(user.ID) {
+			t.Fatal("the email is not verified")
+		}
+
+		// A verified address is not mailed again
+		before := len(f.outbox.sent)
+		verified, _ := f.users.GetUserByID(ctx, user.ID)
+		user.EmailVerifiedAt = &verified.EmailVerifiedAt.Time
+		if err := f.service.SendVerification(ctx, user); err != nil || len(f.outbox.sent) != before {
+			t.Fatalf("SendVerification for a verified address = %v and sent %d mails", err, len(f.outbox.sent)-before)
+		}
+	})
+
+	t.Run("refused after the email changed", func(t *testing.T) {
+		f := newVerificationFixture(t)
+		user := f.user("jane")
+		if err := f.service.SendVerification(ctx, user); err != nil {
+			t.Fatalf("SendVerification: %v", err)
+		}
+		link := f.link(t, user.Email)
+
+		f.users.mu.Lock()
+		f.users.byID[user.ID].Email = "jane.new@example.com"
+		f.users.mu.Unlock()
+
+		if err := f.service.VerifyEmail(ctx, &models.VerifyEmailRequest{Token: link}); !errors.Is(err, apperrors.ErrInvalidToken) {
+			t.Fatalf("VerifyEmail error = %v, want ErrInvalidToken", err)
+		}
+		if f.verified(user.ID) {
+			t.Fatal("the new address was verified with the link of the old one")
+		}
+	})
+
+	t.Run("refuses other tokens", func(t *testing.T) {
+		f := newVerificationFixture(t)
+		user := f.user("jane")
+
+		unsubscribe, _, err := f.tokens.GeneratePurposeToken(ctx, token.PurposeSubject{UserID: user.ID, Email: user.Email}, token.PurposeUnsubscribe, time.Hour)
+		if err != nil {
+			t.Fatalf("GeneratePurposeToken: %v", err)
+		}
+		tests := []struct {
+			name  string
+			token string
+			want  error
+		}{
+			{name: "missing", token: "", want: apperrors.ErrInvalidRequestPayload},
+			{name: "garbage", token: "not-a-token", want: apperrors.ErrInvalidToken},
+			{name: "other purpose", token: unsubscribe, want: apperrors.ErrInvalidToken},
+			{name: "access token", token: f.accessToken(t, user), want: apperrors.ErrInvalidToken},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if err := f.service.VerifyEmail(ctx, &models.VerifyEmailRequest{Token: tt.token}); !errors.Is(err, tt.want) {
+					t.Fatalf("VerifyEmail error = %v, want %v", err, tt.want)
+				}
+			})
+		}
+		if f.verified(user.ID) {
+			t.Fatal("the email was verified without its link")
+		}
+	})
+}
+
+func TestResendVerificationDoesNotRevealTheAccount(t *testing.T) {
+	ctx := context.Background()
+	f := newVerificationFixture(t)
+	jane := f.user("jane")
+	john := f.user("john")
+	if err := f.service.VerifyEmail(ctx, &models.VerifyEmailRequest{Token: f.resent(t, john.Email)}); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	throttled := f.user("max")
+	for i := 0; i < 3; i++ {
+		f.resent(t, throttled.Email)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		wantSent bool
+	}{
+		{name: "unverified", email: jane.Email, wantSent: true},
+		{name: "unknown", email: "nobody@example.com"},
+		{name: "verified", email: john.Email},
+		{name: "throttled", email: throttled.Email},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(f.outbox.sent)
+			if err := f.service.ResendVerification(ctx, &models.ResendVerificationRequest{Email: tt.email}); err != nil {
+				t.Fatalf("ResendVerification error = %v, want the answer of every address", err)
+			}
+			if sent := len(f.outbox.sent) > before; sent != tt.wantSent {
+				t.Fatalf("sent = %t, want %t", sent, tt.wantSent)
+			}
+		})
+	}
+
+	if err := f.service.ResendVerification(ctx, &models.ResendVerificationRequest{Email: "not an email"}); !errors.Is(err, apperrors.ErrInvalidRequestPayload) {
+		t.Fatalf("malformed address error = %v, want ErrInvalidRequestPayload", err)
+	}
+}
+
+func TestLoginOfUnverifiedAccounts(t *testing.T) {
+	opts := services.LoginThrottleOptions{
+		MaxAttempts:        5,
+		IPMaxAttempts:      100,
+		AttemptWindow:      time.Hour,
+		LockoutDuration:    time.Minute,
+		MaxLockoutDuration: time.Hour,
+	}
+
+	tests := []struct {
+		mode    string
+		wantErr error
+	}{
+		{mode: services.EmailVerificationOff},
+		{mode: services.EmailVerificationRestricted},
+		{mode: services.EmailVerificationDeny, wantErr: apperrors.ErrEmailNotVerified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			f := newLoginFixture(t, opts)
+			f.service = services.NewUserService(f.tokenFixture.users, validator.New(), f.tokens, f.blacklist, f.throttle,
+				tt.mode, audit.NewRecorder(f.audit, f.log), f.log)
+			unverified := f.account(t, "jane")
+			verified := f.account(t, "john")
+			f.users.byID[verified.ID].EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+			if err := f.login(unverified.Username, testPassword, "198.51.100.1"); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("login error = %v, want %v", err, tt.wantErr)
+			}
+			if err := f.login(verified.Username, testPassword, "198.51.100.1"); err != nil {
+				t.Fatalf("login of a verified account: %v", err)
+			}
+			if tt.wantErr != nil && !slices.Contains(f.audit.actions(unverified.ID), entities.AuditLoginFailed) {
+				t.Fatal("the refused login was not audited")
+			}
+		})
+	}
+}
+
+func TestRestrictedTokensUntilTheEmailIsVerified(t *testing.T) {
+	ctx := context.Background()
+	f := newVerificationFixture(t)
+	user := f.user("jane")
+
+	api := &handlers.UserHandler{
+		EmailVerificationService: f.service,
+		PreferenceService:        services.NewPreferenceService(newFakePreferenceRepository(), f.users, f.tokens, f.log),
+	}
+	e := echo.New()
+	routes.InitRoutes(e, api, routes.Options{
+		TokenService:         f.tokens,
+		RateLimiter:          newFakeRateLimiter(),
+		RequireVerifiedEmail: true,
+	})
+
+	session, err := f.tokens.GenerateTokenPair(ctx, user, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if _, claims, _, err := f.tokens.ValidateToken(ctx, session.AccessToken); err != nil || claims.EmailVerified {
+		t.Fatalf("token of an unverified account claims a verified email (err %v)", err)
+	}
+	if rec := serve(e, http.MethodGet, "/api/v1/accounts/preferences", session.AccessToken, ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("restricted route before the verification got %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	if rec := serve(e, http.MethodPost, "/api/v1/accounts/email/verify", "", `{"token":"not-a-token"}`); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("verifying with a bad token got %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := serve(e, http.MethodPost, "/api/v1/accounts/email/resend", "", `{"email":"`+user.Email+`"}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("resend got %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	if rec := serve(e, http.MethodPost, "/api/v1/accounts/email/verify", "", `{"token":"`+f.link(t, user.Email)+`"}`); rec.Code != http.StatusOK {
+		t.Fatalf("verifying got %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	// The next token of the session carries the verification
+	refreshed, err := f.tokens.Refresh(ctx, session.RefreshToken, token.IssueOptions{})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if rec := serve(e, http.MethodGet, "/api/v1/accounts/preferences", refreshed.AccessToken, ""); rec.Code != http.StatusOK {
+		t.Fatalf("restricted route after the verification got %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestPasswordlessLoginNeedsAVerifiedEmail(t *testing.T) {
+	f := newVerificationFixture(t)
+	user := f.user("jane")
+	prefs := newFakePreferenceRepository()
+	api := &handlers.UserHandler{PreferenceService: services.NewPreferenceService(prefs, f.users, f.tokens, f.log)}
+	e := newTestRouter(t, api, f.tokens)
+	accessToken := f.accessToken(t, user)
+
+	if rec := serve(e, http.MethodPatch, "/api/v1/accounts/preferences", accessToken, `{"passwordless_login":true}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("turning on passwordless login unverified got %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if rec := serve(e, http.MethodPatch, "/api/v1/accounts/preferences", accessToken, `{"marketing_emails":false}`); rec.Code != http.StatusOK {
+		t.Fatalf("changing marketing emails unverified got %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	f.users.mu.Lock()
+	f.users.byID[user.ID].EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	f.users.mu.Unlock()
+	if rec := serve(e, http.MethodPatch, "/api/v1/accounts/preferences", accessToken, `{"passwordless_login":true}`); rec.Code != http.StatusOK {
+		t.Fatalf("turning on passwordless login verified got %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	// Fields that are not sent keep their value
+	got, _ := prefs.GetPreferences(context.Background(), user.ID)
+	if !got.PasswordlessLogin || got.MarketingEmails {
+		t.Fatalf("preferences = %+v, want passwordless login on and marketing emails off", got)
+	}
+}
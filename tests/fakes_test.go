@@ -0,0 +1,1198 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/middlewares"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/notifier"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/audit"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+// In-memory fakes shared by the tests of several features. Methods a fake does not implement
+// are left to the embedded interface and panic when called.
+
+// testWriter sends log output to the test log.
+type testWriter struct{ t *testing.T }
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSpace(string(p)))
+	return len(p), nil
+}
+
+func newTestLogger(t *testing.T) *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(testWriter{t})
+	return log
+}
+
+const (
+	testAccessTokenTTL = 15 * time.Minute
+	testJWTSecret      = "test-secret-that-is-long-enough-for-hs256"
+)
+
+// tokenFixture is a token service on in-memory repositories.
+type tokenFixture struct {
+	tokens    token.TokenService
+	users     *fakeUserRepository
+	roles     *fakeRoleRepository
+	blacklist *fakeBlacklistRepository
+	refresh   *fakeRefreshTokenRepository
+	sessions  *fakeSessionRepository
+	apiKeys   *fakeAPIKeyRepository
+	audit     *fakeAuditRepository
+	log       *logrus.Logger
+}
+
+func newTokenFixture(t *testing.T) *tokenFixture {
+	t.Helper()
+
+	f := &tokenFixture{
+		users:     newFakeUserRepository(),
+		roles:     newFakeRoleRepository(),
+		blacklist: newFakeBlacklistRepository(),
+		refresh:   newFakeRefreshTokenRepository(),
+		sessions:  newFakeSessionRepository(),
+		apiKeys:   newFakeAPIKeyRepository(),
+		audit:     &fakeAuditRepository{},
+		log:       newTestLogger(t),
+	}
+	f.tokens = f.tokenService(t, token.KeyConfig{Algorithm: token.AlgorithmHS256, Secret: testJWTSecret})
+	return f
+}
+
+// tokenService returns a token service with the given keys on the repositories of the fixture.
+func (f *tokenFixture) tokenService(t *testing.T, cfg token.KeyConfig) token.TokenService {
+	t.Helper()
+
+	keys, err := token.LoadKeySet(cfg)
+	if err != nil {
+		t.Fatalf("LoadKeySet: %v", err)
+	}
+	return token.NewJWTTokenService(keys, testAccessTokenTTL, 24*time.Hour,
+		f.blacklist, f.refresh, f.sessions, f.users, f.roles, nil, f.apiKeys,
+		audit.NewRecorder(f.audit, f.log), f.log)
+}
+
+// user adds an account with the default role.
+func (f *tokenFixture) user(username string) *entities.User {
+	row := f.users.add(&db.User{ID: uuid.New(), Name: username, Username: username, Email: username + "@example.com", Password: "hash", Role: entities.DefaultRole})
+	f.roles.grant(row.ID, entities.DefaultRole)
+	return &entities.User{ID: row.ID, Name: row.Name, Username: row.Username, Email: row.Email, Role: row.Role}
+}
+
+func (f *tokenFixture) validate(t *testing.T, accessToken string) (bool, string) {
+	t.Helper()
+	valid, _, msg, err := f.tokens.ValidateToken(context.Background(), accessToken)
+	if err != nil && valid {
+		t.Fatalf("ValidateToken returned valid with error %v", err)
+	}
+	return valid, msg
+}
+
+// accessToken logs the user in with a password.
+func (f *tokenFixture) accessToken(t *testing.T, user *entities.User) string {
+	t.Helper()
+	pair, err := f.tokens.GenerateTokenPair(context.Background(), user, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	return pair.AccessToken
+}
+
+type fakeUserRepository struct {
+	repositories.UserRepository
+	mu   sync.Mutex
+	byID map[uuid.UUID]*db.User
+}
+
+func newFakeUserRepository() *fakeUserRepository {
+	return &fakeUserRepository{byID: map[uuid.UUID]*db.User{}}
+}
+
+func (r *fakeUserRepository) add(user *db.User) *db.User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byID[user.ID] = user
+	return user
+}
+
+func (r *fakeUserRepository) CreateUser(ctx context.Context, param *db.CreateUserParams) (*db.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.conflict(uuid.Nil, param.Name, param.Username, param.Email); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &db.User{
+		ID:        param.ID,
+		Name:      param.Name,
+		Username:  param.Username,
+		Email:     param.Email,
+		Password:  param.Password,
+		Role:      param.Role,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.byID[user.ID] = user
+	return user, nil
+}
+
+// conflict mirrors the unique constraints of the users table for a user other than id. The
+// username and email indexes ignore case and deleted users, the name constraint does neither.
+func (r *fakeUserRepository) conflict(id uuid.UUID, name, username, email string) error {
+	for _, user := range r.byID {
+		active := user.ID != id && !user.DeletedAt.Valid
+		switch {
+		case active && strings.EqualFold(user.Email, email):
+			return &apperrors.ConflictError{Field: "email"}
+		case active && strings.EqualFold(user.Username, username):
+			return &apperrors.ConflictError{Field: "username"}
+		case user.ID != id && user.Name == name:
+			return &apperrors.ConflictError{Field: "name"}
+		}
+	}
+	return nil
+}
+
+func (r *fakeUserRepository) GetUserByEmail(ctx context.Context, email string) (*db.GetUserByEmailRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.byID {
+		if strings.EqualFold(user.Email, email) {
+			row := db.GetUserByEmailRow{ID: user.ID, Name: user.Name, Username: user.Username, Email: user.Email, Password: user.Password, EmailVerifiedAt: user.EmailVerifiedAt}
+			return &row, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get user by email: %w", sql.ErrNoRows)
+}
+
+func (r *fakeUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*db.GetUserByIDRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get user by id: %w", sql.ErrNoRows)
+	}
+	row := db.GetUserByIDRow{ID: user.ID, Name: user.Name, Username: user.Username, Email: user.Email, Password: user.Password, EmailVerifiedAt: user.EmailVerifiedAt}
+	return &row, nil
+}
+
+func (r *fakeUserRepository) GetUserByUsername(ctx context.Context, username string) (*db.GetUserByUsernameRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.byID {
+		if user.Username == username {
+			row := db.GetUserByUsernameRow{ID: user.ID, Name: user.Name, Username: user.Username, Email: user.Email, Password: user.Password, Role: user.Role, EmailVerifiedAt: user.EmailVerifiedAt}
+			return &row, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get user by username: %w", sql.ErrNoRows)
+}
+
+func (r *fakeUserRepository) UpdateUserPassword(ctx context.Context, param *db.UpdateUserPasswordParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.byID[param.ID]
+	if !ok {
+		return fmt.Errorf("failed to update password: %w", sql.ErrNoRows)
+	}
+	user.Password = param.Password
+	return nil
+}
+
+func (r *fakeUserRepository) MarkEmailVerified(ctx context.Context, param *db.MarkUserEmailVerifiedParams) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.byID[param.ID]
+	if !ok || user.Email != param.Email || user.EmailVerifiedAt.Valid {
+		return false, nil
+	}
+	user.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return true, nil
+}
+
+type fakeRoleRepository struct {
+	repositories.RoleRepository
+	mu          sync.Mutex
+	ids         map[string]uuid.UUID
+	permissions map[string][]string
+	roles       map[uuid.UUID][]string
+}
+
+func newFakeRoleRepository() *fakeRoleRepository {
+	return &fakeRoleRepository{ids: map[string]uuid.UUID{}, permissions: map[string][]string{}, roles: map[uuid.UUID][]string{}}
+}
+
+// define adds the role, or more permissions to it.
+func (r *fakeRoleRepository) define(role string, permissions ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.ids[role]; !ok {
+		r.ids[role] = uuid.New()
+	}
+	r.permissions[role] = append(r.permissions[role], permissions...)
+}
+
+// grant gives the user a role with the permissions it carries.
+func (r *fakeRoleRepository) grant(userID uuid.UUID, role string, permissions ...string) {
+	r.define(role, permissions...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.roles[userID], role) {
+		r.roles[userID] = append(r.roles[userID], role)
+	}
+}
+
+func (r *fakeRoleRepository) name(roleID uuid.UUID) string {
+	for name, id := range r.ids {
+		if id == roleID {
+			return name
+		}
+	}
+	return ""
+}
+
+func (r *fakeRoleRepository) ListRoles(ctx context.Context) ([]db.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var roles []db.Role
+	for name, id := range r.ids {
+		roles = append(roles, db.Role{ID: id, Name: name})
+	}
+	return roles, nil
+}
+
+func (r *fakeRoleRepository) ListRolePermissions(ctx context.Context) ([]db.ListRolePermissionsRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var rows []db.ListRolePermissionsRow
+	for role, permissions := range r.permissions {
+		for _, permission := range permissions {
+			rows = append(rows, db.ListRolePermissionsRow{RoleName: role, PermissionName: permission})
+		}
+	}
+	return rows, nil
+}
+
+func (r *fakeRoleRepository) GetRoleByName(ctx context.Context, name string) (*db.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.ids[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: role %q", apperrors.ErrNotFound, name)
+	}
+	return &db.Role{ID: id, Name: name}, nil
+}
+
+func (r *fakeRoleRepository) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]db.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var roles []db.Role
+	for _, name := range r.roles[userID] {
+		roles = append(roles, db.Role{ID: r.ids[name], Name: name})
+	}
+	return roles, nil
+}
+
+func (r *fakeRoleRepository) GetUserPermissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var permissions []string
+	for _, role := range r.roles[userID] {
+		for _, permission := range r.permissions[role] {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions, nil
+}
+
+func (r *fakeRoleRepository) GrantUserRole(ctx context.Context, param *db.GrantUserRoleParams) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	role := r.name(param.RoleID)
+	if slices.Contains(r.roles[param.UserID], role) {
+		return false, nil
+	}
+	r.roles[param.UserID] = append(r.roles[param.UserID], role)
+	return true, nil
+}
+
+func (r *fakeRoleRepository) RevokeUserRole(ctx context.Context, param *db.RevokeUserRoleParams) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	role := r.name(param.RoleID)
+	held := r.roles[param.UserID]
+	i := slices.Index(held, role)
+	if i < 0 {
+		return false, nil
+	}
+	r.roles[param.UserID] = slices.Delete(held, i, i+1)
+	return true, nil
+}
+
+func (r *fakeRoleRepository) SyncUserPrimaryRole(ctx context.Context, userID uuid.UUID) error {
+	return nil
+}
+
+type fakeBlacklistRepository struct {
+	mu         sync.Mutex
+	jtis       map[string]bool
+	sessions   map[string]bool
+	validAfter map[uuid.UUID]time.Time
+}
+
+func newFakeBlacklistRepository() *fakeBlacklistRepository {
+	return &fakeBlacklistRepository{jtis: map[string]bool{}, sessions: map[string]bool{}, validAfter: map[uuid.UUID]time.Time{}}
+}
+
+func (r *fakeBlacklistRepository) AddToBlacklist(ctx context.Context, jti string, expiration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jtis[jti] = true
+	return nil
+}
+
+func (r *fakeBlacklistRepository) IsBlacklisted(ctx context.Context, jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.jtis[jti], nil
+}
+
+func (r *fakeBlacklistRepository) AddToBlacklistIfAbsent(ctx context.Context, jti string, expiration time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.jtis[jti] {
+		return false, nil
+	}
+	r.jtis[jti] = true
+	return true, nil
+}
+
+func (r *fakeBlacklistRepository) RemoveFromBlacklist(ctx context.Context, jti string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.jtis, jti)
+	return nil
+}
+
+func (r *fakeBlacklistRepository) BlacklistSession(ctx context.Context, sessionID string, expiration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[sessionID] = true
+	return nil
+}
+
+func (r *fakeBlacklistRepository) IsSessionBlacklisted(ctx context.Context, sessionID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sessions[sessionID], nil
+}
+
+// SetTokensValidAfter keeps millisecond precision like the Redis repository.
+func (r *fakeBlacklistRepository) SetTokensValidAfter(ctx context.Context, userID uuid.UUID, validAfter time.Time, expiration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.validAfter[userID] = validAfter.Truncate(time.Millisecond)
+	return nil
+}
+
+func (r *fakeBlacklistRepository) GetTokensValidAfter(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.validAfter[userID], nil
+}
+
+type fakeRefreshTokenRepository struct {
+	repositories.RefreshTokenRepository
+	mu     sync.Mutex
+	tokens map[uuid.UUID]*db.RefreshToken
+}
+
+func newFakeRefreshTokenRepository() *fakeRefreshTokenRepository {
+	return &fakeRefreshTokenRepository{tokens: map[uuid.UUID]*db.RefreshToken{}}
+}
+
+func (r *fakeRefreshTokenRepository) CreateRefreshToken(ctx context.Context, param *db.CreateRefreshTokenParams) (*db.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row := &db.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    param.UserID,
+		FamilyID:  param.FamilyID,
+		ParentID:  param.ParentID,
+		TokenHash: param.TokenHash,
+		ExpiresAt: param.ExpiresAt,
+		CreatedAt: time.Now(),
+		StoreID:   param.StoreID,
+		Amr:       param.Amr,
+		ClientID:  param.ClientID,
+	}
+	r.tokens[row.ID] = row
+	copied := *row
+	return &copied, nil
+}
+
+func (r *fakeRefreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*db.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, row := range r.tokens {
+		if row.TokenHash == tokenHash {
+			copied := *row
+			return &copied, nil
+		}
+	}
+	return nil, apperrors.ErrTokenNotFound
+}
+
+func (r *fakeRefreshTokenRepository) MarkRefreshTokenUsed(ctx context.Context, id uuid.UUID) (*db.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.tokens[id]
+	if !ok || row.UsedAt.Valid || row.RevokedAt.Valid {
+		return nil, apperrors.ErrTokenNotFound
+	}
+	row.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	copied := *row
+	return &copied, nil
+}
+
+func (r *fakeRefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, row := range r.tokens {
+		if row.FamilyID == familyID && !row.RevokedAt.Valid {
+			row.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+func (r *fakeRefreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, row := range r.tokens {
+		if row.UserID == userID && !row.RevokedAt.Valid {
+			row.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+type fakeSessionRepository struct {
+	mu       sync.Mutex
+	sessions map[uuid.UUID]map[string]models.Session
+}
+
+func newFakeSessionRepository() *fakeSessionRepository {
+	return &fakeSessionRepository{sessions: map[uuid.UUID]map[string]models.Session{}}
+}
+
+func (r *fakeSessionRepository) StoreSession(ctx context.Context, session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.sessions[session.UserID] == nil {
+		r.sessions[session.UserID] = map[string]models.Session{}
+	}
+	r.sessions[session.UserID][session.JTI] = *session
+	return nil
+}
+
+func (r *fakeSessionRepository) GetSession(ctx context.Context, userID uuid.UUID, jti string) (*models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[userID][jti]
+	if !ok {
+		return nil, apperrors.ErrNotFound
+	}
+	return &session, nil
+}
+
+func (r *fakeSessionRepository) ListSessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sessions []models.Session
+	for _, session := range r.sessions[userID] {
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (r *fakeSessionRepository) TouchSession(ctx context.Context, userID uuid.UUID, jti string, seenAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if session, ok := r.sessions[userID][jti]; ok {
+		session.LastSeenAt = seenAt
+		r.sessions[userID][jti] = session
+	}
+	return nil
+}
+
+func (r *fakeSessionRepository) DeleteSession(ctx context.Context, userID uuid.UUID, jti string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions[userID], jti)
+	return nil
+}
+
+func (r *fakeSessionRepository) DeleteAllSessions(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, userID)
+	return nil
+}
+
+type fakeAPIKeyRepository struct {
+	repositories.APIKeyRepository
+	mu   sync.Mutex
+	keys map[string]*db.ApiKey
+}
+
+func newFakeAPIKeyRepository() *fakeAPIKeyRepository {
+	return &fakeAPIKeyRepository{keys: map[string]*db.ApiKey{}}
+}
+
+// add issues a key to the user and returns it.
+func (r *fakeAPIKeyRepository) add(t *testing.T, userID uuid.UUID, expiresAt sql.NullTime, scopes ...string) string {
+	t.Helper()
+
+	key, prefix, hash, err := token.NewAPIKey()
+	if err != nil {
+		t.Fatalf("NewAPIKey: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[prefix] = &db.ApiKey{ID: uuid.New(), UserID: userID, Prefix: prefix, KeyHash: hash, Scopes: scopes, ExpiresAt: expiresAt, CreatedAt: time.Now()}
+	return key
+}
+
+func (r *fakeAPIKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*db.ApiKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[prefix]
+	if !ok {
+		return nil, apperrors.ErrTokenNotFound
+	}
+	copied := *key
+	return &copied, nil
+}
+
+func (r *fakeAPIKeyRepository) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	return nil
+}
+
+// fakeAuditRepository keeps the recorded events in order. Writes fail with err when it is set,
+// and with the context error once the context is done, as the database driver does.
+type fakeAuditRepository struct {
+	repositories.AuditRepository
+	mu     sync.Mutex
+	events []db.AuditEvent
+	err    error
+}
+
+func (r *fakeAuditRepository) CreateEvent(ctx context.Context, param *db.CreateAuditEventParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.events = append(r.events, db.AuditEvent{
+		ID:           param.ID,
+		Action:       param.Action,
+		ActorID:      param.ActorID,
+		TargetUserID: param.TargetUserID,
+		IpAddress:    param.IpAddress,
+		UserAgent:    param.UserAgent,
+		RequestID:    param.RequestID,
+		Metadata:     param.Metadata,
+		CreatedAt:    time.Now(),
+	})
+	return nil
+}
+
+// add stores an event as if it had been recorded at createdAt.
+func (r *fakeAuditRepository) add(action string, targetUserID uuid.UUID, createdAt time.Time) uuid.UUID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := uuid.New()
+	r.events = append(r.events, db.AuditEvent{
+		ID:           id,
+		Action:       action,
+		TargetUserID: uuid.NullUUID{UUID: targetUserID, Valid: true},
+		Metadata:     json.RawMessage("{}"),
+		CreatedAt:    createdAt,
+	})
+	return id
+}
+
+func (r *fakeAuditRepository) find(id uuid.UUID) db.AuditEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.events, func(event db.AuditEvent) bool { return event.ID == id })
+	return r.events[i]
+}
+
+// ListEvents filters and pages like the ListAuditEvents query, newest first with ties broken on
+// the id.
+func (r *fakeAuditRepository) ListEvents(ctx context.Context, param *db.ListAuditEventsParams) ([]db.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	newestFirst := func(a, b db.AuditEvent) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(b.ID[:], a.ID[:])
+	}
+	cursor := db.AuditEvent{ID: param.CursorID.UUID, CreatedAt: param.CursorCreatedAt.Time}
+
+	var events []db.AuditEvent
+	for _, event := range r.events {
+		switch {
+		case param.Action.Valid && event.Action != param.Action.String,
+			param.ActorID.Valid && event.ActorID != param.ActorID,
+			param.TargetUserID.Valid && event.TargetUserID != param.TargetUserID,
+			param.CreatedFrom.Valid && event.CreatedAt.Before(param.CreatedFrom.Time),
+			param.CreatedTo.Valid && !event.CreatedAt.Before(param.CreatedTo.Time),
+			param.CursorID.Valid && newestFirst(event, cursor) <= 0:
+			continue
+		}
+		events = append(events, event)
+	}
+
+	slices.SortFunc(events, newestFirst)
+	if len(events) > int(param.PageLimit) {
+		events = events[:param.PageLimit]
+	}
+	return events, nil
+}
+
+// actions returns the actions recorded for the target user.
+func (r *fakeAuditRepository) actions(targetUserID uuid.UUID) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var actions []string
+	for _, event := range r.events {
+		if event.TargetUserID.Valid && event.TargetUserID.UUID == targetUserID {
+			actions = append(actions, event.Action)
+		}
+	}
+	return actions
+}
+
+type fakeIdentityRepository struct {
+	mu         sync.Mutex
+	identities map[uuid.UUID]*db.UserIdentity
+}
+
+func newFakeIdentityRepository() *fakeIdentityRepository {
+	return &fakeIdentityRepository{identities: map[uuid.UUID]*db.UserIdentity{}}
+}
+
+func (r *fakeIdentityRepository) CreateIdentity(ctx context.Context, param *db.CreateUserIdentityParams) (*db.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.identities {
+		if (existing.Provider == param.Provider && existing.Subject == param.Subject) ||
+			(existing.UserID == param.UserID && existing.Provider == param.Provider) {
+			return nil, apperrors.ErrIdentityAlreadyLinked
+		}
+	}
+	row := &db.UserIdentity{ID: uuid.New(), UserID: param.UserID, Provider: param.Provider, Subject: param.Subject, Email: param.Email, CreatedAt: time.Now()}
+	r.identities[row.ID] = row
+	return row, nil
+}
+
+func (r *fakeIdentityRepository) GetIdentity(ctx context.Context, provider string, subject string) (*db.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, row := range r.identities {
+		if row.Provider == provider && row.Subject == subject {
+			return row, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s identity", apperrors.ErrNotFound, provider)
+}
+
+func (r *fakeIdentityRepository) ListIdentities(ctx context.Context, userID uuid.UUID) ([]db.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var rows []db.UserIdentity
+	for _, row := range r.identities {
+		if row.UserID == userID {
+			rows = append(rows, *row)
+		}
+	}
+	return rows, nil
+}
+
+func (r *fakeIdentityRepository) CountIdentities(ctx context.Context, userID uuid.UUID) (int64, error) {
+	rows, err := r.ListIdentities(ctx, userID)
+	return int64(len(rows)), err
+}
+
+func (r *fakeIdentityRepository) TouchIdentity(ctx context.Context, param *db.TouchUserIdentityParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if row, ok := r.identities[param.ID]; ok {
+		row.Email = param.Email
+		row.LastLoginAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	return nil
+}
+
+func (r *fakeIdentityRepository) DeleteIdentity(ctx context.Context, param *db.DeleteUserIdentityParams) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.identities[param.ID]
+	if !ok || row.UserID != param.UserID {
+		return false, nil
+	}
+	delete(r.identities, param.ID)
+	return true, nil
+}
+
+// fakeAttemptRepository counts attempts without expiring them.
+type fakeAttemptRepository struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func newFakeAttemptRepository() *fakeAttemptRepository {
+	return &fakeAttemptRepository{counts: map[string]int64{}}
+}
+
+func (r *fakeAttemptRepository) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[key]++
+	return r.counts[key], nil
+}
+
+func (r *fakeAttemptRepository) Count(ctx context.Context, key string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counts[key], nil
+}
+
+func (r *fakeAttemptRepository) TTL(ctx context.Context, key string) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts[key] == 0 {
+		return 0, nil
+	}
+	return time.Minute, nil
+}
+
+func (r *fakeAttemptRepository) Reset(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.counts, key)
+	return nil
+}
+
+// fakeNotifier keeps the sent messages.
+type fakeNotifier struct {
+	mu   sync.Mutex
+	sent []notifier.Message
+}
+
+func (n *fakeNotifier) Send(ctx context.Context, msg notifier.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+func (n *fakeNotifier) last(to string) (notifier.Message, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for i := len(n.sent) - 1; i >= 0; i-- {
+		if n.sent[i].To == to {
+			return n.sent[i], true
+		}
+	}
+	return notifier.Message{}, false
+}
+
+// fakePreferenceRepository returns the defaults of the real repository for users that never
+// saved their preferences.
+type fakePreferenceRepository struct {
+	mu    sync.Mutex
+	prefs map[uuid.UUID]db.UserPreference
+}
+
+func newFakePreferenceRepository() *fakePreferenceRepository {
+	return &fakePreferenceRepository{prefs: map[uuid.UUID]db.UserPreference{}}
+}
+
+func (r *fakePreferenceRepository) GetPreferences(ctx context.Context, userID uuid.UUID) (*db.UserPreference, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prefs, ok := r.prefs[userID]
+	if !ok {
+		prefs = db.UserPreference{UserID: userID, MarketingEmails: true}
+	}
+	return &prefs, nil
+}
+
+func (r *fakePreferenceRepository) UpsertPreferences(ctx context.Context, param *db.UpsertUserPreferencesParams) (*db.UserPreference, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prefs := db.UserPreference{UserID: param.UserID, PasswordlessLogin: param.PasswordlessLogin, MarketingEmails: param.MarketingEmails, UpdatedAt: time.Now()}
+	r.prefs[param.UserID] = prefs
+	return &prefs, nil
+}
+
+type fakeOAuthClientRepository struct {
+	repositories.OAuthClientRepository
+	mu      sync.Mutex
+	clients map[string]*db.OauthClient
+}
+
+func newFakeOAuthClientRepository() *fakeOAuthClientRepository {
+	return &fakeOAuthClientRepository{clients: map[string]*db.OauthClient{}}
+}
+
+func (r *fakeOAuthClientRepository) add(client *db.OauthClient) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client.ID = uuid.New()
+	r.clients[client.ClientID] = client
+}
+
+func (r *fakeOAuthClientRepository) GetClientByClientID(ctx context.Context, clientID string) (*db.OauthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, ok := r.clients[clientID]
+	if !ok {
+		return nil, apperrors.ErrInvalidClient
+	}
+	copied := *client
+	return &copied, nil
+}
+
+func (r *fakeOAuthClientRepository) CreateClient(ctx context.Context, param *db.CreateOAuthClientParams) (*db.OauthClient, error) {
+	client := &db.OauthClient{
+		ClientID:     param.ClientID,
+		Name:         param.Name,
+		SecretHash:   param.SecretHash,
+		Scopes:       param.Scopes,
+		CreatedBy:    param.CreatedBy,
+		CreatedAt:    time.Now(),
+		RedirectUris: param.RedirectUris,
+		GrantTypes:   param.GrantTypes,
+		IsPublic:     param.IsPublic,
+		FirstParty:   param.FirstParty,
+	}
+	r.add(client)
+	copied := *client
+	return &copied, nil
+}
+
+func (r *fakeOAuthClientRepository) RevokeClient(ctx context.Context, id uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, client := range r.clients {
+		if client.ID == id && !client.RevokedAt.Valid {
+			client.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeOAuthClientRepository) TouchClient(ctx context.Context, id uuid.UUID) error {
+	return nil
+}
+
+type fakeOutboxRepository struct {
+	repositories.OutboxRepository
+	mu     sync.Mutex
+	events map[uuid.UUID]*db.Outbox
+}
+
+func newFakeOutboxRepository() *fakeOutboxRepository {
+	return &fakeOutboxRepository{events: map[uuid.UUID]*db.Outbox{}}
+}
+
+// add writes a due event, as the repositories do in the transaction of the change.
+func (r *fakeOutboxRepository) add(eventType string, createdAt time.Time) *db.Outbox {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := uuid.New()
+	payload, _ := json.Marshal(map[string]string{"user_id": id.String()})
+	event := &db.Outbox{
+		ID:            id,
+		AggregateType: "user",
+		AggregateID:   uuid.New(),
+		EventType:     eventType,
+		Payload:       payload,
+		NextAttemptAt: createdAt,
+		CreatedAt:     createdAt,
+	}
+	r.events[id] = event
+	copied := *event
+	return &copied
+}
+
+func (r *fakeOutboxRepository) get(id uuid.UUID) db.Outbox {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.events[id]
+}
+
+// makeDue lets the backoff of the event run out.
+func (r *fakeOutboxRepository) makeDue(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[id].NextAttemptAt = time.Now().Add(-time.Millisecond)
+}
+
+func (r *fakeOutboxRepository) ClaimEvents(ctx context.Context, batchSize int32, leaseUntil time.Time) ([]db.Outbox, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var claimed []db.Outbox
+	for _, event := range r.events {
+		if int32(len(claimed)) == batchSize {
+			break
+		}
+		if event.PublishedAt.Valid || event.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		event.NextAttemptAt = leaseUntil
+		claimed = append(claimed, *event)
+	}
+	return claimed, nil
+}
+
+func (r *fakeOutboxRepository) MarkPublished(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[id].PublishedAt.Time, r.events[id].PublishedAt.Valid = time.Now(), true
+	r.events[id].LastError = ""
+	return nil
+}
+
+func (r *fakeOutboxRepository) MarkFailed(ctx context.Context, param *db.MarkOutboxEventFailedParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event := r.events[param.ID]
+	event.Attempts++
+	event.LastError = param.LastError
+	event.NextAttemptAt = param.NextAttemptAt
+	return nil
+}
+
+func (r *fakeOutboxRepository) DeletePublished(ctx context.Context, publishedBefore time.Time, batchSize int32) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for id, event := range r.events {
+		if deleted == int64(batchSize) {
+			break
+		}
+		if event.PublishedAt.Valid && event.PublishedAt.Time.Before(publishedBefore) {
+			delete(r.events, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *fakeOutboxRepository) CountPublished(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for _, event := range r.events {
+		if event.PublishedAt.Valid && event.PublishedAt.Time.Before(publishedBefore) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type fakeTenancyRepository struct {
+	repositories.TenancyRepository
+	mu          sync.Mutex
+	memberships map[uuid.UUID][]db.ListUserMembershipsRow
+}
+
+func newFakeTenancyRepository() *fakeTenancyRepository {
+	return &fakeTenancyRepository{memberships: map[uuid.UUID][]db.ListUserMembershipsRow{}}
+}
+
+// join makes the user a cashier of a new store of the organization.
+func (r *fakeTenancyRepository) join(userID uuid.UUID, organizationID uuid.UUID) uuid.UUID {
+	storeID := uuid.New()
+	r.joinStore(userID, storeID, organizationID, entities.StoreRoleCashier)
+	return storeID
+}
+
+func (r *fakeTenancyRepository) joinStore(userID uuid.UUID, storeID uuid.UUID, organizationID uuid.UUID, role string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.memberships[userID] = append(r.memberships[userID], db.ListUserMembershipsRow{StoreID: storeID, OrganizationID: organizationID, Role: role})
+}
+
+func (r *fakeTenancyRepository) ListUserMemberships(ctx context.Context, userID uuid.UUID) ([]db.ListUserMembershipsRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]db.ListUserMembershipsRow(nil), r.memberships[userID]...), nil
+}
+
+// GetStore knows the stores that have members.
+func (r *fakeTenancyRepository) GetStore(ctx context.Context, id uuid.UUID) (*db.Store, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, rows := range r.memberships {
+		for _, row := range rows {
+			if row.StoreID == id {
+				return &db.Store{ID: id, OrganizationID: row.OrganizationID}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: store %s", apperrors.ErrNotFound, id)
+}
+
+func (r *fakeTenancyRepository) GetStoreMembership(ctx context.Context, param *db.GetStoreMembershipParams) (*db.GetStoreMembershipRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, row := range r.memberships[param.UserID] {
+		if row.StoreID == param.StoreID {
+			return &db.GetStoreMembershipRow{UserID: param.UserID, StoreID: row.StoreID, Role: row.Role, OrganizationID: row.OrganizationID}, nil
+		}
+	}
+	return nil, apperrors.ErrNotStoreMember
+}
+
+// fakeRateLimiter counts requests per key in a window that never slides.
+type fakeRateLimiter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+	start  map[string]time.Time
+	err    error
+}
+
+func newFakeRateLimiter() *fakeRateLimiter {
+	return &fakeRateLimiter{counts: map[string]int64{}, start: map[string]time.Time{}}
+}
+
+func (l *fakeRateLimiter) Allow(ctx context.Context, key string, limit middlewares.RateLimit) (*middlewares.RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return nil, l.err
+	}
+	if _, ok := l.start[key]; !ok {
+		l.start[key] = time.Now()
+	}
+
+	allowed := l.counts[key] < limit.Limit
+	if allowed {
+		l.counts[key]++
+	}
+	return &middlewares.RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit.Limit,
+		Remaining: limit.Limit - l.counts[key],
+		ResetAt:   l.start[key].Add(limit.Window),
+	}, nil
+}
+
+const (
+	testSessionToken = "session-token"
+	testAPIKey       = "szk_0123abcd_secret"
+)
+
+// fakeAuthTokenService accepts the tokens it was given, testSessionToken and testAPIKey stand
+// for a password login and an API key of the same user.
+type fakeAuthTokenService struct {
+	token.TokenService
+	claims map[string]*token.JWTClaims
+}
+
+func newFakeAuthTokenService(userID uuid.UUID) *fakeAuthTokenService {
+	return &fakeAuthTokenService{claims: map[string]*token.JWTClaims{
+		testSessionToken: {UserID: userID, Username: "jane", EmailVerified: true, AMR: []string{entities.AMRPassword}},
+		testAPIKey:       {UserID: userID, Username: "jane", EmailVerified: true, AMR: []string{entities.AMRAPIKey}},
+	}}
+}
+
+func (s *fakeAuthTokenService) ValidateToken(ctx context.Context, tokenString string) (bool, *token.JWTClaims, string, error) {
+	claims, ok := s.claims[tokenString]
+	if !ok {
+		return false, nil, "Invalid token", nil
+	}
+	return true, claims, "", nil
+}
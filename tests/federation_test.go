@@ -0,0 +1,553 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/identity"
+)
+
+const (
+	fakeClientID     = "shopeezy-test"
+	fakeClientSecret = "s3cret"
+	fakeRedirectURL  = "http://localhost:3000/auth/callback"
+	fakeKeyID        = "test-key"
+)
+
+// fakeOIDCServer is a minimal OpenID Connect provider: discovery, JWKS, an authorize endpoint
+// that signs the user in right away, a token endpoint checking client secret and PKCE, and
+// userinfo.
+type fakeOIDCServer struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+
+	mu      sync.Mutex
+	codes   map[string]fakeAuthorization
+	tokens  map[string]string
+	profile fakeProfile
+	// signingKey replaces key when signing ID tokens, to simulate a forged token
+	signingKey *rsa.PrivateKey
+	// nonce replaces the nonce of the authorization request in ID tokens
+	nonce string
+}
+
+type fakeProfile struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type fakeAuthorization struct {
+	nonce         string
+	codeChallenge string
+	redirectURI   string
+}
+
+func newFakeOIDCServer(t *testing.T) *fakeOIDCServer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	s := &fakeOIDCServer{
+		t:      t,
+		key:    key,
+		codes:  map[string]fakeAuthorization{},
+		tokens: map[string]string{},
+		profile: fakeProfile{
+			Subject:       "provider-user-1",
+			Email:         "Jane.Doe@example.com",
+			EmailVerified: true,
+			Name:          "Jane Doe",
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/userinfo", s.userinfo)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *fakeOIDCServer) provider(t *testing.T) identity.Provider {
+	t.Helper()
+
+	provider, err := identity.NewOIDCProvider(identity.OIDCProviderConfig{
+		Name:         "fake",
+		Issuer:       s.URL,
+		ClientID:     fakeClientID,
+		ClientSecret: fakeClientSecret,
+		HTTPClient:   s.Client(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	return provider
+}
+
+func (s *fakeOIDCServer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.URL,
+		"authorization_endpoint":                s.URL + "/authorize",
+		"token_endpoint":                        s.URL + "/token",
+		"userinfo_endpoint":                     s.URL + "/userinfo",
+		"jwks_uri":                              s.URL + "/jwks",
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic"},
+	})
+}
+
+func (s *fakeOIDCServer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": fakeKeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *fakeOIDCServer) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != fakeClientID || query.Get("response_type") != "code" ||
+		query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" ||
+		!strings.Contains(query.Get("scope"), "openid") {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := uuid.NewString()
+	s.mu.Lock()
+	s.codes[code] = fakeAuthorization{
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		redirectURI:   query.Get("redirect_uri"),
+	}
+	s.mu.Unlock()
+
+	redirect, _ := url.Parse(query.Get("redirect_uri"))
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *fakeOIDCServer) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != fakeClientID || secret != fakeClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	s.mu.Lock()
+	code := r.PostForm.Get("code")
+	authorization, found := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !found || authorization.redirectURI != r.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != authorization.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	s.mu.Lock()
+	profile := s.profile
+	nonce := authorization.nonce
+	if s.nonce != "" {
+		nonce = s.nonce
+	}
+	signingKey := s.key
+	if s.signingKey != nil {
+		signingKey = s.signingKey
+	}
+	accessToken := uuid.NewString()
+	s.tokens[accessToken] = profile.Subject
+	s.mu.Unlock()
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            s.URL,
+		"sub":            profile.Subject,
+		"aud":            fakeClientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          profile.Email,
+		"email_verified": profile.EmailVerified,
+	})
+	idToken.Header["kid"] = fakeKeyID
+	signed, err := idToken.SignedString(signingKey)
+	if err != nil {
+		s.t.Errorf("failed to sign id token: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func (s *fakeOIDCServer) userinfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	subject, ok := s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	profile := s.profile
+	s.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sub":            subject,
+		"email":          profile.Email,
+		"email_verified": profile.EmailVerified,
+		"name":           profile.Name,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// signIn plays the browser: it opens the authorization URL and returns the code and state
+// the provider redirected back with.
+func signIn(t *testing.T, authURL string) (string, string) {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("failed to open authorization url: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %d", res.StatusCode)
+	}
+
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+	if got := location.Scheme + "://" + location.Host + location.Path; got != fakeRedirectURL {
+		t.Fatalf("redirected to %s, want %s", got, fakeRedirectURL)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestOIDCProviderFlow(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeOIDCServer(t)
+	provider := server.provider(t)
+
+	verifier := "a-code-verifier-that-is-long-enough-for-pkce-0123456789"
+	authURL, err := provider.AuthorizeURL(ctx, identity.AuthorizeOptions{
+		State:         "state-1",
+		Nonce:         "nonce-1",
+		CodeChallenge: identity.CodeChallenge(verifier),
+		RedirectURI:   fakeRedirectURL,
+	})
+	if err != nil {
+		t.Fatalf("AuthorizeURL: %v", err)
+	}
+
+	code, state := signIn(t, authURL)
+	if state != "state-1" {
+		t.Fatalf("state = %q, want state-1", state)
+	}
+
+	tok, err := provider.Exchange(ctx, code, identity.ExchangeOptions{
+		RedirectURI:  fakeRedirectURL,
+		CodeVerifier: verifier,
+		Nonce:        "nonce-1",
+	})
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	profile, err := provider.FetchProfile(ctx, tok)
+	if err != nil {
+		t.Fatalf("FetchProfile: %v", err)
+	}
+	if profile.Subject != "provider-user-1" || profile.Email != "Jane.Doe@example.com" || !profile.EmailVerified || profile.Name != "Jane Doe" {
+		t.Fatalf("unexpected profile %+v", profile)
+	}
+}
+
+func TestOIDCProviderRejectsInvalidTokens(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		setup    func(s *fakeOIDCServer)
+		verifier string
+	}{
+		{name: "nonce mismatch", setup: func(s *fakeOIDCServer) { s.nonce = "replayed-nonce" }},
+		{name: "forged signature", setup: func(s *fakeOIDCServer) { s.signingKey = otherKey }},
+		{name: "wrong code verifier", verifier: "not-the-verifier-of-the-authorization-request-000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			server := newFakeOIDCServer(t)
+			if tt.setup != nil {
+				tt.setup(server)
+			}
+			provider := server.provider(t)
+
+			verifier := "a-code-verifier-that-is-long-enough-for-pkce-0123456789"
+			authURL, err := provider.AuthorizeURL(ctx, identity.AuthorizeOptions{
+				State:         "state",
+				Nonce:         "nonce",
+				CodeChallenge: identity.CodeChallenge(verifier),
+				RedirectURI:   fakeRedirectURL,
+			})
+			if err != nil {
+				t.Fatalf("AuthorizeURL: %v", err)
+			}
+			code, _ := signIn(t, authURL)
+
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			_, err = provider.Exchange(ctx, code, identity.ExchangeOptions{
+				RedirectURI:  fakeRedirectURL,
+				CodeVerifier: verifier,
+				Nonce:        "nonce",
+			})
+			if !errors.Is(err, apperrors.ErrIdentityProvider) {
+				t.Fatalf("Exchange error = %v, want ErrIdentityProvider", err)
+			}
+		})
+	}
+}
+
+func TestFederatedLoginCreatesAndLinksAccounts(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeOIDCServer(t)
+
+	identities := newFakeIdentityRepository()
+	users := &fakeFederatedUserRepository{fakeUserRepository: newFakeUserRepository(), identities: identities}
+	log := newTestLogger(t)
+
+	svc := services.NewFederationService(
+		[]identity.Provider{server.provider(t)},
+		identities,
+		newFakeStateRepository(),
+		users,
+		services.FederationOptions{RedirectURL: fakeRedirectURL, StateTTL: time.Minute},
+		log,
+	)
+
+	login := func(t *testing.T) (*services.FederatedLogin, error) {
+		t.Helper()
+		authorization, err := svc.BeginLogin(ctx, "fake")
+		if err != nil {
+			t.Fatalf("BeginLogin: %v", err)
+		}
+		code, state := signIn(t, authorization.URL)
+		return svc.CompleteLogin(ctx, &models.FederatedCallbackRequest{Code: code, State: state})
+	}
+
+	// An account whose identity could not be linked is not kept, or the email would stay taken
+	users.linkErr = errors.New("connection reset")
+	if _, err := login(t); err == nil {
+		t.Fatal("login succeeded without linking the identity")
+	}
+	if _, err := users.GetUserByEmail(ctx, "Jane.Doe@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("account of the failed login was kept: %v", err)
+	}
+	users.linkErr = nil
+
+	// The first login creates the account with a verified email and no password
+	first, err := login(t)
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if !first.Created || first.User.Username != "jane.doe" || first.User.Email != "Jane.Doe@example.com" || !first.User.EmailVerified() {
+		t.Fatalf("unexpected first login %+v / %+v", first, first.User)
+	}
+	if users.byID[first.User.ID].Password != "" {
+		t.Fatal("federated account must not get a password")
+	}
+
+	// The next login signs in to the same account
+	second, err := login(t)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if second.Created || second.User.ID != first.User.ID {
+		t.Fatalf("second login created %v for user %s, want existing %s", second.Created, second.User.ID, first.User.ID)
+	}
+
+	// A state can only be used once
+	authorization, err := svc.BeginLogin(ctx, "fake")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	code, state := signIn(t, authorization.URL)
+	if _, err := svc.CompleteLogin(ctx, &models.FederatedCallbackRequest{Code: code, State: state}); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if _, err := svc.CompleteLogin(ctx, &models.FederatedCallbackRequest{Code: code, State: state}); !errors.Is(err, apperrors.ErrInvalidLoginState) {
+		t.Fatalf("replayed state error = %v, want ErrInvalidLoginState", err)
+	}
+
+	// The only sign-in method of a passwordless account can not be removed
+	linked, err := svc.ListIdentities(ctx, first.User.ID)
+	if err != nil || len(linked) != 1 {
+		t.Fatalf("ListIdentities = %v, %v", linked, err)
+	}
+	if err := svc.UnlinkIdentity(ctx, first.User.ID, linked[0].ID); !errors.Is(err, apperrors.ErrLastSignInMethod) {
+		t.Fatalf("unlink error = %v, want ErrLastSignInMethod", err)
+	}
+
+	// A new provider user whose email belongs to an existing account is not signed in to it
+	server.mu.Lock()
+	server.profile = fakeProfile{Subject: "provider-user-2", Email: "owner@example.com", EmailVerified: true}
+	server.mu.Unlock()
+	owner := users.add(&db.User{ID: uuid.New(), Name: "Owner", Username: "owner", Email: "owner@example.com", Password: "hash"})
+	if _, err := login(t); !errors.Is(err, apperrors.ErrFederatedEmailTaken) {
+		t.Fatalf("login with taken email error = %v, want ErrFederatedEmailTaken", err)
+	}
+
+	// The owner links it from their profile, then it signs in to their account
+	authorization, err = svc.BeginLink(ctx, owner.ID, "fake")
+	if err != nil {
+		t.Fatalf("BeginLink: %v", err)
+	}
+	code, state = signIn(t, authorization.URL)
+	if _, err := svc.CompleteLink(ctx, owner.ID, &models.FederatedCallbackRequest{Code: code, State: state}); err != nil {
+		t.Fatalf("CompleteLink: %v", err)
+	}
+	third, err := login(t)
+	if err != nil {
+		t.Fatalf("login after link: %v", err)
+	}
+	if third.Created || third.User.ID != owner.ID {
+		t.Fatalf("login after link signed in %s, want %s", third.User.ID, owner.ID)
+	}
+
+	// A link state can not complete a login
+	authorization, err = svc.BeginLink(ctx, owner.ID, "fake")
+	if err != nil {
+		t.Fatalf("BeginLink: %v", err)
+	}
+	code, state = signIn(t, authorization.URL)
+	if _, err := svc.CompleteLogin(ctx, &models.FederatedCallbackRequest{Code: code, State: state}); !errors.Is(err, apperrors.ErrInvalidLoginState) {
+		t.Fatalf("login with link state error = %v, want ErrInvalidLoginState", err)
+	}
+
+	if _, err := svc.BeginLogin(ctx, "unknown"); !errors.Is(err, apperrors.ErrUnknownProvider) {
+		t.Fatalf("unknown provider error = %v, want ErrUnknownProvider", err)
+	}
+}
+
+// In-memory repositories. Methods the federation service does not use are left to the
+// embedded interface and panic when called.
+
+// fakeFederatedUserRepository creates users and links their identity like one transaction: the
+// user is dropped again when the link fails.
+type fakeFederatedUserRepository struct {
+	*fakeUserRepository
+	identities *fakeIdentityRepository
+	// linkErr, when set, fails every link
+	linkErr error
+}
+
+func (r *fakeFederatedUserRepository) CreateFederatedUser(ctx context.Context, param *db.CreateUserParams, identity *db.CreateUserIdentityParams) (*db.User, error) {
+	user, err := r.CreateUser(ctx, param)
+	if err != nil {
+		return nil, err
+	}
+
+	identity.UserID = user.ID
+	if err = r.linkErr; err == nil {
+		_, err = r.identities.CreateIdentity(ctx, identity)
+	}
+	if err != nil {
+		r.mu.Lock()
+		delete(r.byID, user.ID)
+		r.mu.Unlock()
+		return nil, err
+	}
+	return user, nil
+}
+
+type fakeStateRepository struct {
+	mu     sync.Mutex
+	states map[string]models.FederationState
+}
+
+func newFakeStateRepository() *fakeStateRepository {
+	return &fakeStateRepository{states: map[string]models.FederationState{}}
+}
+
+func (r *fakeStateRepository) SaveState(ctx context.Context, state string, value *models.FederationState, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[state] = *value
+	return nil
+}
+
+func (r *fakeStateRepository) TakeState(ctx context.Context, state string) (*models.FederationState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	value, ok := r.states[state]
+	if !ok {
+		return nil, apperrors.ErrInvalidLoginState
+	}
+	delete(r.states, state)
+	return &value, nil
+}
@@ -0,0 +1,232 @@
+package test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+// writeKey writes a PEM file to a temporary directory and returns its path.
+func writeKey(t *testing.T, name string, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+// newRSAKey returns the paths of a fresh RSA private key and its public key.
+func newRSAKey(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal RSA public key: %v", err)
+	}
+	return writeKey(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)), writeKey(t, "rsa.pub.pem", "PUBLIC KEY", public)
+}
+
+// newEd25519Key returns the paths of a fresh Ed25519 private key and its public key.
+func newEd25519Key(t *testing.T) (string, string) {
+	t.Helper()
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate Ed25519 key: %v", err)
+	}
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal Ed25519 private key: %v", err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("marshal Ed25519 public key: %v", err)
+	}
+	return writeKey(t, "ed25519.pem", "PRIVATE KEY", private), writeKey(t, "ed25519.pub.pem", "PUBLIC KEY", public)
+}
+
+// issue returns an access token of the user signed by the service.
+func issue(t *testing.T, tokens token.TokenService, user *entities.User) string {
+	t.Helper()
+
+	pair, err := tokens.GenerateTokenPair(context.Background(), user, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	return pair.AccessToken
+}
+
+func header(t *testing.T, accessToken string) (alg string, kid string) {
+	t.Helper()
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(accessToken, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	kid, _ = parsed.Header["kid"].(string)
+	return parsed.Method.Alg(), kid
+}
+
+// resign signs the claims of accessToken again with key, under the given kid header.
+func resign(t *testing.T, accessToken string, method jwt.SigningMethod, kid string, key interface{}) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(accessToken, claims); err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	forged := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		forged.Header["kid"] = kid
+	}
+	signed, err := forged.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	rsaKey, _ := newRSAKey(t)
+	edKey, _ := newEd25519Key(t)
+
+	tests := []struct {
+		algorithm string
+		keyPath   string
+		keyID     string
+		wantKty   string
+	}{
+		{algorithm: token.AlgorithmRS256, keyPath: rsaKey, wantKty: "RSA"},
+		{algorithm: token.AlgorithmEdDSA, keyPath: edKey, wantKty: "OKP"},
+		{algorithm: token.AlgorithmRS256, keyPath: rsaKey, keyID: "2026-10", wantKty: "RSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm+" "+tt.keyID, func(t *testing.T) {
+			f := newTokenFixture(t)
+			tokens := f.tokenService(t, token.KeyConfig{Algorithm: tt.algorithm, PrivateKeyPath: tt.keyPath, KeyID: tt.keyID, Secret: testJWTSecret})
+			accessToken := issue(t, tokens, f.user("jane"))
+
+			jwks := tokens.JWKS()
+			if len(jwks.Keys) != 1 {
+				t.Fatalf("JWKS has %d keys, want only the signing key: %+v", len(jwks.Keys), jwks.Keys)
+			}
+			published := jwks.Keys[0]
+			if published.Kty != tt.wantKty || published.Alg != tt.algorithm || published.Use != "sig" {
+				t.Fatalf("published key %+v, want a %s %s signing key", published, tt.wantKty, tt.algorithm)
+			}
+			if tt.keyID != "" && published.Kid != tt.keyID {
+				t.Fatalf("kid = %q, want the configured %q", published.Kid, tt.keyID)
+			}
+
+			if alg, kid := header(t, accessToken); alg != tt.algorithm || kid != published.Kid {
+				t.Fatalf("token signed with %s under kid %q, want %s under %q", alg, kid, tt.algorithm, published.Kid)
+			}
+			if valid, msg := f.validate(t, accessToken); valid {
+				t.Fatalf("the HS256 service accepted a %s token: %s", tt.algorithm, msg)
+			}
+			valid, _, msg, _ := tokens.ValidateToken(context.Background(), accessToken)
+			if !valid {
+				t.Fatalf("token was rejected: %s", msg)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	f := newTokenFixture(t)
+	user := f.user("jane")
+	oldKey, oldPublic := newRSAKey(t)
+	newKey, _ := newEd25519Key(t)
+	otherKey, _ := newEd25519Key(t)
+
+	before := f.tokenService(t, token.KeyConfig{Algorithm: token.AlgorithmRS256, PrivateKeyPath: oldKey})
+	after := f.tokenService(t, token.KeyConfig{Algorithm: token.AlgorithmEdDSA, PrivateKeyPath: newKey, VerificationKeyPaths: []string{oldPublic}})
+	other := f.tokenService(t, token.KeyConfig{Algorithm: token.AlgorithmEdDSA, PrivateKeyPath: otherKey})
+
+	oldToken, newToken := issue(t, before, user), issue(t, after, user)
+	_, oldKid := header(t, oldToken)
+	_, newKid := header(t, newToken)
+
+	jwks := after.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != newKid || jwks.Keys[1].Kid != oldKid {
+		t.Fatalf("JWKS %+v, want the signing key %s first, then the retired key %s", jwks.Keys, newKid, oldKid)
+	}
+
+	_, otherSigner, _ := ed25519.GenerateKey(rand.Reader)
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "token of the new key", token: newToken, valid: true},
+		{name: "token of the retired key", token: oldToken, valid: true},
+		{name: "token of an unknown key", token: issue(t, other, user)},
+		{name: "token signed by another key under a known kid", token: resign(t, oldToken, jwt.SigningMethodEdDSA, newKid, otherSigner)},
+		{name: "token without a kid", token: resign(t, oldToken, jwt.SigningMethodEdDSA, "", otherSigner)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if valid, _, msg, _ := after.ValidateToken(context.Background(), tt.token); valid != tt.valid {
+				t.Fatalf("valid = %t, want %t: %s", valid, tt.valid, msg)
+			}
+		})
+	}
+}
+
+func TestLegacyHMACVerificationIsOptInAndExpires(t *testing.T) {
+	f := newTokenFixture(t)
+	user := f.user("jane")
+	rsaKey, _ := newRSAKey(t)
+
+	// Issued by the HS256 setup before the switch, and minted by a service holding the secret
+	hmacToken := issue(t, f.tokens, user)
+	kidless := resign(t, hmacToken, jwt.SigningMethodHS256, "", []byte(testJWTSecret))
+
+	tests := []struct {
+		name  string
+		until time.Time
+		valid bool
+	}{
+		{name: "not enabled"},
+		{name: "enabled", until: time.Now().Add(time.Hour), valid: true},
+		{name: "ended", until: time.Now().Add(-time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := f.tokenService(t, token.KeyConfig{Algorithm: token.AlgorithmRS256, PrivateKeyPath: rsaKey, Secret: testJWTSecret, LegacyHMACUntil: tt.until})
+			for _, accessToken := range []string{hmacToken, kidless} {
+				if valid, _, msg, _ := tokens.ValidateToken(context.Background(), accessToken); valid != tt.valid {
+					t.Fatalf("valid = %t, want %t: %s", valid, tt.valid, msg)
+				}
+			}
+			for _, key := range tokens.JWKS().Keys {
+				if key.Kty != "RSA" {
+					t.Fatalf("JWKS publishes a %s key", key.Kty)
+				}
+			}
+		})
+	}
+
+	if _, err := token.LoadKeySet(token.KeyConfig{Algorithm: token.AlgorithmRS256, PrivateKeyPath: rsaKey, LegacyHMACUntil: time.Now().Add(time.Hour)}); err == nil {
+		t.Fatal("LoadKeySet accepted legacy HS256 verification without a secret")
+	}
+}
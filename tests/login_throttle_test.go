@@ -0,0 +1,233 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/audit"
+)
+
+const testPassword = "correct horse"
+
+// loginFixture is a user service whose logins go through the real throttle on in-memory
+// counters and locks.
+type loginFixture struct {
+	*tokenFixture
+	service  services.UserService
+	throttle services.LoginThrottleService
+	locks    *fakeLoginLockRepository
+}
+
+func newLoginFixture(t *testing.T, opts services.LoginThrottleOptions) *loginFixture {
+	t.Helper()
+
+	f := &loginFixture{tokenFixture: newTokenFixture(t), locks: newFakeLoginLockRepository()}
+	f.throttle = services.NewLoginThrottleService(f.tokenFixture.users, newFakeAttemptRepository(), f.locks, opts, f.log)
+	f.service = services.NewUserService(f.tokenFixture.users, validator.New(), f.tokens, f.blacklist, f.throttle,
+		services.EmailVerificationOff, audit.NewRecorder(f.audit, f.log), f.log)
+	return f
+}
+
+// account adds a user that logs in with testPassword.
+func (f *loginFixture) account(t *testing.T, username string) *entities.User {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user := f.user(username)
+	f.tokenFixture.users.byID[user.ID].Password = string(hash)
+	return user
+}
+
+func (f *loginFixture) login(username, password, ipAddress string) error {
+	_, err := f.service.Login(context.Background(), &models.UserLoginRequest{Username: username, Password: password, IPAddress: ipAddress})
+	return err
+}
+
+// failUntilLocked logs in with a wrong password until the account locks and returns the lock.
+func (f *loginFixture) failUntilLocked(t *testing.T, username string, maxAttempts int) *apperrors.RetryAfterError {
+	t.Helper()
+
+	for i := 1; i < maxAttempts; i++ {
+		if err := f.login(username, "wrong", "198.51.100.1"); !errors.Is(err, apperrors.ErrInvalidCredentials) {
+			t.Fatalf("failure %d error = %v, want ErrInvalidCredentials", i, err)
+		}
+	}
+
+	var locked *apperrors.RetryAfterError
+	if err := f.login(username, "wrong", "198.51.100.1"); !errors.As(err, &locked) || !errors.Is(err, apperrors.ErrAccountLocked) {
+		t.Fatalf("failure %d error = %v, want ErrAccountLocked", maxAttempts, err)
+	}
+	return locked
+}
+
+func TestLoginLocksOutWithExponentialBackoff(t *testing.T) {
+	f := newLoginFixture(t, services.LoginThrottleOptions{
+		MaxAttempts:        3,
+		IPMaxAttempts:      100,
+		AttemptWindow:      time.Hour,
+		LockoutDuration:    time.Minute,
+		MaxLockoutDuration: 4 * time.Minute,
+	})
+	user := f.account(t, "jane")
+
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+		locked := f.failUntilLocked(t, user.Username, 3)
+		if locked.RetryAfter != want {
+			t.Fatalf("lockout = %s, want %s", locked.RetryAfter, want)
+		}
+
+		var retryErr *apperrors.RetryAfterError
+		if err := f.login(user.Username, testPassword, "198.51.100.1"); !errors.As(err, &retryErr) || !errors.Is(err, apperrors.ErrAccountLocked) {
+			t.Fatalf("login during the lockout error = %v, want ErrAccountLocked", err)
+		}
+		if retryErr.RetryAfter <= 0 {
+			t.Fatalf("login during the lockout has no Retry-After")
+		}
+
+		f.locks.expire(user.Username)
+	}
+
+	if err := f.login(user.Username, testPassword, "198.51.100.1"); err != nil {
+		t.Fatalf("login after the lockout expired: %v", err)
+	}
+}
+
+func TestLoginSuccessResetsTheFailureCount(t *testing.T) {
+	f := newLoginFixture(t, services.LoginThrottleOptions{
+		MaxAttempts:        3,
+		IPMaxAttempts:      100,
+		AttemptWindow:      time.Hour,
+		LockoutDuration:    time.Minute,
+		MaxLockoutDuration: time.Hour,
+	})
+	user := f.account(t, "jane")
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 2; i++ {
+			if err := f.login(user.Username, "wrong", ""); !errors.Is(err, apperrors.ErrInvalidCredentials) {
+				t.Fatalf("round %d failure error = %v, want ErrInvalidCredentials", round, err)
+			}
+		}
+		if err := f.login(user.Username, testPassword, ""); err != nil {
+			t.Fatalf("round %d login: %v", round, err)
+		}
+	}
+}
+
+func TestAdministratorUnlockClearsTheBackoff(t *testing.T) {
+	ctx := context.Background()
+	f := newLoginFixture(t, services.LoginThrottleOptions{
+		MaxAttempts:        2,
+		IPMaxAttempts:      100,
+		AttemptWindow:      time.Hour,
+		LockoutDuration:    time.Minute,
+		MaxLockoutDuration: time.Hour,
+	})
+	user := f.account(t, "jane")
+
+	f.failUntilLocked(t, user.Username, 2)
+	status, err := f.throttle.GetLockStatus(ctx, user.Username)
+	if err != nil || !status.Locked {
+		t.Fatalf("GetLockStatus = %+v, %v, want locked", status, err)
+	}
+
+	if err := f.throttle.Unlock(ctx, user.ID); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := f.login(user.Username, testPassword, ""); err != nil {
+		t.Fatalf("login after Unlock: %v", err)
+	}
+
+	// The lockout history went with the lock, the next lockout starts at the base duration again
+	if locked := f.failUntilLocked(t, user.Username, 2); locked.RetryAfter != time.Minute {
+		t.Fatalf("lockout after Unlock = %s, want %s", locked.RetryAfter, time.Minute)
+	}
+}
+
+func TestLoginThrottlesAnIPAcrossUsernames(t *testing.T) {
+	f := newLoginFixture(t, services.LoginThrottleOptions{
+		MaxAttempts:        100,
+		IPMaxAttempts:      3,
+		AttemptWindow:      time.Hour,
+		LockoutDuration:    time.Minute,
+		MaxLockoutDuration: time.Hour,
+	})
+	user := f.account(t, "jane")
+
+	for _, username := range []string{"alice", "bob", "carol"} {
+		if err := f.login(username, "wrong", "203.0.113.7"); !errors.Is(err, apperrors.ErrInvalidCredentials) {
+			t.Fatalf("login as %s error = %v, want ErrInvalidCredentials", username, err)
+		}
+	}
+
+	if err := f.login(user.Username, testPassword, "203.0.113.7"); !errors.Is(err, apperrors.ErrTooManyAttempts) {
+		t.Fatalf("login from the throttled IP error = %v, want ErrTooManyAttempts", err)
+	}
+	if err := f.login(user.Username, testPassword, "203.0.113.8"); err != nil {
+		t.Fatalf("login from another IP: %v", err)
+	}
+
+	var failures int
+	for _, event := range f.audit.events {
+		if event.Action == entities.AuditLoginFailed && strings.Contains(string(event.Metadata), entities.AuditReasonRateLimited) {
+			failures++
+		}
+	}
+	if failures != 1 {
+		t.Fatalf("%d rate limited login failures audited, want 1", failures)
+	}
+}
+
+// fakeLoginLockRepository keeps locks until they expire or are expired by the test.
+type fakeLoginLockRepository struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func newFakeLoginLockRepository() *fakeLoginLockRepository {
+	return &fakeLoginLockRepository{until: map[string]time.Time{}}
+}
+
+func (r *fakeLoginLockRepository) expire(username string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.until, strings.ToLower(username))
+}
+
+func (r *fakeLoginLockRepository) Lock(ctx context.Context, username string, duration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.until[username] = time.Now().Add(duration)
+	return nil
+}
+
+func (r *fakeLoginLockRepository) LockedFor(ctx context.Context, username string) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if remaining := time.Until(r.until[username]); remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+func (r *fakeLoginLockRepository) Unlock(ctx context.Context, username string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.until, username)
+	return nil
+}
@@ -0,0 +1,218 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+const testMFAMaxAttempts = 3
+
+func newTestMFAService(f *tokenFixture, mfa *fakeMFARepository) services.MFAService {
+	return services.NewMFAService(mfa, f.users, newFakeAttemptRepository(), f.tokens, nil, "Shopeezy", 5*time.Minute, testMFAMaxAttempts, f.log)
+}
+
+func TestMFAChallengeIsSingleUse(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	user := f.user("jane")
+	mfa := newFakeMFARepository()
+	mfa.enable(user.ID, "aaaa1111", "bbbb2222", "cccc3333")
+	svc := newTestMFAService(f, mfa)
+
+	challenge, _, err := svc.IssueChallenge(ctx, user.ID, []string{entities.AMRPassword})
+	if err != nil {
+		t.Fatalf("IssueChallenge: %v", err)
+	}
+
+	// A wrong code does not use up the challenge
+	if _, _, err := svc.VerifyLogin(ctx, &models.MFAVerifyRequest{MFAToken: challenge, RecoveryCode: "wrong000"}); !errors.Is(err, apperrors.ErrInvalidMFACode) {
+		t.Fatalf("wrong code error = %v, want ErrInvalidMFACode", err)
+	}
+
+	verified, amr, err := svc.VerifyLogin(ctx, &models.MFAVerifyRequest{MFAToken: challenge, RecoveryCode: "aaaa1111"})
+	if err != nil {
+		t.Fatalf("VerifyLogin: %v", err)
+	}
+	if verified.ID != user.ID || len(amr) != 2 || amr[0] != entities.AMRPassword || amr[1] != entities.AMRMFA {
+		t.Fatalf("VerifyLogin = %s %v, want %s [pwd mfa]", verified.ID, amr, user.ID)
+	}
+
+	// The answered challenge can not be replayed with another valid code
+	if _, _, err := svc.VerifyLogin(ctx, &models.MFAVerifyRequest{MFAToken: challenge, RecoveryCode: "bbbb2222"}); !errors.Is(err, apperrors.ErrInvalidToken) {
+		t.Fatalf("replay error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestMFAChallengeCanNotBeAnsweredConcurrently(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	user := f.user("jane")
+	mfa := newFakeMFARepository()
+	mfa.enable(user.ID, "aaaa1111", "bbbb2222")
+	// Hold every code check until a second one arrives, or for a while when none does
+	mfa.barrier = make(chan struct{})
+	svc := newTestMFAService(f, mfa)
+
+	challenge, _, err := svc.IssueChallenge(ctx, user.ID, []string{entities.AMRPassword})
+	if err != nil {
+		t.Fatalf("IssueChallenge: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, code := range []string{"aaaa1111", "bbbb2222"} {
+		wg.Add(1)
+		go func(i int, code string) {
+			defer wg.Done()
+			_, _, errs[i] = svc.VerifyLogin(ctx, &models.MFAVerifyRequest{MFAToken: challenge, RecoveryCode: code})
+		}(i, code)
+	}
+	wg.Wait()
+
+	var passed int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			passed++
+		case !errors.Is(err, apperrors.ErrInvalidToken):
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if passed != 1 {
+		t.Fatalf("%d concurrent answers passed, want 1", passed)
+	}
+}
+
+func TestMFAAttemptsAreLimited(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	user := f.user("jane")
+	mfa := newFakeMFARepository()
+	mfa.enable(user.ID, "aaaa1111")
+	svc := newTestMFAService(f, mfa)
+
+	challenge, _, err := svc.IssueChallenge(ctx, user.ID, []string{entities.AMRPassword})
+	if err != nil {
+		t.Fatalf("IssueChallenge: %v", err)
+	}
+
+	for i := 0; i < testMFAMaxAttempts; i++ {
+		if _, _, err := svc.VerifyLogin(ctx, &models.MFAVerifyRequest{MFAToken: challenge, RecoveryCode: "wrong000"}); !errors.Is(err, apperrors.ErrInvalidMFACode) {
+			t.Fatalf("attempt %d error = %v, want ErrInvalidMFACode", i+1, err)
+		}
+	}
+	if _, _, err := svc.VerifyLogin(ctx, &models.MFAVerifyRequest{MFAToken: challenge, RecoveryCode: "aaaa1111"}); !errors.Is(err, apperrors.ErrTooManyAttempts) {
+		t.Fatalf("error after the limit = %v, want ErrTooManyAttempts", err)
+	}
+}
+
+func TestMFAAttemptsAreNotResetByANewChallenge(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	user := f.user("jane")
+	mfa := newFakeMFARepository()
+	mfa.enable(user.ID, "aaaa1111")
+	svc := newTestMFAService(f, mfa)
+
+	// Every login with the password mints a new challenge, the guesses carry over
+	for i := 0; i < testMFAMaxAttempts; i++ {
+		challenge, _, err := svc.IssueChallenge(ctx, user.ID, []string{entities.AMRPassword})
+		if err != nil {
+			t.Fatalf("IssueChallenge: %v", err)
+		}
+		if _, _, err := svc.VerifyLogin(ctx, &models.MFAVerifyRequest{MFAToken: challenge, RecoveryCode: "wrong000"}); !errors.Is(err, apperrors.ErrInvalidMFACode) {
+			t.Fatalf("attempt %d error = %v, want ErrInvalidMFACode", i+1, err)
+		}
+	}
+	challenge, _, err := svc.IssueChallenge(ctx, user.ID, []string{entities.AMRPassword})
+	if err != nil {
+		t.Fatalf("IssueChallenge: %v", err)
+	}
+	if _, _, err := svc.VerifyLogin(ctx, &models.MFAVerifyRequest{MFAToken: challenge, RecoveryCode: "aaaa1111"}); !errors.Is(err, apperrors.ErrTooManyAttempts) {
+		t.Fatalf("error on a new challenge after the limit = %v, want ErrTooManyAttempts", err)
+	}
+}
+
+type fakeMFARepository struct {
+	repositories.MFARepository
+	mu       sync.Mutex
+	settings map[uuid.UUID]*db.UserMfa
+	// recovery code hashes per user, true once used
+	recovery map[uuid.UUID]map[string]bool
+	// barrier, when set, pairs up concurrent recovery code checks
+	barrier chan struct{}
+}
+
+func newFakeMFARepository() *fakeMFARepository {
+	return &fakeMFARepository{settings: map[uuid.UUID]*db.UserMfa{}, recovery: map[uuid.UUID]map[string]bool{}}
+}
+
+// enable turns MFA on for the user with the given recovery codes.
+func (r *fakeMFARepository) enable(userID uuid.UUID, recoveryCodes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.settings[userID] = &db.UserMfa{UserID: userID, TotpSecret: "JBSWY3DPEHPK3PXP", EnabledAt: sql.NullTime{Time: time.Now(), Valid: true}}
+	r.recovery[userID] = map[string]bool{}
+	for _, code := range recoveryCodes {
+		r.recovery[userID][helpers.HashToken(code)] = false
+	}
+}
+
+func (r *fakeMFARepository) GetUserMFA(ctx context.Context, userID uuid.UUID) (*db.UserMfa, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	settings, ok := r.settings[userID]
+	if !ok {
+		return nil, apperrors.ErrMFANotEnrolled
+	}
+	copied := *settings
+	return &copied, nil
+}
+
+func (r *fakeMFARepository) UpdateLastUsedStep(ctx context.Context, param *db.UpdateMFALastUsedStepParams) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	settings, ok := r.settings[param.UserID]
+	if !ok || param.LastUsedStep <= settings.LastUsedStep {
+		return false, nil
+	}
+	settings.LastUsedStep = param.LastUsedStep
+	return true, nil
+}
+
+func (r *fakeMFARepository) UseRecoveryCode(ctx context.Context, param *db.UseRecoveryCodeParams) (bool, error) {
+	if r.barrier != nil {
+		select {
+		case r.barrier <- struct{}{}:
+		case <-r.barrier:
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	used, ok := r.recovery[param.UserID][param.CodeHash]
+	if !ok || used {
+		return false, nil
+	}
+	r.recovery[param.UserID][param.CodeHash] = true
+	return true, nil
+}
@@ -0,0 +1,153 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/middlewares"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+func TestClientCredentialsGrant(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	clients := newFakeOAuthClientRepository()
+	svc := services.NewOAuthService(clients, f.tokens, time.Minute, f.log)
+
+	client, secret, err := svc.RegisterClient(ctx, uuid.New(), &models.CreateOAuthClientRequest{Name: "orders", Scopes: []string{entities.PermUsersRead}})
+	if err != nil {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+	if secret == "" {
+		t.Fatal("RegisterClient returned no secret")
+	}
+	if stored, _ := clients.GetClientByClientID(ctx, client.ClientID); stored.SecretHash != helpers.HashToken(secret) {
+		t.Fatal("the client secret is not stored as its hash")
+	}
+	clients.add(&db.OauthClient{ClientID: "web-app", IsPublic: true, FirstParty: true, GrantTypes: []string{entities.GrantAuthorizationCode}})
+
+	failures := []struct {
+		name     string
+		clientID string
+		secret   string
+		scope    string
+		wantErr  error
+	}{
+		{name: "wrong secret", clientID: client.ClientID, secret: "not-the-secret", wantErr: apperrors.ErrInvalidClient},
+		{name: "missing secret", clientID: client.ClientID, wantErr: apperrors.ErrInvalidClient},
+		{name: "unknown client", clientID: "svc-unknown", secret: secret, wantErr: apperrors.ErrInvalidClient},
+		{name: "scope the client was not registered for", clientID: client.ClientID, secret: secret, scope: entities.PermUsersManage, wantErr: apperrors.ErrInvalidScope},
+		{name: "client without the grant", clientID: "web-app", wantErr: apperrors.ErrUnauthorizedClient},
+	}
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := svc.IssueClientCredentialsToken(ctx, tt.clientID, tt.secret, tt.scope); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("IssueClientCredentialsToken error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	serviceToken, scopes, _, err := svc.IssueClientCredentialsToken(ctx, client.ClientID, secret, "")
+	if err != nil {
+		t.Fatalf("IssueClientCredentialsToken: %v", err)
+	}
+	if len(scopes) != 1 || scopes[0] != entities.PermUsersRead {
+		t.Fatalf("granted scopes %v, want [%s]", scopes, entities.PermUsersRead)
+	}
+	claims, err := svc.ValidateServiceToken(ctx, serviceToken)
+	if err != nil {
+		t.Fatalf("ValidateServiceToken: %v", err)
+	}
+	if claims.ClientID != client.ClientID || !claims.HasScope(entities.PermUsersRead) {
+		t.Fatalf("service token claims %+v, want client %s with %s", claims, client.ClientID, entities.PermUsersRead)
+	}
+
+	// Service and user tokens are not interchangeable
+	if valid, _ := f.validate(t, serviceToken); valid {
+		t.Fatal("ValidateToken accepted a service token")
+	}
+	if _, err := svc.ValidateServiceToken(ctx, f.accessToken(t, f.user("jane"))); err == nil {
+		t.Fatal("ValidateServiceToken accepted a user token")
+	}
+
+	if err := svc.RevokeClient(ctx, client.ID); err != nil {
+		t.Fatalf("RevokeClient: %v", err)
+	}
+	if _, err := svc.ValidateServiceToken(ctx, serviceToken); !errors.Is(err, apperrors.ErrInvalidToken) {
+		t.Fatalf("token of a revoked client error = %v, want ErrInvalidToken", err)
+	}
+	if _, _, _, err := svc.IssueClientCredentialsToken(ctx, client.ClientID, secret, ""); !errors.Is(err, apperrors.ErrInvalidClient) {
+		t.Fatalf("revoked client error = %v, want ErrInvalidClient", err)
+	}
+}
+
+func TestGRPCServiceAuthInterceptor(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	clients := newFakeOAuthClientRepository()
+	svc := services.NewOAuthService(clients, f.tokens, time.Minute, f.log)
+
+	client, secret, err := svc.RegisterClient(ctx, uuid.New(), &models.CreateOAuthClientRequest{Name: "orders", Scopes: []string{entities.PermUsersRead}})
+	if err != nil {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+	serviceToken, _, _, err := svc.IssueClientCredentialsToken(ctx, client.ClientID, secret, "")
+	if err != nil {
+		t.Fatalf("IssueClientCredentialsToken: %v", err)
+	}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	newInterceptor := func(permissive bool) grpc.UnaryServerInterceptor {
+		return middlewares.UnaryServiceAuthInterceptor(middlewares.GRPCServiceAuthOptions{
+			Validator: svc,
+			Services:  []string{"account.AccountService"},
+			Methods: map[string]string{
+				"/account.AccountService/GetUser":    entities.PermUsersRead,
+				"/account.AccountService/DeleteUser": entities.PermUsersManage,
+			},
+			Permissive: permissive,
+		})
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		bearer     string
+		permissive bool
+		want       codes.Code
+	}{
+		{name: "granted scope", method: "/account.AccountService/GetUser", bearer: serviceToken, want: codes.OK},
+		{name: "missing token", method: "/account.AccountService/GetUser", want: codes.Unauthenticated},
+		{name: "user token", method: "/account.AccountService/GetUser", bearer: f.accessToken(t, f.user("jane")), want: codes.Unauthenticated},
+		{name: "missing scope", method: "/account.AccountService/DeleteUser", bearer: serviceToken, want: codes.PermissionDenied},
+		{name: "method not listed", method: "/account.AccountService/ListUsers", bearer: serviceToken, want: codes.PermissionDenied},
+		{name: "other service", method: "/auth.AuthService/ValidateToken", want: codes.OK},
+		{name: "permissive mode", method: "/account.AccountService/GetUser", permissive: true, want: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callCtx := ctx
+			if tt.bearer != "" {
+				callCtx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+tt.bearer))
+			}
+			_, err := newInterceptor(tt.permissive)(callCtx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if code := status.Code(err); code != tt.want {
+				t.Fatalf("code = %s, want %s (%v)", code, tt.want, err)
+			}
+		})
+	}
+}
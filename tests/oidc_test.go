@@ -0,0 +1,348 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+func TestUserInfoTokensOnlyReachUserInfo(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	user := f.user("jane")
+
+	userInfoToken, _, err := f.tokens.GenerateUserInfoToken(ctx, user, []string{entities.ScopeOpenID, entities.ScopeEmail}, time.Minute, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateUserInfoToken: %v", err)
+	}
+	pair, err := f.tokens.GenerateTokenPair(ctx, user, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+
+	if valid, _ := f.validate(t, userInfoToken); valid {
+		t.Fatal("ValidateToken accepted a userinfo token")
+	}
+
+	for name, accessToken := range map[string]string{"userinfo token": userInfoToken, "session token": pair.AccessToken} {
+		valid, claims, msg, err := f.tokens.ValidateUserInfoToken(ctx, accessToken)
+		if err != nil || !valid {
+			t.Fatalf("ValidateUserInfoToken refused the %s: %s %v", name, msg, err)
+		}
+		if claims.UserID != user.ID {
+			t.Fatalf("ValidateUserInfoToken returned user %s for the %s, want %s", claims.UserID, name, user.ID)
+		}
+	}
+
+	if valid, _, _, _ := f.tokens.ValidateUserInfoToken(ctx, token.APIKeyPrefix+"0123abcd_secret"); valid {
+		t.Fatal("ValidateUserInfoToken accepted an API key")
+	}
+}
+
+func TestOIDCRefreshIsBoundToTheClient(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	user := f.user("jane")
+
+	clients := newFakeOAuthClientRepository()
+	grants := []string{entities.GrantAuthorizationCode, entities.GrantRefreshToken}
+	clients.add(&db.OauthClient{ClientID: "app-a", IsPublic: true, FirstParty: true, GrantTypes: grants})
+	clients.add(&db.OauthClient{ClientID: "app-b", IsPublic: true, FirstParty: true, GrantTypes: grants})
+	// Registration never grants refresh tokens to third parties, a client edited in the database might
+	clients.add(&db.OauthClient{ClientID: "third-party", IsPublic: true, GrantTypes: grants})
+	svc := services.NewOIDCService(clients, nil, f.users, f.tokens, services.OIDCOptions{Issuer: "https://accounts.example.com", AccessTokenTTL: time.Minute}, f.log)
+
+	issue := func(clientID string) string {
+		t.Helper()
+		pair, err := f.tokens.GenerateTokenPair(ctx, user, token.IssueOptions{AMR: []string{entities.AMRPassword}, ClientID: clientID})
+		if err != nil {
+			t.Fatalf("GenerateTokenPair: %v", err)
+		}
+		return pair.RefreshToken
+	}
+	ofAppA, ofLogin := issue("app-a"), issue("")
+
+	if _, err := svc.RefreshTokens(ctx, "third-party", "", ofAppA, token.IssueOptions{}); !errors.Is(err, apperrors.ErrUnauthorizedClient) {
+		t.Fatalf("third-party client error = %v, want ErrUnauthorizedClient", err)
+	}
+	if _, err := svc.RefreshTokens(ctx, "app-b", "", ofAppA, token.IssueOptions{}); !errors.Is(err, apperrors.ErrInvalidToken) {
+		t.Fatalf("other client error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := f.tokens.Refresh(ctx, ofAppA, token.IssueOptions{}); !errors.Is(err, apperrors.ErrInvalidToken) {
+		t.Fatalf("/token/refresh error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := svc.RefreshTokens(ctx, "app-a", "", ofLogin, token.IssueOptions{}); !errors.Is(err, apperrors.ErrInvalidToken) {
+		t.Fatalf("login token error = %v, want ErrInvalidToken", err)
+	}
+
+	// The rejected attempts did not use the token up
+	rotated, err := svc.RefreshTokens(ctx, "app-a", "", ofAppA, token.IssueOptions{})
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	if _, err := svc.RefreshTokens(ctx, "app-a", "", rotated.RefreshToken, token.IssueOptions{}); err != nil {
+		t.Fatalf("rotated token of the client was refused: %v", err)
+	}
+}
+
+const (
+	testRedirectURI = "https://app.example.com/callback"
+	// The PKCE example of RFC 7636 appendix B
+	testCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+type oidcFixture struct {
+	*tokenFixture
+	clients *fakeOAuthClientRepository
+	codes   *fakeAuthorizationCodeRepository
+	svc     services.OIDCService
+}
+
+func newOIDCFixture(t *testing.T) *oidcFixture {
+	t.Helper()
+
+	f := &oidcFixture{tokenFixture: newTokenFixture(t), clients: newFakeOAuthClientRepository(), codes: newFakeAuthorizationCodeRepository()}
+	scopes := []string{entities.ScopeOpenID, entities.ScopeProfile, entities.ScopeEmail}
+	f.clients.add(&db.OauthClient{ClientID: "web-app", IsPublic: true, FirstParty: true, Scopes: scopes, RedirectUris: []string{testRedirectURI},
+		GrantTypes: []string{entities.GrantAuthorizationCode, entities.GrantRefreshToken}})
+	f.clients.add(&db.OauthClient{ClientID: "partner", IsPublic: true, Scopes: scopes, RedirectUris: []string{testRedirectURI},
+		GrantTypes: []string{entities.GrantAuthorizationCode}})
+	f.svc = services.NewOIDCService(f.clients, f.codes, f.users, f.tokens, services.OIDCOptions{
+		Issuer:         "https://accounts.example.com",
+		CodeTTL:        time.Minute,
+		AccessTokenTTL: time.Minute,
+	}, f.log)
+	return f
+}
+
+func authorizationRequest(clientID string) *models.AuthorizationRequest {
+	return &models.AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            clientID,
+		RedirectURI:         testRedirectURI,
+		Scope:               "openid email",
+		State:               "xyz",
+		Nonce:               "n-0S6_WzA2Mj",
+		CodeChallenge:       testCodeChallenge,
+		CodeChallengeMethod: "S256",
+	}
+}
+
+// authorize signs the user in to the client and returns the code from the redirect.
+func (f *oidcFixture) authorize(t *testing.T, clientID string, user *entities.User) string {
+	t.Helper()
+
+	grant, err := f.svc.ValidateAuthorizationRequest(context.Background(), authorizationRequest(clientID))
+	if err != nil {
+		t.Fatalf("ValidateAuthorizationRequest: %v", err)
+	}
+	redirect, err := f.svc.IssueAuthorizationCode(context.Background(), grant, user, []string{entities.AMRPassword})
+	if err != nil {
+		t.Fatalf("IssueAuthorizationCode: %v", err)
+	}
+
+	u, err := url.Parse(redirect)
+	if err != nil || !strings.HasPrefix(redirect, testRedirectURI+"?") {
+		t.Fatalf("redirect %q does not go to the registered URI", redirect)
+	}
+	if u.Query().Get("state") != "xyz" || u.Query().Get("iss") != "https://accounts.example.com" {
+		t.Fatalf("redirect %q lacks the state or issuer", redirect)
+	}
+	return u.Query().Get("code")
+}
+
+func TestAuthorizationRequestValidation(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	tests := []struct {
+		name    string
+		modify  func(req *models.AuthorizationRequest)
+		wantErr error
+	}{
+		{name: "unknown client", modify: func(req *models.AuthorizationRequest) { req.ClientID = "unknown" }, wantErr: apperrors.ErrInvalidClient},
+		{name: "unregistered redirect URI", modify: func(req *models.AuthorizationRequest) { req.RedirectURI = "https://evil.example.com/callback" }, wantErr: apperrors.ErrInvalidRedirectURI},
+		{name: "missing code challenge", modify: func(req *models.AuthorizationRequest) { req.CodeChallenge, req.CodeChallengeMethod = "", "" }, wantErr: apperrors.ErrInvalidRequestPayload},
+		{name: "plain code challenge", modify: func(req *models.AuthorizationRequest) { req.CodeChallengeMethod = "plain" }, wantErr: apperrors.ErrInvalidRequestPayload},
+		{name: "missing openid scope", modify: func(req *models.AuthorizationRequest) { req.Scope = "email" }, wantErr: apperrors.ErrInvalidScope},
+		{name: "unsupported response type", modify: func(req *models.AuthorizationRequest) { req.ResponseType = "token" }, wantErr: apperrors.ErrUnsupportedResponseType},
+		{name: "valid request", modify: func(req *models.AuthorizationRequest) {}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := authorizationRequest("web-app")
+			tt.modify(req)
+			if _, err := f.svc.ValidateAuthorizationRequest(context.Background(), req); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateAuthorizationRequest error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizationCodeExchangeRequiresPKCE(t *testing.T) {
+	ctx := context.Background()
+	f := newOIDCFixture(t)
+	user := f.user("jane")
+
+	tests := []struct {
+		name        string
+		clientID    string
+		redirectURI string
+		verifier    string
+	}{
+		{name: "wrong verifier", clientID: "web-app", redirectURI: testRedirectURI, verifier: strings.Repeat("a", 43)},
+		{name: "challenge sent as verifier", clientID: "web-app", redirectURI: testRedirectURI, verifier: testCodeChallenge},
+		{name: "other redirect URI", clientID: "web-app", redirectURI: testRedirectURI + "/other", verifier: testCodeVerifier},
+		{name: "other client", clientID: "partner", redirectURI: testRedirectURI, verifier: testCodeVerifier},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := f.authorize(t, "web-app", user)
+			if _, err := f.svc.ExchangeAuthorizationCode(ctx, tt.clientID, "", code, tt.redirectURI, tt.verifier, token.IssueOptions{}); !errors.Is(err, apperrors.ErrInvalidGrant) {
+				t.Fatalf("ExchangeAuthorizationCode error = %v, want ErrInvalidGrant", err)
+			}
+			// A failed attempt burns the code, it can not be retried with the right values
+			if _, err := f.svc.ExchangeAuthorizationCode(ctx, "web-app", "", code, testRedirectURI, testCodeVerifier, token.IssueOptions{}); !errors.Is(err, apperrors.ErrInvalidGrant) {
+				t.Fatalf("retry error = %v, want ErrInvalidGrant", err)
+			}
+		})
+	}
+
+	t.Run("expired code", func(t *testing.T) {
+		code := f.authorize(t, "web-app", user)
+		f.codes.expireAll()
+		if _, err := f.svc.ExchangeAuthorizationCode(ctx, "web-app", "", code, testRedirectURI, testCodeVerifier, token.IssueOptions{}); !errors.Is(err, apperrors.ErrInvalidGrant) {
+			t.Fatalf("ExchangeAuthorizationCode error = %v, want ErrInvalidGrant", err)
+		}
+	})
+}
+
+func TestAuthorizationCodeExchange(t *testing.T) {
+	ctx := context.Background()
+	f := newOIDCFixture(t)
+	user := f.user("jane")
+
+	code := f.authorize(t, "web-app", user)
+	tokens, err := f.svc.ExchangeAuthorizationCode(ctx, "web-app", "", code, testRedirectURI, testCodeVerifier, token.IssueOptions{})
+	if err != nil {
+		t.Fatalf("ExchangeAuthorizationCode: %v", err)
+	}
+	if valid, msg := f.validate(t, tokens.AccessToken); !valid {
+		t.Fatalf("first-party access token was refused: %s", msg)
+	}
+	if tokens.RefreshToken == "" {
+		t.Fatal("first-party client got no refresh token")
+	}
+
+	idToken, err := jwt.Parse(tokens.IDToken, func(*jwt.Token) (interface{}, error) { return []byte(testJWTSecret), nil },
+		jwt.WithAudience("web-app"), jwt.WithIssuer("https://accounts.example.com"))
+	if err != nil {
+		t.Fatalf("ID token: %v", err)
+	}
+	if claims := idToken.Claims.(jwt.MapClaims); claims["nonce"] != "n-0S6_WzA2Mj" || claims["sub"] != user.ID.String() {
+		t.Fatalf("ID token claims %v, want the nonce and subject of the request", claims)
+	}
+
+	if _, err := f.svc.ExchangeAuthorizationCode(ctx, "web-app", "", code, testRedirectURI, testCodeVerifier, token.IssueOptions{}); !errors.Is(err, apperrors.ErrInvalidGrant) {
+		t.Fatalf("replayed code error = %v, want ErrInvalidGrant", err)
+	}
+
+	// Third parties only get a token for the userinfo endpoint
+	tokens, err = f.svc.ExchangeAuthorizationCode(ctx, "partner", "", f.authorize(t, "partner", user), testRedirectURI, testCodeVerifier, token.IssueOptions{})
+	if err != nil {
+		t.Fatalf("ExchangeAuthorizationCode: %v", err)
+	}
+	if tokens.RefreshToken != "" {
+		t.Fatal("third-party client got a refresh token")
+	}
+	if valid, _ := f.validate(t, tokens.AccessToken); valid {
+		t.Fatal("third-party access token was accepted by the account API")
+	}
+	if valid, _, msg, _ := f.tokens.ValidateUserInfoToken(ctx, tokens.AccessToken); !valid {
+		t.Fatalf("third-party access token was refused by userinfo: %s", msg)
+	}
+}
+
+type fakeAuthorizationCodeRepository struct {
+	mu    sync.Mutex
+	codes map[string]*db.OauthAuthorizationCode
+}
+
+func newFakeAuthorizationCodeRepository() *fakeAuthorizationCodeRepository {
+	return &fakeAuthorizationCodeRepository{codes: map[string]*db.OauthAuthorizationCode{}}
+}
+
+func (r *fakeAuthorizationCodeRepository) expireAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, code := range r.codes {
+		code.ExpiresAt = time.Now().Add(-time.Second)
+	}
+}
+
+func (r *fakeAuthorizationCodeRepository) CreateAuthorizationCode(ctx context.Context, param *db.CreateAuthorizationCodeParams) (*db.OauthAuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code := &db.OauthAuthorizationCode{
+		ID:            uuid.New(),
+		CodeHash:      param.CodeHash,
+		OauthClientID: param.OauthClientID,
+		UserID:        param.UserID,
+		RedirectUri:   param.RedirectUri,
+		Scopes:        param.Scopes,
+		Nonce:         param.Nonce,
+		CodeChallenge: param.CodeChallenge,
+		Amr:           param.Amr,
+		AuthTime:      param.AuthTime,
+		ExpiresAt:     param.ExpiresAt,
+		CreatedAt:     time.Now(),
+	}
+	r.codes[param.CodeHash] = code
+	copied := *code
+	return &copied, nil
+}
+
+func (r *fakeAuthorizationCodeRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*db.OauthAuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.codes[codeHash]
+	if !ok || code.ConsumedAt.Valid || time.Now().After(code.ExpiresAt) {
+		return nil, apperrors.ErrInvalidGrant
+	}
+	code.ConsumedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	copied := *code
+	return &copied, nil
+}
+
+func (r *fakeAuthorizationCodeRepository) DeleteExpired(ctx context.Context, expiresBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for hash, code := range r.codes {
+		if code.ExpiresAt.Before(expiresBefore) {
+			delete(r.codes, hash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
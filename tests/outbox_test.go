@@ -0,0 +1,180 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+var testOutboxOptions = services.OutboxRelayOptions{
+	PollInterval: time.Second,
+	BatchSize:    10,
+	Lease:        time.Minute,
+	RetryBase:    time.Second,
+	RetryMax:     4 * time.Second,
+}
+
+func newTestOutboxRelay(t *testing.T, outbox *fakeOutboxRepository, publisher *fakeEventPublisher) services.OutboxRelay {
+	t.Helper()
+	log := newTestLogger(t)
+	return services.NewOutboxRelay(outbox, publisher, testOutboxOptions, log)
+}
+
+func TestOutboxRelayPublishesInOrderOnce(t *testing.T) {
+	ctx := context.Background()
+	outbox := newFakeOutboxRepository()
+	now := time.Now()
+	deleted := outbox.add("user.deleted", now.Add(-time.Second))
+	registered := outbox.add("user.registered", now.Add(-3*time.Second))
+	updated := outbox.add("user.updated", now.Add(-2*time.Second))
+	publisher := &fakeEventPublisher{}
+	relay := newTestOutboxRelay(t, outbox, publisher)
+
+	claimed, err := relay.RelayBatch(ctx)
+	if err != nil || claimed != 3 {
+		t.Fatalf("RelayBatch = %d, %v, want 3 events", claimed, err)
+	}
+
+	want := []string{registered.ID.String(), updated.ID.String(), deleted.ID.String()}
+	if got := publisher.messageIDs(); !slices.Equal(got, want) {
+		t.Fatalf("published %v, want %v in the order of the changes", got, want)
+	}
+	if publisher.published[0].routingKey != "user.registered" || string(publisher.published[0].body) != string(registered.Payload) {
+		t.Fatalf("published %+v, want the event type as routing key and the payload as body", publisher.published[0])
+	}
+	for _, event := range []*db.Outbox{registered, updated, deleted} {
+		if !outbox.get(event.ID).PublishedAt.Valid {
+			t.Fatalf("event %s was not marked published", event.EventType)
+		}
+	}
+
+	if claimed, err := relay.RelayBatch(ctx); err != nil || claimed != 0 {
+		t.Fatalf("second RelayBatch = %d, %v, want nothing left", claimed, err)
+	}
+	if len(publisher.published) != 3 {
+		t.Fatalf("%d messages published, want 3", len(publisher.published))
+	}
+}
+
+func TestOutboxRelayRetriesFailedEventsWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	outbox := newFakeOutboxRepository()
+	failing := outbox.add("user.updated", time.Now().Add(-2*time.Second))
+	other := outbox.add("user.deleted", time.Now().Add(-time.Second))
+	publisher := &fakeEventPublisher{fail: map[string]error{failing.ID.String(): errors.New("broker nacked: " + strings.Repeat("x", 1000))}}
+	relay := newTestOutboxRelay(t, outbox, publisher)
+
+	// One failing event does not hold up the rest of the batch
+	if _, err := relay.RelayBatch(ctx); err != nil {
+		t.Fatalf("RelayBatch: %v", err)
+	}
+	if !outbox.get(other.ID).PublishedAt.Valid {
+		t.Fatal("event after the failing one was not published")
+	}
+
+	for attempt, wantDelay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		stored := outbox.get(failing.ID)
+		if stored.PublishedAt.Valid || stored.Attempts != int32(attempt+1) {
+			t.Fatalf("after failure %d: published %t, attempts %d", attempt+1, stored.PublishedAt.Valid, stored.Attempts)
+		}
+		if len(stored.LastError) != 500 {
+			t.Fatalf("last error has %d characters, want it cut at 500", len(stored.LastError))
+		}
+		if delay := time.Until(stored.NextAttemptAt); delay > wantDelay || delay < wantDelay-time.Second {
+			t.Fatalf("after failure %d the retry is in %s, want %s", attempt+1, delay.Round(time.Millisecond), wantDelay)
+		}
+
+		// Not due yet
+		if claimed, _ := relay.RelayBatch(ctx); claimed != 0 {
+			t.Fatalf("event was retried before its backoff ran out")
+		}
+		outbox.makeDue(failing.ID)
+		if _, err := relay.RelayBatch(ctx); err != nil {
+			t.Fatalf("RelayBatch: %v", err)
+		}
+	}
+
+	delete(publisher.fail, failing.ID.String())
+	outbox.makeDue(failing.ID)
+	if _, err := relay.RelayBatch(ctx); err != nil {
+		t.Fatalf("RelayBatch: %v", err)
+	}
+	if stored := outbox.get(failing.ID); !stored.PublishedAt.Valid || stored.LastError != "" {
+		t.Fatalf("event after the broker recovered: published %t, last error %q", stored.PublishedAt.Valid, stored.LastError)
+	}
+}
+
+func TestOutboxRelayLeavesTheBatchOnShutdown(t *testing.T) {
+	outbox := newFakeOutboxRepository()
+	first := outbox.add("user.registered", time.Now().Add(-2*time.Second))
+	second := outbox.add("user.updated", time.Now().Add(-time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	publisher := &fakeEventPublisher{onPublish: func(messageID string) error {
+		// The broker connection goes away together with the shutdown
+		cancel()
+		return context.Canceled
+	}}
+	relay := newTestOutboxRelay(t, outbox, publisher)
+
+	if _, err := relay.RelayBatch(ctx); err != nil {
+		t.Fatalf("RelayBatch: %v", err)
+	}
+	for _, event := range []*db.Outbox{first, second} {
+		stored := outbox.get(event.ID)
+		if stored.PublishedAt.Valid || stored.Attempts != 0 {
+			t.Fatalf("event %s: published %t, attempts %d, want it left to the lease", event.EventType, stored.PublishedAt.Valid, stored.Attempts)
+		}
+	}
+	if len(publisher.published) != 0 {
+		t.Fatalf("%d messages published during shutdown, want 0", len(publisher.published))
+	}
+}
+
+type publishedMessage struct {
+	routingKey string
+	messageID  string
+	body       []byte
+}
+
+// fakeEventPublisher records published messages. Messages in fail are refused with their error.
+type fakeEventPublisher struct {
+	mu        sync.Mutex
+	published []publishedMessage
+	fail      map[string]error
+	onPublish func(messageID string) error
+}
+
+func (p *fakeEventPublisher) Publish(ctx context.Context, routingKey string, messageID string, body []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.onPublish != nil {
+		if err := p.onPublish(messageID); err != nil {
+			return err
+		}
+	}
+	if err := p.fail[messageID]; err != nil {
+		return err
+	}
+	p.published = append(p.published, publishedMessage{routingKey: routingKey, messageID: messageID, body: body})
+	return nil
+}
+
+func (p *fakeEventPublisher) messageIDs() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var ids []string
+	for _, msg := range p.published {
+		ids = append(ids, msg.messageID)
+	}
+	return ids
+}
@@ -0,0 +1,189 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+const testResetTTL = time.Hour
+
+var resetLinkPattern = regexp.MustCompile(`https://shop\.example\.com/reset\S*`)
+
+func newTestPasswordService(f *tokenFixture, resets *fakePasswordResetRepository, outbox *fakeNotifier) services.PasswordService {
+	return services.NewPasswordService(f.users, resets, f.tokens, outbox, validator.New(), testResetTTL, "https://shop.example.com/reset", f.log)
+}
+
+func TestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	user := f.user("jane")
+	resets := newFakePasswordResetRepository()
+	outbox := &fakeNotifier{}
+	svc := newTestPasswordService(f, resets, outbox)
+
+	session, err := f.tokens.GenerateTokenPair(ctx, user, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+
+	if err := svc.RequestPasswordReset(ctx, &models.ForgotPasswordRequest{Email: user.Email}); err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	superseded := outbox.resetToken(t, user.Email)
+	if err := svc.RequestPasswordReset(ctx, &models.ForgotPasswordRequest{Email: user.Email}); err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	latest := outbox.resetToken(t, user.Email)
+
+	if err := svc.ResetPassword(ctx, &models.ResetPasswordRequest{Token: superseded, NewPassword: "correct horse"}); !errors.Is(err, apperrors.ErrInvalidResetToken) {
+		t.Fatalf("superseded link error = %v, want ErrInvalidResetToken", err)
+	}
+
+	if err := svc.ResetPassword(ctx, &models.ResetPasswordRequest{Token: latest, NewPassword: "correct horse"}); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	stored, _ := f.users.GetUserByID(ctx, user.ID)
+	if bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("correct horse")) != nil {
+		t.Fatal("the new password was not stored")
+	}
+	if valid, _ := f.validate(t, session.AccessToken); valid {
+		t.Fatal("session from before the reset is still valid")
+	}
+	if _, err := f.tokens.Refresh(ctx, session.RefreshToken, token.IssueOptions{}); err == nil {
+		t.Fatal("refresh token from before the reset still works")
+	}
+
+	if err := svc.ResetPassword(ctx, &models.ResetPasswordRequest{Token: latest, NewPassword: "another password"}); !errors.Is(err, apperrors.ErrInvalidResetToken) {
+		t.Fatalf("second use error = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestPasswordResetDoesNotRevealAccounts(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	outbox := &fakeNotifier{}
+	svc := newTestPasswordService(f, newFakePasswordResetRepository(), outbox)
+
+	if err := svc.RequestPasswordReset(ctx, &models.ForgotPasswordRequest{Email: "nobody@example.com"}); err != nil {
+		t.Fatalf("unknown email error = %v, want nil", err)
+	}
+	if _, sent := outbox.last("nobody@example.com"); sent {
+		t.Fatal("a reset link was sent to an unknown address")
+	}
+}
+
+func TestExpiredPasswordResetLinkIsRefused(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	user := f.user("jane")
+	resets := newFakePasswordResetRepository()
+	outbox := &fakeNotifier{}
+	svc := newTestPasswordService(f, resets, outbox)
+
+	if err := svc.RequestPasswordReset(ctx, &models.ForgotPasswordRequest{Email: user.Email}); err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	resets.expireAll()
+
+	if err := svc.ResetPassword(ctx, &models.ResetPasswordRequest{Token: outbox.resetToken(t, user.Email), NewPassword: "correct horse"}); !errors.Is(err, apperrors.ErrInvalidResetToken) {
+		t.Fatalf("expired link error = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+// resetToken takes the token out of the last reset link mailed to the address.
+func (n *fakeNotifier) resetToken(t *testing.T, to string) string {
+	t.Helper()
+
+	msg, ok := n.last(to)
+	if !ok {
+		t.Fatalf("no message was sent to %s", to)
+	}
+	link, err := url.Parse(resetLinkPattern.FindString(msg.Body))
+	if err != nil || link.Query().Get("token") == "" {
+		t.Fatalf("message to %s has no reset link: %q", to, msg.Body)
+	}
+	return link.Query().Get("token")
+}
+
+type fakePasswordResetRepository struct {
+	mu     sync.Mutex
+	tokens map[string]*db.PasswordResetToken
+}
+
+func newFakePasswordResetRepository() *fakePasswordResetRepository {
+	return &fakePasswordResetRepository{tokens: map[string]*db.PasswordResetToken{}}
+}
+
+func (r *fakePasswordResetRepository) expireAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, row := range r.tokens {
+		row.ExpiresAt = time.Now().Add(-time.Second)
+	}
+}
+
+func (r *fakePasswordResetRepository) CreateResetToken(ctx context.Context, param *db.CreatePasswordResetTokenParams) (*db.PasswordResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row := &db.PasswordResetToken{ID: uuid.New(), UserID: param.UserID, TokenHash: param.TokenHash, ExpiresAt: param.ExpiresAt, CreatedAt: time.Now()}
+	r.tokens[param.TokenHash] = row
+	copied := *row
+	return &copied, nil
+}
+
+func (r *fakePasswordResetRepository) ConsumeResetToken(ctx context.Context, tokenHash string) (*db.PasswordResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.tokens[tokenHash]
+	if !ok || row.UsedAt.Valid || time.Now().After(row.ExpiresAt) {
+		return nil, apperrors.ErrTokenNotFound
+	}
+	row.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	copied := *row
+	return &copied, nil
+}
+
+func (r *fakePasswordResetRepository) InvalidateUserResetTokens(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, row := range r.tokens {
+		if row.UserID == userID && !row.UsedAt.Valid {
+			row.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+func (r *fakePasswordResetRepository) DeleteExpired(ctx context.Context, expiresBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for hash, row := range r.tokens {
+		if row.ExpiresAt.Before(expiresBefore) {
+			delete(r.tokens, hash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,305 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+const (
+	testMagicLoginMaxAttempts = 3
+	testMagicLoginSendLimit   = 2
+)
+
+var (
+	magicLinkPattern = regexp.MustCompile(`https://shop\.example\.com/login/magic\S*`)
+	loginCodePattern = regexp.MustCompile(`code is (\d{6})`)
+)
+
+type passwordlessFixture struct {
+	*tokenFixture
+	prefs      *fakePreferenceRepository
+	challenges *fakeMagicLoginRepository
+	outbox     *fakeNotifier
+	service    services.PasswordlessService
+}
+
+func newPasswordlessFixture(t *testing.T) *passwordlessFixture {
+	t.Helper()
+
+	f := &passwordlessFixture{
+		tokenFixture: newTokenFixture(t),
+		prefs:        newFakePreferenceRepository(),
+		challenges:   newFakeMagicLoginRepository(),
+		outbox:       &fakeNotifier{},
+	}
+	f.service = services.NewPasswordlessService(f.users, f.prefs, f.challenges, newFakeAttemptRepository(), f.tokens, f.outbox, validator.New(),
+		services.PasswordlessOptions{
+			TTL:         10 * time.Minute,
+			MaxAttempts: testMagicLoginMaxAttempts,
+			SendLimit:   testMagicLoginSendLimit,
+			SendWindow:  time.Hour,
+			LinkURL:     "https://shop.example.com/login/magic",
+		}, f.log)
+	return f
+}
+
+// optedIn returns a user with a verified email who turned on passwordless login.
+func (f *passwordlessFixture) optedIn(t *testing.T, name string) *entities.User {
+	t.Helper()
+
+	user := f.user(name)
+	if _, err := f.users.MarkEmailVerified(context.Background(), &db.MarkUserEmailVerifiedParams{ID: user.ID, Email: user.Email}); err != nil {
+		t.Fatalf("MarkEmailVerified: %v", err)
+	}
+	f.setPasswordless(t, user.ID, true)
+	return user
+}
+
+func (f *passwordlessFixture) setPasswordless(t *testing.T, userID uuid.UUID, enabled bool) {
+	t.Helper()
+	if _, err := f.prefs.UpsertPreferences(context.Background(), &db.UpsertUserPreferencesParams{UserID: userID, PasswordlessLogin: enabled}); err != nil {
+		t.Fatalf("UpsertPreferences: %v", err)
+	}
+}
+
+// request asks for a sign-in link or code and returns the link token or the code that was mailed.
+func (f *passwordlessFixture) request(t *testing.T, email string, method string) string {
+	t.Helper()
+
+	if err := f.service.RequestLogin(context.Background(), &models.MagicLoginRequest{Email: email, Method: method}); err != nil {
+		t.Fatalf("RequestLogin: %v", err)
+	}
+	msg, ok := f.outbox.last(email)
+	if !ok {
+		t.Fatalf("no message was sent to %s", email)
+	}
+
+	if method == services.MagicLoginMethodCode {
+		match := loginCodePattern.FindStringSubmatch(msg.Body)
+		if match == nil {
+			t.Fatalf("message to %s has no code: %q", email, msg.Body)
+		}
+		return match[1]
+	}
+	link, err := url.Parse(magicLinkPattern.FindString(msg.Body))
+	if err != nil || link.Query().Get("token") == "" {
+		t.Fatalf("message to %s has no link: %q", email, msg.Body)
+	}
+	return link.Query().Get("token")
+}
+
+func TestMagicLoginIsSingleUse(t *testing.T) {
+	ctx := context.Background()
+
+	for _, method := range []string{services.MagicLoginMethodLink, services.MagicLoginMethodCode} {
+		t.Run(method, func(t *testing.T) {
+			f := newPasswordlessFixture(t)
+			user := f.optedIn(t, "jane")
+			secret := f.request(t, user.Email, method)
+
+			req := &models.MagicLoginVerifyRequest{Token: secret}
+			if method == services.MagicLoginMethodCode {
+				req = &models.MagicLoginVerifyRequest{Email: user.Email, Code: secret}
+			}
+
+			// Both requests pass the challenge check before either consumes it
+			f.challenges.barrier = make(chan struct{})
+			var wg sync.WaitGroup
+			errs := make([]error, 2)
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					_, errs[i] = f.service.VerifyLogin(ctx, req)
+				}(i)
+			}
+			wg.Wait()
+			f.challenges.barrier = nil
+
+			var passed int
+			for _, err := range errs {
+				switch {
+				case err == nil:
+					passed++
+				case !errors.Is(err, apperrors.ErrInvalidLoginCode):
+					t.Fatalf("unexpected error %v", err)
+				}
+			}
+			if passed != 1 {
+				t.Fatalf("%d concurrent verifications passed, want 1", passed)
+			}
+			if _, err := f.service.VerifyLogin(ctx, req); !errors.Is(err, apperrors.ErrInvalidLoginCode) {
+				t.Fatalf("replay error = %v, want ErrInvalidLoginCode", err)
+			}
+		})
+	}
+}
+
+func TestLoginCodeAttemptsAreLimited(t *testing.T) {
+	ctx := context.Background()
+	f := newPasswordlessFixture(t)
+	user := f.optedIn(t, "jane")
+
+	code := f.request(t, user.Email, services.MagicLoginMethodCode)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for i := 0; i < testMagicLoginMaxAttempts; i++ {
+		if _, err := f.service.VerifyLogin(ctx, &models.MagicLoginVerifyRequest{Email: user.Email, Code: wrong}); !errors.Is(err, apperrors.ErrInvalidLoginCode) {
+			t.Fatalf("attempt %d error = %v, want ErrInvalidLoginCode", i+1, err)
+		}
+	}
+	if f.challenges.has(user.ID) {
+		t.Fatal("the code is still pending after the last attempt")
+	}
+	if _, err := f.service.VerifyLogin(ctx, &models.MagicLoginVerifyRequest{Email: user.Email, Code: code}); !errors.Is(err, apperrors.ErrTooManyAttempts) {
+		t.Fatalf("error after the limit = %v, want ErrTooManyAttempts", err)
+	}
+
+	// A new code does not bring new guesses
+	code = f.request(t, user.Email, services.MagicLoginMethodCode)
+	if _, err := f.service.VerifyLogin(ctx, &models.MagicLoginVerifyRequest{Email: user.Email, Code: code}); !errors.Is(err, apperrors.ErrTooManyAttempts) {
+		t.Fatalf("error with a resent code = %v, want ErrTooManyAttempts", err)
+	}
+}
+
+func TestMagicLoginIsRefusedAfterTheAccountChanged(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, f *passwordlessFixture, user *entities.User)
+	}{
+		{name: "email changed", change: func(t *testing.T, f *passwordlessFixture, user *entities.User) {
+			f.users.mu.Lock()
+			defer f.users.mu.Unlock()
+			f.users.byID[user.ID].Email = "jane.new@example.com"
+		}},
+		{name: "email no longer verified", change: func(t *testing.T, f *passwordlessFixture, user *entities.User) {
+			f.users.mu.Lock()
+			defer f.users.mu.Unlock()
+			f.users.byID[user.ID].EmailVerifiedAt = sql.NullTime{}
+		}},
+		{name: "opt-in withdrawn", change: func(t *testing.T, f *passwordlessFixture, user *entities.User) {
+			f.setPasswordless(t, user.ID, false)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPasswordlessFixture(t)
+			user := f.optedIn(t, "jane")
+			link := f.request(t, user.Email, services.MagicLoginMethodLink)
+
+			tt.change(t, f, user)
+			if _, err := f.service.VerifyLogin(context.Background(), &models.MagicLoginVerifyRequest{Token: link}); !errors.Is(err, apperrors.ErrInvalidLoginCode) {
+				t.Fatalf("VerifyLogin error = %v, want ErrInvalidLoginCode", err)
+			}
+		})
+	}
+}
+
+func TestMagicLoginRequestDoesNotRevealTheAccount(t *testing.T) {
+	ctx := context.Background()
+	f := newPasswordlessFixture(t)
+	jane := f.optedIn(t, "jane")
+	john := f.user("john")
+	if _, err := f.users.MarkEmailVerified(ctx, &db.MarkUserEmailVerifiedParams{ID: john.ID, Email: john.Email}); err != nil {
+		t.Fatalf("MarkEmailVerified: %v", err)
+	}
+	throttled := f.optedIn(t, "max")
+	for i := 0; i < testMagicLoginSendLimit; i++ {
+		f.request(t, throttled.Email, services.MagicLoginMethodLink)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		wantSent bool
+	}{
+		{name: "opted in", email: jane.Email, wantSent: true},
+		{name: "unknown", email: "nobody@example.com"},
+		{name: "not opted in", email: john.Email},
+		{name: "throttled", email: throttled.Email},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(f.outbox.sent)
+			if err := f.service.RequestLogin(ctx, &models.MagicLoginRequest{Email: tt.email}); err != nil {
+				t.Fatalf("RequestLogin error = %v, want the answer of every address", err)
+			}
+			if sent := len(f.outbox.sent) > before; sent != tt.wantSent {
+				t.Fatalf("sent = %t, want %t", sent, tt.wantSent)
+			}
+		})
+	}
+}
+
+type fakeMagicLoginRepository struct {
+	mu         sync.Mutex
+	challenges map[uuid.UUID]models.MagicLoginChallenge
+	// barrier, when set, pairs up concurrent challenge lookups
+	barrier chan struct{}
+}
+
+func newFakeMagicLoginRepository() *fakeMagicLoginRepository {
+	return &fakeMagicLoginRepository{challenges: map[uuid.UUID]models.MagicLoginChallenge{}}
+}
+
+func (r *fakeMagicLoginRepository) has(userID uuid.UUID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.challenges[userID]
+	return ok
+}
+
+func (r *fakeMagicLoginRepository) SaveChallenge(ctx context.Context, userID uuid.UUID, challenge *models.MagicLoginChallenge, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.challenges[userID] = *challenge
+	return nil
+}
+
+func (r *fakeMagicLoginRepository) GetChallenge(ctx context.Context, userID uuid.UUID) (*models.MagicLoginChallenge, error) {
+	if r.barrier != nil {
+		select {
+		case r.barrier <- struct{}{}:
+		case <-r.barrier:
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	challenge, ok := r.challenges[userID]
+	if !ok {
+		return nil, apperrors.ErrInvalidLoginCode
+	}
+	return &challenge, nil
+}
+
+func (r *fakeMagicLoginRepository) DeleteChallenge(ctx context.Context, userID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.challenges[userID]
+	delete(r.challenges, userID)
+	return ok, nil
+}
@@ -0,0 +1,166 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/helpers"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+const testPIN = "2580"
+
+func TestPINLoginRequiresTheTerminalSecret(t *testing.T) {
+	ctx := context.Background()
+	log := newTestLogger(t)
+
+	users := newFakeUserRepository()
+	cashier := users.add(&db.User{ID: uuid.New(), Name: "cashier", Username: "cashier", Email: "cashier@example.com", Role: entities.DefaultRole})
+	tenancy := newFakeTenancyRepository()
+	storeID := tenancy.join(cashier.ID, uuid.New())
+
+	pos := newFakePOSRepository()
+	pos.setPIN(t, cashier.ID, testPIN)
+	attempts := newFakeAttemptRepository()
+	svc := services.NewPOSService(pos, tenancy, users, attempts, fakeScopedTokenService{}, services.POSOptions{
+		PINMaxAttempts:     5,
+		PINLockoutDuration: time.Minute,
+		TokenTTL:           time.Hour,
+	}, log)
+
+	terminal, secret, err := svc.RegisterTerminal(ctx, storeID, "Front register", services.StoreActor{UserID: uuid.New(), CanManageTenants: true})
+	if err != nil {
+		t.Fatalf("RegisterTerminal: %v", err)
+	}
+	if secret == "" {
+		t.Fatal("RegisterTerminal returned no secret")
+	}
+	if stored := pos.terminals[terminal.ID].SecretHash; stored != helpers.HashToken(secret) {
+		t.Fatalf("stored secret hash %q, want the hash of the returned secret", stored)
+	}
+
+	// A terminal registered before secrets existed has no hash
+	legacy := &db.PosTerminal{ID: uuid.New(), StoreID: storeID, Name: "Old register"}
+	pos.terminals[legacy.ID] = legacy
+
+	tests := []struct {
+		name       string
+		terminalID uuid.UUID
+		secret     string
+		wantErr    error
+	}{
+		{name: "missing secret", terminalID: terminal.ID, wantErr: apperrors.ErrInvalidTerminal},
+		{name: "wrong secret", terminalID: terminal.ID, secret: "not-the-secret", wantErr: apperrors.ErrInvalidTerminal},
+		{name: "secret of another terminal", terminalID: legacy.ID, secret: secret, wantErr: apperrors.ErrInvalidTerminal},
+		{name: "terminal without a secret", terminalID: legacy.ID, wantErr: apperrors.ErrInvalidTerminal},
+		{name: "valid secret", terminalID: terminal.ID, secret: secret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accessToken, _, err := svc.PINLogin(ctx, &services.PINLoginRequest{
+				TerminalID:     tt.terminalID,
+				TerminalSecret: tt.secret,
+				Username:       cashier.Username,
+				PIN:            testPIN,
+			}, token.IssueOptions{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PINLogin error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && accessToken == "" {
+				t.Fatal("PINLogin returned no token")
+			}
+		})
+	}
+
+	// Rejected terminals never got to check the PIN, so they did not count against the cashier
+	if count, _ := attempts.Count(ctx, "pin:user:"+cashier.ID.String()); count != 0 {
+		t.Fatalf("%d PIN attempts counted, want 0", count)
+	}
+}
+
+// fakeScopedTokenService issues opaque tokens instead of signing them.
+type fakeScopedTokenService struct {
+	token.TokenService
+}
+
+func (fakeScopedTokenService) GenerateScopedToken(ctx context.Context, user *entities.User, scopes []string, ttl time.Duration, opts token.IssueOptions) (string, time.Time, error) {
+	return "scoped-" + user.ID.String(), time.Now().Add(ttl), nil
+}
+
+type fakePOSRepository struct {
+	repositories.POSRepository
+	mu        sync.Mutex
+	pins      map[uuid.UUID]*db.UserPin
+	terminals map[uuid.UUID]*db.PosTerminal
+}
+
+func newFakePOSRepository() *fakePOSRepository {
+	return &fakePOSRepository{pins: map[uuid.UUID]*db.UserPin{}, terminals: map[uuid.UUID]*db.PosTerminal{}}
+}
+
+func (r *fakePOSRepository) setPIN(t *testing.T, userID uuid.UUID, pin string) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash pin: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pins[userID] = &db.UserPin{UserID: userID, PinHash: string(hash)}
+}
+
+func (r *fakePOSRepository) GetUserPin(ctx context.Context, userID uuid.UUID) (*db.UserPin, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pin, ok := r.pins[userID]
+	if !ok {
+		return nil, apperrors.ErrPINNotSet
+	}
+	return pin, nil
+}
+
+func (r *fakePOSRepository) CreateTerminal(ctx context.Context, param *db.CreatePosTerminalParams) (*db.PosTerminal, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	terminal := &db.PosTerminal{
+		ID:           uuid.New(),
+		StoreID:      param.StoreID,
+		Name:         param.Name,
+		RegisteredBy: param.RegisteredBy,
+		CreatedAt:    time.Now(),
+		SecretHash:   param.SecretHash,
+	}
+	r.terminals[terminal.ID] = terminal
+	return terminal, nil
+}
+
+func (r *fakePOSRepository) GetTerminal(ctx context.Context, id uuid.UUID) (*db.PosTerminal, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	terminal, ok := r.terminals[id]
+	if !ok {
+		return nil, apperrors.ErrNotFound
+	}
+	copied := *terminal
+	return &copied, nil
+}
+
+func (r *fakePOSRepository) TouchTerminal(ctx context.Context, id uuid.UUID) error {
+	return nil
+}
@@ -0,0 +1,196 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/handlers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/middlewares"
+)
+
+var testRateLimit = middlewares.RateLimit{Limit: 2, Window: time.Minute}
+
+func newRateLimitedEcho(limiter middlewares.RateLimiter, keyFunc middlewares.RateLimitKeyFunc) *echo.Echo {
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if id, err := uuid.Parse(c.Request().Header.Get("X-Test-User")); err == nil {
+				c.Set("userID", id)
+			}
+			return next(c)
+		}
+	})
+	e.Use(middlewares.RateLimitMiddleware(middlewares.RateLimitOptions{
+		Limiter: limiter,
+		Name:    "test",
+		Limit:   testRateLimit,
+		KeyFunc: keyFunc,
+	}))
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/a", ok)
+	e.GET("/b", ok)
+	return e
+}
+
+func serveFrom(e *echo.Echo, path string, ip string, userID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set(echo.HeaderXRealIP, ip)
+	if userID != "" {
+		req.Header.Set("X-Test-User", userID)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitMiddlewareRejectsRequestsOverTheQuota(t *testing.T) {
+	e := newRateLimitedEcho(newFakeRateLimiter(), middlewares.KeyByIP)
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		rec := serveFrom(e, "/a", "198.51.100.1", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d got %d, want %d", i+1, rec.Code, http.StatusOK)
+		}
+		if got := rec.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Fatalf("X-RateLimit-Limit = %q, want 2", got)
+		}
+		if got := rec.Header().Get("X-RateLimit-Remaining"); got != wantRemaining {
+			t.Fatalf("request %d X-RateLimit-Remaining = %q, want %s", i+1, got, wantRemaining)
+		}
+	}
+
+	rec := serveFrom(e, "/a", "198.51.100.1", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the quota got %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || retryAfter < 1 || retryAfter > 60 {
+		t.Fatalf("Retry-After = %q, want 1 to 60 seconds", rec.Header().Get("Retry-After"))
+	}
+	if rec.Header().Get("X-RateLimit-Reset") == "" {
+		t.Fatal("X-RateLimit-Reset is missing")
+	}
+
+	if rec := serveFrom(e, "/b", "198.51.100.1", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("other route of the same IP got %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if rec := serveFrom(e, "/a", "198.51.100.2", ""); rec.Code != http.StatusOK {
+		t.Fatalf("other IP got %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	userA, userB := uuid.NewString(), uuid.NewString()
+
+	tests := []struct {
+		name    string
+		keyFunc middlewares.RateLimitKeyFunc
+		// path, ip and userID make the request after two of userA to /a from 198.51.100.1
+		path   string
+		ip     string
+		userID string
+		want   int
+	}{
+		{name: "same user on another IP", keyFunc: middlewares.KeyByUserID, path: "/a", ip: "198.51.100.9", userID: userA, want: http.StatusTooManyRequests},
+		{name: "other user on the same IP", keyFunc: middlewares.KeyByUserID, path: "/a", ip: "198.51.100.1", userID: userB, want: http.StatusOK},
+		{name: "anonymous falls back to the IP", keyFunc: middlewares.KeyByUserID, path: "/a", ip: "198.51.100.1", want: http.StatusOK},
+		{name: "other route", keyFunc: middlewares.KeyByRoute(middlewares.KeyByIP), path: "/b", ip: "198.51.100.1", userID: userA, want: http.StatusOK},
+		{name: "same route", keyFunc: middlewares.KeyByRoute(middlewares.KeyByIP), path: "/a", ip: "198.51.100.1", userID: userB, want: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newRateLimitedEcho(newFakeRateLimiter(), tt.keyFunc)
+			for i := 0; i < 2; i++ {
+				if rec := serveFrom(e, "/a", "198.51.100.1", userA); rec.Code != http.StatusOK {
+					t.Fatalf("request %d got %d, want %d", i+1, rec.Code, http.StatusOK)
+				}
+			}
+			if rec := serveFrom(e, tt.path, tt.ip, tt.userID); rec.Code != tt.want {
+				t.Fatalf("got %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddlewareFailsOpen(t *testing.T) {
+	limiter := newFakeRateLimiter()
+	limiter.err = errors.New("connection refused")
+	e := newRateLimitedEcho(limiter, middlewares.KeyByIP)
+
+	for i := 0; i < 5; i++ {
+		rec := serveFrom(e, "/a", "198.51.100.1", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d got %d, want %d", i+1, rec.Code, http.StatusOK)
+		}
+		if rec.Header().Get("X-RateLimit-Limit") != "" {
+			t.Fatal("X-RateLimit-Limit sent without a limiter")
+		}
+	}
+}
+
+func TestLoginIsRateLimitedPerRoute(t *testing.T) {
+	e := newTestRouter(t, &handlers.UserHandler{}, newFakeAuthTokenService(uuid.New()))
+
+	// Malformed bodies never reach the services, the quota is taken before the handler runs
+	for i := 0; i < 10; i++ {
+		if rec := serve(e, http.MethodPost, "/api/v1/accounts/login", "", "{"); rec.Code != http.StatusBadRequest {
+			t.Fatalf("login %d got %d, want %d", i+1, rec.Code, http.StatusBadRequest)
+		}
+	}
+	if rec := serve(e, http.MethodPost, "/api/v1/accounts/login", "", "{"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("login over the quota got %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	if rec := serve(e, http.MethodPost, "/api/v1/accounts/register", "", "{"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("register got %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGRPCRateLimitInterceptor(t *testing.T) {
+	interceptor := middlewares.UnaryRateLimitInterceptor(middlewares.GRPCRateLimitOptions{
+		Limiter:  newFakeRateLimiter(),
+		Name:     "grpc",
+		Limit:    testRateLimit,
+		Services: []string{"account.AccountService"},
+	})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+
+	call := func(ip string, method string) error {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := call("10.0.0.1", "/account.AccountService/GetUser"); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	if err := call("10.0.0.1", "/account.AccountService/GetUser"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("call over the quota code = %s, want %s", status.Code(err), codes.ResourceExhausted)
+	}
+	if err := call("10.0.0.1", "/account.AccountService/ListUsers"); err != nil {
+		t.Fatalf("other method: %v", err)
+	}
+	if err := call("10.0.0.2", "/account.AccountService/GetUser"); err != nil {
+		t.Fatalf("other peer: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := call("10.0.0.1", "/auth.AuthService/ValidateToken"); err != nil {
+			t.Fatalf("service outside the limit: %v", err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/handlers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/middlewares"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/audit"
+)
+
+const testAdminRole = "admin"
+
+func TestRoleChangesTakeEffectThroughPermissions(t *testing.T) {
+	f := newTokenFixture(t)
+	admin := f.user("admin")
+	// A platform admin, the roles of users of any tenant can be managed without an active store
+	f.roles.grant(admin.ID, testAdminRole, entities.PermRolesRead, entities.PermRolesManage, entities.PermTenantsManage)
+	jane := f.user("jane")
+
+	api := &handlers.UserHandler{RoleService: services.NewRoleService(f.roles, f.users, f.tokens, audit.NewRecorder(f.audit, f.log), f.log)}
+	e := newTestRouter(t, api, f.tokens)
+	adminToken := f.accessToken(t, admin)
+
+	expect := func(step string, method string, path string, bearer string, body string, want int) {
+		t.Helper()
+		if rec := serve(e, method, path, bearer, body); rec.Code != want {
+			t.Fatalf("%s got %d, want %d: %s", step, rec.Code, want, rec.Body)
+		}
+	}
+	janeRoles := "/api/v1/accounts/" + jane.ID.String() + "/roles"
+
+	expect("listing roles without a token", http.MethodGet, "/api/v1/accounts/roles", "", "", http.StatusUnauthorized)
+	expect("listing roles without roles:read", http.MethodGet, "/api/v1/accounts/roles", f.accessToken(t, jane), "", http.StatusForbidden)
+	expect("listing roles as admin", http.MethodGet, "/api/v1/accounts/roles", adminToken, "", http.StatusOK)
+
+	expect("granting without roles:manage", http.MethodPost, janeRoles, f.accessToken(t, jane), `{"role":"admin"}`, http.StatusForbidden)
+	expect("granting an unknown role", http.MethodPost, janeRoles, adminToken, `{"role":"superuser"}`, http.StatusNotFound)
+	expect("granting admin", http.MethodPost, janeRoles, adminToken, `{"role":"admin"}`, http.StatusOK)
+	if !slices.Contains(f.audit.actions(jane.ID), entities.AuditRoleGranted) {
+		t.Fatal("the grant was not audited")
+	}
+
+	// Permissions are part of the token, so the grant applies to the next one issued
+	promoted := f.accessToken(t, jane)
+	expect("listing roles after the grant", http.MethodGet, "/api/v1/accounts/roles", promoted, "", http.StatusOK)
+
+	expect("revoking an own role", http.MethodDelete, "/api/v1/accounts/"+admin.ID.String()+"/roles/admin", adminToken, "", http.StatusForbidden)
+	expect("revoking admin", http.MethodDelete, janeRoles+"/admin", adminToken, "", http.StatusOK)
+	if !slices.Contains(f.audit.actions(jane.ID), entities.AuditRoleRevoked) {
+		t.Fatal("the revocation was not audited")
+	}
+
+	// The revocation ends the sessions holding the permission instead of waiting for them to expire
+	expect("token from before the revocation", http.MethodGet, "/api/v1/accounts/roles", promoted, "", http.StatusUnauthorized)
+	time.Sleep(5 * time.Millisecond)
+	expect("token from after the revocation", http.MethodGet, "/api/v1/accounts/roles", f.accessToken(t, jane), "", http.StatusForbidden)
+}
+
+func TestGRPCPermissionInterceptor(t *testing.T) {
+	f := newTokenFixture(t)
+	admin := f.user("admin")
+	f.roles.grant(admin.ID, testAdminRole, entities.PermUsersRead)
+	jane := f.user("jane")
+
+	interceptor := middlewares.UnaryPermissionInterceptor(middlewares.GRPCPermissionOptions{
+		TokenService: f.tokens,
+		Methods:      map[string]string{"/account.AccountService/ListUsers": entities.PermUsersRead},
+	})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+
+	tests := []struct {
+		name   string
+		method string
+		bearer string
+		want   codes.Code
+	}{
+		{name: "unchecked method", method: "/account.AccountService/GetUser", want: codes.OK},
+		{name: "missing token", method: "/account.AccountService/ListUsers", want: codes.Unauthenticated},
+		{name: "invalid token", method: "/account.AccountService/ListUsers", bearer: "not-a-token", want: codes.Unauthenticated},
+		{name: "missing permission", method: "/account.AccountService/ListUsers", bearer: f.accessToken(t, jane), want: codes.PermissionDenied},
+		{name: "granted permission", method: "/account.AccountService/ListUsers", bearer: f.accessToken(t, admin), want: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.bearer != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+tt.bearer))
+			}
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if code := status.Code(err); code != tt.want {
+				t.Fatalf("code = %s, want %s (%v)", code, tt.want, err)
+			}
+		})
+	}
+}
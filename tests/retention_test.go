@@ -0,0 +1,340 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+const testRetentionPeriod = 30 * 24 * time.Hour
+
+// retentionFixture holds, for each kind of data the policy covers, something old enough to be
+// removed and something that has to stay.
+type retentionFixture struct {
+	users      *fakeRetentionUserRepository
+	sessions   *fakeSessionRepository
+	outbox     *fakeOutboxRepository
+	deliveries *fakeCampaignDeliveryRepository
+	audit      *fakeAuditLogRepository
+	log        *logrus.Logger
+
+	expired  []uuid.UUID
+	recent   uuid.UUID
+	active   uuid.UUID
+	unsent   *db.Outbox
+	outboxID uuid.UUID
+}
+
+func newRetentionFixture(t *testing.T) *retentionFixture {
+	t.Helper()
+
+	f := &retentionFixture{
+		users:      &fakeRetentionUserRepository{fakeUserRepository: newFakeUserRepository(), credentials: map[uuid.UUID]db.PurgeUserCredentialsRow{}},
+		sessions:   newFakeSessionRepository(),
+		outbox:     newFakeOutboxRepository(),
+		deliveries: &fakeCampaignDeliveryRepository{},
+		audit:      &fakeAuditLogRepository{},
+		log:        newTestLogger(t),
+	}
+
+	old := time.Now().Add(-testRetentionPeriod - time.Hour)
+	for i := 0; i < 5; i++ {
+		f.expired = append(f.expired, f.deletedUser(t, sql.NullTime{Time: old, Valid: true}))
+	}
+	f.recent = f.deletedUser(t, sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true})
+	f.active = f.deletedUser(t, sql.NullTime{})
+
+	for i := 0; i < 5; i++ {
+		event := f.outbox.add("user.deleted", old)
+		f.outbox.MarkPublished(context.Background(), event.ID)
+		f.outbox.events[event.ID].PublishedAt.Time = old
+	}
+	published := f.outbox.add("user.updated", time.Now())
+	f.outbox.MarkPublished(context.Background(), published.ID)
+	f.outboxID = published.ID
+	f.unsent = f.outbox.add("user.registered", old)
+
+	f.deliveries.times = []time.Time{old, old, old, time.Now()}
+	f.audit.times = []time.Time{old, old, old, old, old, time.Now()}
+	return f
+}
+
+// deletedUser adds a user with a session, an API key and a linked identity.
+func (f *retentionFixture) deletedUser(t *testing.T, deletedAt sql.NullTime) uuid.UUID {
+	t.Helper()
+
+	id := uuid.New()
+	f.users.add(&db.User{ID: id, Name: id.String(), Username: id.String(), Email: id.String() + "@example.com", DeletedAt: deletedAt})
+	f.users.credentials[id] = db.PurgeUserCredentialsRow{ApiKeys: 1, Identities: 1}
+	if err := f.sessions.StoreSession(context.Background(), &models.Session{JTI: uuid.NewString(), UserID: id}); err != nil {
+		t.Fatalf("StoreSession: %v", err)
+	}
+	return id
+}
+
+func (f *retentionFixture) service(mode string) services.RetentionService {
+	return services.NewRetentionService(f.users, f.sessions, f.outbox, f.deliveries, f.audit, services.RetentionOptions{
+		DeletedUserMode:       mode,
+		DeletedUserAfter:      testRetentionPeriod,
+		OutboxAfter:           testRetentionPeriod,
+		CampaignDeliveryAfter: testRetentionPeriod,
+		AuditAfter:            testRetentionPeriod,
+		// Smaller than what is to be removed, so every step has to page
+		BatchSize: 2,
+	}, f.log)
+}
+
+func wantRetentionCounts(t *testing.T, report *services.RetentionReport) {
+	t.Helper()
+
+	got := []int64{report.Users, report.Sessions, report.APIKeys, report.Identities, report.OutboxEvents, report.CampaignDeliveries, report.AuditEvents}
+	if want := []int64{5, 5, 5, 5, 5, 3, 5}; !slices.Equal(got, want) {
+		t.Fatalf("report counts users, sessions, api keys, identities, outbox, deliveries, audit = %v, want %v", got, want)
+	}
+}
+
+func TestRetentionDryRunOnlyCounts(t *testing.T) {
+	f := newRetentionFixture(t)
+
+	report, err := f.service(services.RetentionAnonymize).Run(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !report.DryRun {
+		t.Fatal("report is not marked as a dry run")
+	}
+	wantRetentionCounts(t, report)
+
+	for _, id := range f.expired {
+		if user := f.users.byID[id]; user.AnonymizedAt.Valid {
+			t.Fatal("dry run anonymized a user")
+		}
+		if sessions, _ := f.sessions.ListSessions(context.Background(), id); len(sessions) != 1 {
+			t.Fatal("dry run removed a session")
+		}
+	}
+	if len(f.outbox.events) != 7 || len(f.deliveries.times) != 4 || len(f.audit.times) != 6 {
+		t.Fatal("dry run removed outbox events, deliveries or audit events")
+	}
+}
+
+func TestRetentionAnonymizesExpiredUsers(t *testing.T) {
+	ctx := context.Background()
+	f := newRetentionFixture(t)
+	svc := f.service(services.RetentionAnonymize)
+
+	report, err := svc.Run(ctx, false)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	wantRetentionCounts(t, report)
+
+	for _, id := range f.expired {
+		user, ok := f.users.byID[id]
+		if !ok || !user.AnonymizedAt.Valid || user.Email == id.String()+"@example.com" {
+			t.Fatalf("expired user %s was not anonymized", id)
+		}
+		if sessions, _ := f.sessions.ListSessions(ctx, id); len(sessions) != 0 {
+			t.Fatalf("expired user %s kept their sessions", id)
+		}
+		if f.users.credentials[id] != (db.PurgeUserCredentialsRow{}) {
+			t.Fatalf("expired user %s kept their API keys or identities", id)
+		}
+	}
+	for _, id := range []uuid.UUID{f.recent, f.active} {
+		if user := f.users.byID[id]; user.AnonymizedAt.Valid {
+			t.Fatalf("user %s was anonymized before the retention period ran out", id)
+		}
+		if sessions, _ := f.sessions.ListSessions(ctx, id); len(sessions) != 1 {
+			t.Fatalf("user %s lost their session", id)
+		}
+	}
+
+	if _, ok := f.outbox.events[f.unsent.ID]; !ok {
+		t.Fatal("an unpublished outbox event was pruned")
+	}
+	if _, ok := f.outbox.events[f.outboxID]; !ok || len(f.outbox.events) != 2 {
+		t.Fatalf("%d outbox events left, want the recent and the unpublished one", len(f.outbox.events))
+	}
+	if len(f.deliveries.times) != 1 || len(f.audit.times) != 1 {
+		t.Fatalf("%d deliveries and %d audit events left, want the recent ones", len(f.deliveries.times), len(f.audit.times))
+	}
+
+	// Anonymized users drop out, a second run has nothing left to do
+	report, err = svc.Run(ctx, false)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if report.Users != 0 || report.OutboxEvents != 0 || report.AuditEvents != 0 {
+		t.Fatalf("second run removed %+v, want nothing", report)
+	}
+}
+
+func TestRetentionHardDeletesExpiredUsers(t *testing.T) {
+	f := newRetentionFixture(t)
+
+	if _, err := f.service(services.RetentionDelete).Run(context.Background(), false); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	for _, id := range f.expired {
+		if _, ok := f.users.byID[id]; ok {
+			t.Fatalf("expired user %s was not deleted", id)
+		}
+	}
+	for _, id := range []uuid.UUID{f.recent, f.active} {
+		if _, ok := f.users.byID[id]; !ok {
+			t.Fatalf("user %s was deleted before the retention period ran out", id)
+		}
+	}
+}
+
+func TestRetentionSkipsDisabledSteps(t *testing.T) {
+	f := newRetentionFixture(t)
+	svc := services.NewRetentionService(f.users, f.sessions, f.outbox, f.deliveries, f.audit, services.RetentionOptions{
+		DeletedUserMode: services.RetentionAnonymize,
+		AuditAfter:      testRetentionPeriod,
+		BatchSize:       100,
+	}, f.log)
+
+	report, err := svc.Run(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Users != 0 || report.OutboxEvents != 0 || report.CampaignDeliveries != 0 || report.AuditEvents != 5 {
+		t.Fatalf("report %+v, want only audit events removed", report)
+	}
+	if user := f.users.byID[f.expired[0]]; user.AnonymizedAt.Valid {
+		t.Fatal("users were purged with the step disabled")
+	}
+}
+
+type fakeRetentionUserRepository struct {
+	*fakeUserRepository
+	credentials map[uuid.UUID]db.PurgeUserCredentialsRow
+}
+
+func (r *fakeRetentionUserRepository) expired(deletedBefore time.Time) []*db.User {
+	var users []*db.User
+	for _, user := range r.byID {
+		if user.DeletedAt.Valid && user.DeletedAt.Time.Before(deletedBefore) && !user.AnonymizedAt.Valid {
+			users = append(users, user)
+		}
+	}
+	slices.SortFunc(users, func(a, b *db.User) int { return bytes.Compare(a.ID[:], b.ID[:]) })
+	return users
+}
+
+func (r *fakeRetentionUserRepository) ListExpiredDeletedUsers(ctx context.Context, deletedBefore time.Time, afterID uuid.UUID, batchSize int32) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ids []uuid.UUID
+	for _, user := range r.expired(deletedBefore) {
+		if bytes.Compare(user.ID[:], afterID[:]) > 0 && len(ids) < int(batchSize) {
+			ids = append(ids, user.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *fakeRetentionUserRepository) CountExpiredDeletedUsers(ctx context.Context, deletedBefore time.Time) (*db.CountExpiredDeletedUsersRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := &db.CountExpiredDeletedUsersRow{}
+	for _, user := range r.expired(deletedBefore) {
+		counts.Users++
+		counts.ApiKeys += r.credentials[user.ID].ApiKeys
+		counts.Identities += r.credentials[user.ID].Identities
+	}
+	return counts, nil
+}
+
+func (r *fakeRetentionUserRepository) PurgeDeletedUser(ctx context.Context, id uuid.UUID, hardDelete bool) (*db.PurgeUserCredentialsRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	purged := r.credentials[id]
+	delete(r.credentials, id)
+	if hardDelete {
+		delete(r.byID, id)
+		return &purged, nil
+	}
+
+	user := r.byID[id]
+	user.Name, user.Username, user.Email = "deleted-"+id.String(), "deleted-"+id.String(), "deleted-"+id.String()+"@invalid"
+	user.AnonymizedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return &purged, nil
+}
+
+// timestampLog stands in for a table pruned by creation or completion time.
+type timestampLog struct {
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (l *timestampLog) deleteBefore(before time.Time, batchSize int32) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var deleted int64
+	l.times = slices.DeleteFunc(l.times, func(at time.Time) bool {
+		if deleted < int64(batchSize) && at.Before(before) {
+			deleted++
+			return true
+		}
+		return false
+	})
+	return deleted
+}
+
+func (l *timestampLog) countBefore(before time.Time) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var count int64
+	for _, at := range l.times {
+		if at.Before(before) {
+			count++
+		}
+	}
+	return count
+}
+
+type fakeCampaignDeliveryRepository struct {
+	repositories.CampaignRepository
+	timestampLog
+}
+
+func (r *fakeCampaignDeliveryRepository) DeleteFinishedDeliveries(ctx context.Context, finishedBefore time.Time, batchSize int32) (int64, error) {
+	return r.deleteBefore(finishedBefore, batchSize), nil
+}
+
+func (r *fakeCampaignDeliveryRepository) CountFinishedDeliveries(ctx context.Context, finishedBefore time.Time) (int64, error) {
+	return r.countBefore(finishedBefore), nil
+}
+
+type fakeAuditLogRepository struct {
+	repositories.AuditRepository
+	timestampLog
+}
+
+func (r *fakeAuditLogRepository) DeleteBefore(ctx context.Context, createdBefore time.Time, batchSize int32) (int64, error) {
+	return r.deleteBefore(createdBefore, batchSize), nil
+}
+
+func (r *fakeAuditLogRepository) CountBefore(ctx context.Context, createdBefore time.Time) (int64, error) {
+	return r.countBefore(createdBefore), nil
+}
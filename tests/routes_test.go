@@ -0,0 +1,80 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/handlers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/routes"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+type fakeUserService struct {
+	services.UserService
+}
+
+func (fakeUserService) UpdateUser(ctx context.Context, id uuid.UUID, req *models.UserUpdateRequest) (*entities.User, error) {
+	return &entities.User{ID: id, Username: req.Username}, nil
+}
+
+func (fakeUserService) DeleteUser(ctx context.Context, id uuid.UUID) (*entities.User, error) {
+	return &entities.User{ID: id}, nil
+}
+
+// newTestRouter serves the account API with the given handler. Rate limits are counted in
+// memory.
+func newTestRouter(t *testing.T, api *handlers.UserHandler, tokenService token.TokenService) *echo.Echo {
+	t.Helper()
+
+	e := echo.New()
+	routes.InitRoutes(e, api, routes.Options{
+		TokenService: tokenService,
+		RateLimiter:  newFakeRateLimiter(),
+	})
+	return e
+}
+
+func serve(e *echo.Echo, method string, path string, bearer string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if bearer != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+bearer)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAccountChangesRequireASession(t *testing.T) {
+	userID := uuid.New()
+	e := newTestRouter(t, &handlers.UserHandler{UserService: fakeUserService{}}, newFakeAuthTokenService(userID))
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: http.MethodPut, path: "/api/v1/accounts/update", body: `{"email":"attacker@example.com","password":"new-password"}`},
+		{method: http.MethodDelete, path: "/api/v1/accounts/delete/" + userID.String()},
+	}
+
+	for _, r := range requests {
+		t.Run(r.method+" "+r.path, func(t *testing.T) {
+			if rec := serve(e, r.method, r.path, testAPIKey, r.body); rec.Code != http.StatusForbidden {
+				t.Fatalf("API key got %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body)
+			}
+			if rec := serve(e, r.method, r.path, testSessionToken, r.body); rec.Code != http.StatusOK {
+				t.Fatalf("session got %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	accountpb "github.com/RehanAthallahAzhar/shopeezy-protos/pb/account"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	authgrpc "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/grpc"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/handlers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/middlewares"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+const (
+	testOrgAdminToken      = "org-admin-token"
+	testNoStoreAdminToken  = "no-store-admin-token"
+	testPlatformAdminToken = "platform-admin-token"
+)
+
+func TestAdminUserAccessIsScopedToTheTenant(t *testing.T) {
+	orgA, orgB := uuid.New(), uuid.New()
+	memberOfA, memberOfB := uuid.New(), uuid.New()
+
+	tenancy := newFakeTenancyRepository()
+	tenancy.join(memberOfA, orgA)
+	tenancy.join(memberOfB, orgB)
+
+	directory := &fakeDirectoryService{}
+	log := newTestLogger(t)
+	api := &handlers.UserHandler{
+		UserService:          directory,
+		TenancyService:       services.NewTenancyService(tenancy, nil, nil, log),
+		LoginThrottleService: fakeLoginThrottleService{},
+	}
+
+	admin := uuid.New()
+	tokens := newFakeAuthTokenService(admin)
+	tokens.claims[testOrgAdminToken] = &token.JWTClaims{UserID: admin, AMR: []string{entities.AMRPassword}, EmailVerified: true,
+		Permissions: []string{entities.PermUsersRead}, OrganizationID: orgA.String()}
+	tokens.claims[testNoStoreAdminToken] = &token.JWTClaims{UserID: admin, AMR: []string{entities.AMRPassword}, EmailVerified: true,
+		Permissions: []string{entities.PermUsersRead}}
+	tokens.claims[testPlatformAdminToken] = &token.JWTClaims{UserID: admin, AMR: []string{entities.AMRPassword}, EmailVerified: true,
+		Permissions: []string{entities.PermUsersRead, entities.PermTenantsManage}}
+	e := newTestRouter(t, api, tokens)
+
+	tests := []struct {
+		name   string
+		bearer string
+		path   string
+		want   int
+		// wantScope is the organization the listing must be filtered by
+		wantScope uuid.NullUUID
+	}{
+		{name: "list without active store", bearer: testNoStoreAdminToken, path: "/api/v1/accounts/list", want: http.StatusForbidden},
+		{name: "search without active store", bearer: testNoStoreAdminToken, path: "/api/v1/accounts/search?q=jane", want: http.StatusForbidden},
+		{name: "get without active store", bearer: testNoStoreAdminToken, path: "/api/v1/accounts/" + memberOfA.String(), want: http.StatusForbidden},
+		{name: "list of own tenant", bearer: testOrgAdminToken, path: "/api/v1/accounts/list", want: http.StatusOK, wantScope: uuid.NullUUID{UUID: orgA, Valid: true}},
+		{name: "search of own tenant", bearer: testOrgAdminToken, path: "/api/v1/accounts/search?q=jane", want: http.StatusOK, wantScope: uuid.NullUUID{UUID: orgA, Valid: true}},
+		{name: "get member of own tenant", bearer: testOrgAdminToken, path: "/api/v1/accounts/" + memberOfA.String(), want: http.StatusOK},
+		{name: "get member of other tenant", bearer: testOrgAdminToken, path: "/api/v1/accounts/" + memberOfB.String(), want: http.StatusNotFound},
+		{name: "platform admin lists every tenant", bearer: testPlatformAdminToken, path: "/api/v1/accounts/list", want: http.StatusOK},
+		{name: "platform admin gets any user", bearer: testPlatformAdminToken, path: "/api/v1/accounts/" + memberOfB.String(), want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory.reset()
+			if rec := serve(e, http.MethodGet, tt.path, tt.bearer, ""); rec.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if scope, listed := directory.lastScope(); listed && scope != tt.wantScope {
+				t.Fatalf("listing scoped to %v, want %v", scope, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestAdminUserActionsAreScopedToTheTenant(t *testing.T) {
+	orgA, orgB := uuid.New(), uuid.New()
+	memberOfA, memberOfB := uuid.New(), uuid.New()
+
+	tenancy := newFakeTenancyRepository()
+	tenancy.join(memberOfA, orgA)
+	tenancy.join(memberOfB, orgB)
+
+	actions := &adminActions{}
+	api := &handlers.UserHandler{
+		UserService:          &fakeDirectoryService{adminActions: actions},
+		TenancyService:       services.NewTenancyService(tenancy, nil, nil, newTestLogger(t)),
+		LoginThrottleService: fakeLoginThrottleService{adminActions: actions},
+		SessionService:       fakeAdminSessionService{adminActions: actions},
+		MFAService:           fakeAdminMFAService{adminActions: actions},
+		RoleService:          fakeAdminRoleService{adminActions: actions},
+	}
+
+	admin := uuid.New()
+	permissions := []string{entities.PermUsersManage, entities.PermUsersDelete, entities.PermRolesRead, entities.PermRolesManage}
+	tokens := newFakeAuthTokenService(admin)
+	tokens.claims[testOrgAdminToken] = &token.JWTClaims{UserID: admin, AMR: []string{entities.AMRPassword}, EmailVerified: true,
+		Permissions: permissions, OrganizationID: orgA.String()}
+	tokens.claims[testNoStoreAdminToken] = &token.JWTClaims{UserID: admin, AMR: []string{entities.AMRPassword}, EmailVerified: true,
+		Permissions: permissions}
+	tokens.claims[testPlatformAdminToken] = &token.JWTClaims{UserID: admin, AMR: []string{entities.AMRPassword}, EmailVerified: true,
+		Permissions: append([]string{entities.PermTenantsManage}, permissions...)}
+	e := newTestRouter(t, api, tokens)
+
+	actionsOn := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{name: "revoke sessions", method: http.MethodPost, path: "/api/v1/accounts/%s/revoke-sessions"},
+		{name: "reset MFA", method: http.MethodPost, path: "/api/v1/accounts/%s/mfa/reset"},
+		{name: "unlock", method: http.MethodPost, path: "/api/v1/accounts/%s/unlock"},
+		{name: "list roles", method: http.MethodGet, path: "/api/v1/accounts/%s/roles"},
+		{name: "grant role", method: http.MethodPost, path: "/api/v1/accounts/%s/roles", body: `{"role":"support"}`},
+		{name: "revoke role", method: http.MethodDelete, path: "/api/v1/accounts/%s/roles/support"},
+		{name: "delete", method: http.MethodDelete, path: "/api/v1/accounts/delete/%s"},
+	}
+	callers := []struct {
+		name   string
+		bearer string
+		target uuid.UUID
+		want   int
+	}{
+		{name: "member of own tenant", bearer: testOrgAdminToken, target: memberOfA, want: http.StatusOK},
+		{name: "member of other tenant", bearer: testOrgAdminToken, target: memberOfB, want: http.StatusNotFound},
+		{name: "without active store", bearer: testNoStoreAdminToken, target: memberOfA, want: http.StatusForbidden},
+		{name: "platform admin", bearer: testPlatformAdminToken, target: memberOfB, want: http.StatusOK},
+	}
+
+	for _, action := range actionsOn {
+		for _, caller := range callers {
+			t.Run(action.name+" "+caller.name, func(t *testing.T) {
+				actions.take()
+				if rec := serve(e, action.method, fmt.Sprintf(action.path, caller.target), caller.bearer, action.body); rec.Code != caller.want {
+					t.Fatalf("got %d, want %d: %s", rec.Code, caller.want, rec.Body)
+				}
+				acted := actions.take()
+				if caller.want == http.StatusOK && (len(acted) != 1 || acted[0] != caller.target) {
+					t.Fatalf("acted on %v, want %s", acted, caller.target)
+				}
+				if caller.want != http.StatusOK && len(acted) != 0 {
+					t.Fatalf("acted on %v of a refused request", acted)
+				}
+			})
+		}
+	}
+}
+
+func TestGRPCUserListingIsScopedToTheTenant(t *testing.T) {
+	orgA := uuid.New()
+	admin := uuid.New()
+	directory := &fakeDirectoryService{}
+
+	tokens := newFakeAuthTokenService(admin)
+	tokens.claims[testOrgAdminToken] = &token.JWTClaims{UserID: admin, Permissions: []string{entities.PermUsersRead}, OrganizationID: orgA.String()}
+	tokens.claims[testNoStoreAdminToken] = &token.JWTClaims{UserID: admin, Permissions: []string{entities.PermUsersRead}}
+	tokens.claims[testPlatformAdminToken] = &token.JWTClaims{UserID: admin, Permissions: []string{entities.PermUsersRead, entities.PermTenantsManage}}
+
+	search := authgrpc.NewSearchServer(directory)
+	interceptor := middlewares.UnaryPermissionInterceptor(middlewares.GRPCPermissionOptions{
+		TokenService: tokens,
+		Methods:      map[string]string{"/account.AccountSearchService/SearchUsers": entities.PermUsersRead},
+	})
+	searchAs := func(bearer string) error {
+		ctx := grpc.NewContextWithServerTransportStream(
+			metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+bearer)),
+			&fakeServerStream{})
+		_, err := interceptor(ctx, wrapperspb.String("jane"), &grpc.UnaryServerInfo{FullMethod: "/account.AccountSearchService/SearchUsers"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return search.SearchUsers(ctx, req.(*wrapperspb.StringValue))
+			})
+		return err
+	}
+
+	accounts := authgrpc.NewAccountServer(directory)
+	listFor := func(organizationID string) error {
+		md := metadata.MD{}
+		if organizationID != "" {
+			md.Set(authgrpc.OrganizationIDHeader, organizationID)
+		}
+		ctx := grpc.NewContextWithServerTransportStream(metadata.NewIncomingContext(context.Background(), md), &fakeServerStream{})
+		_, err := accounts.GetUsers(ctx, &accountpb.GetUsersRequest{})
+		return err
+	}
+
+	tests := []struct {
+		name      string
+		call      func() error
+		want      codes.Code
+		wantScope uuid.NullUUID
+	}{
+		{name: "search of own tenant", call: func() error { return searchAs(testOrgAdminToken) }, wantScope: uuid.NullUUID{UUID: orgA, Valid: true}},
+		{name: "search without active store", call: func() error { return searchAs(testNoStoreAdminToken) }, want: codes.PermissionDenied},
+		{name: "platform admin searches every tenant", call: func() error { return searchAs(testPlatformAdminToken) }},
+		{name: "listing for a tenant", call: func() error { return listFor(orgA.String()) }, wantScope: uuid.NullUUID{UUID: orgA, Valid: true}},
+		{name: "listing without a tenant", call: func() error { return listFor("") }, want: codes.PermissionDenied},
+		{name: "listing for a malformed tenant", call: func() error { return listFor("acme") }, want: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory.reset()
+			if code := status.Code(tt.call()); code != tt.want {
+				t.Fatalf("code = %s, want %s", code, tt.want)
+			}
+			scope, listed := directory.lastScope()
+			if listed != (tt.want == codes.OK) || scope != tt.wantScope {
+				t.Fatalf("listed %t scoped to %v, want %v", listed, scope, tt.wantScope)
+			}
+		})
+	}
+}
+
+// adminActions records the users the admin actions of a test were applied to.
+type adminActions struct {
+	mu    sync.Mutex
+	acted []uuid.UUID
+}
+
+func (a *adminActions) record(userID uuid.UUID) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.acted = append(a.acted, userID)
+	return nil
+}
+
+// take returns the recorded users and starts over.
+func (a *adminActions) take() []uuid.UUID {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	acted := a.acted
+	a.acted = nil
+	return acted
+}
+
+type fakeAdminSessionService struct {
+	services.SessionService
+	*adminActions
+}
+
+func (s fakeAdminSessionService) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	return s.record(userID)
+}
+
+type fakeAdminMFAService struct {
+	services.MFAService
+	*adminActions
+}
+
+func (s fakeAdminMFAService) ResetMFA(ctx context.Context, userID uuid.UUID) error {
+	return s.record(userID)
+}
+
+type fakeAdminRoleService struct {
+	services.RoleService
+	*adminActions
+}
+
+func (s fakeAdminRoleService) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]entities.Role, error) {
+	return nil, s.record(userID)
+}
+
+func (s fakeAdminRoleService) GrantRole(ctx context.Context, userID uuid.UUID, roleName string, grantedBy uuid.UUID) error {
+	return s.record(userID)
+}
+
+func (s fakeAdminRoleService) RevokeRole(ctx context.Context, userID uuid.UUID, roleName string, revokedBy uuid.UUID) error {
+	return s.record(userID)
+}
+
+// fakeServerStream lets handlers set response headers outside of a gRPC server.
+type fakeServerStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *fakeServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+// fakeDirectoryService returns empty listings and records the organization they were scoped to.
+type fakeDirectoryService struct {
+	services.UserService
+	*adminActions
+	mu     sync.Mutex
+	scope  uuid.NullUUID
+	listed bool
+}
+
+func (s *fakeDirectoryService) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scope, s.listed = uuid.NullUUID{}, false
+}
+
+func (s *fakeDirectoryService) lastScope() (uuid.NullUUID, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scope, s.listed
+}
+
+func (s *fakeDirectoryService) ListUsers(ctx context.Context, query *models.UserListQuery) (*services.UserPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scope, s.listed = query.OrganizationID, true
+	return &services.UserPage{}, nil
+}
+
+func (s *fakeDirectoryService) SearchUsers(ctx context.Context, query *models.UserSearchQuery) (*services.UserPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scope, s.listed = query.OrganizationID, true
+	return &services.UserPage{}, nil
+}
+
+func (s *fakeDirectoryService) DeleteUser(ctx context.Context, id uuid.UUID) (*entities.User, error) {
+	return &entities.User{ID: id, Username: "member"}, s.record(id)
+}
+
+func (s *fakeDirectoryService) GetUserByID(ctx context.Context, id uuid.UUID) (*entities.User, error) {
+	return &entities.User{ID: id, Username: "member"}, nil
+}
+
+type fakeLoginThrottleService struct {
+	services.LoginThrottleService
+	*adminActions
+}
+
+func (fakeLoginThrottleService) GetLockStatus(ctx context.Context, username string) (*services.LockStatus, error) {
+	return &services.LockStatus{}, nil
+}
+
+func (s fakeLoginThrottleService) Unlock(ctx context.Context, userID uuid.UUID) error {
+	return s.record(userID)
+}
@@ -0,0 +1,140 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/token"
+)
+
+// waitForSecondStart sleeps until just after the next full second, so the following steps run
+// within a single second.
+func waitForSecondStart() {
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second + 10*time.Millisecond)))
+}
+
+func TestRevokeAllAcceptsTokensIssuedInTheSameSecond(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	user := f.user("jane")
+
+	waitForSecondStart()
+	start := time.Now()
+	before, err := f.tokens.GenerateTokenPair(ctx, user, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := f.tokens.RevokeAllUserTokens(ctx, user.ID); err != nil {
+		t.Fatalf("RevokeAllUserTokens: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	after, err := f.tokens.GenerateTokenPair(ctx, user, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if !time.Now().Truncate(time.Second).Equal(start.Truncate(time.Second)) {
+		t.Skip("the steps did not run within one second")
+	}
+
+	if valid, _ := f.validate(t, before.AccessToken); valid {
+		t.Fatal("token issued before the revocation is still valid")
+	}
+	if valid, msg := f.validate(t, after.AccessToken); !valid {
+		t.Fatalf("token issued after the revocation in the same second was rejected: %s", msg)
+	}
+
+	// The next revocation cuts off the token stamped past the first one as well
+	time.Sleep(5 * time.Millisecond)
+	if err := f.tokens.RevokeAllUserTokens(ctx, user.ID); err != nil {
+		t.Fatalf("RevokeAllUserTokens: %v", err)
+	}
+	if valid, _ := f.validate(t, after.AccessToken); valid {
+		t.Fatal("token is still valid after a second revocation")
+	}
+	latest, err := f.tokens.GenerateTokenPair(ctx, user, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if valid, msg := f.validate(t, latest.AccessToken); !valid {
+		t.Fatalf("token issued after the second revocation was rejected: %s", msg)
+	}
+}
+
+func TestRefreshRotatesAndDetectsReuse(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	user := f.user("jane")
+
+	first, err := f.tokens.GenerateTokenPair(ctx, user, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	second, err := f.tokens.Refresh(ctx, first.RefreshToken, token.IssueOptions{})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("Refresh returned the same refresh token")
+	}
+	if valid, msg := f.validate(t, second.AccessToken); !valid {
+		t.Fatalf("refreshed access token was rejected: %s", msg)
+	}
+
+	// Replaying the rotated token revokes the whole session, the stolen copy and the real one
+	if _, err := f.tokens.Refresh(ctx, first.RefreshToken, token.IssueOptions{}); !errors.Is(err, apperrors.ErrRefreshTokenReused) {
+		t.Fatalf("replay error = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := f.tokens.Refresh(ctx, second.RefreshToken, token.IssueOptions{}); !errors.Is(err, apperrors.ErrInvalidToken) {
+		t.Fatalf("refresh after reuse error = %v, want ErrInvalidToken", err)
+	}
+	if valid, _ := f.validate(t, second.AccessToken); valid {
+		t.Fatal("access token of the revoked session is still valid")
+	}
+	if !slices.Contains(f.audit.actions(user.ID), entities.AuditRefreshTokenReused) {
+		t.Fatalf("audit log %v has no %s event", f.audit.actions(user.ID), entities.AuditRefreshTokenReused)
+	}
+}
+
+func TestConcurrentRefreshRotatesOnce(t *testing.T) {
+	ctx := context.Background()
+	f := newTokenFixture(t)
+	user := f.user("jane")
+
+	pair, err := f.tokens.GenerateTokenPair(ctx, user, token.IssueOptions{AMR: []string{entities.AMRPassword}})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+
+	const callers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = f.tokens.Refresh(ctx, pair.RefreshToken, token.IssueOptions{})
+		}(i)
+	}
+	wg.Wait()
+
+	var rotated int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			rotated++
+		case !errors.Is(err, apperrors.ErrRefreshTokenReused) && !errors.Is(err, apperrors.ErrInvalidToken):
+			// Losers either detect the reuse or find the family revoked by an earlier loser
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if rotated != 1 {
+		t.Fatalf("%d concurrent refreshes succeeded, want 1", rotated)
+	}
+}
@@ -0,0 +1,229 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/handlers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/repositories"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/audit"
+)
+
+func TestUserRepositoryNamesTheTakenField(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        *pq.Error
+		wantField  string
+		isConflict bool
+	}{
+		{name: "name", err: &pq.Error{Code: "23505", Constraint: "users_name_key"}, wantField: "name", isConflict: true},
+		{name: "username", err: &pq.Error{Code: "23505", Constraint: "idx_users_username_lower_unique"}, wantField: "username", isConflict: true},
+		{name: "email", err: &pq.Error{Code: "23505", Constraint: "idx_users_email_lower_unique"}, wantField: "email", isConflict: true},
+		{name: "other unique constraint", err: &pq.Error{Code: "23505", Constraint: "users_pkey"}, wantField: "user", isConflict: true},
+		{name: "not a unique violation", err: &pq.Error{Code: "23503", Constraint: "users_role_fkey"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB := sql.OpenDB(usersConnector{err: tt.err})
+			defer sqlDB.Close()
+			repo := repositories.NewUserRepository(sqlDB, db.New(sqlDB), newTestLogger(t))
+
+			_, createErr := repo.CreateUser(context.Background(), &db.CreateUserParams{ID: uuid.New(), Name: "Jane", Username: "jane", Email: "jane@example.com"})
+			_, updateErr := repo.UpdateUser(context.Background(), &db.UpdateUserParams{ID: uuid.New(), Name: "Jane", Username: "jane", Email: "jane@example.com"})
+
+			for op, err := range map[string]error{"CreateUser": createErr, "UpdateUser": updateErr} {
+				var conflict *apperrors.ConflictError
+				switch {
+				case errors.As(err, &conflict) != tt.isConflict:
+					t.Fatalf("%s error = %v, conflict %v", op, err, tt.isConflict)
+				case tt.isConflict && (conflict.Field != tt.wantField || !errors.Is(err, apperrors.ErrUserAlreadyExists)):
+					t.Fatalf("%s error = %v (field %q), want an ErrUserAlreadyExists on %q", op, err, conflict.Field, tt.wantField)
+				case !tt.isConflict && !errors.Is(err, tt.err):
+					t.Fatalf("%s error = %v, want the driver error wrapped", op, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterRefusesTakenUsernamesAndEmailsIgnoringCase(t *testing.T) {
+	f := newVerificationFixture(t)
+	f.user("jane")
+	deleted := f.user("tom")
+	f.users.mu.Lock()
+	f.users.byID[deleted.ID].DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	f.users.mu.Unlock()
+
+	users := services.NewUserService(f.users, validator.New(), f.tokens, f.blacklist, nil,
+		services.EmailVerificationOff, audit.NewRecorder(f.audit, f.log), f.log)
+	e := newTestRouter(t, &handlers.UserHandler{UserService: users, EmailVerificationService: f.service}, f.tokens)
+
+	tests := []struct {
+		name      string
+		req       models.UserRegisterRequest
+		wantCode  int
+		wantField string
+	}{
+		{name: "username in another case", req: models.UserRegisterRequest{Name: "Jane Doe", Username: "JANE", Email: "jane.doe@example.com"}, wantCode: http.StatusConflict, wantField: "username"},
+		{name: "email in another case", req: models.UserRegisterRequest{Name: "Jane Doe", Username: "janedoe", Email: "Jane@Example.COM"}, wantCode: http.StatusConflict, wantField: "email"},
+		{name: "name", req: models.UserRegisterRequest{Name: "jane", Username: "janedoe", Email: "jane.doe@example.com"}, wantCode: http.StatusConflict, wantField: "name"},
+		{name: "username of a deleted account", req: models.UserRegisterRequest{Name: "Tom Doe", Username: "Tom", Email: "tom@example.com"}, wantCode: http.StatusCreated},
+		{name: "new account", req: models.UserRegisterRequest{Name: "Jane Doe", Username: "janedoe", Email: "jane.doe@example.com"}, wantCode: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Password = "s3cret-password"
+			body, _ := json.Marshal(tt.req)
+
+			rec := serve(e, http.MethodPost, "/api/v1/accounts/register", "", string(body))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("register got %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantField != "" {
+				assertConflictField(t, rec.Body.Bytes(), tt.wantField)
+			}
+		})
+	}
+}
+
+func TestUpdateRefusesTakenUsernamesAndEmailsIgnoringCase(t *testing.T) {
+	f := newVerificationFixture(t)
+	f.user("jane")
+	john := f.user("john")
+
+	users := services.NewUserService(f.users, validator.New(), f.tokens, f.blacklist, nil,
+		services.EmailVerificationOff, audit.NewRecorder(f.audit, f.log), f.log)
+	e := newTestRouter(t, &handlers.UserHandler{UserService: users, EmailVerificationService: f.service}, f.tokens)
+	johnToken := f.accessToken(t, john)
+
+	tests := []struct {
+		name      string
+		req       models.UserUpdateRequest
+		wantCode  int
+		wantField string
+	}{
+		{name: "username in another case", req: models.UserUpdateRequest{Name: "john", Username: "Jane", Email: "john@example.com"}, wantCode: http.StatusConflict, wantField: "username"},
+		{name: "email in another case", req: models.UserUpdateRequest{Name: "john", Username: "john", Email: "JANE@example.com"}, wantCode: http.StatusConflict, wantField: "email"},
+		{name: "name", req: models.UserUpdateRequest{Name: "jane", Username: "john", Email: "john@example.com"}, wantCode: http.StatusConflict, wantField: "name"},
+		{name: "own username in another case", req: models.UserUpdateRequest{Name: "john", Username: "John", Email: "John@example.com"}, wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.req)
+
+			rec := serve(e, http.MethodPut, "/api/v1/accounts/update", johnToken, string(body))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("update got %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantField != "" {
+				assertConflictField(t, rec.Body.Bytes(), tt.wantField)
+			}
+		})
+	}
+}
+
+func assertConflictField(t *testing.T, body []byte, want string) {
+	t.Helper()
+
+	var res models.ErrorResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatalf("decode conflict: %v", err)
+	}
+	if res.Field != want {
+		t.Fatalf("conflict on field %q, want %q: %s", res.Field, want, body)
+	}
+}
+
+func (r *fakeUserRepository) UpdateUser(ctx context.Context, param *db.UpdateUserParams) (*db.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.byID[param.ID]
+	if !ok {
+		return nil, fmt.Errorf("failed to update user: %w", sql.ErrNoRows)
+	}
+	if err := r.conflict(param.ID, param.Name, param.Username, param.Email); err != nil {
+		return nil, err
+	}
+
+	user.Name, user.Username, user.Email, user.Password = param.Name, param.Username, param.Email, param.Password
+	user.Address, user.PhoneNumber, user.Role = param.Address, param.PhoneNumber, param.Role
+	user.UpdatedAt = time.Now()
+	updated := *user
+	return &updated, nil
+}
+
+// usersConnector is a database whose user inserts and updates fail with err, as Postgres
+// reports a violated constraint. Looking a user up by id finds a stored user.
+type usersConnector struct {
+	err error
+}
+
+func (c usersConnector) Connect(context.Context) (driver.Conn, error) { return usersConn(c), nil }
+func (c usersConnector) Driver() driver.Driver                        { return nil }
+
+type usersConn struct {
+	err error
+}
+
+func (c usersConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+func (c usersConn) Close() error              { return nil }
+func (c usersConn) Begin() (driver.Tx, error) { return usersTx{}, nil }
+
+func (c usersConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	// sqlc starts every query with its name
+	switch {
+	case strings.HasPrefix(query, "-- name: CreateUser "), strings.HasPrefix(query, "-- name: UpdateUser "):
+		return nil, c.err
+	case strings.HasPrefix(query, "-- name: GetUserByID "):
+		now := time.Now()
+		return &usersRows{row: []driver.Value{args[0].Value, "Jane", "jane", "jane@example.com", "hash", "", "", "user", now, now, nil}}, nil
+	default:
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+}
+
+type usersTx struct{}
+
+func (usersTx) Commit() error   { return nil }
+func (usersTx) Rollback() error { return nil }
+
+// usersRows returns row once.
+type usersRows struct {
+	row  []driver.Value
+	done bool
+}
+
+func (r *usersRows) Columns() []string { return make([]string, len(r.row)) }
+func (r *usersRows) Close() error      { return nil }
+
+func (r *usersRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.row)
+	return nil
+}
@@ -0,0 +1,302 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/handlers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services/audit"
+)
+
+// directoryFixture is a user service whose listings run on the users of the token fixture.
+type directoryFixture struct {
+	*tokenFixture
+	directory *fakeUserDirectoryRepository
+	service   services.UserService
+}
+
+func newDirectoryFixture(t *testing.T) *directoryFixture {
+	t.Helper()
+
+	f := &directoryFixture{tokenFixture: newTokenFixture(t)}
+	f.directory = &fakeUserDirectoryRepository{fakeUserRepository: f.users, roles: f.roles}
+	f.service = services.NewUserService(f.directory, validator.New(), f.tokens, f.blacklist, nil,
+		services.EmailVerificationOff, audit.NewRecorder(f.audit, f.log), f.log)
+	return f
+}
+
+// member adds a user that signed up at createdAt.
+func (f *directoryFixture) member(name string, createdAt time.Time) *entities.User {
+	user := f.user(name)
+	f.users.mu.Lock()
+	defer f.users.mu.Unlock()
+	f.users.byID[user.ID].CreatedAt = createdAt
+	return user
+}
+
+// listAll follows the cursors of the listing and returns every user it returned.
+func (f *directoryFixture) listAll(t *testing.T, query models.UserListQuery) []entities.User {
+	t.Helper()
+
+	var users []entities.User
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("the listing does not end")
+		}
+		page, err := f.service.ListUsers(context.Background(), &query)
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		if query.Limit > 0 && len(page.Users) > query.Limit {
+			t.Fatalf("page of %d users, want at most %d", len(page.Users), query.Limit)
+		}
+		users = append(users, page.Users...)
+		if page.NextCursor == "" {
+			return users
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func usernames(users []entities.User) []string {
+	names := make([]string, 0, len(users))
+	for _, u := range users {
+		names = append(names, u.Username)
+	}
+	return names
+}
+
+func TestUserListingPagesThroughEveryUser(t *testing.T) {
+	f := newDirectoryFixture(t)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"mia", "jane", "ana", "john", "lisa", "max", "tom"} {
+		// Pairs of users signed up at the same time, the id breaks the tie
+		f.member(name, start.Add(time.Duration(i/2)*time.Hour))
+	}
+
+	sorts := map[string]func(a, b entities.User) int{
+		"created_at": func(a, b entities.User) int { return a.CreatedAt.Compare(b.CreatedAt) },
+		"name":       func(a, b entities.User) int { return strings.Compare(a.Name, b.Name) },
+	}
+
+	for _, sort := range []string{"created_at", "-created_at", "name", "-name"} {
+		t.Run(sort, func(t *testing.T) {
+			users := f.listAll(t, models.UserListQuery{Limit: 3, Sort: sort})
+			if len(users) != 7 {
+				t.Fatalf("listed %v, want all 7 users once", usernames(users))
+			}
+
+			compare := sorts[strings.TrimPrefix(sort, "-")]
+			for i := 1; i < len(users); i++ {
+				c := compare(users[i-1], users[i])
+				if c == 0 {
+					c = bytes.Compare(users[i-1].ID[:], users[i].ID[:])
+				}
+				if strings.HasPrefix(sort, "-") {
+					c = -c
+				}
+				if c >= 0 {
+					t.Fatalf("listed %v, not in %s order", usernames(users), sort)
+				}
+			}
+		})
+	}
+
+	// The default is the newest first
+	page, err := f.service.ListUsers(context.Background(), &models.UserListQuery{})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(page.Users) != 7 || page.NextCursor != "" || page.Users[0].Username != "tom" {
+		t.Fatalf("default listing = %v (cursor %q), want all users newest first", usernames(page.Users), page.NextCursor)
+	}
+}
+
+func TestUserListingFilters(t *testing.T) {
+	f := newDirectoryFixture(t)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.member("jane_doe", start)
+	f.member("janexdoe", start.Add(time.Hour))
+	seller := f.member("max", start.Add(2*time.Hour))
+	f.roles.grant(seller.ID, testSellerRole)
+	deleted := f.member("tom", start.Add(3*time.Hour))
+	f.users.mu.Lock()
+	f.users.byID[deleted.ID].DeletedAt = sql.NullTime{Time: start.Add(4 * time.Hour), Valid: true}
+	f.users.mu.Unlock()
+
+	tests := []struct {
+		name  string
+		query models.UserListQuery
+		want  []string
+	}{
+		{name: "active", want: []string{"jane_doe", "janexdoe", "max"}},
+		{name: "with deleted", query: models.UserListQuery{Deleted: "include"}, want: []string{"jane_doe", "janexdoe", "max", "tom"}},
+		{name: "only deleted", query: models.UserListQuery{Deleted: "only"}, want: []string{"tom"}},
+		{name: "role", query: models.UserListQuery{Role: testSellerRole}, want: []string{"max"}},
+		{name: "created range", query: models.UserListQuery{CreatedFrom: start.Add(time.Hour).Format(time.RFC3339), CreatedTo: start.Add(2 * time.Hour).Format(time.RFC3339)}, want: []string{"janexdoe"}},
+		{name: "search", query: models.UserListQuery{Search: " JANE "}, want: []string{"jane_doe", "janexdoe"}},
+		{name: "search wildcard", query: models.UserListQuery{Search: "jane_"}, want: []string{"jane_doe"}},
+		{name: "search email", query: models.UserListQuery{Search: "max@example"}, want: []string{"max"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Sort = "created_at"
+			if got := usernames(f.listAll(t, tt.query)); !slices.Equal(got, tt.want) {
+				t.Fatalf("listed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserListingRejectsBadQueries(t *testing.T) {
+	f := newDirectoryFixture(t)
+	for _, name := range []string{"jane", "john", "max"} {
+		f.user(name)
+	}
+	page, err := f.service.ListUsers(context.Background(), &models.UserListQuery{Limit: 1, Sort: "name"})
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("ListUsers = %v, want a next page", err)
+	}
+
+	tests := []struct {
+		name  string
+		query models.UserListQuery
+	}{
+		{name: "negative limit", query: models.UserListQuery{Limit: -1}},
+		{name: "limit too high", query: models.UserListQuery{Limit: 201}},
+		{name: "unknown sort", query: models.UserListQuery{Sort: "password"}},
+		{name: "unknown deleted", query: models.UserListQuery{Deleted: "all"}},
+		{name: "malformed date", query: models.UserListQuery{CreatedFrom: "yesterday"}},
+		{name: "malformed cursor", query: models.UserListQuery{Cursor: "not-a-cursor"}},
+		{name: "cursor of another sort", query: models.UserListQuery{Sort: "created_at", Cursor: page.NextCursor}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.service.ListUsers(context.Background(), &tt.query); !errors.Is(err, apperrors.ErrInvalidRequestPayload) {
+				t.Fatalf("ListUsers error = %v, want ErrInvalidRequestPayload", err)
+			}
+		})
+	}
+}
+
+func TestUserListingEndpoint(t *testing.T) {
+	f := newDirectoryFixture(t)
+	admin := f.user("admin")
+	// A platform admin, the listing is not limited to one tenant
+	f.roles.grant(admin.ID, testAdminRole, entities.PermUsersRead, entities.PermTenantsManage)
+	for _, name := range []string{"jane", "john", "max"} {
+		f.user(name)
+	}
+
+	e := newTestRouter(t, &handlers.UserHandler{UserService: f.service}, f.tokens)
+	adminToken := f.accessToken(t, admin)
+
+	var listed []string
+	query := url.Values{"limit": {"3"}, "sort": {"name"}}
+	for {
+		rec := serve(e, http.MethodGet, "/api/v1/accounts/list?"+query.Encode(), adminToken, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("listing got %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		var res struct {
+			Data models.UserListResponse `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatalf("decode listing: %v", err)
+		}
+		for _, u := range res.Data.Users {
+			listed = append(listed, u.Username)
+		}
+		if res.Data.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", res.Data.NextCursor)
+	}
+	if want := []string{"admin", "jane", "john", "max"}; !slices.Equal(listed, want) {
+		t.Fatalf("listed %v, want %v", listed, want)
+	}
+
+	if rec := serve(e, http.MethodGet, "/api/v1/accounts/list?sort=password", adminToken, ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("listing with an unknown sort got %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := serve(e, http.MethodGet, "/api/v1/accounts/list", f.accessToken(t, f.user("ana")), ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("listing without users:read got %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+// fakeUserDirectoryRepository lists and searches the users of the fake user repository with
+// the filters and keyset order of the queries. Tenant scopes are not applied.
+type fakeUserDirectoryRepository struct {
+	*fakeUserRepository
+	roles *fakeRoleRepository
+}
+
+func (r *fakeUserDirectoryRepository) ListUsers(ctx context.Context, param *db.ListUsersParams) ([]db.ListUsersRow, error) {
+	// The service escapes the ILIKE wildcards of the search term
+	search := strings.ToLower(strings.NewReplacer(`\\`, `\`, `\%`, `%`, `\_`, `_`).Replace(param.Search.String))
+
+	r.mu.Lock()
+	var rows []db.ListUsersRow
+	for _, u := range r.byID {
+		r.roles.mu.Lock()
+		inRole := slices.Contains(r.roles.roles[u.ID], param.Role.String)
+		r.roles.mu.Unlock()
+
+		switch {
+		case param.Role.Valid && !inRole,
+			param.CreatedFrom.Valid && u.CreatedAt.Before(param.CreatedFrom.Time),
+			param.CreatedTo.Valid && !u.CreatedAt.Before(param.CreatedTo.Time),
+			param.Deleted == "only" && !u.DeletedAt.Valid,
+			param.Deleted == "exclude" && u.DeletedAt.Valid,
+			param.Search.Valid && !strings.Contains(strings.ToLower(u.Name+"\x00"+u.Username+"\x00"+u.Email), search):
+			continue
+		}
+		rows = append(rows, db.ListUsersRow{
+			ID: u.ID, Name: u.Name, Username: u.Username, Email: u.Email, Role: u.Role,
+			CreatedAt: u.CreatedAt, UpdatedAt: u.UpdatedAt, EmailVerifiedAt: u.EmailVerifiedAt, DeletedAt: u.DeletedAt,
+		})
+	}
+	r.mu.Unlock()
+
+	compare := func(a, b db.ListUsersRow) int {
+		c := a.CreatedAt.Compare(b.CreatedAt)
+		if strings.TrimPrefix(param.Sort, "-") == "name" {
+			c = strings.Compare(a.Name, b.Name)
+		}
+		if c == 0 {
+			c = bytes.Compare(a.ID[:], b.ID[:])
+		}
+		if strings.HasPrefix(param.Sort, "-") {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(rows, compare)
+
+	if param.CursorID.Valid {
+		cursor := db.ListUsersRow{ID: param.CursorID.UUID, CreatedAt: param.CursorCreatedAt.Time, Name: param.CursorName.String}
+		rows = slices.DeleteFunc(rows, func(row db.ListUsersRow) bool { return compare(row, cursor) <= 0 })
+	}
+	if int32(len(rows)) > param.PageLimit {
+		rows = rows[:param.PageLimit]
+	}
+	return rows, nil
+}
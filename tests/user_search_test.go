@@ -0,0 +1,290 @@
+package test
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/handlers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+)
+
+// customer adds a user with a display name and phone number next to the username.
+func (f *directoryFixture) customer(username, name, phone string) *entities.User {
+	user := f.user(username)
+	f.users.mu.Lock()
+	defer f.users.mu.Unlock()
+	f.users.byID[user.ID].Name = name
+	f.users.byID[user.ID].PhoneNumber = phone
+	return user
+}
+
+// searchAll follows the cursors of the search and returns every user it found, best first.
+func (f *directoryFixture) searchAll(t *testing.T, query models.UserSearchQuery) []entities.User {
+	t.Helper()
+
+	var users []entities.User
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("the search does not end")
+		}
+		page, err := f.service.SearchUsers(context.Background(), &query)
+		if err != nil {
+			t.Fatalf("SearchUsers(%q): %v", query.Search, err)
+		}
+		if query.Limit > 0 && len(page.Users) > query.Limit {
+			t.Fatalf("page of %d users, want at most %d", len(page.Users), query.Limit)
+		}
+		users = append(users, page.Users...)
+		if page.NextCursor == "" {
+			return users
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func TestUserSearchFindsPartialAndMisspelledMatches(t *testing.T) {
+	f := newDirectoryFixture(t)
+	f.customer("jsmith", "Jonathan Smith", "+62 812 5550 1234")
+	f.customer("joanna", "Joanna Smithers", "+62 813 7770 9999")
+	f.customer("lisam", "Lisa Monroe", "+62 811 2220 3333")
+	deleted := f.customer("jdeleted", "Jonathan Smith", "+62 812 5550 1234")
+	f.users.mu.Lock()
+	f.users.byID[deleted.ID].DeletedAt = sql.NullTime{Valid: true}
+	f.users.mu.Unlock()
+
+	tests := []struct {
+		name   string
+		q      string
+		first  string
+		absent []string
+	}{
+		{name: "misspelled name", q: "Jonathon Smith", first: "jsmith", absent: []string{"lisam", "jdeleted"}},
+		{name: "case", q: "JOANNA", first: "joanna", absent: []string{"jsmith", "lisam"}},
+		{name: "partial phone number", q: "5550 12", first: "jsmith", absent: []string{"joanna", "lisam", "jdeleted"}},
+		{name: "email fragment", q: "lisam@exa", first: "lisam", absent: []string{"jsmith", "joanna"}},
+		{name: "surrounding spaces", q: "  monroe ", first: "lisam", absent: []string{"jsmith", "joanna"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := usernames(f.searchAll(t, models.UserSearchQuery{Search: tt.q}))
+			if len(found) == 0 || found[0] != tt.first {
+				t.Fatalf("search %q found %v, want %s first", tt.q, found, tt.first)
+			}
+			for _, name := range tt.absent {
+				if slices.Contains(found, name) {
+					t.Fatalf("search %q found %v, want no %s", tt.q, found, name)
+				}
+			}
+		})
+	}
+
+	if found := f.searchAll(t, models.UserSearchQuery{Search: "xyzzy"}); len(found) != 0 {
+		t.Fatalf("search for nobody found %v", usernames(found))
+	}
+}
+
+func TestUserSearchRanksTheBestMatchFirst(t *testing.T) {
+	f := newDirectoryFixture(t)
+	// Added in the reverse order of the ranking, the result must not follow insertion
+	f.customer("u3", "Janelle Park", "")
+	f.customer("u2", "Janet Park", "")
+	f.customer("u1", "Jane", "")
+
+	found := usernames(f.searchAll(t, models.UserSearchQuery{Search: "jane"}))
+	if want := []string{"u1", "u2", "u3"}; !slices.Equal(found, want) {
+		t.Fatalf("search found %v, want %v", found, want)
+	}
+}
+
+func TestUserSearchPagesThroughEveryMatch(t *testing.T) {
+	f := newDirectoryFixture(t)
+	f.customer("customer", "Customer", "")
+	for _, n := range []string{"1", "2", "3", "4", "5", "6", "7"} {
+		// Equal scores, the id breaks the tie
+		f.customer("customer"+n, "Shop Customer "+n, "")
+	}
+	f.customer("max", "Max Power", "")
+
+	found := usernames(f.searchAll(t, models.UserSearchQuery{Search: "customer", Limit: 3}))
+	if len(found) != 8 || found[0] != "customer" {
+		t.Fatalf("search found %v, want the 8 customers once, the exact match first", found)
+	}
+	slices.Sort(found)
+	if len(slices.Compact(found)) != 8 {
+		t.Fatalf("search returned a customer twice: %v", found)
+	}
+}
+
+func TestUserSearchRejectsBadQueries(t *testing.T) {
+	f := newDirectoryFixture(t)
+	for _, name := range []string{"jane", "janet", "janelle"} {
+		f.user(name)
+	}
+	page, err := f.service.SearchUsers(context.Background(), &models.UserSearchQuery{Search: "jane", Limit: 1})
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("SearchUsers = %v, want a next page", err)
+	}
+	listing, err := f.service.ListUsers(context.Background(), &models.UserListQuery{Limit: 1})
+	if err != nil || listing.NextCursor == "" {
+		t.Fatalf("ListUsers = %v, want a next page", err)
+	}
+
+	tests := []struct {
+		name  string
+		query models.UserSearchQuery
+	}{
+		{name: "missing q", query: models.UserSearchQuery{}},
+		{name: "blank q", query: models.UserSearchQuery{Search: "   "}},
+		{name: "q too long", query: models.UserSearchQuery{Search: strings.Repeat("a", 101)}},
+		{name: "negative limit", query: models.UserSearchQuery{Search: "jane", Limit: -1}},
+		{name: "limit too high", query: models.UserSearchQuery{Search: "jane", Limit: 201}},
+		{name: "malformed cursor", query: models.UserSearchQuery{Search: "jane", Cursor: "not-a-cursor"}},
+		{name: "cursor of another search", query: models.UserSearchQuery{Search: "janet", Cursor: page.NextCursor}},
+		{name: "cursor of the listing", query: models.UserSearchQuery{Search: "jane", Cursor: listing.NextCursor}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.service.SearchUsers(context.Background(), &tt.query); !errors.Is(err, apperrors.ErrInvalidRequestPayload) {
+				t.Fatalf("SearchUsers error = %v, want ErrInvalidRequestPayload", err)
+			}
+		})
+	}
+}
+
+func TestUserSearchEndpoint(t *testing.T) {
+	f := newDirectoryFixture(t)
+	admin := f.customer("admin", "Site Admin", "")
+	f.roles.grant(admin.ID, testAdminRole, entities.PermUsersRead, entities.PermTenantsManage)
+	f.customer("jsmith", "Jonathan Smith", "+62 812 5550 1234")
+	f.customer("jsmithers", "Jonathan Smithers", "+62 813 7770 9999")
+	f.customer("lisam", "Lisa Monroe", "+62 811 2220 3333")
+
+	e := newTestRouter(t, &handlers.UserHandler{UserService: f.service}, f.tokens)
+	adminToken := f.accessToken(t, admin)
+
+	var found []string
+	query := url.Values{"q": {"Jonathon Smith"}, "limit": {"1"}}
+	for {
+		rec := serve(e, http.MethodGet, "/api/v1/accounts/search?"+query.Encode(), adminToken, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("search got %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		var res struct {
+			Data models.UserListResponse `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatalf("decode search: %v", err)
+		}
+		for _, u := range res.Data.Users {
+			found = append(found, u.Username)
+		}
+		if res.Data.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", res.Data.NextCursor)
+	}
+	if want := []string{"jsmith", "jsmithers"}; !slices.Equal(found, want) {
+		t.Fatalf("search found %v, want %v", found, want)
+	}
+
+	if rec := serve(e, http.MethodGet, "/api/v1/accounts/search", adminToken, ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("search without q got %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := serve(e, http.MethodGet, "/api/v1/accounts/search?q=jane&limit=ten", adminToken, ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("search with a malformed limit got %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := serve(e, http.MethodGet, "/api/v1/accounts/search?q=jane", f.accessToken(t, f.user("ana")), ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("search without users:read got %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+// searchSimilarityThreshold is the default pg_trgm.similarity_threshold of the % operator.
+const searchSimilarityThreshold = 0.3
+
+func (r *fakeUserDirectoryRepository) SearchUsers(ctx context.Context, param *db.SearchUsersParams) ([]db.SearchUsersRow, error) {
+	pattern := strings.ToLower(strings.NewReplacer(`\\`, `\`, `\%`, `%`, `\_`, `_`).Replace(strings.Trim(param.Pattern, "%")))
+
+	r.mu.Lock()
+	var rows []db.SearchUsersRow
+	for _, u := range r.byID {
+		if u.DeletedAt.Valid {
+			continue
+		}
+		// ts_rank is left out, the trigram similarity alone orders the matches
+		var score float64
+		contains := false
+		for _, field := range []string{u.Name, u.Username, u.Email, u.PhoneNumber} {
+			score = max(score, trigramSimilarity(field, param.Query))
+			contains = contains || strings.Contains(strings.ToLower(field), pattern)
+		}
+		if score < searchSimilarityThreshold && !contains {
+			continue
+		}
+		rows = append(rows, db.SearchUsersRow{
+			ID: u.ID, Name: u.Name, Username: u.Username, Email: u.Email, PhoneNumber: u.PhoneNumber, Address: u.Address,
+			Role: u.Role, CreatedAt: u.CreatedAt, UpdatedAt: u.UpdatedAt, EmailVerifiedAt: u.EmailVerifiedAt, Score: float32(score),
+		})
+	}
+	r.mu.Unlock()
+
+	// ORDER BY score DESC, id DESC
+	compare := func(a, b db.SearchUsersRow) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return bytes.Compare(b.ID[:], a.ID[:])
+	}
+	slices.SortFunc(rows, compare)
+
+	if param.CursorID.Valid {
+		cursor := db.SearchUsersRow{ID: param.CursorID.UUID, Score: float32(param.CursorScore.Float64)}
+		rows = slices.DeleteFunc(rows, func(row db.SearchUsersRow) bool { return compare(row, cursor) <= 0 })
+	}
+	if int32(len(rows)) > param.PageLimit {
+		rows = rows[:param.PageLimit]
+	}
+	return rows, nil
+}
+
+// trigramSimilarity is pg_trgm's similarity: the shared trigrams of the lower-cased words,
+// each padded with two spaces in front and one behind, over all trigrams of both.
+func trigramSimilarity(a, b string) float64 {
+	trigrams := func(s string) map[string]bool {
+		set := map[string]bool{}
+		words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		for _, word := range words {
+			padded := []rune("  " + word + " ")
+			for i := 0; i+3 <= len(padded); i++ {
+				set[string(padded[i:i+3])] = true
+			}
+		}
+		return set
+	}
+
+	x, y := trigrams(a), trigrams(b)
+	shared := 0
+	for t := range x {
+		if y[t] {
+			shared++
+		}
+	}
+	if all := len(x) + len(y) - shared; all > 0 {
+		return float64(shared) / float64(all)
+	}
+	return 0
+}
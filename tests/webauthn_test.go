@@ -0,0 +1,465 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/google/uuid"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/db"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/models"
+	apperrors "github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/errors"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:3000"
+)
+
+// Authenticator data flags (WebAuthn §6.1).
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+var b64 = base64.RawURLEncoding
+
+// softAuthenticator is a platform authenticator in software: one P-256 passkey with a
+// signature counter, answering the JSON options the way a browser passes them on.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	counter      uint32
+	userVerified bool
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	credentialID := make([]byte, 32)
+	_, _ = rand.Read(credentialID)
+	return &softAuthenticator{key: key, credentialID: credentialID, userVerified: true}
+}
+
+// options decodes the "publicKey" member of the options the service handed out.
+func options(t *testing.T, ceremony *services.WebAuthnCeremony) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(ceremony.Options)
+	if err != nil {
+		t.Fatalf("marshal options: %v", err)
+	}
+	var wrapper struct {
+		PublicKey map[string]interface{} `json:"publicKey"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		t.Fatalf("unmarshal options: %v", err)
+	}
+	return wrapper.PublicKey
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremonyType string, opts map[string]interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{
+		"type":      ceremonyType,
+		"challenge": opts["challenge"],
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatalf("marshal client data: %v", err)
+	}
+	return data
+}
+
+func (a *softAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	if a.userVerified {
+		flags |= flagUserVerified
+	}
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags|flagUserPresent)
+	return binary.BigEndian.AppendUint32(data, a.counter)
+}
+
+// create answers navigator.credentials.create() with a "none" attestation.
+func (a *softAuthenticator) create(t *testing.T, ceremony *services.WebAuthnCeremony) json.RawMessage {
+	t.Helper()
+	opts := options(t, ceremony)
+	user := opts["user"].(map[string]interface{})
+	userHandle, err := b64.DecodeString(user["id"].(string))
+	if err != nil {
+		t.Fatalf("decode user handle: %v", err)
+	}
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{KeyType: int64(webauthncose.EllipticKey), Algorithm: int64(webauthncose.AlgES256)},
+		Curve:         1, // P-256
+		XCoord:        a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord:        a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+
+	authData := a.authData(flagAttested)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(struct {
+		Format       string                 `cbor:"fmt"`
+		AttStatement map[string]interface{} `cbor:"attStmt"`
+		AuthData     []byte                 `cbor:"authData"`
+	}{Format: "none", AttStatement: map[string]interface{}{}, AuthData: authData})
+	if err != nil {
+		t.Fatalf("marshal attestation: %v", err)
+	}
+
+	return a.credential(t, map[string]interface{}{
+		"clientDataJSON":    b64.EncodeToString(a.clientData(t, "webauthn.create", opts)),
+		"attestationObject": b64.EncodeToString(attestation),
+		"transports":        []string{"internal"},
+	})
+}
+
+// get answers navigator.credentials.get(), counting the signature counter up.
+func (a *softAuthenticator) get(t *testing.T, ceremony *services.WebAuthnCeremony) json.RawMessage {
+	t.Helper()
+	a.counter++
+
+	authData := a.authData(0)
+	clientData := a.clientData(t, "webauthn.get", options(t, ceremony))
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("sign assertion: %v", err)
+	}
+
+	return a.credential(t, map[string]interface{}{
+		"clientDataJSON":    b64.EncodeToString(clientData),
+		"authenticatorData": b64.EncodeToString(authData),
+		"signature":         b64.EncodeToString(signature),
+		"userHandle":        b64.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) credential(t *testing.T, response map[string]interface{}) json.RawMessage {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{
+		"id":       b64.EncodeToString(a.credentialID),
+		"rawId":    b64.EncodeToString(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatalf("marshal credential: %v", err)
+	}
+	return data
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	ctx := context.Background()
+
+	users := newFakeUserRepository()
+	credentials := newFakeWebAuthnRepository()
+	log := newTestLogger(t)
+
+	svc, err := services.NewWebAuthnService(credentials, newFakeWebAuthnSessionRepository(), users, newFakeIdentityRepository(), services.WebAuthnOptions{
+		RPID:       testRPID,
+		RPName:     "Shopeezy",
+		Origins:    []string{testOrigin},
+		SessionTTL: time.Minute,
+	}, log)
+	if err != nil {
+		t.Fatalf("NewWebAuthnService: %v", err)
+	}
+
+	owner := users.add(&db.User{ID: uuid.New(), Name: "Owner", Username: "owner", Email: "owner@example.com"})
+	other := users.add(&db.User{ID: uuid.New(), Name: "Other", Username: "other", Email: "other@example.com", Password: "hash"})
+	authenticator := newSoftAuthenticator(t)
+
+	register := func(t *testing.T, userID uuid.UUID, a *softAuthenticator) error {
+		t.Helper()
+		ceremony, err := svc.BeginRegistration(ctx, userID)
+		if err != nil {
+			t.Fatalf("BeginRegistration: %v", err)
+		}
+		_, err = svc.FinishRegistration(ctx, userID, &models.RegisterPasskeyRequest{
+			SessionID:  ceremony.SessionID,
+			Name:       "Laptop",
+			Credential: a.create(t, ceremony),
+		})
+		return err
+	}
+
+	// Registration stores the key under the user
+	if err := register(t, owner.ID, authenticator); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	passkeys, err := svc.ListCredentials(ctx, owner.ID)
+	if err != nil || len(passkeys) != 1 || passkeys[0].Name != "Laptop" || !bytes.Equal(passkeys[0].CredentialID, authenticator.credentialID) {
+		t.Fatalf("ListCredentials = %+v, %v", passkeys, err)
+	}
+
+	// The same key can not be registered twice, not even by another account
+	copied := *authenticator
+	if err := register(t, other.ID, &copied); !errors.Is(err, apperrors.ErrPasskeyExists) {
+		t.Fatalf("duplicate registration error = %v, want ErrPasskeyExists", err)
+	}
+
+	login := func(t *testing.T) (*services.PasskeyLogin, error) {
+		t.Helper()
+		ceremony, err := svc.BeginLogin(ctx)
+		if err != nil {
+			t.Fatalf("BeginLogin: %v", err)
+		}
+		return svc.FinishLogin(ctx, &models.PasskeyLoginRequest{
+			SessionID:  ceremony.SessionID,
+			Credential: authenticator.get(t, ceremony),
+		})
+	}
+
+	// A user-verifying passkey signs in without a username
+	result, err := login(t)
+	if err != nil {
+		t.Fatalf("passkey login: %v", err)
+	}
+	if result.User.ID != owner.ID || !result.UserVerified {
+		t.Fatalf("passkey login = %+v / %+v, want verified login of %s", result, result.User, owner.ID)
+	}
+
+	// Without user verification the passkey is a single factor
+	authenticator.userVerified = false
+	result, err = login(t)
+	if err != nil || result.UserVerified {
+		t.Fatalf("login without user verification = %+v, %v", result, err)
+	}
+	authenticator.userVerified = true
+
+	// Each challenge is answered once, a replayed assertion is refused
+	ceremony, err := svc.BeginLogin(ctx)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	assertion := authenticator.get(t, ceremony)
+	req := &models.PasskeyLoginRequest{SessionID: ceremony.SessionID, Credential: assertion}
+	if _, err := svc.FinishLogin(ctx, req); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if _, err := svc.FinishLogin(ctx, req); !errors.Is(err, apperrors.ErrInvalidPasskey) {
+		t.Fatalf("replayed assertion error = %v, want ErrInvalidPasskey", err)
+	}
+
+	// A signature counter that goes backwards points to a cloned key
+	authenticator.counter = 0
+	if _, err := login(t); !errors.Is(err, apperrors.ErrInvalidPasskey) {
+		t.Fatalf("login with stale counter error = %v, want ErrInvalidPasskey", err)
+	}
+	authenticator.counter = 100
+
+	// The passkey answers an MFA challenge of its owner, but not of another user
+	ceremony, err = svc.BeginSecondFactor(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("BeginSecondFactor: %v", err)
+	}
+	if err := svc.VerifySecondFactor(ctx, owner.ID, ceremony.SessionID, authenticator.get(t, ceremony)); err != nil {
+		t.Fatalf("VerifySecondFactor: %v", err)
+	}
+	if _, err := svc.BeginSecondFactor(ctx, other.ID); !errors.Is(err, apperrors.ErrInvalidPasskey) {
+		t.Fatalf("second factor without passkey error = %v, want ErrInvalidPasskey", err)
+	}
+	ceremony, err = svc.BeginSecondFactor(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("BeginSecondFactor: %v", err)
+	}
+	if err := svc.VerifySecondFactor(ctx, other.ID, ceremony.SessionID, authenticator.get(t, ceremony)); !errors.Is(err, apperrors.ErrInvalidPasskey) {
+		t.Fatalf("second factor for another user error = %v, want ErrInvalidPasskey", err)
+	}
+
+	// A login session can not finish a registration
+	registration, err := svc.BeginRegistration(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	ceremony, err = svc.BeginLogin(ctx)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	_, err = svc.FinishRegistration(ctx, owner.ID, &models.RegisterPasskeyRequest{
+		SessionID:  ceremony.SessionID,
+		Credential: newSoftAuthenticator(t).create(t, registration),
+	})
+	if !errors.Is(err, apperrors.ErrInvalidPasskey) {
+		t.Fatalf("registration with login session error = %v, want ErrInvalidPasskey", err)
+	}
+
+	// The only sign-in method of an account without a password stays
+	if err := svc.DeleteCredential(ctx, owner.ID, passkeys[0].ID); !errors.Is(err, apperrors.ErrLastSignInMethod) {
+		t.Fatalf("delete last passkey error = %v, want ErrLastSignInMethod", err)
+	}
+	if err := register(t, owner.ID, newSoftAuthenticator(t)); err != nil {
+		t.Fatalf("register second passkey: %v", err)
+	}
+	if err := svc.DeleteCredential(ctx, other.ID, passkeys[0].ID); !errors.Is(err, apperrors.ErrNotFound) {
+		t.Fatalf("delete passkey of another user error = %v, want ErrNotFound", err)
+	}
+	if err := svc.DeleteCredential(ctx, owner.ID, passkeys[0].ID); err != nil {
+		t.Fatalf("DeleteCredential: %v", err)
+	}
+	if _, err := login(t); !errors.Is(err, apperrors.ErrInvalidPasskey) {
+		t.Fatalf("login with removed passkey error = %v, want ErrInvalidPasskey", err)
+	}
+}
+
+type fakeWebAuthnRepository struct {
+	mu          sync.Mutex
+	credentials map[uuid.UUID]*db.WebauthnCredential
+}
+
+func newFakeWebAuthnRepository() *fakeWebAuthnRepository {
+	return &fakeWebAuthnRepository{credentials: map[uuid.UUID]*db.WebauthnCredential{}}
+}
+
+func (r *fakeWebAuthnRepository) CreateCredential(ctx context.Context, param *db.CreateWebauthnCredentialParams) (*db.WebauthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.credentials {
+		if bytes.Equal(existing.CredentialID, param.CredentialID) {
+			return nil, apperrors.ErrPasskeyExists
+		}
+	}
+	row := &db.WebauthnCredential{
+		ID:              uuid.New(),
+		UserID:          param.UserID,
+		CredentialID:    param.CredentialID,
+		PublicKey:       param.PublicKey,
+		AttestationType: param.AttestationType,
+		Aaguid:          param.Aaguid,
+		SignCount:       param.SignCount,
+		Transports:      param.Transports,
+		BackupEligible:  param.BackupEligible,
+		BackupState:     param.BackupState,
+		Name:            param.Name,
+		CreatedAt:       time.Now(),
+	}
+	r.credentials[row.ID] = row
+	return row, nil
+}
+
+func (r *fakeWebAuthnRepository) GetCredential(ctx context.Context, credentialID []byte) (*db.WebauthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, row := range r.credentials {
+		if bytes.Equal(row.CredentialID, credentialID) {
+			found := *row
+			return &found, nil
+		}
+	}
+	return nil, apperrors.ErrNotFound
+}
+
+func (r *fakeWebAuthnRepository) ListCredentials(ctx context.Context, userID uuid.UUID) ([]db.WebauthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var rows []db.WebauthnCredential
+	for _, row := range r.credentials {
+		if row.UserID == userID {
+			rows = append(rows, *row)
+		}
+	}
+	return rows, nil
+}
+
+func (r *fakeWebAuthnRepository) CountCredentials(ctx context.Context, userID uuid.UUID) (int64, error) {
+	rows, err := r.ListCredentials(ctx, userID)
+	return int64(len(rows)), err
+}
+
+func (r *fakeWebAuthnRepository) UpdateCredentialUsage(ctx context.Context, param *db.UpdateWebauthnCredentialUsageParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if row, ok := r.credentials[param.ID]; ok {
+		row.SignCount = param.SignCount
+		row.BackupState = param.BackupState
+	}
+	return nil
+}
+
+func (r *fakeWebAuthnRepository) DeleteCredential(ctx context.Context, param *db.DeleteWebauthnCredentialParams) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.credentials[param.ID]
+	if !ok || row.UserID != param.UserID {
+		return false, nil
+	}
+	delete(r.credentials, param.ID)
+	return true, nil
+}
+
+type fakeWebAuthnSessionRepository struct {
+	mu       sync.Mutex
+	sessions map[string][]byte
+}
+
+func newFakeWebAuthnSessionRepository() *fakeWebAuthnSessionRepository {
+	return &fakeWebAuthnSessionRepository{sessions: map[string][]byte{}}
+}
+
+// SaveSession stores the JSON like the Redis repository, so the session data survives the
+// same round trip.
+func (r *fakeWebAuthnSessionRepository) SaveSession(ctx context.Context, id string, session *models.WebAuthnSession, ttl time.Duration) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[id] = data
+	return nil
+}
+
+func (r *fakeWebAuthnSessionRepository) TakeSession(ctx context.Context, id string) (*models.WebAuthnSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, ok := r.sessions[id]
+	if !ok {
+		return nil, apperrors.ErrInvalidPasskey
+	}
+	delete(r.sessions, id)
+
+	var session models.WebAuthnSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,185 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/consumers"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/entities"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/pkg/rabbitmq"
+	"github.com/RehanAthallahAzhar/shopeezy-accounts/internal/services"
+)
+
+var testConsumeOptions = rabbitmq.ConsumeOptions{
+	MaxRetries:    3,
+	RetryDelay:    time.Second,
+	MaxRetryDelay: 3 * time.Second,
+}
+
+func TestRetryBackoffDoublesUpToTheCap(t *testing.T) {
+	for retries, want := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		if delay := rabbitmq.RetryDelay(testConsumeOptions, retries); delay != want {
+			t.Fatalf("delay after %d retries = %s, want %s", retries, delay, want)
+		}
+	}
+}
+
+func TestHandleDeliverySettlesByTheOutcome(t *testing.T) {
+	failure := errors.New("smtp unavailable")
+	poison := fmt.Errorf("%w: failed to decode", rabbitmq.ErrPoisonMessage)
+
+	tests := []struct {
+		name       string
+		retries    int32
+		handlerErr error
+		publishErr error
+		want       string
+		// wantRetry is the retry number the message is parked for, 0 for none
+		wantRetry int32
+		wantDelay time.Duration
+	}{
+		{name: "handled", want: "ack"},
+		{name: "first failure", handlerErr: failure, want: "ack", wantRetry: 1, wantDelay: time.Second},
+		{name: "later failure backs off", retries: 1, handlerErr: failure, want: "ack", wantRetry: 2, wantDelay: 2 * time.Second},
+		{name: "out of retries", retries: 3, handlerErr: failure, want: "dead-letter"},
+		{name: "poison", handlerErr: poison, want: "dead-letter"},
+		{name: "retry not confirmed", handlerErr: failure, publishErr: rabbitmq.ErrNotConfirmed, want: "requeue", wantRetry: 1, wantDelay: time.Second},
+	}
+
+	client := &rabbitmq.RabbitMQClient{QueueName: "account-events", DeadLetterQueue: "account-events.dlq"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack := &fakeAcknowledger{}
+			retrier := &fakeRetrier{err: tt.publishErr}
+			headers := amqp.Table{"x-source": "outbox"}
+			if tt.retries > 0 {
+				headers[rabbitmq.RetryCountHeader] = tt.retries
+			}
+			delivery := amqp.Delivery{Acknowledger: ack, DeliveryTag: 7, MessageId: "msg-1", Headers: headers, Body: []byte(`{"id":1}`)}
+
+			client.HandleDelivery(context.Background(), delivery, testConsumeOptions, retrier,
+				func(ctx context.Context, msg amqp.Delivery) error { return tt.handlerErr })
+
+			if got := ack.outcome(); got != tt.want {
+				t.Fatalf("delivery was settled with %q, want %q", got, tt.want)
+			}
+			if tt.wantRetry == 0 {
+				if len(retrier.published) != 0 {
+					t.Fatalf("message was parked for a retry: %+v", retrier.published)
+				}
+				return
+			}
+
+			if len(retrier.published) != 1 {
+				t.Fatalf("message was parked %d times, want once", len(retrier.published))
+			}
+			parked := retrier.published[0]
+			if parked.delay != tt.wantDelay || parked.msg.Headers[rabbitmq.RetryCountHeader] != tt.wantRetry {
+				t.Fatalf("parked for %s as retry %v, want %s as retry %d", parked.delay, parked.msg.Headers[rabbitmq.RetryCountHeader], tt.wantDelay, tt.wantRetry)
+			}
+			if string(parked.msg.Body) != `{"id":1}` || parked.msg.MessageId != "msg-1" || parked.msg.Headers["x-source"] != "outbox" {
+				t.Fatalf("the parked copy differs from the message: %+v", parked.msg)
+			}
+		})
+	}
+}
+
+func TestAccountEventConsumerSeparatesPoisonFromFailures(t *testing.T) {
+	notices := &fakeSecurityNoticeService{}
+	consumer := consumers.NewAccountEventConsumer(notices, newTestLogger(t))
+	userID := uuid.New()
+
+	tests := []struct {
+		name       string
+		body       string
+		noticeErr  error
+		wantPoison bool
+		wantErr    bool
+	}{
+		{name: "handled", body: fmt.Sprintf(`{"type":%q,"version":1,"data":{"user_id":%q}}`, entities.EventUserPasswordChanged, userID)},
+		{name: "ignored type", body: `{"type":"user.logged_in","version":1,"data":{}}`},
+		{name: "not JSON", body: `not json`, wantPoison: true, wantErr: true},
+		{name: "newer version", body: fmt.Sprintf(`{"type":%q,"version":%d,"data":{}}`, entities.EventUserPasswordChanged, entities.EventVersion+1), wantPoison: true, wantErr: true},
+		{name: "malformed data", body: fmt.Sprintf(`{"type":%q,"version":1,"data":{"user_id":"jane"}}`, entities.EventUserPasswordChanged), wantPoison: true, wantErr: true},
+		{name: "notice not sent", body: fmt.Sprintf(`{"type":%q,"version":1,"data":{"user_id":%q}}`, entities.EventUserPasswordChanged, userID), noticeErr: errors.New("smtp unavailable"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notices.err = tt.noticeErr
+			err := consumer.Handle(context.Background(), amqp.Delivery{Body: []byte(tt.body)})
+			if (err != nil) != tt.wantErr || errors.Is(err, rabbitmq.ErrPoisonMessage) != tt.wantPoison {
+				t.Fatalf("Handle error = %v, want error %t and poison %t", err, tt.wantErr, tt.wantPoison)
+			}
+		})
+	}
+}
+
+// fakeAcknowledger records how a delivery was settled.
+type fakeAcknowledger struct {
+	mu      sync.Mutex
+	settled []string
+}
+
+func (a *fakeAcknowledger) settle(outcome string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settled = append(a.settled, outcome)
+	return nil
+}
+
+// outcome is the only way the delivery was settled, or a description of what went wrong.
+func (a *fakeAcknowledger) outcome() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.settled) != 1 {
+		return fmt.Sprintf("settled %d times: %v", len(a.settled), a.settled)
+	}
+	return a.settled[0]
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	return a.settle("ack")
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	if requeue {
+		return a.settle("requeue")
+	}
+	return a.settle("dead-letter")
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+type parkedMessage struct {
+	delay time.Duration
+	msg   amqp.Publishing
+}
+
+type fakeRetrier struct {
+	err       error
+	published []parkedMessage
+}
+
+func (r *fakeRetrier) Publish(ctx context.Context, delay time.Duration, msg amqp.Publishing) error {
+	r.published = append(r.published, parkedMessage{delay: delay, msg: msg})
+	return r.err
+}
+
+type fakeSecurityNoticeService struct {
+	services.SecurityNoticeService
+	err error
+}
+
+func (s *fakeSecurityNoticeService) PasswordChanged(ctx context.Context, userID uuid.UUID, changedAt time.Time) error {
+	return s.err
+}